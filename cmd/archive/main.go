@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/caspereijkens/cryptocurrency/internal/analytics"
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+)
+
+// errDone stops reading the block files once the last block of the range is written
+var errDone = errors.New("done")
+
+func main() {
+	var isTestnet bool
+	flag.BoolVar(&isTestnet, "testnet", false, "read testnet block files")
+	var outDir string
+	flag.StringVar(&outDir, "out", ".", "directory txs.csv, inputs.csv and outputs.csv are written to")
+	var from, to int
+	flag.IntVar(&from, "from", 0, "first block to export, counted in the order of the files")
+	flag.IntVar(&to, "to", -1, "last block to export, -1 for all of them")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: archive [-testnet] [-out dir] [-from n] [-to n] blk00000.dat...")
+		os.Exit(2)
+	}
+	if err := export(flag.Args(), outDir, from, to, isTestnet); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// export writes the transactions of blocks from through to of the block files as CSV tables in outDir
+func export(paths []string, outDir string, from, to int, testnet bool) error {
+	var tables []*os.File
+	for _, name := range []string{"txs.csv", "inputs.csv", "outputs.csv"} {
+		f, err := os.Create(filepath.Join(outDir, name))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		tables = append(tables, f)
+	}
+	archive := analytics.NewArchiveWriter(tables[0], tables[1], tables[2], testnet)
+
+	n, written := 0, 0
+	for _, path := range paths {
+		key, err := block.ReadXorKey(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = block.ReadBlockFile(block.Deobfuscate(f, key), testnet, func(fb *block.FullBlock) error {
+			defer func() { n++ }()
+			if n < from {
+				return nil
+			}
+			if to >= 0 && n > to {
+				return errDone
+			}
+			written++
+			return archive.WriteBlock(fb)
+		})
+		f.Close()
+		if errors.Is(err, errDone) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := archive.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d blocks to %s\n", written, outDir)
+	return nil
+}
@@ -3,12 +3,15 @@ package main
 import (
 	"bufio"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/caspereijkens/cryptocurrency/internal/network"
 	"github.com/caspereijkens/cryptocurrency/internal/script"
 	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
 	"github.com/caspereijkens/cryptocurrency/internal/transaction"
@@ -19,14 +22,25 @@ func main() {
 	// Define command-line flags
 	var inFlags, outFlags []string
 	var secret string
+	var broadcast, fees bool
 
 	// Parse command-line arguments
 	flag.Var((*stringSlice)(&inFlags), "in", "Input file(s)")
 	flag.Var((*stringSlice)(&outFlags), "out", "Output file(s)")
+	flag.BoolVar(&broadcast, "broadcast", false, "broadcast the transaction to a testnet peer")
+	flag.BoolVar(&fees, "fees", false, "show the testnet mempool by fee rate and the fee rates to confirm in time, then exit")
 
 	// Parse the command-line
 	flag.Parse()
 
+	if fees {
+		if err := printFees(transaction.NewTxFetcher()); err != nil {
+			fmt.Println("Could not fetch the mempool:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	txIns := parseTxIns(inFlags)
 	txOuts := parseTxOuts(outFlags)
 
@@ -45,7 +59,14 @@ func main() {
 		panic("couldn't create private key with this")
 	}
 
-	tx.SignInput(uint32(0), privateKey)
+	keys := transaction.KeyMap{}
+	for i := range tx.TxIns {
+		keys[i] = privateKey
+	}
+	if _, err := tx.SignAll(keys, nil); err != nil {
+		fmt.Println("Could not sign the transaction:", err)
+		os.Exit(1)
+	}
 
 	fmt.Println("The following transaction was SIGNED:")
 	fmt.Println(tx.String())
@@ -57,9 +78,67 @@ func main() {
 
 	fmt.Printf("The transaction is:\n\n%s\n\n", hex.EncodeToString(txBytes))
 
+	if broadcast {
+		if err := broadcastToPeer(tx); err != nil {
+			fmt.Println("Broadcast failed:", err)
+			os.Exit(1)
+		}
+		fmt.Println("The transaction was accepted by a testnet peer")
+		return
+	}
+
 	fmt.Println("You can broadcast the transaction at https://blockstream.info/testnet/tx/push")
 }
 
+// printFees shows the testnet mempool by fee rate and the fee rate needed to confirm within 1, 3 and 6 blocks
+func printFees(fetcher *transaction.TxFetcher) error {
+	histogram, err := fetcher.FetchFeeHistogram(true)
+	if err != nil {
+		return err
+	}
+	fmt.Print(histogram)
+	for _, blocks := range []int{1, 3, 6} {
+		fmt.Printf("within %d blocks: %.1f sat/vB\n", blocks, histogram.EstimateFeeRate(blocks))
+	}
+	return nil
+}
+
+// broadcastToPeer hands the transaction to the first testnet peer from the DNS seeds that accepts it
+func broadcastToPeer(tx *transaction.Tx) error {
+	addresses, err := network.ResolveSeeds(true, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, address := range addresses {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			continue
+		}
+		portNumber, _ := strconv.Atoi(port)
+		node, err := network.NewSimpleNode(host, portNumber, true, false)
+		if err != nil {
+			continue
+		}
+		err = node.Handshake()
+		if err == nil {
+			err = node.Broadcast(tx)
+		}
+		node.Close()
+
+		var rejectErr *network.RejectError
+		if errors.As(err, &rejectErr) {
+			// another peer would reject it for the same reason
+			return err
+		}
+		if err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no peer accepted the transaction")
+}
+
 // Custom type to handle multiple string values for a flag
 type stringSlice []string
 
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/caspereijkens/cryptocurrency/internal/merkle"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// The vectors are derived from these secrets and messages only, so every run prints the same file
+var (
+	secrets  = []int64{1, 2, 3, 12345, 0xdeadbeef12345}
+	messages = []string{"", "abc", "Programming Bitcoin!", "my message"}
+)
+
+type vectors struct {
+	Keys         []keyVector         `json:"keys"`
+	Signatures   []signatureVector   `json:"signatures"`
+	Transactions []transactionVector `json:"transactions"`
+	Hashes       []hashVector        `json:"hashes"`
+	MerkleRoots  []merkleVector      `json:"merkle_roots"`
+}
+
+type keyVector struct {
+	Secret            string `json:"secret"`
+	SECCompressed     string `json:"sec_compressed"`
+	SECUncompressed   string `json:"sec_uncompressed"`
+	WIF               string `json:"wif"`
+	WIFTestnet        string `json:"wif_testnet"`
+	P2PKH             string `json:"p2pkh"`
+	P2PKHTestnet      string `json:"p2pkh_testnet"`
+	P2PKHUncompressed string `json:"p2pkh_uncompressed"`
+	P2SHP2WPKH        string `json:"p2sh_p2wpkh"`
+	P2WPKH            string `json:"p2wpkh"`
+	P2WPKHTestnet     string `json:"p2wpkh_testnet"`
+}
+
+// signatureVector is an RFC6979 signature of Z, the hash256 of Message read as a big endian number
+type signatureVector struct {
+	Secret  string `json:"secret"`
+	Message string `json:"message"`
+	Z       string `json:"z"`
+	K       string `json:"k"`
+	R       string `json:"r"`
+	S       string `json:"s"`
+	DER     string `json:"der"`
+}
+
+type transactionVector struct {
+	Description string          `json:"description"`
+	Hex         string          `json:"hex"`
+	TxID        string          `json:"txid"`
+	Size        int             `json:"size"`
+	VSize       int             `json:"vsize"`
+	Weight      int             `json:"weight"`
+	SigHashes   []sigHashVector `json:"sighashes"`
+}
+
+// sigHashVector is the signature hash of an input. ScriptCode is the script the legacy hash commits to,
+// Prevouts the outputs the taproot hash commits to.
+type sigHashVector struct {
+	Input      uint32   `json:"input"`
+	Kind       string   `json:"kind"`
+	HashType   uint32   `json:"hash_type"`
+	ScriptCode string   `json:"script_code,omitempty"`
+	Prevouts   []string `json:"prevouts,omitempty"`
+	SigHash    string   `json:"sighash"`
+}
+
+type hashVector struct {
+	Input   string `json:"input"`
+	Hash256 string `json:"hash256"`
+	Hash160 string `json:"hash160"`
+	Sha256  string `json:"sha256"`
+	// TapTweak is the BIP340 tagged hash with the tag TapTweak
+	TapTweak string `json:"tagged_taptweak"`
+}
+
+type merkleVector struct {
+	Leaves []string `json:"leaves"`
+	Root   string   `json:"root"`
+}
+
+func main() {
+	var outPath string
+	flag.StringVar(&outPath, "out", "", "file the vectors are written to, standard output when empty")
+	flag.Parse()
+
+	v, err := generate()
+	if err != nil {
+		fmt.Println("Could not generate the vectors:", err)
+		os.Exit(1)
+	}
+	encoded, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Println("Could not encode the vectors:", err)
+		os.Exit(1)
+	}
+	encoded = append(encoded, '\n')
+
+	if outPath == "" {
+		os.Stdout.Write(encoded)
+		return
+	}
+	if err := os.WriteFile(outPath, encoded, 0o644); err != nil {
+		fmt.Println("Could not write the vectors:", err)
+		os.Exit(1)
+	}
+}
+
+func generate() (*vectors, error) {
+	v := &vectors{}
+	var keys []*signatureverification.PrivateKey
+	for _, secret := range secrets {
+		key, err := signatureverification.NewPrivateKey(big.NewInt(secret))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		keyV, err := newKeyVector(key)
+		if err != nil {
+			return nil, err
+		}
+		v.Keys = append(v.Keys, keyV)
+
+		for _, message := range messages {
+			signatureV, err := newSignatureVector(key, message)
+			if err != nil {
+				return nil, err
+			}
+			v.Signatures = append(v.Signatures, signatureV)
+		}
+	}
+
+	legacy, err := legacyTransaction(keys[0], keys[1])
+	if err != nil {
+		return nil, err
+	}
+	taproot, err := taprootTransaction()
+	if err != nil {
+		return nil, err
+	}
+	v.Transactions = []transactionVector{legacy, taproot}
+
+	for _, message := range messages {
+		data := []byte(message)
+		v.Hashes = append(v.Hashes, hashVector{
+			Input:    hex.EncodeToString(data),
+			Hash256:  hex.EncodeToString(utils.Hash256(data)),
+			Hash160:  hex.EncodeToString(utils.Hash160(data)),
+			Sha256:   hex.EncodeToString(utils.Sha256Hash(data)),
+			TapTweak: hex.EncodeToString(utils.TaggedHash("TapTweak", data)),
+		})
+	}
+
+	for _, n := range []int{1, 2, 3, 7} {
+		var leaves [][]byte
+		var hexLeaves []string
+		for i := 0; i < n; i++ {
+			leaf := utils.Hash256([]byte{byte(i)})
+			leaves = append(leaves, leaf)
+			hexLeaves = append(hexLeaves, hex.EncodeToString(leaf))
+		}
+		root, err := merkle.MerkleRoot(leaves)
+		if err != nil {
+			return nil, err
+		}
+		v.MerkleRoots = append(v.MerkleRoots, merkleVector{Leaves: hexLeaves, Root: hex.EncodeToString(root)})
+	}
+	return v, nil
+}
+
+func newKeyVector(key *signatureverification.PrivateKey) (keyVector, error) {
+	h160 := key.Point.Hash160(true)
+	p2wpkh, err := utils.EncodeSegwitAddress(0, h160, false)
+	if err != nil {
+		return keyVector{}, err
+	}
+	p2wpkhTestnet, err := utils.EncodeSegwitAddress(0, h160, true)
+	if err != nil {
+		return keyVector{}, err
+	}
+	redeemScript, err := script.CreateP2WPKHScript(h160).RawSerialize()
+	if err != nil {
+		return keyVector{}, err
+	}
+	return keyVector{
+		Secret:            fmt.Sprintf("%064x", key.Secret),
+		SECCompressed:     hex.EncodeToString(key.Point.Serialize(true)),
+		SECUncompressed:   hex.EncodeToString(key.Point.Serialize(false)),
+		WIF:               key.Serialize(true, false),
+		WIFTestnet:        key.Serialize(true, true),
+		P2PKH:             key.Point.Address(true, false),
+		P2PKHTestnet:      key.Point.Address(true, true),
+		P2PKHUncompressed: key.Point.Address(false, false),
+		P2SHP2WPKH:        utils.H160ToP2SHAddress(utils.Hash160(redeemScript), false),
+		P2WPKH:            p2wpkh,
+		P2WPKHTestnet:     p2wpkhTestnet,
+	}, nil
+}
+
+func newSignatureVector(key *signatureverification.PrivateKey, message string) (signatureVector, error) {
+	z := new(big.Int).SetBytes(utils.Hash256([]byte(message)))
+	signature, err := key.Sign(z)
+	if err != nil {
+		return signatureVector{}, err
+	}
+	return signatureVector{
+		Secret:  fmt.Sprintf("%064x", key.Secret),
+		Message: message,
+		Z:       fmt.Sprintf("%064x", z),
+		K:       fmt.Sprintf("%064x", key.GetDeterministicK(z)),
+		R:       fmt.Sprintf("%064x", signature.R),
+		S:       fmt.Sprintf("%064x", signature.S),
+		DER:     hex.EncodeToString(signature.Serialize()),
+	}, nil
+}
+
+// legacyTransaction spends two p2pkh outputs of sender to receiver and back to sender, signed with SIGHASH_ALL
+func legacyTransaction(sender, receiver *signatureverification.PrivateKey) (transactionVector, error) {
+	senderScript := script.CreateP2pkhScript(sender.Point.Hash160(true))
+	txIns := []*transaction.TxIn{
+		transaction.NewTxIn(utils.Hash256([]byte("gen-vectors prevout 0")), 0, &script.Script{}, 0xffffffff),
+		transaction.NewTxIn(utils.Hash256([]byte("gen-vectors prevout 1")), 3, &script.Script{}, 0xfffffffe),
+	}
+	txOuts := []*transaction.TxOut{
+		transaction.NewTxOut(40000000, script.CreateP2pkhScript(receiver.Point.Hash160(true))),
+		transaction.NewTxOut(9990000, senderScript),
+	}
+	tx := transaction.NewTx(1, txIns, txOuts, 0, false)
+
+	scriptCode, err := senderScript.RawSerialize()
+	if err != nil {
+		return transactionVector{}, err
+	}
+	var sigHashes []sigHashVector
+	for i := range tx.TxIns {
+		z, err := tx.SigHash(uint32(i), senderScript)
+		if err != nil {
+			return transactionVector{}, err
+		}
+		sigHashes = append(sigHashes, sigHashVector{
+			Input:      uint32(i),
+			Kind:       "legacy",
+			HashType:   transaction.SigHashAll,
+			ScriptCode: hex.EncodeToString(scriptCode),
+			SigHash:    fmt.Sprintf("%064x", z),
+		})
+	}
+	// the hashes are taken before any input is signed, a legacy signature does not commit to the other ScriptSigs
+	for i := range tx.TxIns {
+		z, _ := new(big.Int).SetString(sigHashes[i].SigHash, 16)
+		signature, err := sender.Sign(z)
+		if err != nil {
+			return transactionVector{}, err
+		}
+		sig := append(signature.Serialize(), byte(transaction.SigHashAll))
+		tx.TxIns[i].ScriptSig = &script.Script{sig, sender.Point.Serialize(true)}
+		if err := tx.VerifyInputWith(uint32(i), senderScript, script.StandardVerifyFlags); err != nil {
+			return transactionVector{}, fmt.Errorf("signed input %d does not verify: %v", i, err)
+		}
+	}
+	return newTransactionVector("two p2pkh inputs signed with SIGHASH_ALL", tx, sigHashes)
+}
+
+// taprootTransaction spends two taproot outputs, with the BIP341 signature hash of each hash type for the first input
+func taprootTransaction() (transactionVector, error) {
+	prevouts := []*transaction.TxOut{
+		transaction.NewTxOut(100000, script.CreateP2TRScript(utils.TaggedHash("TapTweak", []byte("gen-vectors key 0")))),
+		transaction.NewTxOut(250000, script.CreateP2TRScript(utils.TaggedHash("TapTweak", []byte("gen-vectors key 1")))),
+	}
+	txIns := []*transaction.TxIn{
+		transaction.NewTxIn(utils.Hash256([]byte("gen-vectors prevout 2")), 1, &script.Script{}, 0xfffffffd),
+		transaction.NewTxIn(utils.Hash256([]byte("gen-vectors prevout 3")), 0, &script.Script{}, 0xfffffffd),
+	}
+	txOuts := []*transaction.TxOut{
+		transaction.NewTxOut(300000, script.CreateP2WPKHScript(utils.Hash160([]byte("gen-vectors receiver")))),
+		transaction.NewTxOut(49000, prevouts[0].ScriptPubkey),
+	}
+	tx := transaction.NewTx(2, txIns, txOuts, 800000, false)
+
+	var serializedPrevouts []string
+	for _, prevout := range prevouts {
+		serialized, err := prevout.Serialize()
+		if err != nil {
+			return transactionVector{}, err
+		}
+		serializedPrevouts = append(serializedPrevouts, hex.EncodeToString(serialized))
+	}
+	hashTypes := []uint32{
+		transaction.SigHashDefault, transaction.SigHashAll, transaction.SigHashNone, transaction.SigHashSingle,
+		transaction.SigHashAll | transaction.SigHashAnyoneCanPay,
+		transaction.SigHashNone | transaction.SigHashAnyoneCanPay,
+		transaction.SigHashSingle | transaction.SigHashAnyoneCanPay,
+	}
+	var sigHashes []sigHashVector
+	for _, hashType := range hashTypes {
+		sigHash, err := tx.TaprootSigHash(0, prevouts, hashType, nil, nil)
+		if err != nil {
+			return transactionVector{}, err
+		}
+		sigHashes = append(sigHashes, sigHashVector{
+			Input:    0,
+			Kind:     "taproot key path",
+			HashType: hashType,
+			Prevouts: serializedPrevouts,
+			SigHash:  hex.EncodeToString(sigHash),
+		})
+	}
+	return newTransactionVector("two unsigned taproot inputs, version 2 with a locktime", tx, sigHashes)
+}
+
+func newTransactionVector(description string, tx *transaction.Tx, sigHashes []sigHashVector) (transactionVector, error) {
+	serialized, err := tx.Serialize()
+	if err != nil {
+		return transactionVector{}, err
+	}
+	id, err := tx.Id()
+	if err != nil {
+		return transactionVector{}, err
+	}
+	weight, err := tx.Weight()
+	if err != nil {
+		return transactionVector{}, err
+	}
+	vsize, err := tx.VSize()
+	if err != nil {
+		return transactionVector{}, err
+	}
+	return transactionVector{
+		Description: description,
+		Hex:         hex.EncodeToString(serialized),
+		TxID:        id,
+		Size:        len(serialized),
+		VSize:       vsize,
+		Weight:      weight,
+		SigHashes:   sigHashes,
+	}, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/network"
+)
+
+const (
+	// Print progress every this many headers
+	reportInterval = 10000
+	// Number of peers headers are downloaded from and cross-checked between with -p2p
+	syncPeers = 4
+)
+
+func main() {
+	var isTestnet bool
+	var storePath string
+	flag.BoolVar(&isTestnet, "testnet", false, "enable testnet mode")
+	var useP2P bool
+	flag.StringVar(&storePath, "store", "headers.dat", "file the validated headers are written to")
+	flag.BoolVar(&useP2P, "p2p", false, "download the headers from peers found through the DNS seeds")
+
+	flag.Parse()
+
+	store, err := block.OpenHeaderStore(storePath, isTestnet)
+	if err != nil {
+		fmt.Println("Could not open header store:", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	if useP2P {
+		syncFromPeers(store, isTestnet)
+		return
+	}
+
+	fetcher := block.NewHeaderFetcher()
+
+	tipHeight, err := fetcher.FetchTipHeight(isTestnet)
+	if err != nil {
+		fmt.Println("Could not fetch the tip height:", err)
+		os.Exit(1)
+	}
+
+	startHeight := store.Height()
+	fmt.Printf("Header store is at height %d, the API tip is at height %d\n", startHeight, tipHeight)
+
+	start := time.Now()
+	lastReport := startHeight
+
+	for store.Height() < tipHeight {
+		headers, err := fetcher.FetchHeaders(store.Height()+1, tipHeight, isTestnet)
+		if err != nil {
+			fmt.Println("Could not fetch headers:", err)
+			os.Exit(1)
+		}
+
+		if err := store.Append(headers...); err != nil {
+			fmt.Println("Header validation failed:", err)
+			os.Exit(1)
+		}
+
+		if store.Height()-lastReport >= reportInterval {
+			lastReport = store.Height()
+			fmt.Printf("height %d (%.0f headers/s)\n", store.Height(), rate(store.Height()-startHeight, start))
+		}
+	}
+
+	fmt.Printf("Synced %d headers in %s (%.0f headers/s)\n", store.Height()-startHeight, time.Since(start).Round(time.Second), rate(store.Height()-startHeight, start))
+	printTip(store)
+}
+
+// syncFromPeers connects to peers from the DNS seeds and downloads the headers from all of them at once
+func syncFromPeers(store *block.HeaderStore, testnet bool) {
+	addresses, err := network.ResolveSeeds(testnet, nil)
+	if err != nil {
+		fmt.Println("Could not resolve the DNS seeds:", err)
+		os.Exit(1)
+	}
+
+	addrman := network.NewAddrMan()
+	addrman.Add(addresses...)
+
+	var peers []network.HeaderPeer
+	for _, address := range addrman.Select(len(addresses)) {
+		if len(peers) == syncPeers {
+			break
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			continue
+		}
+		portNumber, _ := strconv.Atoi(port)
+		node, err := network.NewSimpleNode(host, portNumber, testnet, false)
+		if err != nil {
+			addrman.Failed(address)
+			continue
+		}
+		if err := node.Handshake(); err != nil {
+			addrman.Failed(address)
+			node.Close()
+			continue
+		}
+		defer node.Close()
+		peers = append(peers, node)
+	}
+
+	startHeight := store.Height()
+	start := time.Now()
+	fmt.Printf("Header store is at height %d, syncing from %d peers\n", startHeight, len(peers))
+
+	added, err := network.SyncHeaders(store, peers, addrman, nil)
+	if err != nil {
+		fmt.Println("Header sync failed:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Synced %d headers in %s (%.0f headers/s)\n", added, time.Since(start).Round(time.Second), rate(uint32(added), start))
+	printTip(store)
+}
+
+// printTip prints the tip of the store and where the subsidy schedule stands at it
+func printTip(store *block.HeaderStore) {
+	height := store.Height()
+	fmt.Printf("Tip: %x at height %d\n", store.TipHash(), height)
+	fmt.Printf("Subsidy: %s BTC, halving at height %d in %d blocks\n", formatBTC(block.BlockSubsidy(height)), block.NextHalvingHeight(height), block.BlocksUntilHalving(height))
+	fmt.Printf("Supply: %s BTC\n", formatBTC(block.TotalSupplyAt(height)))
+}
+
+// formatBTC formats satoshis as bitcoin with all eight decimals, without going through a float
+func formatBTC(satoshis uint64) string {
+	return fmt.Sprintf("%d.%08d", satoshis/100000000, satoshis%100000000)
+}
+
+func rate(count uint32, since time.Time) float64 {
+	elapsed := time.Since(since).Seconds()
+	if elapsed == 0 {
+		return 0
+	}
+	return float64(count) / elapsed
+}
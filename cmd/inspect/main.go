@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/caspereijkens/cryptocurrency/internal/analytics"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// Base58Check version bytes of addresses and WIF keys
+var base58Versions = map[byte]struct {
+	kind    string
+	testnet bool
+}{
+	0x00: {"p2pkh address", false},
+	0x6f: {"p2pkh address", true},
+	0x05: {"p2sh address", false},
+	0xc4: {"p2sh address", true},
+	0x80: {"WIF private key", false},
+	0xef: {"WIF private key", true},
+}
+
+// BIP32 version bytes of extended keys
+var extendedKeyVersions = map[uint32]struct {
+	kind    string
+	testnet bool
+}{
+	0x0488b21e: {"xpub", false},
+	0x0488ade4: {"xprv", false},
+	0x043587cf: {"tpub", true},
+	0x04358394: {"tprv", true},
+}
+
+func main() {
+	var isTestnet bool
+	flag.BoolVar(&isTestnet, "testnet", false, "decode transactions as testnet ones")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		fmt.Println("Please provide an address, key, txid, transaction or script to inspect.")
+		os.Exit(1)
+	}
+
+	for _, arg := range args {
+		report, err := inspect(strings.TrimSpace(arg), isTestnet)
+		if err != nil {
+			fmt.Printf("%s: %v\n", arg, err)
+			continue
+		}
+		fmt.Println(report)
+	}
+}
+
+// inspect identifies what s is and returns a breakdown of it
+func inspect(s string, testnet bool) (string, error) {
+	if report, ok := inspectSegwitAddress(s); ok {
+		return report, nil
+	}
+	if isHex(s) {
+		raw, _ := hex.DecodeString(s)
+		return inspectHex(raw, testnet)
+	}
+	if payload, err := utils.DecodeBase58Checksum(s); err == nil {
+		return inspectBase58(payload)
+	} else if isBase58(s) {
+		return "", fmt.Errorf("not a valid Base58Check string: %v", err)
+	}
+	return "", fmt.Errorf("not an address, key, txid, transaction or script")
+}
+
+func inspectSegwitAddress(s string) (string, bool) {
+	for _, testnet := range []bool{false, true} {
+		version, program, err := utils.DecodeSegwitAddress(s, testnet)
+		if err != nil {
+			continue
+		}
+		kind := fmt.Sprintf("segwit v%d address", version)
+		switch {
+		case version == 0 && len(program) == 20:
+			kind = "p2wpkh address"
+		case version == 0 && len(program) == 32:
+			kind = "p2wsh address"
+		case version == 1 && len(program) == 32:
+			kind = "p2tr address"
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%s (%s)\n", kind, networkName(testnet))
+		fmt.Fprintf(&sb, "  witness version: %d\n", version)
+		fmt.Fprintf(&sb, "  witness program: %x", program)
+		return sb.String(), true
+	}
+	return "", false
+}
+
+func inspectBase58(payload []byte) (string, error) {
+	// BIP32 serializes extended keys in 78 bytes
+	if len(payload) == 78 {
+		return inspectExtendedKey(payload)
+	}
+
+	version, ok := base58Versions[payload[0]]
+	if !ok {
+		return "", fmt.Errorf("unknown Base58Check version byte 0x%02x", payload[0])
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s (%s)\n", version.kind, networkName(version.testnet))
+	switch version.kind {
+	case "p2pkh address", "p2sh address":
+		if len(payload) != 21 {
+			return "", fmt.Errorf("%s payload is %d bytes, want 21", version.kind, len(payload))
+		}
+		fmt.Fprintf(&sb, "  hash160: %x", payload[1:])
+	case "WIF private key":
+		compressed := len(payload) == 34 && payload[33] == 0x01
+		if len(payload) != 33 && !compressed {
+			return "", fmt.Errorf("WIF payload is %d bytes, want 33 or 34 ending in 0x01", len(payload))
+		}
+		key, err := newPrivateKey(payload[1:33])
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "  compressed: %t\n", compressed)
+		fmt.Fprintf(&sb, "  public key: %x\n", key.Point.Serialize(compressed))
+		fmt.Fprintf(&sb, "  p2pkh address: %s", key.Point.Address(compressed, version.testnet))
+	}
+	return sb.String(), nil
+}
+
+func inspectExtendedKey(payload []byte) (string, error) {
+	version, ok := extendedKeyVersions[binary.BigEndian.Uint32(payload[:4])]
+	if !ok {
+		return "", fmt.Errorf("unknown extended key version %x", payload[:4])
+	}
+	depth := payload[4]
+	fingerprint := payload[5:9]
+	childNumber := binary.BigEndian.Uint32(payload[9:13])
+	chainCode := payload[13:45]
+	keyData := payload[45:]
+
+	var point *signatureverification.S256Point
+	if strings.HasSuffix(version.kind, "prv") {
+		if keyData[0] != 0 {
+			return "", fmt.Errorf("private key data does not start with 0x00")
+		}
+		key, err := newPrivateKey(keyData[1:])
+		if err != nil {
+			return "", err
+		}
+		point = key.Point
+	} else {
+		var err error
+		if point, err = parsePublicKey(keyData); err != nil {
+			return "", err
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s extended key (%s)\n", version.kind, networkName(version.testnet))
+	fmt.Fprintf(&sb, "  depth: %d\n", depth)
+	fmt.Fprintf(&sb, "  parent fingerprint: %x\n", fingerprint)
+	if childNumber >= 0x80000000 {
+		fmt.Fprintf(&sb, "  child number: %d'\n", childNumber-0x80000000)
+	} else {
+		fmt.Fprintf(&sb, "  child number: %d\n", childNumber)
+	}
+	fmt.Fprintf(&sb, "  chain code: %x\n", chainCode)
+	fmt.Fprintf(&sb, "  public key: %x\n", point.Serialize(true))
+	fmt.Fprintf(&sb, "  p2pkh address: %s", point.Address(true, version.testnet))
+	return sb.String(), nil
+}
+
+func inspectHex(raw []byte, testnet bool) (string, error) {
+	if point, err := parsePublicKey(raw); err == nil {
+		return describePublicKey(point, raw[0] != 4), nil
+	}
+
+	if len(raw) == 32 {
+		var sb strings.Builder
+		sb.WriteString("32 byte hash: a txid, block hash or private key\n")
+		fmt.Fprintf(&sb, "  as displayed: %x\n", raw)
+		fmt.Fprintf(&sb, "  wire order: %x", utils.ReverseBytes(append([]byte{}, raw...)))
+		return sb.String(), nil
+	}
+
+	if tx, err := transaction.ParseTxStrict(raw, testnet); err == nil {
+		// non-canonical encodings, such as oversized varints, do not count as a transaction
+		if serialized, err := tx.Serialize(); err == nil && bytes.Equal(serialized, raw) {
+			return describeTx(tx)
+		}
+	}
+
+	s, err := script.ParseRawScript(raw)
+	if err != nil {
+		return "", fmt.Errorf("hex is not a public key, hash, transaction or script: %v", err)
+	}
+	if serialized, err := s.RawSerialize(); err != nil || !bytes.Equal(serialized, raw) {
+		return "", fmt.Errorf("hex is not a public key, hash, transaction or script")
+	}
+	return describeScript(s), nil
+}
+
+func describePublicKey(point *signatureverification.S256Point, compressed bool) string {
+	var sb strings.Builder
+	if compressed {
+		sb.WriteString("compressed SEC public key\n")
+	} else {
+		sb.WriteString("uncompressed SEC public key\n")
+	}
+	fmt.Fprintf(&sb, "  hash160: %x\n", point.Hash160(compressed))
+	for _, testnet := range []bool{false, true} {
+		fmt.Fprintf(&sb, "  p2pkh address (%s): %s\n", networkName(testnet), point.Address(compressed, testnet))
+		if compressed {
+			address, _ := utils.EncodeSegwitAddress(0, point.Hash160(true), testnet)
+			fmt.Fprintf(&sb, "  p2wpkh address (%s): %s\n", networkName(testnet), address)
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func describeTx(tx *transaction.Tx) (string, error) {
+	id, err := tx.Id()
+	if err != nil {
+		return "", err
+	}
+	vsize, err := tx.VSize()
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "raw transaction %s\n", id)
+	fmt.Fprintf(&sb, "  segwit: %t, vsize: %d\n", tx.HasWitness(), vsize)
+	for _, line := range strings.Split(strings.TrimSpace(tx.String()), "\n") {
+		if line == "" {
+			sb.WriteString("\n")
+			continue
+		}
+		fmt.Fprintf(&sb, "  %s\n", line)
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+func describeScript(s *script.Script) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "script (%s)\n", analytics.OutputType(s))
+	fmt.Fprintf(&sb, "  asm: %s\n", s)
+	for _, testnet := range []bool{false, true} {
+		if address, err := transaction.NewTxOut(0, s).Address(testnet); err == nil {
+			fmt.Fprintf(&sb, "  address (%s): %s\n", networkName(testnet), address)
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// parsePublicKey parses a SEC public key, rejecting lengths that do not match the prefix byte
+func parsePublicKey(sec []byte) (*signatureverification.S256Point, error) {
+	switch {
+	case len(sec) == 33 && (sec[0] == 2 || sec[0] == 3):
+	case len(sec) == 65 && sec[0] == 4:
+	default:
+		return nil, fmt.Errorf("not a SEC public key")
+	}
+	return signatureverification.ParseSEC(sec)
+}
+
+func newPrivateKey(secret []byte) (*signatureverification.PrivateKey, error) {
+	e := new(big.Int).SetBytes(secret)
+	if e.Sign() == 0 || e.Cmp(signatureverification.N) >= 0 {
+		return nil, fmt.Errorf("private key is out of range")
+	}
+	return signatureverification.NewPrivateKey(e)
+}
+
+func networkName(testnet bool) string {
+	if testnet {
+		return "testnet"
+	}
+	return "mainnet"
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil && len(s) > 0
+}
+
+func isBase58(s string) bool {
+	return len(s) > 0 && strings.Trim(s, "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz") == ""
+}
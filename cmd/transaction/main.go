@@ -1,10 +1,15 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
+	"strings"
 
+	"github.com/caspereijkens/cryptocurrency/internal/block"
 	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
 )
 
 func main() {
@@ -12,6 +17,10 @@ func main() {
 	var isTestnet bool
 	var fresh = true
 	flag.BoolVar(&isTestnet, "testnet", false, "enable testnet mode")
+	var headersPath string
+	flag.StringVar(&headersPath, "headers", "", "header store synced with headers-sync, to check the transaction is confirmed on the chosen network")
+	var esploraURLs string
+	flag.StringVar(&esploraURLs, "esplora", "", "comma-separated Esplora base URLs tried before the public ones")
 
 	// Parse the command-line arguments
 	flag.Parse()
@@ -28,11 +37,89 @@ func main() {
 	// Extract the transaction ID
 	transactionID := args[0]
 
-	tx, err := transaction.NewTxFetcher().Fetch(transactionID, isTestnet, fresh)
+	fetcher := transaction.NewTxFetcherWithClient(&http.Client{Transport: esploraTransport(esploraURLs, isTestnet)}, "")
+	var store *block.HeaderStore
+	if headersPath != "" {
+		var err error
+		store, err = block.OpenHeaderStore(headersPath, isTestnet)
+		if err != nil {
+			fmt.Println("Could not open header store:", err)
+			return
+		}
+		defer store.Close()
+		fetcher.VerifyBlock = store.VerifyBlock
+	}
+	tx, err := fetcher.Fetch(transactionID, isTestnet, fresh)
 	if err != nil {
-		fmt.Println("Transaction could not be found. Please provide a correct transaction ID.")
+		fmt.Println(fetchErrorMessage(err, isTestnet))
 		return
 	}
 
-	fmt.Println(tx.String())
+	// the spent outputs are fetched to show the input amounts and the fee
+	fmt.Println(tx.StringWith(fetcher.PrevoutLookup(isTestnet)))
+
+	confirmation, err := confirmationMessage(fetcher, store, transactionID, isTestnet)
+	if err != nil {
+		fmt.Println("Could not look up the confirmation status:", err)
+		return
+	}
+	fmt.Println(confirmation)
+}
+
+// confirmationMessage tells whether the transaction is confirmed and how deep. The tip of the header store is
+// used when there is one, the tip the API reports otherwise.
+func confirmationMessage(fetcher *transaction.TxFetcher, store *block.HeaderStore, txID string, testnet bool) (string, error) {
+	status, err := fetcher.FetchStatus(txID, testnet)
+	if err != nil {
+		return "", err
+	}
+	if !status.Confirmed {
+		return "Unconfirmed: the transaction is waiting in the mempool.", nil
+	}
+	var tip uint32
+	if store != nil {
+		tip = store.Height()
+	} else if tip, err = fetcher.FetchTipHeight(testnet); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Confirmed in block %s at height %d, %d confirmations.", status.BlockHash, status.BlockHeight, status.Confirmations(tip)), nil
+}
+
+// esploraTransport fails over from the endpoints in urls to the public Esplora endpoints of the network
+func esploraTransport(urls string, testnet bool) *utils.FailoverTransport {
+	groups := make([][]string, len(utils.EsploraEndpoints))
+	for i, group := range utils.EsploraEndpoints {
+		groups[i] = append([]string{}, group...)
+	}
+	network := 0
+	if testnet {
+		network = 1
+	}
+	var custom []string
+	for _, url := range strings.Split(urls, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			custom = append(custom, url)
+		}
+	}
+	groups[network] = append(custom, groups[network]...)
+	return utils.NewFailoverTransport(groups...)
+}
+
+// fetchErrorMessage tells the user why the transaction could not be fetched
+func fetchErrorMessage(err error, testnet bool) string {
+	network := "mainnet"
+	if testnet {
+		network = "testnet"
+	}
+
+	var httpErr *utils.HTTPError
+	switch {
+	case errors.Is(err, transaction.ErrTxNotFound):
+		return fmt.Sprintf("Transaction could not be found on %s. Please provide a correct transaction ID.", network)
+	case errors.Is(err, transaction.ErrRateLimited) && errors.As(err, &httpErr) && httpErr.RetryAfter > 0:
+		return fmt.Sprintf("The API is rate limiting requests, please retry after %s.", httpErr.RetryAfter)
+	case errors.Is(err, transaction.ErrRateLimited):
+		return "The API is rate limiting requests, please retry later."
+	}
+	return fmt.Sprintf("Transaction could not be fetched: %v", err)
 }
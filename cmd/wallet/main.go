@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/monitor"
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/wallet"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "create":
+		err = create(os.Args[2:])
+	case "list":
+		err = list(os.Args[2:])
+	case "watch":
+		err = watch(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: wallet create [-walletdir dir] [-testnet | -signet] name")
+	fmt.Fprintln(os.Stderr, "       wallet list [-walletdir dir]")
+	fmt.Fprintln(os.Stderr, "       wallet watch [-walletdir dir] [-wallet default] [-testnet | -signet] [-json] [-interval 30s] [-depth 6] [address...]")
+}
+
+// defaultWalletDir is where the wallets are kept unless -walletdir says otherwise
+func defaultWalletDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "wallets"
+	}
+	return filepath.Join(dir, "cryptocurrency", "wallets")
+}
+
+// create makes a new named wallet
+func create(args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	walletDir := flags.String("walletdir", defaultWalletDir(), "directory of the wallets")
+	isTestnet := flags.Bool("testnet", false, "create a testnet wallet")
+	isSignet := flags.Bool("signet", false, "create a signet wallet")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+	network, err := chooseNetwork(*isTestnet, *isSignet)
+	if err != nil {
+		return err
+	}
+
+	w, err := wallet.NewManager(*walletDir).Create(flags.Arg(0), network)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created wallet %s (%s)\n", w.Name, w.Network.Name)
+	return nil
+}
+
+// list prints the name, network and number of watched addresses of every wallet
+func list(args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	walletDir := flags.String("walletdir", defaultWalletDir(), "directory of the wallets")
+	flags.Parse(args)
+
+	manager := wallet.NewManager(*walletDir)
+	names, err := manager.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		w, err := manager.Load(name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\t%s\t%d addresses\n", w.Name, w.Network.Name, len(w.Addresses()))
+	}
+	return nil
+}
+
+// chooseNetwork returns the network of the -testnet and -signet flags, mainnet when neither is given
+func chooseNetwork(testnet, signet bool) (*params.Params, error) {
+	switch {
+	case testnet && signet:
+		return nil, fmt.Errorf("give -testnet or -signet, not both")
+	case signet:
+		return params.SigNet, nil
+	case testnet:
+		return params.TestNet3, nil
+	}
+	return params.MainNet, nil
+}
+
+// watchLine is one event of wallet watch
+type watchLine struct {
+	Time          time.Time `json:"time"`
+	Event         string    `json:"event"`
+	Address       string    `json:"address"`
+	TxID          string    `json:"txid"`
+	Amount        int64     `json:"amount"`
+	Balance       int64     `json:"balance"`
+	Confirmations uint32    `json:"confirmations"`
+}
+
+// String returns the line as tab separated fields, in the order of the struct
+func (l watchLine) String() string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s\t%+d\t%d\t%d", l.Time.Format(time.RFC3339), l.Event, l.Address, l.TxID, l.Amount, l.Balance, l.Confirmations)
+}
+
+// watch prints a line for every new transaction of the addresses of a wallet and every change in its confirmations
+// until it is interrupted. The transactions already in the histories are printed first, so the balance starts out
+// right. Addresses given on the command line are added to the wallet.
+func watch(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	walletDir := flags.String("walletdir", defaultWalletDir(), "directory of the wallets")
+	walletName := flags.String("wallet", wallet.DefaultName, "wallet whose addresses are watched")
+	isTestnet := flags.Bool("testnet", false, "make the default wallet a testnet wallet when it is created")
+	isSignet := flags.Bool("signet", false, "make the default wallet a signet wallet when it is created")
+	asJSON := flags.Bool("json", false, "print every event as a JSON object instead of tab separated fields")
+	interval := flags.Duration("interval", monitor.DefaultInterval, "time between polls")
+	depth := flags.Uint("depth", monitor.DefaultDepth, "confirmations up to which transactions are followed")
+	flags.Parse(args)
+	network, err := chooseNetwork(*isTestnet, *isSignet)
+	if err != nil {
+		return err
+	}
+
+	manager := wallet.NewManager(*walletDir)
+	var w *wallet.Wallet
+	if *walletName == wallet.DefaultName {
+		w, err = manager.Default(network)
+	} else {
+		w, err = manager.Load(*walletName)
+	}
+	if err != nil {
+		return err
+	}
+	if (*isTestnet || *isSignet) && w.Network != network {
+		return fmt.Errorf("wallet %s is a %s wallet", w.Name, w.Network.Name)
+	}
+	if err := w.Watch(flags.Args()...); err != nil {
+		return err
+	}
+	if len(w.Addresses()) == 0 {
+		return fmt.Errorf("wallet %s watches no addresses, give some to add them", w.Name)
+	}
+	if err := w.Save(); err != nil {
+		return err
+	}
+
+	// the balance counts a transaction of an address once, from its first event on
+	counted := make(map[string]bool)
+	var balance int64
+	encoder := json.NewEncoder(os.Stdout)
+	m := w.Monitor(nil, func(event monitor.Event) {
+		if key := event.Address + ":" + event.Tx.TxID; !counted[key] {
+			counted[key] = true
+			balance += event.Received
+		}
+		line := watchLine{
+			Time:          time.Now().UTC(),
+			Event:         event.Kind.String(),
+			Address:       event.Address,
+			TxID:          event.Tx.TxID,
+			Amount:        event.Received,
+			Balance:       balance,
+			Confirmations: event.Confirmations,
+		}
+		if *asJSON {
+			encoder.Encode(line)
+			return
+		}
+		fmt.Println(line)
+	})
+	m.Interval = *interval
+	m.Depth = uint32(*depth)
+	m.ErrorHandler = func(err error) {
+		fmt.Fprintln(os.Stderr, "poll failed:", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err = m.Run(ctx)
+	if ctx.Err() != nil {
+		// interrupted, which is how watch ends
+		err = nil
+	}
+	// the transactions fetched while watching are kept for the next run
+	if saveErr := manager.SaveAll(); err == nil {
+		err = saveErr
+	}
+	return err
+}
@@ -1,5 +1,3 @@
 module github.com/caspereijkens/cryptocurrency
 
 go 1.21.1
-
-require golang.org/x/crypto v0.16.0
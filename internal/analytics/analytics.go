@@ -0,0 +1,297 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+// OutputTypes are the output types counted per block, in the order of the CSV columns
+var OutputTypes = []string{"p2pk", "p2pkh", "p2sh", "p2wpkh", "p2wsh", "p2tr", "multisig", "op_return", "nonstandard"}
+
+// FeeRatePercentiles are the percentiles of the fee rate distribution in BlockStats
+var FeeRatePercentiles = []int{10, 25, 50, 75, 90}
+
+// BlockStats are statistics of the transactions in a single block
+type BlockStats struct {
+	Hash      string `json:"hash"`
+	Timestamp uint32 `json:"timestamp"`
+	TxCount   int    `json:"tx_count"`
+	Size      int    `json:"size"`
+	Weight    int    `json:"weight"`
+	// the average sizes exclude the coinbase
+	AverageTxSize  float64 `json:"avg_tx_size"`
+	AverageTxVSize float64 `json:"avg_tx_vsize"`
+
+	OutputCount int    `json:"output_count"`
+	OutputValue uint64 `json:"output_value"`
+	// OutputTypes counts the outputs by type, see OutputTypes
+	OutputTypes   map[string]int `json:"output_types"`
+	OpReturnCount int            `json:"op_return_count"`
+	OpReturnBytes int            `json:"op_return_bytes"`
+
+	// FeeTxCount is the number of transactions whose fee is known, the fee statistics cover only those
+	FeeTxCount int    `json:"fee_tx_count"`
+	TotalFee   uint64 `json:"total_fee"`
+	// fee rates in satoshis per vbyte
+	MinFeeRate         float64   `json:"min_fee_rate"`
+	MaxFeeRate         float64   `json:"max_fee_rate"`
+	FeeRatePercentiles []float64 `json:"fee_rate_percentiles"`
+}
+
+// ComputeBlockStats computes the statistics of fb. Inputs spending outputs created earlier in the block are
+// resolved from the block itself, other ones with lookup. Transactions with an input that cannot be resolved,
+// or all of them when lookup is nil, are left out of the fee statistics.
+func ComputeBlockStats(fb *block.FullBlock, lookup transaction.PrevoutLookup) (*BlockStats, error) {
+	hash, err := fb.Header.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &BlockStats{
+		Hash:        hex.EncodeToString(hash),
+		Timestamp:   fb.Header.Timestamp,
+		TxCount:     len(fb.Txs),
+		OutputTypes: make(map[string]int),
+	}
+
+	raw, err := fb.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	stats.Size = len(raw)
+
+	created := make(map[string]*transaction.Tx)
+	var feeRates []float64
+	txBytes, totalSize, totalVSize := 0, 0, 0
+	for i, tx := range fb.Txs {
+		serialized, err := tx.Serialize()
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		weight, err := tx.Weight()
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		stats.Weight += weight
+		txBytes += len(serialized)
+
+		for _, txOut := range tx.TxOuts {
+			outputType := OutputType(txOut.ScriptPubkey)
+			stats.OutputCount++
+			stats.OutputValue += txOut.Amount
+			stats.OutputTypes[outputType]++
+			if outputType == "op_return" {
+				stats.OpReturnCount++
+				stats.OpReturnBytes += opReturnDataSize(txOut.ScriptPubkey)
+			}
+		}
+
+		id, err := tx.Id()
+		if err != nil {
+			return nil, err
+		}
+		created[id] = tx
+
+		if i == 0 && tx.IsCoinbase() {
+			continue
+		}
+		vsize, err := tx.VSize()
+		if err != nil {
+			return nil, err
+		}
+		totalSize += len(serialized)
+		totalVSize += vsize
+
+		fee, ok := txFee(tx, created, lookup)
+		if !ok {
+			continue
+		}
+		stats.FeeTxCount++
+		stats.TotalFee += fee
+		feeRates = append(feeRates, float64(fee)/float64(vsize))
+	}
+
+	// the header and the transaction count are not witness data
+	stats.Weight += (stats.Size - txBytes) * transaction.WitnessScaleFactor
+
+	if nonCoinbase := countNonCoinbase(fb); nonCoinbase > 0 {
+		stats.AverageTxSize = float64(totalSize) / float64(nonCoinbase)
+		stats.AverageTxVSize = float64(totalVSize) / float64(nonCoinbase)
+	}
+
+	stats.FeeRatePercentiles = make([]float64, len(FeeRatePercentiles))
+	if len(feeRates) > 0 {
+		sort.Float64s(feeRates)
+		stats.MinFeeRate = feeRates[0]
+		stats.MaxFeeRate = feeRates[len(feeRates)-1]
+		for i, p := range FeeRatePercentiles {
+			stats.FeeRatePercentiles[i] = percentile(feeRates, p)
+		}
+	}
+
+	return stats, nil
+}
+
+// txFee returns the fee of tx, or false when one of its inputs cannot be resolved
+func txFee(tx *transaction.Tx, created map[string]*transaction.Tx, lookup transaction.PrevoutLookup) (uint64, bool) {
+	inputSum := uint64(0)
+	for _, txIn := range tx.TxIns {
+		var prevOut *transaction.TxOut
+		if prevTx, ok := created[txIn.PrevOut.TxidString()]; ok {
+			if txIn.PrevOut.Index >= uint32(len(prevTx.TxOuts)) {
+				return 0, false
+			}
+			prevOut = prevTx.TxOuts[txIn.PrevOut.Index]
+		} else {
+			if lookup == nil {
+				return 0, false
+			}
+			var err error
+			if prevOut, err = lookup(txIn); err != nil {
+				return 0, false
+			}
+		}
+		inputSum += prevOut.Amount
+	}
+
+	outputSum := uint64(0)
+	for _, txOut := range tx.TxOuts {
+		outputSum += txOut.Amount
+	}
+	if outputSum > inputSum {
+		return 0, false
+	}
+	return inputSum - outputSum, true
+}
+
+func countNonCoinbase(fb *block.FullBlock) int {
+	if len(fb.Txs) > 0 && fb.Txs[0].IsCoinbase() {
+		return len(fb.Txs) - 1
+	}
+	return len(fb.Txs)
+}
+
+// percentile returns the p-th percentile of the sorted values, by the nearest rank
+func percentile(sorted []float64, p int) float64 {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// OutputType classifies a ScriptPubKey as one of OutputTypes
+func OutputType(scriptPubkey *script.Script) string {
+	switch {
+	case scriptPubkey.IsP2PKHScriptPubKey():
+		return "p2pkh"
+	case scriptPubkey.IsP2SHScriptPubKey():
+		return "p2sh"
+	case scriptPubkey.IsP2WPKHScriptPubKey():
+		return "p2wpkh"
+	case scriptPubkey.IsP2WSHScriptPubKey():
+		return "p2wsh"
+	case scriptPubkey.IsP2TRScriptPubKey():
+		return "p2tr"
+	case scriptPubkey.IsMultisigScriptPubKey():
+		return "multisig"
+	}
+
+	cmds := *scriptPubkey
+	if len(cmds) > 0 && script.IsOp(cmds[0], 0x6a) {
+		return "op_return"
+	}
+	// <sec pubkey> OP_CHECKSIG
+	if len(cmds) == 2 && (len(cmds[0]) == 33 || len(cmds[0]) == 65) && script.IsOp(cmds[1], 0xac) {
+		return "p2pk"
+	}
+	return "nonstandard"
+}
+
+// opReturnDataSize returns the number of bytes pushed after OP_RETURN
+func opReturnDataSize(scriptPubkey *script.Script) int {
+	size := 0
+	for _, cmd := range (*scriptPubkey)[1:] {
+		// single bytes are opcodes, such as small number pushes
+		if len(cmd) > 1 {
+			size += len(cmd)
+		}
+	}
+	return size
+}
+
+var csvHeader = []string{
+	"hash", "timestamp", "tx_count", "size", "weight", "avg_tx_size", "avg_tx_vsize",
+	"output_count", "output_value", "op_return_count", "op_return_bytes",
+	"fee_tx_count", "total_fee", "min_fee_rate", "max_fee_rate",
+}
+
+// WriteCSV writes the statistics as CSV, one row per block after a header row.
+// Output types and fee rate percentiles get a column each.
+func WriteCSV(w io.Writer, stats []*BlockStats) error {
+	header := append([]string{}, csvHeader...)
+	for _, p := range FeeRatePercentiles {
+		header = append(header, fmt.Sprintf("fee_rate_p%d", p))
+	}
+	for _, outputType := range OutputTypes {
+		header = append(header, "outputs_"+outputType)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, s := range stats {
+		row := []string{
+			s.Hash,
+			strconv.FormatUint(uint64(s.Timestamp), 10),
+			strconv.Itoa(s.TxCount),
+			strconv.Itoa(s.Size),
+			strconv.Itoa(s.Weight),
+			formatFloat(s.AverageTxSize),
+			formatFloat(s.AverageTxVSize),
+			strconv.Itoa(s.OutputCount),
+			strconv.FormatUint(s.OutputValue, 10),
+			strconv.Itoa(s.OpReturnCount),
+			strconv.Itoa(s.OpReturnBytes),
+			strconv.Itoa(s.FeeTxCount),
+			strconv.FormatUint(s.TotalFee, 10),
+			formatFloat(s.MinFeeRate),
+			formatFloat(s.MaxFeeRate),
+		}
+		for i := range FeeRatePercentiles {
+			value := 0.0
+			if i < len(s.FeeRatePercentiles) {
+				value = s.FeeRatePercentiles[i]
+			}
+			row = append(row, formatFloat(value))
+		}
+		for _, outputType := range OutputTypes {
+			row = append(row, strconv.Itoa(s.OutputTypes[outputType]))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+// WriteJSON writes the statistics as a JSON array
+func WriteJSON(w io.Writer, stats []*BlockStats) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
+}
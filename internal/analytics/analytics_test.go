@@ -0,0 +1,155 @@
+package analytics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+// Genesis coinbase paying 50 BTC to the genesis pubkey
+const coinbaseTx = "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff0504ffff001dffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+func testBlock(t *testing.T) *block.FullBlock {
+	t.Helper()
+	raw, _ := hex.DecodeString(coinbaseTx)
+	coinbase, err := transaction.ParseTx(bufio.NewReader(bytes.NewReader(raw)), false)
+	if err != nil {
+		t.Fatalf("ParseTx error: %v", err)
+	}
+	coinbaseHash, _ := coinbase.Hash()
+
+	// spends the coinbase with a fee of 1 BTC
+	opReturn := script.Script{[]byte{0x6a}, []byte("hello")}
+	spend := transaction.NewTx(1, []*transaction.TxIn{transaction.NewTxIn(coinbaseHash, 0, &script.Script{}, 0xffffffff)}, []*transaction.TxOut{
+		transaction.NewTxOut(4900000000, script.CreateP2pkhScript(make([]byte, 20))),
+		transaction.NewTxOut(0, &opReturn),
+	}, 0, false)
+
+	// spends an output outside of the block
+	external := transaction.NewTx(1, []*transaction.TxIn{transaction.NewTxIn(bytes.Repeat([]byte{0x01}, 32), 0, &script.Script{}, 0xffffffff)}, []*transaction.TxOut{
+		transaction.NewTxOut(1000, script.CreateP2WPKHScript(make([]byte, 20))),
+	}, 0, false)
+
+	return &block.FullBlock{Header: block.Genesis(false), Txs: []*transaction.Tx{coinbase, spend, external}}
+}
+
+func TestComputeBlockStats(t *testing.T) {
+	fb := testBlock(t)
+
+	stats, err := ComputeBlockStats(fb, nil)
+	if err != nil {
+		t.Fatalf("ComputeBlockStats error: %v", err)
+	}
+
+	if stats.Hash != "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f" || stats.TxCount != 3 {
+		t.Errorf("Unexpected block: %s with %d transactions", stats.Hash, stats.TxCount)
+	}
+
+	raw, _ := fb.Serialize()
+	if stats.Size != len(raw) || stats.Weight != 4*len(raw) {
+		t.Errorf("Size %d, weight %d for a block of %d bytes without witness data", stats.Size, stats.Weight, len(raw))
+	}
+
+	want := map[string]int{"p2pk": 1, "p2pkh": 1, "op_return": 1, "p2wpkh": 1}
+	for outputType, count := range want {
+		if stats.OutputTypes[outputType] != count {
+			t.Errorf("%s outputs = %d, want %d", outputType, stats.OutputTypes[outputType], count)
+		}
+	}
+	if stats.OpReturnCount != 1 || stats.OpReturnBytes != 5 {
+		t.Errorf("OP_RETURN count %d and bytes %d, want 1 and 5", stats.OpReturnCount, stats.OpReturnBytes)
+	}
+
+	// only the fee of the transaction spending the coinbase is known
+	vsize, _ := fb.Txs[1].VSize()
+	if stats.FeeTxCount != 1 || stats.TotalFee != 100000000 {
+		t.Errorf("Fees of %d transactions totalling %d", stats.FeeTxCount, stats.TotalFee)
+	}
+	if rate := float64(100000000) / float64(vsize); stats.MinFeeRate != rate || stats.FeeRatePercentiles[2] != rate {
+		t.Errorf("Median fee rate %f, want %f", stats.FeeRatePercentiles[2], rate)
+	}
+
+	// with a lookup the external input resolves too
+	lookup := func(txIn *transaction.TxIn) (*transaction.TxOut, error) {
+		if !bytes.Equal(txIn.PrevTx(), bytes.Repeat([]byte{0x01}, 32)) {
+			return nil, fmt.Errorf("unknown transaction")
+		}
+		return transaction.NewTxOut(2000, &script.Script{}), nil
+	}
+	stats, err = ComputeBlockStats(fb, lookup)
+	if err != nil {
+		t.Fatalf("ComputeBlockStats error: %v", err)
+	}
+	if stats.FeeTxCount != 2 || stats.TotalFee != 100001000 {
+		t.Errorf("Fees of %d transactions totalling %d", stats.FeeTxCount, stats.TotalFee)
+	}
+}
+
+func TestOutputType(t *testing.T) {
+	multisig, _ := script.CreateMultisigScript(1, [][]byte{bytes.Repeat([]byte{0x02}, 33), bytes.Repeat([]byte{0x03}, 33)})
+	p2pk := script.Script{bytes.Repeat([]byte{0x02}, 33), []byte{0xac}}
+	testCases := map[string]*script.Script{
+		"p2pkh":       script.CreateP2pkhScript(make([]byte, 20)),
+		"multisig":    multisig,
+		"p2pk":        &p2pk,
+		"nonstandard": {[]byte{0x51}},
+	}
+	for want, scriptPubkey := range testCases {
+		if got := OutputType(scriptPubkey); got != want {
+			t.Errorf("OutputType(%s) = %s, want %s", scriptPubkey, got, want)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := map[int]float64{10: 1, 25: 3, 50: 5, 90: 9, 100: 10}
+	for p, want := range tests {
+		if got := percentile(values, p); got != want {
+			t.Errorf("percentile(%d) = %f, want %f", p, got, want)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	stats, err := ComputeBlockStats(testBlock(t), nil)
+	if err != nil {
+		t.Fatalf("ComputeBlockStats error: %v", err)
+	}
+
+	var csvOut bytes.Buffer
+	if err := WriteCSV(&csvOut, []*BlockStats{stats, stats}); err != nil {
+		t.Fatalf("WriteCSV error: %v", err)
+	}
+	records, err := csv.NewReader(&csvOut).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(records) != 3 || len(records[0]) != len(records[1]) {
+		t.Fatalf("Expected a header and 2 rows of the same width, got %v", records)
+	}
+	if records[0][0] != "hash" || records[1][0] != stats.Hash || !strings.HasPrefix(records[0][len(records[0])-1], "outputs_") {
+		t.Errorf("Unexpected CSV header %v", records[0])
+	}
+
+	var jsonOut bytes.Buffer
+	if err := WriteJSON(&jsonOut, []*BlockStats{stats}); err != nil {
+		t.Fatalf("WriteJSON error: %v", err)
+	}
+	var decoded []BlockStats
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].TotalFee != stats.TotalFee || decoded[0].OutputTypes["p2pk"] != 1 {
+		t.Errorf("JSON round trip lost data: %+v", decoded)
+	}
+}
@@ -0,0 +1,155 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+var (
+	archiveTxHeader = []string{
+		"block_hash", "block_time", "position", "txid", "version", "locktime", "size", "vsize", "weight",
+		"coinbase", "segwit", "input_count", "output_count", "output_value",
+	}
+	archiveInputHeader  = []string{"txid", "index", "prev_txid", "prev_index", "sequence", "script_sig", "witness"}
+	archiveOutputHeader = []string{"txid", "index", "amount", "type", "script_pubkey", "address"}
+)
+
+// ArchiveWriter writes the transactions of blocks as three CSV tables for analysis in other tools: one row per
+// transaction, one per input and one per output, which join on txid. Scripts are written as hex, witness items as
+// hex separated by spaces.
+type ArchiveWriter struct {
+	txs, inputs, outputs *csv.Writer
+	testnet              bool
+	started              bool
+}
+
+// NewArchiveWriter creates a writer of the transaction, input and output tables
+func NewArchiveWriter(txs, inputs, outputs io.Writer, testnet bool) *ArchiveWriter {
+	return &ArchiveWriter{txs: csv.NewWriter(txs), inputs: csv.NewWriter(inputs), outputs: csv.NewWriter(outputs), testnet: testnet}
+}
+
+// WriteBlock adds the transactions of fb to the tables, after their header rows for the first block
+func (a *ArchiveWriter) WriteBlock(fb *block.FullBlock) error {
+	if !a.started {
+		a.started = true
+		for _, table := range []struct {
+			writer *csv.Writer
+			header []string
+		}{{a.txs, archiveTxHeader}, {a.inputs, archiveInputHeader}, {a.outputs, archiveOutputHeader}} {
+			if err := table.writer.Write(table.header); err != nil {
+				return err
+			}
+		}
+	}
+
+	hash, err := fb.Header.Hash()
+	if err != nil {
+		return err
+	}
+	blockHash := hex.EncodeToString(hash)
+	for position, tx := range fb.Txs {
+		id, err := tx.Id()
+		if err != nil {
+			return err
+		}
+		serialized, err := tx.Serialize()
+		if err != nil {
+			return err
+		}
+		weight, err := tx.Weight()
+		if err != nil {
+			return err
+		}
+		vsize, err := tx.VSize()
+		if err != nil {
+			return err
+		}
+
+		outputValue := uint64(0)
+		for index, txOut := range tx.TxOuts {
+			outputValue += txOut.Amount
+			scriptPubkey, err := scriptHex(txOut.ScriptPubkey)
+			if err != nil {
+				return err
+			}
+			address, _ := txOut.Address(a.testnet)
+			if err := a.outputs.Write([]string{
+				id,
+				strconv.Itoa(index),
+				strconv.FormatUint(txOut.Amount, 10),
+				OutputType(txOut.ScriptPubkey),
+				scriptPubkey,
+				address,
+			}); err != nil {
+				return err
+			}
+		}
+
+		for index, txIn := range tx.TxIns {
+			scriptSig, err := scriptHex(txIn.ScriptSig)
+			if err != nil {
+				return err
+			}
+			witness := make([]string, len(txIn.Witness))
+			for i, item := range txIn.Witness {
+				witness[i] = hex.EncodeToString(item)
+			}
+			if err := a.inputs.Write([]string{
+				id,
+				strconv.Itoa(index),
+				txIn.PrevOut.TxidString(),
+				strconv.FormatUint(uint64(txIn.PrevOut.Index), 10),
+				strconv.FormatUint(uint64(txIn.Sequence), 10),
+				scriptSig,
+				strings.Join(witness, " "),
+			}); err != nil {
+				return err
+			}
+		}
+
+		if err := a.txs.Write([]string{
+			blockHash,
+			strconv.FormatUint(uint64(fb.Header.Timestamp), 10),
+			strconv.Itoa(position),
+			id,
+			strconv.FormatUint(uint64(tx.Version), 10),
+			strconv.FormatUint(uint64(tx.Locktime), 10),
+			strconv.Itoa(len(serialized)),
+			strconv.Itoa(vsize),
+			strconv.Itoa(weight),
+			strconv.FormatBool(tx.IsCoinbase()),
+			strconv.FormatBool(tx.HasWitness()),
+			strconv.Itoa(len(tx.TxIns)),
+			strconv.Itoa(len(tx.TxOuts)),
+			strconv.FormatUint(outputValue, 10),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes the buffered rows of the three tables
+func (a *ArchiveWriter) Flush() error {
+	for _, writer := range []*csv.Writer{a.txs, a.inputs, a.outputs} {
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scriptHex(s *script.Script) (string, error) {
+	raw, err := s.RawSerialize()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
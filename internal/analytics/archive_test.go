@@ -0,0 +1,48 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestArchiveWriter(t *testing.T) {
+	fb := testBlock(t)
+	var txs, inputs, outputs bytes.Buffer
+	archive := NewArchiveWriter(&txs, &inputs, &outputs, false)
+	for i := 0; i < 2; i++ {
+		if err := archive.WriteBlock(fb); err != nil {
+			t.Fatalf("WriteBlock error: %v", err)
+		}
+	}
+	if err := archive.Flush(); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	read := func(name string, buf *bytes.Buffer, rows int) [][]string {
+		t.Helper()
+		records, err := csv.NewReader(buf).ReadAll()
+		if err != nil {
+			t.Fatalf("%s table is not valid CSV: %v", name, err)
+		}
+		if len(records) != rows+1 {
+			t.Fatalf("%s table has %d rows, want a header and %d rows", name, len(records), rows)
+		}
+		return records
+	}
+	txRows := read("transaction", &txs, 6)
+	inputRows := read("input", &inputs, 6)
+	outputRows := read("output", &outputs, 8)
+
+	spendID, _ := fb.Txs[1].Id()
+	if txRows[1][9] != "true" || txRows[2][3] != spendID || txRows[2][13] != "4900000000" {
+		t.Errorf("unexpected transaction rows %v", txRows[1:3])
+	}
+	coinbaseID, _ := fb.Txs[0].Id()
+	if inputRows[2][0] != spendID || inputRows[2][2] != coinbaseID || inputRows[2][3] != "0" {
+		t.Errorf("input of the spend should join on the coinbase, got %v", inputRows[2])
+	}
+	if outputRows[1][3] != "p2pk" || outputRows[2][3] != "p2pkh" || outputRows[2][5] == "" || outputRows[3][3] != "op_return" {
+		t.Errorf("unexpected output rows %v", outputRows[1:4])
+	}
+}
@@ -0,0 +1,178 @@
+package analytics
+
+import (
+	"sort"
+
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+// RoundAmount is the unit below which a payment amount is not considered round, 0.001 BTC
+const RoundAmount = 100000
+
+// Clustering groups addresses that are likely controlled by the same wallet
+type Clustering struct {
+	// IDs holds the cluster ID of each address. IDs are numbered from 0 in the order clusters first appear.
+	IDs     map[string]int
+	parents map[string]string
+}
+
+// ClusterAddresses applies the common-input-ownership heuristic to txs: all inputs of a transaction are
+// assumed to be signed by the same wallet. With changeDetection the change output of a transaction, when it
+// can be told apart from the payment, joins the cluster of the inputs too, see ChangeOutput.
+// The transactions are expected in the order they were confirmed, so outputs spent in txs are resolved from
+// txs itself and other ones with lookup. Inputs that cannot be resolved, or have no address, are skipped.
+func ClusterAddresses(txs []*transaction.Tx, lookup transaction.PrevoutLookup, changeDetection bool) (*Clustering, error) {
+	c := &Clustering{parents: make(map[string]string)}
+
+	created := make(map[string]*transaction.Tx)
+	seen := make(map[string]bool)
+	var order []string
+	addAddress := func(address string) {
+		if _, ok := c.parents[address]; !ok {
+			c.parents[address] = address
+			order = append(order, address)
+		}
+	}
+
+	for _, tx := range txs {
+		var inputAddresses, inputTypes []string
+		if !tx.IsCoinbase() {
+			for _, txIn := range tx.TxIns {
+				prevOut := resolvePrevout(txIn, created, lookup)
+				if prevOut == nil {
+					continue
+				}
+				address, err := prevOut.Address(tx.Testnet)
+				if err != nil {
+					continue
+				}
+				inputAddresses = append(inputAddresses, address)
+				inputTypes = append(inputTypes, OutputType(prevOut.ScriptPubkey))
+			}
+		}
+
+		for _, address := range inputAddresses {
+			addAddress(address)
+			c.union(inputAddresses[0], address)
+		}
+
+		if changeDetection && len(inputAddresses) > 0 {
+			if index, ok := ChangeOutput(tx, inputTypes, seen); ok {
+				change, _ := tx.TxOuts[index].Address(tx.Testnet)
+				addAddress(change)
+				c.union(inputAddresses[0], change)
+			}
+		}
+
+		for _, txOut := range tx.TxOuts {
+			if address, err := txOut.Address(tx.Testnet); err == nil {
+				addAddress(address)
+				seen[address] = true
+			}
+		}
+
+		id, err := tx.Id()
+		if err != nil {
+			return nil, err
+		}
+		created[id] = tx
+	}
+
+	c.IDs = make(map[string]int, len(order))
+	roots := make(map[string]int)
+	for _, address := range order {
+		root := c.find(address)
+		if _, ok := roots[root]; !ok {
+			roots[root] = len(roots)
+		}
+		c.IDs[address] = roots[root]
+	}
+
+	return c, nil
+}
+
+// ChangeOutput returns the index of the output of tx that is likely change going back to the sender.
+// An output is a change candidate when its address was not seen before, it is of the same type as all
+// inputs, given by inputTypes, and its amount is not a round number, as payment amounts tend to be.
+// It only returns an output when exactly one of two or more outputs is a candidate.
+func ChangeOutput(tx *transaction.Tx, inputTypes []string, seen map[string]bool) (int, bool) {
+	if len(tx.TxOuts) < 2 || len(inputTypes) == 0 {
+		return 0, false
+	}
+	for _, inputType := range inputTypes[1:] {
+		if inputType != inputTypes[0] {
+			return 0, false
+		}
+	}
+
+	candidate := -1
+	for i, txOut := range tx.TxOuts {
+		address, err := txOut.Address(tx.Testnet)
+		if err != nil || seen[address] {
+			continue
+		}
+		if OutputType(txOut.ScriptPubkey) != inputTypes[0] || txOut.Amount%RoundAmount == 0 {
+			continue
+		}
+		if candidate >= 0 {
+			return 0, false
+		}
+		candidate = i
+	}
+
+	return candidate, candidate >= 0
+}
+
+func resolvePrevout(txIn *transaction.TxIn, created map[string]*transaction.Tx, lookup transaction.PrevoutLookup) *transaction.TxOut {
+	if prevTx, ok := created[txIn.PrevOut.TxidString()]; ok {
+		if txIn.PrevOut.Index < uint32(len(prevTx.TxOuts)) {
+			return prevTx.TxOuts[txIn.PrevOut.Index]
+		}
+		return nil
+	}
+	if lookup == nil {
+		return nil
+	}
+	prevOut, err := lookup(txIn)
+	if err != nil {
+		return nil
+	}
+	return prevOut
+}
+
+// ClusterOf returns the cluster ID of address
+func (c *Clustering) ClusterOf(address string) (int, bool) {
+	id, ok := c.IDs[address]
+	return id, ok
+}
+
+// Clusters returns the addresses of each cluster, indexed by cluster ID, sorted within a cluster
+func (c *Clustering) Clusters() [][]string {
+	clusters := make([][]string, 0)
+	for address, id := range c.IDs {
+		for len(clusters) <= id {
+			clusters = append(clusters, nil)
+		}
+		clusters[id] = append(clusters[id], address)
+	}
+	for _, cluster := range clusters {
+		sort.Strings(cluster)
+	}
+	return clusters
+}
+
+// find returns the representative address of the cluster of address, compressing the path to it
+func (c *Clustering) find(address string) string {
+	for c.parents[address] != address {
+		c.parents[address] = c.parents[c.parents[address]]
+		address = c.parents[address]
+	}
+	return address
+}
+
+func (c *Clustering) union(a, b string) {
+	rootA, rootB := c.find(a), c.find(b)
+	if rootA != rootB {
+		c.parents[rootB] = rootA
+	}
+}
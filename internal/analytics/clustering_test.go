@@ -0,0 +1,100 @@
+package analytics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+func testOutput(amount uint64, key byte) *transaction.TxOut {
+	return transaction.NewTxOut(amount, script.CreateP2pkhScript(bytes.Repeat([]byte{key}, 20)))
+}
+
+func testAddress(t *testing.T, key byte) string {
+	t.Helper()
+	address, err := testOutput(0, key).Address(true)
+	if err != nil {
+		t.Fatalf("Address error: %v", err)
+	}
+	return address
+}
+
+func spending(t *testing.T, prevTx *transaction.Tx, indexes []uint32, txOuts ...*transaction.TxOut) *transaction.Tx {
+	t.Helper()
+	hash, _ := prevTx.Hash()
+	txIns := make([]*transaction.TxIn, len(indexes))
+	for i, index := range indexes {
+		txIns[i] = transaction.NewTxIn(hash, index, &script.Script{}, 0xffffffff)
+	}
+	return transaction.NewTx(1, txIns, txOuts, 0, true)
+}
+
+func TestClusterAddresses(t *testing.T) {
+	// funds addresses 1 and 2 from outside the set
+	funding := transaction.NewTx(1, []*transaction.TxIn{transaction.NewTxIn(bytes.Repeat([]byte{0xff}, 32), 0, &script.Script{}, 0xffffffff)},
+		[]*transaction.TxOut{testOutput(100000000, 1), testOutput(200000000, 2)}, 0, true)
+	// 1 and 2 pay a round 0.5 BTC to 3, the change goes to the fresh address 4
+	payment := spending(t, funding, []uint32{0, 1}, testOutput(50000000, 3), testOutput(249987000, 4))
+	// 3 sends everything on to 5
+	forward := spending(t, payment, []uint32{0}, testOutput(49990000, 5))
+	txs := []*transaction.Tx{funding, payment, forward}
+
+	clustering, err := ClusterAddresses(txs, nil, false)
+	if err != nil {
+		t.Fatalf("ClusterAddresses error: %v", err)
+	}
+	id1, _ := clustering.ClusterOf(testAddress(t, 1))
+	id2, _ := clustering.ClusterOf(testAddress(t, 2))
+	id4, _ := clustering.ClusterOf(testAddress(t, 4))
+	if id1 != id2 || id1 == id4 {
+		t.Errorf("Inputs should share a cluster without the change: %v", clustering.IDs)
+	}
+	if len(clustering.Clusters()) != 4 {
+		t.Errorf("Expected 4 clusters, got %v", clustering.Clusters())
+	}
+
+	clustering, err = ClusterAddresses(txs, nil, true)
+	if err != nil {
+		t.Fatalf("ClusterAddresses error: %v", err)
+	}
+	id1, _ = clustering.ClusterOf(testAddress(t, 1))
+	id3, _ := clustering.ClusterOf(testAddress(t, 3))
+	id4, _ = clustering.ClusterOf(testAddress(t, 4))
+	if id1 != id4 || id1 == id3 {
+		t.Errorf("The change should join the cluster of the inputs: %v", clustering.IDs)
+	}
+	clusters := clustering.Clusters()
+	if len(clusters) != 3 || len(clusters[id1]) != 3 {
+		t.Errorf("Expected clusters {1, 2, 4}, {3} and {5}, got %v", clusters)
+	}
+
+	if _, ok := clustering.ClusterOf(testAddress(t, 9)); ok {
+		t.Errorf("Unknown address should not have a cluster")
+	}
+}
+
+func TestChangeOutput(t *testing.T) {
+	tx := transaction.NewTx(1, nil, []*transaction.TxOut{testOutput(50000000, 1), testOutput(12345678, 2)}, 0, true)
+
+	if index, ok := ChangeOutput(tx, []string{"p2pkh"}, map[string]bool{}); !ok || index != 1 {
+		t.Errorf("Expected the non-round output as change, got %d %v", index, ok)
+	}
+
+	// an address seen before is not fresh change
+	if _, ok := ChangeOutput(tx, []string{"p2pkh"}, map[string]bool{testAddress(t, 2): true}); ok {
+		t.Errorf("Reused address should not be change")
+	}
+
+	// the inputs are of another type than both outputs
+	if _, ok := ChangeOutput(tx, []string{"p2wpkh"}, map[string]bool{}); ok {
+		t.Errorf("Outputs of another type than the inputs should not be change")
+	}
+
+	// two candidates are ambiguous
+	tx.TxOuts[0].Amount = 50000001
+	if _, ok := ChangeOutput(tx, []string{"p2pkh"}, map[string]bool{}); ok {
+		t.Errorf("Two change candidates should be ambiguous")
+	}
+}
@@ -0,0 +1,188 @@
+package analytics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+// FindingKind is a kind of privacy problem found in the history of a wallet
+type FindingKind int
+
+const (
+	// AddressReuse is an address of the wallet that received in more than one transaction
+	AddressReuse FindingKind = iota
+	// DustReceived is an output below the dust limit paid to the wallet, it costs more to spend than it is worth
+	// and is a common way to trace wallets
+	DustReceived
+	// LinkableChange is a change output that ChangeOutput tells apart from the payment
+	LinkableChange
+	// RoundPayment is a round payment amount sent next to change that is not round, which gives away the payment
+	RoundPayment
+)
+
+func (k FindingKind) String() string {
+	switch k {
+	case AddressReuse:
+		return "address reuse"
+	case DustReceived:
+		return "dust received"
+	case LinkableChange:
+		return "linkable change"
+	case RoundPayment:
+		return "round payment"
+	}
+	return fmt.Sprintf("FindingKind(%d)", int(k))
+}
+
+// Finding is one privacy problem in a transaction
+type Finding struct {
+	Kind    FindingKind
+	TxID    string
+	Address string
+	Amount  uint64
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s in %s (%d satoshis)", f.Kind, f.Address, f.TxID, f.Amount)
+}
+
+// HygieneReport lists the privacy problems in the history of a wallet
+type HygieneReport struct {
+	Findings []Finding
+}
+
+// Count returns the number of findings of kind
+func (r *HygieneReport) Count(kind FindingKind) int {
+	var count int
+	for _, finding := range r.Findings {
+		if finding.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *HygieneReport) String() string {
+	if len(r.Findings) == 0 {
+		return "no findings\n"
+	}
+	var sb strings.Builder
+	for _, finding := range r.Findings {
+		fmt.Fprintln(&sb, finding)
+	}
+	return sb.String()
+}
+
+// dustLimits are the amounts below which Bitcoin Core considers an output of a type dust at the default relay fee
+var dustLimits = map[string]uint64{
+	"p2pk":   576,
+	"p2pkh":  546,
+	"p2sh":   540,
+	"p2wpkh": 294,
+	"p2wsh":  330,
+	"p2tr":   330,
+}
+
+// IsDust returns whether txOut is worth less than the fee of spending it
+func IsDust(txOut *transaction.TxOut) bool {
+	limit, ok := dustLimits[OutputType(txOut.ScriptPubkey)]
+	return ok && txOut.Amount < limit
+}
+
+// AuditWallet checks the history of the wallet that owns addresses for address reuse, dust, change that can
+// be linked to the wallet and round payments. The transactions are expected in the order they were confirmed,
+// spent outputs are resolved like ClusterAddresses does.
+func AuditWallet(addresses []string, txs []*transaction.Tx, lookup transaction.PrevoutLookup) (*HygieneReport, error) {
+	owned := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		owned[address] = true
+	}
+
+	report := &HygieneReport{}
+	created := make(map[string]*transaction.Tx)
+	seen := make(map[string]bool)
+	received := make(map[string]string)
+
+	for _, tx := range txs {
+		id, err := tx.Id()
+		if err != nil {
+			return nil, err
+		}
+
+		var inputTypes []string
+		spends := false
+		if !tx.IsCoinbase() {
+			for _, txIn := range tx.TxIns {
+				prevOut := resolvePrevout(txIn, created, lookup)
+				if prevOut == nil {
+					continue
+				}
+				inputTypes = append(inputTypes, OutputType(prevOut.ScriptPubkey))
+				if address, err := prevOut.Address(tx.Testnet); err == nil && owned[address] {
+					spends = true
+				}
+			}
+		}
+
+		if spends {
+			report.Findings = append(report.Findings, outgoingFindings(tx, id, owned, inputTypes, seen)...)
+		}
+
+		for _, txOut := range tx.TxOuts {
+			address, err := txOut.Address(tx.Testnet)
+			if err != nil {
+				continue
+			}
+			seen[address] = true
+			if !owned[address] {
+				continue
+			}
+			if IsDust(txOut) && !spends {
+				report.Findings = append(report.Findings, Finding{DustReceived, id, address, txOut.Amount})
+			}
+			// an address that receives twice in one transaction is reused only once
+			if first, ok := received[address]; ok && first != id {
+				report.Findings = append(report.Findings, Finding{AddressReuse, id, address, txOut.Amount})
+			} else {
+				received[address] = id
+			}
+		}
+
+		created[id] = tx
+	}
+
+	return report, nil
+}
+
+// outgoingFindings checks a transaction the wallet sent for change that can be told apart from the payments
+func outgoingFindings(tx *transaction.Tx, id string, owned map[string]bool, inputTypes []string, seen map[string]bool) []Finding {
+	var findings []Finding
+	if index, ok := ChangeOutput(tx, inputTypes, seen); ok {
+		txOut := tx.TxOuts[index]
+		if address, _ := txOut.Address(tx.Testnet); owned[address] {
+			findings = append(findings, Finding{LinkableChange, id, address, txOut.Amount})
+		}
+	}
+
+	changeIsRound := true
+	for _, txOut := range tx.TxOuts {
+		if address, err := txOut.Address(tx.Testnet); err == nil && owned[address] && txOut.Amount%RoundAmount != 0 {
+			changeIsRound = false
+		}
+	}
+	if changeIsRound {
+		return findings
+	}
+	for _, txOut := range tx.TxOuts {
+		address, err := txOut.Address(tx.Testnet)
+		if err != nil || owned[address] {
+			continue
+		}
+		if txOut.Amount%RoundAmount == 0 {
+			findings = append(findings, Finding{RoundPayment, id, address, txOut.Amount})
+		}
+	}
+	return findings
+}
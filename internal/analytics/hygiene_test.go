@@ -0,0 +1,68 @@
+package analytics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+func TestAuditWallet(t *testing.T) {
+	outside := transaction.NewTxIn(bytes.Repeat([]byte{0xff}, 32), 0, &script.Script{}, 0xffffffff)
+	// funds addresses 1 and 2 of the wallet from outside
+	funding := transaction.NewTx(1, []*transaction.TxIn{outside}, []*transaction.TxOut{testOutput(100000000, 1), testOutput(200000000, 2)}, 0, true)
+	// the wallet pays a round 0.5 BTC to 3, the change goes to its fresh address 4
+	payment := spending(t, funding, []uint32{0, 1}, testOutput(50000000, 3), testOutput(249987000, 4))
+	// someone sends dust to the used address 1
+	dusting := transaction.NewTx(1, []*transaction.TxIn{transaction.NewTxIn(bytes.Repeat([]byte{0xee}, 32), 0, &script.Script{}, 0xffffffff)},
+		[]*transaction.TxOut{testOutput(500, 1)}, 0, true)
+
+	wallet := []string{testAddress(t, 1), testAddress(t, 2), testAddress(t, 4)}
+	report, err := AuditWallet(wallet, []*transaction.Tx{funding, payment, dusting}, nil)
+	if err != nil {
+		t.Fatalf("AuditWallet error: %v", err)
+	}
+
+	want := map[FindingKind]string{
+		LinkableChange: testAddress(t, 4),
+		RoundPayment:   testAddress(t, 3),
+		DustReceived:   testAddress(t, 1),
+		AddressReuse:   testAddress(t, 1),
+	}
+	if len(report.Findings) != len(want) {
+		t.Fatalf("got findings\n%s", report)
+	}
+	for _, finding := range report.Findings {
+		if want[finding.Kind] != finding.Address {
+			t.Errorf("unexpected finding %s", finding)
+		}
+	}
+	if report.Count(DustReceived) != 1 || report.Count(AddressReuse) != 1 {
+		t.Errorf("got counts %d and %d", report.Count(DustReceived), report.Count(AddressReuse))
+	}
+}
+
+func TestAuditWalletClean(t *testing.T) {
+	funding := transaction.NewTx(1, []*transaction.TxIn{transaction.NewTxIn(bytes.Repeat([]byte{0xff}, 32), 0, &script.Script{}, 0xffffffff)},
+		[]*transaction.TxOut{testOutput(100000000, 1)}, 0, true)
+	// a payment with no change leaks nothing
+	payment := spending(t, funding, []uint32{0}, testOutput(99990000, 3))
+
+	report, err := AuditWallet([]string{testAddress(t, 1)}, []*transaction.Tx{funding, payment}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Findings) != 0 {
+		t.Errorf("got findings\n%s", report)
+	}
+}
+
+func TestIsDust(t *testing.T) {
+	if !IsDust(testOutput(545, 1)) || IsDust(testOutput(546, 1)) {
+		t.Error("p2pkh dust limit should be 546 satoshis")
+	}
+	if IsDust(transaction.NewTxOut(0, &script.Script{{0x6a}})) {
+		t.Error("OP_RETURN outputs are not dust")
+	}
+}
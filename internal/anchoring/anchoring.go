@@ -0,0 +1,187 @@
+package anchoring
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+const (
+	// CommitmentSize is the size of a commitment, such as the sha256 of a document
+	CommitmentSize = 32
+	// MaxOpReturnData is the most data relay policy allows in an OP_RETURN output
+	MaxOpReturnData = 80
+	// MaxCommitmentsPerOutput is how many commitments fit in one OP_RETURN output after the header
+	MaxCommitmentsPerOutput = (MaxOpReturnData - len(magic) - 1) / CommitmentSize
+
+	// every commitment output starts with the magic and a version byte
+	magic   = "CMT"
+	version = byte(1)
+)
+
+// Entry locates a commitment: the output of a transaction and the position within that output
+type Entry struct {
+	TxID     string `json:"txid"`
+	Vout     uint32 `json:"vout"`
+	Position int    `json:"position"`
+}
+
+// Index maps hex commitments to where they are anchored
+type Index struct {
+	Testnet bool              `json:"testnet"`
+	Entries map[string]*Entry `json:"entries"`
+}
+
+// Pack returns the OP_RETURN outputs that anchor commitments, in order.
+// Relay policy allows a single OP_RETURN output per transaction, so each needs its own transaction.
+func Pack(commitments [][]byte) ([]*transaction.TxOut, error) {
+	if len(commitments) == 0 {
+		return nil, fmt.Errorf("nothing to commit to")
+	}
+
+	var txOuts []*transaction.TxOut
+	for start := 0; start < len(commitments); start += MaxCommitmentsPerOutput {
+		end := min(start+MaxCommitmentsPerOutput, len(commitments))
+		txOut, err := CommitmentOutput(commitments[start:end])
+		if err != nil {
+			return nil, err
+		}
+		txOuts = append(txOuts, txOut)
+	}
+
+	return txOuts, nil
+}
+
+// CommitmentOutput returns a zero value OP_RETURN output with up to MaxCommitmentsPerOutput commitments
+func CommitmentOutput(commitments [][]byte) (*transaction.TxOut, error) {
+	if len(commitments) == 0 || len(commitments) > MaxCommitmentsPerOutput {
+		return nil, fmt.Errorf("an output holds 1 to %d commitments, got %d", MaxCommitmentsPerOutput, len(commitments))
+	}
+
+	payload := append([]byte(magic), version)
+	for _, commitment := range commitments {
+		if len(commitment) != CommitmentSize {
+			return nil, fmt.Errorf("commitment %x is not %d bytes", commitment, CommitmentSize)
+		}
+		payload = append(payload, commitment...)
+	}
+
+	return transaction.NewTxOut(0, &script.Script{[]byte{0x6a}, payload}), nil
+}
+
+// Extract returns the commitments in txOut, or an error when it is not a commitment output
+func Extract(txOut *transaction.TxOut) ([][]byte, error) {
+	s := txOut.ScriptPubkey
+	if s == nil || len(*s) != 2 || !script.IsOp((*s)[0], 0x6a) {
+		return nil, fmt.Errorf("not an OP_RETURN output")
+	}
+
+	payload := (*s)[1]
+	header := len(magic) + 1
+	if len(payload) < header || string(payload[:len(magic)]) != magic {
+		return nil, fmt.Errorf("not a commitment output")
+	}
+	if payload[len(magic)] != version {
+		return nil, fmt.Errorf("unknown commitment version %d", payload[len(magic)])
+	}
+
+	data := payload[header:]
+	if len(data) == 0 || len(data)%CommitmentSize != 0 {
+		return nil, fmt.Errorf("commitment data of %d bytes is malformed", len(data))
+	}
+
+	var commitments [][]byte
+	for i := 0; i < len(data); i += CommitmentSize {
+		commitments = append(commitments, data[i:i+CommitmentSize])
+	}
+	return commitments, nil
+}
+
+// NewIndex records the commitments anchored by the outputs of txs
+func NewIndex(txs []*transaction.Tx, testnet bool) (*Index, error) {
+	index := &Index{Testnet: testnet, Entries: make(map[string]*Entry)}
+
+	for _, tx := range txs {
+		id, err := tx.Id()
+		if err != nil {
+			return nil, err
+		}
+		for vout, txOut := range tx.TxOuts {
+			commitments, err := Extract(txOut)
+			if err != nil {
+				continue
+			}
+			for position, commitment := range commitments {
+				index.Entries[hex.EncodeToString(commitment)] = &Entry{TxID: id, Vout: uint32(vout), Position: position}
+			}
+		}
+	}
+
+	return index, nil
+}
+
+// ParseIndex reads an index from its JSON serialization
+func ParseIndex(data []byte) (*Index, error) {
+	index := &Index{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, err
+	}
+	if index.Entries == nil {
+		index.Entries = make(map[string]*Entry)
+	}
+	return index, nil
+}
+
+// Serialize returns the JSON serialization of the index
+func (index *Index) Serialize() ([]byte, error) {
+	return json.MarshalIndent(index, "", "  ")
+}
+
+// Lookup returns where commitment is anchored
+func (index *Index) Lookup(commitment []byte) (*Entry, bool) {
+	entry, ok := index.Entries[hex.EncodeToString(commitment)]
+	return entry, ok
+}
+
+// Verify checks that tx is the transaction of entry and anchors commitment where entry says
+func Verify(tx *transaction.Tx, entry *Entry, commitment []byte) error {
+	id, err := tx.Id()
+	if err != nil {
+		return err
+	}
+	if id != entry.TxID {
+		return fmt.Errorf("transaction %s is not %s", id, entry.TxID)
+	}
+	if entry.Vout >= uint32(len(tx.TxOuts)) {
+		return fmt.Errorf("output %d does not exist in %s", entry.Vout, id)
+	}
+
+	commitments, err := Extract(tx.TxOuts[entry.Vout])
+	if err != nil {
+		return fmt.Errorf("output %d of %s: %v", entry.Vout, id, err)
+	}
+	if entry.Position < 0 || entry.Position >= len(commitments) || !bytes.Equal(commitments[entry.Position], commitment) {
+		return fmt.Errorf("commitment %x is not at position %d of output %d of %s", commitment, entry.Position, entry.Vout, id)
+	}
+
+	return nil
+}
+
+// VerifyWith fetches the transaction of the entry for commitment in the index and verifies it
+func (index *Index) VerifyWith(fetcher *transaction.TxFetcher, commitment []byte) error {
+	entry, ok := index.Lookup(commitment)
+	if !ok {
+		return fmt.Errorf("commitment %x is not in the index", commitment)
+	}
+
+	tx, err := fetcher.Fetch(entry.TxID, index.Testnet, false)
+	if err != nil {
+		return err
+	}
+
+	return Verify(tx, entry, commitment)
+}
@@ -0,0 +1,139 @@
+package anchoring
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func testCommitments(n int) [][]byte {
+	var commitments [][]byte
+	for i := 0; i < n; i++ {
+		commitments = append(commitments, utils.Sha256Hash([]byte{byte(i)}))
+	}
+	return commitments
+}
+
+// anchorTxs puts every output in a transaction of its own, as they would be broadcast
+func anchorTxs(txOuts []*transaction.TxOut) []*transaction.Tx {
+	var txs []*transaction.Tx
+	for i, txOut := range txOuts {
+		txIn := transaction.NewTxIn(utils.Hash256([]byte{byte(i)}), 0, &script.Script{}, 0xffffffff)
+		change := transaction.NewTxOut(10000, script.CreateP2pkhScript(utils.Hash160([]byte{byte(i)})))
+		txs = append(txs, transaction.NewTx(1, []*transaction.TxIn{txIn}, []*transaction.TxOut{change, txOut}, 0, true))
+	}
+	return txs
+}
+
+func TestPack(t *testing.T) {
+	commitments := testCommitments(5)
+
+	txOuts, err := Pack(commitments)
+	if err != nil {
+		t.Fatalf("Error packing commitments: %v", err)
+	}
+	if len(txOuts) != 3 {
+		t.Fatalf("Expected 3 outputs, got %d", len(txOuts))
+	}
+
+	var extracted [][]byte
+	for _, txOut := range txOuts {
+		raw, err := txOut.ScriptPubkey.RawSerialize()
+		if err != nil {
+			t.Fatalf("Error serializing output: %v", err)
+		}
+		if len(raw) > MaxOpReturnData+3 {
+			t.Errorf("Output script of %d bytes exceeds relay policy", len(raw))
+		}
+
+		// the outputs survive a serialization round trip
+		serialized, _ := txOut.Serialize()
+		parsed, err := transaction.ParseTxOut(bufio.NewReader(bytes.NewReader(serialized)))
+		if err != nil {
+			t.Fatalf("Error parsing output: %v", err)
+		}
+		batch, err := Extract(parsed)
+		if err != nil {
+			t.Fatalf("Error extracting commitments: %v", err)
+		}
+		extracted = append(extracted, batch...)
+	}
+
+	for i := range commitments {
+		if !bytes.Equal(extracted[i], commitments[i]) {
+			t.Errorf("Commitment %d: expected %x, got %x", i, commitments[i], extracted[i])
+		}
+	}
+
+	if _, err := Pack([][]byte{{0x01, 0x02}}); err == nil {
+		t.Errorf("Expected an error for a short commitment")
+	}
+}
+
+func TestExtractRejectsOtherOutputs(t *testing.T) {
+	outputs := []*transaction.TxOut{
+		transaction.NewTxOut(0, &script.Script{[]byte{0x6a}, []byte("hello world")}),
+		transaction.NewTxOut(0, &script.Script{[]byte{0x6a}, append([]byte("CMT\x01"), 0x01, 0x02)}),
+		transaction.NewTxOut(0, &script.Script{[]byte{0x6a}, append([]byte("CMT\x02"), make([]byte, 32)...)}),
+		transaction.NewTxOut(1000, script.CreateP2pkhScript(make([]byte, 20))),
+	}
+
+	for i, txOut := range outputs {
+		if _, err := Extract(txOut); err == nil {
+			t.Errorf("Output %d: expected an error", i)
+		}
+	}
+}
+
+func TestIndexVerify(t *testing.T) {
+	commitments := testCommitments(3)
+	txOuts, err := Pack(commitments)
+	if err != nil {
+		t.Fatalf("Error packing commitments: %v", err)
+	}
+	txs := anchorTxs(txOuts)
+
+	index, err := NewIndex(txs, true)
+	if err != nil {
+		t.Fatalf("Error indexing: %v", err)
+	}
+
+	data, err := index.Serialize()
+	if err != nil {
+		t.Fatalf("Error serializing index: %v", err)
+	}
+	index, err = ParseIndex(data)
+	if err != nil {
+		t.Fatalf("Error parsing index: %v", err)
+	}
+
+	for i, commitment := range commitments {
+		entry, ok := index.Lookup(commitment)
+		if !ok {
+			t.Fatalf("Commitment %d is not in the index", i)
+		}
+		if entry.Vout != 1 || entry.Position != i%MaxCommitmentsPerOutput {
+			t.Errorf("Commitment %d: unexpected entry %+v", i, entry)
+		}
+		if err := Verify(txs[i/MaxCommitmentsPerOutput], entry, commitment); err != nil {
+			t.Errorf("Commitment %d: %v", i, err)
+		}
+	}
+
+	// a commitment does not verify against another position or transaction
+	entry, _ := index.Lookup(commitments[0])
+	if err := Verify(txs[0], entry, commitments[1]); err == nil {
+		t.Errorf("Expected an error for a commitment at the wrong position")
+	}
+	if err := Verify(txs[1], entry, commitments[0]); err == nil {
+		t.Errorf("Expected an error for the wrong transaction")
+	}
+
+	if _, ok := index.Lookup(utils.Sha256Hash([]byte("unknown"))); ok {
+		t.Errorf("Expected an unknown commitment not to be found")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"math/big"
 	"slices"
 
+	"github.com/caspereijkens/cryptocurrency/internal/params"
 	"github.com/caspereijkens/cryptocurrency/internal/utils"
 )
 
@@ -29,39 +30,44 @@ func Parse(r io.Reader) (*Block, error) {
 	block := &Block{}
 	err := binary.Read(r, binary.LittleEndian, &block.Version)
 	if err != nil {
-		return nil, err
+		return nil, malformedBlock(err)
 	}
 
 	_, err = io.ReadFull(r, block.PrevBlock[:])
 	if err != nil {
-		return nil, err
+		return nil, malformedBlock(err)
 	}
 	slices.Reverse(block.PrevBlock[:])
 
 	_, err = io.ReadFull(r, block.MerkleRoot[:])
 	if err != nil {
-		return nil, err
+		return nil, malformedBlock(err)
 	}
 	slices.Reverse(block.MerkleRoot[:])
 
 	err = binary.Read(r, binary.LittleEndian, &block.Timestamp)
 	if err != nil {
-		return nil, err
+		return nil, malformedBlock(err)
 	}
 
 	err = binary.Read(r, binary.BigEndian, &block.Bits)
 	if err != nil {
-		return nil, err
+		return nil, malformedBlock(err)
 	}
 
 	err = binary.Read(r, binary.BigEndian, &block.Nonce)
 	if err != nil {
-		return nil, err
+		return nil, malformedBlock(err)
 	}
 
 	return block, nil
 }
 
+// ParseHeader parses a block from its 80-byte serialization
+func ParseHeader(raw []byte) (*Block, error) {
+	return Parse(bytes.NewReader(raw))
+}
+
 // Serialize serializes the block into a byte slice
 func (b *Block) Serialize() ([]byte, error) {
 	var buf bytes.Buffer
@@ -72,14 +78,16 @@ func (b *Block) Serialize() ([]byte, error) {
 		return nil, err
 	}
 
-	// Serialize prev_block (reversed)
-	_, err = buf.Write(utils.ReverseBytes(b.PrevBlock[:]))
+	// Serialize prev_block (reversed), on a copy so the block itself is not modified
+	prevBlock := b.PrevBlock
+	_, err = buf.Write(utils.ReverseBytes(prevBlock[:]))
 	if err != nil {
 		return nil, err
 	}
 
 	// Serialize merkle_root (reversed)
-	_, err = buf.Write(utils.ReverseBytes(b.MerkleRoot[:]))
+	merkleRoot := b.MerkleRoot
+	_, err = buf.Write(utils.ReverseBytes(merkleRoot[:]))
 	if err != nil {
 		return nil, err
 	}
@@ -133,18 +141,35 @@ func (b *Block) Target() *big.Int {
 	return BitsToTarget(b.Bits)
 }
 
-// BitsToTarget converts the bits representation to target
+// BitsToTarget converts the bits representation to target. The sign bit is not part of the number,
+// use DecodeBits to tell negative and overflowing targets apart.
 func BitsToTarget(bits uint32) *big.Int {
-	byteSlice := make([]byte, 4)
-	binary.BigEndian.PutUint32(byteSlice, bits)
-	exponent := byteSlice[3]
-	byteSlice[3] = 0x00
-	coefficient := big.NewInt(int64(binary.LittleEndian.Uint32(byteSlice)))
-	target := new(big.Int).Exp(big.NewInt(256), big.NewInt(int64(exponent-3)), nil)
-	target.Mul(target, coefficient)
+	target, _, _ := DecodeBits(bits)
 	return target
 }
 
+// DecodeBits decodes bits like SetCompact of Bitcoin Core: the target without the sign bit 0x00800000 of the
+// coefficient, whether that bit makes it negative and whether it does not fit in 256 bits. Like the Bits of a
+// Block, bits are in the byte order of the header, the reverse of the compact numbers of Bitcoin Core.
+func DecodeBits(bits uint32) (target *big.Int, negative bool, overflow bool) {
+	raw := make([]byte, 4)
+	binary.LittleEndian.PutUint32(raw, bits)
+	compact := binary.BigEndian.Uint32(raw)
+	exponent := compact >> 24
+	coefficient := compact & 0x007fffff
+	// An exponent below 3 shifts bytes of the coefficient out instead of adding zeros
+	if exponent <= 3 {
+		coefficient >>= 8 * (3 - exponent)
+	}
+	target = big.NewInt(int64(coefficient))
+	if exponent > 3 {
+		target.Lsh(target, uint(8*(exponent-3)))
+	}
+	negative = coefficient != 0 && compact&0x00800000 != 0
+	overflow = coefficient != 0 && (exponent > 34 || (coefficient > 0xff && exponent > 33) || (coefficient > 0xffff && exponent > 32))
+	return target, negative, overflow
+}
+
 // Difficulty returns the block difficulty based on the bits
 func (b *Block) Difficulty() *big.Int {
 	lowestDifficultyBits := uint32(0xffff001d)
@@ -157,37 +182,78 @@ func (b *Block) Difficulty() *big.Int {
 	return difficulty
 }
 
-// CheckPOW returns whether this block satisfies proof of work
-func (b *Block) CheckPOW() bool {
-	hash, _ := b.Hash()
-	hashInt := new(big.Int).SetBytes(hash)
+// CheckPOW returns whether this block satisfies proof of work on mainnet or testnet, like CheckProofOfWork of
+// Bitcoin Core: its bits encode a positive target no easier than PowLimit and its hash is at most that target
+func (b *Block) CheckPOW(testnet bool) bool {
+	target, negative, overflow := DecodeBits(b.Bits)
+	if negative || overflow || target.Sign() == 0 || target.Cmp(PowLimit(testnet)) > 0 {
+		return false
+	}
+	hash, err := b.Hash()
+	if err != nil {
+		return false
+	}
+	hashValue, err := utils.Uint256FromBytes(hash)
+	if err != nil {
+		return false
+	}
+	targetValue, err := utils.Uint256FromBig(target)
+	if err != nil {
+		return false
+	}
+	return hashValue.Cmp(targetValue) <= 0
+}
+
+// Work returns the expected number of hashes needed to find a block with this target, 2^256 / (target+1)
+func (b *Block) Work() utils.Uint256 {
 	target := b.Target()
-	return target.Cmp(hashInt) == 1
+	if target.Sign() <= 0 {
+		return utils.Uint256{}
+	}
+	work := new(big.Int).Lsh(big.NewInt(1), 256)
+	work.Div(work, target.Add(target, big.NewInt(1)))
+	// target+1 is at least 2, so the work fits in 256 bits
+	value, _ := utils.Uint256FromBig(work)
+	return value
 }
 
+// TargetToBits converts a target to the bits representation, dropping the bytes after the first three.
+// Targets that are zero or negative have no bits representation and give 0.
 func TargetToBits(target *big.Int) uint32 {
-	var exponent int
-	var coefficient []byte
-
-	rawBytes := make([]byte, 32)
-	target.FillBytes(rawBytes)
-	rawBytes = utils.LstripNullBytes(rawBytes)
+	if target.Sign() <= 0 {
+		return 0
+	}
+	rawBytes := target.Bytes()
+	// The coefficient is signed, a leading byte with the high bit set needs a zero byte in front of it
 	if rawBytes[0] > 0x7f {
-		exponent = len(rawBytes) + 1
-		coefficient = append([]byte{0x00}, rawBytes[:2]...)
-	} else {
-		exponent = len(rawBytes)
-		coefficient = rawBytes[:3]
+		rawBytes = append([]byte{0x00}, rawBytes...)
 	}
+	exponent := len(rawBytes)
+	// Targets shorter than three bytes are padded on the right, the exponent then shifts them back
+	coefficient := make([]byte, 3)
+	copy(coefficient, rawBytes)
 	bits := append(utils.ReverseBytes(coefficient), byte(exponent))
 
 	return binary.BigEndian.Uint32(bits)
 }
 
+// PowLimit returns the easiest target a block of mainnet or testnet may have
+func PowLimit(testnet bool) *big.Int {
+	return BitsToTarget(params.ForNetwork(testnet).PowLimitBits)
+}
+
+// CalculateNewBits returns the bits of the next retarget period on mainnet, see CalculateNewBitsWithLimit
 func CalculateNewBits(previousBits uint32, timeDifferential int64) uint32 {
+	return CalculateNewBitsWithLimit(previousBits, timeDifferential, PowLimit(false))
+}
+
+// CalculateNewBitsWithLimit returns the bits of the next retarget period, given the bits of the last period and
+// the seconds it took. The target changes by at most a factor of 4 either way and never gets easier than powLimit.
+func CalculateNewBitsWithLimit(previousBits uint32, timeDifferential int64, powLimit *big.Int) uint32 {
 	previousTarget := BitsToTarget(previousBits)
 
-	// Ensure time differential is within the specified range between 0.5 week and 8 weeks
+	// Ensure time differential is within the specified range between 0.5 week and 8 weeks. Timestamps only have to
+	// exceed the median of the last 11 blocks, so a zero or negative differential is possible and clamps to 0.5 week.
 	if timeDifferential > twoWeeks*4 {
 		timeDifferential = twoWeeks * 4
 	}
@@ -197,6 +263,9 @@ func CalculateNewBits(previousBits uint32, timeDifferential int64) uint32 {
 
 	newTarget := new(big.Int).Mul(previousTarget, big.NewInt(timeDifferential))
 	newTarget.Div(newTarget, big.NewInt(twoWeeks))
+	if newTarget.Cmp(powLimit) > 0 {
+		newTarget.Set(powLimit)
+	}
 
 	return TargetToBits(newTarget)
 }
@@ -2,13 +2,16 @@ package block
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"math/big"
 	"reflect"
 	"strconv"
 	"testing"
 
+	"github.com/caspereijkens/cryptocurrency/internal/params"
 	"github.com/caspereijkens/cryptocurrency/internal/utils"
 )
 
@@ -225,7 +228,7 @@ func TestCheckPow(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if !block1.CheckPOW() {
+	if !block1.CheckPOW(false) {
 		t.Errorf("Block 1's Proof of Work check failed")
 	}
 
@@ -235,11 +238,69 @@ func TestCheckPow(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if block2.CheckPOW() {
+	if block2.CheckPOW(false) {
 		t.Errorf("Block 2's Proof of Work check passed, but it shouldn't have")
 	}
 }
 
+func TestDecodeBits(t *testing.T) {
+	tests := []struct {
+		bits     uint32
+		target   string
+		negative bool
+		overflow bool
+	}{
+		{0x1d00ffff, "ffff0000000000000000000000000000000000000000000000000000", false, false},
+		{0x01003456, "0", false, false},
+		{0x02123456, "1234", false, false},
+		// the sign bit is not part of the number
+		{0x04923456, "12345600", true, false},
+		{0x01fedcba, "7e", true, false},
+		{0x00923456, "0", false, false},
+		// a zero coefficient is zero whatever the exponent
+		{0xff000000, "0", false, false},
+		{0x22010000, "", false, true},
+		{0x21010000, "", false, true},
+		{0x20010000, "", false, false},
+	}
+	for _, tt := range tests {
+		// the table has the compact numbers of Bitcoin Core, Bits are in the byte order of the header
+		target, negative, overflow := DecodeBits(headerBits(tt.bits))
+		if negative != tt.negative || overflow != tt.overflow {
+			t.Errorf("DecodeBits(%08x) negative %v, overflow %v, want %v, %v", tt.bits, negative, overflow, tt.negative, tt.overflow)
+		}
+		if tt.target != "" && target.Text(16) != tt.target {
+			t.Errorf("DecodeBits(%08x) target %s, want %s", tt.bits, target.Text(16), tt.target)
+		}
+	}
+}
+
+// headerBits returns the Bits of a block with the compact number of Bitcoin Core
+func headerBits(compact uint32) uint32 {
+	raw := make([]byte, 4)
+	binary.BigEndian.PutUint32(raw, compact)
+	return binary.LittleEndian.Uint32(raw)
+}
+
+func TestCheckPOWRejectsBadTargets(t *testing.T) {
+	// the genesis block meets the target and pow limit of its network only
+	if !Genesis(false).CheckPOW(false) || !Genesis(true).CheckPOW(true) {
+		t.Fatalf("Genesis blocks should satisfy proof of work")
+	}
+	for _, bits := range []uint32{
+		// negative, zero and overflowing targets
+		0x1d80ffff, 0x1d000000, 0x23010000,
+		// easier than the pow limit
+		0x1e00ffff, 0x2100ffff,
+	} {
+		block := Genesis(false)
+		block.Bits = headerBits(bits)
+		if block.CheckPOW(false) {
+			t.Errorf("Block with bits %08x should fail proof of work", bits)
+		}
+	}
+}
+
 func TestTargetToBits(t *testing.T) {
 	// Parse the block
 	blockRaw, _ := hex.DecodeString("020000208ec39428b17323fa0ddec8e887b4a7c53b8c0a0a220cfd0000000000000000005b0750fce0a889502d40508d39576821155e9c9e3f5c3157f961db38fd8b25be1e77a759e93c0118a4ffd71d")
@@ -278,3 +339,107 @@ func TestCalculateNewBits(t *testing.T) {
 		t.Errorf("calculateNewBits() = %d, want %d", gotBits, wantBits)
 	}
 }
+
+func TestTargetToBitsEdgeCases(t *testing.T) {
+	tests := []struct {
+		name   string
+		target *big.Int
+		bits   uint32
+	}{
+		{"zero", big.NewInt(0), 0},
+		{"negative", big.NewInt(-1), 0},
+		{"one byte", big.NewInt(0x12), 0x00001201},
+		{"high bit", big.NewInt(0x80), 0x00800002},
+		{"mainnet limit", PowLimit(false), LowestDifficultyBits},
+	}
+	for _, tt := range tests {
+		if got := TargetToBits(tt.target); got != tt.bits {
+			t.Errorf("%s: TargetToBits = %08x, want %08x", tt.name, got, tt.bits)
+		}
+	}
+
+	// targets of more than 32 bytes do not fit a header but still convert
+	huge := new(big.Int).Lsh(big.NewInt(1), 256)
+	if got := BitsToTarget(TargetToBits(huge)); got.Cmp(huge) != 0 {
+		t.Errorf("Round trip of 2^256 = %x", got)
+	}
+	if got := BitsToTarget(0x56341201); got.Int64() != 0x12 {
+		t.Errorf("BitsToTarget with exponent 1 = %x, want 12", got)
+	}
+}
+
+func TestPowLimit(t *testing.T) {
+	// the genesis blocks have the easiest target allowed
+	for _, testnet := range []bool{false, true} {
+		if Genesis(testnet).Target().Cmp(PowLimit(testnet)) != 0 {
+			t.Errorf("Genesis target of testnet=%v differs from the pow limit", testnet)
+		}
+	}
+	signetGenesis, _ := hex.DecodeString(params.SigNet.GenesisHeader)
+	genesis, _ := ParseHeader(signetGenesis)
+	if genesis.Bits != params.SigNet.PowLimitBits {
+		t.Errorf("Signet genesis bits = %08x, want %08x", genesis.Bits, params.SigNet.PowLimitBits)
+	}
+}
+
+func TestCalculateNewBitsClamps(t *testing.T) {
+	prevBits := uint32(0x54d80118)
+	prevTarget := BitsToTarget(prevBits)
+	quarter := TargetToBits(new(big.Int).Div(prevTarget, big.NewInt(4)))
+	fourfold := TargetToBits(new(big.Int).Mul(prevTarget, big.NewInt(4)))
+
+	tests := []struct {
+		name             string
+		timeDifferential int64
+		want             uint32
+	}{
+		// timestamps can go back in time, a time-warp can not make the target harder than a quarter
+		{"negative", -twoWeeks, quarter},
+		{"zero", 0, quarter},
+		{"one day", 24 * 60 * 60, quarter},
+		{"two weeks", twoWeeks, prevBits},
+		{"a year", 365 * 24 * 60 * 60, fourfold},
+		{"max int64", math.MaxInt64, fourfold},
+	}
+	for _, tt := range tests {
+		if got := CalculateNewBits(prevBits, tt.timeDifferential); got != tt.want {
+			t.Errorf("%s: CalculateNewBits = %08x, want %08x", tt.name, got, tt.want)
+		}
+	}
+
+	// the target never gets easier than the pow limit
+	if got := CalculateNewBits(LowestDifficultyBits, twoWeeks*4); got != LowestDifficultyBits {
+		t.Errorf("CalculateNewBits above the pow limit = %08x, want %08x", got, LowestDifficultyBits)
+	}
+	signetLimit := BitsToTarget(params.SigNet.PowLimitBits)
+	if got := CalculateNewBitsWithLimit(params.SigNet.PowLimitBits, twoWeeks*2, signetLimit); got != params.SigNet.PowLimitBits {
+		t.Errorf("CalculateNewBitsWithLimit above the signet limit = %08x", got)
+	}
+}
+
+func TestWork(t *testing.T) {
+	if got := Genesis(false).Work().String(); got != "0000000000000000000000000000000000000000000000000000000100010001" {
+		t.Errorf("Genesis work = %s", got)
+	}
+	// a target that does not fit 256 bits fails proof of work instead of comparing as a big number
+	block := Genesis(false)
+	block.Bits = 0xffff0022
+	if block.CheckPOW(false) {
+		t.Errorf("Block with a target beyond 256 bits should fail proof of work")
+	}
+}
+
+func TestHashDoesNotModifyBlock(t *testing.T) {
+	blockRaw, _ := hex.DecodeString("020000208ec39428b17323fa0ddec8e887b4a7c53b8c0a0a220cfd0000000000000000005b0750fce0a889502d40508d39576821155e9c9e3f5c3157f961db38fd8b25be1e77a759e93c0118a4ffd71d")
+	block, err := Parse(bytes.NewReader(blockRaw))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	hash1, _ := block.Hash()
+	hash2, _ := block.Hash()
+
+	if !bytes.Equal(hash1, hash2) {
+		t.Errorf("Hashing the same block twice gave different results: %x vs %x", hash1, hash2)
+	}
+}
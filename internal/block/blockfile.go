@@ -0,0 +1,100 @@
+package block
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+)
+
+// XorKeyFileName is the file in the blocks directory of Bitcoin Core 28 and later holding the key its block files are
+// XORed with
+const XorKeyFileName = "xor.dat"
+
+// ReadXorKey returns the key in the xor.dat of blocksDir. Block files of versions before 28 are not obfuscated, without
+// an xor.dat the key is nil.
+func ReadXorKey(blocksDir string) ([]byte, error) {
+	key, err := os.ReadFile(filepath.Join(blocksDir, XorKeyFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 8 {
+		return nil, fmt.Errorf("%s holds %d bytes, want 8", XorKeyFileName, len(key))
+	}
+	return key, nil
+}
+
+// xorReader undoes the obfuscation of a block file read from its start
+type xorReader struct {
+	r      io.Reader
+	key    []byte
+	offset int
+}
+
+// Deobfuscate returns a reader of the plain bytes of the block file r reads from its start, which are XORed with key.
+// A nil or all zero key leaves them as they are.
+func Deobfuscate(r io.Reader, key []byte) io.Reader {
+	if len(key) == 0 || bytes.Equal(key, make([]byte, len(key))) {
+		return r
+	}
+	return &xorReader{r: r, key: key}
+}
+
+func (x *xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := range p[:n] {
+		p[i] ^= x.key[(x.offset+i)%len(x.key)]
+	}
+	x.offset += n
+	return n, err
+}
+
+// ReadBlockFile calls fn for every block of a blk*.dat file of Bitcoin Core, in the order they are stored, which is
+// the order they were downloaded in and not by height. Every block is preceded by the network magic and its size.
+// The zeroes Bitcoin Core preallocates at the end of a file end it like the end of the file does. The files of
+// Bitcoin Core 28 and later are obfuscated, read them through Deobfuscate with the key of ReadXorKey.
+func ReadBlockFile(r io.Reader, testnet bool, fn func(fb *FullBlock) error) error {
+	reader := bufio.NewReader(r)
+	magic := params.ForNetwork(testnet).Magic
+	for i := 0; ; i++ {
+		var header [8]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		if bytes.Equal(header[:4], make([]byte, 4)) {
+			return nil
+		}
+		if !bytes.Equal(header[:4], magic) {
+			return fmt.Errorf("record %d: magic %x is not %x", i, header[:4], magic)
+		}
+
+		size := binary.LittleEndian.Uint32(header[4:])
+		if size > MaxBlockWeight {
+			// a block is never larger than its weight
+			return fmt.Errorf("record %d: size %d exceeds the maximum block weight of %d", i, size, MaxBlockWeight)
+		}
+		raw := make([]byte, size)
+		if _, err := io.ReadFull(reader, raw); err != nil {
+			return fmt.Errorf("record %d of %d bytes: %w", i, size, err)
+		}
+		fb, err := ParseFullBlock(bufio.NewReader(bytes.NewReader(raw)), testnet)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		if err := fn(fb); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package block
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+)
+
+func TestReadBlockFile(t *testing.T) {
+	raw := testFullBlock(t)
+	var file []byte
+	for i := 0; i < 2; i++ {
+		file = append(file, params.ForNetwork(false).Magic...)
+		file = binary.LittleEndian.AppendUint32(file, uint32(len(raw)))
+		file = append(file, raw...)
+	}
+	// the preallocated end of the file
+	file = append(file, make([]byte, 64)...)
+
+	var blocks []*FullBlock
+	err := ReadBlockFile(bytes.NewReader(file), false, func(fb *FullBlock) error {
+		blocks = append(blocks, fb)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadBlockFile error: %v", err)
+	}
+	if len(blocks) != 2 || len(blocks[1].Txs) != 1 {
+		t.Fatalf("read %d blocks, want the 2 in the file", len(blocks))
+	}
+
+	if err := ReadBlockFile(bytes.NewReader(file), true, func(*FullBlock) error { return nil }); err == nil {
+		t.Errorf("mainnet file should not read as testnet")
+	}
+	if err := ReadBlockFile(bytes.NewReader(file[:len(raw)]), false, func(*FullBlock) error { return nil }); err == nil {
+		t.Errorf("truncated file should fail")
+	}
+
+	// a size above any block is rejected before it is allocated
+	oversized := binary.LittleEndian.AppendUint32(append([]byte{}, params.ForNetwork(false).Magic...), 0xffffffff)
+	if err := ReadBlockFile(bytes.NewReader(oversized), false, func(*FullBlock) error { return nil }); err == nil {
+		t.Errorf("record larger than a block should fail")
+	}
+}
+
+func TestReadObfuscatedBlockFile(t *testing.T) {
+	raw := testFullBlock(t)
+	file := append(append([]byte{}, params.ForNetwork(false).Magic...), binary.LittleEndian.AppendUint32(nil, uint32(len(raw)))...)
+	file = append(file, raw...)
+
+	dir := t.TempDir()
+	if key, err := ReadXorKey(dir); key != nil || err != nil {
+		t.Fatalf("a directory without xor.dat should have no key, got %x, %v", key, err)
+	}
+	key := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	if err := os.WriteFile(filepath.Join(dir, XorKeyFileName), key, 0644); err != nil {
+		t.Fatal(err)
+	}
+	key, err := ReadXorKey(dir)
+	if err != nil {
+		t.Fatalf("ReadXorKey error: %v", err)
+	}
+	obfuscated := make([]byte, len(file))
+	for i := range file {
+		obfuscated[i] = file[i] ^ key[i%len(key)]
+	}
+
+	count := 0
+	err = ReadBlockFile(Deobfuscate(bytes.NewReader(obfuscated), key), false, func(*FullBlock) error {
+		count++
+		return nil
+	})
+	if err != nil || count != 1 {
+		t.Errorf("read %d blocks of the obfuscated file, %v", count, err)
+	}
+	if err := ReadBlockFile(bytes.NewReader(obfuscated), false, func(*FullBlock) error { return nil }); err == nil {
+		t.Errorf("obfuscated file should not read without its key")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, XorKeyFileName), key[:4], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadXorKey(dir); err == nil {
+		t.Errorf("xor.dat of 4 bytes should fail")
+	}
+}
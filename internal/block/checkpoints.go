@@ -0,0 +1,37 @@
+package block
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+)
+
+const (
+	// Bits of the genesis block, which is also the easiest target the chain allows.
+	LowestDifficultyBits = uint32(0xffff001d)
+)
+
+// Genesis returns the genesis block header of mainnet or testnet
+func Genesis(testnet bool) *Block {
+	headerBytes, _ := hex.DecodeString(params.ForNetwork(testnet).GenesisHeader)
+	genesis, _ := ParseHeader(headerBytes)
+	return genesis
+}
+
+// Checkpoints returns the hard-coded block hashes by height for mainnet or testnet
+func Checkpoints(testnet bool) map[uint32]string {
+	return params.ForNetwork(testnet).Checkpoints
+}
+
+// CheckCheckpoint returns an error if a checkpoint exists at this height and the hash differs from it
+func CheckCheckpoint(height uint32, hash []byte, testnet bool) error {
+	want, ok := Checkpoints(testnet)[height]
+	if !ok {
+		return nil
+	}
+	if got := hex.EncodeToString(hash); got != want {
+		return fmt.Errorf("%w at height %d: got %s, want %s", ErrCheckpointMismatch, height, got, want)
+	}
+	return nil
+}
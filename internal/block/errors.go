@@ -0,0 +1,50 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors of parsing and validating blocks and headers wrap one of these, so callers can tell failures apart
+// with errors.Is
+var (
+	// ErrMalformedBlock is wrapped by the errors of blocks and headers that do not parse
+	ErrMalformedBlock = errors.New("malformed block")
+	// ErrBadProofOfWork is wrapped when the hash of a header is above its target or the bits are not the expected ones
+	ErrBadProofOfWork = errors.New("bad proof of work")
+	// ErrBadCoinbase is wrapped when the coinbase is missing, duplicated, lacks the height or pays too much
+	ErrBadCoinbase = errors.New("bad coinbase")
+	// ErrSigOpLimit is wrapped when the signature operations of a block exceed MaxBlockSigOpsCost
+	ErrSigOpLimit = errors.New("too many signature operations")
+	// ErrOverspend is wrapped when a transaction pays out more than its inputs hold
+	ErrOverspend = errors.New("outputs exceed inputs")
+	// ErrNotConnected is wrapped when a header does not build on the chain it is added to
+	ErrNotConnected = errors.New("header does not connect")
+	// ErrCheckpointMismatch is wrapped when a header at a checkpoint height has another hash
+	ErrCheckpointMismatch = errors.New("checkpoint mismatch")
+	// ErrHeaderNotFound is wrapped when a height is beyond the tip of a HeaderStore
+	ErrHeaderNotFound = errors.New("header not found")
+)
+
+// TxError is the error of transaction Index of a block. errors.As with a *TxError gives the index, and with a
+// *transaction.InputError the input of the transaction when the error is about one.
+type TxError struct {
+	Index int
+	Err   error
+}
+
+func (e *TxError) Error() string {
+	return fmt.Sprintf("transaction %d: %v", e.Index, e.Err)
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// malformedBlock wraps err, the error of reading a block, with ErrMalformedBlock
+func malformedBlock(err error) error {
+	if errors.Is(err, ErrMalformedBlock) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrMalformedBlock, err)
+}
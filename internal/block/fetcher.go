@@ -0,0 +1,167 @@
+package block
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// Esplora returns at most this many blocks per /blocks/:start_height request
+const esploraBlocksPerPage = 10
+
+// HeaderFetcher downloads block headers from an Esplora API
+type HeaderFetcher struct {
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+	// UserAgent, when set, is sent with every request
+	UserAgent string
+	// BaseURL, when set, is the Esplora API the requests go to instead of the public one of the network, such as
+	// one serving signet
+	BaseURL string
+}
+
+func NewHeaderFetcher() *HeaderFetcher {
+	return &HeaderFetcher{}
+}
+
+// NewHeaderFetcherWithClient creates a fetcher that sends its requests with client and userAgent
+func NewHeaderFetcherWithClient(client *http.Client, userAgent string) *HeaderFetcher {
+	return &HeaderFetcher{Client: client, UserAgent: userAgent}
+}
+
+func (hf *HeaderFetcher) GetURL(testnet bool) string {
+	if hf.BaseURL != "" {
+		return hf.BaseURL
+	}
+	if testnet {
+		return "https://blockstream.info/testnet/api"
+	}
+	return "https://blockstream.info/api"
+}
+
+// FetchTipHeight returns the height of the best block known to the API
+func (hf *HeaderFetcher) FetchTipHeight(testnet bool) (uint32, error) {
+	body, err := hf.get(fmt.Sprintf("%s/blocks/tip/height", hf.GetURL(testnet)))
+	if err != nil {
+		return 0, err
+	}
+	height, err := strconv.ParseUint(strings.TrimSpace(string(body)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected tip height %q: %v", body, err)
+	}
+	return uint32(height), nil
+}
+
+// FetchBlockHash returns the hash of the block at height in the best chain known to the API
+func (hf *HeaderFetcher) FetchBlockHash(height uint32, testnet bool) (string, error) {
+	body, err := hf.get(fmt.Sprintf("%s/block-height/%d", hf.GetURL(testnet), height))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// FetchHeaders returns the headers from startHeight upwards in ascending order.
+// At most 10 headers are returned per call and never beyond tipHeight.
+func (hf *HeaderFetcher) FetchHeaders(startHeight, tipHeight uint32, testnet bool) ([]*Block, error) {
+	if startHeight > tipHeight {
+		return nil, nil
+	}
+	endHeight := min(startHeight+esploraBlocksPerPage-1, tipHeight)
+
+	body, err := hf.get(fmt.Sprintf("%s/blocks/%d", hf.GetURL(testnet), endHeight))
+	if err != nil {
+		return nil, err
+	}
+
+	var page []esploraBlock
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("unexpected blocks response: %v", err)
+	}
+
+	headers := make([]*Block, endHeight-startHeight+1)
+	for _, b := range page {
+		if b.Height < startHeight || b.Height > endHeight {
+			continue
+		}
+		header, err := b.header()
+		if err != nil {
+			return nil, err
+		}
+		headers[b.Height-startHeight] = header
+	}
+
+	for i, header := range headers {
+		if header == nil {
+			return nil, fmt.Errorf("missing header at height %d in response", startHeight+uint32(i))
+		}
+	}
+
+	return headers, nil
+}
+
+func (hf *HeaderFetcher) get(url string) ([]byte, error) {
+	return utils.HTTPGet(hf.Client, hf.UserAgent, url)
+}
+
+// esploraBlock is the block summary returned by the Esplora API
+type esploraBlock struct {
+	ID                string `json:"id"`
+	Height            uint32 `json:"height"`
+	Version           uint32 `json:"version"`
+	Timestamp         uint32 `json:"timestamp"`
+	Bits              uint32 `json:"bits"`
+	Nonce             uint32 `json:"nonce"`
+	MerkleRoot        string `json:"merkle_root"`
+	PreviousBlockHash string `json:"previousblockhash"`
+}
+
+// header rebuilds the block header and checks that it hashes to the advertised id
+func (b *esploraBlock) header() (*Block, error) {
+	header := &Block{
+		Version:   b.Version,
+		Timestamp: b.Timestamp,
+		// Bits and Nonce are kept in serialization byte order, the API reports them as numbers
+		Bits:  bits.ReverseBytes32(b.Bits),
+		Nonce: bits.ReverseBytes32(b.Nonce),
+	}
+
+	if err := decodeHash(header.MerkleRoot[:], b.MerkleRoot); err != nil {
+		return nil, fmt.Errorf("bad merkle root for block %s: %v", b.ID, err)
+	}
+
+	if b.PreviousBlockHash != "" {
+		if err := decodeHash(header.PrevBlock[:], b.PreviousBlockHash); err != nil {
+			return nil, fmt.Errorf("bad previous block hash for block %s: %v", b.ID, err)
+		}
+	}
+
+	hash, err := header.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	if id := hex.EncodeToString(hash); id != b.ID {
+		return nil, fmt.Errorf("rebuilt header hashes to %s, API reported %s", id, b.ID)
+	}
+
+	return header, nil
+}
+
+func decodeHash(dst []byte, hexHash string) error {
+	raw, err := hex.DecodeString(hexHash)
+	if err != nil {
+		return err
+	}
+	if len(raw) != len(dst) {
+		return fmt.Errorf("hash has %d bytes, want %d", len(raw), len(dst))
+	}
+	copy(dst, raw)
+	return nil
+}
@@ -0,0 +1,326 @@
+package block
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/merkle"
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// MaxBlockSigOpsCost is the maximum total signature operation cost of the transactions in a block
+const MaxBlockSigOpsCost = 80000
+
+// FullBlock is a block header together with its transactions
+type FullBlock struct {
+	Header  *Block
+	Txs     []*transaction.Tx
+	Testnet bool
+}
+
+// ParseFullBlock reads a serialized block including its transactions
+func ParseFullBlock(reader *bufio.Reader, testnet bool) (*FullBlock, error) {
+	header, err := Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	numTxs, err := utils.ReadVarint(reader)
+	if err != nil {
+		return nil, malformedBlock(err)
+	}
+
+	txs := make([]*transaction.Tx, 0, numTxs)
+	for i := 0; i < int(numTxs); i++ {
+		tx, err := transaction.ParseTx(reader, testnet)
+		if err != nil {
+			return nil, malformedBlock(&TxError{Index: i, Err: err})
+		}
+		txs = append(txs, tx)
+	}
+
+	return &FullBlock{Header: header, Txs: txs, Testnet: testnet}, nil
+}
+
+// ParseFullBlockWithHashes reads a block like ParseFullBlock and also returns its TxHashes.
+// Every transaction is hashed in the background as soon as it is read, instead of after the whole block.
+func ParseFullBlockWithHashes(reader *bufio.Reader, testnet bool) (*FullBlock, [][]byte, error) {
+	header, err := Parse(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	numTxs, err := utils.ReadVarint(reader)
+	if err != nil {
+		return nil, nil, malformedBlock(err)
+	}
+
+	hasher := newTxHasher()
+	txs := make([]*transaction.Tx, 0, numTxs)
+	for i := 0; i < int(numTxs); i++ {
+		tx, err := transaction.ParseTx(reader, testnet)
+		if err != nil {
+			hasher.wait()
+			return nil, nil, malformedBlock(&TxError{Index: i, Err: err})
+		}
+		txs = append(txs, tx)
+		hasher.add(tx)
+	}
+
+	hashes, err := hasher.wait()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &FullBlock{Header: header, Txs: txs, Testnet: testnet}, hashes, nil
+}
+
+// Serialize returns the header followed by the transactions, including their witness data
+func (fb *FullBlock) Serialize() ([]byte, error) {
+	result, err := fb.Header.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	numTxs, err := utils.EncodeVarint(uint64(len(fb.Txs)))
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, numTxs...)
+
+	for _, tx := range fb.Txs {
+		serializedTx, err := tx.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, serializedTx...)
+	}
+
+	return result, nil
+}
+
+// SigOpCost returns the total signature operation cost of the transactions in the block.
+// Outputs created earlier in the block are looked up in the block itself, other ones are fetched.
+func (fb *FullBlock) SigOpCost() (int, error) {
+	created := make(map[string]*transaction.Tx)
+	lookup := func(txIn *transaction.TxIn) (*script.Script, error) {
+		return fb.prevScriptPubkey(created, txIn)
+	}
+
+	cost := 0
+	for _, tx := range fb.Txs {
+		txCost, err := tx.SigOpCostWith(lookup)
+		if err != nil {
+			return 0, err
+		}
+		cost += txCost
+
+		id, err := tx.Id()
+		if err != nil {
+			return 0, err
+		}
+		created[id] = tx
+	}
+
+	return cost, nil
+}
+
+// prevOut returns the output spent by txIn, from the transactions created earlier in the block or fetched
+func (fb *FullBlock) prevOut(created map[string]*transaction.Tx, txIn *transaction.TxIn) (*transaction.TxOut, error) {
+	prevTx, ok := created[txIn.PrevOut.TxidString()]
+	if !ok {
+		return transaction.NewTxFetcher().PrevoutLookup(fb.Testnet)(txIn)
+	}
+	if txIn.PrevOut.Index >= uint32(len(prevTx.TxOuts)) {
+		return nil, fmt.Errorf("%w: previous index %d out of range for transaction outputs", transaction.ErrOutputNotFound, txIn.PrevOut.Index)
+	}
+	return prevTx.TxOuts[txIn.PrevOut.Index], nil
+}
+
+// prevScriptPubkey returns the ScriptPubKey spent by txIn, from the transactions created earlier in the block or fetched
+func (fb *FullBlock) prevScriptPubkey(created map[string]*transaction.Tx, txIn *transaction.TxIn) (*script.Script, error) {
+	prevOut, err := fb.prevOut(created, txIn)
+	if err != nil {
+		return nil, err
+	}
+	return prevOut.ScriptPubkey, nil
+}
+
+// Fees returns the total fee paid by the transactions of the block, the amount the coinbase may claim on top of the subsidy
+func (fb *FullBlock) Fees() (uint64, error) {
+	created := make(map[string]*transaction.Tx)
+	var fees uint64
+	for i, tx := range fb.Txs {
+		if !tx.IsCoinbase() {
+			var inputSum, outputSum uint64
+			for j, txIn := range tx.TxIns {
+				prevOut, err := fb.prevOut(created, txIn)
+				if err != nil {
+					return 0, &TxError{Index: i, Err: &transaction.InputError{Index: j, Err: err}}
+				}
+				inputSum += prevOut.Amount
+			}
+			for _, txOut := range tx.TxOuts {
+				outputSum += txOut.Amount
+			}
+			if outputSum > inputSum {
+				return 0, &TxError{Index: i, Err: fmt.Errorf("%w: spends %d satoshis more than its inputs", ErrOverspend, outputSum-inputSum)}
+			}
+			fees += inputSum - outputSum
+		}
+
+		id, err := tx.Id()
+		if err != nil {
+			return 0, err
+		}
+		created[id] = tx
+	}
+	return fees, nil
+}
+
+// CheckCoinbase checks the coinbase of the block at height: from BIP34 activation it has to start with the height,
+// and it may not pay more than the subsidy and the fees. The fees need the spent outputs, so like the scripts
+// they are not checked for blocks buried under a checkpoint.
+func (fb *FullBlock) CheckCoinbase(height uint32) error {
+	if len(fb.Txs) == 0 || !fb.Txs[0].IsCoinbase() {
+		return fmt.Errorf("%w: first transaction of the block is not a coinbase", ErrBadCoinbase)
+	}
+	coinbase := fb.Txs[0]
+
+	chain := params.ForNetwork(fb.Testnet)
+	if height >= chain.BIP34Height {
+		scriptSig := coinbase.TxIns[0].ScriptSig
+		if scriptSig == nil || len(*scriptSig) == 0 || !(&script.Script{(*scriptSig)[0]}).Equal(heightScript(height)) {
+			return fmt.Errorf("%w: coinbase does not start with the block height %d", ErrBadCoinbase, height)
+		}
+	}
+
+	if chain.AssumeValid(height) {
+		return nil
+	}
+	fees, err := fb.Fees()
+	if err != nil {
+		return err
+	}
+	var value uint64
+	for _, txOut := range coinbase.TxOuts {
+		value += txOut.Amount
+	}
+	if limit := BlockSubsidy(height) + fees; value > limit {
+		return fmt.Errorf("%w: coinbase pays %d satoshis, more than the subsidy and fees of %d", ErrBadCoinbase, value, limit)
+	}
+	return nil
+}
+
+// VerifyScripts verifies the inputs of the transactions in the block at height with the soft fork rules
+// active at that height. Blocks buried under a checkpoint are assumed valid and not verified. Witnesses are not
+// verified: a segwit or taproot spend fails with script.ErrWitnessNotImplemented once its soft fork is active.
+func (fb *FullBlock) VerifyScripts(height uint32) error {
+	return fb.VerifyScriptsCached(height, transaction.VerifyCaches{})
+}
+
+// VerifyScriptsCached is VerifyScripts that consults and fills caches, so inputs and signatures that were
+// verified before, when the transactions were accepted or put in a block template, are not verified again
+func (fb *FullBlock) VerifyScriptsCached(height uint32, caches transaction.VerifyCaches) error {
+	chain := params.ForNetwork(fb.Testnet)
+	if chain.AssumeValid(height) {
+		return nil
+	}
+	flags := script.FlagsAtHeight(chain, height)
+
+	created := make(map[string]*transaction.Tx)
+	for i, tx := range fb.Txs {
+		if !tx.IsCoinbase() {
+			for j, txIn := range tx.TxIns {
+				scriptPubkey, err := fb.prevScriptPubkey(created, txIn)
+				if err != nil {
+					return &TxError{Index: i, Err: &transaction.InputError{Index: j, Err: err}}
+				}
+				// the error already names the input
+				if err := tx.VerifyInputCached(uint32(j), scriptPubkey, flags, caches); err != nil {
+					return &TxError{Index: i, Err: err}
+				}
+			}
+		}
+
+		id, err := tx.Id()
+		if err != nil {
+			return err
+		}
+		created[id] = tx
+	}
+
+	return nil
+}
+
+// TxHashes returns the hashes of the transactions in the little endian order the merkle tree is built from
+func (fb *FullBlock) TxHashes() ([][]byte, error) {
+	hasher := newTxHasher()
+	for _, tx := range fb.Txs {
+		hasher.add(tx)
+	}
+	return hasher.wait()
+}
+
+// MerkleRoot computes the merkle root of the transactions, in the byte order of Block.MerkleRoot
+func (fb *FullBlock) MerkleRoot() ([]byte, error) {
+	hashes, err := fb.TxHashes()
+	if err != nil {
+		return nil, err
+	}
+	root, err := merkle.ParallelMerkleRoot(hashes, 0)
+	if err != nil {
+		return nil, err
+	}
+	return utils.ReverseBytes(root), nil
+}
+
+// TxMerkleBranch returns the merkle branch proving the transaction at index is in the block
+func (fb *FullBlock) TxMerkleBranch(index int) ([][]byte, error) {
+	hashes, err := fb.TxHashes()
+	if err != nil {
+		return nil, err
+	}
+	return merkle.MerkleBranch(hashes, index)
+}
+
+// Validate checks the proof of work, the position of the coinbase and the signature operation limit
+func (fb *FullBlock) Validate() error {
+	if !fb.Header.CheckPOW(fb.Testnet) {
+		return fmt.Errorf("%w: block does not satisfy proof of work", ErrBadProofOfWork)
+	}
+
+	if len(fb.Txs) == 0 || !fb.Txs[0].IsCoinbase() {
+		return fmt.Errorf("%w: first transaction of the block is not a coinbase", ErrBadCoinbase)
+	}
+
+	for i, tx := range fb.Txs[1:] {
+		if tx.IsCoinbase() {
+			return &TxError{Index: i + 1, Err: fmt.Errorf("%w: second coinbase", ErrBadCoinbase)}
+		}
+	}
+
+	cost, err := fb.SigOpCost()
+	if err != nil {
+		return err
+	}
+
+	if cost > MaxBlockSigOpsCost {
+		return fmt.Errorf("%w: block sigop cost %d exceeds the limit of %d", ErrSigOpLimit, cost, MaxBlockSigOpsCost)
+	}
+
+	return nil
+}
+
+// ValidateAtHeight validates the block, checks its coinbase and verifies its scripts with the rules active at height
+func (fb *FullBlock) ValidateAtHeight(height uint32) error {
+	if err := fb.Validate(); err != nil {
+		return err
+	}
+	if err := fb.CheckCoinbase(height); err != nil {
+		return err
+	}
+	return fb.VerifyScripts(height)
+}
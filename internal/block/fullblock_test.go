@@ -0,0 +1,332 @@
+package block
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/merkle"
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// Genesis coinbase with only the bits pushed in the ScriptSig, paying to the genesis pubkey
+const coinbaseTx = "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff0504ffff001dffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+func testFullBlock(t *testing.T) []byte {
+	t.Helper()
+	header, err := Genesis(false).Serialize()
+	if err != nil {
+		t.Fatalf("Failed to serialize genesis header: %v", err)
+	}
+	coinbase, _ := hex.DecodeString(coinbaseTx)
+	return append(append(header, 0x01), coinbase...)
+}
+
+func TestParseFullBlock(t *testing.T) {
+	raw := testFullBlock(t)
+
+	fullBlock, err := ParseFullBlock(bufio.NewReader(bytes.NewReader(raw)), false)
+	if err != nil {
+		t.Fatalf("ParseFullBlock error: %v", err)
+	}
+
+	if len(fullBlock.Txs) != 1 {
+		t.Fatalf("Expected 1 transaction, got %d", len(fullBlock.Txs))
+	}
+
+	if !fullBlock.Txs[0].IsCoinbase() {
+		t.Errorf("Expected the first transaction to be a coinbase")
+	}
+
+	serialized, err := fullBlock.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize error: %v", err)
+	}
+	if !bytes.Equal(serialized, raw) {
+		t.Errorf("Serialized block does not match the parsed one")
+	}
+
+	if err := fullBlock.Validate(); err != nil {
+		t.Errorf("Block should be valid: %v", err)
+	}
+
+	// The pay to pubkey output has a single OP_CHECKSIG
+	cost, err := fullBlock.SigOpCost()
+	if err != nil {
+		t.Fatalf("SigOpCost error: %v", err)
+	}
+	if cost != 4 {
+		t.Errorf("SigOpCost = %d, want 4", cost)
+	}
+}
+
+func TestValidateSigOpLimit(t *testing.T) {
+	fullBlock, err := ParseFullBlock(bufio.NewReader(bytes.NewReader(testFullBlock(t))), false)
+	if err != nil {
+		t.Fatalf("ParseFullBlock error: %v", err)
+	}
+
+	// 20001 OP_CHECKSIGs cost 80004, just over the limit
+	checkSigs := make(script.Script, MaxBlockSigOpsCost/transaction.WitnessScaleFactor+1)
+	for i := range checkSigs {
+		checkSigs[i] = []byte{0xac}
+	}
+	fullBlock.Txs[0].TxOuts[0].ScriptPubkey = &checkSigs
+
+	if err := fullBlock.Validate(); err == nil {
+		t.Errorf("Block exceeding the sigop limit should be rejected")
+	}
+}
+
+func TestValidateCoinbasePosition(t *testing.T) {
+	fullBlock, err := ParseFullBlock(bufio.NewReader(bytes.NewReader(testFullBlock(t))), false)
+	if err != nil {
+		t.Fatalf("ParseFullBlock error: %v", err)
+	}
+
+	fullBlock.Txs = append(fullBlock.Txs, fullBlock.Txs[0])
+	var txErr *TxError
+	if err := fullBlock.Validate(); !errors.Is(err, ErrBadCoinbase) || !errors.As(err, &txErr) || txErr.Index != 1 {
+		t.Errorf("Block with two coinbase transactions should be rejected at the second, got %v", err)
+	}
+
+	fullBlock.Txs = nil
+	if err := fullBlock.Validate(); !errors.Is(err, ErrBadCoinbase) {
+		t.Errorf("Block without transactions should be rejected, got %v", err)
+	}
+}
+
+func TestFullBlockMerkleRoot(t *testing.T) {
+	fullBlock, err := ParseFullBlock(bufio.NewReader(bytes.NewReader(testFullBlock(t))), false)
+	if err != nil {
+		t.Fatalf("ParseFullBlock error: %v", err)
+	}
+
+	// with a single transaction the merkle root is its hash
+	root, err := fullBlock.MerkleRoot()
+	if err != nil {
+		t.Fatalf("MerkleRoot error: %v", err)
+	}
+	hash, _ := fullBlock.Txs[0].Hash()
+	if !bytes.Equal(root, hash) {
+		t.Errorf("MerkleRoot = %x, want %x", root, hash)
+	}
+
+	// a second transaction is paired with the coinbase
+	fullBlock.Txs = append(fullBlock.Txs, fullBlock.Txs[0])
+	root, _ = fullBlock.MerkleRoot()
+	branch, err := fullBlock.TxMerkleBranch(1)
+	if err != nil {
+		t.Fatalf("TxMerkleBranch error: %v", err)
+	}
+	hashes, _ := fullBlock.TxHashes()
+	if len(branch) != 1 || !bytes.Equal(branch[0], hashes[0]) {
+		t.Errorf("Expected the coinbase hash as the only branch hash")
+	}
+	if !merkle.VerifyBranch(hashes[1], branch, 1, utils.ReverseBytes(root)) {
+		t.Errorf("Branch does not verify against the merkle root")
+	}
+}
+
+func TestVerifyScripts(t *testing.T) {
+	fullBlock, err := ParseFullBlock(bufio.NewReader(bytes.NewReader(testFullBlock(t))), false)
+	if err != nil {
+		t.Fatalf("ParseFullBlock error: %v", err)
+	}
+
+	// the coinbase pays to a p2sh redeem script that fails: OP_1 OP_0 OP_EQUAL
+	redeemScript := []byte{0x51, 0x00, 0x87}
+	coinbase := fullBlock.Txs[0]
+	coinbase.TxOuts[0].ScriptPubkey = script.CreateP2SHScript(utils.Hash160(redeemScript))
+	coinbaseHash, _ := coinbase.Hash()
+
+	scriptSig := script.Script{redeemScript}
+	spend := transaction.NewTx(1, []*transaction.TxIn{transaction.NewTxIn(coinbaseHash, 0, &scriptSig, 0xffffffff)}, nil, 0, false)
+	fullBlock.Txs = append(fullBlock.Txs, spend)
+
+	// mined before BIP16 only the hash of the redeem script was checked
+	if err := fullBlock.VerifyScripts(params.MainNet.P2SHHeight - 1); err != nil {
+		t.Errorf("Block before P2SH activation should verify: %v", err)
+	}
+
+	err = fullBlock.VerifyScripts(params.MainNet.LastCheckpoint() + 1)
+	var txErr *TxError
+	var inputErr *transaction.InputError
+	if !errors.Is(err, script.ErrEvalFalse) || !errors.As(err, &txErr) || txErr.Index != 1 || !errors.As(err, &inputErr) || inputErr.Index != 0 {
+		t.Errorf("Block after P2SH activation should fail at input 0 of transaction 1, got %v", err)
+	}
+
+	if _, err := ParseFullBlock(bufio.NewReader(bytes.NewReader(testFullBlock(t)[:100])), false); !errors.Is(err, ErrMalformedBlock) || !errors.Is(err, transaction.ErrMalformedTx) || !errors.As(err, &txErr) {
+		t.Errorf("Truncated block should be malformed in its first transaction, got %v", err)
+	}
+}
+
+func TestCheckCoinbase(t *testing.T) {
+	height := params.MainNet.LastCheckpoint() + 1
+	payout := script.CreateP2WPKHScript(make([]byte, 20))
+
+	// the second transaction spends the subsidy within the block, so its fee of 1000 satoshis is known without fetching
+	newBlock := func(claimedFees uint64) *FullBlock {
+		coinbase, err := templateCoinbase(height, BlockSubsidy(height), payout, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		coinbase.TxOuts = append(coinbase.TxOuts, transaction.NewTxOut(claimedFees, payout))
+		coinbaseHash, _ := coinbase.Hash()
+		spend := transaction.NewTx(1, []*transaction.TxIn{transaction.NewTxIn(coinbaseHash, 0, &script.Script{}, 0xffffffff)}, []*transaction.TxOut{transaction.NewTxOut(BlockSubsidy(height)-1000, payout)}, 0, false)
+		return &FullBlock{Header: &Block{}, Txs: []*transaction.Tx{coinbase, spend}}
+	}
+
+	if fees, err := newBlock(0).Fees(); err != nil || fees != 1000 {
+		t.Errorf("Fees() = %d, %v, want 1000", fees, err)
+	}
+	if err := newBlock(0).CheckCoinbase(height); err != nil {
+		t.Errorf("Coinbase claiming the subsidy should be valid: %v", err)
+	}
+	if err := newBlock(1000).CheckCoinbase(height); err != nil {
+		t.Errorf("Coinbase claiming the subsidy and the fees should be valid: %v", err)
+	}
+	if err := newBlock(1001).CheckCoinbase(height); err == nil {
+		t.Errorf("Coinbase claiming more than the subsidy and the fees should be rejected")
+	}
+
+	// buried blocks only get the height checked
+	if err := newBlock(1001).CheckCoinbase(height - 1); err == nil {
+		t.Errorf("Coinbase committing to another height should be rejected")
+	}
+}
+
+func TestCheckCoinbaseHeight(t *testing.T) {
+	fullBlock, err := ParseFullBlock(bufio.NewReader(bytes.NewReader(testFullBlock(t))), false)
+	if err != nil {
+		t.Fatalf("ParseFullBlock error: %v", err)
+	}
+
+	// the genesis coinbase pushes the bits, it predates BIP34
+	if err := fullBlock.CheckCoinbase(params.MainNet.BIP34Height - 1); err != nil {
+		t.Errorf("Coinbase before BIP34 activation should not need a height: %v", err)
+	}
+	if err := fullBlock.CheckCoinbase(params.MainNet.BIP34Height); err == nil {
+		t.Errorf("Coinbase after BIP34 activation without the height should be rejected")
+	}
+
+	// small heights are pushed with OP_0 to OP_16, others as minimal script numbers
+	tests := []struct {
+		height uint32
+		want   string
+	}{
+		{0, "00"},
+		{1, "51"},
+		{16, "60"},
+		{17, "0111"},
+		{78, "014e"},
+		{127, "017f"},
+		{128, "028000"},
+		{300, "022c01"},
+		{params.MainNet.BIP34Height, "035b7a03"},
+	}
+	for _, tt := range tests {
+		coinbase, err := templateCoinbase(tt.height, 0, &script.Script{}, false)
+		if err != nil {
+			t.Fatalf("templateCoinbase(%d) failed: %v", tt.height, err)
+		}
+		raw, _ := coinbase.TxIns[0].ScriptSig.RawSerialize()
+		if got := hex.EncodeToString(raw); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("Coinbase at height %d starts with %s, want %s", tt.height, got, tt.want)
+		}
+	}
+
+	fullBlock.Txs[0], _ = templateCoinbase(params.MainNet.BIP34Height, 0, &script.Script{}, false)
+	if err := fullBlock.CheckCoinbase(params.MainNet.BIP34Height); err != nil {
+		t.Errorf("Coinbase committing to its height should be valid: %v", err)
+	}
+}
+
+// largeBlock serializes a block with the genesis coinbase followed by n-1 transactions of one input and two outputs
+func largeBlock(tb testing.TB, n int) []byte {
+	tb.Helper()
+	raw, err := Genesis(false).Serialize()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	numTxs, _ := utils.EncodeVarint(uint64(n))
+	raw = append(raw, numTxs...)
+	coinbase, _ := hex.DecodeString(coinbaseTx)
+	raw = append(raw, coinbase...)
+
+	// a scriptSig the size of a signature and a compressed public key
+	scriptSig := script.Script{make([]byte, 72), make([]byte, 33)}
+	for i := 1; i < n; i++ {
+		prevTx := utils.Hash256([]byte{byte(i), byte(i >> 8)})
+		outputs := []*transaction.TxOut{
+			transaction.NewTxOut(uint64(i), script.CreateP2pkhScript(make([]byte, 20))),
+			transaction.NewTxOut(uint64(i), script.CreateP2WPKHScript(make([]byte, 20))),
+		}
+		tx := transaction.NewTx(1, []*transaction.TxIn{transaction.NewTxIn(prevTx, 0, &scriptSig, 0xffffffff)}, outputs, 0, false)
+		serialized, err := tx.Serialize()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		raw = append(raw, serialized...)
+	}
+	return raw
+}
+
+func TestParseFullBlockWithHashes(t *testing.T) {
+	raw := largeBlock(t, 1000)
+	fullBlock, hashes, err := ParseFullBlockWithHashes(bufio.NewReader(bytes.NewReader(raw)), false)
+	if err != nil {
+		t.Fatalf("ParseFullBlockWithHashes error: %v", err)
+	}
+	if len(fullBlock.Txs) != 1000 || len(hashes) != 1000 {
+		t.Fatalf("got %d transactions and %d hashes, want 1000", len(fullBlock.Txs), len(hashes))
+	}
+	for i, tx := range fullBlock.Txs {
+		hash, _ := tx.Hash()
+		if !bytes.Equal(hashes[i], utils.ReverseBytes(hash)) {
+			t.Fatalf("hash %d is %x, want %x", i, hashes[i], utils.ReverseBytes(hash))
+		}
+	}
+
+	txHashes, err := fullBlock.TxHashes()
+	if err != nil {
+		t.Fatalf("TxHashes error: %v", err)
+	}
+	serialRoot, _ := merkle.MerkleRoot(txHashes)
+	root, err := fullBlock.MerkleRoot()
+	if err != nil || !bytes.Equal(root, utils.ReverseBytes(serialRoot)) {
+		t.Errorf("MerkleRoot = %x, %v, want %x", root, err, utils.ReverseBytes(serialRoot))
+	}
+
+	if _, _, err := ParseFullBlockWithHashes(bufio.NewReader(bytes.NewReader(raw[:len(raw)-10])), false); err == nil {
+		t.Error("ParseFullBlockWithHashes of a truncated block should fail")
+	}
+}
+
+// BenchmarkBlockHashes parses a block of 4000 transactions and computes its merkle root
+func BenchmarkBlockHashes(b *testing.B) {
+	raw := largeBlock(b, 4000)
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fullBlock, _ := ParseFullBlock(bufio.NewReader(bytes.NewReader(raw)), false)
+			hashes := make([][]byte, len(fullBlock.Txs))
+			for j, tx := range fullBlock.Txs {
+				hash, _ := tx.Hash()
+				hashes[j] = utils.ReverseBytes(hash)
+			}
+			merkle.MerkleRoot(hashes)
+		}
+	})
+	b.Run("streaming", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, hashes, _ := ParseFullBlockWithHashes(bufio.NewReader(bytes.NewReader(raw)), false)
+			merkle.ParallelMerkleRoot(hashes, 0)
+		}
+	})
+}
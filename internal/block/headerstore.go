@@ -0,0 +1,243 @@
+package block
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+const (
+	headerSize = 80
+	// Retargeting happens every 2016 blocks
+	retargetInterval = 2016
+	// On testnet a block may use the lowest difficulty if it comes 20 minutes after its parent
+	testnetMinDifficultySpacing = 20 * 60
+)
+
+// HeaderStore keeps a validated chain of block headers, starting at genesis.
+// On disk it is a plain concatenation of 80-byte serialized headers,
+// so the position of a header in the file is its height.
+type HeaderStore struct {
+	file    *os.File
+	testnet bool
+	headers []*Block
+	hashes  [][]byte
+}
+
+// OpenHeaderStore opens (or creates) the header file at path and loads all headers in it.
+// An empty store is initialized with the genesis block of the chosen network.
+func OpenHeaderStore(path string, testnet bool) (*HeaderStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &HeaderStore{file: file, testnet: testnet}
+
+	reader := bufio.NewReader(file)
+	raw := make([]byte, headerSize)
+	for {
+		_, err := io.ReadFull(reader, raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("corrupt header store at height %d: %v", len(store.headers), err)
+		}
+		header, err := ParseHeader(raw)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := store.connect(header); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("invalid header in store at height %d: %w", len(store.headers), err)
+		}
+	}
+
+	// The file offset is at the end now, so appends go after the loaded headers
+	if len(store.headers) == 0 {
+		if err := store.Append(Genesis(testnet)); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// Close closes the underlying header file
+func (s *HeaderStore) Close() error {
+	return s.file.Close()
+}
+
+// Height returns the height of the tip of the stored chain
+func (s *HeaderStore) Height() uint32 {
+	return uint32(len(s.headers) - 1)
+}
+
+// Tip returns the header at the highest height
+func (s *HeaderStore) Tip() *Block {
+	return s.headers[len(s.headers)-1]
+}
+
+// TipHash returns the hash of the header at the highest height
+func (s *HeaderStore) TipHash() []byte {
+	return s.hashes[len(s.hashes)-1]
+}
+
+// HeaderAt returns the header at the given height
+func (s *HeaderStore) HeaderAt(height uint32) (*Block, error) {
+	if int(height) >= len(s.headers) {
+		return nil, fmt.Errorf("%w: no header at height %d, tip is at %d", ErrHeaderNotFound, height, s.Height())
+	}
+	return s.headers[height], nil
+}
+
+// HashAt returns the hash of the header at the given height
+func (s *HeaderStore) HashAt(height uint32) ([]byte, error) {
+	if int(height) >= len(s.hashes) {
+		return nil, fmt.Errorf("%w: no header at height %d, tip is at %d", ErrHeaderNotFound, height, s.Height())
+	}
+	return s.hashes[height], nil
+}
+
+// ChainWork returns the total work of the stored chain, the sum of the work of every header
+func (s *HeaderStore) ChainWork() utils.Uint256 {
+	var total utils.Uint256
+	for _, header := range s.headers {
+		total, _ = total.Add(header.Work())
+	}
+	return total
+}
+
+// VerifyBlock checks that the block with blockHash, in display byte order, is the stored header at height.
+// Its signature matches transaction.BlockVerifier, so a synced store can cross-check fetched transactions.
+func (s *HeaderStore) VerifyBlock(blockHash string, height uint32, testnet bool) error {
+	network := params.ForNetwork(testnet).Name
+	if testnet != s.testnet {
+		return fmt.Errorf("header store is for %s, not %s", params.ForNetwork(s.testnet).Name, network)
+	}
+	hash, err := s.HashAt(height)
+	if err != nil {
+		return fmt.Errorf("cannot verify block %s on %s: %w", blockHash, network, err)
+	}
+	if hex.EncodeToString(hash) != blockHash {
+		return fmt.Errorf("block %s at height %d is not in the %s chain, which has %x there", blockHash, height, network, hash)
+	}
+	return nil
+}
+
+// VerifyMerkleProof checks that proof shows the transaction with txID is in the stored header at the height of the
+// proof, which confirms the transaction without trusting whoever provided the proof
+func (s *HeaderStore) VerifyMerkleProof(txID string, proof *transaction.MerkleProof) error {
+	header, err := s.HeaderAt(proof.BlockHeight)
+	if err != nil {
+		return fmt.Errorf("cannot verify transaction %s: %w", txID, err)
+	}
+	return proof.Verify(txID, header.MerkleRoot[:])
+}
+
+// Append validates the headers one by one on top of the current tip and writes them to disk.
+// Headers before the first invalid one are kept.
+func (s *HeaderStore) Append(headers ...*Block) error {
+	var buf bytes.Buffer
+	var connectErr error
+
+	for _, header := range headers {
+		if connectErr = s.connect(header); connectErr != nil {
+			break
+		}
+		raw, err := header.Serialize()
+		if err != nil {
+			return err
+		}
+		buf.Write(raw)
+	}
+
+	if _, err := s.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	return connectErr
+}
+
+// connect validates a header against the current tip and adds it to the in-memory chain
+func (s *HeaderStore) connect(header *Block) error {
+	hash, err := header.Hash()
+	if err != nil {
+		return err
+	}
+	height := uint32(len(s.headers))
+
+	if height == 0 {
+		genesisHash, _ := Genesis(s.testnet).Hash()
+		if !bytes.Equal(hash, genesisHash) {
+			return fmt.Errorf("%w: first header %x is not the genesis block", ErrNotConnected, hash)
+		}
+	} else {
+		if !bytes.Equal(header.PrevBlock[:], s.TipHash()) {
+			return fmt.Errorf("%w: header %x at height %d does not build on tip %x", ErrNotConnected, hash, height, s.TipHash())
+		}
+
+		wantBits, err := s.nextBits(header)
+		if err != nil {
+			return err
+		}
+		if header.Bits != wantBits {
+			return fmt.Errorf("%w: header %x at height %d has bits %08x, want %08x", ErrBadProofOfWork, hash, height, header.Bits, wantBits)
+		}
+
+		if !header.CheckPOW(s.testnet) {
+			return fmt.Errorf("%w: header %x at height %d does not satisfy proof of work", ErrBadProofOfWork, hash, height)
+		}
+	}
+
+	if err := CheckCheckpoint(height, hash, s.testnet); err != nil {
+		return err
+	}
+
+	s.headers = append(s.headers, header)
+	s.hashes = append(s.hashes, hash)
+	return nil
+}
+
+// nextBits returns the bits the given header must have to extend the current tip
+func (s *HeaderStore) nextBits(header *Block) (uint32, error) {
+	height := uint32(len(s.headers))
+	tip := s.Tip()
+
+	if height%retargetInterval == 0 {
+		first, err := s.HeaderAt(height - retargetInterval)
+		if err != nil {
+			return 0, err
+		}
+		timeDifferential := int64(tip.Timestamp) - int64(first.Timestamp)
+		// The target can never get easier than the target of the genesis block
+		return CalculateNewBitsWithLimit(tip.Bits, timeDifferential, PowLimit(s.testnet)), nil
+	}
+
+	if !s.testnet {
+		return tip.Bits, nil
+	}
+
+	// Testnet allows a lowest difficulty block when no block was found for 20 minutes.
+	// Otherwise the bits of the last block that did not use this exception apply.
+	if int64(header.Timestamp) > int64(tip.Timestamp)+testnetMinDifficultySpacing {
+		return LowestDifficultyBits, nil
+	}
+	h := height - 1
+	for h%retargetInterval != 0 && s.headers[h].Bits == LowestDifficultyBits {
+		h--
+	}
+	return s.headers[h].Bits, nil
+}
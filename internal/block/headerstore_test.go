@@ -0,0 +1,255 @@
+package block
+
+import (
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+const (
+	mainnetBlock1Header = "010000006fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000982051fd1e4ba744bbbe680e1fee14677ba1a3c3540bf7b1cdb606e857233e0e61bc6649ffff001d01e36299"
+	mainnetBlock2Header = "010000004860eb18bf1b1620e37e9490fc8a427514416fd75159ab86688e9a8300000000d5fdcc541e25de1c7a5addedf24858b8bb665c9f36ef744ee42c316022c90f9bb0bc6649ffff001d08d2bd61"
+)
+
+func mustParseHeader(t *testing.T, headerHex string) *Block {
+	t.Helper()
+	raw, err := hex.DecodeString(headerHex)
+	if err != nil {
+		t.Fatalf("Failed to decode header hex: %v", err)
+	}
+	header, err := ParseHeader(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse header: %v", err)
+	}
+	return header
+}
+
+func TestGenesis(t *testing.T) {
+	tests := []struct {
+		testnet bool
+		want    string
+	}{
+		{false, "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"},
+		{true, "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943"},
+	}
+
+	for _, tt := range tests {
+		hash, err := Genesis(tt.testnet).Hash()
+		if err != nil {
+			t.Fatalf("Hash error: %v", err)
+		}
+		if got := hex.EncodeToString(hash); got != tt.want {
+			t.Errorf("Genesis(%v) hash = %s, want %s", tt.testnet, got, tt.want)
+		}
+	}
+}
+
+func TestHeaderStoreAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "headers.dat")
+
+	store, err := OpenHeaderStore(path, false)
+	if err != nil {
+		t.Fatalf("OpenHeaderStore error: %v", err)
+	}
+
+	if store.Height() != 0 {
+		t.Fatalf("New store should start at genesis, got height %d", store.Height())
+	}
+
+	block1 := mustParseHeader(t, mainnetBlock1Header)
+	block2 := mustParseHeader(t, mainnetBlock2Header)
+
+	// Block 2 does not build on genesis
+	if err := store.Append(block2); err == nil {
+		t.Errorf("Appending a header that does not build on the tip should fail")
+	}
+
+	if err := store.Append(block1, block2); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+
+	if store.Height() != 2 {
+		t.Errorf("Height mismatch. Got: %d, Want: 2", store.Height())
+	}
+
+	if got := hex.EncodeToString(store.TipHash()); got != "000000006a625f06636b8bb6ac7b960a8d03705d1ace08b1a19da3fdcc99ddbd" {
+		t.Errorf("Tip hash mismatch. Got: %s", got)
+	}
+	store.Close()
+
+	// Reopening the store loads the headers from disk
+	store, err = OpenHeaderStore(path, false)
+	if err != nil {
+		t.Fatalf("OpenHeaderStore error: %v", err)
+	}
+	defer store.Close()
+
+	if store.Height() != 2 {
+		t.Errorf("Height after reopen mismatch. Got: %d, Want: 2", store.Height())
+	}
+
+	hash1, err := store.HashAt(1)
+	if err != nil {
+		t.Fatalf("HashAt error: %v", err)
+	}
+	if got := hex.EncodeToString(hash1); got != "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048" {
+		t.Errorf("Hash at height 1 mismatch. Got: %s", got)
+	}
+
+	// every block of the first period has the work of the genesis block
+	if got := store.ChainWork().Big().Int64(); got != 3*0x100010001 {
+		t.Errorf("ChainWork = %x, want %x", got, 3*0x100010001)
+	}
+}
+
+func TestHeaderStoreVerifyBlock(t *testing.T) {
+	store, err := OpenHeaderStore(filepath.Join(t.TempDir(), "headers.dat"), false)
+	if err != nil {
+		t.Fatalf("OpenHeaderStore error: %v", err)
+	}
+	defer store.Close()
+	if err := store.Append(mustParseHeader(t, mainnetBlock1Header), mustParseHeader(t, mainnetBlock2Header)); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+
+	block1 := "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048"
+	if err := store.VerifyBlock(block1, 1, false); err != nil {
+		t.Errorf("VerifyBlock of block 1 failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		hash    string
+		height  uint32
+		testnet bool
+		want    string
+	}{
+		{"other height", block1, 2, false, "not in the mainnet chain"},
+		{"beyond the tip", block1, 3, false, "no header at height 3"},
+		{"other network", block1, 1, true, "header store is for mainnet, not testnet3"},
+	}
+	for _, tt := range tests {
+		err := store.VerifyBlock(tt.hash, tt.height, tt.testnet)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+	if err := store.VerifyBlock(block1, 3, false); !errors.Is(err, ErrHeaderNotFound) {
+		t.Errorf("Block beyond the tip should not be found, got %v", err)
+	}
+}
+
+func TestHeaderStoreVerifyMerkleProof(t *testing.T) {
+	store, err := OpenHeaderStore(filepath.Join(t.TempDir(), "headers.dat"), false)
+	if err != nil {
+		t.Fatalf("OpenHeaderStore error: %v", err)
+	}
+	defer store.Close()
+	if err := store.Append(mustParseHeader(t, mainnetBlock1Header)); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+
+	// block 1 only has its coinbase, which is the merkle root
+	coinbase := "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098"
+	if err := store.VerifyMerkleProof(coinbase, &transaction.MerkleProof{BlockHeight: 1}); err != nil {
+		t.Errorf("VerifyMerkleProof of the coinbase of block 1 failed: %v", err)
+	}
+	if err := store.VerifyMerkleProof(strings.Repeat("00", 32), &transaction.MerkleProof{BlockHeight: 1}); err == nil {
+		t.Errorf("VerifyMerkleProof of another transaction should fail")
+	}
+	if err := store.VerifyMerkleProof(coinbase, &transaction.MerkleProof{BlockHeight: 2}); err == nil {
+		t.Errorf("VerifyMerkleProof beyond the tip should fail")
+	}
+}
+
+func TestHeaderStoreRejectsInvalidHeaders(t *testing.T) {
+	store, err := OpenHeaderStore(filepath.Join(t.TempDir(), "headers.dat"), false)
+	if err != nil {
+		t.Fatalf("OpenHeaderStore error: %v", err)
+	}
+	defer store.Close()
+
+	// Wrong bits for a non-retarget height
+	badBits := mustParseHeader(t, mainnetBlock1Header)
+	badBits.Bits = 0xffff001c
+	if err := store.Append(badBits); err == nil {
+		t.Errorf("Header with unexpected bits should be rejected")
+	}
+
+	// Right bits, but the nonce no longer produces a valid proof of work
+	badNonce := mustParseHeader(t, mainnetBlock1Header)
+	badNonce.Nonce++
+	if err := store.Append(badNonce); err == nil {
+		t.Errorf("Header without valid proof of work should be rejected")
+	}
+
+	if store.Height() != 0 {
+		t.Errorf("Rejected headers should not be stored, height is %d", store.Height())
+	}
+}
+
+func TestCheckCheckpoint(t *testing.T) {
+	hash, _ := hex.DecodeString("0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d")
+
+	if err := CheckCheckpoint(11111, hash, false); err != nil {
+		t.Errorf("Checkpoint should match: %v", err)
+	}
+	if err := CheckCheckpoint(11111, hash, true); err != nil {
+		t.Errorf("Height without a testnet checkpoint should pass: %v", err)
+	}
+	if err := CheckCheckpoint(33333, hash, false); err == nil {
+		t.Errorf("Checkpoint mismatch should be reported")
+	}
+}
+
+func TestNextBitsTestnetMinDifficulty(t *testing.T) {
+	hardBits := uint32(0xffff001c)
+	store := &HeaderStore{testnet: true}
+	for i, bits := range []uint32{hardBits, hardBits, LowestDifficultyBits} {
+		store.headers = append(store.headers, &Block{Timestamp: uint32(1000 * i), Bits: bits})
+	}
+
+	// A block more than 20 minutes after its parent may use the lowest difficulty
+	late := &Block{Timestamp: store.Tip().Timestamp + testnetMinDifficultySpacing + 1}
+	if bits, _ := store.nextBits(late); bits != LowestDifficultyBits {
+		t.Errorf("Late block bits = %08x, want %08x", bits, LowestDifficultyBits)
+	}
+
+	// Otherwise the last regular difficulty applies again
+	onTime := &Block{Timestamp: store.Tip().Timestamp + 600}
+	if bits, _ := store.nextBits(onTime); bits != hardBits {
+		t.Errorf("On-time block bits = %08x, want %08x", bits, hardBits)
+	}
+}
+
+func TestEsploraBlockHeader(t *testing.T) {
+	b := esploraBlock{
+		ID:                "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048",
+		Height:            1,
+		Version:           1,
+		Timestamp:         1231469665,
+		Bits:              486604799,
+		Nonce:             2573394689,
+		MerkleRoot:        "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098",
+		PreviousBlockHash: "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+	}
+
+	header, err := b.header()
+	if err != nil {
+		t.Fatalf("header() error: %v", err)
+	}
+
+	raw, _ := header.Serialize()
+	if got := hex.EncodeToString(raw); got != mainnetBlock1Header {
+		t.Errorf("Rebuilt header mismatch.\nGot:  %s\nWant: %s", got, mainnetBlock1Header)
+	}
+
+	b.Nonce++
+	if _, err := b.header(); err == nil {
+		t.Errorf("Header that does not hash to the reported id should be rejected")
+	}
+}
@@ -0,0 +1,33 @@
+package block
+
+// MaxLocatorHashes is the most hashes a peer accepts in the locator of a getheaders or getblocks message
+const MaxLocatorHashes = 101
+
+// LocatorHeights returns the heights a block locator for a chain with its tip at height names, the way Bitcoin Core
+// builds them: the tip and the 10 blocks below it, then blocks twice as far apart each step, and genesis last. A peer
+// answers with the headers after the highest of them it has, so a locator finds a fork in a logarithmic number of hashes.
+func LocatorHeights(height uint32) []uint32 {
+	var heights []uint32
+	step := uint32(1)
+	for h := height; ; {
+		heights = append(heights, h)
+		if h == 0 {
+			return heights
+		}
+		h -= min(step, h)
+		if len(heights) > 10 {
+			step *= 2
+		}
+	}
+}
+
+// Locator returns the block locator of the stored chain, the hashes at LocatorHeights from the tip, in display byte
+// order. It is what a getheaders message sends to resume header sync from the tip.
+func (s *HeaderStore) Locator() [][]byte {
+	heights := LocatorHeights(s.Height())
+	locator := make([][]byte, len(heights))
+	for i, height := range heights {
+		locator[i] = s.hashes[height]
+	}
+	return locator
+}
@@ -0,0 +1,55 @@
+package block
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLocatorHeights(t *testing.T) {
+	tests := []struct {
+		height uint32
+		want   []uint32
+	}{
+		{0, []uint32{0}},
+		{3, []uint32{3, 2, 1, 0}},
+		{10, []uint32{10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0}},
+		{11, []uint32{11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0}},
+		{20, []uint32{20, 19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 7, 3, 0}},
+		{100, []uint32{100, 99, 98, 97, 96, 95, 94, 93, 92, 91, 90, 89, 87, 83, 75, 59, 27, 0}},
+	}
+
+	for _, tt := range tests {
+		if got := LocatorHeights(tt.height); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LocatorHeights(%d) = %v, want %v", tt.height, got, tt.want)
+		}
+	}
+
+	if got := LocatorHeights(1<<32 - 1); len(got) > MaxLocatorHashes || got[len(got)-1] != 0 {
+		t.Errorf("LocatorHeights of the highest height = %v, want at most %d hashes ending at genesis", got, MaxLocatorHashes)
+	}
+}
+
+func TestHeaderStoreLocator(t *testing.T) {
+	store, err := OpenHeaderStore(filepath.Join(t.TempDir(), "headers.dat"), false)
+	if err != nil {
+		t.Fatalf("OpenHeaderStore error: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Append(mustParseHeader(t, mainnetBlock1Header), mustParseHeader(t, mainnetBlock2Header)); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+
+	locator := store.Locator()
+	if len(locator) != 3 {
+		t.Fatalf("Expected a locator of 3 hashes, got %d", len(locator))
+	}
+	for i, height := range []uint32{2, 1, 0} {
+		hash, _ := store.HashAt(height)
+		if !bytes.Equal(locator[i], hash) {
+			t.Errorf("Locator hash %d = %x, want the hash at height %d, %x", i, locator[i], height, hash)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package block
+
+// SubsidyHalvingInterval is the number of blocks after which the subsidy halves
+const SubsidyHalvingInterval = 210000
+
+// initialSubsidy is the subsidy of the first era in satoshis, 50 bitcoin
+const initialSubsidy = uint64(50 * 100000000)
+
+// After this many halvings the subsidy is shifted out completely
+const maxHalvings = 64
+
+// BlockSubsidy returns the newly created satoshis a block at height may claim
+func BlockSubsidy(height uint32) uint64 {
+	halvings := height / SubsidyHalvingInterval
+	if halvings >= maxHalvings {
+		return 0
+	}
+	return initialSubsidy >> halvings
+}
+
+// TotalSupplyAt returns the satoshis created by the subsidies of the blocks up to and including height.
+// The unspendable genesis output is counted, so the result is the schedule rather than the spendable supply.
+func TotalSupplyAt(height uint32) uint64 {
+	var supply uint64
+	for era := uint32(0); era < maxHalvings; era++ {
+		start := era * SubsidyHalvingInterval
+		if start > height {
+			break
+		}
+		blocks := min(height-start, SubsidyHalvingInterval-1) + 1
+		supply += uint64(blocks) * (initialSubsidy >> era)
+	}
+	return supply
+}
+
+// NextHalvingHeight returns the height of the first block after height with a halved subsidy
+func NextHalvingHeight(height uint32) uint32 {
+	return (height/SubsidyHalvingInterval + 1) * SubsidyHalvingInterval
+}
+
+// BlocksUntilHalving returns how many blocks after height are mined before the subsidy halves
+func BlocksUntilHalving(height uint32) uint32 {
+	return NextHalvingHeight(height) - height
+}
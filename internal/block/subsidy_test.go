@@ -0,0 +1,63 @@
+package block
+
+import "testing"
+
+func TestBlockSubsidy(t *testing.T) {
+	tests := []struct {
+		height uint32
+		want   uint64
+	}{
+		{0, 5000000000},
+		{209999, 5000000000},
+		{210000, 2500000000},
+		{840000, 312500000},
+		{6929999, 1},
+		{6930000, 0},
+		{13440000, 0},
+	}
+	for _, tt := range tests {
+		if got := BlockSubsidy(tt.height); got != tt.want {
+			t.Errorf("BlockSubsidy(%d) = %d, want %d", tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestTotalSupplyAt(t *testing.T) {
+	tests := []struct {
+		height uint32
+		want   uint64
+	}{
+		{0, 5000000000},
+		{1, 10000000000},
+		{209999, 1050000000000000},
+		{210000, 1050002500000000},
+		{839999, 1968750000000000},
+		// the last satoshi of the schedule, the well known total of 20999999.9769 bitcoin
+		{6929999, 2099999997690000},
+		{0xffffffff, 2099999997690000},
+	}
+	for _, tt := range tests {
+		if got := TotalSupplyAt(tt.height); got != tt.want {
+			t.Errorf("TotalSupplyAt(%d) = %d, want %d", tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestHalvingCountdown(t *testing.T) {
+	tests := []struct {
+		height, next, blocks uint32
+	}{
+		{0, 210000, 210000},
+		{209999, 210000, 1},
+		{210000, 420000, 210000},
+		{850000, 1050000, 200000},
+	}
+	for _, tt := range tests {
+		if got := NextHalvingHeight(tt.height); got != tt.next {
+			t.Errorf("NextHalvingHeight(%d) = %d, want %d", tt.height, got, tt.next)
+		}
+		if got := BlocksUntilHalving(tt.height); got != tt.blocks {
+			t.Errorf("BlocksUntilHalving(%d) = %d, want %d", tt.height, got, tt.blocks)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package block
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/merkle"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// MaxBlockWeight is the maximum weight of a block (BIP141)
+const MaxBlockWeight = 4000000
+
+// Weight and signature operation cost kept free for the coinbase, as Bitcoin Core does
+const (
+	coinbaseReservedWeight    = 4000
+	coinbaseReservedSigOpCost = 400
+)
+
+// Blocks that signal with BIP9 and no deployment set this version
+const templateVersion = 0x20000000
+
+// witnessCommitmentHeader starts the coinbase output committing to the witnesses of the block (BIP141)
+var witnessCommitmentHeader = []byte{0xaa, 0x21, 0xa9, 0xed}
+
+// MempoolEntry is a transaction that may go into a block, with what the mempool knows about it
+type MempoolEntry struct {
+	Tx  *transaction.Tx
+	Fee uint64
+	// SigOpCost is the signature operation cost, which needs the spent outputs. When zero the legacy count is used.
+	SigOpCost int
+}
+
+// BlockTemplate is a block ready for mining: only the nonce, and the timestamp within limits, are left to vary
+type BlockTemplate struct {
+	Header   *Block
+	Coinbase *transaction.Tx
+	// Txs are the selected transactions in block order, without the coinbase
+	Txs       []*transaction.Tx
+	Height    uint32
+	Fees      uint64
+	Weight    int
+	SigOpCost int
+}
+
+// FullBlock returns the block of the template with the coinbase first
+func (t *BlockTemplate) FullBlock() *FullBlock {
+	txs := append([]*transaction.Tx{t.Coinbase}, t.Txs...)
+	return &FullBlock{Header: t.Header, Txs: txs, Testnet: t.Coinbase.Testnet}
+}
+
+// AssembleBlockTemplate builds a block on prevHeader at height from the mempool. Transactions are selected by the fee
+// rate of their package, the transaction together with its ancestors that are not in the block yet, so a child paying
+// for its parent (CPFP) is counted, until the weight and signature operation limits are reached. The coinbase pays
+// the subsidy and the fees to payout and commits to the height (BIP34) and, when needed, to the witnesses (BIP141).
+// The difficulty is copied from prevHeader, at a retarget height the caller has to set Header.Bits.
+func AssembleBlockTemplate(prevHeader *Block, mempool []*MempoolEntry, height uint32, payout *script.Script, testnet bool) (*BlockTemplate, error) {
+	selected, err := selectPackages(mempool)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &BlockTemplate{Height: height, Weight: coinbaseReservedWeight, SigOpCost: coinbaseReservedSigOpCost}
+	hasWitness := false
+	for _, entry := range selected {
+		t.Txs = append(t.Txs, entry.Tx)
+		t.Fees += entry.Fee
+		t.Weight += entry.weight
+		t.SigOpCost += entry.sigOpCost
+		hasWitness = hasWitness || entry.Tx.HasWitness()
+	}
+
+	coinbase, err := templateCoinbase(height, BlockSubsidy(height)+t.Fees, payout, testnet)
+	if err != nil {
+		return nil, err
+	}
+	if hasWitness {
+		if err := addWitnessCommitment(coinbase, t.Txs); err != nil {
+			return nil, err
+		}
+	}
+	t.Coinbase = coinbase
+
+	prevHash, err := prevHeader.Hash()
+	if err != nil {
+		return nil, err
+	}
+	t.Header = &Block{Version: templateVersion, Bits: prevHeader.Bits, Timestamp: max(uint32(time.Now().Unix()), prevHeader.Timestamp+1)}
+	copy(t.Header.PrevBlock[:], prevHash)
+	root, err := t.FullBlock().MerkleRoot()
+	if err != nil {
+		return nil, err
+	}
+	copy(t.Header.MerkleRoot[:], root)
+	return t, nil
+}
+
+// poolEntry is a MempoolEntry with the values selection works with
+type poolEntry struct {
+	*MempoolEntry
+	weight    int
+	sigOpCost int
+	parents   []*poolEntry
+	included  bool
+	skipped   bool
+}
+
+// selectPackages picks the entries for a block, parents always before their children
+func selectPackages(mempool []*MempoolEntry) ([]*poolEntry, error) {
+	entries := make(map[string]*poolEntry, len(mempool))
+	pool := make([]*poolEntry, 0, len(mempool))
+	for _, entry := range mempool {
+		id, err := entry.Tx.Id()
+		if err != nil {
+			return nil, err
+		}
+		if entries[id] != nil {
+			return nil, fmt.Errorf("transaction %s is in the mempool twice", id)
+		}
+		weight, err := entry.Tx.Weight()
+		if err != nil {
+			return nil, err
+		}
+		sigOpCost := entry.SigOpCost
+		if sigOpCost == 0 {
+			sigOpCost = entry.Tx.LegacySigOpCount() * transaction.WitnessScaleFactor
+		}
+		e := &poolEntry{MempoolEntry: entry, weight: weight, sigOpCost: sigOpCost}
+		entries[id] = e
+		pool = append(pool, e)
+	}
+	for _, e := range pool {
+		for _, txIn := range e.Tx.TxIns {
+			if parent := entries[txIn.PrevOut.TxidString()]; parent != nil && !slices.Contains(e.parents, parent) {
+				e.parents = append(e.parents, parent)
+			}
+		}
+	}
+
+	var selected []*poolEntry
+	weight, sigOpCost := coinbaseReservedWeight, coinbaseReservedSigOpCost
+	for {
+		var best []*poolEntry
+		var bestFee uint64
+		var bestWeight int
+		for _, e := range pool {
+			if e.included || e.skipped {
+				continue
+			}
+			pkg := pendingAncestors(e)
+			var fee uint64
+			var pkgWeight int
+			for _, member := range pkg {
+				fee += member.Fee
+				pkgWeight += member.weight
+			}
+			// compare fee rates by cross multiplying, a package earlier in the mempool wins a tie
+			if best == nil || fee*uint64(bestWeight) > bestFee*uint64(pkgWeight) {
+				best, bestFee, bestWeight = pkg, fee, pkgWeight
+			}
+		}
+		if best == nil {
+			return selected, nil
+		}
+
+		var pkgSigOpCost int
+		skip := false
+		for _, member := range best {
+			pkgSigOpCost += member.sigOpCost
+			skip = skip || member.skipped
+		}
+		if skip || weight+bestWeight > MaxBlockWeight || sigOpCost+pkgSigOpCost > MaxBlockSigOpsCost {
+			// the package does not fit, its last member is the candidate it was built for
+			best[len(best)-1].skipped = true
+			continue
+		}
+		for _, member := range best {
+			member.included = true
+			selected = append(selected, member)
+		}
+		weight += bestWeight
+		sigOpCost += pkgSigOpCost
+	}
+}
+
+// pendingAncestors returns e and its ancestors that are not in the block yet, every parent before its children and e last
+func pendingAncestors(e *poolEntry) []*poolEntry {
+	visited := make(map[*poolEntry]bool)
+	var pkg []*poolEntry
+	var visit func(entry *poolEntry)
+	visit = func(entry *poolEntry) {
+		if entry.included || visited[entry] {
+			return
+		}
+		visited[entry] = true
+		for _, parent := range entry.parents {
+			visit(parent)
+		}
+		pkg = append(pkg, entry)
+	}
+	visit(e)
+	return pkg
+}
+
+// templateCoinbase creates the coinbase paying value to payout, with the BIP34 height and room for an extra nonce
+func templateCoinbase(height uint32, value uint64, payout *script.Script, testnet bool) (*transaction.Tx, error) {
+	scriptSig, err := script.NewBuilder().PushInt(int64(height)).PushData(make([]byte, 8)).Script()
+	if err != nil {
+		return nil, err
+	}
+	coinbaseIn := transaction.NewTxIn(make([]byte, 32), 0xffffffff, scriptSig, 0xffffffff)
+	return transaction.NewTx(1, []*transaction.TxIn{coinbaseIn}, []*transaction.TxOut{transaction.NewTxOut(value, payout)}, 0, testnet), nil
+}
+
+// heightScript returns the push of height the coinbase ScriptSig starts with under BIP34: OP_0 to OP_16
+// for the smallest heights, the minimal script number encoding otherwise
+func heightScript(height uint32) *script.Script {
+	return script.NewBuilder().PushInt(int64(height)).MustScript()
+}
+
+// addWitnessCommitment adds the BIP141 commitment to the witnesses of txs to coinbase.
+// The coinbase itself counts with a wtxid of zero, its witness is the 32 zero byte reserved value.
+func addWitnessCommitment(coinbase *transaction.Tx, txs []*transaction.Tx) error {
+	hashes := [][]byte{make([]byte, 32)}
+	for _, tx := range txs {
+		serialized, err := tx.Serialize()
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, utils.Hash256(serialized))
+	}
+	root, err := merkle.MerkleRoot(hashes)
+	if err != nil {
+		return err
+	}
+	reserved := make([]byte, 32)
+	commitment := append(bytes.Clone(witnessCommitmentHeader), utils.Hash256(append(root, reserved...))...)
+
+	coinbase.TxIns[0].Witness = [][]byte{reserved}
+	coinbase.TxOuts = append(coinbase.TxOuts, transaction.NewTxOut(0, &script.Script{{0x6a}, commitment}))
+	return nil
+}
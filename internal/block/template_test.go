@@ -0,0 +1,121 @@
+package block
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+func templateTx(t *testing.T, prevTx []byte, amount uint64) *transaction.Tx {
+	t.Helper()
+	txIn := transaction.NewTxIn(prevTx, 0, &script.Script{}, 0xffffffff)
+	return transaction.NewTx(1, []*transaction.TxIn{txIn}, []*transaction.TxOut{transaction.NewTxOut(amount, script.CreateP2WPKHScript(make([]byte, 20)))}, 0, true)
+}
+
+func TestAssembleBlockTemplate(t *testing.T) {
+	parent := templateTx(t, bytes.Repeat([]byte{0x01}, 32), 1000)
+	parentHash, _ := parent.Hash()
+	child := templateTx(t, parentHash, 900)
+	independent := templateTx(t, bytes.Repeat([]byte{0x02}, 32), 1000)
+	// an output script this long does not fit in a block
+	huge := templateTx(t, bytes.Repeat([]byte{0x03}, 32), 1000)
+	var long script.Script
+	for i := 0; i < MaxBlockWeight/4/520; i++ {
+		long = append(long, make([]byte, 520))
+	}
+	huge.TxOuts[0].ScriptPubkey = &long
+
+	mempool := []*MempoolEntry{
+		{Tx: parent, Fee: 10},
+		{Tx: huge, Fee: 1000000},
+		{Tx: independent, Fee: 500},
+		// the child pays enough for both
+		{Tx: child, Fee: 5000},
+	}
+	prevHeader := &Block{Version: 1, Bits: 0x1d00ffff, Timestamp: 1700000000}
+	payout := script.CreateP2WPKHScript(bytes.Repeat([]byte{0x09}, 20))
+
+	template, err := AssembleBlockTemplate(prevHeader, mempool, 840000, payout, true)
+	if err != nil {
+		t.Fatalf("AssembleBlockTemplate failed: %v", err)
+	}
+
+	want := []*transaction.Tx{parent, child, independent}
+	if len(template.Txs) != len(want) {
+		t.Fatalf("selected %d transactions, want %d", len(template.Txs), len(want))
+	}
+	for i, tx := range want {
+		if template.Txs[i] != tx {
+			gotID, _ := template.Txs[i].Id()
+			wantID, _ := tx.Id()
+			t.Errorf("transaction %d is %s, want %s", i, gotID, wantID)
+		}
+	}
+
+	if template.Fees != 5510 {
+		t.Errorf("got fees %d, want 5510", template.Fees)
+	}
+	if template.Coinbase.TxOuts[0].Amount != 312500000+5510 {
+		t.Errorf("coinbase pays %d", template.Coinbase.TxOuts[0].Amount)
+	}
+	if height, err := template.Coinbase.CoinbaseHeight(); err != nil || height != 840000 {
+		t.Errorf("coinbase commits to height %d, %v", height, err)
+	}
+	if template.Weight > MaxBlockWeight || template.SigOpCost > MaxBlockSigOpsCost {
+		t.Errorf("template weight %d and sigop cost %d exceed the limits", template.Weight, template.SigOpCost)
+	}
+
+	fb := template.FullBlock()
+	root, err := fb.MerkleRoot()
+	if err != nil || !bytes.Equal(root, template.Header.MerkleRoot[:]) {
+		t.Error("header does not commit to the transactions")
+	}
+	prevHash, _ := prevHeader.Hash()
+	if !bytes.Equal(template.Header.PrevBlock[:], prevHash) || template.Header.Bits != prevHeader.Bits {
+		t.Error("header does not build on the previous block")
+	}
+	if template.Header.Timestamp <= prevHeader.Timestamp {
+		t.Errorf("timestamp %d is not after the previous block", template.Header.Timestamp)
+	}
+
+	// none of the transactions has a witness, so there is no commitment
+	if len(template.Coinbase.TxOuts) != 1 || template.Coinbase.HasWitness() {
+		t.Error("unexpected witness commitment")
+	}
+}
+
+func TestAssembleBlockTemplateSingleByteHeights(t *testing.T) {
+	// heights 17 to 127 are pushed as a single byte, 78 to 127 are bytes that read as op codes
+	for _, height := range []uint32{17, 78, 100, 127} {
+		template, err := AssembleBlockTemplate(&Block{}, nil, height, script.CreateP2WPKHScript(make([]byte, 20)), true)
+		if err != nil {
+			t.Fatalf("AssembleBlockTemplate at height %d failed: %v", height, err)
+		}
+		if got, err := template.Coinbase.CoinbaseHeight(); err != nil || got != height {
+			t.Errorf("coinbase at height %d commits to height %d, %v", height, got, err)
+		}
+	}
+}
+
+func TestAssembleBlockTemplateWitnessCommitment(t *testing.T) {
+	tx := templateTx(t, bytes.Repeat([]byte{0x01}, 32), 1000)
+	tx.TxIns[0].Witness = [][]byte{{0x01}}
+	template, err := AssembleBlockTemplate(&Block{}, []*MempoolEntry{{Tx: tx, Fee: 100}}, 500000, script.CreateP2WPKHScript(make([]byte, 20)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coinbase := template.Coinbase
+	if len(coinbase.TxOuts) != 2 || len(coinbase.TxIns[0].Witness) != 1 {
+		t.Fatalf("coinbase has %d outputs and witness %x", len(coinbase.TxOuts), coinbase.TxIns[0].Witness)
+	}
+	commitment := *coinbase.TxOuts[1].ScriptPubkey
+	if len(commitment) != 2 || !bytes.Equal(commitment[0], []byte{0x6a}) || !bytes.HasPrefix(commitment[1], witnessCommitmentHeader) || len(commitment[1]) != 36 {
+		t.Errorf("bad witness commitment %s", coinbase.TxOuts[1].ScriptPubkey)
+	}
+	if template.Coinbase.TxOuts[0].Amount != 1250000000+100 {
+		t.Errorf("coinbase pays %d", template.Coinbase.TxOuts[0].Amount)
+	}
+}
@@ -0,0 +1,86 @@
+package block
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// txHasher computes the merkle tree hashes of transactions on a pool of goroutines,
+// so transactions can be handed over while the rest of a block is still being parsed
+type txHasher struct {
+	jobs    chan txHashJob
+	wg      sync.WaitGroup
+	results []*txHashResult
+}
+
+type txHashJob struct {
+	tx     *transaction.Tx
+	result *txHashResult
+}
+
+type txHashResult struct {
+	hash []byte
+	err  error
+}
+
+// newTxHasher starts a worker for every CPU. With a single CPU there are no workers and add hashes right away.
+func newTxHasher() *txHasher {
+	workers := runtime.GOMAXPROCS(0)
+	h := &txHasher{}
+	if workers == 1 {
+		return h
+	}
+	h.jobs = make(chan txHashJob, 4*workers)
+	for w := 0; w < workers; w++ {
+		h.wg.Add(1)
+		go h.work()
+	}
+	return h
+}
+
+func (h *txHasher) work() {
+	defer h.wg.Done()
+	for job := range h.jobs {
+		job.run()
+	}
+}
+
+func (job txHashJob) run() {
+	serialized, err := job.tx.SerializeLegacy()
+	if err != nil {
+		job.result.err = err
+		return
+	}
+	// Hash256 of the legacy serialization is the txid in the byte order of the merkle tree
+	job.result.hash = utils.Hash256(serialized)
+}
+
+// add queues tx, its hash comes after those of the transactions added before it
+func (h *txHasher) add(tx *transaction.Tx) {
+	job := txHashJob{tx: tx, result: &txHashResult{}}
+	h.results = append(h.results, job.result)
+	if h.jobs == nil {
+		job.run()
+		return
+	}
+	h.jobs <- job
+}
+
+// wait stops the workers and returns the hashes in the order the transactions were added
+func (h *txHasher) wait() ([][]byte, error) {
+	if h.jobs != nil {
+		close(h.jobs)
+		h.wg.Wait()
+	}
+	hashes := make([][]byte, len(h.results))
+	for i, result := range h.results {
+		if result.err != nil {
+			return nil, result.err
+		}
+		hashes[i] = result.hash
+	}
+	return hashes, nil
+}
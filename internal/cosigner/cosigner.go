@@ -0,0 +1,312 @@
+package cosigner
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+// Session is a p2sh multisig signing session that can be passed between the cosigners.
+// Every input spends a p2sh output with an m-of-n multisig redeem script.
+type Session struct {
+	Tx      string   `json:"tx"`
+	Testnet bool     `json:"testnet"`
+	Inputs  []*Input `json:"inputs"`
+
+	tx *transaction.Tx
+}
+
+// Input holds the redeem script of an input and the signatures collected for it, keyed by hex SEC pubkey
+type Input struct {
+	RedeemScript string            `json:"redeem_script"`
+	Signatures   map[string]string `json:"signatures"`
+
+	redeemScript *script.Script
+	required     int
+	pubkeys      [][]byte
+}
+
+// NewSession starts a session for tx, with the redeem script of every input in order
+func NewSession(tx *transaction.Tx, redeemScripts []*script.Script) (*Session, error) {
+	if len(redeemScripts) != len(tx.TxIns) {
+		return nil, fmt.Errorf("got %d redeem scripts for %d inputs", len(redeemScripts), len(tx.TxIns))
+	}
+
+	unsigned := unsignedTx(tx)
+	serializedTx, err := unsigned.SerializeLegacy()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{Tx: hex.EncodeToString(serializedTx), Testnet: tx.Testnet, tx: unsigned}
+	for _, redeemScript := range redeemScripts {
+		raw, err := redeemScript.RawSerialize()
+		if err != nil {
+			return nil, err
+		}
+		input := &Input{RedeemScript: hex.EncodeToString(raw), Signatures: make(map[string]string)}
+		if err := input.load(); err != nil {
+			return nil, err
+		}
+		session.Inputs = append(session.Inputs, input)
+	}
+
+	return session, nil
+}
+
+// ParseSession reads a session from its JSON serialization and checks every collected signature
+func ParseSession(data []byte) (*Session, error) {
+	session := &Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, err
+	}
+
+	rawTx, err := hex.DecodeString(session.Tx)
+	if err != nil {
+		return nil, fmt.Errorf("bad transaction in session: %v", err)
+	}
+	session.tx, err = transaction.ParseTxStrict(rawTx, session.Testnet)
+	if err != nil {
+		return nil, fmt.Errorf("bad transaction in session: %v", err)
+	}
+
+	if len(session.Inputs) != len(session.tx.TxIns) {
+		return nil, fmt.Errorf("session has %d inputs, transaction has %d", len(session.Inputs), len(session.tx.TxIns))
+	}
+
+	for i, input := range session.Inputs {
+		if input.Signatures == nil {
+			input.Signatures = make(map[string]string)
+		}
+		if err := input.load(); err != nil {
+			return nil, fmt.Errorf("input %d: %v", i, err)
+		}
+		for pubkey, sig := range input.Signatures {
+			if err := session.checkSignature(i, pubkey, sig); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return session, nil
+}
+
+// LoadSession reads a session file
+func LoadSession(filename string) (*Session, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSession(data)
+}
+
+// Serialize returns the JSON serialization of the session
+func (s *Session) Serialize() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// Save writes the session to a file that can be handed to the next cosigner
+func (s *Session) Save(filename string) error {
+	data, err := s.Serialize()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// Sign adds a signature by privateKey to every input whose redeem script contains its compressed pubkey.
+// It returns the number of inputs signed.
+func (s *Session) Sign(privateKey *signatureverification.PrivateKey) (int, error) {
+	pubkey := privateKey.Point.Serialize(true)
+	signed := 0
+
+	for i, input := range s.Inputs {
+		if !input.hasPubkey(pubkey) {
+			continue
+		}
+
+		z, err := s.tx.SigHash(uint32(i), input.redeemScript)
+		if err != nil {
+			return signed, err
+		}
+		derSig, err := privateKey.Sign(z)
+		if err != nil {
+			return signed, err
+		}
+
+		sig := append(derSig.Serialize(), byte(transaction.SigHashAll))
+		input.Signatures[hex.EncodeToString(pubkey)] = hex.EncodeToString(sig)
+		signed++
+	}
+
+	return signed, nil
+}
+
+// Merge adds the signatures collected in other, which must be a session for the same transaction
+func (s *Session) Merge(other *Session) error {
+	if s.Tx != other.Tx || len(s.Inputs) != len(other.Inputs) {
+		return fmt.Errorf("sessions are for different transactions")
+	}
+
+	for i, input := range s.Inputs {
+		if input.RedeemScript != other.Inputs[i].RedeemScript {
+			return fmt.Errorf("input %d has a different redeem script", i)
+		}
+	}
+
+	// check everything before changing anything
+	for i, otherInput := range other.Inputs {
+		for pubkey, sig := range otherInput.Signatures {
+			if err := s.checkSignature(i, pubkey, sig); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, otherInput := range other.Inputs {
+		for pubkey, sig := range otherInput.Signatures {
+			s.Inputs[i].Signatures[pubkey] = sig
+		}
+	}
+
+	return nil
+}
+
+// Missing returns how many more signatures each input needs
+func (s *Session) Missing() []int {
+	missing := make([]int, len(s.Inputs))
+	for i, input := range s.Inputs {
+		missing[i] = max(input.required-len(input.Signatures), 0)
+	}
+	return missing
+}
+
+// IsComplete returns whether every input has enough signatures to be finalized
+func (s *Session) IsComplete() bool {
+	for _, missing := range s.Missing() {
+		if missing > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Finalize returns the transaction with the ScriptSig of every input filled in
+func (s *Session) Finalize() (*transaction.Tx, error) {
+	if !s.IsComplete() {
+		return nil, fmt.Errorf("not enough signatures, missing %v", s.Missing())
+	}
+
+	tx := unsignedTx(s.tx)
+
+	for i, input := range s.Inputs {
+		// OP_CHECKMULTISIG pops one element too many, and needs the signatures in pubkey order
+		scriptSig := script.Script{[]byte{0x00}}
+		for _, pubkey := range input.pubkeys {
+			if len(scriptSig) == input.required+1 {
+				break
+			}
+			sigHex, ok := input.Signatures[hex.EncodeToString(pubkey)]
+			if !ok {
+				continue
+			}
+			sig, err := hex.DecodeString(sigHex)
+			if err != nil {
+				return nil, fmt.Errorf("input %d: signature by %x: %w", i, pubkey, err)
+			}
+			scriptSig = append(scriptSig, sig)
+		}
+
+		raw, err := hex.DecodeString(input.RedeemScript)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: redeem script: %w", i, err)
+		}
+		scriptSig = append(scriptSig, raw)
+		tx.TxIns[i].ScriptSig = &scriptSig
+	}
+
+	return tx, nil
+}
+
+// checkSignature verifies that sig is a valid SIGHASH_ALL signature by pubkey for input index
+func (s *Session) checkSignature(index int, pubkeyHex, sigHex string) error {
+	input := s.Inputs[index]
+
+	pubkey, err := hex.DecodeString(pubkeyHex)
+	if err != nil || !input.hasPubkey(pubkey) {
+		return fmt.Errorf("input %d: %s is not a key of the redeem script", index, pubkeyHex)
+	}
+
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil || len(sig) < 2 || sig[len(sig)-1] != byte(transaction.SigHashAll) {
+		return fmt.Errorf("input %d: bad signature by %s", index, pubkeyHex)
+	}
+
+	derSig, err := signatureverification.ParseDER(sig[:len(sig)-1])
+	if err != nil {
+		return fmt.Errorf("input %d: bad signature by %s: %v", index, pubkeyHex, err)
+	}
+
+	point, err := signatureverification.ParseSEC(pubkey)
+	if err != nil {
+		return err
+	}
+
+	z, err := s.tx.SigHash(uint32(index), input.redeemScript)
+	if err != nil {
+		return err
+	}
+
+	if !point.Verify(z, derSig) {
+		return fmt.Errorf("input %d: invalid signature by %s", index, pubkeyHex)
+	}
+
+	return nil
+}
+
+// load parses the redeem script as an m-of-n multisig script
+func (input *Input) load() error {
+	raw, err := hex.DecodeString(input.RedeemScript)
+	if err != nil {
+		return err
+	}
+
+	redeemScript, err := script.ParseRawScript(raw)
+	if err != nil {
+		return err
+	}
+
+	required, pubkeys, ok := redeemScript.ParseMultisig()
+	if !ok {
+		return fmt.Errorf("redeem script is not an m-of-n multisig script")
+	}
+
+	input.redeemScript = redeemScript
+	input.required = required
+	input.pubkeys = pubkeys
+	return nil
+}
+
+func (input *Input) hasPubkey(pubkey []byte) bool {
+	for _, candidate := range input.pubkeys {
+		if bytes.Equal(candidate, pubkey) {
+			return true
+		}
+	}
+	return false
+}
+
+// unsignedTx returns a copy of tx with empty ScriptSigs
+func unsignedTx(tx *transaction.Tx) *transaction.Tx {
+	txIns := make([]*transaction.TxIn, len(tx.TxIns))
+	for i, txIn := range tx.TxIns {
+		txIns[i] = transaction.NewTxInFromOutPoint(txIn.PrevOut, &script.Script{}, txIn.Sequence)
+	}
+	return transaction.NewTx(tx.Version, txIns, tx.TxOuts, tx.Locktime, tx.Testnet)
+}
@@ -0,0 +1,181 @@
+package cosigner
+
+import (
+	"bytes"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func testKeys(t *testing.T) []*signatureverification.PrivateKey {
+	t.Helper()
+	var keys []*signatureverification.PrivateKey
+	for _, secret := range []int64{1001, 2002, 3003} {
+		key, err := signatureverification.NewPrivateKey(big.NewInt(secret))
+		if err != nil {
+			t.Fatalf("NewPrivateKey error: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// multisig returns the 2-of-3 redeem script for keys
+func multisig(keys []*signatureverification.PrivateKey) *script.Script {
+	s := script.Script{[]byte{0x52}}
+	for _, key := range keys {
+		s = append(s, key.Point.Serialize(true))
+	}
+	s = append(s, []byte{0x53}, []byte{0xae})
+	return &s
+}
+
+func testSession(t *testing.T, redeemScript *script.Script) *Session {
+	t.Helper()
+	txIn := transaction.NewTxIn(bytes.Repeat([]byte{0x11}, 32), 0, &script.Script{}, 0xffffffff)
+	txOut := transaction.NewTxOut(90000, script.CreateP2pkhScript(make([]byte, 20)))
+	tx := transaction.NewTx(1, []*transaction.TxIn{txIn}, []*transaction.TxOut{txOut}, 0, true)
+
+	session, err := NewSession(tx, []*script.Script{redeemScript})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	return session
+}
+
+func TestSessionSignMergeFinalize(t *testing.T) {
+	keys := testKeys(t)
+	redeemScript := multisig(keys)
+	session := testSession(t, redeemScript)
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := session.Save(path); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	// The first and last cosigner sign independently from the same file
+	var signed []*Session
+	for _, key := range []*signatureverification.PrivateKey{keys[2], keys[0]} {
+		copySession, err := LoadSession(path)
+		if err != nil {
+			t.Fatalf("LoadSession error: %v", err)
+		}
+		if n, err := copySession.Sign(key); n != 1 || err != nil {
+			t.Fatalf("Sign signed %d inputs, error: %v", n, err)
+		}
+		signed = append(signed, copySession)
+	}
+
+	if signed[0].IsComplete() {
+		t.Errorf("A single signature should not complete a 2-of-3 session")
+	}
+	if _, err := signed[0].Finalize(); err == nil {
+		t.Errorf("Finalizing an incomplete session should fail")
+	}
+
+	if err := signed[0].Merge(signed[1]); err != nil {
+		t.Fatalf("Merge error: %v", err)
+	}
+	if !signed[0].IsComplete() {
+		t.Fatalf("Session should be complete, missing %v", signed[0].Missing())
+	}
+
+	// The merged session survives a round trip
+	serialized, _ := signed[0].Serialize()
+	merged, err := ParseSession(serialized)
+	if err != nil {
+		t.Fatalf("ParseSession error: %v", err)
+	}
+
+	tx, err := merged.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize error: %v", err)
+	}
+
+	// The ScriptSig satisfies the p2sh output
+	rawRedeemScript, _ := redeemScript.RawSerialize()
+	scriptPubkey := script.CreateP2SHScript(utils.Hash160(rawRedeemScript))
+	z, err := tx.SigHash(0, redeemScript)
+	if err != nil {
+		t.Fatalf("SigHash error: %v", err)
+	}
+	ctx := &script.ExecutionContext{Z: z, Flags: script.StandardVerifyFlags}
+	if err := script.VerifyScript(tx.TxIns[0].ScriptSig, scriptPubkey, ctx); err != nil {
+		t.Errorf("Finalized ScriptSig does not satisfy the p2sh ScriptPubKey: %v", err)
+	}
+}
+
+func TestSessionRejectsBadSignatures(t *testing.T) {
+	keys := testKeys(t)
+	session := testSession(t, multisig(keys))
+
+	outsider, _ := signatureverification.NewPrivateKey(big.NewInt(4004))
+	if n, _ := session.Sign(outsider); n != 0 {
+		t.Errorf("A key outside the redeem script should not sign")
+	}
+
+	other := testSession(t, multisig(keys))
+	other.Sign(keys[1])
+
+	// Corrupt the signature before merging
+	for pubkey, sig := range other.Inputs[0].Signatures {
+		other.Inputs[0].Signatures[pubkey] = "30" + sig[2:len(sig)-4] + "0001"
+	}
+	if err := session.Merge(other); err == nil {
+		t.Errorf("Merging an invalid signature should fail")
+	}
+	if len(session.Inputs[0].Signatures) != 0 {
+		t.Errorf("A failed merge should not add signatures")
+	}
+
+	differentTx := testSession(t, multisig(keys[:]))
+	differentTx.Tx = differentTx.Tx[:len(differentTx.Tx)-2] + "01"
+	if err := session.Merge(differentTx); err == nil {
+		t.Errorf("Merging a session for another transaction should fail")
+	}
+}
+
+func TestNewSessionRequiresMultisig(t *testing.T) {
+	txIn := transaction.NewTxIn(bytes.Repeat([]byte{0x11}, 32), 0, &script.Script{}, 0xffffffff)
+	tx := transaction.NewTx(1, []*transaction.TxIn{txIn}, nil, 0, true)
+
+	if _, err := NewSession(tx, []*script.Script{script.CreateP2pkhScript(make([]byte, 20))}); err == nil {
+		t.Errorf("A non-multisig redeem script should be rejected")
+	}
+	if _, err := NewSession(tx, nil); err == nil {
+		t.Errorf("A redeem script is required for every input")
+	}
+
+	// OP_2 pushed as data is not the m of a multisig script
+	raw, _ := multisig(testKeys(t)).RawSerialize()
+	pushedM, err := script.ParseRawScript(append([]byte{0x01}, raw...))
+	if err != nil {
+		t.Fatalf("ParseRawScript error: %v", err)
+	}
+	if _, err := NewSession(tx, []*script.Script{pushedM}); err == nil {
+		t.Errorf("A redeem script with a pushed m should be rejected")
+	}
+}
+
+func TestFinalizeRejectsBadHex(t *testing.T) {
+	keys := testKeys(t)
+	session := testSession(t, multisig(keys))
+	for _, key := range keys[:2] {
+		if n, err := session.Sign(key); n != 1 || err != nil {
+			t.Fatalf("Sign signed %d inputs, error: %v", n, err)
+		}
+	}
+
+	for pubkey, sig := range session.Inputs[0].Signatures {
+		session.Inputs[0].Signatures[pubkey] = sig[:len(sig)-1] + "x"
+		break
+	}
+	if _, err := session.Finalize(); err == nil {
+		t.Errorf("Finalize should fail on a signature that is not hex")
+	}
+}
@@ -0,0 +1,262 @@
+package descriptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+const (
+	// inputCharset are the characters a descriptor may contain, in the order the checksum numbers them
+	inputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	// checksumCharset are the characters of the checksum, the bech32 charset
+	checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	checksumLength  = 8
+)
+
+var checksumGenerator = [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+
+func polymod(symbols []uint64) uint64 {
+	chk := uint64(1)
+	for _, value := range symbols {
+		top := chk >> 35
+		chk = (chk&0x7ffffffff)<<5 ^ value
+		for i, generator := range checksumGenerator {
+			if (top>>i)&1 == 1 {
+				chk ^= generator
+			}
+		}
+	}
+	return chk
+}
+
+// Checksum returns the 8 character checksum of a descriptor expression, as computed by Bitcoin Core
+func Checksum(expression string) (string, error) {
+	var symbols, groups []uint64
+	for _, c := range expression {
+		position := strings.IndexRune(inputCharset, c)
+		if position < 0 {
+			return "", fmt.Errorf("descriptor has an invalid character %q", c)
+		}
+		// the low 5 bits are a symbol of their own, the high bits of every 3 characters are combined into one
+		symbols = append(symbols, uint64(position&31))
+		groups = append(groups, uint64(position>>5))
+		if len(groups) == 3 {
+			symbols = append(symbols, groups[0]*9+groups[1]*3+groups[2])
+			groups = groups[:0]
+		}
+	}
+	switch len(groups) {
+	case 1:
+		symbols = append(symbols, groups[0])
+	case 2:
+		symbols = append(symbols, groups[0]*3+groups[1])
+	}
+	symbols = append(symbols, make([]uint64, checksumLength)...)
+
+	chk := polymod(symbols) ^ 1
+	checksum := make([]byte, checksumLength)
+	for i := range checksum {
+		checksum[i] = checksumCharset[(chk>>(5*(checksumLength-1-i)))&31]
+	}
+	return string(checksum), nil
+}
+
+// AddChecksum returns the expression followed by # and its checksum
+func AddChecksum(expression string) (string, error) {
+	checksum, err := Checksum(expression)
+	if err != nil {
+		return "", err
+	}
+	return expression + "#" + checksum, nil
+}
+
+// StripChecksum checks the checksum after the # of descriptor and returns the expression before it
+func StripChecksum(descriptor string) (string, error) {
+	expression, checksum, ok := strings.Cut(descriptor, "#")
+	if !ok {
+		return "", fmt.Errorf("descriptor %q has no checksum", descriptor)
+	}
+	want, err := Checksum(expression)
+	if err != nil {
+		return "", err
+	}
+	if checksum != want {
+		return "", fmt.Errorf("descriptor %q has checksum %q, want %q", expression, checksum, want)
+	}
+	return expression, nil
+}
+
+// Descriptor is an output descriptor: an expression that describes an output script, such as wpkh(02...).
+// Descriptors are made with the functions of this package, which write the expression in canonical form.
+type Descriptor struct {
+	expression   string
+	scriptPubkey *script.Script
+	// inner is the script an sh or wsh descriptor wraps, nil for the others
+	inner *script.Script
+	// topLevel descriptors can not be wrapped, segwit ones can not be wrapped in wsh and neither can uncompressed keys
+	topLevel, segwit, uncompressed bool
+}
+
+// String returns the canonical form of the descriptor: lowercase hex keys, no spaces and the checksum Bitcoin Core
+// requires for importdescriptors
+func (d *Descriptor) String() string {
+	// descriptors are only built from characters of the input charset
+	desc, _ := AddChecksum(d.expression)
+	return desc
+}
+
+// Expression returns the descriptor without its checksum
+func (d *Descriptor) Expression() string {
+	return d.expression
+}
+
+// ScriptPubkey returns the output script the descriptor describes
+func (d *Descriptor) ScriptPubkey() *script.Script {
+	return d.scriptPubkey
+}
+
+// InnerScript returns the redeem script of an sh descriptor or the witness script of a wsh one, nil for the others
+func (d *Descriptor) InnerScript() *script.Script {
+	return d.inner
+}
+
+// Address returns the address of the output script, for the descriptors that have one
+func (d *Descriptor) Address(testnet bool) (string, error) {
+	return transaction.NewTxOut(0, d.scriptPubkey).Address(testnet)
+}
+
+// keyHex returns the SEC serialization of a public key as lowercase hex
+func keyHex(key *signatureverification.S256Point, compressed bool) string {
+	return hex.EncodeToString(key.Serialize(compressed))
+}
+
+// PKH describes the p2pkh output of key
+func PKH(key *signatureverification.S256Point, compressed bool) *Descriptor {
+	return &Descriptor{
+		expression:   fmt.Sprintf("pkh(%s)", keyHex(key, compressed)),
+		scriptPubkey: script.CreateP2pkhScript(key.Hash160(compressed)),
+		uncompressed: !compressed,
+	}
+}
+
+// WPKH describes the p2wpkh output of key, which is always compressed
+func WPKH(key *signatureverification.S256Point) *Descriptor {
+	return &Descriptor{
+		expression:   fmt.Sprintf("wpkh(%s)", keyHex(key, true)),
+		scriptPubkey: script.CreateP2WPKHScript(key.Hash160(true)),
+		segwit:       true,
+	}
+}
+
+// RawTR describes the taproot output of the output key itself, a 32 byte x-only key, without a tweak
+func RawTR(outputKey []byte) (*Descriptor, error) {
+	if len(outputKey) != 32 {
+		return nil, fmt.Errorf("x-only key of %d bytes is not 32 bytes", len(outputKey))
+	}
+	return &Descriptor{
+		expression:   fmt.Sprintf("rawtr(%x)", outputKey),
+		scriptPubkey: script.CreateP2TRScript(outputKey),
+		topLevel:     true,
+	}, nil
+}
+
+// Multi describes a bare OP_CHECKMULTISIG script that needs required signatures of keys, in the given order.
+// Wrap it in SH or WSH for a p2sh or p2wsh output.
+func Multi(required int, keys ...*signatureverification.S256Point) (*Descriptor, error) {
+	return multi("multi", required, keys)
+}
+
+// SortedMulti is Multi with the keys sorted by their serialization, as BIP67 does, so their order does not matter
+func SortedMulti(required int, keys ...*signatureverification.S256Point) (*Descriptor, error) {
+	return multi("sortedmulti", required, keys)
+}
+
+func multi(name string, required int, keys []*signatureverification.S256Point) (*Descriptor, error) {
+	if required < 1 || required > len(keys) || len(keys) > 16 {
+		return nil, fmt.Errorf("%s of %d of %d keys, want 1 <= required <= keys <= 16", name, required, len(keys))
+	}
+	var serialized [][]byte
+	var expression strings.Builder
+	fmt.Fprintf(&expression, "%s(%d", name, required)
+	for _, key := range keys {
+		serialized = append(serialized, key.Serialize(true))
+		fmt.Fprintf(&expression, ",%s", keyHex(key, true))
+	}
+	expression.WriteString(")")
+	if name == "sortedmulti" {
+		slices.SortFunc(serialized, bytes.Compare)
+	}
+
+	multisig, err := script.CreateMultisigScript(required, serialized)
+	if err != nil {
+		return nil, err
+	}
+	return &Descriptor{expression: expression.String(), scriptPubkey: multisig}, nil
+}
+
+// SH describes the p2sh output of the script inner describes
+func SH(inner *Descriptor) (*Descriptor, error) {
+	if inner.topLevel {
+		return nil, fmt.Errorf("%s can not be nested in sh", inner.expression)
+	}
+	raw, err := inner.scriptPubkey.RawSerialize()
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > script.MaxScriptElementSize {
+		return nil, fmt.Errorf("redeem script of %d bytes exceeds the limit of %d", len(raw), script.MaxScriptElementSize)
+	}
+	return &Descriptor{
+		expression:   fmt.Sprintf("sh(%s)", inner.expression),
+		scriptPubkey: script.CreateP2SHScript(utils.Hash160(raw)),
+		inner:        inner.scriptPubkey,
+		topLevel:     true,
+	}, nil
+}
+
+// WSH describes the p2wsh output of the script inner describes
+func WSH(inner *Descriptor) (*Descriptor, error) {
+	if inner.topLevel || inner.segwit || inner.uncompressed {
+		return nil, fmt.Errorf("%s can not be nested in wsh", inner.expression)
+	}
+	raw, err := inner.scriptPubkey.RawSerialize()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(raw)
+	return &Descriptor{
+		expression:   fmt.Sprintf("wsh(%s)", inner.expression),
+		scriptPubkey: script.CreateP2WSHScript(hash[:]),
+		inner:        inner.scriptPubkey,
+		segwit:       true,
+	}, nil
+}
+
+// Addr describes the output paying address
+func Addr(address string, testnet bool) (*Descriptor, error) {
+	scriptPubkey, err := transaction.AddressScriptPubkey(address, testnet)
+	if err != nil {
+		return nil, err
+	}
+	return &Descriptor{expression: fmt.Sprintf("addr(%s)", address), scriptPubkey: scriptPubkey, topLevel: true}, nil
+}
+
+// Raw describes the output script s as it is
+func Raw(s *script.Script) (*Descriptor, error) {
+	raw, err := s.RawSerialize()
+	if err != nil {
+		return nil, err
+	}
+	return &Descriptor{expression: fmt.Sprintf("raw(%x)", raw), scriptPubkey: s, topLevel: true}, nil
+}
@@ -0,0 +1,124 @@
+package descriptor
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func testKey(secret int64) *signatureverification.S256Point {
+	key, _ := signatureverification.NewPrivateKey(big.NewInt(secret))
+	return key.Point
+}
+
+func TestChecksum(t *testing.T) {
+	// vectors of Bitcoin Core
+	tests := []struct {
+		expression string
+		checksum   string
+	}{
+		{"raw(deadbeef)", "89f8spxm"},
+		{"pkh(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)", "8fhd9pwu"},
+	}
+	for _, tt := range tests {
+		got, err := Checksum(tt.expression)
+		if err != nil || got != tt.checksum {
+			t.Errorf("Checksum(%s) = %s, %v, want %s", tt.expression, got, err, tt.checksum)
+		}
+		expression, err := StripChecksum(tt.expression + "#" + tt.checksum)
+		if err != nil || expression != tt.expression {
+			t.Errorf("StripChecksum = %s, %v", expression, err)
+		}
+	}
+
+	for _, invalid := range []string{"raw(deadbeef)", "raw(deadbeef)#89f8spxn", "raw(deadbeef)#89f8spx", "raw(dé)#89f8spxm"} {
+		if _, err := StripChecksum(invalid); err == nil {
+			t.Errorf("StripChecksum(%s) should fail", invalid)
+		}
+	}
+}
+
+func TestDescriptors(t *testing.T) {
+	two, three := testKey(2), testKey(3)
+
+	pkh := PKH(two, true)
+	if got := pkh.String(); got != "pkh(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)#8fhd9pwu" {
+		t.Errorf("PKH = %s", got)
+	}
+	if address, _ := pkh.Address(false); address != two.Address(true, false) {
+		t.Errorf("PKH address = %s, want %s", address, two.Address(true, false))
+	}
+
+	wpkh := WPKH(three)
+	if got := wpkh.Expression(); got != "wpkh(02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9)" {
+		t.Errorf("WPKH = %s", got)
+	}
+	nested, err := SH(wpkh)
+	if err != nil {
+		t.Fatalf("SH error: %v", err)
+	}
+	rawWPKH, _ := wpkh.ScriptPubkey().RawSerialize()
+	rawNested, _ := nested.ScriptPubkey().RawSerialize()
+	rawWant, _ := script.CreateP2SHScript(utils.Hash160(rawWPKH)).RawSerialize()
+	if !bytes.Equal(rawNested, rawWant) || nested.InnerScript() != wpkh.ScriptPubkey() {
+		t.Errorf("sh(wpkh) script = %x, want %x", rawNested, rawWant)
+	}
+
+	// sortedmulti describes the same script whatever the order of its keys, multi does not
+	sorted, _ := SortedMulti(1, three, two)
+	resorted, _ := SortedMulti(1, two, three)
+	rawSorted, _ := sorted.ScriptPubkey().RawSerialize()
+	rawResorted, _ := resorted.ScriptPubkey().RawSerialize()
+	if !bytes.Equal(rawSorted, rawResorted) || sorted.Expression() == resorted.Expression() {
+		t.Errorf("SortedMulti depends on the order of its keys")
+	}
+	multi, _ := Multi(1, three, two)
+	if rawMulti, _ := multi.ScriptPubkey().RawSerialize(); bytes.Equal(rawMulti, rawSorted) {
+		t.Errorf("Multi should keep the order of its keys")
+	}
+	wsh, err := WSH(multi)
+	if err != nil {
+		t.Fatalf("WSH error: %v", err)
+	}
+	if !wsh.ScriptPubkey().IsP2WSHScriptPubKey() || !strings.HasPrefix(wsh.String(), "wsh(multi(1,02f9") {
+		t.Errorf("WSH = %s", wsh)
+	}
+
+	raw, _ := Raw(&script.Script{{0xde, 0xad, 0xbe, 0xef}})
+	if expression, err := StripChecksum(raw.String()); err != nil || expression != "raw(04deadbeef)" {
+		t.Errorf("Raw = %s, %v", raw, err)
+	}
+}
+
+func TestNesting(t *testing.T) {
+	key := testKey(2)
+	multi, _ := Multi(1, key)
+	shMulti, _ := SH(multi)
+	wshMulti, _ := WSH(multi)
+	rawtr, _ := RawTR(make([]byte, 32))
+	tests := []struct {
+		name  string
+		build func() (*Descriptor, error)
+	}{
+		{"sh(sh)", func() (*Descriptor, error) { return SH(shMulti) }},
+		{"wsh(wsh)", func() (*Descriptor, error) { return WSH(wshMulti) }},
+		{"wsh(wpkh)", func() (*Descriptor, error) { return WSH(WPKH(key)) }},
+		{"wsh(uncompressed pkh)", func() (*Descriptor, error) { return WSH(PKH(key, false)) }},
+		{"sh(rawtr)", func() (*Descriptor, error) { return SH(rawtr) }},
+		{"multi of 0", func() (*Descriptor, error) { return Multi(0, key) }},
+		{"multi of too many", func() (*Descriptor, error) { return Multi(2, key) }},
+	}
+	for _, tt := range tests {
+		if _, err := tt.build(); err == nil {
+			t.Errorf("%s should fail", tt.name)
+		}
+	}
+	if _, err := SH(wshMulti); err != nil {
+		t.Errorf("sh(wsh(multi)) failed: %v", err)
+	}
+}
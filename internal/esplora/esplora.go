@@ -0,0 +1,29 @@
+package esplora
+
+import (
+	"net/http"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+// Backend combines the transaction and header fetchers of an Esplora API, it is the chain backend
+// of the monitor and the reserves packages
+type Backend struct {
+	*transaction.TxFetcher
+	*block.HeaderFetcher
+}
+
+func NewBackend() *Backend {
+	return &Backend{transaction.NewTxFetcher(), block.NewHeaderFetcher()}
+}
+
+// NewBackendWithClient creates a backend whose fetchers send their requests with client and userAgent
+func NewBackendWithClient(client *http.Client, userAgent string) *Backend {
+	return &Backend{transaction.NewTxFetcherWithClient(client, userAgent), block.NewHeaderFetcherWithClient(client, userAgent)}
+}
+
+// FetchTipHeight returns the height of the chain tip. Both fetchers can look it up, the header fetcher is asked.
+func (b *Backend) FetchTipHeight(testnet bool) (uint32, error) {
+	return b.HeaderFetcher.FetchTipHeight(testnet)
+}
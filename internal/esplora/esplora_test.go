@@ -0,0 +1,17 @@
+package esplora
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNewBackendWithClient(t *testing.T) {
+	client := &http.Client{}
+	b := NewBackendWithClient(client, "test-agent")
+	if b.TxFetcher.Client != client || b.HeaderFetcher.Client != client {
+		t.Error("the fetchers do not use the client")
+	}
+	if b.TxFetcher.UserAgent != "test-agent" || b.HeaderFetcher.UserAgent != "test-agent" {
+		t.Error("the fetchers do not send the user agent")
+	}
+}
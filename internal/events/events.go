@@ -0,0 +1,66 @@
+package events
+
+import "github.com/caspereijkens/cryptocurrency/internal/transaction"
+
+// Events receives what happens on the chain and to watched addresses, so an application embedding this module can
+// react to it instead of polling. network.SyncHeaders reports the blocks it connects and monitor.Monitor the
+// transactions and balances of its addresses, and the blocks it sees reorged out. Block hashes are hex in display
+// byte order. The methods are called from the goroutine that syncs or polls and should return quickly.
+type Events interface {
+	// OnBlockConnected is called for every block that extends the chain, in order of height
+	OnBlockConnected(hash string, height uint32)
+	// OnBlockDisconnected is called for a block that is no longer in the chain
+	OnBlockDisconnected(hash string, height uint32)
+	// OnTxMempool is called when a transaction of address is first seen unconfirmed
+	OnTxMempool(address string, tx *transaction.AddressTx)
+	// OnTxConfirmed is called when a transaction of address confirms, and again as its confirmations grow
+	OnTxConfirmed(address string, tx *transaction.AddressTx, confirmations uint32)
+	// OnBalanceChanged is called with the new balance of address, unconfirmed transactions included
+	OnBalanceChanged(address string, balance int64)
+}
+
+// NoEvents ignores all events. Embed it to implement only the methods of Events that are needed.
+type NoEvents struct{}
+
+func (NoEvents) OnBlockConnected(hash string, height uint32)                                   {}
+func (NoEvents) OnBlockDisconnected(hash string, height uint32)                                {}
+func (NoEvents) OnTxMempool(address string, tx *transaction.AddressTx)                         {}
+func (NoEvents) OnTxConfirmed(address string, tx *transaction.AddressTx, confirmations uint32) {}
+func (NoEvents) OnBalanceChanged(address string, balance int64)                                {}
+
+// Multi returns Events that passes every event to all of targets in order
+func Multi(targets ...Events) Events {
+	return multi(targets)
+}
+
+type multi []Events
+
+func (m multi) OnBlockConnected(hash string, height uint32) {
+	for _, target := range m {
+		target.OnBlockConnected(hash, height)
+	}
+}
+
+func (m multi) OnBlockDisconnected(hash string, height uint32) {
+	for _, target := range m {
+		target.OnBlockDisconnected(hash, height)
+	}
+}
+
+func (m multi) OnTxMempool(address string, tx *transaction.AddressTx) {
+	for _, target := range m {
+		target.OnTxMempool(address, tx)
+	}
+}
+
+func (m multi) OnTxConfirmed(address string, tx *transaction.AddressTx, confirmations uint32) {
+	for _, target := range m {
+		target.OnTxConfirmed(address, tx, confirmations)
+	}
+}
+
+func (m multi) OnBalanceChanged(address string, balance int64) {
+	for _, target := range m {
+		target.OnBalanceChanged(address, balance)
+	}
+}
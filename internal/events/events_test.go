@@ -0,0 +1,40 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+// blockRecorder only implements the block events, the rest comes from NoEvents
+type blockRecorder struct {
+	NoEvents
+	name   string
+	events *[]string
+}
+
+func (r blockRecorder) OnBlockConnected(hash string, height uint32) {
+	*r.events = append(*r.events, fmt.Sprintf("%s connected %s at %d", r.name, hash, height))
+}
+
+func (r blockRecorder) OnBlockDisconnected(hash string, height uint32) {
+	*r.events = append(*r.events, fmt.Sprintf("%s disconnected %s at %d", r.name, hash, height))
+}
+
+func TestMulti(t *testing.T) {
+	var got []string
+	events := Multi(blockRecorder{name: "first", events: &got}, blockRecorder{name: "second", events: &got})
+
+	events.OnBlockConnected("aa", 1)
+	events.OnTxMempool("address", &transaction.AddressTx{TxID: "bb"})
+	events.OnTxConfirmed("address", &transaction.AddressTx{TxID: "bb"}, 1)
+	events.OnBalanceChanged("address", 5000)
+	events.OnBlockDisconnected("aa", 1)
+
+	want := []string{"first connected aa at 1", "second connected aa at 1", "first disconnected aa at 1", "second disconnected aa at 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,191 @@
+package lightning
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+const (
+	// AnchorOutputValue is the amount of an anchor output in satoshis, as in BOLT 3
+	AnchorOutputValue = uint64(330)
+	// Anybody can spend an anchor output this many blocks after the commitment confirms
+	anchorCSVDelay = 16
+)
+
+// FundingScript returns the 2-of-2 multisig witness script of a channel funding output.
+// The keys are sorted so both sides build the same script.
+func FundingScript(pubkey1, pubkey2 []byte) (*script.Script, error) {
+	if err := checkPubkey(pubkey1); err != nil {
+		return nil, err
+	}
+	if err := checkPubkey(pubkey2); err != nil {
+		return nil, err
+	}
+
+	first, second := sortPubkeys(pubkey1, pubkey2)
+	return script.NewBuilder().Op("OP_2").PushData(first).PushData(second).Ops("OP_2", "OP_CHECKMULTISIG").Script()
+}
+
+// FundingOutput returns the p2wsh funding output of a channel and its witness script
+func FundingOutput(amount uint64, pubkey1, pubkey2 []byte) (*transaction.TxOut, *script.Script, error) {
+	witnessScript, err := FundingScript(pubkey1, pubkey2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txOut, err := p2wshOutput(amount, witnessScript)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return txOut, witnessScript, nil
+}
+
+// FundingWitness returns the witness spending a funding output with a signature of each key
+func FundingWitness(pubkey1, sig1, pubkey2, sig2 []byte) ([][]byte, error) {
+	witnessScript, err := FundingScript(pubkey1, pubkey2)
+	if err != nil {
+		return nil, err
+	}
+
+	rawWitnessScript, err := witnessScript.RawSerialize()
+	if err != nil {
+		return nil, err
+	}
+
+	// the signatures go in the same order as the sorted keys
+	if bytes.Compare(pubkey1, pubkey2) > 0 {
+		sig1, sig2 = sig2, sig1
+	}
+
+	// the empty element is popped by the OP_CHECKMULTISIG off-by-one bug
+	return [][]byte{{}, sig1, sig2, rawWitnessScript}, nil
+}
+
+// AnchorScript returns the witness script of an anchor output: spendable with the funding key,
+// or by anyone 16 blocks after the commitment transaction confirms.
+func AnchorScript(fundingPubkey []byte) (*script.Script, error) {
+	if err := checkPubkey(fundingPubkey); err != nil {
+		return nil, err
+	}
+
+	// <pubkey> OP_CHECKSIG OP_IFDUP OP_NOTIF <16> OP_CHECKSEQUENCEVERIFY OP_ENDIF
+	return script.NewBuilder().
+		PushData(fundingPubkey).Ops("OP_CHECKSIG", "OP_IFDUP", "OP_NOTIF").
+		PushInt(anchorCSVDelay).Ops("OP_CHECKSEQUENCEVERIFY", "OP_ENDIF").
+		Script()
+}
+
+// AnchorOutput returns the p2wsh anchor output for fundingPubkey and its witness script
+func AnchorOutput(fundingPubkey []byte) (*transaction.TxOut, *script.Script, error) {
+	witnessScript, err := AnchorScript(fundingPubkey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txOut, err := p2wshOutput(AnchorOutputValue, witnessScript)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return txOut, witnessScript, nil
+}
+
+// AnchorWitness returns the witness spending an anchor output with a signature of the funding key
+func AnchorWitness(sig []byte, witnessScript *script.Script) ([][]byte, error) {
+	return witnessWithScript(witnessScript, sig)
+}
+
+// AnchorSweepWitness returns the witness with which anyone can spend an anchor output after the delay
+func AnchorSweepWitness(witnessScript *script.Script) ([][]byte, error) {
+	return witnessWithScript(witnessScript, []byte{})
+}
+
+// HTLCScript returns a witness script paying to receiverPubkey with the preimage of paymentHash,
+// or back to senderPubkey after the timeout. The timeout is an absolute locktime checked with
+// OP_CHECKLOCKTIMEVERIFY, or a relative one checked with OP_CHECKSEQUENCEVERIFY when relative is set.
+func HTLCScript(paymentHash, receiverPubkey, senderPubkey []byte, timeout uint32, relative bool) (*script.Script, error) {
+	if err := checkPubkey(receiverPubkey); err != nil {
+		return nil, err
+	}
+	if err := checkPubkey(senderPubkey); err != nil {
+		return nil, err
+	}
+
+	htlc, err := script.CreateHTLCScript(paymentHash, receiverPubkey, senderPubkey, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if relative {
+		for i, cmd := range *htlc {
+			if script.IsOp(cmd, 0xb1) {
+				(*htlc)[i] = []byte{0xb2}
+			}
+		}
+	}
+
+	return htlc, nil
+}
+
+// HTLCOutput returns the p2wsh output of an HTLC and its witness script
+func HTLCOutput(amount uint64, paymentHash, receiverPubkey, senderPubkey []byte, timeout uint32, relative bool) (*transaction.TxOut, *script.Script, error) {
+	witnessScript, err := HTLCScript(paymentHash, receiverPubkey, senderPubkey, timeout, relative)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txOut, err := p2wshOutput(amount, witnessScript)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return txOut, witnessScript, nil
+}
+
+// HTLCSuccessWitness returns the witness with which the receiver claims an HTLC with the preimage
+func HTLCSuccessWitness(sig, preimage []byte, witnessScript *script.Script) ([][]byte, error) {
+	// 0x01 selects the OP_IF branch, as required by MINIMALIF
+	return witnessWithScript(witnessScript, sig, preimage, []byte{0x01})
+}
+
+// HTLCTimeoutWitness returns the witness with which the sender takes an HTLC back after the timeout.
+// The spending transaction needs a locktime or input sequence that satisfies the timeout.
+func HTLCTimeoutWitness(sig []byte, witnessScript *script.Script) ([][]byte, error) {
+	// the empty element selects the OP_ELSE branch
+	return witnessWithScript(witnessScript, sig, []byte{})
+}
+
+func witnessWithScript(witnessScript *script.Script, items ...[]byte) ([][]byte, error) {
+	rawWitnessScript, err := witnessScript.RawSerialize()
+	if err != nil {
+		return nil, err
+	}
+	return append(items, rawWitnessScript), nil
+}
+
+func p2wshOutput(amount uint64, witnessScript *script.Script) (*transaction.TxOut, error) {
+	scriptPubkey, err := script.ScriptToP2WSH(witnessScript)
+	if err != nil {
+		return nil, err
+	}
+	return transaction.NewTxOut(amount, scriptPubkey), nil
+}
+
+func sortPubkeys(pubkey1, pubkey2 []byte) ([]byte, []byte) {
+	if bytes.Compare(pubkey1, pubkey2) > 0 {
+		return pubkey2, pubkey1
+	}
+	return pubkey1, pubkey2
+}
+
+// checkPubkey requires a compressed SEC pubkey, which is all segwit policy allows
+func checkPubkey(pubkey []byte) error {
+	if len(pubkey) != 33 || (pubkey[0] != 0x02 && pubkey[0] != 0x03) {
+		return fmt.Errorf("expected a 33 byte compressed pubkey, got %x", pubkey)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package lightning
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+var testZ = big.NewInt(0x1234567890)
+
+func testKey(t *testing.T, secret int64) (*signatureverification.PrivateKey, []byte) {
+	t.Helper()
+	key, err := signatureverification.NewPrivateKey(big.NewInt(secret))
+	if err != nil {
+		t.Fatalf("NewPrivateKey error: %v", err)
+	}
+	return key, key.Point.Serialize(true)
+}
+
+func sign(t *testing.T, key *signatureverification.PrivateKey) []byte {
+	t.Helper()
+	sig, err := key.Sign(testZ)
+	if err != nil {
+		t.Fatalf("Sign error: %v", err)
+	}
+	return append(sig.Serialize(), byte(transaction.SigHashAll))
+}
+
+// executeWitness runs the witness script, the last witness item, on the other items
+func executeWitness(t *testing.T, witness [][]byte, ctx *script.ExecutionContext) (bool, error) {
+	t.Helper()
+	witnessScript, err := script.ParseRawScript(witness[len(witness)-1])
+	if err != nil {
+		t.Fatalf("ParseRawScript error: %v", err)
+	}
+	ctx.Stack = append(script.Stack{}, witness[:len(witness)-1]...)
+	ctx.Z = testZ
+	ctx.Flags = script.StandardVerifyFlags | script.ScriptVerifyMinimalIf
+	return witnessScript.Execute(ctx)
+}
+
+func checkP2WSH(t *testing.T, txOut *transaction.TxOut, witnessScript *script.Script) {
+	t.Helper()
+	raw, _ := witnessScript.RawSerialize()
+	if !txOut.ScriptPubkey.IsP2WSHScriptPubKey() || !bytes.Equal((*txOut.ScriptPubkey)[1], utils.Sha256Hash(raw)) {
+		t.Errorf("Output is not the p2wsh of its witness script")
+	}
+}
+
+func TestFundingOutput(t *testing.T) {
+	key1, pubkey1 := testKey(t, 111)
+	key2, pubkey2 := testKey(t, 222)
+
+	txOut, witnessScript, err := FundingOutput(1000000, pubkey1, pubkey2)
+	if err != nil {
+		t.Fatalf("FundingOutput error: %v", err)
+	}
+	checkP2WSH(t, txOut, witnessScript)
+
+	// Both sides build the same script whatever order they pass the keys in
+	swapped, _ := FundingScript(pubkey2, pubkey1)
+	a, _ := witnessScript.RawSerialize()
+	b, _ := swapped.RawSerialize()
+	if !bytes.Equal(a, b) {
+		t.Errorf("Funding script depends on the order of the keys")
+	}
+
+	witness, err := FundingWitness(pubkey2, sign(t, key2), pubkey1, sign(t, key1))
+	if err != nil {
+		t.Fatalf("FundingWitness error: %v", err)
+	}
+	if ok, err := executeWitness(t, witness, &script.ExecutionContext{}); !ok || err != nil {
+		t.Errorf("Funding witness does not satisfy the script: %v", err)
+	}
+
+	if _, err := FundingScript(pubkey1, key2.Point.Serialize(false)); err == nil {
+		t.Errorf("Uncompressed keys should be rejected")
+	}
+}
+
+func TestAnchorOutput(t *testing.T) {
+	key, pubkey := testKey(t, 333)
+
+	txOut, witnessScript, err := AnchorOutput(pubkey)
+	if err != nil {
+		t.Fatalf("AnchorOutput error: %v", err)
+	}
+	if txOut.Amount != AnchorOutputValue {
+		t.Errorf("Anchor amount = %d, want %d", txOut.Amount, AnchorOutputValue)
+	}
+	checkP2WSH(t, txOut, witnessScript)
+
+	witness, _ := AnchorWitness(sign(t, key), witnessScript)
+	if ok, err := executeWitness(t, witness, &script.ExecutionContext{}); !ok || err != nil {
+		t.Errorf("Anchor witness does not satisfy the script: %v", err)
+	}
+
+	// Without a signature the branch with the relative timelock is taken
+	sweep, _ := AnchorSweepWitness(witnessScript)
+	ctx := &script.ExecutionContext{Version: 2, Sequence: 16}
+	if ok, err := executeWitness(t, sweep, ctx); !ok || err != nil {
+		t.Errorf("Anchor sweep witness does not satisfy the script: %v", err)
+	}
+}
+
+func TestHTLC(t *testing.T) {
+	receiver, receiverPubkey := testKey(t, 444)
+	sender, senderPubkey := testKey(t, 555)
+	preimage := []byte("lightning preimage for the test!")
+	paymentHash := utils.Sha256Hash(preimage)
+
+	txOut, witnessScript, err := HTLCOutput(50000, paymentHash, receiverPubkey, senderPubkey, 800000, false)
+	if err != nil {
+		t.Fatalf("HTLCOutput error: %v", err)
+	}
+	checkP2WSH(t, txOut, witnessScript)
+
+	success, _ := HTLCSuccessWitness(sign(t, receiver), preimage, witnessScript)
+	if ok, err := executeWitness(t, success, &script.ExecutionContext{}); !ok || err != nil {
+		t.Errorf("Success witness does not satisfy the script: %v", err)
+	}
+
+	wrongPreimage, _ := HTLCSuccessWitness(sign(t, receiver), []byte("wrong"), witnessScript)
+	if ok, _ := executeWitness(t, wrongPreimage, &script.ExecutionContext{}); ok {
+		t.Errorf("Success witness with the wrong preimage should fail")
+	}
+
+	timeout, _ := HTLCTimeoutWitness(sign(t, sender), witnessScript)
+	if ok, err := executeWitness(t, timeout, &script.ExecutionContext{Locktime: 800001, Sequence: 0xfffffffe}); !ok || err != nil {
+		t.Errorf("Timeout witness does not satisfy the script after the locktime: %v", err)
+	}
+	if ok, _ := executeWitness(t, timeout, &script.ExecutionContext{Locktime: 799999, Sequence: 0xfffffffe}); ok {
+		t.Errorf("Timeout witness should fail before the locktime")
+	}
+
+	// The sender cannot use the success branch
+	stolen, _ := HTLCSuccessWitness(sign(t, sender), preimage, witnessScript)
+	if ok, _ := executeWitness(t, stolen, &script.ExecutionContext{}); ok {
+		t.Errorf("Success branch should require the receiver's signature")
+	}
+
+	relative, err := HTLCScript(paymentHash, receiverPubkey, senderPubkey, 144, true)
+	if err != nil {
+		t.Fatalf("HTLCScript error: %v", err)
+	}
+	if !bytes.Equal((*relative)[7], []byte{0xb2}) {
+		t.Errorf("Relative timeout should use OP_CHECKSEQUENCEVERIFY")
+	}
+
+	if _, err := HTLCScript(paymentHash[:20], receiverPubkey, senderPubkey, 800000, false); err == nil {
+		t.Errorf("Payment hash of the wrong length should be rejected")
+	}
+}
@@ -0,0 +1,142 @@
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// MerkleParent takes the binary hashes and calculates the hash256 of their concatenation
+func MerkleParent(hash1, hash2 []byte) []byte {
+	return utils.Hash256(append(append([]byte{}, hash1...), hash2...))
+}
+
+// MerkleParentLevel takes a list of binary hashes and returns a list that's half the length.
+// When the list has an odd number of hashes, the last one is paired with itself.
+func MerkleParentLevel(hashes [][]byte) ([][]byte, error) {
+	if len(hashes) < 2 {
+		return nil, fmt.Errorf("cannot take a parent level with only %d hashes", len(hashes))
+	}
+
+	if len(hashes)%2 == 1 {
+		hashes = append(hashes[:len(hashes):len(hashes)], hashes[len(hashes)-1])
+	}
+
+	parentLevel := make([][]byte, 0, len(hashes)/2)
+	for i := 0; i < len(hashes); i += 2 {
+		parentLevel = append(parentLevel, MerkleParent(hashes[i], hashes[i+1]))
+	}
+
+	return parentLevel, nil
+}
+
+// ParallelMerkleParentLevel is MerkleParentLevel with the pairs hashed by workers goroutines, GOMAXPROCS when workers is below one
+func ParallelMerkleParentLevel(hashes [][]byte, workers int) ([][]byte, error) {
+	if len(hashes) < 2 {
+		return nil, fmt.Errorf("cannot take a parent level with only %d hashes", len(hashes))
+	}
+	pairs := make([][]byte, 0, (len(hashes)+1)/2)
+	for i := 0; i < len(hashes); i += 2 {
+		// the last hash of an odd level is paired with itself
+		right := hashes[min(i+1, len(hashes)-1)]
+		pairs = append(pairs, append(append(make([]byte, 0, len(hashes[i])+len(right)), hashes[i]...), right...))
+	}
+	return utils.Hash256Batch(pairs, workers), nil
+}
+
+// ParallelMerkleRoot is MerkleRoot with every level hashed by ParallelMerkleParentLevel
+func ParallelMerkleRoot(hashes [][]byte, workers int) ([]byte, error) {
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("cannot take the merkle root of no hashes")
+	}
+	currentLevel := hashes
+	for len(currentLevel) > 1 {
+		var err error
+		currentLevel, err = ParallelMerkleParentLevel(currentLevel, workers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return currentLevel[0], nil
+}
+
+// MerkleRoot takes a list of binary hashes and returns the merkle root
+func MerkleRoot(hashes [][]byte) ([]byte, error) {
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("cannot take the merkle root of no hashes")
+	}
+
+	currentLevel := hashes
+	for len(currentLevel) > 1 {
+		var err error
+		currentLevel, err = MerkleParentLevel(currentLevel)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return currentLevel[0], nil
+}
+
+// MerkleBranch returns the sibling hashes on the path from the hash at index up to the root
+func MerkleBranch(hashes [][]byte, index int) ([][]byte, error) {
+	if index < 0 || index >= len(hashes) {
+		return nil, fmt.Errorf("index %d out of range for %d hashes", index, len(hashes))
+	}
+
+	var branch [][]byte
+	currentLevel := hashes
+	for len(currentLevel) > 1 {
+		sibling := index ^ 1
+		if sibling >= len(currentLevel) {
+			// the last hash of an odd level is paired with itself
+			sibling = index
+		}
+		branch = append(branch, currentLevel[sibling])
+
+		var err error
+		currentLevel, err = MerkleParentLevel(currentLevel)
+		if err != nil {
+			return nil, err
+		}
+		index /= 2
+	}
+
+	return branch, nil
+}
+
+// BranchRoot returns the root that leaf at index hashes up to with branch
+func BranchRoot(leaf []byte, branch [][]byte, index int) []byte {
+	current := leaf
+	for _, sibling := range branch {
+		if index%2 == 0 {
+			current = MerkleParent(current, sibling)
+		} else {
+			current = MerkleParent(sibling, current)
+		}
+		index /= 2
+	}
+	return current
+}
+
+// VerifyBranch returns whether leaf at index is committed to by root through branch
+func VerifyBranch(leaf []byte, branch [][]byte, index int, root []byte) bool {
+	return index >= 0 && index>>len(branch) == 0 && bytes.Equal(BranchRoot(leaf, branch, index), root)
+}
+
+// Proof is a merkle branch together with the index of the leaf it proves, hashes in internal byte order
+type Proof struct {
+	Branch [][]byte
+	Index  int
+}
+
+// Root returns the root leaf hashes up to with the proof
+func (p *Proof) Root(leaf []byte) []byte {
+	return BranchRoot(leaf, p.Branch, p.Index)
+}
+
+// Verify returns whether the proof shows that leaf is committed to by root
+func (p *Proof) Verify(leaf, root []byte) bool {
+	return VerifyBranch(leaf, p.Branch, p.Index, root)
+}
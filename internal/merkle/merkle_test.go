@@ -0,0 +1,168 @@
+package merkle
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func decodeHashes(hexHashes []string) [][]byte {
+	hashes := make([][]byte, len(hexHashes))
+	for i, hexHash := range hexHashes {
+		hashes[i], _ = hex.DecodeString(hexHash)
+	}
+	return hashes
+}
+
+func TestMerkleParent(t *testing.T) {
+	hashes := decodeHashes([]string{
+		"c117ea8ec828342f4dfb0ad6bd140e03a50720ece40169ee38bdc15d9eb64cf5",
+		"c131474164b412e3406696da1ee20ab0fc9bf41c8f05fa8ceea7a08d672d7cc5",
+	})
+	want := "8b30c5ba100f6f2e5ad1e2a742e5020491240f8eb514fe97c713c31718ad7ecd"
+
+	if have := hex.EncodeToString(MerkleParent(hashes[0], hashes[1])); have != want {
+		t.Errorf("MerkleParent = %s, want %s", have, want)
+	}
+}
+
+func TestMerkleParentLevel(t *testing.T) {
+	hashes := decodeHashes([]string{
+		"c117ea8ec828342f4dfb0ad6bd140e03a50720ece40169ee38bdc15d9eb64cf5",
+		"c131474164b412e3406696da1ee20ab0fc9bf41c8f05fa8ceea7a08d672d7cc5",
+		"f391da6ecfeed1814efae39e7fcb3838ae0b02c02ae7d0a5848a66947c0727b0",
+		"3d238a92a94532b946c90e19c49351c763696cff3db400485b813aecb8a13181",
+		"10092f2633be5f3ce349bf9ddbde36caa3dd10dfa0ec8106bce23acbff637dae",
+		"7d37b3d54fa6a64869084bfd2e831309118b9e833610e6228adacdbd1b4ba161",
+		"8118a77e542892fe15ae3fc771a4abfd2f5d5d5997544c3487ac36b5c85170fc",
+		"dff6879848c2c9b62fe652720b8df5272093acfaa45a43cdb3696fe2466a3877",
+		"b825c0745f46ac58f7d3759e6dc535a1fec7820377f24d4c2c6ad2cc55c0cb59",
+		"95513952a04bd8992721e9b7e2937f1c04ba31e0469fbe615a78197f68f52b7c",
+		"2e6d722e5e4dbdf2447ddecc9f7dabb8e299bae921c99ad5b0184cd9eb8e5908",
+	})
+	want := []string{
+		"8b30c5ba100f6f2e5ad1e2a742e5020491240f8eb514fe97c713c31718ad7ecd",
+		"7f4e6f9e224e20fda0ae4c44114237f97cd35aca38d83081c9bfd41feb907800",
+		"ade48f2bbb57318cc79f3a8678febaa827599c509dce5940602e54c7733332e7",
+		"68b3e2ab8182dfd646f13fdf01c335cf32476482d963f5cd94e934e6b3401069",
+		"43e7274e77fbe8e5a42a8fb58f7decdb04d521f319f332d88e6b06f8e6c09e27",
+		"1796cd3ca4fef00236e07b723d3ed88e1ac433acaaa21da64c4b33c946cf3d10",
+	}
+
+	level, err := MerkleParentLevel(hashes)
+	if err != nil {
+		t.Fatalf("MerkleParentLevel error: %v", err)
+	}
+	if len(level) != len(want) {
+		t.Fatalf("Expected %d hashes, got %d", len(want), len(level))
+	}
+	for i := range want {
+		if have := hex.EncodeToString(level[i]); have != want[i] {
+			t.Errorf("Hash %d = %s, want %s", i, have, want[i])
+		}
+	}
+}
+
+func TestMerkleRoot(t *testing.T) {
+	hashes := decodeHashes([]string{
+		"c117ea8ec828342f4dfb0ad6bd140e03a50720ece40169ee38bdc15d9eb64cf5",
+		"c131474164b412e3406696da1ee20ab0fc9bf41c8f05fa8ceea7a08d672d7cc5",
+		"f391da6ecfeed1814efae39e7fcb3838ae0b02c02ae7d0a5848a66947c0727b0",
+		"3d238a92a94532b946c90e19c49351c763696cff3db400485b813aecb8a13181",
+		"10092f2633be5f3ce349bf9ddbde36caa3dd10dfa0ec8106bce23acbff637dae",
+		"7d37b3d54fa6a64869084bfd2e831309118b9e833610e6228adacdbd1b4ba161",
+		"8118a77e542892fe15ae3fc771a4abfd2f5d5d5997544c3487ac36b5c85170fc",
+		"dff6879848c2c9b62fe652720b8df5272093acfaa45a43cdb3696fe2466a3877",
+		"b825c0745f46ac58f7d3759e6dc535a1fec7820377f24d4c2c6ad2cc55c0cb59",
+		"95513952a04bd8992721e9b7e2937f1c04ba31e0469fbe615a78197f68f52b7c",
+		"2e6d722e5e4dbdf2447ddecc9f7dabb8e299bae921c99ad5b0184cd9eb8e5908",
+		"b13a750047bc0bdceb2473e5fe488c2596d7a7124b4e716fdd29b046ef99bbf0",
+	})
+	want := "acbcab8bcc1af95d8d563b77d24c3d19b18f1486383d75a5085c4e86c86beed6"
+
+	root, err := MerkleRoot(hashes)
+	if err != nil {
+		t.Fatalf("MerkleRoot error: %v", err)
+	}
+	if have := hex.EncodeToString(root); have != want {
+		t.Errorf("MerkleRoot = %s, want %s", have, want)
+	}
+
+	for index := range hashes {
+		branch, err := MerkleBranch(hashes, index)
+		if err != nil {
+			t.Fatalf("MerkleBranch(%d) error: %v", index, err)
+		}
+		if !VerifyBranch(hashes[index], branch, index, root) {
+			t.Errorf("Branch of hash %d does not verify", index)
+		}
+		if VerifyBranch(hashes[index], branch, index^1, root) {
+			t.Errorf("Branch of hash %d verifies at the wrong index", index)
+		}
+	}
+
+	// a single hash is its own root
+	root, _ = MerkleRoot(hashes[:1])
+	if !bytes.Equal(root, hashes[0]) {
+		t.Errorf("Expected a single hash to be the root")
+	}
+}
+
+func testLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = utils.Hash256([]byte{byte(i), byte(i >> 8)})
+	}
+	return leaves
+}
+
+func TestParallelMerkleRoot(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 255, 256, 257, 1001} {
+		leaves := testLeaves(n)
+		want, err := MerkleRoot(leaves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, workers := range []int{0, 1, 4} {
+			got, err := ParallelMerkleRoot(leaves, workers)
+			if err != nil || !bytes.Equal(got, want) {
+				t.Errorf("ParallelMerkleRoot of %d leaves with %d workers = %x, %v, want %x", n, workers, got, err, want)
+			}
+		}
+	}
+	if _, err := ParallelMerkleRoot(nil, 0); err == nil {
+		t.Error("ParallelMerkleRoot of no hashes should fail")
+	}
+}
+
+func TestProof(t *testing.T) {
+	leaves := testLeaves(8)
+	root, _ := MerkleRoot(leaves)
+	for i, leaf := range leaves {
+		branch, _ := MerkleBranch(leaves, i)
+		proof := &Proof{Branch: branch, Index: i}
+		if !bytes.Equal(proof.Root(leaf), root) || !proof.Verify(leaf, root) {
+			t.Errorf("Proof of leaf %d does not verify", i)
+		}
+		if wrong := (&Proof{Branch: branch, Index: i ^ 1}); wrong.Verify(leaf, root) {
+			t.Errorf("Proof of leaf %d verifies at index %d", i, i^1)
+		}
+	}
+}
+
+// BenchmarkMerkleRoot hashes the tree of a block with 4000 transactions
+func BenchmarkMerkleRoot(b *testing.B) {
+	leaves := testLeaves(4000)
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			MerkleRoot(leaves)
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ParallelMerkleRoot(leaves, 0)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package monitor
+
+import (
+	"encoding/hex"
+	"sort"
+	"sync"
+
+	"github.com/caspereijkens/cryptocurrency/internal/events"
+)
+
+// HeaderChain is a chain of validated headers, such as a block.HeaderStore
+type HeaderChain interface {
+	Height() uint32
+	HashAt(height uint32) ([]byte, error)
+}
+
+// Confirmation is the block a transaction confirmed in and how deep that block is in the header chain
+type Confirmation struct {
+	TxID        string
+	BlockHash   string
+	BlockHeight uint32
+	// Confirmations is zero while the chain has not reached BlockHeight and when the block was orphaned
+	Confirmations uint32
+	// Orphaned tells that the chain has another block at BlockHeight
+	Orphaned bool
+}
+
+// ConfirmationTracker counts the confirmations of transactions in the headers of a chain it validated itself,
+// whichever backend reported the block they confirmed in. Every new tip the confirming blocks are checked
+// against the chain again, and a transaction whose block was orphaned drops to zero confirmations until it is
+// confirmed again. It implements events.Events, so it can be notified of new blocks by network.SyncHeaders.
+type ConfirmationTracker struct {
+	events.NoEvents
+
+	Chain HeaderChain
+	// OnOrphaned, when set, is called by Update for every transaction whose block left the chain
+	OnOrphaned func(confirmation Confirmation)
+
+	mu            sync.Mutex
+	confirmations map[string]*Confirmation
+}
+
+func NewConfirmationTracker(chain HeaderChain) *ConfirmationTracker {
+	return &ConfirmationTracker{Chain: chain, confirmations: make(map[string]*Confirmation)}
+}
+
+// Confirmed records that the transaction with txID confirmed in the block with blockHash, hex in display byte
+// order, at blockHeight and returns how that block stands in the chain. A later call replaces the block.
+func (t *ConfirmationTracker) Confirmed(txID, blockHash string, blockHeight uint32) Confirmation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	confirmation := &Confirmation{TxID: txID, BlockHash: blockHash, BlockHeight: blockHeight}
+	t.confirmations[txID] = confirmation
+	t.check(confirmation)
+	return *confirmation
+}
+
+// Forget stops tracking the transaction with txID
+func (t *ConfirmationTracker) Forget(txID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.confirmations, txID)
+}
+
+// Confirmation returns the confirmation of the transaction with txID as of the last Update
+func (t *ConfirmationTracker) Confirmation(txID string) (Confirmation, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	confirmation, ok := t.confirmations[txID]
+	if !ok {
+		return Confirmation{}, false
+	}
+	return *confirmation, true
+}
+
+// Update checks the tracked transactions against the current tip of the chain and returns the ones whose block
+// was orphaned since the previous check, sorted by txid
+func (t *ConfirmationTracker) Update() []Confirmation {
+	t.mu.Lock()
+	var orphaned []Confirmation
+	for _, confirmation := range t.confirmations {
+		wasOrphaned := confirmation.Orphaned
+		t.check(confirmation)
+		if confirmation.Orphaned && !wasOrphaned {
+			orphaned = append(orphaned, *confirmation)
+		}
+	}
+	t.mu.Unlock()
+
+	sort.Slice(orphaned, func(i, j int) bool { return orphaned[i].TxID < orphaned[j].TxID })
+	if t.OnOrphaned != nil {
+		for _, confirmation := range orphaned {
+			t.OnOrphaned(confirmation)
+		}
+	}
+	return orphaned
+}
+
+// OnBlockConnected updates the confirmations for the new tip
+func (t *ConfirmationTracker) OnBlockConnected(hash string, height uint32) {
+	t.Update()
+}
+
+// OnBlockDisconnected updates the confirmations for the tip the chain fell back to
+func (t *ConfirmationTracker) OnBlockDisconnected(hash string, height uint32) {
+	t.Update()
+}
+
+// check sets the confirmations from whether the chain has the block of confirmation at its height
+func (t *ConfirmationTracker) check(confirmation *Confirmation) {
+	confirmation.Confirmations, confirmation.Orphaned = 0, false
+
+	tip := t.Chain.Height()
+	if confirmation.BlockHeight > tip {
+		return
+	}
+	hash, err := t.Chain.HashAt(confirmation.BlockHeight)
+	if err != nil {
+		return
+	}
+	if hex.EncodeToString(hash) != confirmation.BlockHash {
+		confirmation.Orphaned = true
+		return
+	}
+	confirmation.Confirmations = tip - confirmation.BlockHeight + 1
+}
@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/events"
+)
+
+// the tracker can be passed to network.SyncHeaders
+var _ events.Events = (*ConfirmationTracker)(nil)
+
+// fakeChain is a header chain of made up hashes that tests can reorg
+type fakeChain struct {
+	hashes [][]byte
+}
+
+func (c *fakeChain) Height() uint32 {
+	return uint32(len(c.hashes) - 1)
+}
+
+func (c *fakeChain) HashAt(height uint32) ([]byte, error) {
+	if int(height) >= len(c.hashes) {
+		return nil, fmt.Errorf("no header at height %d", height)
+	}
+	return c.hashes[height], nil
+}
+
+// extend adds blocks named after the fork and their height
+func (c *fakeChain) extend(fork byte, n int) {
+	for i := 0; i < n; i++ {
+		c.hashes = append(c.hashes, []byte{fork, byte(len(c.hashes))})
+	}
+}
+
+func TestConfirmationTracker(t *testing.T) {
+	chain := &fakeChain{}
+	chain.extend(0xaa, 3)
+
+	tracker := NewConfirmationTracker(chain)
+	var orphanedByCallback []Confirmation
+	tracker.OnOrphaned = func(confirmation Confirmation) { orphanedByCallback = append(orphanedByCallback, confirmation) }
+
+	if got := tracker.Confirmed(fundTx, "aa01", 1); got.Confirmations != 2 || got.Orphaned {
+		t.Errorf("got %+v, want 2 confirmations", got)
+	}
+	// a block the chain has not reached yet
+	if got := tracker.Confirmed("future", "aa05", 5); got.Confirmations != 0 || got.Orphaned {
+		t.Errorf("got %+v, want no confirmations before the chain reaches the block", got)
+	}
+
+	chain.extend(0xaa, 3)
+	tracker.OnBlockConnected("aa05", 5)
+	if got, _ := tracker.Confirmation(fundTx); got.Confirmations != 5 {
+		t.Errorf("got %d confirmations, want 5", got.Confirmations)
+	}
+	if got, _ := tracker.Confirmation("future"); got.Confirmations != 1 {
+		t.Errorf("got %d confirmations, want 1 once the chain reached the block", got.Confirmations)
+	}
+
+	// blocks 1 and up are replaced by another fork
+	chain.hashes = chain.hashes[:1]
+	chain.extend(0xbb, 6)
+	orphaned := tracker.Update()
+	if len(orphaned) != 2 || orphaned[0].TxID != fundTx || orphaned[1].TxID != "future" {
+		t.Fatalf("got orphaned %+v, want both transactions", orphaned)
+	}
+	if len(orphanedByCallback) != 2 {
+		t.Errorf("got %d calls of OnOrphaned, want 2", len(orphanedByCallback))
+	}
+	if got, _ := tracker.Confirmation(fundTx); got.Confirmations != 0 || !got.Orphaned {
+		t.Errorf("got %+v, want an orphaned confirmation without confirmations", got)
+	}
+	if orphaned := tracker.Update(); len(orphaned) != 0 {
+		t.Errorf("got orphaned %+v again, want them reported once", orphaned)
+	}
+
+	// the transaction confirms again on the new fork
+	if got := tracker.Confirmed(fundTx, "bb03", 3); got.Confirmations != 4 || got.Orphaned {
+		t.Errorf("got %+v, want 4 confirmations in the new block", got)
+	}
+
+	tracker.Forget(fundTx)
+	if _, ok := tracker.Confirmation(fundTx); ok {
+		t.Errorf("a forgotten transaction should not be tracked")
+	}
+}
@@ -0,0 +1,344 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/events"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+const (
+	// DefaultInterval is the time between two polls of Run
+	DefaultInterval = 30 * time.Second
+	// DefaultMinRequestInterval keeps the requests to a public API well below its rate limits
+	DefaultMinRequestInterval = 250 * time.Millisecond
+	// DefaultDepth is the number of confirmations after which a transaction is considered final
+	DefaultDepth = 6
+)
+
+// Backend provides the address histories and the chain tip the monitor polls, such as an esplora.Backend
+type Backend interface {
+	FetchAddressTxs(address string, testnet bool) ([]*transaction.AddressTx, error)
+	FetchStatus(txID string, testnet bool) (*transaction.TxStatus, error)
+	FetchTipHeight(testnet bool) (uint32, error)
+}
+
+// EventKind tells what happened to a transaction of a watched address
+type EventKind int
+
+const (
+	// EventUnconfirmed is sent when a transaction is first seen unconfirmed
+	EventUnconfirmed EventKind = iota
+	// EventConfirmed is sent when a transaction confirms, and again whenever its number
+	// of confirmations grows until it reaches the depth of the monitor
+	EventConfirmed
+	// EventReorged is sent when a confirmed transaction is no longer in the block it was
+	// confirmed in. An EventConfirmed follows when it is confirmed in another block.
+	EventReorged
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventUnconfirmed:
+		return "unconfirmed"
+	case EventConfirmed:
+		return "confirmed"
+	case EventReorged:
+		return "reorged"
+	}
+	return "unknown"
+}
+
+// Event is a change in the status of a transaction of a watched address
+type Event struct {
+	Kind    EventKind
+	Address string
+	Tx      *transaction.AddressTx
+	// Received is what the transaction adds to the balance of the address, negative when it spends from it
+	Received      int64
+	Confirmations uint32
+	// BlockHash and BlockHeight are the block the transaction is confirmed in, for EventReorged the block it left
+	BlockHash   string
+	BlockHeight uint32
+}
+
+// Monitor polls the histories of a set of addresses and calls Handler for their new transactions
+// and changes in their confirmations. Requests to the backend are spaced by MinRequestInterval.
+type Monitor struct {
+	Testnet bool
+	Backend Backend
+	Handler func(event Event)
+	// Events, when set, receives the events of Handler as mempool and confirmed transactions, the blocks
+	// transactions were reorged out of and the balances of the addresses whenever they change
+	Events events.Events
+	// ErrorHandler, when set, receives the errors of the polls of Run
+	ErrorHandler func(err error)
+
+	Interval           time.Duration
+	MinRequestInterval time.Duration
+	// Depth is the number of confirmations up to which transactions are followed:
+	// confirmation events are sent and they are re-checked for reorgs
+	Depth uint32
+
+	mu          sync.Mutex
+	addresses   []string
+	tracked     map[trackedKey]*trackedTx
+	balances    map[string]int64
+	orphaned    map[string]bool
+	tip         uint32
+	lastRequest time.Time
+}
+
+type trackedKey struct {
+	address string
+	txID    string
+}
+
+type trackedTx struct {
+	tx            *transaction.AddressTx
+	confirmed     bool
+	blockHash     string
+	blockHeight   uint32
+	confirmations uint32
+}
+
+func NewMonitor(backend Backend, testnet bool, handler func(event Event)) *Monitor {
+	return &Monitor{
+		Testnet:            testnet,
+		Backend:            backend,
+		Handler:            handler,
+		Interval:           DefaultInterval,
+		MinRequestInterval: DefaultMinRequestInterval,
+		Depth:              DefaultDepth,
+		tracked:            make(map[trackedKey]*trackedTx),
+		balances:           make(map[string]int64),
+		orphaned:           make(map[string]bool),
+	}
+}
+
+// Watch adds addresses to the set that is polled
+func (m *Monitor) Watch(addresses ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, address := range addresses {
+		if !m.watching(address) {
+			m.addresses = append(m.addresses, address)
+		}
+	}
+}
+
+// WatchScript watches the address of a standard ScriptPubKey
+func (m *Monitor) WatchScript(scriptPubkey *script.Script) error {
+	address, err := transaction.NewTxOut(0, scriptPubkey).Address(m.Testnet)
+	if err != nil {
+		return err
+	}
+	m.Watch(address)
+	return nil
+}
+
+func (m *Monitor) watching(address string) bool {
+	for _, watched := range m.addresses {
+		if watched == address {
+			return true
+		}
+	}
+	return false
+}
+
+// Run polls every Interval until ctx is done
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Poll(ctx); err != nil && ctx.Err() == nil && m.ErrorHandler != nil {
+			m.ErrorHandler(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll checks every watched address once and calls Handler for what changed since the previous poll
+func (m *Monitor) Poll(ctx context.Context) error {
+	m.mu.Lock()
+	addresses := append([]string{}, m.addresses...)
+	m.mu.Unlock()
+
+	if err := m.throttle(ctx); err != nil {
+		return err
+	}
+	tip, err := m.Backend.FetchTipHeight(m.Testnet)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.tip = tip
+	m.mu.Unlock()
+
+	for _, address := range addresses {
+		if err := m.throttle(ctx); err != nil {
+			return err
+		}
+		txs, err := m.Backend.FetchAddressTxs(address, m.Testnet)
+		if err != nil {
+			return err
+		}
+
+		present := make(map[string]bool, len(txs))
+		var events []Event
+		m.mu.Lock()
+		for _, tx := range txs {
+			present[tx.TxID] = true
+			events = append(events, m.update(address, tx, tx.Status, tip)...)
+		}
+		missing := m.recentlyConfirmed(address, present)
+		m.mu.Unlock()
+
+		// confirmed transactions that dropped out of the history may have been reorged out of the chain
+		for _, tracked := range missing {
+			if err := m.throttle(ctx); err != nil {
+				return err
+			}
+			status, err := m.Backend.FetchStatus(tracked.tx.TxID, m.Testnet)
+			if err != nil {
+				return err
+			}
+			m.mu.Lock()
+			events = append(events, m.update(address, tracked.tx, *status, tip)...)
+			m.mu.Unlock()
+		}
+
+		if m.Handler != nil {
+			for _, event := range events {
+				m.Handler(event)
+			}
+		}
+		m.notify(address, events)
+	}
+
+	return nil
+}
+
+// update records the status of a transaction of address and returns the events for what changed
+func (m *Monitor) update(address string, tx *transaction.AddressTx, status transaction.TxStatus, tip uint32) []Event {
+	key := trackedKey{address, tx.TxID}
+	tracked, known := m.tracked[key]
+	if !known {
+		tracked = &trackedTx{}
+		m.tracked[key] = tracked
+	}
+	tracked.tx = tx
+
+	event := func(kind EventKind) Event {
+		return Event{
+			Kind:          kind,
+			Address:       address,
+			Tx:            tx,
+			Received:      tx.Received(address),
+			Confirmations: tracked.confirmations,
+			BlockHash:     tracked.blockHash,
+			BlockHeight:   tracked.blockHeight,
+		}
+	}
+
+	var events []Event
+	if known && tracked.confirmed && (!status.Confirmed || status.BlockHash != tracked.blockHash) {
+		tracked.confirmed, tracked.confirmations = false, 0
+		events = append(events, event(EventReorged))
+	}
+
+	if !status.Confirmed {
+		if !known {
+			events = append(events, event(EventUnconfirmed))
+		}
+		return events
+	}
+
+	confirmations := uint32(0)
+	if tip >= status.BlockHeight {
+		confirmations = tip - status.BlockHeight + 1
+	}
+	if !tracked.confirmed || (confirmations > tracked.confirmations && tracked.confirmations < m.Depth) {
+		tracked.confirmed = true
+		tracked.blockHash, tracked.blockHeight = status.BlockHash, status.BlockHeight
+		tracked.confirmations = min(confirmations, max(m.Depth, 1))
+		events = append(events, event(EventConfirmed))
+	}
+	return events
+}
+
+// notify passes the events of address to Events, followed by its balance when that changed
+func (m *Monitor) notify(address string, polled []Event) {
+	if m.Events == nil {
+		return
+	}
+	for _, event := range polled {
+		switch event.Kind {
+		case EventUnconfirmed:
+			m.Events.OnTxMempool(address, event.Tx)
+		case EventConfirmed:
+			m.Events.OnTxConfirmed(address, event.Tx, event.Confirmations)
+		case EventReorged:
+			// the block is reported once, however many transactions it held
+			m.mu.Lock()
+			orphaned := m.orphaned[event.BlockHash]
+			m.orphaned[event.BlockHash] = true
+			m.mu.Unlock()
+			if !orphaned {
+				m.Events.OnBlockDisconnected(event.BlockHash, event.BlockHeight)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	balance := int64(0)
+	for key, tracked := range m.tracked {
+		if key.address == address {
+			balance += tracked.tx.Received(address)
+		}
+	}
+	changed := balance != m.balances[address]
+	m.balances[address] = balance
+	m.mu.Unlock()
+	if changed {
+		m.Events.OnBalanceChanged(address, balance)
+	}
+}
+
+// recentlyConfirmed returns the confirmed transactions of address below the depth that are not in present
+func (m *Monitor) recentlyConfirmed(address string, present map[string]bool) []*trackedTx {
+	var missing []*trackedTx
+	for key, tracked := range m.tracked {
+		if key.address == address && !present[key.txID] && tracked.confirmed && tracked.confirmations < m.Depth {
+			missing = append(missing, tracked)
+		}
+	}
+	return missing
+}
+
+// throttle waits until MinRequestInterval has passed since the previous request
+func (m *Monitor) throttle(ctx context.Context) error {
+	m.mu.Lock()
+	wait := time.Until(m.lastRequest.Add(m.MinRequestInterval))
+	m.lastRequest = time.Now().Add(max(wait, 0))
+	m.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,287 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/esplora"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+const (
+	watched = "mzx5YhAH9kNHtcN481u6WkjeHjYtVeKVh2"
+	fundTx  = "452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03"
+)
+
+// fakeBackend serves a chain state the tests change between polls
+type fakeBackend struct {
+	mu       sync.Mutex
+	tip      uint32
+	history  map[string][]*transaction.AddressTx
+	statuses map[string]transaction.TxStatus
+	requests int
+}
+
+// the Esplora backend has to satisfy Backend even though its fetchers both look up the tip
+var _ Backend = (*esplora.Backend)(nil)
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{history: make(map[string][]*transaction.AddressTx), statuses: make(map[string]transaction.TxStatus)}
+}
+
+func (b *fakeBackend) FetchAddressTxs(address string, testnet bool) ([]*transaction.AddressTx, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	var txs []*transaction.AddressTx
+	for _, tx := range b.history[address] {
+		copied := *tx
+		copied.Status = b.statuses[tx.TxID]
+		txs = append(txs, &copied)
+	}
+	return txs, nil
+}
+
+func (b *fakeBackend) FetchStatus(txID string, testnet bool) (*transaction.TxStatus, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	status := b.statuses[txID]
+	return &status, nil
+}
+
+func (b *fakeBackend) FetchTipHeight(testnet bool) (uint32, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	return b.tip, nil
+}
+
+func (b *fakeBackend) set(tip uint32, txID string, status transaction.TxStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tip = tip
+	b.statuses[txID] = status
+}
+
+func payment(txID, address string, value uint64) *transaction.AddressTx {
+	return &transaction.AddressTx{TxID: txID, Vout: []transaction.AddressTxOut{{Address: address, Value: value}}}
+}
+
+func confirmedIn(height uint32) transaction.TxStatus {
+	return transaction.TxStatus{Confirmed: true, BlockHeight: height, BlockHash: fmt.Sprintf("block%d", height)}
+}
+
+func TestMonitorEvents(t *testing.T) {
+	backend := newFakeBackend()
+	backend.history[watched] = []*transaction.AddressTx{payment(fundTx, watched, 5000)}
+
+	var events []Event
+	m := NewMonitor(backend, true, func(event Event) { events = append(events, event) })
+	m.MinRequestInterval = 0
+	m.Depth = 3
+	m.Watch(watched, watched)
+
+	poll := func() []Event {
+		t.Helper()
+		events = nil
+		if err := m.Poll(context.Background()); err != nil {
+			t.Fatalf("Poll failed: %v", err)
+		}
+		return events
+	}
+	expect := func(got []Event, kinds ...EventKind) {
+		t.Helper()
+		if len(got) != len(kinds) {
+			t.Fatalf("got %d events %v, want %v", len(got), got, kinds)
+		}
+		for i, kind := range kinds {
+			if got[i].Kind != kind {
+				t.Errorf("event %d is %v, want %v", i, got[i].Kind, kind)
+			}
+		}
+	}
+
+	backend.set(100, fundTx, transaction.TxStatus{})
+	got := poll()
+	expect(got, EventUnconfirmed)
+	if got[0].Address != watched || got[0].Received != 5000 || got[0].Tx.TxID != fundTx {
+		t.Errorf("unexpected event %+v", got[0])
+	}
+	expect(poll())
+
+	backend.set(101, fundTx, confirmedIn(101))
+	got = poll()
+	expect(got, EventConfirmed)
+	if got[0].Confirmations != 1 {
+		t.Errorf("got %d confirmations, want 1", got[0].Confirmations)
+	}
+
+	backend.set(102, fundTx, confirmedIn(101))
+	got = poll()
+	expect(got, EventConfirmed)
+	if got[0].Confirmations != 2 {
+		t.Errorf("got %d confirmations, want 2", got[0].Confirmations)
+	}
+
+	// the block is reorged out and the transaction is back in the mempool
+	backend.set(102, fundTx, transaction.TxStatus{})
+	expect(poll(), EventReorged)
+
+	// it confirms in another block
+	backend.set(103, fundTx, confirmedIn(103))
+	got = poll()
+	expect(got, EventConfirmed)
+	if got[0].Confirmations != 1 {
+		t.Errorf("got %d confirmations, want 1", got[0].Confirmations)
+	}
+
+	// confirmations are reported up to the depth only
+	backend.set(107, fundTx, confirmedIn(103))
+	got = poll()
+	expect(got, EventConfirmed)
+	if got[0].Confirmations != 3 {
+		t.Errorf("got %d confirmations, want 3", got[0].Confirmations)
+	}
+	backend.set(108, fundTx, confirmedIn(103))
+	expect(poll())
+}
+
+// eventRecorder records what a monitor passes to Events
+type eventRecorder struct {
+	got []string
+}
+
+func (r *eventRecorder) OnBlockConnected(hash string, height uint32) {
+	r.got = append(r.got, fmt.Sprintf("connected %s %d", hash, height))
+}
+
+func (r *eventRecorder) OnBlockDisconnected(hash string, height uint32) {
+	r.got = append(r.got, fmt.Sprintf("disconnected %s %d", hash, height))
+}
+
+func (r *eventRecorder) OnTxMempool(address string, tx *transaction.AddressTx) {
+	r.got = append(r.got, fmt.Sprintf("mempool %s", tx.TxID[:8]))
+}
+
+func (r *eventRecorder) OnTxConfirmed(address string, tx *transaction.AddressTx, confirmations uint32) {
+	r.got = append(r.got, fmt.Sprintf("confirmed %s %d", tx.TxID[:8], confirmations))
+}
+
+func (r *eventRecorder) OnBalanceChanged(address string, balance int64) {
+	r.got = append(r.got, fmt.Sprintf("balance %d", balance))
+}
+
+func TestMonitorNotifiesEvents(t *testing.T) {
+	const spendTx = "9e067aedc661fca148e13953df75f8ca6eada9ce3b3d8d68631769ac60999156"
+	backend := newFakeBackend()
+	backend.history[watched] = []*transaction.AddressTx{payment(fundTx, watched, 5000)}
+
+	recorder := &eventRecorder{}
+	m := NewMonitor(backend, true, nil)
+	m.Events = recorder
+	m.MinRequestInterval = 0
+	m.Watch(watched)
+
+	poll := func(want ...string) {
+		t.Helper()
+		recorder.got = nil
+		if err := m.Poll(context.Background()); err != nil {
+			t.Fatalf("Poll failed: %v", err)
+		}
+		if fmt.Sprint(recorder.got) != fmt.Sprint(want) {
+			t.Errorf("got events %q, want %q", recorder.got, want)
+		}
+	}
+
+	backend.set(100, fundTx, transaction.TxStatus{})
+	poll("mempool 452c629d", "balance 5000")
+	poll()
+
+	backend.set(101, fundTx, confirmedIn(101))
+	poll("confirmed 452c629d 1")
+
+	// a spend lowers the balance
+	spend := &transaction.AddressTx{TxID: spendTx, Vin: []transaction.AddressTxIn{{Prevout: &transaction.AddressTxOut{Address: watched, Value: 5000}}}}
+	backend.history[watched] = append(backend.history[watched], spend)
+	backend.set(101, spendTx, confirmedIn(101))
+	poll("confirmed 9e067aed 1", "balance 0")
+
+	// block 101 is reorged out, it is reported once for both transactions
+	backend.set(101, fundTx, transaction.TxStatus{})
+	backend.set(101, spendTx, transaction.TxStatus{})
+	poll("disconnected block101 101")
+}
+
+func TestMonitorRechecksMissingTransactions(t *testing.T) {
+	backend := newFakeBackend()
+	backend.history[watched] = []*transaction.AddressTx{payment(fundTx, watched, 5000)}
+	backend.set(101, fundTx, confirmedIn(101))
+
+	var events []Event
+	m := NewMonitor(backend, true, func(event Event) { events = append(events, event) })
+	m.MinRequestInterval = 0
+	m.Watch(watched)
+
+	if err := m.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Kind != EventConfirmed {
+		t.Fatalf("got events %v, want a single confirmed event", events)
+	}
+
+	// the transaction drops out of the history and its block is replaced
+	backend.history[watched] = nil
+	backend.set(101, fundTx, transaction.TxStatus{})
+	events = nil
+	if err := m.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Kind != EventReorged || events[0].Tx.TxID != fundTx {
+		t.Fatalf("got events %v, want a single reorged event", events)
+	}
+}
+
+func TestMonitorRateLimit(t *testing.T) {
+	backend := newFakeBackend()
+	m := NewMonitor(backend, true, nil)
+	m.MinRequestInterval = 20 * time.Millisecond
+	m.Watch(watched, "mnrVtF8DWjMu839VW3rBfgYaAfKk8983Xf")
+
+	start := time.Now()
+	if err := m.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	// the tip and two addresses, the first request goes out right away
+	if backend.requests != 3 {
+		t.Errorf("got %d requests, want 3", backend.requests)
+	}
+	if elapsed := time.Since(start); elapsed < 2*m.MinRequestInterval {
+		t.Errorf("three requests took %v, want at least %v", elapsed, 2*m.MinRequestInterval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Poll(ctx); err == nil {
+		t.Error("expected a cancelled poll to fail")
+	}
+}
+
+func TestWatchScript(t *testing.T) {
+	m := NewMonitor(newFakeBackend(), true, nil)
+	h160 := []byte{0xd5, 0x2a, 0xd7, 0xca, 0x9b, 0x3d, 0x09, 0x6a, 0x38, 0xe7, 0x52, 0xc2, 0x01, 0x8e, 0x6f, 0xbc, 0x40, 0xcd, 0xf2, 0x6f}
+	if err := m.WatchScript(script.CreateP2pkhScript(h160)); err != nil {
+		t.Fatalf("WatchScript failed: %v", err)
+	}
+	if len(m.addresses) != 1 || m.addresses[0] == "" {
+		t.Errorf("got watched addresses %v", m.addresses)
+	}
+
+	if err := m.WatchScript(&script.Script{[]byte{0x6a}}); err == nil {
+		t.Error("expected an OP_RETURN script to have no address")
+	}
+}
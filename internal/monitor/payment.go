@@ -0,0 +1,69 @@
+package monitor
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+// WaitForPayment watches address and polls every Interval until an output paying at least amount to it
+// has minConf confirmations, and returns that output. With minConf 0 an unconfirmed payment is accepted.
+// Payments with more confirmations than the depth of the monitor are not re-checked for reorgs.
+// Errors of the polls go to ErrorHandler and are retried, only the end of ctx stops the wait.
+func (m *Monitor) WaitForPayment(ctx context.Context, address string, amount uint64, minConf uint32) (*transaction.OutPoint, error) {
+	m.Watch(address)
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Poll(ctx); err != nil && ctx.Err() == nil && m.ErrorHandler != nil {
+			m.ErrorHandler(err)
+		}
+		if outPoint, ok := m.payment(address, amount, minConf); ok {
+			return outPoint, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// payment returns the output of the tracked transactions that pays at least amount to address and has
+// at least minConf confirmations. The one with the most confirmations is picked, then the lowest txid.
+func (m *Monitor) payment(address string, amount uint64, minConf uint32) (*transaction.OutPoint, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var best *transaction.OutPoint
+	bestConfirmations := uint32(0)
+	for key, tracked := range m.tracked {
+		if key.address != address {
+			continue
+		}
+		confirmations := uint32(0)
+		if tracked.confirmed && m.tip >= tracked.blockHeight {
+			confirmations = m.tip - tracked.blockHeight + 1
+		}
+		if confirmations < minConf {
+			continue
+		}
+		if best != nil && (confirmations < bestConfirmations || (confirmations == bestConfirmations && key.txID > best.TxidString())) {
+			continue
+		}
+		txid, err := hex.DecodeString(key.txID)
+		if err != nil || len(txid) != 32 {
+			continue
+		}
+		for index, txOut := range tracked.tx.Vout {
+			if txOut.Address == address && txOut.Value >= amount {
+				outPoint := transaction.NewOutPoint(txid, uint32(index))
+				best, bestConfirmations = &outPoint, confirmations
+				break
+			}
+		}
+	}
+	return best, best != nil
+}
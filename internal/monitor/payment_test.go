@@ -0,0 +1,80 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+const smallTx = "d1c789a9c60383bf715f3f6ad9d14b91fe55f3deb369fe5d9280cb1a01793f81"
+
+// miningBackend mines a block on every tip request and confirms the payment in block 101
+type miningBackend struct {
+	*fakeBackend
+}
+
+func (b *miningBackend) FetchTipHeight(testnet bool) (uint32, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tip++
+	if b.tip >= 101 {
+		b.statuses[fundTx] = confirmedIn(101)
+		b.statuses[smallTx] = confirmedIn(101)
+	}
+	return b.tip, nil
+}
+
+func newMiningMonitor() *Monitor {
+	backend := &miningBackend{newFakeBackend()}
+	backend.tip = 99
+	backend.history[watched] = []*transaction.AddressTx{payment(smallTx, watched, 4000), payment(fundTx, watched, 5000)}
+	m := NewMonitor(backend, true, nil)
+	m.Interval = time.Millisecond
+	m.MinRequestInterval = 0
+	return m
+}
+
+func TestWaitForPayment(t *testing.T) {
+	m := newMiningMonitor()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	outPoint, err := m.WaitForPayment(ctx, watched, 5000, 3)
+	if err != nil {
+		t.Fatalf("WaitForPayment failed: %v", err)
+	}
+	if outPoint.DisplayString() != fundTx+":0" {
+		t.Errorf("got outpoint %s, want %s:0", outPoint.DisplayString(), fundTx)
+	}
+	if m.tip != 103 {
+		t.Errorf("payment accepted at tip %d, want 103", m.tip)
+	}
+}
+
+func TestWaitForUnconfirmedPayment(t *testing.T) {
+	m := newMiningMonitor()
+	outPoint, err := m.WaitForPayment(context.Background(), watched, 4000, 0)
+	if err != nil {
+		t.Fatalf("WaitForPayment failed: %v", err)
+	}
+	if m.tip != 100 {
+		t.Errorf("payment accepted at tip %d, want 100", m.tip)
+	}
+	// both payments are large enough, the lowest txid wins the tie
+	if outPoint.DisplayString() != fundTx+":0" {
+		t.Errorf("got outpoint %s, want %s:0", outPoint.DisplayString(), fundTx)
+	}
+}
+
+func TestWaitForPaymentTimeout(t *testing.T) {
+	m := newMiningMonitor()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := m.WaitForPayment(ctx, watched, 6000, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,168 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+)
+
+const (
+	// BanScore is the score at which a peer is not selected anymore
+	BanScore = -10
+	// Score lost for a failed connection or request
+	failurePenalty = 1
+	// Score gained for a useful response
+	successReward = 1
+)
+
+// DefaultPort returns the P2P port of the network
+func DefaultPort(testnet bool) int {
+	return params.ForNetwork(testnet).DefaultPort
+}
+
+// DNSSeeds returns the DNS seeds that resolve to peers of the network
+func DNSSeeds(testnet bool) []string {
+	return params.ForNetwork(testnet).DNSSeeds
+}
+
+// Resolver returns the IP addresses of a host name, like net.LookupHost
+type Resolver func(host string) ([]string, error)
+
+// ResolveSeeds looks up the DNS seeds and returns the host:port addresses of the peers they point to.
+// It only fails when none of the seeds resolve. A nil resolver uses net.LookupHost.
+func ResolveSeeds(testnet bool, resolve Resolver) ([]string, error) {
+	if resolve == nil {
+		resolve = net.LookupHost
+	}
+
+	var addresses []string
+	var lastErr error
+	port := strconv.Itoa(DefaultPort(testnet))
+	for _, seed := range DNSSeeds(testnet) {
+		ips, err := resolve(seed)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, ip := range ips {
+			addresses = append(addresses, net.JoinHostPort(ip, port))
+		}
+	}
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no DNS seed resolved: %v", lastErr)
+	}
+	return addresses, nil
+}
+
+// PeerInfo is what the address manager knows about a peer
+type PeerInfo struct {
+	Address     string
+	Score       int
+	Attempts    int
+	LastSuccess time.Time
+}
+
+// AddrMan keeps the known peer addresses and scores them by how they behave.
+// It is safe for concurrent use.
+type AddrMan struct {
+	mu    sync.Mutex
+	peers map[string]*PeerInfo
+}
+
+func NewAddrMan() *AddrMan {
+	return &AddrMan{peers: make(map[string]*PeerInfo)}
+}
+
+// Add adds addresses that are not known yet
+func (a *AddrMan) Add(addresses ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, address := range addresses {
+		if _, ok := a.peers[address]; !ok {
+			a.peers[address] = &PeerInfo{Address: address}
+		}
+	}
+}
+
+// Good records a useful response from the peer
+func (a *AddrMan) Good(address string) {
+	a.update(address, func(peer *PeerInfo) {
+		peer.Attempts++
+		peer.Score += successReward
+		peer.LastSuccess = time.Now()
+	})
+}
+
+// Failed records a failed connection or request to the peer
+func (a *AddrMan) Failed(address string) {
+	a.update(address, func(peer *PeerInfo) {
+		peer.Attempts++
+		peer.Score -= failurePenalty
+	})
+}
+
+// Misbehaved bans a peer that sent invalid or conflicting data
+func (a *AddrMan) Misbehaved(address string) {
+	a.update(address, func(peer *PeerInfo) {
+		peer.Attempts++
+		peer.Score = min(peer.Score, BanScore)
+	})
+}
+
+func (a *AddrMan) update(address string, change func(peer *PeerInfo)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	peer, ok := a.peers[address]
+	if !ok {
+		peer = &PeerInfo{Address: address}
+		a.peers[address] = peer
+	}
+	change(peer)
+}
+
+// Peer returns what is known about the peer at address
+func (a *AddrMan) Peer(address string) (PeerInfo, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	peer, ok := a.peers[address]
+	if !ok {
+		return PeerInfo{}, false
+	}
+	return *peer, true
+}
+
+// Select returns up to n addresses that are not banned, the highest scores first.
+// Peers with the same score are ordered by the fewest attempts, so new peers get tried.
+func (a *AddrMan) Select(n int) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	candidates := make([]*PeerInfo, 0, len(a.peers))
+	for _, peer := range a.peers {
+		if peer.Score > BanScore {
+			candidates = append(candidates, peer)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		if candidates[i].Attempts != candidates[j].Attempts {
+			return candidates[i].Attempts < candidates[j].Attempts
+		}
+		return candidates[i].Address < candidates[j].Address
+	})
+
+	addresses := make([]string, 0, min(n, len(candidates)))
+	for _, peer := range candidates[:min(n, len(candidates))] {
+		addresses = append(addresses, peer.Address)
+	}
+	return addresses
+}
@@ -0,0 +1,68 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestResolveSeeds(t *testing.T) {
+	resolve := func(host string) ([]string, error) {
+		if host == "seed.bitcoin.sipa.be" {
+			return []string{"192.0.2.1", "2001:db8::1"}, nil
+		}
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+
+	addresses, err := ResolveSeeds(false, resolve)
+	if err != nil {
+		t.Fatalf("ResolveSeeds error: %v", err)
+	}
+	want := []string{"192.0.2.1:8333", "[2001:db8::1]:8333"}
+	if len(addresses) != len(want) || addresses[0] != want[0] || addresses[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, addresses)
+	}
+
+	failing := func(host string) ([]string, error) {
+		return nil, fmt.Errorf("no network")
+	}
+	if _, err := ResolveSeeds(true, failing); err == nil {
+		t.Errorf("Expected an error when no seed resolves")
+	}
+}
+
+func TestAddrManSelect(t *testing.T) {
+	addrman := NewAddrMan()
+	addrman.Add("a:8333", "b:8333", "c:8333", "d:8333")
+
+	addrman.Good("b:8333")
+	addrman.Good("b:8333")
+	addrman.Good("c:8333")
+	addrman.Failed("d:8333")
+	addrman.Misbehaved("a:8333")
+
+	selected := addrman.Select(10)
+	want := []string{"b:8333", "c:8333", "d:8333"}
+	if len(selected) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, selected)
+	}
+	for i := range want {
+		if selected[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, selected)
+		}
+	}
+
+	if selected := addrman.Select(1); len(selected) != 1 || selected[0] != "b:8333" {
+		t.Errorf("Expected the best peer only, got %v", selected)
+	}
+
+	peer, ok := addrman.Peer("b:8333")
+	if !ok || peer.Score != 2 || peer.Attempts != 2 || peer.LastSuccess.IsZero() {
+		t.Errorf("Unexpected peer info %+v", peer)
+	}
+
+	// adding a known address keeps its score
+	addrman.Add("a:8333")
+	if peer, _ := addrman.Peer("a:8333"); peer.Score > BanScore {
+		t.Errorf("Expected a to stay banned, got score %d", peer.Score)
+	}
+}
@@ -0,0 +1,342 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+const (
+	// ProtocolVersion is the P2P protocol version we speak
+	ProtocolVersion = uint32(70015)
+	// DefaultUserAgent is sent in our version messages
+	DefaultUserAgent = "/cryptocurrency:0.1/"
+	// MaxHeadersPerMessage is the most headers a peer sends in reply to getheaders
+	MaxHeadersPerMessage = 2000
+)
+
+// Message is a P2P message that can be put in an envelope
+type Message interface {
+	Command() []byte
+	Serialize() ([]byte, error)
+}
+
+// VersionMessage starts the handshake with a peer
+type VersionMessage struct {
+	Version          uint32
+	Services         uint64
+	Timestamp        uint64
+	ReceiverServices uint64
+	ReceiverIP       [4]byte
+	ReceiverPort     uint16
+	SenderServices   uint64
+	SenderIP         [4]byte
+	SenderPort       uint16
+	Nonce            [8]byte
+	UserAgent        string
+	LatestBlock      uint32
+	Relay            bool
+}
+
+// NewVersionMessage returns a version message with the current time and a random nonce
+func NewVersionMessage(testnet bool) *VersionMessage {
+	msg := &VersionMessage{
+		Version:      ProtocolVersion,
+		Timestamp:    uint64(time.Now().Unix()),
+		ReceiverPort: uint16(DefaultPort(testnet)),
+		SenderPort:   uint16(DefaultPort(testnet)),
+		UserAgent:    DefaultUserAgent,
+	}
+	rand.Read(msg.Nonce[:])
+	return msg
+}
+
+func (m *VersionMessage) Command() []byte {
+	return []byte("version")
+}
+
+func (m *VersionMessage) Serialize() ([]byte, error) {
+	result := binary.LittleEndian.AppendUint32(nil, m.Version)
+	result = binary.LittleEndian.AppendUint64(result, m.Services)
+	result = binary.LittleEndian.AppendUint64(result, m.Timestamp)
+
+	result = binary.LittleEndian.AppendUint64(result, m.ReceiverServices)
+	result = appendAddress(result, m.ReceiverIP, m.ReceiverPort)
+	result = binary.LittleEndian.AppendUint64(result, m.SenderServices)
+	result = appendAddress(result, m.SenderIP, m.SenderPort)
+
+	result = append(result, m.Nonce[:]...)
+
+	userAgentLength, err := utils.EncodeVarint(uint64(len(m.UserAgent)))
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, userAgentLength...)
+	result = append(result, m.UserAgent...)
+
+	result = binary.LittleEndian.AppendUint32(result, m.LatestBlock)
+	if m.Relay {
+		result = append(result, 0x01)
+	} else {
+		result = append(result, 0x00)
+	}
+
+	return result, nil
+}
+
+// ParseVersionMessage reads the version message of a peer
+func ParseVersionMessage(payload []byte) (*VersionMessage, error) {
+	reader := bufio.NewReader(bytes.NewReader(payload))
+	m := &VersionMessage{}
+
+	fields := []any{&m.Version, &m.Services, &m.Timestamp, &m.ReceiverServices}
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return nil, fmt.Errorf("truncated version message: %v", err)
+		}
+	}
+	if err := readAddress(reader, &m.ReceiverIP, &m.ReceiverPort); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &m.SenderServices); err != nil {
+		return nil, fmt.Errorf("truncated version message: %v", err)
+	}
+	if err := readAddress(reader, &m.SenderIP, &m.SenderPort); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(reader, m.Nonce[:]); err != nil {
+		return nil, fmt.Errorf("truncated version message: %v", err)
+	}
+
+	userAgentLength, err := utils.ReadVarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("truncated version message: %v", err)
+	}
+	if userAgentLength > MaxPayloadSize {
+		return nil, fmt.Errorf("user agent of %d bytes is too long", userAgentLength)
+	}
+	userAgent := make([]byte, userAgentLength)
+	if _, err := io.ReadFull(reader, userAgent); err != nil {
+		return nil, fmt.Errorf("truncated version message: %v", err)
+	}
+	m.UserAgent = string(userAgent)
+
+	if err := binary.Read(reader, binary.LittleEndian, &m.LatestBlock); err != nil {
+		return nil, fmt.Errorf("truncated version message: %v", err)
+	}
+
+	// relay is optional, peers before BIP37 leave it out
+	relay, err := reader.ReadByte()
+	m.Relay = err == nil && relay != 0
+
+	return m, nil
+}
+
+// appendAddress appends an IPv4 address as an IPv4-mapped IPv6 address and a big endian port
+func appendAddress(result []byte, ip [4]byte, port uint16) []byte {
+	result = append(result, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff)
+	result = append(result, ip[:]...)
+	return binary.BigEndian.AppendUint16(result, port)
+}
+
+func readAddress(reader io.Reader, ip *[4]byte, port *uint16) error {
+	address := make([]byte, 18)
+	if _, err := io.ReadFull(reader, address); err != nil {
+		return fmt.Errorf("truncated version message: %v", err)
+	}
+	copy(ip[:], address[12:16])
+	*port = binary.BigEndian.Uint16(address[16:])
+	return nil
+}
+
+// VerAckMessage acknowledges the version message of a peer
+type VerAckMessage struct{}
+
+func (m *VerAckMessage) Command() []byte {
+	return []byte("verack")
+}
+
+func (m *VerAckMessage) Serialize() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// PingMessage checks that a peer is still there
+type PingMessage struct {
+	Nonce [8]byte
+}
+
+func (m *PingMessage) Command() []byte {
+	return []byte("ping")
+}
+
+func (m *PingMessage) Serialize() ([]byte, error) {
+	return append([]byte{}, m.Nonce[:]...), nil
+}
+
+// PongMessage answers a ping with the same nonce
+type PongMessage struct {
+	Nonce [8]byte
+}
+
+func (m *PongMessage) Command() []byte {
+	return []byte("pong")
+}
+
+func (m *PongMessage) Serialize() ([]byte, error) {
+	return append([]byte{}, m.Nonce[:]...), nil
+}
+
+// GetHeadersMessage asks for the headers after the first block of Locator the peer has, up to EndBlock or
+// MaxHeadersPerMessage. The hashes are in display byte order, an EndBlock of zeros asks for as many as possible.
+type GetHeadersMessage struct {
+	Version  uint32
+	Locator  [][]byte
+	EndBlock []byte
+}
+
+// NewGetHeadersMessage asks for the headers after the first block of locator the peer has, such as the locator of
+// block.HeaderStore, until endBlock. A nil endBlock asks for headers until the tip of the peer.
+func NewGetHeadersMessage(locator [][]byte, endBlock []byte) *GetHeadersMessage {
+	if endBlock == nil {
+		endBlock = make([]byte, 32)
+	}
+	return &GetHeadersMessage{
+		Version:  ProtocolVersion,
+		Locator:  locator,
+		EndBlock: endBlock,
+	}
+}
+
+func (m *GetHeadersMessage) Command() []byte {
+	return []byte("getheaders")
+}
+
+func (m *GetHeadersMessage) Serialize() ([]byte, error) {
+	if len(m.Locator) > block.MaxLocatorHashes {
+		return nil, fmt.Errorf("locator of %d hashes exceeds the maximum of %d", len(m.Locator), block.MaxLocatorHashes)
+	}
+	result := binary.LittleEndian.AppendUint32(nil, m.Version)
+
+	numHashes, err := utils.EncodeVarint(uint64(len(m.Locator)))
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, numHashes...)
+
+	// the hashes go over the wire little endian
+	for _, hash := range append(slices.Clone(m.Locator), m.EndBlock) {
+		if len(hash) != 32 {
+			return nil, fmt.Errorf("block hash %x is not 32 bytes", hash)
+		}
+		reversed := slices.Clone(hash)
+		slices.Reverse(reversed)
+		result = append(result, reversed...)
+	}
+	return result, nil
+}
+
+// ParseGetHeadersMessage reads a getheaders message
+func ParseGetHeadersMessage(payload []byte) (*GetHeadersMessage, error) {
+	reader := bufio.NewReader(bytes.NewReader(payload))
+
+	var version [4]byte
+	if _, err := io.ReadFull(reader, version[:]); err != nil {
+		return nil, err
+	}
+	numHashes, err := utils.ReadVarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if numHashes > block.MaxLocatorHashes {
+		return nil, fmt.Errorf("locator of %d hashes exceeds the maximum of %d", numHashes, block.MaxLocatorHashes)
+	}
+
+	m := &GetHeadersMessage{Version: binary.LittleEndian.Uint32(version[:])}
+	for i := 0; i <= int(numHashes); i++ {
+		hash := make([]byte, 32)
+		if _, err := io.ReadFull(reader, hash); err != nil {
+			return nil, fmt.Errorf("block hash %d: %v", i, err)
+		}
+		slices.Reverse(hash)
+		if i < int(numHashes) {
+			m.Locator = append(m.Locator, hash)
+		} else {
+			m.EndBlock = hash
+		}
+	}
+
+	if _, err := reader.Peek(1); err == nil {
+		return nil, fmt.Errorf("unexpected data after the end block")
+	}
+
+	return m, nil
+}
+
+// HeadersMessage holds the headers a peer sends in reply to getheaders
+type HeadersMessage struct {
+	Blocks []*block.Block
+}
+
+func (m *HeadersMessage) Command() []byte {
+	return []byte("headers")
+}
+
+func (m *HeadersMessage) Serialize() ([]byte, error) {
+	result, err := utils.EncodeVarint(uint64(len(m.Blocks)))
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range m.Blocks {
+		header, err := b.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		// every header is followed by a transaction count of zero
+		result = append(result, header...)
+		result = append(result, 0x00)
+	}
+	return result, nil
+}
+
+// ParseHeadersMessage reads a headers message
+func ParseHeadersMessage(payload []byte) (*HeadersMessage, error) {
+	reader := bufio.NewReader(bytes.NewReader(payload))
+
+	numHeaders, err := utils.ReadVarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if numHeaders > MaxHeadersPerMessage {
+		return nil, fmt.Errorf("headers message with %d headers exceeds the maximum of %d", numHeaders, MaxHeadersPerMessage)
+	}
+
+	blocks := make([]*block.Block, 0, numHeaders)
+	for i := 0; i < int(numHeaders); i++ {
+		b, err := block.Parse(reader)
+		if err != nil {
+			return nil, fmt.Errorf("header %d: %v", i, err)
+		}
+		numTxs, err := utils.ReadVarint(reader)
+		if err != nil {
+			return nil, fmt.Errorf("header %d: %v", i, err)
+		}
+		if numTxs != 0 {
+			return nil, fmt.Errorf("header %d has %d transactions, expected 0", i, numTxs)
+		}
+		blocks = append(blocks, b)
+	}
+
+	if _, err := reader.Peek(1); err == nil {
+		return nil, fmt.Errorf("unexpected data after the last header")
+	}
+
+	return &HeadersMessage{Blocks: blocks}, nil
+}
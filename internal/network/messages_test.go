@@ -0,0 +1,134 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+)
+
+func TestVersionMessageSerialize(t *testing.T) {
+	msg := &VersionMessage{
+		Version:      70015,
+		ReceiverPort: 8333,
+		SenderPort:   8333,
+		UserAgent:    "/programmingbitcoin:0.1/",
+	}
+	want := "7f11010000000000000000000000000000000000000000000000000000000000000000000000ffff00000000208d000000000000000000000000000000000000ffff00000000208d0000000000000000182f70726f6772616d6d696e67626974636f696e3a302e312f0000000000"
+
+	serialized, err := msg.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize error: %v", err)
+	}
+	if have := hex.EncodeToString(serialized); have != want {
+		t.Errorf("Expected %s, got %s", want, have)
+	}
+
+	parsed, err := ParseVersionMessage(serialized)
+	if err != nil {
+		t.Fatalf("ParseVersionMessage error: %v", err)
+	}
+	if *parsed != *msg {
+		t.Errorf("Expected %+v, got %+v", msg, parsed)
+	}
+}
+
+func TestParseRecordedVersionMessage(t *testing.T) {
+	raw, _ := hex.DecodeString(recordedVersion)
+
+	msg, err := ParseVersionMessage(raw[HeaderSize:])
+	if err != nil {
+		t.Fatalf("ParseVersionMessage error: %v", err)
+	}
+	if msg.Version != 70002 || msg.Services != 1 {
+		t.Errorf("Expected version 70002 with services 1, got %d with %d", msg.Version, msg.Services)
+	}
+	if msg.UserAgent != "/Satoshi:0.9.3/" {
+		t.Errorf("Expected user agent /Satoshi:0.9.3/, got %s", msg.UserAgent)
+	}
+	if msg.LatestBlock != 329167 || !msg.Relay {
+		t.Errorf("Expected latest block 329167 with relay, got %d with %v", msg.LatestBlock, msg.Relay)
+	}
+
+	if _, err := ParseVersionMessage(raw[HeaderSize : HeaderSize+50]); err == nil {
+		t.Errorf("Expected an error for a truncated version message")
+	}
+}
+
+func TestGetHeadersMessageSerialize(t *testing.T) {
+	startBlock, _ := hex.DecodeString("0000000000000000001237f46acddf58578a37e213d2a6edc4884a2fcad05ba3")
+	want := "7f11010001a35bd0ca2f4a88c4eda6d213e2378a5758dfcd6af437120000000000000000000000000000000000000000000000000000000000000000000000000000000000"
+
+	serialized, err := NewGetHeadersMessage([][]byte{startBlock}, nil).Serialize()
+	if err != nil {
+		t.Fatalf("Serialize error: %v", err)
+	}
+	if have := hex.EncodeToString(serialized); have != want {
+		t.Errorf("Expected %s, got %s", want, have)
+	}
+}
+
+func TestParseGetHeadersMessage(t *testing.T) {
+	locator := [][]byte{bytes.Repeat([]byte{0x02}, 32), bytes.Repeat([]byte{0x01}, 32)}
+	endBlock := bytes.Repeat([]byte{0x03}, 32)
+	serialized, err := NewGetHeadersMessage(locator, endBlock).Serialize()
+	if err != nil {
+		t.Fatalf("Serialize error: %v", err)
+	}
+
+	msg, err := ParseGetHeadersMessage(serialized)
+	if err != nil {
+		t.Fatalf("ParseGetHeadersMessage error: %v", err)
+	}
+	if msg.Version != ProtocolVersion || len(msg.Locator) != 2 || !bytes.Equal(msg.Locator[0], locator[0]) ||
+		!bytes.Equal(msg.Locator[1], locator[1]) || !bytes.Equal(msg.EndBlock, endBlock) {
+		t.Errorf("Expected the message that was serialized, got %+v", msg)
+	}
+
+	if _, err := ParseGetHeadersMessage(serialized[:len(serialized)-1]); err == nil {
+		t.Errorf("Expected an error for a truncated getheaders message")
+	}
+
+	tooLong := make([][]byte, block.MaxLocatorHashes+1)
+	for i := range tooLong {
+		tooLong[i] = make([]byte, 32)
+	}
+	if _, err := NewGetHeadersMessage(tooLong, nil).Serialize(); err == nil {
+		t.Errorf("Expected an error for a locator of %d hashes", len(tooLong))
+	}
+}
+
+func TestParseHeadersMessage(t *testing.T) {
+	raw, _ := hex.DecodeString("0200000020df3b053dc46f162a9b00c7f0d5124e2676d47bbe7c5d0793a500000000000000ef445fef2ed495c275892206ca533e7411907971013ab83e3b47bd0d692d14d4dc7c835b67d8001ac157e670000000002030eb2540c41025690160a1014c577061596e32e426b712c7ca00000000000000768b89f07044e6130ead292a3f51951adbd2202df447d98789339937fd006bd44880835b67d8001ade09204600")
+
+	msg, err := ParseHeadersMessage(raw)
+	if err != nil {
+		t.Fatalf("ParseHeadersMessage error: %v", err)
+	}
+	if len(msg.Blocks) != 2 {
+		t.Fatalf("Expected 2 headers, got %d", len(msg.Blocks))
+	}
+	for i, b := range msg.Blocks {
+		if !b.CheckPOW(true) {
+			t.Errorf("Header %d does not satisfy proof of work", i)
+		}
+	}
+
+	serialized, err := msg.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize error: %v", err)
+	}
+	if !bytes.Equal(serialized, raw) {
+		t.Errorf("Expected %x, got %x", raw, serialized)
+	}
+
+	if _, err := ParseHeadersMessage(append(raw, 0x00)); err == nil {
+		t.Errorf("Expected an error for trailing data")
+	}
+	withTxs := append([]byte{}, raw...)
+	withTxs[81] = 0x01
+	if _, err := ParseHeadersMessage(withTxs); err == nil {
+		t.Errorf("Expected an error for a header with transactions")
+	}
+}
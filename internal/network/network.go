@@ -1 +1,181 @@
 package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+const (
+	// MaxPayloadSize is the largest message payload accepted, as in Bitcoin Core
+	MaxPayloadSize = 4000000
+	// HeaderSize is the size of the envelope that precedes every payload
+	HeaderSize = 24
+)
+
+// NetworkEnvelope is a P2P message: a command and its payload
+type NetworkEnvelope struct {
+	Command []byte
+	Payload []byte
+	Testnet bool
+}
+
+func NewNetworkEnvelope(command, payload []byte, testnet bool) *NetworkEnvelope {
+	return &NetworkEnvelope{Command: command, Payload: payload, Testnet: testnet}
+}
+
+func (e *NetworkEnvelope) String() string {
+	return fmt.Sprintf("%s: %s", e.Command, hex.EncodeToString(e.Payload))
+}
+
+// Magic returns the network magic for the network of the envelope
+func Magic(testnet bool) []byte {
+	return params.ForNetwork(testnet).Magic
+}
+
+// ParseNetworkEnvelope reads a single message from reader, which may return it in partial reads
+func ParseNetworkEnvelope(reader io.Reader, testnet bool) (*NetworkEnvelope, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+
+	command, length, checksum, err := parseHeader(header, testnet)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(utils.Hash256(payload)[:4], checksum) {
+		return nil, fmt.Errorf("checksum mismatch for %s message", command)
+	}
+
+	return NewNetworkEnvelope(command, payload, testnet), nil
+}
+
+// Serialize returns the bytes of the envelope as sent over the network
+func (e *NetworkEnvelope) Serialize() ([]byte, error) {
+	if len(e.Command) > 12 {
+		return nil, fmt.Errorf("command %q is longer than 12 bytes", e.Command)
+	}
+	if len(e.Payload) > MaxPayloadSize {
+		return nil, fmt.Errorf("payload of %d bytes exceeds the maximum of %d", len(e.Payload), MaxPayloadSize)
+	}
+
+	result := make([]byte, 0, HeaderSize+len(e.Payload))
+	result = append(result, Magic(e.Testnet)...)
+
+	// command, padded with zeros to 12 bytes
+	command := make([]byte, 12)
+	copy(command, e.Command)
+	result = append(result, command...)
+
+	result = binary.LittleEndian.AppendUint32(result, uint32(len(e.Payload)))
+	result = append(result, utils.Hash256(e.Payload)[:4]...)
+	result = append(result, e.Payload...)
+
+	return result, nil
+}
+
+// parseHeader validates the 24 byte envelope header and returns the command, payload length and checksum
+func parseHeader(header []byte, testnet bool) ([]byte, uint32, []byte, error) {
+	if !bytes.Equal(header[:4], Magic(testnet)) {
+		return nil, 0, nil, fmt.Errorf("magic is not right %x vs %x", header[:4], Magic(testnet))
+	}
+
+	// the command is ascii, padded with zeros to 12 bytes and nothing after the padding
+	rawCommand := header[4:16]
+	end := bytes.IndexByte(rawCommand, 0)
+	if end < 0 {
+		end = len(rawCommand)
+	}
+	for _, b := range rawCommand[end:] {
+		if b != 0 {
+			return nil, 0, nil, fmt.Errorf("command %q is not padded with zeros", rawCommand)
+		}
+	}
+	for _, b := range rawCommand[:end] {
+		if b < 0x20 || b > 0x7e {
+			return nil, 0, nil, fmt.Errorf("command %q is not printable ascii", rawCommand)
+		}
+	}
+	command := append([]byte{}, rawCommand[:end]...)
+
+	length := binary.LittleEndian.Uint32(header[16:20])
+	if length > MaxPayloadSize {
+		return nil, 0, nil, fmt.Errorf("%s payload of %d bytes exceeds the maximum of %d", command, length, MaxPayloadSize)
+	}
+
+	return command, length, header[20:24], nil
+}
+
+// Decoder turns a stream of bytes arriving in arbitrary chunks into envelopes.
+// It waits for a full header, validates it before buffering the payload, and then waits for the payload.
+// After an error the stream is out of sync and the decoder must not be used anymore.
+type Decoder struct {
+	Testnet bool
+
+	buf      []byte
+	command  []byte
+	length   uint32
+	checksum []byte
+	inHeader bool
+	err      error
+}
+
+func NewDecoder(testnet bool) *Decoder {
+	return &Decoder{Testnet: testnet, inHeader: true}
+}
+
+// Feed adds data received from the peer and returns the envelopes that are now complete
+func (d *Decoder) Feed(data []byte) ([]*NetworkEnvelope, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	d.buf = append(d.buf, data...)
+
+	var envelopes []*NetworkEnvelope
+	for {
+		if d.inHeader {
+			if len(d.buf) < HeaderSize {
+				return envelopes, nil
+			}
+			command, length, checksum, err := parseHeader(d.buf[:HeaderSize], d.Testnet)
+			if err != nil {
+				d.err = err
+				return envelopes, err
+			}
+			d.command, d.length, d.checksum = command, length, append([]byte{}, checksum...)
+			d.buf = d.buf[HeaderSize:]
+			d.inHeader = false
+		}
+
+		if uint32(len(d.buf)) < d.length {
+			return envelopes, nil
+		}
+
+		payload := append([]byte{}, d.buf[:d.length]...)
+		d.buf = d.buf[d.length:]
+		d.inHeader = true
+
+		if !bytes.Equal(utils.Hash256(payload)[:4], d.checksum) {
+			d.err = fmt.Errorf("checksum mismatch for %s message", d.command)
+			return envelopes, d.err
+		}
+		envelopes = append(envelopes, NewNetworkEnvelope(d.command, payload, d.Testnet))
+	}
+}
+
+// Buffered returns the number of bytes received that are not part of a complete envelope yet
+func (d *Decoder) Buffered() int {
+	return len(d.buf)
+}
@@ -0,0 +1,167 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+)
+
+// A version and verack message recorded from a mainnet node
+const (
+	recordedVersion = "f9beb4d976657273696f6e0000000000650000005f1a69d2721101000100000000000000bc8f5e5400000000010000000000000000000000000000000000ffffc61b6409208d010000000000000000000000000000000000ffffcb0071c0208d128035cbc97953f80f2f5361746f7368693a302e392e332fcf05050001"
+	recordedVerAck  = "f9beb4d976657261636b000000000000000000005df6e0e2"
+)
+
+// oneByteReader returns one byte per Read, like a slow TCP connection
+type oneByteReader struct {
+	data []byte
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
+
+func TestParseNetworkEnvelope(t *testing.T) {
+	msg, _ := hex.DecodeString(recordedVerAck)
+	envelope, err := ParseNetworkEnvelope(bytes.NewReader(msg), false)
+	if err != nil {
+		t.Fatalf("ParseNetworkEnvelope error: %v", err)
+	}
+	if string(envelope.Command) != "verack" {
+		t.Errorf("Expected command verack, got %q", envelope.Command)
+	}
+	if len(envelope.Payload) != 0 {
+		t.Errorf("Expected an empty payload, got %x", envelope.Payload)
+	}
+
+	msg, _ = hex.DecodeString(recordedVersion)
+	envelope, err = ParseNetworkEnvelope(&oneByteReader{data: msg}, false)
+	if err != nil {
+		t.Fatalf("ParseNetworkEnvelope error: %v", err)
+	}
+	if string(envelope.Command) != "version" {
+		t.Errorf("Expected command version, got %q", envelope.Command)
+	}
+	if !bytes.Equal(envelope.Payload, msg[HeaderSize:]) {
+		t.Errorf("Expected payload %x, got %x", msg[HeaderSize:], envelope.Payload)
+	}
+}
+
+func TestNetworkEnvelopeSerialize(t *testing.T) {
+	for _, recorded := range []string{recordedVerAck, recordedVersion} {
+		msg, _ := hex.DecodeString(recorded)
+		envelope, err := ParseNetworkEnvelope(bytes.NewReader(msg), false)
+		if err != nil {
+			t.Fatalf("ParseNetworkEnvelope error: %v", err)
+		}
+		serialized, err := envelope.Serialize()
+		if err != nil {
+			t.Fatalf("Serialize error: %v", err)
+		}
+		if !bytes.Equal(serialized, msg) {
+			t.Errorf("Expected %x, got %x", msg, serialized)
+		}
+	}
+}
+
+func TestParseNetworkEnvelopeInvalid(t *testing.T) {
+	verack, _ := hex.DecodeString(recordedVerAck)
+	corrupt := func(offset int, value byte) []byte {
+		msg := append([]byte{}, verack...)
+		msg[offset] = value
+		return msg
+	}
+
+	tests := map[string][]byte{
+		"wrong magic":       corrupt(0, 0x0b),
+		"bad checksum":      corrupt(20, 0x00),
+		"garbage padding":   corrupt(15, 'x'),
+		"unprintable":       corrupt(4, 0x01),
+		"oversized payload": corrupt(19, 0x01),
+		"truncated":         verack[:10],
+	}
+
+	for name, msg := range tests {
+		if _, err := ParseNetworkEnvelope(bytes.NewReader(msg), false); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if _, err := ParseNetworkEnvelope(bytes.NewReader(verack), true); err == nil {
+		t.Errorf("Expected a mainnet message to be rejected on testnet")
+	}
+}
+
+func TestDecoderPartialReads(t *testing.T) {
+	version, _ := hex.DecodeString(recordedVersion)
+	verack, _ := hex.DecodeString(recordedVerAck)
+	stream := append(append([]byte{}, version...), verack...)
+
+	// every split of the stream into chunks of the same size decodes the same two messages
+	for chunkSize := 1; chunkSize <= len(stream); chunkSize += 7 {
+		decoder := NewDecoder(false)
+		var commands []string
+		for start := 0; start < len(stream); start += chunkSize {
+			envelopes, err := decoder.Feed(stream[start:min(start+chunkSize, len(stream))])
+			if err != nil {
+				t.Fatalf("Chunk size %d: Feed error: %v", chunkSize, err)
+			}
+			for _, envelope := range envelopes {
+				commands = append(commands, string(envelope.Command))
+			}
+		}
+		if len(commands) != 2 || commands[0] != "version" || commands[1] != "verack" {
+			t.Errorf("Chunk size %d: expected version and verack, got %v", chunkSize, commands)
+		}
+		if decoder.Buffered() != 0 {
+			t.Errorf("Chunk size %d: %d bytes left over", chunkSize, decoder.Buffered())
+		}
+	}
+}
+
+func TestDecoderRejectsOversizedPayloadEarly(t *testing.T) {
+	// a header announcing a payload over the limit fails before any of the payload arrives
+	envelope := NewNetworkEnvelope([]byte("block"), nil, false)
+	header, _ := envelope.Serialize()
+	header[16], header[17], header[18], header[19] = 0xff, 0xff, 0xff, 0xff
+
+	decoder := NewDecoder(false)
+	if _, err := decoder.Feed(header); err == nil {
+		t.Fatalf("Expected an error for an oversized payload")
+	}
+	if _, err := decoder.Feed([]byte{0x00}); err == nil {
+		t.Errorf("Expected the decoder to stay failed")
+	}
+}
+
+func FuzzDecoder(f *testing.F) {
+	version, _ := hex.DecodeString(recordedVersion)
+	verack, _ := hex.DecodeString(recordedVerAck)
+	f.Add(version)
+	f.Add(verack)
+	f.Add(append(append([]byte{}, verack...), version...))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		envelopes, _ := NewDecoder(false).Feed(data)
+		for _, envelope := range envelopes {
+			// whatever decodes, serializes back to the bytes it was decoded from
+			serialized, err := envelope.Serialize()
+			if err != nil {
+				t.Fatalf("Serialize error on decoded envelope: %v", err)
+			}
+			parsed, err := ParseNetworkEnvelope(bytes.NewReader(serialized), false)
+			if err != nil {
+				t.Fatalf("ParseNetworkEnvelope error on serialized envelope: %v", err)
+			}
+			if !bytes.Equal(parsed.Command, envelope.Command) || !bytes.Equal(parsed.Payload, envelope.Payload) {
+				t.Fatalf("Round trip changed the envelope")
+			}
+		}
+	})
+}
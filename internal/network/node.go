@@ -0,0 +1,157 @@
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+// DefaultTimeout bounds connecting to a peer and waiting for one of its messages
+const DefaultTimeout = 30 * time.Second
+
+// SimpleNode is a connection to a single peer
+type SimpleNode struct {
+	Testnet bool
+	Logging bool
+	Timeout time.Duration
+	// FeeFilter is the fee rate in satoshis per 1000 vbytes below which the peer does not want transactions
+	FeeFilter uint64
+
+	conn   net.Conn
+	reader *bufio.Reader
+	// announced holds the transactions we announced, by hash, to answer getdata from the peer
+	announced map[string]*transaction.Tx
+}
+
+// NewSimpleNode connects to the peer at host and port
+func NewSimpleNode(host string, port int, testnet, logging bool) (*SimpleNode, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewSimpleNodeWithConn(conn, testnet, logging), nil
+}
+
+// NewSimpleNodeWithConn uses an existing connection to a peer
+func NewSimpleNodeWithConn(conn net.Conn, testnet, logging bool) *SimpleNode {
+	return &SimpleNode{
+		Testnet:   testnet,
+		Logging:   logging,
+		Timeout:   DefaultTimeout,
+		conn:      conn,
+		reader:    bufio.NewReader(conn),
+		announced: make(map[string]*transaction.Tx),
+	}
+}
+
+// Addr returns the address of the peer
+func (n *SimpleNode) Addr() string {
+	return n.conn.RemoteAddr().String()
+}
+
+// Close closes the connection to the peer
+func (n *SimpleNode) Close() error {
+	return n.conn.Close()
+}
+
+// Handshake sends our version and waits for the peer to acknowledge it
+func (n *SimpleNode) Handshake() error {
+	if err := n.Send(NewVersionMessage(n.Testnet)); err != nil {
+		return err
+	}
+	_, err := n.WaitFor("verack")
+	return err
+}
+
+// Send puts the message in an envelope and sends it to the peer
+func (n *SimpleNode) Send(message Message) error {
+	payload, err := message.Serialize()
+	if err != nil {
+		return err
+	}
+	envelope := NewNetworkEnvelope(message.Command(), payload, n.Testnet)
+	if n.Logging {
+		fmt.Printf("sending: %s\n", envelope)
+	}
+
+	raw, err := envelope.Serialize()
+	if err != nil {
+		return err
+	}
+	n.conn.SetWriteDeadline(time.Now().Add(n.Timeout))
+	_, err = n.conn.Write(raw)
+	return err
+}
+
+// Read reads the next message from the peer
+func (n *SimpleNode) Read() (*NetworkEnvelope, error) {
+	n.conn.SetReadDeadline(time.Now().Add(n.Timeout))
+	envelope, err := ParseNetworkEnvelope(n.reader, n.Testnet)
+	if err != nil {
+		return nil, err
+	}
+	if n.Logging {
+		fmt.Printf("receiving: %s\n", envelope)
+	}
+	return envelope, nil
+}
+
+// WaitFor reads messages until one with one of the commands arrives.
+// Meanwhile a version is acknowledged and pings are answered, to keep the connection alive,
+// the fee filter of the peer is recorded and requests for transactions we announced are served.
+func (n *SimpleNode) WaitFor(commands ...string) (*NetworkEnvelope, error) {
+	for {
+		envelope, err := n.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		command := string(envelope.Command)
+		for _, wanted := range commands {
+			if command == wanted {
+				return envelope, nil
+			}
+		}
+
+		switch command {
+		case "version":
+			err = n.Send(&VerAckMessage{})
+		case "ping":
+			pong := &PongMessage{}
+			copy(pong.Nonce[:], envelope.Payload)
+			err = n.Send(pong)
+		case "feefilter":
+			if feeFilter, parseErr := ParseFeeFilterMessage(envelope.Payload); parseErr == nil {
+				n.FeeFilter = feeFilter.FeeRate
+			}
+		case "getdata":
+			if getData, parseErr := ParseGetDataMessage(envelope.Payload); parseErr == nil {
+				_, err = n.serveGetData(getData)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// GetHeaders asks the peer for the headers after the first block of locator it has
+func (n *SimpleNode) GetHeaders(locator [][]byte) ([]*block.Block, error) {
+	if err := n.Send(NewGetHeadersMessage(locator, nil)); err != nil {
+		return nil, err
+	}
+	envelope, err := n.WaitFor("headers")
+	if err != nil {
+		return nil, err
+	}
+	headers, err := ParseHeadersMessage(envelope.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return headers.Blocks, nil
+}
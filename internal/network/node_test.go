@@ -0,0 +1,133 @@
+package network
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+)
+
+// fakePeer answers on the other end of a pipe the way a node does
+type fakePeer struct {
+	t       *testing.T
+	conn    net.Conn
+	reader  *bufio.Reader
+	headers []*block.Block
+}
+
+// newFakePeer connects a node to a fake peer over loopback TCP, which buffers like a real connection
+func newFakePeer(t *testing.T, headers []*block.Block) (*SimpleNode, *fakePeer) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer listener.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept error: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	peer := &fakePeer{t: t, conn: server, reader: bufio.NewReader(server), headers: headers}
+	return NewSimpleNodeWithConn(client, false, false), peer
+}
+
+func (p *fakePeer) send(message Message) {
+	payload, _ := message.Serialize()
+	raw, _ := NewNetworkEnvelope(message.Command(), payload, false).Serialize()
+	if _, err := p.conn.Write(raw); err != nil {
+		p.t.Errorf("Fake peer write error: %v", err)
+	}
+}
+
+// serve answers version, ping and getheaders messages until the connection closes
+func (p *fakePeer) serve() {
+	for {
+		envelope, err := ParseNetworkEnvelope(p.reader, false)
+		if err != nil {
+			return
+		}
+		switch string(envelope.Command) {
+		case "version":
+			// a real node sends its own version and a ping before acknowledging
+			p.send(&VersionMessage{Version: 70015, UserAgent: "/fake:0.1/"})
+			p.send(&PingMessage{Nonce: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}})
+			p.send(&VerAckMessage{})
+		case "getheaders":
+			p.send(&HeadersMessage{Blocks: p.headers})
+		}
+	}
+}
+
+func TestSimpleNodeHandshake(t *testing.T) {
+	node, peer := newFakePeer(t, nil)
+
+	received := make(chan string, 10)
+	go func() {
+		// record what the node sends, answering like the fake peer does
+		for {
+			envelope, err := ParseNetworkEnvelope(peer.reader, false)
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- string(envelope.Command)
+			if string(envelope.Command) == "version" {
+				peer.send(&VersionMessage{Version: 70015})
+				peer.send(&PingMessage{Nonce: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}})
+				peer.send(&VerAckMessage{})
+			}
+		}
+	}()
+
+	if err := node.Handshake(); err != nil {
+		t.Fatalf("Handshake error: %v", err)
+	}
+	node.Close()
+
+	var commands []string
+	for command := range received {
+		commands = append(commands, command)
+	}
+	want := []string{"version", "verack", "pong"}
+	if len(commands) != len(want) {
+		t.Fatalf("Expected the node to send %v, got %v", want, commands)
+	}
+	for i := range want {
+		if commands[i] != want[i] {
+			t.Errorf("Expected the node to send %v, got %v", want, commands)
+		}
+	}
+}
+
+func TestSimpleNodeGetHeaders(t *testing.T) {
+	raw, _ := hex.DecodeString(mainnetBlock1Header)
+	block1, _ := block.ParseHeader(raw)
+
+	node, peer := newFakePeer(t, []*block.Block{block1})
+	go peer.serve()
+
+	if err := node.Handshake(); err != nil {
+		t.Fatalf("Handshake error: %v", err)
+	}
+
+	genesisHash, _ := block.Genesis(false).Hash()
+	headers, err := node.GetHeaders([][]byte{genesisHash})
+	if err != nil {
+		t.Fatalf("GetHeaders error: %v", err)
+	}
+	if len(headers) != 1 || *headers[0] != *block1 {
+		t.Errorf("Expected block 1, got %v", headers)
+	}
+}
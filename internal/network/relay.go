@@ -0,0 +1,298 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// Inventory types of inv and getdata messages
+const (
+	InvTx            = uint32(1)
+	InvBlock         = uint32(2)
+	InvFilteredBlock = uint32(3)
+	InvWitnessTx     = uint32(0x40000001)
+)
+
+// MaxInvItems is the most items an inv or getdata message may hold
+const MaxInvItems = 50000
+
+// RejectError is the reason a peer gave for rejecting a message, sent in a reject message
+type RejectError struct {
+	Message string
+	Code    byte
+	Reason  string
+	Hash    []byte
+}
+
+func (e *RejectError) Error() string {
+	if len(e.Hash) > 0 {
+		return fmt.Sprintf("peer rejected %s %x: %s (code 0x%02x)", e.Message, e.Hash, e.Reason, e.Code)
+	}
+	return fmt.Sprintf("peer rejected %s: %s (code 0x%02x)", e.Message, e.Reason, e.Code)
+}
+
+// InvVector announces or requests a single transaction or block by its hash
+type InvVector struct {
+	Type uint32
+	Hash []byte
+}
+
+// InvMessage announces transactions or blocks to a peer
+type InvMessage struct {
+	Items []InvVector
+}
+
+func (m *InvMessage) Command() []byte {
+	return []byte("inv")
+}
+
+func (m *InvMessage) Serialize() ([]byte, error) {
+	return serializeInventory(m.Items)
+}
+
+// ParseInvMessage reads an inv message
+func ParseInvMessage(payload []byte) (*InvMessage, error) {
+	items, err := parseInventory(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &InvMessage{Items: items}, nil
+}
+
+// GetDataMessage requests announced transactions or blocks
+type GetDataMessage struct {
+	Items []InvVector
+}
+
+func (m *GetDataMessage) Command() []byte {
+	return []byte("getdata")
+}
+
+func (m *GetDataMessage) Serialize() ([]byte, error) {
+	return serializeInventory(m.Items)
+}
+
+// ParseGetDataMessage reads a getdata message
+func ParseGetDataMessage(payload []byte) (*GetDataMessage, error) {
+	items, err := parseInventory(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &GetDataMessage{Items: items}, nil
+}
+
+func serializeInventory(items []InvVector) ([]byte, error) {
+	if len(items) > MaxInvItems {
+		return nil, fmt.Errorf("%d inventory items exceed the maximum of %d", len(items), MaxInvItems)
+	}
+	result, err := utils.EncodeVarint(uint64(len(items)))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if len(item.Hash) != 32 {
+			return nil, fmt.Errorf("inventory hash %x is not 32 bytes", item.Hash)
+		}
+		result = binary.LittleEndian.AppendUint32(result, item.Type)
+		// hashes go over the wire little endian
+		hash := slices.Clone(item.Hash)
+		slices.Reverse(hash)
+		result = append(result, hash...)
+	}
+	return result, nil
+}
+
+func parseInventory(payload []byte) ([]InvVector, error) {
+	reader := bufio.NewReader(bytes.NewReader(payload))
+	count, err := utils.ReadVarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if count > MaxInvItems {
+		return nil, fmt.Errorf("%d inventory items exceed the maximum of %d", count, MaxInvItems)
+	}
+
+	items := make([]InvVector, 0, count)
+	for i := 0; i < int(count); i++ {
+		item := InvVector{Hash: make([]byte, 32)}
+		if err := binary.Read(reader, binary.LittleEndian, &item.Type); err != nil {
+			return nil, fmt.Errorf("inventory item %d: %v", i, err)
+		}
+		if _, err := io.ReadFull(reader, item.Hash); err != nil {
+			return nil, fmt.Errorf("inventory item %d: %v", i, err)
+		}
+		slices.Reverse(item.Hash)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// TxMessage sends a transaction
+type TxMessage struct {
+	Tx *transaction.Tx
+}
+
+func (m *TxMessage) Command() []byte {
+	return []byte("tx")
+}
+
+func (m *TxMessage) Serialize() ([]byte, error) {
+	return m.Tx.Serialize()
+}
+
+// ParseRejectMessage reads a reject message into the error it describes
+func ParseRejectMessage(payload []byte) (*RejectError, error) {
+	reader := bufio.NewReader(bytes.NewReader(payload))
+
+	message, err := readVarString(reader)
+	if err != nil {
+		return nil, err
+	}
+	code, err := reader.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("reject message without a code")
+	}
+	reason, err := readVarString(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	rejectErr := &RejectError{Message: message, Code: code, Reason: reason}
+
+	// rejections of a transaction or block end with its hash
+	hash := make([]byte, 32)
+	if _, err := io.ReadFull(reader, hash); err == nil {
+		slices.Reverse(hash)
+		rejectErr.Hash = hash
+	}
+
+	return rejectErr, nil
+}
+
+func readVarString(reader *bufio.Reader) (string, error) {
+	length, err := utils.ReadVarint(reader)
+	if err != nil {
+		return "", err
+	}
+	if length > MaxPayloadSize {
+		return "", fmt.Errorf("string of %d bytes is too long", length)
+	}
+	s := make([]byte, length)
+	if _, err := io.ReadFull(reader, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
+
+// FeeFilterMessage tells a peer not to announce transactions below a fee rate in satoshis per 1000 vbytes
+type FeeFilterMessage struct {
+	FeeRate uint64
+}
+
+func (m *FeeFilterMessage) Command() []byte {
+	return []byte("feefilter")
+}
+
+func (m *FeeFilterMessage) Serialize() ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(nil, m.FeeRate), nil
+}
+
+// ParseFeeFilterMessage reads a feefilter message
+func ParseFeeFilterMessage(payload []byte) (*FeeFilterMessage, error) {
+	if len(payload) != 8 {
+		return nil, fmt.Errorf("feefilter payload of %d bytes, expected 8", len(payload))
+	}
+	return &FeeFilterMessage{FeeRate: binary.LittleEndian.Uint64(payload)}, nil
+}
+
+// Broadcast announces tx to the peer and sends it when the peer asks for it.
+// It then pings the peer: as messages are handled in order, a pong without a reject
+// before it means the peer accepted the transaction. The peer may ignore the announcement
+// when the fee rate of the transaction is below its fee filter, Broadcast then times out.
+func (n *SimpleNode) Broadcast(tx *transaction.Tx) error {
+	hash, err := tx.Hash()
+	if err != nil {
+		return err
+	}
+
+	n.announced[hex.EncodeToString(hash)] = tx
+	if err := n.Send(&InvMessage{Items: []InvVector{{Type: InvTx, Hash: hash}}}); err != nil {
+		return err
+	}
+
+	for {
+		envelope, err := n.WaitFor("getdata", "reject")
+		if err != nil {
+			return err
+		}
+		if string(envelope.Command) == "reject" {
+			if rejectErr, err := ParseRejectMessage(envelope.Payload); err == nil && bytes.Equal(rejectErr.Hash, hash) {
+				return rejectErr
+			}
+			continue
+		}
+
+		getData, err := ParseGetDataMessage(envelope.Payload)
+		if err != nil {
+			return err
+		}
+		requested, err := n.serveGetData(getData)
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(requested, func(h []byte) bool { return bytes.Equal(h, hash) }) {
+			break
+		}
+	}
+
+	ping := &PingMessage{}
+	rand.Read(ping.Nonce[:])
+	if err := n.Send(ping); err != nil {
+		return err
+	}
+
+	for {
+		envelope, err := n.WaitFor("pong", "reject")
+		if err != nil {
+			return err
+		}
+		switch string(envelope.Command) {
+		case "reject":
+			if rejectErr, err := ParseRejectMessage(envelope.Payload); err == nil && bytes.Equal(rejectErr.Hash, hash) {
+				return rejectErr
+			}
+		case "pong":
+			if bytes.Equal(envelope.Payload, ping.Nonce[:]) {
+				return nil
+			}
+		}
+	}
+}
+
+// serveGetData sends the transactions we announced that the peer asks for and returns their hashes
+func (n *SimpleNode) serveGetData(getData *GetDataMessage) ([][]byte, error) {
+	var served [][]byte
+	for _, item := range getData.Items {
+		if item.Type != InvTx && item.Type != InvWitnessTx {
+			continue
+		}
+		tx, ok := n.announced[hex.EncodeToString(item.Hash)]
+		if !ok {
+			continue
+		}
+		if err := n.Send(&TxMessage{Tx: tx}); err != nil {
+			return served, err
+		}
+		served = append(served, item.Hash)
+	}
+	return served, nil
+}
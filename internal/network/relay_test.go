@@ -0,0 +1,145 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+const relayTx = "0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600"
+
+func testRelayTx(t *testing.T) (*transaction.Tx, []byte) {
+	t.Helper()
+	raw, _ := hex.DecodeString(relayTx)
+	tx, err := transaction.ParseTx(bufio.NewReader(bytes.NewReader(raw)), false)
+	if err != nil {
+		t.Fatalf("ParseTx error: %v", err)
+	}
+	hash, _ := tx.Hash()
+	return tx, hash
+}
+
+func rejectPayload(message string, code byte, reason string, hash []byte) []byte {
+	payload := append([]byte{byte(len(message))}, message...)
+	payload = append(payload, code, byte(len(reason)))
+	payload = append(payload, reason...)
+	hash = slices.Clone(hash)
+	slices.Reverse(hash)
+	return append(payload, hash...)
+}
+
+func TestInvMessageSerialize(t *testing.T) {
+	_, hash := testRelayTx(t)
+	msg := &InvMessage{Items: []InvVector{{Type: InvTx, Hash: hash}, {Type: InvBlock, Hash: make([]byte, 32)}}}
+
+	serialized, err := msg.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize error: %v", err)
+	}
+	if len(serialized) != 1+2*36 || serialized[1] != 0x01 {
+		t.Errorf("Unexpected serialization %x", serialized)
+	}
+
+	parsed, err := ParseGetDataMessage(serialized)
+	if err != nil {
+		t.Fatalf("ParseGetDataMessage error: %v", err)
+	}
+	if len(parsed.Items) != 2 || parsed.Items[0].Type != InvTx || !bytes.Equal(parsed.Items[0].Hash, hash) {
+		t.Errorf("Unexpected items %v", parsed.Items)
+	}
+
+	if _, err := ParseInvMessage(serialized[:40]); err == nil {
+		t.Errorf("Expected an error for a truncated inv message")
+	}
+}
+
+func TestParseRejectAndFeeFilter(t *testing.T) {
+	_, hash := testRelayTx(t)
+
+	rejectErr, err := ParseRejectMessage(rejectPayload("tx", 0x42, "min relay fee not met", hash))
+	if err != nil {
+		t.Fatalf("ParseRejectMessage error: %v", err)
+	}
+	if rejectErr.Message != "tx" || rejectErr.Code != 0x42 || rejectErr.Reason != "min relay fee not met" || !bytes.Equal(rejectErr.Hash, hash) {
+		t.Errorf("Unexpected reject %+v", rejectErr)
+	}
+
+	feeFilter, err := ParseFeeFilterMessage([]byte{0xe8, 0x03, 0, 0, 0, 0, 0, 0})
+	if err != nil {
+		t.Fatalf("ParseFeeFilterMessage error: %v", err)
+	}
+	if feeFilter.FeeRate != 1000 {
+		t.Errorf("Expected fee rate 1000, got %d", feeFilter.FeeRate)
+	}
+	if _, err := ParseFeeFilterMessage([]byte{0x01}); err == nil {
+		t.Errorf("Expected an error for a short feefilter payload")
+	}
+}
+
+// relayPeer requests announced transactions and optionally rejects them
+func relayPeer(peer *fakePeer, reject bool, received chan<- []byte) {
+	for {
+		envelope, err := ParseNetworkEnvelope(peer.reader, false)
+		if err != nil {
+			return
+		}
+		switch string(envelope.Command) {
+		case "inv":
+			inv, _ := ParseInvMessage(envelope.Payload)
+			peer.send(&FeeFilterMessage{FeeRate: 1000})
+			peer.send(&GetDataMessage{Items: inv.Items})
+		case "tx":
+			received <- envelope.Payload
+			if reject {
+				raw := envelope.Payload
+				tx, _ := transaction.ParseTx(bufio.NewReader(bytes.NewReader(raw)), false)
+				hash, _ := tx.Hash()
+				payload := rejectPayload("tx", 0x42, "min relay fee not met", hash)
+				envelope, _ := NewNetworkEnvelope([]byte("reject"), payload, false).Serialize()
+				peer.conn.Write(envelope)
+			}
+		case "ping":
+			peer.send(&PongMessage{Nonce: [8]byte(envelope.Payload)})
+		}
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	tx, _ := testRelayTx(t)
+	raw, _ := hex.DecodeString(relayTx)
+
+	node, peer := newFakePeer(t, nil)
+	received := make(chan []byte, 1)
+	go relayPeer(peer, false, received)
+
+	if err := node.Broadcast(tx); err != nil {
+		t.Fatalf("Broadcast error: %v", err)
+	}
+	if payload := <-received; !bytes.Equal(payload, raw) {
+		t.Errorf("Expected the peer to receive %x, got %x", raw, payload)
+	}
+	if node.FeeFilter != 1000 {
+		t.Errorf("Expected the fee filter of the peer to be recorded, got %d", node.FeeFilter)
+	}
+}
+
+func TestBroadcastRejected(t *testing.T) {
+	tx, hash := testRelayTx(t)
+
+	node, peer := newFakePeer(t, nil)
+	go relayPeer(peer, true, make(chan []byte, 1))
+
+	err := node.Broadcast(tx)
+	var rejectErr *RejectError
+	if !errors.As(err, &rejectErr) {
+		t.Fatalf("Expected a RejectError, got %v", err)
+	}
+	if rejectErr.Code != 0x42 || !bytes.Equal(rejectErr.Hash, hash) {
+		t.Errorf("Unexpected reject %+v", rejectErr)
+	}
+}
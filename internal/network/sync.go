@@ -0,0 +1,151 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/events"
+)
+
+// HeaderPeer is a peer headers can be downloaded from, such as a SimpleNode after the handshake
+type HeaderPeer interface {
+	Addr() string
+	GetHeaders(locator [][]byte) ([]*block.Block, error)
+}
+
+type headersResponse struct {
+	peer    HeaderPeer
+	headers []*block.Block
+	hashes  [][]byte
+	err     error
+}
+
+// SyncHeaders downloads headers from all peers in parallel until they have nothing more to send,
+// and appends them to the store. Every round the batches are cross-checked: a batch is accepted
+// when a majority of the peers that answered sent it or a prefix of it. Peers that sent
+// conflicting headers are banned in addrman and dropped. It returns the number of headers added.
+// When notify is not nil it receives OnBlockConnected for every header added.
+func SyncHeaders(store *block.HeaderStore, peers []HeaderPeer, addrman *AddrMan, notify events.Events) (int, error) {
+	added := 0
+
+	for {
+		if len(peers) == 0 {
+			return added, fmt.Errorf("no peers left to sync from")
+		}
+
+		responses := requestHeaders(peers, store.Locator())
+
+		var answered []*headersResponse
+		for _, response := range responses {
+			if response.err != nil {
+				addrman.Failed(response.peer.Addr())
+				continue
+			}
+			answered = append(answered, response)
+		}
+		if len(answered) == 0 {
+			return added, fmt.Errorf("none of the %d peers answered", len(peers))
+		}
+
+		chosen := chooseBatch(answered)
+		if chosen == nil {
+			return added, fmt.Errorf("peers disagree on the headers after %x", store.TipHash())
+		}
+
+		peers = peers[:0]
+		for _, response := range answered {
+			if conflicts(response.hashes, chosen.hashes) {
+				addrman.Misbehaved(response.peer.Addr())
+				continue
+			}
+			addrman.Good(response.peer.Addr())
+			peers = append(peers, response.peer)
+		}
+
+		if len(chosen.headers) == 0 {
+			return added, nil
+		}
+
+		before := store.Height()
+		err := store.Append(chosen.headers...)
+		added += int(store.Height() - before)
+		if notify != nil {
+			for height := before + 1; height <= store.Height(); height++ {
+				hash, _ := store.HashAt(height)
+				notify.OnBlockConnected(hex.EncodeToString(hash), height)
+			}
+		}
+		if err != nil {
+			// the majority sent invalid headers, so the result cannot be trusted
+			return added, fmt.Errorf("headers from %s failed validation: %v", chosen.peer.Addr(), err)
+		}
+	}
+}
+
+// requestHeaders asks every peer for the headers after locator at the same time
+func requestHeaders(peers []HeaderPeer, locator [][]byte) []*headersResponse {
+	responses := make([]*headersResponse, len(peers))
+
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, peer HeaderPeer) {
+			defer wg.Done()
+			response := &headersResponse{peer: peer}
+			response.headers, response.err = peer.GetHeaders(locator)
+			for _, header := range response.headers {
+				if response.err != nil {
+					break
+				}
+				var hash []byte
+				hash, response.err = header.Hash()
+				response.hashes = append(response.hashes, hash)
+			}
+			responses[i] = response
+		}(i, peer)
+	}
+	wg.Wait()
+
+	return responses
+}
+
+// chooseBatch returns the longest batch of which a majority of the responses is a prefix
+func chooseBatch(responses []*headersResponse) *headersResponse {
+	quorum := len(responses)/2 + 1
+
+	var chosen *headersResponse
+	for _, candidate := range responses {
+		if chosen != nil && len(candidate.hashes) <= len(chosen.hashes) {
+			continue
+		}
+		support := 0
+		for _, response := range responses {
+			if isPrefix(response.hashes, candidate.hashes) {
+				support++
+			}
+		}
+		if support >= quorum {
+			chosen = candidate
+		}
+	}
+
+	return chosen
+}
+
+// isPrefix returns whether the hashes of prefix are the first hashes of hashes
+func isPrefix(prefix, hashes [][]byte) bool {
+	return len(prefix) <= len(hashes) && !conflicts(prefix, hashes)
+}
+
+// conflicts returns whether the batches differ somewhere in their common length
+func conflicts(hashes1, hashes2 [][]byte) bool {
+	for i := 0; i < min(len(hashes1), len(hashes2)); i++ {
+		if !bytes.Equal(hashes1[i], hashes2[i]) {
+			return true
+		}
+	}
+	return false
+}
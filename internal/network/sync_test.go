@@ -0,0 +1,135 @@
+package network
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/events"
+)
+
+const (
+	mainnetBlock1Header = "010000006fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000982051fd1e4ba744bbbe680e1fee14677ba1a3c3540bf7b1cdb606e857233e0e61bc6649ffff001d01e36299"
+	mainnetBlock2Header = "010000004860eb18bf1b1620e37e9490fc8a427514416fd75159ab86688e9a8300000000d5fdcc541e25de1c7a5addedf24858b8bb665c9f36ef744ee42c316022c90f9bb0bc6649ffff001d08d2bd61"
+)
+
+// chainPeer serves the headers after the first block of the locator in its chain
+type chainPeer struct {
+	addr  string
+	chain []*block.Block
+	err   error
+}
+
+func (p *chainPeer) Addr() string {
+	return p.addr
+}
+
+func (p *chainPeer) GetHeaders(locator [][]byte) ([]*block.Block, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	for _, locatorHash := range locator {
+		for i, header := range p.chain {
+			hash, _ := header.Hash()
+			if string(hash) == string(locatorHash) {
+				return p.chain[i+1:], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// connectedBlocks records the blocks SyncHeaders connects
+type connectedBlocks struct {
+	events.NoEvents
+	blocks []string
+}
+
+func (c *connectedBlocks) OnBlockConnected(hash string, height uint32) {
+	c.blocks = append(c.blocks, fmt.Sprintf("%s@%d", hash, height))
+}
+
+func testChain(t *testing.T) []*block.Block {
+	t.Helper()
+	chain := []*block.Block{block.Genesis(false)}
+	for _, headerHex := range []string{mainnetBlock1Header, mainnetBlock2Header} {
+		raw, _ := hex.DecodeString(headerHex)
+		header, err := block.ParseHeader(raw)
+		if err != nil {
+			t.Fatalf("ParseHeader error: %v", err)
+		}
+		chain = append(chain, header)
+	}
+	return chain
+}
+
+func openStore(t *testing.T) *block.HeaderStore {
+	t.Helper()
+	store, err := block.OpenHeaderStore(filepath.Join(t.TempDir(), "headers.dat"), false)
+	if err != nil {
+		t.Fatalf("OpenHeaderStore error: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSyncHeadersCrossChecksPeers(t *testing.T) {
+	chain := testChain(t)
+
+	// the liar sends a different block 1 on top of genesis
+	forged := *chain[1]
+	forged.Nonce++
+	liar := &chainPeer{addr: "liar", chain: []*block.Block{chain[0], &forged}}
+
+	peers := []HeaderPeer{
+		&chainPeer{addr: "honest", chain: chain},
+		&chainPeer{addr: "behind", chain: chain[:2]},
+		liar,
+		&chainPeer{addr: "offline", err: fmt.Errorf("connection refused")},
+	}
+
+	store := openStore(t)
+	addrman := NewAddrMan()
+
+	connected := &connectedBlocks{}
+	added, err := SyncHeaders(store, peers, addrman, connected)
+	if err != nil {
+		t.Fatalf("SyncHeaders error: %v", err)
+	}
+	if added != 2 || store.Height() != 2 {
+		t.Errorf("Expected 2 headers added up to height 2, got %d up to %d", added, store.Height())
+	}
+	for i, height := range []uint32{1, 2} {
+		hash, _ := store.HashAt(height)
+		if i >= len(connected.blocks) || connected.blocks[i] != fmt.Sprintf("%x@%d", hash, height) {
+			t.Errorf("Expected block %x to be connected at height %d, got %v", hash, height, connected.blocks)
+		}
+	}
+
+	if peer, _ := addrman.Peer("liar"); peer.Score > BanScore {
+		t.Errorf("Expected the liar to be banned, got score %d", peer.Score)
+	}
+	if peer, _ := addrman.Peer("offline"); peer.Score >= 0 {
+		t.Errorf("Expected the offline peer to lose score, got %d", peer.Score)
+	}
+	if peer, _ := addrman.Peer("behind"); peer.Score <= 0 {
+		t.Errorf("Expected a peer that is behind not to be punished, got score %d", peer.Score)
+	}
+}
+
+func TestSyncHeadersWithoutMajority(t *testing.T) {
+	chain := testChain(t)
+	forged := *chain[1]
+	forged.Nonce++
+
+	peers := []HeaderPeer{
+		&chainPeer{addr: "first", chain: chain},
+		&chainPeer{addr: "second", chain: []*block.Block{chain[0], &forged}},
+	}
+
+	if _, err := SyncHeaders(openStore(t), peers, NewAddrMan(), nil); err == nil {
+		t.Errorf("Expected an error when the peers disagree")
+	}
+}
@@ -0,0 +1,163 @@
+package params
+
+// Params holds the consensus and network parameters of a chain
+type Params struct {
+	Name string
+	// GenesisHeader is the serialized genesis block header, GenesisHash its hash
+	GenesisHeader string
+	GenesisHash   string
+
+	Magic       []byte
+	DefaultPort int
+	DNSSeeds    []string
+
+	// Heights from which the soft forks are enforced. BIP16 (P2SH) predates height based
+	// activation and was enforced from a switch-over time, P2SHHeight is the first block after it.
+	P2SHHeight    uint32
+	BIP34Height   uint32
+	BIP66Height   uint32
+	BIP65Height   uint32
+	CSVHeight     uint32
+	SegwitHeight  uint32
+	TaprootHeight uint32
+
+	// PowLimitBits are the bits of the easiest target a block may have, in the byte order of the header
+	PowLimitBits uint32
+
+	// Checkpoints are hard-coded block hashes by height that the chain has to contain
+	Checkpoints map[uint32]string
+}
+
+var MainNet = &Params{
+	Name:          "mainnet",
+	GenesisHeader: "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c",
+	GenesisHash:   "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+
+	Magic:       []byte{0xf9, 0xbe, 0xb4, 0xd9},
+	DefaultPort: 8333,
+	DNSSeeds: []string{
+		"seed.bitcoin.sipa.be",
+		"dnsseed.bluematt.me",
+		"seed.bitcoin.jonasschnelli.ch",
+		"seed.btc.petertodd.net",
+		"seed.bitcoin.sprovoost.nl",
+		"dnsseed.emzy.de",
+		"seed.bitcoin.wiz.biz",
+	},
+
+	P2SHHeight:    173805,
+	BIP34Height:   227931,
+	BIP66Height:   363725,
+	BIP65Height:   388381,
+	CSVHeight:     419328,
+	SegwitHeight:  481824,
+	TaprootHeight: 709632,
+
+	PowLimitBits: 0xffff001d,
+
+	// The same checkpoints Bitcoin Core ships with
+	Checkpoints: map[uint32]string{
+		11111:  "0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d",
+		33333:  "000000002dd5588a74784eaa7ab0507a18ad16a236e7b1ce69f00d7ddfb5d0a6",
+		74000:  "0000000000573993a3c9e41ce34471c079dcf5f52a0e824a81e7f953b8661a20",
+		105000: "00000000000291ce28027faea320c8d2b054b2e0fe44a773f3eefb151d6bdc97",
+		134444: "00000000000005b12ffd4cd315cd34ffd4a594f430ac814c91184a0d42d2b0fe",
+		168000: "000000000000099e61ea72015e79632f216fe6cb33d7899acb35b75c8303b763",
+		193000: "000000000000059f452a5f7340de6682a977387c17010ff6e6c3bd83ca8b1317",
+		210000: "000000000000048b95347e83192f69cf0366076336c639f9b7228e9ba171342e",
+		216116: "00000000000001b4f4b433e81ee46494af945cf96014816a4e2370f11b23df4e",
+		225430: "00000000000001c108384350f74090433e7fcf79a606b8e797f065b130575932",
+		250000: "000000000000003887df1f29024b06fc2200b55f8af8f35453d7be294df2d214",
+		279000: "0000000000000001ae8c72a0b0c301f67e3afca10e819efa9041e458e9bd7e40",
+		295000: "00000000000000004d9b4ef50f0f9d686fd69db2e03af35a100370c64632a983",
+	},
+}
+
+var TestNet3 = &Params{
+	Name:          "testnet3",
+	GenesisHeader: "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4adae5494dffff001d1aa4ae18",
+	GenesisHash:   "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+
+	Magic:       []byte{0x0b, 0x11, 0x09, 0x07},
+	DefaultPort: 18333,
+	DNSSeeds: []string{
+		"testnet-seed.bitcoin.jonasschnelli.ch",
+		"seed.tbtc.petertodd.net",
+		"seed.testnet.bitcoin.sprovoost.nl",
+		"testnet-seed.bluematt.me",
+	},
+
+	// P2SH was enforced on testnet3 from the start
+	P2SHHeight:    0,
+	BIP34Height:   21111,
+	BIP66Height:   330776,
+	BIP65Height:   581885,
+	CSVHeight:     770112,
+	SegwitHeight:  834624,
+	TaprootHeight: 2011968,
+
+	PowLimitBits: 0xffff001d,
+
+	Checkpoints: map[uint32]string{
+		546: "000000002a936ca763904c3c35fce2f3556c559c0214345d31b1bcebf76acb70",
+	},
+}
+
+// SigNet is the default signet, where all soft forks are active from the first block
+var SigNet = &Params{
+	Name:          "signet",
+	GenesisHeader: "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a008f4d5fae77031e8ad22203",
+	GenesisHash:   "00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6",
+
+	Magic:       []byte{0x0a, 0x03, 0xcf, 0x40},
+	DefaultPort: 38333,
+	DNSSeeds: []string{
+		"seed.signet.bitcoin.sprovoost.nl",
+		"seed.signet.achownodes.xyz",
+	},
+
+	P2SHHeight:    0,
+	BIP34Height:   1,
+	BIP66Height:   1,
+	BIP65Height:   1,
+	CSVHeight:     1,
+	SegwitHeight:  1,
+	TaprootHeight: 0,
+
+	PowLimitBits: 0xae77031e,
+
+	Checkpoints: map[uint32]string{},
+}
+
+// ForNetwork returns the parameters of testnet3 or mainnet
+func ForNetwork(testnet bool) *Params {
+	if testnet {
+		return TestNet3
+	}
+	return MainNet
+}
+
+// ByName returns the parameters of the network called name, such as "signet"
+func ByName(name string) (*Params, bool) {
+	for _, p := range []*Params{MainNet, TestNet3, SigNet} {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// LastCheckpoint returns the height of the highest checkpoint, or 0 when there are none
+func (p *Params) LastCheckpoint() uint32 {
+	last := uint32(0)
+	for height := range p.Checkpoints {
+		last = max(last, height)
+	}
+	return last
+}
+
+// AssumeValid returns whether the block at height is buried under a checkpoint. The checkpoint
+// commits to the whole chain before it, so script checks of such blocks can be skipped.
+func (p *Params) AssumeValid(height uint32) bool {
+	return height <= p.LastCheckpoint()
+}
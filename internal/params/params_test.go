@@ -0,0 +1,71 @@
+package params
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func TestGenesisHash(t *testing.T) {
+	for _, p := range []*Params{MainNet, TestNet3, SigNet} {
+		header, err := hex.DecodeString(p.GenesisHeader)
+		if err != nil || len(header) != 80 {
+			t.Fatalf("%s: bad genesis header", p.Name)
+		}
+		hash := hex.EncodeToString(utils.ReverseBytes(utils.Hash256(header)))
+		if hash != p.GenesisHash {
+			t.Errorf("%s: genesis hash = %s, want %s", p.Name, hash, p.GenesisHash)
+		}
+	}
+}
+
+func TestActivationOrder(t *testing.T) {
+	// the soft forks activated one after the other on every network
+	for _, p := range []*Params{MainNet, TestNet3, SigNet} {
+		heights := []uint32{p.BIP34Height, p.BIP66Height, p.BIP65Height, p.CSVHeight, p.SegwitHeight}
+		for i := 1; i < len(heights); i++ {
+			if heights[i] < heights[i-1] {
+				t.Errorf("%s: activation heights out of order: %v", p.Name, heights)
+			}
+		}
+		if p.P2SHHeight > p.BIP34Height {
+			t.Errorf("%s: P2SH activated after BIP34", p.Name)
+		}
+	}
+}
+
+func TestAssumeValid(t *testing.T) {
+	tests := []struct {
+		params *Params
+		height uint32
+		want   bool
+	}{
+		{MainNet, 295000, true},
+		{MainNet, 295001, false},
+		{TestNet3, 100, true},
+		{TestNet3, 547, false},
+		{SigNet, 1, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.params.AssumeValid(tt.height); got != tt.want {
+			t.Errorf("%s: AssumeValid(%d) = %v, want %v", tt.params.Name, tt.height, got, tt.want)
+		}
+	}
+
+	if ForNetwork(true) != TestNet3 || ForNetwork(false) != MainNet {
+		t.Errorf("ForNetwork returned the wrong parameters")
+	}
+}
+
+func TestByName(t *testing.T) {
+	for _, p := range []*Params{MainNet, TestNet3, SigNet} {
+		if found, ok := ByName(p.Name); !ok || found != p {
+			t.Errorf("ByName(%q) = %v, %v", p.Name, found, ok)
+		}
+	}
+	if _, ok := ByName("regtest"); ok {
+		t.Errorf("ByName of an unknown network should fail")
+	}
+}
@@ -0,0 +1,246 @@
+package reserves
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// Prefix of every signed message, as used by Bitcoin Core's signmessage
+const messageMagic = "Bitcoin Signed Message:\n"
+
+// Backend provides the chain data needed to create and verify a proof, such as an esplora.Backend
+type Backend interface {
+	Fetch(txID string, testnet, fresh bool) (*transaction.Tx, error)
+	FetchStatus(txID string, testnet bool) (*transaction.TxStatus, error)
+	FetchOutspend(txID string, vout uint32, testnet bool) (*transaction.Outspend, error)
+	FetchAddressUTXOs(address string, testnet bool) ([]*transaction.UTXO, error)
+	FetchBlockHash(height uint32, testnet bool) (string, error)
+}
+
+// AddressProof proves ownership of a p2pkh address with a signature by its key. The signature is the base64
+// recoverable signature of Bitcoin Core's signmessage, so verifymessage checks it too.
+type AddressProof struct {
+	Address   string `json:"address"`
+	Signature string `json:"signature"`
+}
+
+// Output is an unspent output in the snapshot
+type Output struct {
+	TxID    string `json:"txid"`
+	Vout    uint32 `json:"vout"`
+	Amount  uint64 `json:"amount"`
+	Address string `json:"address"`
+}
+
+// Proof is a proof of reserves: signatures for a set of addresses and their unspent outputs at a block
+type Proof struct {
+	BlockHash string         `json:"block_hash"`
+	Height    uint32         `json:"height"`
+	Testnet   bool           `json:"testnet"`
+	Addresses []AddressProof `json:"addresses"`
+	Outputs   []Output       `json:"outputs"`
+	Total     uint64         `json:"total"`
+}
+
+// Message returns the message that is signed for address, which commits to the snapshot block
+func Message(address, blockHash string) string {
+	return fmt.Sprintf("Proof of reserves for %s at block %s", address, blockHash)
+}
+
+// MessageHash returns the hash that is signed for message, the one of Bitcoin Core's signmessage
+func MessageHash(message string) (*big.Int, error) {
+	magicLength, err := utils.EncodeVarint(uint64(len(messageMagic)))
+	if err != nil {
+		return nil, err
+	}
+	messageLength, err := utils.EncodeVarint(uint64(len(message)))
+	if err != nil {
+		return nil, err
+	}
+
+	data := append(magicLength, messageMagic...)
+	data = append(data, messageLength...)
+	data = append(data, message...)
+
+	return new(big.Int).SetBytes(utils.Hash256(data)), nil
+}
+
+// SignMessage signs message like Bitcoin Core's signmessage with the key of a p2pkh address, compressed or not
+func SignMessage(key *signatureverification.PrivateKey, message string, compressed bool) (string, error) {
+	z, err := MessageHash(message)
+	if err != nil {
+		return "", err
+	}
+	compact, err := key.SignCompact(z, compressed)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(compact), nil
+}
+
+// VerifyMessage checks a signature of SignMessage or signmessage and returns the p2pkh ScriptPubKey of address
+func VerifyMessage(address, signature, message string, testnet bool) (*script.Script, error) {
+	compact, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, fmt.Errorf("bad signature for %s: %v", address, err)
+	}
+	z, err := MessageHash(message)
+	if err != nil {
+		return nil, err
+	}
+	point, compressed, err := signatureverification.RecoverCompact(z, compact)
+	if err != nil {
+		return nil, fmt.Errorf("bad signature for %s: %v", address, err)
+	}
+	// a signature of another message or by another key recovers to a key of another address
+	if point.Address(compressed, testnet) != address {
+		return nil, fmt.Errorf("invalid signature for %s", address)
+	}
+	return script.CreateP2pkhScript(point.Hash160(compressed)), nil
+}
+
+// NewProof signs the p2pkh address of every key and collects its outputs confirmed at or before height.
+// The outputs are taken from the current UTXO set, so outputs spent since height are not included.
+func NewProof(keys []*signatureverification.PrivateKey, height uint32, testnet bool, backend Backend) (*Proof, error) {
+	blockHash, err := backend.FetchBlockHash(height, testnet)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &Proof{BlockHash: blockHash, Height: height, Testnet: testnet}
+
+	for _, key := range keys {
+		address := key.Point.Address(true, testnet)
+
+		signature, err := SignMessage(key, Message(address, blockHash), true)
+		if err != nil {
+			return nil, err
+		}
+		proof.Addresses = append(proof.Addresses, AddressProof{Address: address, Signature: signature})
+
+		utxos, err := backend.FetchAddressUTXOs(address, testnet)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, utxo := range utxos {
+			if !utxo.Status.Confirmed || utxo.Status.BlockHeight > height {
+				continue
+			}
+			proof.Outputs = append(proof.Outputs, Output{TxID: utxo.TxID, Vout: utxo.Vout, Amount: utxo.Value, Address: address})
+			proof.Total += utxo.Value
+		}
+	}
+
+	return proof, nil
+}
+
+// ParseProof reads a proof from its JSON serialization
+func ParseProof(data []byte) (*Proof, error) {
+	proof := &Proof{}
+	if err := json.Unmarshal(data, proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// Serialize returns the JSON serialization of the proof
+func (p *Proof) Serialize() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// Verify checks the address signatures and that every output existed unspent at the snapshot block.
+// It returns the verified total, which must match the total claimed by the proof.
+func (p *Proof) Verify(backend Backend) (uint64, error) {
+	blockHash, err := backend.FetchBlockHash(p.Height, p.Testnet)
+	if err != nil {
+		return 0, err
+	}
+	if blockHash != p.BlockHash {
+		return 0, fmt.Errorf("block at height %d is %s, not %s", p.Height, blockHash, p.BlockHash)
+	}
+
+	scriptPubkeys := make(map[string]*script.Script)
+	for _, addressProof := range p.Addresses {
+		scriptPubkey, err := addressProof.verify(p.BlockHash, p.Testnet)
+		if err != nil {
+			return 0, err
+		}
+		scriptPubkeys[addressProof.Address] = scriptPubkey
+	}
+
+	var total uint64
+	seen := make(map[string]bool)
+	for _, output := range p.Outputs {
+		outpoint := fmt.Sprintf("%s:%d", output.TxID, output.Vout)
+		if seen[outpoint] {
+			return 0, fmt.Errorf("output %s is listed twice", outpoint)
+		}
+		seen[outpoint] = true
+
+		scriptPubkey, ok := scriptPubkeys[output.Address]
+		if !ok {
+			return 0, fmt.Errorf("output %s belongs to %s, which has no ownership proof", outpoint, output.Address)
+		}
+
+		if err := p.verifyOutput(output, scriptPubkey, backend); err != nil {
+			return 0, fmt.Errorf("output %s: %v", outpoint, err)
+		}
+		total += output.Amount
+	}
+
+	if total != p.Total {
+		return 0, fmt.Errorf("outputs add up to %d, proof claims %d", total, p.Total)
+	}
+
+	return total, nil
+}
+
+// verify checks the signature and returns the p2pkh ScriptPubKey of the address
+func (a *AddressProof) verify(blockHash string, testnet bool) (*script.Script, error) {
+	return VerifyMessage(a.Address, a.Signature, Message(a.Address, blockHash), testnet)
+}
+
+func (p *Proof) verifyOutput(output Output, scriptPubkey *script.Script, backend Backend) error {
+	tx, err := backend.Fetch(output.TxID, p.Testnet, false)
+	if err != nil {
+		return err
+	}
+	if output.Vout >= uint32(len(tx.TxOuts)) {
+		return fmt.Errorf("transaction has no output %d", output.Vout)
+	}
+
+	txOut := tx.TxOuts[output.Vout]
+	if txOut.Amount != output.Amount {
+		return fmt.Errorf("amount is %d, proof claims %d", txOut.Amount, output.Amount)
+	}
+	if !txOut.ScriptPubkey.Equal(scriptPubkey) {
+		return fmt.Errorf("does not pay to %s", output.Address)
+	}
+
+	status, err := backend.FetchStatus(output.TxID, p.Testnet)
+	if err != nil {
+		return err
+	}
+	if !status.Confirmed || status.BlockHeight > p.Height {
+		return fmt.Errorf("not confirmed at height %d", p.Height)
+	}
+
+	outspend, err := backend.FetchOutspend(output.TxID, output.Vout, p.Testnet)
+	if err != nil {
+		return err
+	}
+	// an output spent after the snapshot, or only in the mempool, was still unspent at the snapshot
+	if outspend.Spent && outspend.Status.Confirmed && outspend.Status.BlockHeight <= p.Height {
+		return fmt.Errorf("spent by %s at height %d", outspend.TxID, outspend.Status.BlockHeight)
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package reserves
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/esplora"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+const snapshotHash = "00000000000000000002a7c4c1e48d76c5a37902165a270156b7a8d72728a054"
+
+var _ Backend = (*esplora.Backend)(nil)
+
+// fakeBackend serves a fixed chain state for one funding transaction
+type fakeBackend struct {
+	txID      string
+	tx        *transaction.Tx
+	status    transaction.TxStatus
+	outspends map[uint32]transaction.Outspend
+}
+
+func (b *fakeBackend) Fetch(txID string, testnet, fresh bool) (*transaction.Tx, error) {
+	if txID != b.txID {
+		return nil, fmt.Errorf("unknown transaction %s", txID)
+	}
+	return b.tx, nil
+}
+
+func (b *fakeBackend) FetchStatus(txID string, testnet bool) (*transaction.TxStatus, error) {
+	return &b.status, nil
+}
+
+func (b *fakeBackend) FetchOutspend(txID string, vout uint32, testnet bool) (*transaction.Outspend, error) {
+	outspend := b.outspends[vout]
+	return &outspend, nil
+}
+
+func (b *fakeBackend) FetchAddressUTXOs(address string, testnet bool) ([]*transaction.UTXO, error) {
+	var utxos []*transaction.UTXO
+	for vout, txOut := range b.tx.TxOuts {
+		if b.outspends[uint32(vout)].Spent {
+			continue
+		}
+		utxos = append(utxos, &transaction.UTXO{TxID: b.txID, Vout: uint32(vout), Value: txOut.Amount, Status: b.status})
+	}
+	return utxos, nil
+}
+
+func (b *fakeBackend) FetchBlockHash(height uint32, testnet bool) (string, error) {
+	if height != 800000 {
+		return "", fmt.Errorf("unknown height %d", height)
+	}
+	return snapshotHash, nil
+}
+
+func newFakeBackend(t *testing.T, key *signatureverification.PrivateKey) *fakeBackend {
+	t.Helper()
+	scriptPubkey := script.CreateP2pkhScript(key.Point.Hash160(true))
+	txIn := transaction.NewTxIn(make([]byte, 32), 0, &script.Script{}, 0xffffffff)
+	tx := transaction.NewTx(1, []*transaction.TxIn{txIn}, []*transaction.TxOut{
+		transaction.NewTxOut(50000, scriptPubkey),
+		transaction.NewTxOut(25000, scriptPubkey),
+	}, 0, true)
+	txID, err := tx.Id()
+	if err != nil {
+		t.Fatalf("Id error: %v", err)
+	}
+	return &fakeBackend{
+		txID:      txID,
+		tx:        tx,
+		status:    transaction.TxStatus{Confirmed: true, BlockHeight: 799990},
+		outspends: make(map[uint32]transaction.Outspend),
+	}
+}
+
+func TestProofRoundTrip(t *testing.T) {
+	key, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	backend := newFakeBackend(t, key)
+
+	proof, err := NewProof([]*signatureverification.PrivateKey{key}, 800000, true, backend)
+	if err != nil {
+		t.Fatalf("NewProof error: %v", err)
+	}
+	if proof.Total != 75000 || len(proof.Outputs) != 2 {
+		t.Fatalf("Unexpected proof total %d with %d outputs", proof.Total, len(proof.Outputs))
+	}
+
+	serialized, err := proof.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize error: %v", err)
+	}
+	parsed, err := ParseProof(serialized)
+	if err != nil {
+		t.Fatalf("ParseProof error: %v", err)
+	}
+
+	total, err := parsed.Verify(backend)
+	if err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+	if total != 75000 {
+		t.Errorf("Verified total = %d, want 75000", total)
+	}
+
+	// Spending an output after the snapshot does not invalidate the proof
+	backend.outspends[1] = transaction.Outspend{Spent: true, Status: transaction.TxStatus{Confirmed: true, BlockHeight: 800001}}
+	if _, err := parsed.Verify(backend); err != nil {
+		t.Errorf("Output spent after the snapshot should verify: %v", err)
+	}
+
+	// Spending it before the snapshot does
+	backend.outspends[1] = transaction.Outspend{Spent: true, Status: transaction.TxStatus{Confirmed: true, BlockHeight: 799995}}
+	if _, err := parsed.Verify(backend); err == nil {
+		t.Errorf("Output spent before the snapshot should not verify")
+	}
+}
+
+func TestProofVerifyRejectsTampering(t *testing.T) {
+	key, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	other, _ := signatureverification.NewPrivateKey(big.NewInt(12345))
+	backend := newFakeBackend(t, key)
+
+	tests := []struct {
+		name   string
+		tamper func(p *Proof)
+	}{
+		{"inflated total", func(p *Proof) { p.Total++ }},
+		{"inflated amount", func(p *Proof) { p.Outputs[0].Amount++; p.Total++ }},
+		{"other block", func(p *Proof) { p.BlockHash = "ff" + p.BlockHash[2:] }},
+		{"duplicate output", func(p *Proof) { p.Outputs = append(p.Outputs, p.Outputs[0]); p.Total += p.Outputs[0].Amount }},
+		{"foreign key", func(p *Proof) {
+			p.Addresses[0].Signature, _ = SignMessage(other, Message(p.Addresses[0].Address, p.BlockHash), true)
+		}},
+		{"uncompressed key", func(p *Proof) {
+			p.Addresses[0].Signature, _ = SignMessage(key, Message(p.Addresses[0].Address, p.BlockHash), false)
+		}},
+		{"signature for other block", func(p *Proof) {
+			p.Addresses[0].Signature, _ = SignMessage(key, Message(p.Addresses[0].Address, "00"), true)
+		}},
+	}
+
+	for _, tt := range tests {
+		proof, err := NewProof([]*signatureverification.PrivateKey{key}, 800000, true, backend)
+		if err != nil {
+			t.Fatalf("NewProof error: %v", err)
+		}
+		tt.tamper(proof)
+		if _, err := proof.Verify(backend); err == nil {
+			t.Errorf("%s: tampered proof should not verify", tt.name)
+		}
+	}
+}
+
+func TestNewProofSkipsLaterOutputs(t *testing.T) {
+	key, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	backend := newFakeBackend(t, key)
+	backend.status = transaction.TxStatus{Confirmed: true, BlockHeight: 800005}
+
+	proof, err := NewProof([]*signatureverification.PrivateKey{key}, 800000, true, backend)
+	if err != nil {
+		t.Fatalf("NewProof error: %v", err)
+	}
+	if len(proof.Outputs) != 0 || proof.Total != 0 {
+		t.Errorf("Outputs confirmed after the snapshot should not be included")
+	}
+}
+
+// TestSignMessageMatchesCore checks a signature made by Bitcoin Core's signmessage
+func TestSignMessageMatchesCore(t *testing.T) {
+	const (
+		wif       = "L4vB5fomsK8L95wQ7GFzvErYGht49JsCPJyJMHpB4xGM6xgi2jvG"
+		address   = "1F26pNMrywyZJdr22jErtKcjF8R3Ttt55G"
+		signature = "H85WKpqtNZDrajOnYDgUY+abh0KCAcOsAIOQwx2PftAbLEPRA7mzXA/CjXRxzz0MC225pR/hx02Vf2Ag2x33kU4="
+	)
+
+	if _, err := VerifyMessage(address, signature, address, false); err != nil {
+		t.Fatalf("VerifyMessage error: %v", err)
+	}
+	if _, err := VerifyMessage(address, signature, "another message", false); err == nil {
+		t.Errorf("The signature should not verify for another message")
+	}
+
+	payload, err := utils.DecodeBase58Checksum(wif)
+	if err != nil {
+		t.Fatalf("DecodeBase58Checksum error: %v", err)
+	}
+	key, _ := signatureverification.NewPrivateKey(new(big.Int).SetBytes(payload[1:33]))
+	got, err := SignMessage(key, address, true)
+	if err != nil {
+		t.Fatalf("SignMessage error: %v", err)
+	}
+	if got != signature {
+		t.Errorf("SignMessage = %s, want %s", got, signature)
+	}
+}
@@ -0,0 +1,39 @@
+package script
+
+import "github.com/caspereijkens/cryptocurrency/internal/utils"
+
+// ScriptToP2SH returns the p2sh ScriptPubKey paying to redeemScript, the hash160 of its serialization
+func ScriptToP2SH(redeemScript *Script) (*Script, error) {
+	raw, err := redeemScript.RawSerialize()
+	if err != nil {
+		return nil, err
+	}
+	return CreateP2SHScript(utils.Hash160(raw)), nil
+}
+
+// ScriptToP2WSH returns the p2wsh ScriptPubKey paying to witnessScript, the sha256 of its serialization
+func ScriptToP2WSH(witnessScript *Script) (*Script, error) {
+	raw, err := witnessScript.RawSerialize()
+	if err != nil {
+		return nil, err
+	}
+	return CreateP2WSHScript(utils.Sha256Hash(raw)), nil
+}
+
+// ScriptToP2SHAddress returns the base58 p2sh address of redeemScript
+func ScriptToP2SHAddress(redeemScript *Script, testnet bool) (string, error) {
+	raw, err := redeemScript.RawSerialize()
+	if err != nil {
+		return "", err
+	}
+	return utils.H160ToP2SHAddress(utils.Hash160(raw), testnet), nil
+}
+
+// ScriptToP2WSHAddress returns the bech32 p2wsh address of witnessScript
+func ScriptToP2WSHAddress(witnessScript *Script, testnet bool) (string, error) {
+	raw, err := witnessScript.RawSerialize()
+	if err != nil {
+		return "", err
+	}
+	return utils.EncodeSegwitAddress(0, utils.Sha256Hash(raw), testnet)
+}
@@ -0,0 +1,57 @@
+package script
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestScriptToP2WSHAddress(t *testing.T) {
+	// the BIP173 example: <generator point> OP_CHECKSIG
+	pubkey, _ := hex.DecodeString("0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	witnessScript := NewBuilder().PushData(pubkey).Op("OP_CHECKSIG").MustScript()
+
+	tests := []struct {
+		testnet bool
+		want    string
+	}{
+		{false, "bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3"},
+		{true, "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"},
+	}
+	for _, tt := range tests {
+		if address, err := ScriptToP2WSHAddress(witnessScript, tt.testnet); err != nil || address != tt.want {
+			t.Errorf("ScriptToP2WSHAddress(testnet=%v) = %s, %v, want %s", tt.testnet, address, err, tt.want)
+		}
+	}
+
+	scriptPubkey, err := ScriptToP2WSH(witnessScript)
+	if err != nil {
+		t.Fatalf("ScriptToP2WSH error: %v", err)
+	}
+	raw, _ := scriptPubkey.RawSerialize()
+	if want := "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"; hex.EncodeToString(raw) != want {
+		t.Errorf("ScriptToP2WSH = %x, want %s", raw, want)
+	}
+}
+
+func TestScriptToP2SHAddress(t *testing.T) {
+	// the BIP49 example: the p2wpkh redeem script of a nested segwit address
+	h160, _ := hex.DecodeString("38971f73930f6c141d977ac4fd4a727c854935b3")
+	redeemScript := CreateP2WPKHScript(h160)
+
+	if address, err := ScriptToP2SHAddress(redeemScript, true); err != nil || address != "2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2" {
+		t.Errorf("ScriptToP2SHAddress = %s, %v, want 2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2", address, err)
+	}
+
+	scriptPubkey, err := ScriptToP2SH(redeemScript)
+	if err != nil {
+		t.Fatalf("ScriptToP2SH error: %v", err)
+	}
+	raw, _ := scriptPubkey.RawSerialize()
+	if want := "a914336caa13e08b96080a32b5d818d59b4ab3b3674287"; hex.EncodeToString(raw) != want {
+		t.Errorf("ScriptToP2SH = %x, want %s", raw, want)
+	}
+
+	if _, err := ScriptToP2SHAddress(&Script{make([]byte, MaxScriptElementSize+1)}, false); err == nil {
+		t.Errorf("a script that does not serialize should fail")
+	}
+}
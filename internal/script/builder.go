@@ -0,0 +1,132 @@
+package script
+
+import (
+	"fmt"
+	"strings"
+)
+
+// opCodesByName maps the names of opCodeNames back to their op codes, with the aliases Bitcoin Core accepts
+var opCodesByName = func() map[string]byte {
+	byName := map[string]byte{"OP_FALSE": 0x00, "OP_TRUE": 0x51, "OP_NOP2": 0xb1, "OP_NOP3": 0xb2}
+	for opCode, name := range opCodeNames {
+		byName[name] = byte(opCode)
+	}
+	return byName
+}()
+
+// OpCodeByName returns the op code called name, such as "OP_CHECKSIG". The OP_ prefix may be left out.
+func OpCodeByName(name string) (byte, bool) {
+	if !strings.HasPrefix(name, "OP_") {
+		name = "OP_" + name
+	}
+	opCode, ok := opCodesByName[name]
+	return opCode, ok
+}
+
+// Builder assembles a Script from op codes and pushes, encoding the pushes minimally: numbers and data up to 16
+// become OP_0 through OP_16 or OP_1NEGATE. The first error stops the building and is returned by Script.
+//
+//	NewBuilder().Op("OP_DUP").Op("OP_HASH160").PushData(h160).Op("OP_EQUALVERIFY").Op("OP_CHECKSIG").Script()
+type Builder struct {
+	script Script
+	err    error
+}
+
+func NewBuilder() *Builder {
+	return &Builder{script: Script{}}
+}
+
+// Op adds the op code called name, see OpCodeByName
+func (b *Builder) Op(name string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	opCode, ok := OpCodeByName(name)
+	if !ok {
+		b.err = fmt.Errorf("unknown op code %s", name)
+		return b
+	}
+	return b.OpCode(opCode)
+}
+
+// OpCode adds opCode. The push op codes are rejected, their data goes through PushData.
+func (b *Builder) OpCode(opCode byte) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if isPushOpCode(opCode) {
+		b.err = fmt.Errorf("op code %#x pushes data, use PushData", opCode)
+		return b
+	}
+	b.script = append(b.script, []byte{opCode})
+	return b
+}
+
+// Ops adds the op codes called names in order
+func (b *Builder) Ops(names ...string) *Builder {
+	for _, name := range names {
+		b.Op(name)
+	}
+	return b
+}
+
+// PushData adds a push of data with the smallest op code that pushes it
+func (b *Builder) PushData(data []byte) *Builder {
+	if b.err != nil {
+		return b
+	}
+	switch {
+	case len(data) > MaxScriptElementSize:
+		b.err = fmt.Errorf("push of %d bytes exceeds %d bytes", len(data), MaxScriptElementSize)
+		return b
+	case len(data) == 0:
+		return b.OpCode(0x00)
+	case len(data) == 1 && data[0] >= 1 && data[0] <= 16:
+		return b.OpCode(0x50 + data[0])
+	case len(data) == 1 && data[0] == 0x81:
+		return b.OpCode(0x4f)
+	case len(data) == 1:
+		// a literal single byte command would be an op code
+		b.script = append(b.script, pushedByte(data[0]))
+		return b
+	}
+	b.script = append(b.script, append([]byte{}, data...))
+	return b
+}
+
+// PushInt adds a push of n as a script number
+func (b *Builder) PushInt(n int64) *Builder {
+	return b.PushData(ScriptNum(n).Bytes())
+}
+
+// PushScript adds a push of the serialization of s, such as a redeem script
+func (b *Builder) PushScript(s *Script) *Builder {
+	if b.err != nil {
+		return b
+	}
+	raw, err := s.RawSerialize()
+	if err != nil {
+		b.err = err
+		return b
+	}
+	return b.PushData(raw)
+}
+
+// Script returns the assembled Script, or the first error of the builder
+func (b *Builder) Script() (*Script, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	s := append(Script{}, b.script...)
+	return &s, nil
+}
+
+// MustScript returns the assembled Script and panics on an error. It is meant for scripts in tests and
+// constants, whose pushes are known to be valid.
+func (b *Builder) MustScript() *Script {
+	s, err := b.Script()
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	h160, _ := hex.DecodeString("d52ad7ca9b3d096a38e752c2018e6fbc40cdf26f")
+	p2pkh, err := NewBuilder().Ops("OP_DUP", "HASH160").PushData(h160).Op("OP_EQUALVERIFY").Op("OP_CHECKSIG").Script()
+	if err != nil {
+		t.Fatalf("Script error: %v", err)
+	}
+	if !p2pkh.Equal(CreateP2pkhScript(h160)) {
+		t.Errorf("Expected the p2pkh script, got %v", p2pkh)
+	}
+
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    string
+	}{
+		{"small numbers are op codes", NewBuilder().PushInt(0).PushInt(-1).PushInt(1).PushInt(16), "004f5160"},
+		{"larger numbers are minimal pushes", NewBuilder().PushInt(17).PushInt(500).PushInt(-500), "0111" + "02f401" + "02f481"},
+		{"single byte data up to 16 is an op code", NewBuilder().PushData([]byte{0x05}).PushData([]byte{0x81}).PushData(nil), "554f00"},
+		{"single byte data of a push op code", NewBuilder().PushData([]byte{0x4d}), "014d"},
+		{"single byte data of other op codes", NewBuilder().PushData([]byte{0x00}).PushData([]byte{0x4e}).PushData([]byte{0xac}), "0100" + "014e" + "01ac"},
+		{"numbers pushed as a single byte", NewBuilder().PushInt(100).PushInt(127).PushInt(-2).PushInt(-127), "0164" + "017f" + "0182" + "01ff"},
+		{"long data uses OP_PUSHDATA1", NewBuilder().PushData(bytes.Repeat([]byte{0xab}, 80)), "4c50" + hex.EncodeToString(bytes.Repeat([]byte{0xab}, 80))},
+		{"aliases", NewBuilder().Ops("OP_TRUE", "OP_FALSE", "OP_NOP3"), "5100b2"},
+		{"scripts are pushed serialized", NewBuilder().PushScript(NewBuilder().Ops("OP_1", "OP_EQUAL").MustScript()), "025187"},
+	}
+	for _, tt := range tests {
+		s, err := tt.builder.Script()
+		if err != nil {
+			t.Errorf("%s: Script error: %v", tt.name, err)
+			continue
+		}
+		raw, err := s.RawSerialize()
+		if err != nil || hex.EncodeToString(raw) != tt.want {
+			t.Errorf("%s: serialized to %x, %v, want %s", tt.name, raw, err, tt.want)
+		}
+		// the serialization parses back to the same pushes
+		parsed, err := ParseRawScript(raw)
+		if err != nil || !parsed.Equal(s) {
+			t.Errorf("%s: %x parsed back as %v, %v, want %v", tt.name, raw, parsed, err, s)
+		}
+	}
+
+	// pushes of single bytes run as data, not as the op codes of the same byte
+	s := NewBuilder().PushInt(100).PushData([]byte{0xac}).Op("OP_SIZE").Op("OP_NIP").Op("OP_NIP").MustScript()
+	if !s.Evaluate(nil) {
+		t.Errorf("%v should leave the size of the push of 0xac", s)
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+	}{
+		{"unknown op code", NewBuilder().Op("OP_FOO")},
+		{"push op code", NewBuilder().OpCode(0x4c)},
+		{"too long a push", NewBuilder().PushData(make([]byte, MaxScriptElementSize+1))},
+		{"error stops the building", NewBuilder().Op("OP_FOO").Op("OP_1")},
+	}
+	for _, tt := range tests {
+		if s, err := tt.builder.Script(); err == nil {
+			t.Errorf("%s: expected an error, got %v", tt.name, s)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustScript should panic on an error")
+		}
+	}()
+	NewBuilder().Op("OP_FOO").MustScript()
+}
@@ -0,0 +1,63 @@
+package script
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+// Engine evaluates a script on a stack it is given, such as the items of a witness, instead of on the stack a
+// ScriptSig leaves. It holds the transaction data and rules of ExecutionContext, so one Engine can run any number
+// of scripts. The stacks it is given are copied and never changed, and the stacks it returns share no memory with
+// the script.
+type Engine struct {
+	Z        *big.Int
+	SigHash  func(hashType signatureverification.SigHashType) (*big.Int, error)
+	Locktime int
+	Sequence int
+	Version  int
+	Flags    VerificationFlags
+	SigCache *signatureverification.SigCache
+	Opcodes  *OpcodeRegistry
+	Tracer   func(step TraceStep)
+}
+
+// Run evaluates s with stack as its initial stack, bottom first, and returns the stack it leaves and whether it
+// ends true. Like the items of a witness, no element of stack may be larger than MaxScriptElementSize.
+func (e *Engine) Run(s *Script, stack [][]byte) (Stack, bool, error) {
+	for i, element := range stack {
+		if len(element) > MaxScriptElementSize {
+			return nil, false, fmt.Errorf("initial stack element %d of %d bytes exceeds the maximum element size", i, len(element))
+		}
+	}
+	ctx := &ExecutionContext{
+		Stack:    Stack(stack).copy(),
+		Z:        e.Z,
+		SigHash:  e.SigHash,
+		Locktime: e.Locktime,
+		Sequence: e.Sequence,
+		Version:  e.Version,
+		Flags:    e.Flags,
+		SigCache: e.SigCache,
+		Opcodes:  e.Opcodes,
+		Tracer:   e.Tracer,
+	}
+	ok, err := s.Execute(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	return ctx.Stack.copy(), ok, nil
+}
+
+// Verify runs s on stack and returns an error wrapping ErrEvalFalse unless it ends true
+func (e *Engine) Verify(s *Script, stack [][]byte) error {
+	_, ok, err := e.Run(s, stack)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("script %w", ErrEvalFalse)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+func TestEngineRun(t *testing.T) {
+	engine := &Engine{Flags: StandardVerifyFlags}
+
+	// OP_ADD OP_5 OP_EQUAL on a stack of 2 and 3
+	stack := [][]byte{encodeNum(2), encodeNum(3)}
+	result, ok, err := engine.Run(&Script{{0x93}, {0x55}, {0x87}}, stack)
+	if err != nil || !ok || len(result) != 1 {
+		t.Fatalf("Run = %x, %v, %v", result, ok, err)
+	}
+	if len(stack) != 2 || decodeNum(stack[0]) != 2 || decodeNum(stack[1]) != 3 {
+		t.Errorf("Run changed the stack it was given: %x", stack)
+	}
+
+	if err := engine.Verify(&Script{{0x75}}, [][]byte{{}, {0x01}}); !errors.Is(err, ErrEvalFalse) {
+		t.Errorf("Script leaving an empty element on top should evaluate to false, got %v", err)
+	}
+	if err := engine.Verify(&Script{{0x75}}, nil); !errors.Is(err, ErrBadOp) {
+		t.Errorf("OP_DROP on an empty stack should fail, got %v", err)
+	}
+	if _, _, err := engine.Run(&Script{{0x51}}, [][]byte{make([]byte, MaxScriptElementSize+1)}); err == nil {
+		t.Errorf("Initial element larger than the maximum element size should be rejected")
+	}
+
+	// a single key witness script run on its witness items
+	z := big.NewInt(42)
+	key, _ := signatureverification.NewPrivateKey(big.NewInt(7))
+	sig, err := key.Sign(z)
+	if err != nil {
+		t.Fatalf("Sign error: %v", err)
+	}
+	witnessScript := &Script{key.Point.Serialize(true), {0xac}}
+	witness := [][]byte{append(sig.Serialize(), 0x01)}
+	engine.Z = z
+	if err := engine.Verify(witnessScript, witness); err != nil {
+		t.Errorf("Signature should satisfy the witness script: %v", err)
+	}
+	engine.Z = big.NewInt(43)
+	if err := engine.Verify(witnessScript, witness); err == nil {
+		t.Errorf("Signature of another hash should not satisfy the witness script")
+	}
+	if !bytes.Equal(witness[0][len(witness[0])-1:], []byte{0x01}) {
+		t.Errorf("Verify changed the witness")
+	}
+}
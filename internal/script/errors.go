@@ -0,0 +1,24 @@
+package script
+
+import "errors"
+
+// The errors of parsing and executing scripts wrap one of these, so callers can tell failures apart with errors.Is
+var (
+	// ErrMalformedScript is wrapped by the errors of scripts that do not parse
+	ErrMalformedScript = errors.New("malformed script")
+	// ErrBadOp is wrapped by the errors of operations that fail or are unknown
+	ErrBadOp = errors.New("bad op")
+	// ErrUnbalancedConditional is wrapped when an OP_IF or OP_NOTIF is not closed by an OP_ENDIF
+	ErrUnbalancedConditional = errors.New("unbalanced conditional")
+	// ErrNotPushOnly is wrapped when a ScriptSig that may only push data does something else
+	ErrNotPushOnly = errors.New("not push only")
+	// ErrEvalFalse is wrapped when a script ends with an empty stack or false on top of it
+	ErrEvalFalse = errors.New("evaluated to false")
+	// ErrUpgradableWitnessProgram is wrapped when ScriptVerifyDiscourageUpgradableWitnessProgram rejects a spend
+	ErrUpgradableWitnessProgram = errors.New("upgradable witness program")
+	// ErrWitnessMalleated is wrapped when the ScriptSig of a witness program spend holds more than it may
+	ErrWitnessMalleated = errors.New("witness malleated")
+	// ErrWitnessNotImplemented is wrapped when a segwit version 0 or taproot spend would need its witness verified,
+	// which the script engine does not do
+	ErrWitnessNotImplemented = errors.New("witness verification not implemented")
+)
@@ -0,0 +1,278 @@
+package script
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+// VerificationFlags selects optional script verification rules
+type VerificationFlags uint32
+
+const (
+	// ScriptVerifyMinimalIf requires the argument of OP_IF and OP_NOTIF to be empty or 0x01, as segwit scripts do
+	ScriptVerifyMinimalIf VerificationFlags = 1 << iota
+	// ScriptVerifyP2SH evaluates the redeem script of pay to script hash outputs (BIP16)
+	ScriptVerifyP2SH
+	// ScriptVerifyDERSig requires strict DER encoding of signatures (BIP66)
+	ScriptVerifyDERSig
+	// ScriptVerifyCheckLockTimeVerify enforces OP_CHECKLOCKTIMEVERIFY instead of treating it as OP_NOP2 (BIP65)
+	ScriptVerifyCheckLockTimeVerify
+	// ScriptVerifyCheckSequenceVerify enforces OP_CHECKSEQUENCEVERIFY instead of treating it as OP_NOP3 (BIP112)
+	ScriptVerifyCheckSequenceVerify
+	// ScriptVerifyWitness and ScriptVerifyTaproot mark segwit (BIP141) and taproot (BIP341) as active
+	ScriptVerifyWitness
+	ScriptVerifyTaproot
+	// ScriptVerifyExtendedOpcodes enables ExtendedOpCodeFunctions. It is not a consensus rule and must not be
+	// combined with the verification of real transactions.
+	ScriptVerifyExtendedOpcodes
+	// ScriptVerifyMinimalData requires numeric operands to be minimally encoded, a policy rule of Bitcoin Core
+	ScriptVerifyMinimalData
+	// ScriptVerifyDiscourageUpgradableWitnessProgram rejects spends of the witness versions no soft fork has defined
+	// yet, which consensus lets anyone spend. It is a policy rule of Bitcoin Core, so a future soft fork cannot
+	// make nodes relay transactions that became invalid.
+	ScriptVerifyDiscourageUpgradableWitnessProgram
+	// ScriptVerifyStrictEncoding requires signatures to be strict DER with a defined hash type, a policy rule of
+	// Bitcoin Core that consensus never adopted
+	ScriptVerifyStrictEncoding
+)
+
+// StandardVerifyFlags are the rules enforced on transactions that are not part of a historical block
+const StandardVerifyFlags = ScriptVerifyP2SH | ScriptVerifyDERSig | ScriptVerifyCheckLockTimeVerify | ScriptVerifyCheckSequenceVerify
+
+// PolicyVerifyFlags add the policy rules of Bitcoin Core for relaying transactions to all soft forks. Scripts
+// that pass the consensus rules of FlagsAtHeight can still fail them, such as spends of upgradable witness programs.
+const PolicyVerifyFlags = StandardVerifyFlags | ScriptVerifyWitness | ScriptVerifyTaproot | ScriptVerifyMinimalData |
+	ScriptVerifyDiscourageUpgradableWitnessProgram | ScriptVerifyStrictEncoding
+
+// FlagsAtHeight returns the soft fork rules that apply to scripts in the block at height,
+// so historical blocks are verified the way they were when they were mined
+func FlagsAtHeight(chain *params.Params, height uint32) VerificationFlags {
+	var flags VerificationFlags
+	if height >= chain.P2SHHeight {
+		flags |= ScriptVerifyP2SH
+	}
+	if height >= chain.BIP66Height {
+		flags |= ScriptVerifyDERSig
+	}
+	if height >= chain.BIP65Height {
+		flags |= ScriptVerifyCheckLockTimeVerify
+	}
+	if height >= chain.CSVHeight {
+		flags |= ScriptVerifyCheckSequenceVerify
+	}
+	if height >= chain.SegwitHeight {
+		flags |= ScriptVerifyWitness
+	}
+	if height >= chain.TaprootHeight {
+		flags |= ScriptVerifyTaproot
+	}
+	return flags
+}
+
+// Has returns whether all bits of flag are set
+func (f VerificationFlags) Has(flag VerificationFlags) bool {
+	return f&flag == flag
+}
+
+// ExecutionContext holds everything an operation can read or change while a script is evaluated.
+// Locktime, Sequence and Version come from the transaction spending the output.
+type ExecutionContext struct {
+	Stack    Stack
+	AltStack Stack
+	// Cmds are the commands that still have to be executed
+	Cmds Script
+	// Z is the SIGHASH_ALL hash the signatures sign. Signatures of other hash types fail unless SigHash is set.
+	Z *big.Int
+	// SigHash, when set, returns the hash a signature of hashType signs, so signatures of every hash type verify
+	SigHash  func(hashType signatureverification.SigHashType) (*big.Int, error)
+	Locktime int
+	Sequence int
+	Version  int
+	Flags    VerificationFlags
+	// SigCache, when set, skips verifying signatures it already holds and stores new valid ones
+	SigCache *signatureverification.SigCache
+	// Opcodes, when set, defines opcodes before OpCodeFunctions is consulted
+	Opcodes *OpcodeRegistry
+	// Tracer, when set, is called after every command with the state before and after it
+	Tracer func(step TraceStep)
+	// conditions holds whether each enclosing OP_IF branch is being executed
+	conditions []bool
+	steps      int
+}
+
+// executing returns whether the current command is in a branch that is executed
+func (ctx *ExecutionContext) executing() bool {
+	for _, condition := range ctx.conditions {
+		if !condition {
+			return false
+		}
+	}
+	return true
+}
+
+// isConditional returns whether opCode is one of OP_IF, OP_NOTIF, OP_ELSE and OP_ENDIF,
+// which also run inside branches that are not executed
+func isConditional(opCode int) bool {
+	return opCode == 99 || opCode == 100 || opCode == 103 || opCode == 104
+}
+
+// Operation is the signature shared by every entry of OpCodeFunctions
+type Operation func(ctx *ExecutionContext) (bool, error)
+
+// The adapters below let the opcode implementations keep taking only the state they use.
+
+func stackOperation(op func(stack *Stack) (bool, error)) Operation {
+	return func(ctx *ExecutionContext) (bool, error) {
+		return op(&ctx.Stack)
+	}
+}
+
+func altStackOperation(op func(stack, altStack *Stack) (bool, error)) Operation {
+	return func(ctx *ExecutionContext) (bool, error) {
+		return op(&ctx.Stack, &ctx.AltStack)
+	}
+}
+
+// numericOperation checks the operands op reads from the top of the stack as numbers before running it
+func numericOperation(operands int, op func(stack *Stack) (bool, error)) Operation {
+	return func(ctx *ExecutionContext) (bool, error) {
+		for i := 1; i <= operands && i <= len(ctx.Stack); i++ {
+			if err := ctx.checkNum(ctx.Stack[len(ctx.Stack)-i], MaxScriptNumLen); err != nil {
+				return false, err
+			}
+		}
+		return op(&ctx.Stack)
+	}
+}
+
+// sigHasher returns the hash a signature of hashType signs
+type sigHasher func(hashType signatureverification.SigHashType) (*big.Int, error)
+
+// fixedSigHash is the sigHasher of a context with only the SIGHASH_ALL hash z
+func fixedSigHash(z *big.Int) sigHasher {
+	return func(hashType signatureverification.SigHashType) (*big.Int, error) {
+		if hashType != signatureverification.SigHashAll {
+			return nil, fmt.Errorf("signature of hash type %s needs ExecutionContext.SigHash, only the SIGHASH_ALL hash is known", hashType)
+		}
+		return z, nil
+	}
+}
+
+func (ctx *ExecutionContext) sigHasher() sigHasher {
+	if ctx.SigHash != nil {
+		return ctx.SigHash
+	}
+	return fixedSigHash(ctx.Z)
+}
+
+func signatureOperation(op func(stack *Stack, sigHash sigHasher, sigCache *signatureverification.SigCache) (bool, error)) Operation {
+	return func(ctx *ExecutionContext) (bool, error) {
+		if len(ctx.Stack) >= 2 {
+			if err := ctx.checkSignatureEncoding(ctx.Stack[len(ctx.Stack)-2]); err != nil {
+				return false, err
+			}
+		}
+		return op(&ctx.Stack, ctx.sigHasher(), ctx.SigCache)
+	}
+}
+
+func multiSignatureOperation(op func(stack *Stack, sigHash sigHasher, sigCache *signatureverification.SigCache) (bool, error)) Operation {
+	return func(ctx *ExecutionContext) (bool, error) {
+		if err := ctx.checkMultiSigCounts(); err != nil {
+			return false, err
+		}
+		for _, signature := range multiSigSignatures(ctx.Stack) {
+			if err := ctx.checkSignatureEncoding(signature); err != nil {
+				return false, err
+			}
+		}
+		return op(&ctx.Stack, ctx.sigHasher(), ctx.SigCache)
+	}
+}
+
+// multiSigSignatures returns the signatures OP_CHECKMULTISIG will consume, or nil when the stack is too short for them
+func multiSigSignatures(stack Stack) [][]byte {
+	if len(stack) < 1 {
+		return nil
+	}
+	numPubKeys := decodeNum(stack[len(stack)-1])
+	numSigsIndex := len(stack) - 2 - numPubKeys
+	if numPubKeys < 0 || numSigsIndex < 0 {
+		return nil
+	}
+	numSigs := decodeNum(stack[numSigsIndex])
+	if numSigs < 0 || numSigsIndex-numSigs < 0 {
+		return nil
+	}
+	return stack[numSigsIndex-numSigs : numSigsIndex]
+}
+
+// checkMultiSigCounts checks the public key and signature counts OP_CHECKMULTISIG reads as numbers
+func (ctx *ExecutionContext) checkMultiSigCounts() error {
+	stack := ctx.Stack
+	if len(stack) < 1 {
+		return nil
+	}
+	if err := ctx.checkNum(stack[len(stack)-1], MaxScriptNumLen); err != nil {
+		return err
+	}
+	numPubKeys := decodeNum(stack[len(stack)-1])
+	if numSigsIndex := len(stack) - 2 - numPubKeys; numPubKeys >= 0 && numSigsIndex >= 0 && numSigsIndex < len(stack) {
+		return ctx.checkNum(stack[numSigsIndex], MaxScriptNumLen)
+	}
+	return nil
+}
+
+// checkNum fails when element is too long to be a numeric operand or, with ScriptVerifyMinimalData,
+// not minimally encoded. Without this a long element would silently overflow decodeNum.
+func (ctx *ExecutionContext) checkNum(element []byte, maxLen int) error {
+	_, err := ParseScriptNum(element, maxLen, ctx.Flags.Has(ScriptVerifyMinimalData))
+	return err
+}
+
+// checkSignatureEncoding enforces BIP66 on a signature with its hash type byte, when ScriptVerifyDERSig or
+// ScriptVerifyStrictEncoding is set, and a defined hash type with ScriptVerifyStrictEncoding.
+// Empty signatures are allowed, they deliberately fail the check.
+func (ctx *ExecutionContext) checkSignatureEncoding(signature []byte) error {
+	strict := ctx.Flags.Has(ScriptVerifyStrictEncoding)
+	if !ctx.Flags.Has(ScriptVerifyDERSig) && !strict || len(signature) == 0 {
+		return nil
+	}
+	der, hashType, _ := signatureverification.SplitHashType(signature)
+	if !signatureverification.IsStrictDER(der) {
+		return fmt.Errorf("signature is not strict DER")
+	}
+	if strict && !hashType.IsDefined() {
+		return fmt.Errorf("signature has undefined hash type %s", hashType)
+	}
+	return nil
+}
+
+// Before their soft forks OP_CHECKLOCKTIMEVERIFY and OP_CHECKSEQUENCEVERIFY were OP_NOP2 and OP_NOP3
+
+func checkLockTimeVerify(ctx *ExecutionContext) (bool, error) {
+	if !ctx.Flags.Has(ScriptVerifyCheckLockTimeVerify) {
+		return true, nil
+	}
+	if len(ctx.Stack) > 0 {
+		if err := ctx.checkNum(ctx.Stack[len(ctx.Stack)-1], MaxLockTimeNumLen); err != nil {
+			return false, err
+		}
+	}
+	return opCheckLockTimeVerify(&ctx.Stack, ctx.Locktime, ctx.Sequence)
+}
+
+func checkSequenceVerify(ctx *ExecutionContext) (bool, error) {
+	if !ctx.Flags.Has(ScriptVerifyCheckSequenceVerify) {
+		return true, nil
+	}
+	if len(ctx.Stack) > 0 {
+		if err := ctx.checkNum(ctx.Stack[len(ctx.Stack)-1], MaxLockTimeNumLen); err != nil {
+			return false, err
+		}
+	}
+	return opCheckSequenceVerify(&ctx.Stack, ctx.Version, ctx.Sequence)
+}
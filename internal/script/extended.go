@@ -0,0 +1,86 @@
+package script
+
+import "fmt"
+
+// MaxScriptElementSize is the largest element a script may push or create
+const MaxScriptElementSize = 520
+
+// ExtendedOpCodeFunctions are byte string opcodes that are disabled in Bitcoin, for experimenting with
+// covenant-style scripts. They only run with ScriptVerifyExtendedOpcodes, which no consensus rule set includes.
+// OP_SPLIT takes the place of OP_SUBSTR, as in Bitcoin Cash.
+var ExtendedOpCodeFunctions = map[int]Operation{
+	126: stackOperation(opCat),
+	127: stackOperation(opSplit),
+	132: stackOperation(opAnd),
+	133: stackOperation(opOr),
+	134: stackOperation(opXor),
+}
+
+// opCat replaces the top two elements by their concatenation, the second element first
+func opCat(stack *Stack) (bool, error) {
+	if len(*stack) < 2 {
+		return false, fmt.Errorf("not enough elements in stack: %d < 2", len(*stack))
+	}
+
+	second, _ := stack.pop(-1)
+	first, _ := stack.pop(-1)
+
+	if len(first)+len(second) > MaxScriptElementSize {
+		return false, fmt.Errorf("concatenation of %d bytes exceeds the maximum element size", len(first)+len(second))
+	}
+
+	stack.push(append(append([]byte{}, first...), second...))
+	return true, nil
+}
+
+// opSplit splits the second element at the position on top of the stack
+func opSplit(stack *Stack) (bool, error) {
+	if len(*stack) < 2 {
+		return false, fmt.Errorf("not enough elements in stack: %d < 2", len(*stack))
+	}
+
+	positionEncoded, _ := stack.pop(-1)
+	data, _ := stack.pop(-1)
+
+	position := decodeNum(positionEncoded)
+	if position < 0 || position > len(data) {
+		return false, fmt.Errorf("split position %d out of range for %d bytes", position, len(data))
+	}
+
+	stack.push(append([]byte{}, data[:position]...))
+	stack.push(append([]byte{}, data[position:]...))
+	return true, nil
+}
+
+func opAnd(stack *Stack) (bool, error) {
+	return bitwise(stack, func(a, b byte) byte { return a & b })
+}
+
+func opOr(stack *Stack) (bool, error) {
+	return bitwise(stack, func(a, b byte) byte { return a | b })
+}
+
+func opXor(stack *Stack) (bool, error) {
+	return bitwise(stack, func(a, b byte) byte { return a ^ b })
+}
+
+// bitwise replaces the top two elements, which must be of the same size, by op applied to each of their bytes
+func bitwise(stack *Stack, op func(a, b byte) byte) (bool, error) {
+	if len(*stack) < 2 {
+		return false, fmt.Errorf("not enough elements in stack: %d < 2", len(*stack))
+	}
+
+	second, _ := stack.pop(-1)
+	first, _ := stack.pop(-1)
+
+	if len(first) != len(second) {
+		return false, fmt.Errorf("operands of %d and %d bytes differ in size", len(first), len(second))
+	}
+
+	result := make([]byte, len(first))
+	for i := range first {
+		result[i] = op(first[i], second[i])
+	}
+	stack.push(result)
+	return true, nil
+}
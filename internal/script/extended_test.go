@@ -0,0 +1,82 @@
+package script
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExtendedOpcodesAreOptIn(t *testing.T) {
+	// <abcd> <ef01> OP_CAT <abcdef01> OP_EQUAL
+	script := Script{{0xab, 0xcd}, {0xef, 0x01}, {0x7e}, {0xab, 0xcd, 0xef, 0x01}, {0x87}}
+
+	if ok, err := script.Execute(&ExecutionContext{Flags: StandardVerifyFlags}); ok || err == nil {
+		t.Errorf("OP_CAT should be disabled without ScriptVerifyExtendedOpcodes")
+	}
+	if ok, err := script.Execute(&ExecutionContext{Flags: ScriptVerifyExtendedOpcodes}); !ok || err != nil {
+		t.Errorf("OP_CAT should concatenate in extended mode: %v", err)
+	}
+}
+
+func TestOpCat(t *testing.T) {
+	stack := Stack{{0x01, 0x02}, {0x03}}
+	if ok, err := opCat(&stack); !ok || err != nil || !bytes.Equal(stack[0], []byte{0x01, 0x02, 0x03}) {
+		t.Errorf("opCat = %x, %v", stack, err)
+	}
+
+	stack = Stack{make([]byte, MaxScriptElementSize), {0x01}}
+	if _, err := opCat(&stack); err == nil {
+		t.Errorf("Concatenation over the element size limit should fail")
+	}
+
+	stack = Stack{{0x01}}
+	if _, err := opCat(&stack); err == nil {
+		t.Errorf("opCat with a single element should fail")
+	}
+}
+
+func TestOpSplit(t *testing.T) {
+	stack := Stack{{0x01, 0x02, 0x03}, encodeNum(1)}
+	if ok, err := opSplit(&stack); !ok || err != nil {
+		t.Fatalf("opSplit error: %v", err)
+	}
+	if len(stack) != 2 || !bytes.Equal(stack[0], []byte{0x01}) || !bytes.Equal(stack[1], []byte{0x02, 0x03}) {
+		t.Errorf("opSplit = %x", stack)
+	}
+
+	// splitting at either end leaves an empty element
+	stack = Stack{{0x01, 0x02}, encodeNum(2)}
+	if ok, err := opSplit(&stack); !ok || err != nil || len(stack[1]) != 0 {
+		t.Errorf("opSplit at the end = %x, %v", stack, err)
+	}
+
+	for _, position := range []int{3, -1} {
+		stack = Stack{{0x01, 0x02}, encodeNum(position)}
+		if _, err := opSplit(&stack); err == nil {
+			t.Errorf("Split at %d should be out of range", position)
+		}
+	}
+}
+
+func TestBitwiseOpcodes(t *testing.T) {
+	tests := []struct {
+		name string
+		op   func(stack *Stack) (bool, error)
+		want []byte
+	}{
+		{"OP_AND", opAnd, []byte{0x00, 0x0f}},
+		{"OP_OR", opOr, []byte{0xff, 0xff}},
+		{"OP_XOR", opXor, []byte{0xff, 0xf0}},
+	}
+
+	for _, tt := range tests {
+		stack := Stack{{0xf0, 0x0f}, {0x0f, 0xff}}
+		if ok, err := tt.op(&stack); !ok || err != nil || !bytes.Equal(stack[0], tt.want) {
+			t.Errorf("%s = %x, %v, want %x", tt.name, stack, err, tt.want)
+		}
+
+		stack = Stack{{0xf0}, {0x0f, 0xff}}
+		if _, err := tt.op(&stack); err == nil {
+			t.Errorf("%s of operands of a different size should fail", tt.name)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package script
+
+import "fmt"
+
+// MaxMultisigKeys is the most keys of a multisig script built here, the most OP_1 through OP_16 can count
+const MaxMultisigKeys = 16
+
+// CreateMultisigScript returns the m-of-n script OP_m <pubkey>... OP_n OP_CHECKMULTISIG that needs required
+// signatures by pubkeys, in the order given. As a ScriptPubKey it is a bare multisig output.
+func CreateMultisigScript(required int, pubkeys [][]byte) (*Script, error) {
+	if len(pubkeys) == 0 || len(pubkeys) > MaxMultisigKeys {
+		return nil, fmt.Errorf("multisig needs 1 to %d keys, got %d", MaxMultisigKeys, len(pubkeys))
+	}
+	if required < 1 || required > len(pubkeys) {
+		return nil, fmt.Errorf("multisig can require 1 to %d signatures, got %d", len(pubkeys), required)
+	}
+	builder := NewBuilder().PushInt(int64(required))
+	for _, pubkey := range pubkeys {
+		if !isMultisigKey(pubkey) {
+			return nil, fmt.Errorf("%x is not a SEC public key", pubkey)
+		}
+		builder.PushData(pubkey)
+	}
+	return builder.PushInt(int64(len(pubkeys))).Op("OP_CHECKMULTISIG").Script()
+}
+
+// ParseMultisig returns the number of signatures and the keys of an m-of-n OP_CHECKMULTISIG script, such as a bare
+// multisig ScriptPubKey or the redeem script of a p2sh multisig output. A nil script is not one.
+func (s *Script) ParseMultisig() (int, [][]byte, bool) {
+	if s == nil {
+		return 0, nil, false
+	}
+	cmds := *s
+	if len(cmds) < 4 || !isOpCode(cmds[0]) || !isOpCode(cmds[len(cmds)-2]) || !IsOp(cmds[len(cmds)-1], 0xae) {
+		return 0, nil, false
+	}
+	required, total := int(cmds[0][0])-0x50, int(cmds[len(cmds)-2][0])-0x50
+	keys := cmds[1 : len(cmds)-2]
+	if required < 1 || total > MaxMultisigKeys || required > total || len(keys) != total {
+		return 0, nil, false
+	}
+	for _, key := range keys {
+		if !isMultisigKey(key) {
+			return 0, nil, false
+		}
+	}
+	return required, keys, true
+}
+
+// IsMultisigScriptPubKey returns whether s is a bare m-of-n multisig ScriptPubKey
+func (s *Script) IsMultisigScriptPubKey() bool {
+	_, _, ok := s.ParseMultisig()
+	return ok
+}
+
+// isMultisigKey returns whether key has the length of a compressed or uncompressed SEC public key
+func isMultisigKey(key []byte) bool {
+	return len(key) == 33 || len(key) == 65
+}
@@ -0,0 +1,68 @@
+package script
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCreateMultisigScript(t *testing.T) {
+	keys := [][]byte{bytes.Repeat([]byte{0x02}, 33), bytes.Repeat([]byte{0x03}, 33), bytes.Repeat([]byte{0x04}, 65)}
+	multisig, err := CreateMultisigScript(2, keys)
+	if err != nil {
+		t.Fatalf("CreateMultisigScript error: %v", err)
+	}
+	if len(*multisig) != 6 || (*multisig)[0][0] != 0x52 || (*multisig)[4][0] != 0x53 || (*multisig)[5][0] != 0xae {
+		t.Errorf("Unexpected 2-of-3 script %s", multisig)
+	}
+
+	required, parsed, ok := multisig.ParseMultisig()
+	if !ok || required != 2 || len(parsed) != 3 {
+		t.Fatalf("ParseMultisig = %d, %d keys, %v, want 2-of-3", required, len(parsed), ok)
+	}
+	for i := range keys {
+		if !bytes.Equal(parsed[i], keys[i]) {
+			t.Errorf("Key %d = %x, want %x", i, parsed[i], keys[i])
+		}
+	}
+	if !multisig.IsMultisigScriptPubKey() {
+		t.Errorf("2-of-3 script should be a bare multisig ScriptPubKey")
+	}
+
+	for _, tc := range []struct {
+		required int
+		keys     [][]byte
+	}{
+		{0, keys},
+		{4, keys},
+		{1, nil},
+		{1, [][]byte{bytes.Repeat([]byte{0x02}, 32)}},
+		{1, make([][]byte, MaxMultisigKeys+1)},
+	} {
+		if _, err := CreateMultisigScript(tc.required, tc.keys); err == nil {
+			t.Errorf("CreateMultisigScript(%d, %d keys) should fail", tc.required, len(tc.keys))
+		}
+	}
+}
+
+func TestParseMultisigRejects(t *testing.T) {
+	key := bytes.Repeat([]byte{0x02}, 33)
+	for _, s := range []Script{
+		*CreateP2pkhScript(make([]byte, 20)),
+		// the count of keys does not match
+		{[]byte{0x51}, key, []byte{0x52}, []byte{0xae}},
+		// more signatures than keys
+		{[]byte{0x52}, key, []byte{0x51}, []byte{0xae}},
+		// a key of the wrong length
+		{[]byte{0x51}, key[:20], []byte{0x51}, []byte{0xae}},
+		// OP_CHECKMULTISIGVERIFY
+		{[]byte{0x51}, key, []byte{0x51}, []byte{0xaf}},
+	} {
+		if _, _, ok := s.ParseMultisig(); ok {
+			t.Errorf("%s should not parse as multisig", &s)
+		}
+	}
+	var missing *Script
+	if missing.IsMultisigScriptPubKey() {
+		t.Errorf("A nil script should not be multisig")
+	}
+}
@@ -12,65 +12,11 @@ import (
 type Stack [][]byte
 
 func encodeNum(num int) []byte {
-	if num == 0 {
-		return []byte{}
-	}
-
-	absNum := num
-	if absNum < 0 {
-		absNum = -absNum
-	}
-
-	var result []byte
-	for absNum > 0 {
-		result = append(result, byte(absNum&0xff))
-		absNum >>= 8
-	}
-
-	if result[len(result)-1]&0x80 != 0 {
-		if num < 0 {
-			result = append(result, 0x80)
-		} else {
-			result = append(result, 0)
-		}
-	} else if num < 0 {
-		result[len(result)-1] |= 0x80
-	}
-
-	return result
+	return ScriptNum(num).Bytes()
 }
 
 func decodeNum(element []byte) int {
-	if len(element) == 0 {
-		return 0
-	}
-
-	var bigEndian []byte
-	for i := len(element) - 1; i >= 0; i-- {
-		bigEndian = append(bigEndian, element[i])
-	}
-
-	var negative bool
-	var result int
-
-	if bigEndian[0]&0x80 != 0 {
-		negative = true
-		result = int(bigEndian[0] & 0x7f)
-	} else {
-		negative = false
-		result = int(bigEndian[0])
-	}
-
-	for _, c := range bigEndian[1:] {
-		result <<= 8
-		result += int(c)
-	}
-
-	if negative {
-		return -result
-	}
-
-	return result
+	return int(DecodeScriptNum(element))
 }
 
 func op0(stack *Stack) (bool, error) {
@@ -167,104 +113,64 @@ func opNop(stack *Stack) (bool, error) {
 	return true, nil
 }
 
-func opIf(stack, items *Stack) (bool, error) {
-	if len(*stack) < 1 {
-		return false, fmt.Errorf("stack is empty")
-	}
-
-	// go through and re-make the items array based on the top stack element
-	trueItems, falseItems := new(Stack), new(Stack)
-	var found bool
-	currentArray := trueItems
-	numEndifsNeeded := 1
+func opIf(ctx *ExecutionContext) (bool, error) {
+	return pushCondition(ctx, false)
+}
 
-	for len(*items) > 0 {
-		item, err := items.pop(0)
-		if err != nil {
-			return false, err
-		}
+func opNotIf(ctx *ExecutionContext) (bool, error) {
+	return pushCondition(ctx, true)
+}
 
-		if bytes.Equal(item, encodeNum(99)) || bytes.Equal(item, encodeNum(100)) {
-			// nested if, we have to go another endif
-			numEndifsNeeded++
-			*currentArray = append(*currentArray, item)
-		} else if numEndifsNeeded == 1 && bytes.Equal(item, encodeNum(103)) {
-			currentArray = falseItems
-		} else if bytes.Equal(item, encodeNum(104)) {
-			if numEndifsNeeded == 1 {
-				found = true
-				break
-			} else {
-				numEndifsNeeded--
-				*currentArray = append(*currentArray, item)
-			}
-		} else {
-			*currentArray = append(*currentArray, item)
-		}
+// pushCondition opens a conditional branch based on the top stack element.
+// Inside a branch that is not executed nothing is popped and the new branch is not executed either.
+func pushCondition(ctx *ExecutionContext, negate bool) (bool, error) {
+	if !ctx.executing() {
+		ctx.conditions = append(ctx.conditions, false)
+		return true, nil
 	}
 
-	if !found {
-		return false, nil
+	element, err := ctx.Stack.pop(-1)
+	if err != nil {
+		return false, err
 	}
 
-	element, _ := stack.pop(-1)
-	if bytes.Equal(element, encodeNum(0)) {
-		*items = append(*falseItems, *items...)
-	} else {
-		*items = append(*trueItems, *items...)
+	if ctx.Flags.Has(ScriptVerifyMinimalIf) && !(len(element) == 0 || (len(element) == 1 && element[0] == 1)) {
+		return false, fmt.Errorf("argument of conditional is not minimal: %x", element)
 	}
 
+	ctx.conditions = append(ctx.conditions, castToBool(element) != negate)
 	return true, nil
 }
 
-func opNotIf(stack, items *Stack) (bool, error) {
-	if len(*stack) < 1 {
-		return false, fmt.Errorf("stack is empty")
+func opElse(ctx *ExecutionContext) (bool, error) {
+	if len(ctx.conditions) == 0 {
+		return false, fmt.Errorf("OP_ELSE without OP_IF")
 	}
 
-	// go through and re-make the items array based on the top stack element
-	trueItems, falseItems := new(Stack), new(Stack)
-	var found bool
-	currentArray := trueItems
-	numEndifsNeeded := 1
-
-	for len(*items) > 0 {
-		item, err := items.pop(0)
-		if err != nil {
-			return false, err
-		}
+	// every OP_ELSE flips the branch, so OP_IF a OP_ELSE b OP_ELSE c executes a and c
+	top := len(ctx.conditions) - 1
+	ctx.conditions[top] = !ctx.conditions[top]
+	return true, nil
+}
 
-		if bytes.Equal(item, encodeNum(99)) || bytes.Equal(item, encodeNum(100)) {
-			// nested if, we have to go another endif
-			numEndifsNeeded++
-			*currentArray = append(*currentArray, item)
-		} else if numEndifsNeeded == 1 && bytes.Equal(item, encodeNum(103)) {
-			currentArray = falseItems
-		} else if bytes.Equal(item, encodeNum(104)) {
-			if numEndifsNeeded == 1 {
-				found = true
-				break
-			} else {
-				numEndifsNeeded--
-				*currentArray = append(*currentArray, item)
-			}
-		} else {
-			*currentArray = append(*currentArray, item)
-		}
+func opEndIf(ctx *ExecutionContext) (bool, error) {
+	if len(ctx.conditions) == 0 {
+		return false, fmt.Errorf("OP_ENDIF without OP_IF")
 	}
 
-	if !found {
-		return false, nil
-	}
+	ctx.conditions = ctx.conditions[:len(ctx.conditions)-1]
+	return true, nil
+}
 
-	element, _ := stack.pop(-1)
-	if bytes.Equal(element, encodeNum(0)) {
-		*items = append(*trueItems, *items...)
-	} else {
-		*items = append(*falseItems, *items...)
+// castToBool returns false for any encoding of zero, including negative zero
+func castToBool(element []byte) bool {
+	for i, b := range element {
+		if b != 0 {
+			// 0x80 as the last byte is the sign bit of negative zero
+			return !(i == len(element)-1 && b == 0x80)
+		}
 	}
-
-	return true, nil
+	return false
 }
 
 func opVerify(stack *Stack) (bool, error) {
@@ -274,7 +180,7 @@ func opVerify(stack *Stack) (bool, error) {
 		return false, err
 	}
 
-	return (decodeNum(element) != 0), nil
+	return castToBool(element), nil
 }
 
 func opReturn(stack *Stack) (bool, error) {
@@ -371,7 +277,7 @@ func opIfDup(stack *Stack) (bool, error) {
 
 	element := (*stack)[len(*stack)-1]
 
-	if decodeNum(element) != 0 {
+	if castToBool(element) {
 		stack.push(element)
 	}
 
@@ -432,6 +338,9 @@ func opPick(stack *Stack) (bool, error) {
 	}
 
 	n := decodeNum(element)
+	if n < 0 {
+		return false, fmt.Errorf("negative stack index %d", n)
+	}
 
 	if len(*stack) < n+1 {
 		return false, fmt.Errorf("not enough elements in stack: %d < %d", len(*stack), n+1)
@@ -450,6 +359,9 @@ func opRoll(stack *Stack) (bool, error) {
 	}
 
 	n := decodeNum(element)
+	if n < 0 {
+		return false, fmt.Errorf("negative stack index %d", n)
+	}
 
 	if len(*stack) < n+1 {
 		return false, fmt.Errorf("not enough elements in stack: %d < %d", len(*stack), n+1)
@@ -1011,6 +923,12 @@ func opHash256(stack *Stack) (bool, error) {
 }
 
 func opCheckSig(stack *Stack, z *big.Int) (bool, error) {
+	return checkSig(stack, fixedSigHash(z), nil)
+}
+
+// checkSig is OP_CHECKSIG verifying through sigCache, which may be nil, against the hash of the hash type of
+// the signature
+func checkSig(stack *Stack, sigHash sigHasher, sigCache *signatureverification.SigCache) (bool, error) {
 	if len(*stack) < 2 {
 		return false, fmt.Errorf("not enough elements in stack: %d < 2", len(*stack))
 	}
@@ -1025,8 +943,17 @@ func opCheckSig(stack *Stack, z *big.Int) (bool, error) {
 		return false, err
 	}
 
-	// take off the last byte of the signature as that"s the hash type
-	derSignature, err := signatureverification.ParseDER(derSignatureBytes[:len(derSignatureBytes)-1])
+	// an empty signature is how a script deliberately fails a signature check
+	if len(derSignatureBytes) == 0 {
+		op0(stack)
+		return true, nil
+	}
+
+	signature, err := signatureverification.ParseSignatureWithAnyHashType(derSignatureBytes)
+	if err != nil {
+		return false, err
+	}
+	z, err := sigHash(signature.HashType)
 	if err != nil {
 		return false, err
 	}
@@ -1036,7 +963,7 @@ func opCheckSig(stack *Stack, z *big.Int) (bool, error) {
 		return false, err
 	}
 
-	if !point.Verify(z, derSignature) {
+	if !sigCache.Verify(point, z, signature.Signature) {
 		op0(stack)
 		return false, fmt.Errorf("signature validation failed")
 	}
@@ -1046,7 +973,11 @@ func opCheckSig(stack *Stack, z *big.Int) (bool, error) {
 }
 
 func opCheckSigVerify(stack *Stack, z *big.Int) (bool, error) {
-	resultCheckSig, err := opCheckSig(stack, z)
+	return checkSigVerify(stack, fixedSigHash(z), nil)
+}
+
+func checkSigVerify(stack *Stack, sigHash sigHasher, sigCache *signatureverification.SigCache) (bool, error) {
+	resultCheckSig, err := checkSig(stack, sigHash, sigCache)
 
 	if err != nil || !resultCheckSig {
 		return false, err
@@ -1057,6 +988,12 @@ func opCheckSigVerify(stack *Stack, z *big.Int) (bool, error) {
 
 // opCheckMultiSig implements the OP_CHECKMULTISIG operation in Go.
 func opCheckMultiSig(stack *Stack, z *big.Int) (bool, error) {
+	return checkMultiSig(stack, fixedSigHash(z), nil)
+}
+
+// checkMultiSig is OP_CHECKMULTISIG verifying through sigCache, which may be nil, every signature against the
+// hash of its own hash type
+func checkMultiSig(stack *Stack, sigHash sigHasher, sigCache *signatureverification.SigCache) (bool, error) {
 	var secPubKey *signatureverification.S256Point
 	var numOk int
 
@@ -1070,6 +1007,9 @@ func opCheckMultiSig(stack *Stack, z *big.Int) (bool, error) {
 	}
 
 	numPubKeys := decodeNum(numPubKeysEncoded)
+	if numPubKeys < 0 || numPubKeys > MaxPubKeysPerMultisig {
+		return false, fmt.Errorf("public key count %d out of range", numPubKeys)
+	}
 
 	if len(*stack) < numPubKeys+1 {
 		return false, fmt.Errorf("not enough elements in stack for public keys")
@@ -1093,22 +1033,29 @@ func opCheckMultiSig(stack *Stack, z *big.Int) (bool, error) {
 	}
 
 	numSigs := decodeNum(numSigsEncoded)
+	if numSigs < 0 || numSigs > numPubKeys {
+		return false, fmt.Errorf("signature count %d out of range", numSigs)
+	}
 
 	if len(*stack) < numSigs+1 {
 		return false, fmt.Errorf("not enough elements in stack for signatures")
 	}
 
 	derSignatures := make([]*signatureverification.Signature, numSigs)
+	zs := make([]*big.Int, numSigs)
 	for i := 0; i < int(numSigs); i++ {
 		derSignatureBytes, err := stack.pop(-1)
 		if err != nil {
 			return false, err
 		}
-		// Remove the last byte of the signature (hash type)
-		derSignatures[i], err = signatureverification.ParseDER(derSignatureBytes[:len(derSignatureBytes)-1])
+		signature, err := signatureverification.ParseSignatureWithAnyHashType(derSignatureBytes)
 		if err != nil {
 			return false, err
 		}
+		derSignatures[i] = signature.Signature
+		if zs[i], err = sigHash(signature.HashType); err != nil {
+			return false, err
+		}
 	}
 
 	// Pop the extra element from the stack (due to the OP_CHECKMULTISIG off-by-one bug)
@@ -1117,10 +1064,10 @@ func opCheckMultiSig(stack *Stack, z *big.Int) (bool, error) {
 		return false, err
 	}
 
-	for _, sig := range derSignatures {
+	for i, sig := range derSignatures {
 		for len(secPubKeys) > 0 {
 			secPubKey, secPubKeys = secPubKeys[0], secPubKeys[1:]
-			if !secPubKey.Verify(z, sig) {
+			if !sigCache.Verify(secPubKey, zs[i], sig) {
 				continue
 			}
 			numOk += 1
@@ -1137,7 +1084,11 @@ func opCheckMultiSig(stack *Stack, z *big.Int) (bool, error) {
 }
 
 func opCheckMultiSigVerify(stack *Stack, z *big.Int) (bool, error) {
-	resultCheckMultiSig, err := opCheckMultiSig(stack, z)
+	return checkMultiSigVerify(stack, fixedSigHash(z), nil)
+}
+
+func checkMultiSigVerify(stack *Stack, sigHash sigHasher, sigCache *signatureverification.SigCache) (bool, error) {
+	resultCheckMultiSig, err := checkMultiSig(stack, sigHash, sigCache)
 
 	if err != nil || !resultCheckMultiSig {
 		return false, err
@@ -1146,6 +1097,8 @@ func opCheckMultiSigVerify(stack *Stack, z *big.Int) (bool, error) {
 	return opVerify(stack)
 }
 
+// opCheckLockTimeVerify checks the absolute locktime of BIP65: the transaction locktime has to be of the same kind as
+// the element and at least as late, and the input must not be final
 func opCheckLockTimeVerify(stack *Stack, locktime, sequence int) (bool, error) {
 	if sequence == 0xffffffff {
 		return false, fmt.Errorf("invalid sequence value")
@@ -1160,7 +1113,7 @@ func opCheckLockTimeVerify(stack *Stack, locktime, sequence int) (bool, error) {
 		return false, fmt.Errorf("negative element in stack")
 	}
 
-	if element < 500000000 && locktime > 500000000 {
+	if element < 500000000 && locktime >= 500000000 {
 		return false, fmt.Errorf("locktime exceeds 500000000 for element less than 500000000")
 	}
 
@@ -1171,11 +1124,10 @@ func opCheckLockTimeVerify(stack *Stack, locktime, sequence int) (bool, error) {
 	return true, nil
 }
 
+// opCheckSequenceVerify checks the relative locktime of BIP112. An element with the disable flag set passes as
+// OP_NOP3 did, any other one requires a version 2 transaction whose input sequence has a relative locktime of the
+// same kind and at least as long.
 func opCheckSequenceVerify(stack *Stack, version, sequence int) (bool, error) {
-	if sequence&(1<<31) == (1 << 31) {
-		return false, fmt.Errorf("invalid sequence value")
-	}
-
 	if len(*stack) < 1 {
 		return false, fmt.Errorf("stack is empty")
 	}
@@ -1185,18 +1137,24 @@ func opCheckSequenceVerify(stack *Stack, version, sequence int) (bool, error) {
 		return false, fmt.Errorf("negative element in stack")
 	}
 
-	if element&(1<<31) == (1 << 31) {
-		if version < 2 {
-			return false, fmt.Errorf("version is less than 2 for sequence with sign bit set")
-		}
+	if element&(1<<31) != 0 {
+		return true, nil
+	}
 
-		if element&(1<<22) != sequence&(1<<22) {
-			return false, fmt.Errorf("mismatch in bits 22-31 between element and sequence")
-		}
+	if version < 2 {
+		return false, fmt.Errorf("version is less than 2")
+	}
 
-		if element&0xffff > sequence&0xffff {
-			return false, fmt.Errorf("sequence value is less than element in stack")
-		}
+	if sequence&(1<<31) != 0 {
+		return false, fmt.Errorf("invalid sequence value")
+	}
+
+	if element&(1<<22) != sequence&(1<<22) {
+		return false, fmt.Errorf("mismatch in type flag between element and sequence")
+	}
+
+	if element&0xffff > sequence&0xffff {
+		return false, fmt.Errorf("sequence value is less than element in stack")
 	}
 
 	return true, nil
@@ -1241,93 +1199,105 @@ func (stack *Stack) insert(index int, element []byte) error {
 	return nil
 }
 
-// OpCodeFunctions is a map of opcode values to their corresponding functions
-var OpCodeFunctions = map[int]interface{}{
-	0:   op0,
-	79:  op1Negate,
-	81:  op1,
-	82:  op2,
-	83:  op3,
-	84:  op4,
-	85:  op5,
-	86:  op6,
-	87:  op7,
-	88:  op8,
-	89:  op9,
-	90:  op10,
-	91:  op11,
-	92:  op12,
-	93:  op13,
-	94:  op14,
-	95:  op15,
-	96:  op16,
-	97:  opNop,
+// copy returns a stack with copies of the elements of stack, which later operations on stack do not change
+func (stack Stack) copy() Stack {
+	result := make(Stack, len(stack))
+	for i, element := range stack {
+		result[i] = append([]byte{}, element...)
+	}
+	return result
+}
+
+// OpCodeFunctions is a map of opcode values to their corresponding operations.
+// It is only read during evaluation, so scripts can be evaluated concurrently.
+var OpCodeFunctions = map[int]Operation{
+	0:   stackOperation(op0),
+	79:  stackOperation(op1Negate),
+	81:  stackOperation(op1),
+	82:  stackOperation(op2),
+	83:  stackOperation(op3),
+	84:  stackOperation(op4),
+	85:  stackOperation(op5),
+	86:  stackOperation(op6),
+	87:  stackOperation(op7),
+	88:  stackOperation(op8),
+	89:  stackOperation(op9),
+	90:  stackOperation(op10),
+	91:  stackOperation(op11),
+	92:  stackOperation(op12),
+	93:  stackOperation(op13),
+	94:  stackOperation(op14),
+	95:  stackOperation(op15),
+	96:  stackOperation(op16),
+	97:  stackOperation(opNop),
 	99:  opIf,
 	100: opNotIf,
-	105: opVerify,
-	106: opReturn,
-	107: opToAltStack,
-	108: opFromAltStack,
-	109: op2Drop,
-	110: op2Dup,
-	111: op3Dup,
-	112: op2Over,
-	113: op2Rot,
-	114: op2Swap,
-	115: opIfDup,
-	116: opDepth,
-	117: opDrop,
-	118: opDup,
-	119: opNip,
-	120: opOver,
-	121: opPick,
-	122: opRoll,
-	123: opRot,
-	124: opSwap,
-	125: opTuck,
-	130: opSize,
-	135: opEqual,
-	136: opEqualVerify,
-	139: op1Add,
-	140: op1Sub,
-	143: opNegate,
-	144: opAbs,
-	145: opNot,
-	146: op0NotEqual,
-	147: opAdd,
-	148: opSub,
-	149: opMul,
-	154: opBoolAnd,
-	155: opBoolOr,
-	156: opNumEqual,
-	157: opNumEqualVerify,
-	158: opNumNotEqual,
-	159: opLessThan,
-	160: opGreaterThan,
-	161: opLessThanOrEqual,
-	162: opGreaterThanOrEqual,
-	163: opMin,
-	164: opMax,
-	165: opWithin,
-	166: opRipemd160,
-	167: opSha1,
-	168: opSha256,
-	169: opHash160,
-	170: opHash256,
-	172: opCheckSig,
-	173: opCheckSigVerify,
-	174: opCheckMultiSig,
-	175: opCheckMultiSigVerify,
-	176: opNop,
-	177: opCheckLockTimeVerify,
-	178: opCheckSequenceVerify,
-	179: opNop,
-	180: opNop,
-	181: opNop,
-	182: opNop,
-	183: opNop,
-	184: opNop,
-	185: opNop,
+	103: opElse,
+	104: opEndIf,
+	105: stackOperation(opVerify),
+	106: stackOperation(opReturn),
+	107: altStackOperation(opToAltStack),
+	108: altStackOperation(opFromAltStack),
+	109: stackOperation(op2Drop),
+	110: stackOperation(op2Dup),
+	111: stackOperation(op3Dup),
+	112: stackOperation(op2Over),
+	113: stackOperation(op2Rot),
+	114: stackOperation(op2Swap),
+	115: stackOperation(opIfDup),
+	116: stackOperation(opDepth),
+	117: stackOperation(opDrop),
+	118: stackOperation(opDup),
+	119: stackOperation(opNip),
+	120: stackOperation(opOver),
+	121: numericOperation(1, opPick),
+	122: numericOperation(1, opRoll),
+	123: stackOperation(opRot),
+	124: stackOperation(opSwap),
+	125: stackOperation(opTuck),
+	130: stackOperation(opSize),
+	135: stackOperation(opEqual),
+	136: stackOperation(opEqualVerify),
+	139: numericOperation(1, op1Add),
+	140: numericOperation(1, op1Sub),
+	143: numericOperation(1, opNegate),
+	144: numericOperation(1, opAbs),
+	145: numericOperation(1, opNot),
+	146: numericOperation(1, op0NotEqual),
+	147: numericOperation(2, opAdd),
+	148: numericOperation(2, opSub),
+	149: numericOperation(2, opMul),
+	154: numericOperation(2, opBoolAnd),
+	155: numericOperation(2, opBoolOr),
+	156: numericOperation(2, opNumEqual),
+	157: numericOperation(2, opNumEqualVerify),
+	158: numericOperation(2, opNumNotEqual),
+	159: numericOperation(2, opLessThan),
+	160: numericOperation(2, opGreaterThan),
+	161: numericOperation(2, opLessThanOrEqual),
+	162: numericOperation(2, opGreaterThanOrEqual),
+	163: numericOperation(2, opMin),
+	164: numericOperation(2, opMax),
+	165: numericOperation(3, opWithin),
+	166: stackOperation(opRipemd160),
+	167: stackOperation(opSha1),
+	168: stackOperation(opSha256),
+	169: stackOperation(opHash160),
+	170: stackOperation(opHash256),
+	172: signatureOperation(checkSig),
+	173: signatureOperation(checkSigVerify),
+	174: multiSignatureOperation(checkMultiSig),
+	175: multiSignatureOperation(checkMultiSigVerify),
+	176: stackOperation(opNop),
+	177: checkLockTimeVerify,
+	178: checkSequenceVerify,
+	179: stackOperation(opNop),
+	180: stackOperation(opNop),
+	181: stackOperation(opNop),
+	182: stackOperation(opNop),
+	183: stackOperation(opNop),
+	184: stackOperation(opNop),
+	185: stackOperation(opNop),
 }
 
 var opCodeNames = map[int]string{
@@ -1378,7 +1348,12 @@ var opCodeNames = map[int]string{
 	123: "OP_ROT",
 	124: "OP_SWAP",
 	125: "OP_TUCK",
+	126: "OP_CAT",
+	127: "OP_SPLIT",
 	130: "OP_SIZE",
+	132: "OP_AND",
+	133: "OP_OR",
+	134: "OP_XOR",
 	135: "OP_EQUAL",
 	136: "OP_EQUALVERIFY",
 	139: "OP_1ADD",
@@ -6,10 +6,11 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"math/big"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/caspereijkens/cryptocurrency/internal/utils"
-	"golang.org/x/crypto/ripemd160"
 )
 
 func TestEncodeDecodeNum(t *testing.T) {
@@ -99,125 +100,189 @@ func TestOpNop(t *testing.T) {
 }
 
 func TestOpIf(t *testing.T) {
-	// Test case 1: Regular if statement without else
-	stack1 := &Stack{encodeNum(1)}
-	items1 := &Stack{encodeNum(1), encodeNum(104)}
+	// Test case 1: true condition executes the branch
+	ctx1 := &ExecutionContext{Stack: Stack{encodeNum(1)}}
+	result1, err1 := opIf(ctx1)
+	assertOpIfResult(t, result1, err1, ctx1, []bool{true})
 
-	result1, err1 := opIf(stack1, items1)
-	assertOpIfResult(t, result1, err1, stack1, &Stack{}, items1, &Stack{encodeNum(1)}, true)
-
-	// Test case 2: Regular if statement (false condition)
-	stack2 := &Stack{encodeNum(0)}
-	items2 := &Stack{encodeNum(5), encodeNum(104)}
-
-	result2, err2 := opIf(stack2, items2)
-	assertOpIfResult(t, result2, err2, stack2, &Stack{}, items2, &Stack{}, true)
+	// Test case 2: false condition skips the branch
+	ctx2 := &ExecutionContext{Stack: Stack{encodeNum(0)}}
+	result2, err2 := opIf(ctx2)
+	assertOpIfResult(t, result2, err2, ctx2, []bool{false})
 
 	// Test case 3: Empty stack
-	stack3 := &Stack{}
-	items3 := &Stack{encodeNum(1), encodeNum(104)}
-
-	_, err3 := opIf(stack3, items3)
+	_, err3 := opIf(&ExecutionContext{})
 	assertOpIfError(t, err3, "stack is empty")
 
-	// Test case 4: Nested if/else statement
-	stack4 := &Stack{encodeNum(1)}
-	items4 := &Stack{encodeNum(99), encodeNum(1), encodeNum(103), encodeNum(2), encodeNum(104), encodeNum(104)}
-
-	result4, err4 := opIf(stack4, items4)
-	assertOpIfResult(t, result4, err4, stack4, &Stack{}, items4, &Stack{encodeNum(99), encodeNum(1), encodeNum(103), encodeNum(2), encodeNum(104)}, true)
-
-	// Test case 5 if/else statement
-	stack5 := &Stack{encodeNum(0)}
-	items5 := &Stack{encodeNum(1), encodeNum(103), encodeNum(2), encodeNum(104)}
+	// Test case 4: nested in a branch that is not executed, nothing is popped
+	ctx4 := &ExecutionContext{Stack: Stack{encodeNum(1)}, conditions: []bool{false}}
+	result4, err4 := opIf(ctx4)
+	if len(ctx4.Stack) != 1 {
+		t.Errorf("opIf should not pop inside a branch that is not executed")
+	}
+	assertOpIfResult(t, result4, err4, ctx4, []bool{false, false})
 
-	result5, err5 := opIf(stack5, items5)
-	assertOpIfResult(t, result5, err5, stack5, &Stack{}, items5, &Stack{encodeNum(2)}, true)
+	// Test case 5: negative zero is false
+	ctx5 := &ExecutionContext{Stack: Stack{{0x00, 0x80}}}
+	result5, err5 := opIf(ctx5)
+	assertOpIfResult(t, result5, err5, ctx5, []bool{false})
 }
 
 func TestOpNotIf(t *testing.T) {
-	// Test case 1: Regular if statement without else
-	stack1 := &Stack{encodeNum(0)}
-	items1 := &Stack{encodeNum(1), encodeNum(104)}
-
-	result1, err1 := opNotIf(stack1, items1)
-	assertOpNotIfResult(t, result1, err1, stack1, &Stack{}, items1, &Stack{encodeNum(1)}, true)
+	// Test case 1: false condition executes the branch
+	ctx1 := &ExecutionContext{Stack: Stack{encodeNum(0)}}
+	result1, err1 := opNotIf(ctx1)
+	assertOpIfResult(t, result1, err1, ctx1, []bool{true})
 
-	// Test case 2: Regular if statement (false condition)
-	stack2 := &Stack{encodeNum(1)}
-	items2 := &Stack{encodeNum(5), encodeNum(104)}
-
-	result2, err2 := opNotIf(stack2, items2)
-	assertOpNotIfResult(t, result2, err2, stack2, &Stack{}, items2, &Stack{}, true)
+	// Test case 2: true condition skips the branch
+	ctx2 := &ExecutionContext{Stack: Stack{encodeNum(5)}}
+	result2, err2 := opNotIf(ctx2)
+	assertOpIfResult(t, result2, err2, ctx2, []bool{false})
 
 	// Test case 3: Empty stack
-	stack3 := &Stack{}
-	items3 := &Stack{encodeNum(1), encodeNum(104)}
-
-	_, err3 := opNotIf(stack3, items3)
-	assertOpNotIfError(t, err3, "stack is empty")
-
-	// Test case 4: Nested if/else statement
-	stack4 := &Stack{encodeNum(0)}
-	items4 := &Stack{encodeNum(99), encodeNum(1), encodeNum(103), encodeNum(2), encodeNum(104), encodeNum(104)}
+	_, err3 := opNotIf(&ExecutionContext{})
+	assertOpIfError(t, err3, "stack is empty")
+}
 
-	result4, err4 := opNotIf(stack4, items4)
-	assertOpNotIfResult(t, result4, err4, stack4, &Stack{}, items4, &Stack{encodeNum(99), encodeNum(1), encodeNum(103), encodeNum(2), encodeNum(104)}, true)
+func TestOpElseEndIf(t *testing.T) {
+	ctx := &ExecutionContext{conditions: []bool{true, false}}
 
-	// Test case 5 if/else statement
-	stack5 := &Stack{encodeNum(1)}
-	items5 := &Stack{encodeNum(1), encodeNum(103), encodeNum(2), encodeNum(104)}
+	if ok, err := opElse(ctx); !ok || err != nil || ctx.conditions[1] != true {
+		t.Errorf("opElse should flip the innermost branch, got %v", ctx.conditions)
+	}
 
-	result5, err5 := opNotIf(stack5, items5)
-	assertOpNotIfResult(t, result5, err5, stack5, &Stack{}, items5, &Stack{encodeNum(2)}, true)
+	if ok, err := opEndIf(ctx); !ok || err != nil || len(ctx.conditions) != 1 {
+		t.Errorf("opEndIf should close the innermost branch, got %v", ctx.conditions)
+	}
 
+	ctx.conditions = nil
+	if _, err := opElse(ctx); err == nil {
+		t.Errorf("OP_ELSE without OP_IF should fail")
+	}
+	if _, err := opEndIf(ctx); err == nil {
+		t.Errorf("OP_ENDIF without OP_IF should fail")
+	}
 }
 
-func assertOpIfResult(t *testing.T, result bool, err error, stack, expectedStack, items, expectedItems *Stack, success bool) {
-	t.Helper()
-
-	if success {
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
+func TestConditionalScripts(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  Script
+		want    Stack
+		wantErr bool
+	}{
+		{
+			// OP_1 OP_IF OP_2 OP_ELSE OP_3 OP_ENDIF
+			name:   "if/else true",
+			script: Script{{0x51}, {0x63}, {0x52}, {0x67}, {0x53}, {0x68}},
+			want:   Stack{encodeNum(2)},
+		},
+		{
+			// OP_0 OP_IF OP_2 OP_ELSE OP_3 OP_ENDIF
+			name:   "if/else false",
+			script: Script{{0x00}, {0x63}, {0x52}, {0x67}, {0x53}, {0x68}},
+			want:   Stack{encodeNum(3)},
+		},
+		{
+			// OP_1 OP_IF OP_2 OP_ELSE OP_3 OP_ELSE OP_4 OP_ENDIF
+			name:   "multiple else",
+			script: Script{{0x51}, {0x63}, {0x52}, {0x67}, {0x53}, {0x67}, {0x54}, {0x68}},
+			want:   Stack{encodeNum(2), encodeNum(4)},
+		},
+		{
+			// OP_1 OP_0 OP_IF OP_1 OP_IF OP_2 OP_ENDIF OP_ELSE OP_3 OP_ENDIF
+			name:   "nested in skipped branch",
+			script: Script{{0x51}, {0x00}, {0x63}, {0x51}, {0x63}, {0x52}, {0x68}, {0x67}, {0x53}, {0x68}},
+			want:   Stack{encodeNum(1), encodeNum(3)},
+		},
+		{
+			// OP_0 OP_IF <data> OP_RETURN OP_ENDIF OP_1
+			name:   "skipped pushes and return",
+			script: Script{{0x00}, {0x63}, {0xde, 0xad}, {0x6a}, {0x68}, {0x51}},
+			want:   Stack{encodeNum(1)},
+		},
+		{
+			// OP_1 OP_IF OP_1
+			name:    "missing endif",
+			script:  Script{{0x51}, {0x63}, {0x51}},
+			wantErr: true,
+		},
+		{
+			// OP_1 OP_ENDIF
+			name:    "endif without if",
+			script:  Script{{0x51}, {0x68}},
+			wantErr: true,
+		},
+		{
+			// OP_1 OP_ELSE OP_1
+			name:    "else without if",
+			script:  Script{{0x51}, {0x67}, {0x51}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		ctx := &ExecutionContext{}
+		_, err := tt.script.Execute(ctx)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
 		}
-
-		if !equalStacks(stack, expectedStack) || !equalStacks(items, expectedItems) || !result {
-			t.Errorf("opIf test failed")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
 		}
-	} else {
-		if err == nil {
-			t.Errorf("Expected error, but got nil")
+		if !equalStacks(&ctx.Stack, &tt.want) {
+			t.Errorf("%s: stack = %x, want %x", tt.name, ctx.Stack, tt.want)
 		}
 	}
 }
 
-func assertOpNotIfResult(t *testing.T, result bool, err error, stack, expectedStack, items, expectedItems *Stack, success bool) {
-	t.Helper()
-
-	if success {
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
+func TestMinimalIf(t *testing.T) {
+	tests := []struct {
+		argument []byte
+		minimal  bool
+	}{
+		{[]byte{}, true},
+		{[]byte{0x01}, true},
+		{[]byte{0x02}, false},
+		{[]byte{0x01, 0x00}, false},
+		{[]byte{0x00}, false},
+	}
+
+	for _, tt := range tests {
+		ctx := &ExecutionContext{Stack: Stack{tt.argument}, Flags: ScriptVerifyMinimalIf}
+		_, err := opIf(ctx)
+		if tt.minimal && err != nil {
+			t.Errorf("Argument %x should be accepted: %v", tt.argument, err)
 		}
-
-		if !equalStacks(stack, expectedStack) || !equalStacks(items, expectedItems) || !result {
-			t.Errorf("opNotIf test failed")
+		if !tt.minimal && err == nil {
+			t.Errorf("Argument %x should be rejected under MINIMALIF", tt.argument)
 		}
-	} else {
-		if err == nil {
-			t.Errorf("Expected error, but got nil")
+
+		// Without the flag any argument is accepted
+		ctx = &ExecutionContext{Stack: Stack{tt.argument}}
+		if _, err := opNotIf(ctx); err != nil {
+			t.Errorf("Argument %x should be accepted without MINIMALIF: %v", tt.argument, err)
 		}
 	}
 }
 
-func assertOpIfError(t *testing.T, err error, expectedError string) {
+func assertOpIfResult(t *testing.T, result bool, err error, ctx *ExecutionContext, expectedConditions []bool) {
 	t.Helper()
 
-	if err == nil || err.Error() != expectedError {
-		t.Errorf("Expected error: %v, got: %v", expectedError, err)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if !result || !reflect.DeepEqual(ctx.conditions, expectedConditions) {
+		t.Errorf("Conditional test failed. Got conditions %v, want %v", ctx.conditions, expectedConditions)
 	}
 }
 
-func assertOpNotIfError(t *testing.T, err error, expectedError string) {
+func assertOpIfError(t *testing.T, err error, expectedError string) {
 	t.Helper()
 
 	if err == nil || err.Error() != expectedError {
@@ -1111,6 +1176,84 @@ func TestOpWithin(t *testing.T) {
 	}
 }
 
+func TestNumericOperandLimits(t *testing.T) {
+	maxOperand := encodeNum(0x7fffffff)
+	tooLong := []byte{0x00, 0x00, 0x00, 0x80, 0x00}
+
+	// 4 byte operands may add up to a 5 byte result
+	ctx := &ExecutionContext{Stack: Stack{maxOperand, maxOperand}}
+	if ok, err := OpCodeFunctions[147](ctx); !ok || err != nil {
+		t.Fatalf("OP_ADD failed: %v", err)
+	}
+	if want := encodeNum(0xfffffffe); !bytes.Equal(ctx.Stack[0], want) {
+		t.Errorf("OP_ADD pushed %x, want %x", ctx.Stack[0], want)
+	}
+	// but the result is not accepted as an operand
+	ctx.Stack = append(ctx.Stack, encodeNum(1))
+	if _, err := OpCodeFunctions[147](ctx); err == nil {
+		t.Error("expected OP_ADD to reject a 5 byte operand")
+	}
+
+	for _, opCode := range []int{121, 139, 143, 156, 159, 163, 165} {
+		ctx := &ExecutionContext{Stack: Stack{encodeNum(1), encodeNum(1), tooLong}}
+		if ok, err := OpCodeFunctions[opCode](ctx); ok || err == nil {
+			t.Errorf("opcode %d accepted a 5 byte operand", opCode)
+		}
+	}
+
+	// locktimes reach up to 5 bytes, longer ones fail instead of overflowing
+	ctx = &ExecutionContext{Stack: Stack{tooLong}, Flags: ScriptVerifyCheckLockTimeVerify, Locktime: 0, Sequence: 0}
+	if _, err := checkLockTimeVerify(ctx); err == nil || strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("got error %v for a 5 byte locktime above the transaction locktime", err)
+	}
+	ctx = &ExecutionContext{Stack: Stack{make([]byte, 9)}, Flags: ScriptVerifyCheckSequenceVerify}
+	if _, err := checkSequenceVerify(ctx); err == nil {
+		t.Error("expected OP_CHECKSEQUENCEVERIFY to reject a 9 byte operand")
+	}
+
+	// negative counts and indexes are script failures
+	for _, opCode := range []int{121, 122, 174} {
+		ctx := &ExecutionContext{Stack: Stack{encodeNum(1), encodeNum(-1)}}
+		if ok, err := OpCodeFunctions[opCode](ctx); ok || err == nil {
+			t.Errorf("opcode %d accepted a negative count", opCode)
+		}
+	}
+}
+
+func TestMinimalData(t *testing.T) {
+	tests := []struct {
+		operand []byte
+		minimal bool
+	}{
+		{[]byte{}, true},
+		{[]byte{0x01}, true},
+		{[]byte{0x81}, true},
+		{[]byte{0xff, 0x00}, true},
+		{[]byte{0xff, 0x80}, true},
+		{[]byte{0x00}, false},
+		{[]byte{0x80}, false},
+		{[]byte{0x01, 0x00}, false},
+		{[]byte{0x01, 0x80}, false},
+	}
+
+	for _, tt := range tests {
+		ctx := &ExecutionContext{Stack: Stack{tt.operand}, Flags: ScriptVerifyMinimalData}
+		_, err := OpCodeFunctions[139](ctx)
+		if tt.minimal && err != nil {
+			t.Errorf("Operand %x should be accepted: %v", tt.operand, err)
+		}
+		if !tt.minimal && err == nil {
+			t.Errorf("Operand %x should be rejected under MINIMALDATA", tt.operand)
+		}
+
+		// Without the flag any encoding is accepted
+		ctx = &ExecutionContext{Stack: Stack{tt.operand}}
+		if _, err := OpCodeFunctions[139](ctx); err != nil {
+			t.Errorf("Operand %x should be accepted without MINIMALDATA: %v", tt.operand, err)
+		}
+	}
+}
+
 func TestOpRipemd160(t *testing.T) {
 	// Test case 1: Test when the stack is empty
 	emptyStack := Stack{}
@@ -1120,7 +1263,7 @@ func TestOpRipemd160(t *testing.T) {
 	}
 
 	// Test case 2: Test when the stack has at least 1 element
-	hash := ripemd160.New()
+	hash := utils.NewRipemd160()
 	hash.Write([]byte("hello"))
 	expected := hash.Sum(nil)
 	stackRipemd160 := Stack{[]byte("hello")}
@@ -1257,6 +1400,19 @@ func TestOpCheckMultisig(t *testing.T) {
 	}
 }
 
+func TestOpChecksigEmptySignature(t *testing.T) {
+	sec, _ := new(big.Int).SetString("0x022626e955ea6ea6d98850c994f9107b036b1334f18ca8830bfff1295d21cfdb70", 0)
+	stack := Stack{{}, sec.Bytes()}
+
+	result, err := opCheckSig(&stack, big.NewInt(1))
+	if !result || err != nil {
+		t.Errorf("opCheckSig with an empty signature should not fail the script, got %v, %v", result, err)
+	}
+	if len(stack) != 1 || decodeNum(stack[0]) != 0 {
+		t.Errorf("opCheckSig with an empty signature should push 0, got %v", stack)
+	}
+}
+
 func TestOpChecksigVerify(t *testing.T) {
 	z, _ := new(big.Int).SetString("0x7c076ff316692a3d7eb3c3bb0f8b1488cf72e1afcd929e29307032997a838a3d", 0)
 	// Test case 1: Test when the stack is empty
@@ -1319,7 +1475,15 @@ func TestOpCheckLockTimeVerify(t *testing.T) {
 		t.Errorf("opCheckLockTimeVerify failed for locktime is less than element in stack. Expected false, 'locktime is less than element in stack'; got true, %v", err)
 	}
 
-	// Test case 6: Valid case
+	// Test case 6: Locktime at the threshold is a time, not a height
+	stack = Stack{encodeNum(400000000)}
+	locktime = 500000000
+	result, err = opCheckLockTimeVerify(&stack, locktime, sequence)
+	if result || err == nil {
+		t.Errorf("opCheckLockTimeVerify accepted a time based locktime for a height, got %v, %v", result, err)
+	}
+
+	// Test case 7: Valid case
 	stack = Stack{encodeNum(122)}
 	locktime = 123
 	result, err = opCheckLockTimeVerify(&stack, locktime, sequence)
@@ -1329,72 +1493,39 @@ func TestOpCheckLockTimeVerify(t *testing.T) {
 }
 
 func TestOpCheckSequenceVerify(t *testing.T) {
-	// Test case 1: Invalid sequence value
-	stack := Stack{encodeNum(100)}
-	version := 1
-	sequence := 0xffffffff
-	result, err := opCheckSequenceVerify(&stack, version, sequence)
-	if result || err == nil || err.Error() != "invalid sequence value" {
-		t.Errorf("opCheckSequenceVerify failed for invalid sequence value. Expected false, 'invalid sequence value'; got true, %v", err)
-	}
-
-	// Test case 2: Insufficient elements in stack
-	emptyStack := Stack{}
-	sequence = 0x7FFFFFFF
-	result, err = opCheckSequenceVerify(&emptyStack, version, sequence)
-	if result || err == nil || err.Error() != "stack is empty" {
-		t.Errorf("opCheckSequenceVerify failed for insufficient elements in stack. Expected false, 'insufficient elements in stack'; got true, %v", err)
-	}
-
-	// Test case 3: Negative element in stack
-	negativeElementStack := Stack{encodeNum(-100)}
-	result, err = opCheckSequenceVerify(&negativeElementStack, version, sequence)
-	if result || err == nil || err.Error() != "negative element in stack" {
-		t.Errorf("opCheckSequenceVerify failed for negative element in stack. Expected false, 'negative element in stack'; got true, %v", err)
-	}
-
-	// Test case 4: Version is less than 2 for sequence with sign bit set
-	stack = Stack{encodeNum(0xC0000000)}
-	version = 1
-	sequence = 0x40000000
-	result, err = opCheckSequenceVerify(&stack, version, sequence)
-	if result || err == nil || err.Error() != "version is less than 2 for sequence with sign bit set" {
-		t.Errorf("opCheckSequenceVerify failed for version is less than 2 for sequence with sign bit set. Expected false, 'version is less than 2 for sequence with sign bit set'; got true, %v", err)
-	}
-
-	// Test case 5: Invalid sequence value
-	version = 2
-	sequence = 0xffffffff
-	result, err = opCheckSequenceVerify(&stack, version, sequence)
-	if result || err == nil || err.Error() != "invalid sequence value" {
-		t.Errorf("opCheckSequenceVerify failed for invalid sequence value. Expected false, 'invalid sequence value'; got true, %v", err)
-	}
-
-	// Test case 6: Mismatch in bits 22-31 between element and sequence
-	stack = Stack{encodeNum(0b11111111111111111110000000000000)}
-	version = 2
-	sequence = 0b00000000000000000001111111111111
-	result, err = opCheckSequenceVerify(&stack, version, sequence)
-	if result || err == nil || err.Error() != "mismatch in bits 22-31 between element and sequence" {
-		t.Errorf("opCheckSequenceVerify failed for mismatch in bits 22-31 between element and sequence. Expected false, 'mismatch in bits 22-31 between element and sequence'; got true, %v", err)
-	}
-
-	// Test case 7: Sequence value is less than element in stack
-	stack = Stack{encodeNum(0b11111111111111111111111111111111)}
-	version = 2
-	sequence = 0b01111111111111110000000000000000
-	result, err = opCheckSequenceVerify(&stack, version, sequence)
-	if result || err == nil || err.Error() != "sequence value is less than element in stack" {
-		t.Errorf("opCheckSequenceVerify failed for sequence value is less than element in stack. Expected false, 'sequence value is less than element in stack'; got true, %v", err)
-	}
-
-	// Test case 8: Valid case
-	stack = Stack{encodeNum(0)}
-	version = 2
-	sequence = 0x0020ffff
-	result, err = opCheckSequenceVerify(&stack, version, sequence)
-	if !result || err != nil {
-		t.Errorf("opCheckSequenceVerify failed for valid case. Expected true, nil; got %v, %v", result, err)
+	testCases := []struct {
+		name              string
+		stack             Stack
+		version, sequence int
+		err               string
+	}{
+		{"empty stack", Stack{}, 2, 10, "stack is empty"},
+		{"negative element", Stack{encodeNum(-100)}, 2, 10, "negative element in stack"},
+		{"version 1", Stack{encodeNum(10)}, 1, 10, "version is less than 2"},
+		{"sequence disabled", Stack{encodeNum(10)}, 2, 0xffffffff, "invalid sequence value"},
+		{"blocks against time", Stack{encodeNum(10)}, 2, 1<<22 | 10, "mismatch in type flag between element and sequence"},
+		{"time against blocks", Stack{encodeNum(1<<22 | 10)}, 2, 10, "mismatch in type flag between element and sequence"},
+		{"too few blocks", Stack{encodeNum(10)}, 2, 9, "sequence value is less than element in stack"},
+		{"too little time", Stack{encodeNum(1<<22 | 10)}, 2, 1<<22 | 9, "sequence value is less than element in stack"},
+		{"enough blocks", Stack{encodeNum(10)}, 2, 10, ""},
+		{"enough time", Stack{encodeNum(1<<22 | 10)}, 2, 1<<22 | 11, ""},
+		// the bits outside of the type flag and the value are ignored
+		{"other bits", Stack{encodeNum(1<<30 | 10)}, 2, 1<<25 | 0xffff, ""},
+		{"zero", Stack{encodeNum(0)}, 2, 0x0020ffff, ""},
+		// an element with the disable flag set is OP_NOP3, whatever the transaction
+		{"element disabled", Stack{encodeNum(1<<31 | 10)}, 1, 0xffffffff, ""},
+	}
+	for _, tc := range testCases {
+		result, err := opCheckSequenceVerify(&tc.stack, tc.version, tc.sequence)
+		if tc.err == "" {
+			if !result || err != nil {
+				t.Errorf("%s: opCheckSequenceVerify = %v, %v, want true", tc.name, result, err)
+			}
+			continue
+		}
+		if result || err == nil || err.Error() != tc.err {
+			t.Errorf("%s: opCheckSequenceVerify = %v, %v, want false, %q", tc.name, result, err, tc.err)
+		}
 	}
 }
 
@@ -0,0 +1,95 @@
+package script
+
+import (
+	"fmt"
+)
+
+// consensusFlags are the flags of real soft forks. Under any of them a registry may only redefine the upgradable
+// OP_NOPs: consensus rejects a script with an opcode that does not exist, such as OP_CAT, so defining one would
+// make scripts verify that consensus fails.
+const consensusFlags = ScriptVerifyP2SH | ScriptVerifyDERSig | ScriptVerifyCheckLockTimeVerify | ScriptVerifyCheckSequenceVerify |
+	ScriptVerifyWitness | ScriptVerifyTaproot
+
+// OpcodeRegistry holds opcodes defined outside of OpCodeFunctions, for private chains, teaching or trying out
+// soft fork proposals. Set it as ExecutionContext.Opcodes, its opcodes are looked up before the built-in ones.
+type OpcodeRegistry struct {
+	operations map[int]Operation
+	names      map[int]string
+}
+
+func NewOpcodeRegistry() *OpcodeRegistry {
+	return &OpcodeRegistry{operations: make(map[int]Operation), names: make(map[int]string)}
+}
+
+// Register defines opCode, which must not be one of OpCodeFunctions. Use Override to replace a built-in opcode.
+// Scripts using the registry then fail to execute under consensus flags.
+func (r *OpcodeRegistry) Register(opCode byte, name string, op Operation) error {
+	if _, ok := OpCodeFunctions[int(opCode)]; ok {
+		return fmt.Errorf("opcode %s is built in, use Override to replace it", opCodeNames[int(opCode)])
+	}
+	return r.set(opCode, name, op)
+}
+
+// Override replaces the built-in opCode with op. Scripts using the registry then fail to execute under consensus
+// flags, unless opCode is an upgradable OP_NOP.
+func (r *OpcodeRegistry) Override(opCode byte, name string, op Operation) error {
+	if _, ok := OpCodeFunctions[int(opCode)]; !ok {
+		return fmt.Errorf("opcode %d is not built in, use Register to define it", opCode)
+	}
+	if isConditional(int(opCode)) {
+		return fmt.Errorf("conditional opcode %s cannot be overridden", opCodeNames[int(opCode)])
+	}
+	return r.set(opCode, name, op)
+}
+
+func (r *OpcodeRegistry) set(opCode byte, name string, op Operation) error {
+	if op == nil {
+		return fmt.Errorf("operation of %s is nil", name)
+	}
+	if isPushOpCode(opCode) {
+		return fmt.Errorf("opcode %d pushes data and cannot be registered", opCode)
+	}
+	if _, ok := r.operations[int(opCode)]; ok {
+		return fmt.Errorf("opcode %d is already registered as %s", opCode, r.names[int(opCode)])
+	}
+	r.operations[int(opCode)] = op
+	r.names[int(opCode)] = name
+	return nil
+}
+
+// Name returns the name opCode was registered with
+func (r *OpcodeRegistry) Name(opCode byte) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	name, ok := r.names[int(opCode)]
+	return name, ok
+}
+
+// lookup returns the registered operation of opCode, it is safe to call on a nil registry
+func (r *OpcodeRegistry) lookup(opCode int) (Operation, bool) {
+	if r == nil {
+		return nil, false
+	}
+	op, ok := r.operations[opCode]
+	return op, ok
+}
+
+// checkFlags fails when flags enable consensus rules and the registry defines an opcode other than the
+// upgradable OP_NOPs
+func (r *OpcodeRegistry) checkFlags(flags VerificationFlags) error {
+	if r == nil || flags&consensusFlags == 0 {
+		return nil
+	}
+	for opCode, name := range r.names {
+		if !isUpgradableNop(opCode) {
+			return fmt.Errorf("opcode %s is registered, which is not allowed under consensus flags", name)
+		}
+	}
+	return nil
+}
+
+// isUpgradableNop returns whether opCode is OP_NOP1 or one of OP_NOP4 to OP_NOP10, which soft forks may redefine
+func isUpgradableNop(opCode int) bool {
+	return opCode == 176 || (opCode >= 179 && opCode <= 185)
+}
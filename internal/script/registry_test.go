@@ -0,0 +1,125 @@
+package script
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// opDouble doubles the number on top of the stack
+func opDouble(ctx *ExecutionContext) (bool, error) {
+	element, err := ctx.Stack.pop(-1)
+	if err != nil {
+		return false, err
+	}
+	ctx.Stack.push(encodeNum(2 * decodeNum(element)))
+	return true, nil
+}
+
+func TestOpcodeRegistryRegister(t *testing.T) {
+	registry := NewOpcodeRegistry()
+	if err := registry.Register(0xc0, "OP_DOUBLE", opDouble); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	// OP_3 OP_DOUBLE OP_6 OP_EQUAL
+	script := Script{{0x53}, {0xc0}, {0x56}, {0x87}}
+
+	if ok, err := script.Execute(&ExecutionContext{Flags: StandardVerifyFlags}); ok || err == nil {
+		t.Errorf("Opcode 0xc0 should be unknown without the registry")
+	}
+	if ok, err := script.Execute(&ExecutionContext{Opcodes: registry}); !ok || err != nil {
+		t.Errorf("Registered opcode should run without consensus flags: %v", err)
+	}
+	// consensus fails a script with an opcode that does not exist, defining one must not make it verify
+	for _, flags := range []VerificationFlags{ScriptVerifyP2SH, ScriptVerifyTaproot, StandardVerifyFlags} {
+		if ok, err := script.Execute(&ExecutionContext{Flags: flags, Opcodes: registry}); ok || err == nil {
+			t.Errorf("Registered opcode should be refused under flags %b", flags)
+		}
+	}
+	cat := NewOpcodeRegistry()
+	if err := cat.Register(0x7e, "OP_CAT", opDouble); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if ok, err := (&Script{{0x51}, {0x7e}}).Execute(&ExecutionContext{Flags: StandardVerifyFlags, Opcodes: cat}); ok || err == nil {
+		t.Errorf("Registered OP_CAT should be refused under consensus flags")
+	}
+
+	_, err := (&Script{{0xc0}}).Execute(&ExecutionContext{Opcodes: registry})
+	if err == nil || !strings.Contains(err.Error(), "OP_DOUBLE") {
+		t.Errorf("Failing registered opcode should be reported by name, got %v", err)
+	}
+}
+
+func TestOpcodeRegistryRejects(t *testing.T) {
+	registry := NewOpcodeRegistry()
+	if err := registry.Register(0x87, "OP_MYEQUAL", opDouble); err == nil {
+		t.Errorf("Register should refuse a built-in opcode")
+	}
+	if err := registry.Override(0xc0, "OP_DOUBLE", opDouble); err == nil {
+		t.Errorf("Override should refuse an opcode that is not built in")
+	}
+	if err := registry.Override(0x63, "OP_MYIF", opDouble); err == nil {
+		t.Errorf("Override should refuse a conditional opcode")
+	}
+	if err := registry.Register(0x4c, "OP_MYPUSH", opDouble); err == nil {
+		t.Errorf("Register should refuse a push opcode")
+	}
+	if err := registry.Register(0xc0, "OP_NIL", nil); err == nil {
+		t.Errorf("Register should refuse a nil operation")
+	}
+	if err := registry.Register(0xc0, "OP_DOUBLE", opDouble); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if err := registry.Register(0xc0, "OP_DOUBLE", opDouble); err == nil {
+		t.Errorf("Register should refuse an opcode twice")
+	}
+}
+
+func TestOpcodeRegistryOverride(t *testing.T) {
+	registry := NewOpcodeRegistry()
+	// OP_ADD multiplies instead
+	err := registry.Override(0x93, "OP_MUL", func(ctx *ExecutionContext) (bool, error) {
+		if len(ctx.Stack) < 2 {
+			return false, fmt.Errorf("stack has fewer than 2 elements")
+		}
+		b, _ := ctx.Stack.pop(-1)
+		a, _ := ctx.Stack.pop(-1)
+		ctx.Stack.push(encodeNum(decodeNum(a) * decodeNum(b)))
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("Override error: %v", err)
+	}
+	// OP_2 OP_3 OP_ADD OP_6 OP_EQUAL
+	script := Script{{0x52}, {0x53}, {0x93}, {0x56}, {0x87}}
+
+	if ok, err := script.Execute(&ExecutionContext{Opcodes: registry}); !ok || err != nil {
+		t.Errorf("Overridden opcode should run without consensus flags: %v", err)
+	}
+	for _, flags := range []VerificationFlags{ScriptVerifyP2SH, ScriptVerifyWitness, StandardVerifyFlags} {
+		if ok, err := script.Execute(&ExecutionContext{Flags: flags, Opcodes: registry}); ok || err == nil {
+			t.Errorf("Overridden opcode should be refused under flags %b", flags)
+		}
+	}
+}
+
+func TestOpcodeRegistryUpgradableNop(t *testing.T) {
+	registry := NewOpcodeRegistry()
+	// OP_NOP4 fails unless the top of the stack is true, like a soft fork redefining it would
+	err := registry.Override(0xb3, "OP_CHECKTRUE", func(ctx *ExecutionContext) (bool, error) {
+		if len(ctx.Stack) < 1 || decodeNum(ctx.Stack[len(ctx.Stack)-1]) == 0 {
+			return false, fmt.Errorf("top of the stack is not true")
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("Override error: %v", err)
+	}
+
+	if ok, err := (&Script{{0x51}, {0xb3}}).Execute(&ExecutionContext{Flags: StandardVerifyFlags, Opcodes: registry}); !ok || err != nil {
+		t.Errorf("Upgradable OP_NOP should be overridable under consensus flags: %v", err)
+	}
+	if ok, _ := (&Script{{0x51}, {0x00}, {0xb3}}).Execute(&ExecutionContext{Flags: StandardVerifyFlags, Opcodes: registry}); ok {
+		t.Errorf("Redefined OP_NOP4 should fail on false")
+	}
+}
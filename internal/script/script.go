@@ -7,32 +7,130 @@ import (
 	"fmt"
 	"io"
 	"math/big"
-	"reflect"
 
 	"github.com/caspereijkens/cryptocurrency/internal/utils"
 )
 
 type Script [][]byte
 
+// MaxScriptLength bounds the length prefix ParseScript accepts, no script can be longer than a block
+const MaxScriptLength = 4000000
+
 // ParseScript creates a new Script from a byte slice.
 // OP_PUSHDATA1/2 can be used to group data in a []byte.
 func ParseScript(reader *bufio.Reader) (*Script, error) {
+	buf, err := readScriptBytes(reader)
+	if err != nil {
+		return nil, err
+	}
+	script, err := parseCommands(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedScript, err)
+	}
+	return script, nil
+}
+
+// readScriptBytes reads the length prefix of a script and the bytes it covers
+func readScriptBytes(reader *bufio.Reader) ([]byte, error) {
 	length, err := utils.ReadVarint(reader)
 
 	if err != nil {
-		return nil, fmt.Errorf("no uvarint could be read from reader: %v", err)
+		return nil, fmt.Errorf("%w: no uvarint could be read from reader: %v", ErrMalformedScript, err)
+	}
+	if length > MaxScriptLength {
+		return nil, fmt.Errorf("%w: script length %d exceeds the limit of %d", ErrMalformedScript, length, MaxScriptLength)
 	}
 
 	buf := make([]byte, length)
 	_, err = io.ReadFull(reader, buf)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read script data: %v", err)
+		return nil, fmt.Errorf("%w: failed to read script data: %v", ErrMalformedScript, err)
+	}
+	return buf, nil
+}
+
+// opaqueMarker is the first command of the scripts ParseScriptLenient could not parse, followed by their raw bytes.
+// It is recognized by identity, so no parsed or built script can be mistaken for an opaque one.
+var opaqueMarker = []byte{0xff}
+
+// ParseScriptLenient is ParseScript for historical scripts that do not parse, such as a push that runs past the end.
+// Their raw bytes are kept in an opaque Script: it serializes to the same bytes, prints as hex and fails when it
+// is executed. Only a length prefix or data that cannot be read is an error.
+func ParseScriptLenient(reader *bufio.Reader) (*Script, error) {
+	buf, err := readScriptBytes(reader)
+	if err != nil {
+		return nil, err
+	}
+	if script, err := parseCommands(buf); err == nil {
+		return script, nil
+	}
+	return &Script{opaqueMarker, buf}, nil
+}
+
+// IsOpaque returns whether the script is the raw bytes of a script ParseScriptLenient could not parse
+func (s *Script) IsOpaque() bool {
+	return len(*s) == 2 && isOpaqueMarker((*s)[0])
+}
+
+func isOpaqueMarker(cmd []byte) bool {
+	return len(cmd) == 1 && &cmd[0] == &opaqueMarker[0]
+}
+
+// pushTag marks the commands that remember the push op code they were pushed with: single byte pushes and pushes
+// that were not minimally encoded. A one byte command is an op code unless it is one of the push op codes, so a
+// push of a byte such as 0x00 or 0xac has its push op code and the tag in the capacity beyond its byte. A literal
+// []byte{0xac} stays OP_CHECKSIG. Every push has memory of its own, so a caller writing to one changes no other
+// script, and a copy that drops the capacity, such as append([]byte{}, cmd...), loses it: use Script.Copy.
+var pushTag = [...]byte{0x9c, 0x3e, 0x51, 0xd7, 0x08, 0xa6, 0xf4, 0x2b}
+
+// taggedPush returns the command pushing data with the push op code op, which RawSerialize encodes it with
+func taggedPush(data []byte, op byte) []byte {
+	cmd := make([]byte, len(data), len(data)+1+len(pushTag))
+	copy(cmd, data)
+	tail := cmd[len(data):cap(cmd)]
+	tail[0] = op
+	copy(tail[1:], pushTag[:])
+	return cmd
+}
+
+// pushOp returns the push op code a command made by taggedPush was pushed with
+func pushOp(cmd []byte) (byte, bool) {
+	if cap(cmd) != len(cmd)+1+len(pushTag) {
+		return 0, false
 	}
+	tail := cmd[len(cmd):cap(cmd)]
+	if !bytes.Equal(tail[1:], pushTag[:]) {
+		return 0, false
+	}
+	return tail[0], true
+}
+
+// pushedByte returns the command that pushes the single byte b
+func pushedByte(b byte) []byte {
+	return taggedPush([]byte{b}, 0x01)
+}
+
+func isPushedByte(cmd []byte) bool {
+	_, tagged := pushOp(cmd)
+	return len(cmd) == 1 && tagged
+}
+
+// isOpCode reports whether cmd is an op code rather than data it pushes
+func isOpCode(cmd []byte) bool {
+	return len(cmd) == 1 && !isPushOpCode(cmd[0]) && !isPushedByte(cmd)
+}
 
+// IsOp reports whether cmd is the op code op, and not a push of the byte op
+func IsOp(cmd []byte, op byte) bool {
+	return isOpCode(cmd) && cmd[0] == op
+}
+
+// parseCommands splits the bytes of a script into its commands
+func parseCommands(buf []byte) (*Script, error) {
 	script := make(Script, 0)
 	count := 0
 
-	for count < int(length) {
+	for count < len(buf) {
 		currentByte := buf[count]
 		count++
 
@@ -40,19 +138,34 @@ func ParseScript(reader *bufio.Reader) (*Script, error) {
 		case currentByte >= 1 && currentByte <= 75:
 			// For a number between 1 and 75 inclusive, the next n bytes are an element.
 			n := int(currentByte)
-			script = append(script, buf[count:count+n])
+			if count+n > len(buf) {
+				return nil, fmt.Errorf("push of %d bytes runs past the end of the script", n)
+			}
+			script = append(script, dataCmd(buf[count:count+n], currentByte))
 			count += n
 		case currentByte == 76:
 			// 76 is OP_PUSHDATA1, so the next byte tells us how many bytes to read.
+			if count+1 > len(buf) {
+				return nil, fmt.Errorf("OP_PUSHDATA1 without a length")
+			}
 			bufLength := int(buf[count])
 			count++
-			script = append(script, buf[count:count+bufLength])
+			if count+bufLength > len(buf) {
+				return nil, fmt.Errorf("push of %d bytes runs past the end of the script", bufLength)
+			}
+			script = append(script, dataCmd(buf[count:count+bufLength], currentByte))
 			count += bufLength
 		case currentByte == 77:
 			// 77 is OP_PUSHDATA2, so the next two bytes tell us how many bytes to read.
+			if count+2 > len(buf) {
+				return nil, fmt.Errorf("OP_PUSHDATA2 without a length")
+			}
 			bufLength := binary.LittleEndian.Uint16(buf[count : count+2])
 			count += 2
-			script = append(script, buf[count:count+int(bufLength)])
+			if count+int(bufLength) > len(buf) {
+				return nil, fmt.Errorf("push of %d bytes runs past the end of the script", bufLength)
+			}
+			script = append(script, dataCmd(buf[count:count+int(bufLength)], currentByte))
 			count += int(bufLength)
 		default:
 			script = append(script, []byte{currentByte})
@@ -66,10 +179,47 @@ func ParseScript(reader *bufio.Reader) (*Script, error) {
 	return &script, nil
 }
 
+// dataCmd returns the command pushing data with the push op code op. It is tagged when a single byte would
+// otherwise make it an op code, or when op is not the one RawSerialize pushes data with.
+func dataCmd(data []byte, op byte) []byte {
+	if op != minimalPushOp(len(data)) {
+		return taggedPush(data, op)
+	}
+	if len(data) == 1 {
+		return pushedByte(data[0])
+	}
+	// the rest of the script is no capacity of the command, it could hold a tag
+	return data[:len(data):len(data)]
+}
+
+// minimalPushOp returns the push op code RawSerialize pushes n bytes with
+func minimalPushOp(n int) byte {
+	switch {
+	case n <= 75:
+		return byte(n)
+	case n < 0x100:
+		return 0x4c
+	default:
+		return 0x4d
+	}
+}
+
+// ParseRawScript parses script bytes that have no length prefix, such as a redeem or witness script.
+func ParseRawScript(raw []byte) (*Script, error) {
+	length, err := utils.EncodeVarint(uint64(len(raw)))
+	if err != nil {
+		return nil, err
+	}
+	return ParseScript(bufio.NewReader(bytes.NewReader(append(length, raw...))))
+}
+
 func (s *Script) String() string {
+	if s.IsOpaque() {
+		return fmt.Sprintf(" [unparseable %x]", (*s)[1])
+	}
 	var result []string
 	for _, cmd := range *s {
-		if len(cmd) == 1 {
+		if isOpCode(cmd) {
 			opCode := int(cmd[0])
 			name, ok := opCodeNames[opCode]
 			if !ok {
@@ -89,20 +239,112 @@ func (s *Script) Add(otherScript *Script) *Script {
 	return &result
 }
 
-func (s *Script) rawSerialize() ([]byte, error) {
+// Copy returns a deep copy of the script
+func (s *Script) Copy() *Script {
+	if s == nil {
+		return nil
+	}
+	copied := make(Script, len(*s))
+	for i, cmd := range *s {
+		if isOpaqueMarker(cmd) {
+			copied[i] = cmd
+			continue
+		}
+		if op, tagged := pushOp(cmd); tagged {
+			copied[i] = taggedPush(cmd, op)
+			continue
+		}
+		copied[i] = append([]byte{}, cmd...)
+	}
+	return &copied
+}
+
+// Equal reports whether s and other have the same commands. An empty push and OP_0 are the same command, a push of
+// a single byte and the op code of that byte are not.
+func (s *Script) Equal(other *Script) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	if len(*s) != len(*other) {
+		return false
+	}
+	for i, cmd := range *s {
+		otherCmd := (*other)[i]
+		if isOp0(cmd) && isOp0(otherCmd) {
+			continue
+		}
+		if !bytes.Equal(cmd, otherCmd) || isOpCode(cmd) != isOpCode(otherCmd) {
+			return false
+		}
+	}
+	return true
+}
+
+// Canonicalize returns a copy of the script in which every push has its minimal encoding (BIP62).
+// Pushes of more than one byte are always serialized minimally. Single byte pushes of 1 through 16
+// become OP_1 through OP_16, a push of 0x81 becomes OP_1NEGATE and empty pushes become OP_0.
+func (s *Script) Canonicalize() *Script {
+	canonical := make(Script, len(*s))
+	for i, cmd := range *s {
+		switch {
+		case len(cmd) == 0:
+			canonical[i] = []byte{0x00}
+		case isOpCode(cmd):
+			canonical[i] = []byte{cmd[0]}
+		case len(cmd) == 1 && cmd[0] >= 1 && cmd[0] <= 16:
+			canonical[i] = []byte{0x50 + cmd[0]}
+		case len(cmd) == 1 && cmd[0] == 0x81:
+			canonical[i] = []byte{0x4f}
+		case isPushedByte(cmd):
+			canonical[i] = pushedByte(cmd[0])
+		default:
+			canonical[i] = append([]byte{}, cmd...)
+		}
+	}
+	return &canonical
+}
+
+func isOp0(cmd []byte) bool {
+	return len(cmd) == 0 || IsOp(cmd, 0x00)
+}
+
+// isPushOpCode reports whether op is a direct push, OP_PUSHDATA1 or OP_PUSHDATA2, which ParseScript reads with their data
+func isPushOpCode(op byte) bool {
+	return op >= 0x01 && op <= 0x4d
+}
+
+// RawSerialize serializes the Script without the length prefix.
+func (s *Script) RawSerialize() ([]byte, error) {
+	if s.IsOpaque() {
+		return bytes.Clone((*s)[1]), nil
+	}
 	var result []byte
 
 	for _, cmd := range *s {
 		length := len(cmd)
+		op, tagged := pushOp(cmd)
 		switch {
+		case tagged && op <= 75:
+			result = append(result, op)
+			result = append(result, cmd...)
+		case tagged && op == 76 && length < 0x100:
+			result = append(result, 76, byte(length))
+			result = append(result, cmd...)
+		case tagged && op == 77 && length <= 520:
+			result = append(result, 77)
+			result = binary.LittleEndian.AppendUint16(result, uint16(length))
+			result = append(result, cmd...)
+		case len(cmd) == 1 && !isOpCode(cmd):
+			// a single byte push, or a push op code, which does not stand on its own and is read as a data byte
+			result = append(result, 1, cmd[0])
 		case len(cmd) == 1:
 			// if the command is an integer, we know it's an op code
 			result = append(result, cmd...)
-		case length < 75:
+		case length <= 75:
 			// if the length is between 1 and 75, we encode the length as a single byte
 			result = append(result, byte(length))
 			result = append(result, cmd...)
-		case length > 75 && length < 0x100:
+		case length < 0x100:
 			// For any element with length 76 to 255, we put OP_PUSHDATA1 first, then encode the length as a single byte, followed by the element.
 			result = append(result, 76)
 			result = append(result, byte(length))
@@ -110,7 +352,7 @@ func (s *Script) rawSerialize() ([]byte, error) {
 		case length >= 0x100 && length <= 520:
 			// For any element with length 256 to 520, we put OP_PUSHDATA2 first, then encode the length as two bytes, followed by the element.
 			result = append(result, 77)
-			binary.LittleEndian.PutUint16(result[len(result):], uint16(length))
+			result = binary.LittleEndian.AppendUint16(result, uint16(length))
 			result = append(result, cmd...)
 		default:
 			return nil, fmt.Errorf("too long a cmd")
@@ -121,7 +363,7 @@ func (s *Script) rawSerialize() ([]byte, error) {
 
 // serialize serializes the Script and adds the total length prefix.
 func (s *Script) Serialize() ([]byte, error) {
-	rawResult, err := s.rawSerialize()
+	rawResult, err := s.RawSerialize()
 	if err != nil {
 		return nil, err
 	}
@@ -138,118 +380,175 @@ func (s *Script) Serialize() ([]byte, error) {
 	return result, nil
 }
 
+// Evaluate runs the script with the signature hash z and the standard rules. It has no transaction, so
+// OP_CHECKLOCKTIMEVERIFY and OP_CHECKSEQUENCEVERIFY see a version 0 transaction with a zero locktime and sequence,
+// use EvaluateSpend for scripts with timelocks.
 func (s *Script) Evaluate(z *big.Int) bool {
-	cmds := make(Script, len(*s))
-	copy(cmds, *s)
+	return s.EvaluateSpend(z, 0, 0, 0)
+}
 
-	var stack Stack
-	var altStack Stack
+// EvaluateSpend is Evaluate for a script spent by an input with sequence of a transaction with locktime and
+// version, the values OP_CHECKLOCKTIMEVERIFY and OP_CHECKSEQUENCEVERIFY check
+func (s *Script) EvaluateSpend(z *big.Int, locktime, sequence, version uint32) bool {
+	ok, err := s.Execute(&ExecutionContext{
+		Z:        z,
+		Locktime: int(locktime),
+		Sequence: int(sequence),
+		Version:  int(version),
+		Flags:    StandardVerifyFlags,
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+	return ok
+}
 
-	for len(cmds) > 0 {
-		cmd := cmds[0]
-		cmds = cmds[1:]
+// Execute runs the script against ctx, leaving the final stacks in ctx.
+// Any data already on ctx.Stack is used as the initial stack.
+func (s *Script) Execute(ctx *ExecutionContext) (bool, error) {
+	if err := ctx.Opcodes.checkFlags(ctx.Flags); err != nil {
+		return false, err
+	}
 
-		if len(cmd) == 1 {
-			opCode := int(cmd[0])
+	ctx.Cmds = make(Script, len(*s))
+	copy(ctx.Cmds, *s)
 
-			operation := OpCodeFunctions[opCode]
-			opName := opCodeNames[opCode]
-
-			switch opCode {
-			case 99, 100:
-				ok, err := callOperation(operation, &stack, cmds)
-				if !ok || err != nil {
-					fmt.Printf("bad op: '%s', error: %v\n", opName, err)
-					return false
-				}
-			case 107, 108:
-				ok, err := callOperation(operation, &stack, &altStack)
-				if !ok || err != nil {
-					fmt.Printf("bad op: '%s', error: %v\n", opName, err)
-					return false
-				}
-			case 172, 173, 174, 175:
-				ok, err := callOperation(operation, &stack, z)
-				if !ok || err != nil {
-					fmt.Printf("bad op: '%s', error: %v\n", opName, err)
-					return false
-				}
-			default:
-				ok, err := callOperation(operation, &stack)
-				if !ok || err != nil {
-					fmt.Printf("bad op: '%s', error: %v\n", opName, err)
-					return false
-				}
-			}
-		} else {
-			stack.push(cmd)
-
-			if cmds.IsP2SHScriptPubKey() {
-				h160 := cmds[1]
-				cmds = Script{}
-				ok, err := opHash160(&stack)
-				if !ok || err != nil {
-					return false
-				}
-				stack.push(h160)
-				ok, err = opEqual(&stack)
-				if !ok || err != nil {
-					return false
-				}
-				ok, err = opVerify(&stack)
-				if !ok || err != nil {
-					fmt.Println("bad p2sh h160")
-					return false
-				}
-				scriptLength, err := utils.EncodeVarint(uint64(len(cmd)))
-				if err != nil {
-					fmt.Printf("error parsing redeem script: %v\n", err)
-					return false
-				}
-				redeemScript := append(scriptLength, cmd...)
-				parsedScript, err := ParseScript(bufio.NewReader(bytes.NewReader(redeemScript)))
-				if err != nil {
-					fmt.Printf("error parsing redeem script: %v\n", err)
-					return false
-				}
-				cmds = append(*parsedScript, cmds...)
+	ctx.conditions = nil
+	ctx.steps = 0
+
+	for len(ctx.Cmds) > 0 {
+		cmd := ctx.Cmds[0]
+		ctx.Cmds = ctx.Cmds[1:]
+
+		if ctx.Tracer == nil {
+			if err := ctx.step(cmd); err != nil {
+				return false, err
 			}
+			continue
+		}
+
+		before := ctx.Stack.hexElements()
+		executed := ctx.executing() || (isOpCode(cmd) && isConditional(int(cmd[0])))
+		err := ctx.step(cmd)
+		ctx.trace(cmd, before, executed, err)
+		if err != nil {
+			return false, err
 		}
 	}
 
-	if len(stack) == 0 || string(stack[len(stack)-1]) == "" {
-		return false
+	if len(ctx.conditions) > 0 {
+		return false, fmt.Errorf("%w: %d OP_ENDIF missing", ErrUnbalancedConditional, len(ctx.conditions))
 	}
 
-	return true
+	// the top element is true unless it is a zero of any length or negative zero, as for OP_IF and OP_VERIFY
+	if len(ctx.Stack) == 0 || !castToBool(ctx.Stack[len(ctx.Stack)-1]) {
+		return false, nil
+	}
+
+	return true, nil
 }
 
-func callOperation(fn interface{}, args ...interface{}) (bool, error) {
-	v := reflect.ValueOf(fn)
-	if v.Kind() != reflect.Func {
-		return false, fmt.Errorf("not a function")
+// VerifyScript evaluates scriptSig and scriptPubkey the way consensus does: scriptSig runs on an empty stack and
+// scriptPubkey on the stack it leaves, so neither can reach into the other's conditionals. With ScriptVerifyP2SH and
+// a p2sh scriptPubkey the scriptSig has to be push only, and after the hash check the redeem script it pushed last
+// runs on the rest of the stack scriptSig left. With ScriptVerifyWitness a witness program, native or as redeem
+// script, is checked by checkWitnessProgram. ctx keeps the stacks of the last script that ran.
+func VerifyScript(scriptSig, scriptPubkey *Script, ctx *ExecutionContext) error {
+	p2sh := ctx.Flags.Has(ScriptVerifyP2SH) && scriptPubkey.IsP2SHScriptPubKey()
+	if p2sh && !scriptSig.IsPushOnly() {
+		return fmt.Errorf("ScriptSig spending a p2sh output is %w", ErrNotPushOnly)
+	}
+
+	ctx.Stack, ctx.AltStack = Stack{}, Stack{}
+	if _, err := scriptSig.Execute(ctx); err != nil {
+		return fmt.Errorf("ScriptSig: %w", err)
+	}
+	saved := ctx.Stack.copy()
+
+	ctx.AltStack = Stack{}
+	ok, err := scriptPubkey.Execute(ctx)
+	if err != nil {
+		return fmt.Errorf("ScriptPubKey: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("ScriptPubKey %w", ErrEvalFalse)
+	}
+	if version, program, ok := scriptPubkey.WitnessProgram(); ok && ctx.Flags.Has(ScriptVerifyWitness) {
+		if len(*scriptSig) != 0 {
+			return fmt.Errorf("ScriptSig spending a witness program is not empty: %w", ErrWitnessMalleated)
+		}
+		return checkWitnessProgram(version, program, false, ctx.Flags)
+	}
+	if !p2sh {
+		return nil
 	}
 
-	// Prepare the arguments
-	var input []reflect.Value
-	for _, arg := range args {
-		input = append(input, reflect.ValueOf(arg))
+	// a push only ScriptSig that satisfied the hash check left at least the redeem script
+	redeemScript, err := ParseRawScript(saved[len(saved)-1])
+	if err != nil {
+		return fmt.Errorf("redeem script: %w", err)
 	}
+	ctx.Stack, ctx.AltStack = saved[:len(saved)-1], Stack{}
+	ok, err = redeemScript.Execute(ctx)
+	if err != nil {
+		return fmt.Errorf("redeem script: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("redeem script %w", ErrEvalFalse)
+	}
+	if version, program, ok := redeemScript.WitnessProgram(); ok && ctx.Flags.Has(ScriptVerifyWitness) {
+		if len(*scriptSig) != 1 {
+			return fmt.Errorf("ScriptSig spending a p2sh witness program does more than push it: %w", ErrWitnessMalleated)
+		}
+		return checkWitnessProgram(version, program, true, ctx.Flags)
+	}
+	return nil
+}
 
-	// Call the function
-	result := v.Call(input)
+// step executes a single command, skipping it when it is in a branch that is not executed
+func (ctx *ExecutionContext) step(cmd []byte) error {
+	if isOpCode(cmd) {
+		opCode := int(cmd[0])
 
-	// Extract the return values
-	if len(result) != 2 {
-		// Assuming the first return value is bool and the second is error
-		return false, fmt.Errorf("function did not return expected values")
+		if !ctx.executing() && !isConditional(opCode) {
+			return nil
+		}
+		if isOpaqueMarker(cmd) {
+			return fmt.Errorf("%w: script does not parse", ErrMalformedScript)
+		}
+
+		operation, ok := ctx.Opcodes.lookup(opCode)
+		if !ok {
+			operation, ok = OpCodeFunctions[opCode]
+		}
+		if !ok && ctx.Flags.Has(ScriptVerifyExtendedOpcodes) {
+			operation, ok = ExtendedOpCodeFunctions[opCode]
+		}
+		if !ok {
+			return fmt.Errorf("%w: 'OP_[%d]', error: unknown opcode", ErrBadOp, opCode)
+		}
+
+		ok, err := operation(ctx)
+		if !ok || err != nil {
+			name, registered := ctx.Opcodes.Name(cmd[0])
+			if !registered {
+				name = opCodeNames[opCode]
+			}
+			return fmt.Errorf("%w: '%s', error: %v", ErrBadOp, name, err)
+		}
+		return nil
 	}
 
-	if result[1].Interface() != nil {
-		return result[0].Bool(), result[1].Interface().(error)
+	if !ctx.executing() {
+		return nil
 	}
 
-	return result[0].Bool(), nil
+	if _, tagged := pushOp(cmd); tagged {
+		// the stack holds plain data, appending to an element must not clobber the tag of the command
+		cmd = append([]byte{}, cmd...)
+	}
+	ctx.Stack.push(cmd)
+	return nil
 }
 
 func (s *Script) TranslateToOps() []string {
@@ -260,21 +559,63 @@ func (s *Script) TranslateToOps() []string {
 	return ops
 }
 
+// IsPushOnly returns whether every command pushes data or a number up to OP_16, as BIP16 requires of the
+// ScriptSig of p2sh spends. An opaque script is not push only.
+func (s *Script) IsPushOnly() bool {
+	if s.IsOpaque() {
+		return false
+	}
+	for _, cmd := range *s {
+		if isOpCode(cmd) && cmd[0] > 0x60 {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Script) IsP2PKHScriptPubKey() bool {
 	// Returns whether this follows the
 	// OP_DUP OP_HASH160 <20 byte hash> OP_EQUALVERIFY OP_CHECKSIG pattern.
-	return len(*s) == 5 && bytes.Equal((*s)[0], []byte{0x76}) &&
-		bytes.Equal((*s)[1], []byte{0xa9}) &&
+	return len(*s) == 5 && IsOp((*s)[0], 0x76) &&
+		IsOp((*s)[1], 0xa9) &&
 		len((*s)[2]) == 20 &&
-		(*s)[3][0] == 0x88 && bytes.Equal((*s)[4], []byte{0xac})
+		IsOp((*s)[3], 0x88) && IsOp((*s)[4], 0xac)
 }
 
 func (s *Script) IsP2SHScriptPubKey() bool {
 	// Returns whether this follows the
 	// OP_HASH160 <20 byte hash> OP_EQUAL pattern.
-	return len(*s) == 3 && bytes.Equal((*s)[0], []byte{0xa9}) &&
+	return len(*s) == 3 && IsOp((*s)[0], 0xa9) &&
 		len((*s)[1]) == 20 &&
-		(*s)[2][0] == 0x87
+		IsOp((*s)[2], 0x87)
+}
+
+func (s *Script) IsP2WPKHScriptPubKey() bool {
+	// Returns whether this follows the
+	// OP_0 <20 byte hash> pattern.
+	return len(*s) == 2 && IsOp((*s)[0], 0x00) &&
+		len((*s)[1]) == 20
+}
+
+func (s *Script) IsP2WSHScriptPubKey() bool {
+	// Returns whether this follows the
+	// OP_0 <32 byte hash> pattern.
+	return len(*s) == 2 && IsOp((*s)[0], 0x00) &&
+		len((*s)[1]) == 32
+}
+
+func (s *Script) IsP2TRScriptPubKey() bool {
+	// Returns whether this follows the
+	// OP_1 <32 byte x-only pubkey> pattern.
+	return len(*s) == 2 && IsOp((*s)[0], 0x51) &&
+		len((*s)[1]) == 32
+}
+
+// IsP2AScriptPubKey returns whether this is the pay to anchor output OP_1 <0x4e73>, which anyone can spend with
+// an empty witness. It is used for ephemeral anchors that a child spends to bump the fee of its parent.
+func (s *Script) IsP2AScriptPubKey() bool {
+	return len(*s) == 2 && IsOp((*s)[0], 0x51) &&
+		bytes.Equal((*s)[1], []byte{0x4e, 0x73})
 }
 
 // Takes a hash160 and returns the p2pkh ScriptPubKey
@@ -285,3 +626,90 @@ func CreateP2pkhScript(h160 []byte) *Script {
 func CreateP2SHScript(h160 []byte) *Script {
 	return &Script{[]byte{0xa9}, h160, []byte{0x87}}
 }
+
+// Takes a hash160 and returns the p2wpkh ScriptPubKey
+func CreateP2WPKHScript(h160 []byte) *Script {
+	return &Script{[]byte{0x00}, h160}
+}
+
+// Takes a sha256 of a witness script and returns the p2wsh ScriptPubKey
+func CreateP2WSHScript(s256 []byte) *Script {
+	return &Script{[]byte{0x00}, s256}
+}
+
+// Takes a 32 byte x-only output key and returns the p2tr ScriptPubKey
+func CreateP2TRScript(outputKey []byte) *Script {
+	return &Script{[]byte{0x51}, outputKey}
+}
+
+// CreateP2AScript returns the pay to anchor ScriptPubKey
+func CreateP2AScript() *Script {
+	return &Script{[]byte{0x51}, []byte{0x4e, 0x73}}
+}
+
+// CreateHTLCScript returns a hash timelock contract as used for atomic swaps: receiverPubkey can spend it
+// with the preimage of secretHash, senderPubkey can take it back once the locktime has passed.
+func CreateHTLCScript(secretHash, receiverPubkey, senderPubkey []byte, locktime uint32) (*Script, error) {
+	if len(secretHash) != 32 {
+		return nil, fmt.Errorf("secret hash must be a 32 byte sha256, got %d bytes", len(secretHash))
+	}
+
+	locktimeCmd, err := NumberCmd(int(locktime))
+	if err != nil {
+		return nil, err
+	}
+
+	// OP_IF
+	//     OP_SHA256 <secret hash> OP_EQUALVERIFY <receiver pubkey>
+	// OP_ELSE
+	//     <locktime> OP_CHECKLOCKTIMEVERIFY OP_DROP <sender pubkey>
+	// OP_ENDIF
+	// OP_CHECKSIG
+	return &Script{
+		[]byte{0x63},
+		[]byte{0xa8}, secretHash, []byte{0x88}, receiverPubkey,
+		[]byte{0x67},
+		locktimeCmd, []byte{0xb1}, []byte{0x75}, senderPubkey,
+		[]byte{0x68},
+		[]byte{0xac},
+	}, nil
+}
+
+// NumberCmd returns the command that pushes num: OP_0, OP_1NEGATE and OP_1 through OP_16 for small numbers,
+// the minimal encoding otherwise.
+func NumberCmd(num int) ([]byte, error) {
+	switch {
+	case num == 0:
+		return []byte{0x00}, nil
+	case num == -1:
+		return []byte{0x4f}, nil
+	case num >= 1 && num <= 16:
+		return []byte{byte(0x50 + num)}, nil
+	}
+
+	encoded := ScriptNum(num).Bytes()
+	if len(encoded) == 1 {
+		return pushedByte(encoded[0]), nil
+	}
+	return encoded, nil
+}
+
+// CmdNumber returns the number cmd pushes, the reverse of NumberCmd. Elements longer than maxLen bytes
+// and op codes other than the small number ones are not numbers.
+func CmdNumber(cmd []byte, maxLen int) (int, bool) {
+	if isOpCode(cmd) {
+		switch op := cmd[0]; {
+		case op == 0x00:
+			return 0, true
+		case op == 0x4f:
+			return -1, true
+		case op >= 0x51 && op <= 0x60:
+			return int(op) - 0x50, true
+		}
+		return 0, false
+	}
+	if len(cmd) > maxLen {
+		return 0, false
+	}
+	return decodeNum(cmd), true
+}
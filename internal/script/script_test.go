@@ -4,12 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"os"
-	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
 )
 
 func TestNewScript(t *testing.T) {
@@ -61,7 +67,7 @@ func TestNewScript(t *testing.T) {
 				return
 			}
 
-			if !reflect.DeepEqual(script, tt.expected) {
+			if !script.Equal(tt.expected) {
 				t.Errorf("NewScript() got = %v, want %v", script, tt.expected)
 			}
 		})
@@ -129,6 +135,40 @@ func TestSerialize(t *testing.T) {
 	}
 }
 
+func TestParseScriptLenient(t *testing.T) {
+	// OP_DUP, then a push of 75 bytes with only 2 left
+	raw := []byte{0x04, 0x76, 0x4b, 0xab, 0xcd}
+	if _, err := ParseScript(bufio.NewReader(bytes.NewReader(raw))); err == nil {
+		t.Fatalf("ParseScript should fail on a push past the end")
+	}
+	opaque, err := ParseScriptLenient(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("ParseScriptLenient error: %v", err)
+	}
+	if !opaque.IsOpaque() || !opaque.Copy().IsOpaque() {
+		t.Errorf("Script that does not parse should be opaque, also when copied")
+	}
+	if serialized, err := opaque.Serialize(); err != nil || !bytes.Equal(serialized, raw) {
+		t.Errorf("Serialize = %x, %v, want %x", serialized, err, raw)
+	}
+	if got := opaque.String(); got != " [unparseable 764babcd]" {
+		t.Errorf("String = %q", got)
+	}
+	if ok, err := opaque.Execute(&ExecutionContext{}); ok || err == nil {
+		t.Errorf("Opaque script should fail to execute")
+	}
+
+	// a parsed OP_INVALIDOPCODE followed by a push is not opaque
+	parsed, err := ParseScriptLenient(bufio.NewReader(bytes.NewReader([]byte{0x03, 0xff, 0x01, 0xab})))
+	if err != nil || parsed.IsOpaque() || len(*parsed) != 2 {
+		t.Errorf("Script that parses should not be opaque: %v, %v", parsed, err)
+	}
+
+	if _, err := ParseScriptLenient(bufio.NewReader(bytes.NewReader([]byte{0x05, 0x76}))); err == nil {
+		t.Errorf("ParseScriptLenient should fail when the script bytes cannot be read")
+	}
+}
+
 // Now a bunch of tests where I try the standard scripts from the book.
 
 func TestPayToPubKeyExample(t *testing.T) {
@@ -154,17 +194,17 @@ func TestPayToPubKeyExample(t *testing.T) {
 
 func TestSomeArbitraryPrograms(t *testing.T) {
 	// 4 + 5 = 9
-	pubkeyScript1 := Script{[]byte{0x55}, []byte{0x93}, []byte{0x59}, []byte{0x87}}
-	sigScript1 := Script{[]byte{0x54}}
-	combinedScript1 := sigScript1.Add(&pubkeyScript1)
+	pubkeyScript1 := NewBuilder().Ops("OP_5", "OP_ADD", "OP_9", "OP_EQUAL").MustScript()
+	sigScript1 := NewBuilder().PushInt(4).MustScript()
+	combinedScript1 := sigScript1.Add(pubkeyScript1)
 	if ok := combinedScript1.Evaluate(nil); !ok {
 		t.Errorf("Combined script does not match. Evalutation resulted in False")
 	}
 
 	// 2 + 2^2 = 6
-	pubkeyScript2 := Script{[]byte{0x76}, []byte{0x76}, []byte{0x95}, []byte{0x93}, []byte{0x56}, []byte{0x87}}
-	sigScript2 := Script{[]byte{0x52}}
-	combinedScript2 := sigScript2.Add(&pubkeyScript2)
+	pubkeyScript2 := NewBuilder().Ops("OP_DUP", "OP_DUP", "OP_MUL", "OP_ADD", "OP_6", "OP_EQUAL").MustScript()
+	sigScript2 := NewBuilder().PushInt(2).MustScript()
+	combinedScript2 := sigScript2.Add(pubkeyScript2)
 	if ok := combinedScript2.Evaluate(nil); !ok {
 		t.Errorf("Combined script does not match. Evalutation resulted in False")
 	}
@@ -229,3 +269,442 @@ func TestGenesisBlockScript(t *testing.T) {
 		t.Errorf("Incorrect script")
 	}
 }
+
+func TestEvaluateConditionals(t *testing.T) {
+	trueBranch := NewBuilder().Ops("OP_1", "OP_IF", "OP_2", "OP_ELSE", "OP_3", "OP_ENDIF", "OP_2", "OP_EQUAL").MustScript()
+	if ok := trueBranch.Evaluate(nil); !ok {
+		t.Errorf("OP_IF should have taken the true branch")
+	}
+
+	notIfBranch := NewBuilder().Ops("OP_0", "OP_NOTIF", "OP_2", "OP_ELSE", "OP_3", "OP_ENDIF", "OP_2", "OP_EQUAL").MustScript()
+	if ok := notIfBranch.Evaluate(nil); !ok {
+		t.Errorf("OP_NOTIF should have taken the true branch")
+	}
+}
+
+func TestExecuteUsesContext(t *testing.T) {
+	lockScript := NewBuilder().PushInt(500).Ops("OP_CHECKLOCKTIMEVERIFY", "OP_DROP", "OP_1").MustScript()
+
+	ctx := &ExecutionContext{Locktime: 600, Sequence: 0xfffffffe, Flags: StandardVerifyFlags}
+	if ok, err := lockScript.Execute(ctx); !ok || err != nil {
+		t.Errorf("Locktime past the required height should pass: %v", err)
+	}
+
+	ctx = &ExecutionContext{Locktime: 400, Sequence: 0xfffffffe, Flags: StandardVerifyFlags}
+	if ok, _ := lockScript.Execute(ctx); ok {
+		t.Errorf("Locktime before the required height should fail")
+	}
+
+	// OP_TOALTSTACK OP_FROMALTSTACK leaves the initial stack as it was
+	altScript := NewBuilder().Ops("OP_TOALTSTACK", "OP_FROMALTSTACK").MustScript()
+	ctx = &ExecutionContext{Stack: Stack{encodeNum(7)}}
+	if ok, err := altScript.Execute(ctx); !ok || err != nil {
+		t.Fatalf("Alt stack round trip failed: %v", err)
+	}
+	if len(ctx.AltStack) != 0 || decodeNum(ctx.Stack[0]) != 7 {
+		t.Errorf("Unexpected stacks after execution: %v %v", ctx.Stack, ctx.AltStack)
+	}
+}
+
+func TestEvaluateSpend(t *testing.T) {
+	lockScript := NewBuilder().PushInt(500).Ops("OP_CHECKLOCKTIMEVERIFY", "OP_DROP", "OP_1").MustScript()
+	if !lockScript.EvaluateSpend(nil, 600, 0xfffffffe, 1) {
+		t.Errorf("Locktime past the required height should pass")
+	}
+	if lockScript.Evaluate(nil) {
+		t.Errorf("Without a transaction the locktime should not be satisfied")
+	}
+
+	sequenceScript := NewBuilder().PushInt(144).Ops("OP_CHECKSEQUENCEVERIFY", "OP_DROP", "OP_1").MustScript()
+	if !sequenceScript.EvaluateSpend(nil, 0, 144, 2) {
+		t.Errorf("Sequence of the required blocks should pass")
+	}
+	if sequenceScript.EvaluateSpend(nil, 0, 144, 1) {
+		t.Errorf("Relative locktime of a version 1 transaction should fail")
+	}
+}
+
+func TestVerifyScriptFalseEncodings(t *testing.T) {
+	nop := &Script{{0x61}, {0x61}}
+	rawNop, _ := nop.RawSerialize()
+	p2sh := CreateP2SHScript(utils.Hash160(rawNop))
+	// zeros of any length and negative zero are all false
+	for _, zero := range [][]byte{{0x00, 0x00}, {0x00, 0x00, 0x00}, {0x00, 0x80}, {0x00, 0x00, 0x80}} {
+		err := VerifyScript(&Script{zero}, nop, &ExecutionContext{Flags: StandardVerifyFlags})
+		if !errors.Is(err, ErrEvalFalse) {
+			t.Errorf("ScriptPubKey leaving %x = %v, want ErrEvalFalse", zero, err)
+		}
+		err = VerifyScript(&Script{zero, rawNop}, p2sh, &ExecutionContext{Flags: StandardVerifyFlags})
+		if !errors.Is(err, ErrEvalFalse) {
+			t.Errorf("Redeem script leaving %x = %v, want ErrEvalFalse", zero, err)
+		}
+	}
+	if err := VerifyScript(&Script{{0x00, 0x01}}, nop, &ExecutionContext{Flags: StandardVerifyFlags}); err != nil {
+		t.Errorf("ScriptPubKey leaving 0001 should be true: %v", err)
+	}
+}
+
+func TestSoftForkFlags(t *testing.T) {
+	// before BIP65 OP_CHECKLOCKTIMEVERIFY is OP_NOP2 and does not look at the locktime
+	lockScript := NewBuilder().PushInt(500).Ops("OP_NOP2", "OP_DROP", "OP_1").MustScript()
+	if ok, err := lockScript.Execute(&ExecutionContext{Locktime: 400}); !ok || err != nil {
+		t.Errorf("OP_CHECKLOCKTIMEVERIFY should be a no-op without its flag: %v", err)
+	}
+
+	// OP_1 OP_0 OP_EQUAL as redeem script: the hash matches, but the script itself fails
+	redeemScript := []byte{0x51, 0x00, 0x87}
+	scriptSig := &Script{redeemScript}
+	p2sh := CreateP2SHScript(utils.Hash160(redeemScript))
+	if err := VerifyScript(scriptSig, p2sh, &ExecutionContext{}); err != nil {
+		t.Errorf("Without BIP16 only the script hash should be checked: %v", err)
+	}
+	if err := VerifyScript(scriptSig, p2sh, &ExecutionContext{Flags: ScriptVerifyP2SH}); err == nil {
+		t.Errorf("With BIP16 the redeem script should be evaluated")
+	}
+
+	// BIP66 rejects a zero padded r value, before that the signature was just parsed
+	paddedSig, _ := hex.DecodeString("300702020001020101" + "01")
+	checkSig := Script{paddedSig, make([]byte, 33), []byte{0xac}}
+	if _, err := checkSig.Execute(&ExecutionContext{Flags: ScriptVerifyDERSig}); err == nil || !bytes.Contains([]byte(err.Error()), []byte("strict DER")) {
+		t.Errorf("Expected a strict DER error, got %v", err)
+	}
+}
+
+func TestUndefinedHashType(t *testing.T) {
+	key, _ := signatureverification.NewPrivateKey(big.NewInt(12345))
+	z := big.NewInt(67890)
+	sig, err := key.Sign(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := key.Point.Serialize(true)
+	checkSig := Script{append(sig.Serialize(), 0x04), sec, []byte{0xac}}
+	checkMultiSig := Script{{0x00}, append(sig.Serialize(), 0x04), {0x51}, sec, {0x51}, []byte{0xae}}
+
+	// the hash of hash type 0x04 is z, the signature signs it
+	sigHash := func(hashType signatureverification.SigHashType) (*big.Int, error) {
+		if hashType != 0x04 {
+			return nil, fmt.Errorf("unexpected hash type %s", hashType)
+		}
+		return z, nil
+	}
+
+	for _, s := range []Script{checkSig, checkMultiSig} {
+		// consensus verifies a signature whatever its hash type
+		if ok, err := (&s).Copy().Execute(&ExecutionContext{SigHash: sigHash, Flags: StandardVerifyFlags}); !ok || err != nil {
+			t.Errorf("%s should verify without strict encoding: %v, %v", &s, ok, err)
+		}
+		if _, err := (&s).Copy().Execute(&ExecutionContext{SigHash: sigHash, Flags: PolicyVerifyFlags}); err == nil || !strings.Contains(err.Error(), "undefined hash type") {
+			t.Errorf("%s should fail strict encoding, got %v", &s, err)
+		}
+		// with only the SIGHASH_ALL hash the signature cannot be checked, which is an error and no false signature
+		if _, err := (&s).Copy().Execute(&ExecutionContext{Z: z, Flags: StandardVerifyFlags}); err == nil || !strings.Contains(err.Error(), "needs ExecutionContext.SigHash") {
+			t.Errorf("%s without SigHash should fail for its hash type, got %v", &s, err)
+		}
+	}
+}
+
+func TestVerifyScript(t *testing.T) {
+	// OP_2 OP_EQUAL as redeem script, satisfied by a 2 pushed before it
+	redeemScript := []byte{0x52, 0x87}
+	p2sh := CreateP2SHScript(utils.Hash160(redeemScript))
+	if err := VerifyScript(&Script{{0x52}, redeemScript}, p2sh, &ExecutionContext{Flags: ScriptVerifyP2SH}); err != nil {
+		t.Errorf("Redeem script should run on the stack the ScriptSig left: %v", err)
+	}
+	if err := VerifyScript(&Script{{0x53}, redeemScript}, p2sh, &ExecutionContext{Flags: ScriptVerifyP2SH}); !errors.Is(err, ErrEvalFalse) {
+		t.Errorf("Redeem script evaluating to false should fail")
+	}
+	if err := VerifyScript(&Script{{0x52}, {0x76}, {0x75}, redeemScript}, p2sh, &ExecutionContext{Flags: ScriptVerifyP2SH}); !errors.Is(err, ErrNotPushOnly) {
+		t.Errorf("ScriptSig spending a p2sh output has to be push only")
+	}
+	// the redeem script does not see anything the ScriptPubKey did to the stack
+	if err := VerifyScript(&Script{redeemScript}, p2sh, &ExecutionContext{Flags: ScriptVerifyP2SH}); err == nil {
+		t.Errorf("Redeem script should run without the result of the hash check")
+	}
+
+	// an OP_IF opened in the ScriptSig does not extend into the ScriptPubKey
+	if err := VerifyScript(&Script{{0x00}, {0x63}}, &Script{{0x68}, {0x51}}, &ExecutionContext{}); !errors.Is(err, ErrUnbalancedConditional) {
+		t.Errorf("Conditional spanning ScriptSig and ScriptPubKey should fail")
+	}
+	// a ScriptSig leaving false on the stack is fine as long as the ScriptPubKey ends true
+	if err := VerifyScript(&Script{{0x00}}, &Script{{0x75}, {0x51}}, &ExecutionContext{}); err != nil {
+		t.Errorf("Only the result of the ScriptPubKey should count: %v", err)
+	}
+	if err := VerifyScript(&Script{{0x51}}, &Script{{0x75}, {0x00}}, &ExecutionContext{}); !errors.Is(err, ErrEvalFalse) {
+		t.Errorf("ScriptPubKey evaluating to false should fail")
+	}
+	if err := VerifyScript(&Script{}, &Script{{0x75}}, &ExecutionContext{}); !errors.Is(err, ErrBadOp) {
+		t.Errorf("OP_DROP on an empty stack should be a bad op, got %v", err)
+	}
+	if _, err := ParseRawScript([]byte{0x4c}); !errors.Is(err, ErrMalformedScript) {
+		t.Errorf("OP_PUSHDATA1 without a length should be malformed, got %v", err)
+	}
+}
+
+func TestFlagsAtHeight(t *testing.T) {
+	tests := []struct {
+		chain  *params.Params
+		height uint32
+		want   VerificationFlags
+	}{
+		{params.MainNet, 173804, 0},
+		{params.MainNet, 173805, ScriptVerifyP2SH},
+		{params.MainNet, 363725, ScriptVerifyP2SH | ScriptVerifyDERSig},
+		{params.MainNet, 419328, StandardVerifyFlags},
+		{params.MainNet, 481824, StandardVerifyFlags | ScriptVerifyWitness},
+		{params.MainNet, 709632, StandardVerifyFlags | ScriptVerifyWitness | ScriptVerifyTaproot},
+		{params.TestNet3, 0, ScriptVerifyP2SH},
+		{params.SigNet, 0, ScriptVerifyP2SH | ScriptVerifyTaproot},
+		{params.SigNet, 1, StandardVerifyFlags | ScriptVerifyWitness | ScriptVerifyTaproot},
+	}
+
+	for _, tt := range tests {
+		if got := FlagsAtHeight(tt.chain, tt.height); got != tt.want {
+			t.Errorf("%s at %d: flags = %b, want %b", tt.chain.Name, tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteUnknownOpcode(t *testing.T) {
+	script := NewBuilder().Ops("OP_1", "OP_CAT").MustScript()
+	if ok, err := script.Execute(&ExecutionContext{}); ok || err == nil {
+		t.Errorf("Unknown opcode should fail with an error")
+	}
+}
+
+func TestEvaluateConcurrently(t *testing.T) {
+	// 4 + 5 = 9
+	script := NewBuilder().Ops("OP_4", "OP_5", "OP_ADD", "OP_9", "OP_EQUAL").MustScript()
+
+	var wg sync.WaitGroup
+	results := make([]bool, 32)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = script.Evaluate(nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, ok := range results {
+		if !ok {
+			t.Errorf("Evaluation %d resulted in False", i)
+		}
+	}
+}
+
+func TestRawSerializePushLengths(t *testing.T) {
+	tests := []struct {
+		length int
+		prefix []byte
+	}{
+		{75, []byte{75}},
+		{76, []byte{0x4c, 76}},
+		{255, []byte{0x4c, 255}},
+		{256, []byte{0x4d, 0x00, 0x01}},
+		{520, []byte{0x4d, 0x08, 0x02}},
+	}
+
+	for _, tt := range tests {
+		element := bytes.Repeat([]byte{0xab}, tt.length)
+		s := Script{element}
+		raw, err := s.RawSerialize()
+		if err != nil {
+			t.Fatalf("RawSerialize of a %d byte push failed: %v", tt.length, err)
+		}
+		if !bytes.Equal(raw, append(tt.prefix, element...)) {
+			t.Errorf("Push of %d bytes serialized with prefix %x, want %x", tt.length, raw[:len(tt.prefix)], tt.prefix)
+		}
+
+		parsed, err := ParseRawScript(raw)
+		if err != nil || len(*parsed) != 1 || !bytes.Equal((*parsed)[0], element) {
+			t.Errorf("Push of %d bytes does not round trip", tt.length)
+		}
+	}
+
+	tooLong := Script{make([]byte, 521)}
+	if _, err := tooLong.RawSerialize(); err == nil {
+		t.Errorf("Push of more than 520 bytes should fail")
+	}
+}
+
+func TestNumberCmd(t *testing.T) {
+	tests := []struct {
+		num  int
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{-1, []byte{0x4f}},
+		{1, []byte{0x51}},
+		{16, []byte{0x60}},
+		{17, []byte{0x11}},
+		{100, []byte{0x64}},
+		{-2, []byte{0x82}},
+		{144, []byte{0x90, 0x00}},
+		{800000, []byte{0x00, 0x35, 0x0c}},
+	}
+
+	for _, tt := range tests {
+		got, err := NumberCmd(tt.num)
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("NumberCmd(%d) = %x, %v, want %x", tt.num, got, err, tt.want)
+		}
+		if decoded, ok := CmdNumber(got, 5); !ok || decoded != tt.num {
+			t.Errorf("CmdNumber(NumberCmd(%d)) = %d, %v", tt.num, decoded, ok)
+		}
+	}
+}
+
+func TestCmdNumber(t *testing.T) {
+	for _, num := range []int{0, -1, 1, 16, 144, 800000, 1700000000} {
+		cmd, err := NumberCmd(num)
+		if err != nil {
+			t.Fatalf("NumberCmd(%d) error: %v", num, err)
+		}
+		if got, ok := CmdNumber(cmd, 5); !ok || got != num {
+			t.Errorf("CmdNumber(%x) = %d, %v, want %d", cmd, got, ok, num)
+		}
+	}
+	if got, ok := CmdNumber([]byte{}, 5); !ok || got != 0 {
+		t.Errorf("Empty push should be 0, got %d, %v", got, ok)
+	}
+	if _, ok := CmdNumber([]byte{0xb1}, 5); ok {
+		t.Errorf("OP_CHECKLOCKTIMEVERIFY is not a number")
+	}
+	if _, ok := CmdNumber([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, 5); ok {
+		t.Errorf("A 6 byte element is too long to be a number")
+	}
+}
+
+func TestIsPushOnly(t *testing.T) {
+	tests := []struct {
+		script Script
+		want   bool
+	}{
+		{Script{}, true},
+		// OP_0 <sig> OP_1NEGATE OP_16 and a single byte push of 0x4c
+		{Script{{0x00}, make([]byte, 71), {0x4f}, {0x60}, {0x4c}}, true},
+		{Script{make([]byte, 71), {0x61}}, false},
+		{Script{{0x76}, {0xa9}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.script.IsPushOnly(); got != tt.want {
+			t.Errorf("IsPushOnly(%s) = %v, want %v", &tt.script, got, tt.want)
+		}
+	}
+}
+
+func TestScriptEqual(t *testing.T) {
+	a := &Script{[]byte{0x76}, []byte{0xab, 0xcd}}
+	tests := []struct {
+		other *Script
+		want  bool
+	}{
+		{&Script{[]byte{0x76}, []byte{0xab, 0xcd}}, true},
+		{&Script{[]byte{0x76}, []byte{0xab, 0xce}}, false},
+		{&Script{[]byte{0x76}}, false},
+		{nil, false},
+	}
+	for i, tt := range tests {
+		if got := a.Equal(tt.other); got != tt.want {
+			t.Errorf("test %d: Equal = %v, want %v", i, got, tt.want)
+		}
+	}
+
+	if !(&Script{[]byte{}}).Equal(&Script{[]byte{0x00}}) {
+		t.Error("an empty push should equal OP_0")
+	}
+	var empty *Script
+	if !empty.Equal(nil) {
+		t.Error("two nil scripts should be equal")
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	// OP_PUSHDATA1 of 2 bytes, a single byte push of 5, a single byte push of 0x20, OP_PUSHDATA1 of 0 bytes,
+	// a single byte push of 0x81 and one of 0xac
+	raw := []byte{0x4c, 0x02, 0xab, 0xcd, 0x01, 0x05, 0x01, 0x20, 0x4c, 0x00, 0x01, 0x81, 0x01, 0xac}
+	s, err := ParseRawScript(raw)
+	if err != nil {
+		t.Fatalf("ParseRawScript failed: %v", err)
+	}
+
+	canonical := s.Canonicalize()
+	want := &Script{[]byte{0xab, 0xcd}, []byte{0x55}, []byte{0x20}, []byte{0x00}, []byte{0x4f}, pushedByte(0xac)}
+	if !canonical.Equal(want) {
+		t.Errorf("Canonicalize() = %v, want %v", canonical, want)
+	}
+	serialized, err := canonical.RawSerialize()
+	if err != nil {
+		t.Fatalf("RawSerialize failed: %v", err)
+	}
+	if wantRaw := []byte{0x02, 0xab, 0xcd, 0x55, 0x01, 0x20, 0x00, 0x4f, 0x01, 0xac}; !bytes.Equal(serialized, wantRaw) {
+		t.Errorf("canonical script serialized to %x, want %x", serialized, wantRaw)
+	}
+
+	// the copy does not share memory with the original
+	(*canonical)[0][0] = 0xff
+	if (*s)[0][0] != 0xab {
+		t.Error("Canonicalize should copy the pushed data")
+	}
+}
+
+func TestSingleBytePushRoundTrip(t *testing.T) {
+	for _, value := range []byte{0x00, 0x01, 0x10, 0x4b, 0x4d, 0x4e, 0x81, 0xac, 0xff} {
+		raw := []byte{0x01, value}
+		s, err := ParseRawScript(raw)
+		if err != nil {
+			t.Fatalf("ParseRawScript(%x) failed: %v", raw, err)
+		}
+		serialized, err := s.RawSerialize()
+		if err != nil || !bytes.Equal(serialized, raw) {
+			t.Errorf("single byte push %x serialized to %x, %v", raw, serialized, err)
+		}
+	}
+
+	// the data byte is pushed, not executed: <0x05> OP_SIZE OP_1 OP_EQUAL
+	s := Script{[]byte{0x05}, []byte{0x82}, []byte{0x51}, []byte{0x87}}
+	if !s.Evaluate(big.NewInt(0)) {
+		t.Error("a single data byte should be pushed onto the stack")
+	}
+
+	// a push of 0xac is data, not OP_CHECKSIG
+	pushed, err := ParseRawScript([]byte{0x01, 0xac})
+	if err != nil {
+		t.Fatalf("ParseRawScript failed: %v", err)
+	}
+	if pushed.Equal(&Script{{0xac}}) || pushed.Copy().Equal(&Script{{0xac}}) {
+		t.Error("a push of 0xac should not equal OP_CHECKSIG")
+	}
+	if serialized, _ := pushed.Copy().RawSerialize(); !bytes.Equal(serialized, []byte{0x01, 0xac}) {
+		t.Errorf("copy of the push of 0xac serialized to %x", serialized)
+	}
+	ctx := &ExecutionContext{Z: big.NewInt(0)}
+	if ok, err := pushed.Execute(ctx); !ok || err != nil || !bytes.Equal(ctx.Stack[0], []byte{0xac}) {
+		t.Errorf("executing the push of 0xac = %v, %v with stack %x", ok, err, ctx.Stack)
+	}
+	if pushed.SigOpCount(true) != 0 || !pushed.IsPushOnly() {
+		t.Error("a push of 0xac is no signature operation")
+	}
+
+	// pushes share no memory, writing to one leaves every other script as it was
+	(*pushed)[0][0] = 0x00
+	again, _ := ParseRawScript([]byte{0x01, 0xac})
+	if serialized, _ := again.RawSerialize(); !bytes.Equal(serialized, []byte{0x01, 0xac}) {
+		t.Errorf("push of 0xac parsed after another was changed serialized to %x", serialized)
+	}
+	stack, _, err := (&Engine{}).Run(again, nil)
+	if err != nil || len(stack) != 1 || isPushedByte(stack[0]) {
+		t.Fatalf("Run of the push of 0xac left %x, %v", stack, err)
+	}
+	stack[0][0] = 0x00
+	if serialized, _ := again.RawSerialize(); !bytes.Equal(serialized, []byte{0x01, 0xac}) {
+		t.Errorf("writing to the stack Run returned changed the script to %x", serialized)
+	}
+
+	// a non-minimal OP_PUSHDATA1 of one byte pushes data too
+	if s, err := ParseRawScript([]byte{0x4c, 0x01, 0xac}); err != nil || s.SigOpCount(true) != 0 || !s.IsPushOnly() {
+		t.Errorf("OP_PUSHDATA1 of 0xac parsed to %s, %v", s, err)
+	}
+}
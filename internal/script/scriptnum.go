@@ -0,0 +1,135 @@
+package script
+
+import "fmt"
+
+// Numeric opcodes only accept operands of up to 4 bytes, OP_CHECKLOCKTIMEVERIFY and OP_CHECKSEQUENCEVERIFY up to 5.
+// Results may be longer, they fail as soon as they are used as an operand again.
+const (
+	MaxScriptNumLen   = 4
+	MaxLockTimeNumLen = 5
+)
+
+// ScriptNum is a number as scripts encode it on the stack: little endian with the sign in the top bit of the last
+// byte, zero as the empty element. It is what CScriptNum is in Bitcoin Core.
+type ScriptNum int64
+
+// Bytes returns the minimal encoding of n
+func (n ScriptNum) Bytes() []byte {
+	if n == 0 {
+		return []byte{}
+	}
+
+	// the magnitude as a uint64, so the most negative number does not overflow
+	magnitude := uint64(n)
+	if n < 0 {
+		magnitude = -magnitude
+	}
+
+	var result []byte
+	for magnitude > 0 {
+		result = append(result, byte(magnitude&0xff))
+		magnitude >>= 8
+	}
+
+	// the top bit of the last byte is the sign, a byte is added when the magnitude needs that bit
+	if result[len(result)-1]&0x80 != 0 {
+		if n < 0 {
+			result = append(result, 0x80)
+		} else {
+			result = append(result, 0)
+		}
+	} else if n < 0 {
+		result[len(result)-1] |= 0x80
+	}
+
+	return result
+}
+
+// Int32 returns n clamped to an int32, the way Bitcoin Core reads the counts and indexes of op codes
+func (n ScriptNum) Int32() int32 {
+	switch {
+	case n > ScriptNum(1<<31-1):
+		return 1<<31 - 1
+	case n < ScriptNum(-1<<31):
+		return -1 << 31
+	}
+	return int32(n)
+}
+
+// DecodeScriptNum returns the number element encodes without checking its length or encoding. Elements of more
+// than 8 bytes overflow, use ParseScriptNum for elements that come from a script.
+func DecodeScriptNum(element []byte) ScriptNum {
+	if len(element) == 0 {
+		return 0
+	}
+
+	var magnitude uint64
+	for i := len(element) - 1; i >= 0; i-- {
+		b := element[i]
+		if i == len(element)-1 {
+			b &= 0x7f
+		}
+		magnitude = magnitude<<8 | uint64(b)
+	}
+
+	if element[len(element)-1]&0x80 != 0 {
+		return -ScriptNum(magnitude)
+	}
+	return ScriptNum(magnitude)
+}
+
+// ParseScriptNum decodes element as an operand of at most maxLen bytes, such as MaxScriptNumLen. With
+// requireMinimal it also has to be minimally encoded, as ScriptVerifyMinimalData requires.
+func ParseScriptNum(element []byte, maxLen int, requireMinimal bool) (ScriptNum, error) {
+	if len(element) > maxLen {
+		return 0, fmt.Errorf("numeric operand of %d bytes exceeds %d bytes", len(element), maxLen)
+	}
+	if requireMinimal && !IsMinimallyEncoded(element) {
+		return 0, fmt.Errorf("numeric operand %x is not minimally encoded", element)
+	}
+	return DecodeScriptNum(element), nil
+}
+
+// IsMinimallyEncoded returns whether element is the shortest encoding of its number, the one Bytes returns
+func IsMinimallyEncoded(element []byte) bool {
+	if len(element) == 0 {
+		return true
+	}
+	// the last byte holds the sign bit, it may only be zero apart from that when the byte before needs its top bit
+	if element[len(element)-1]&0x7f == 0 {
+		return len(element) > 1 && element[len(element)-2]&0x80 != 0
+	}
+	return true
+}
+
+// MinimallyEncode returns the shortest encoding of the number element encodes, dropping the zero bytes before the
+// sign and negative zero. Unlike DecodeScriptNum it works for elements of any length.
+func MinimallyEncode(element []byte) []byte {
+	if len(element) == 0 {
+		return []byte{}
+	}
+	last := element[len(element)-1]
+	if last&0x7f != 0 {
+		return append([]byte{}, element...)
+	}
+	if len(element) == 1 {
+		// zero and negative zero
+		return []byte{}
+	}
+	// the sign byte is redundant unless the byte before needs its top bit, drop the zero bytes before it
+	if element[len(element)-2]&0x80 != 0 {
+		return append([]byte{}, element...)
+	}
+	for i := len(element) - 2; i >= 0; i-- {
+		if element[i] != 0 {
+			result := append([]byte{}, element[:i+1]...)
+			if element[i]&0x80 != 0 {
+				// the sign needs a byte of its own
+				return append(result, last)
+			}
+			result[i] |= last
+			return result
+		}
+	}
+	return []byte{}
+}
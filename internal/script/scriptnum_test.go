@@ -0,0 +1,93 @@
+package script
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestScriptNum(t *testing.T) {
+	testCases := []struct {
+		num     ScriptNum
+		encoded []byte
+	}{
+		{0, []byte{}},
+		{1, []byte{0x01}},
+		{-1, []byte{0x81}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x00}},
+		{-128, []byte{0x80, 0x80}},
+		{255, []byte{0xff, 0x00}},
+		{256, []byte{0x00, 0x01}},
+		{-32768, []byte{0x00, 0x80, 0x80}},
+		{500000000, []byte{0x00, 0x65, 0xcd, 0x1d}},
+		{1<<31 - 1, []byte{0xff, 0xff, 0xff, 0x7f}},
+		{1 << 32, []byte{0x00, 0x00, 0x00, 0x00, 0x01}},
+		{math.MinInt64 + 1, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, tc := range testCases {
+		if got := tc.num.Bytes(); !bytes.Equal(got, tc.encoded) {
+			t.Errorf("Bytes of %d = %x, want %x", tc.num, got, tc.encoded)
+		}
+		if got := DecodeScriptNum(tc.encoded); got != tc.num {
+			t.Errorf("DecodeScriptNum(%x) = %d, want %d", tc.encoded, got, tc.num)
+		}
+		if !IsMinimallyEncoded(tc.encoded) {
+			t.Errorf("%x should be minimally encoded", tc.encoded)
+		}
+	}
+
+	if got := ScriptNum(1 << 40).Int32(); got != math.MaxInt32 {
+		t.Errorf("Int32 of 2^40 = %d, want it clamped to %d", got, math.MaxInt32)
+	}
+	if got := ScriptNum(-1 << 40).Int32(); got != math.MinInt32 {
+		t.Errorf("Int32 of -2^40 = %d, want it clamped to %d", got, math.MinInt32)
+	}
+}
+
+func TestParseScriptNum(t *testing.T) {
+	if n, err := ParseScriptNum([]byte{0x00, 0x65, 0xcd, 0x1d}, MaxScriptNumLen, true); err != nil || n != 500000000 {
+		t.Errorf("ParseScriptNum = %d, %v, want 500000000", n, err)
+	}
+	if _, err := ParseScriptNum([]byte{0x00, 0x00, 0x00, 0x00, 0x01}, MaxScriptNumLen, false); err == nil {
+		t.Errorf("5 byte operand should exceed %d bytes", MaxScriptNumLen)
+	}
+	if n, err := ParseScriptNum([]byte{0x00, 0x00, 0x00, 0x00, 0x01}, MaxLockTimeNumLen, true); err != nil || n != 1<<32 {
+		t.Errorf("5 byte locktime operand = %d, %v", n, err)
+	}
+	if _, err := ParseScriptNum([]byte{0x01, 0x00}, MaxScriptNumLen, true); err == nil {
+		t.Errorf("padded operand should not be minimally encoded")
+	}
+	if n, err := ParseScriptNum([]byte{0x01, 0x00}, MaxScriptNumLen, false); err != nil || n != 1 {
+		t.Errorf("padded operand without the minimal rule = %d, %v, want 1", n, err)
+	}
+}
+
+func TestMinimallyEncode(t *testing.T) {
+	testCases := []struct {
+		element, minimal []byte
+	}{
+		{[]byte{}, []byte{}},
+		{[]byte{0x00}, []byte{}},
+		{[]byte{0x80}, []byte{}},
+		{[]byte{0x00, 0x00, 0x80}, []byte{}},
+		{[]byte{0x01, 0x00}, []byte{0x01}},
+		{[]byte{0x01, 0x00, 0x80}, []byte{0x81}},
+		{[]byte{0x80, 0x00, 0x00}, []byte{0x80, 0x00}},
+		{[]byte{0xff, 0x00, 0x80}, []byte{0xff, 0x80}},
+		{[]byte{0x80, 0x00}, []byte{0x80, 0x00}},
+		{[]byte{0x05}, []byte{0x05}},
+	}
+	for _, tc := range testCases {
+		got := MinimallyEncode(tc.element)
+		if !bytes.Equal(got, tc.minimal) {
+			t.Errorf("MinimallyEncode(%x) = %x, want %x", tc.element, got, tc.minimal)
+		}
+		if !IsMinimallyEncoded(got) {
+			t.Errorf("MinimallyEncode(%x) = %x is not minimally encoded", tc.element, got)
+		}
+		if len(tc.element) <= 8 && DecodeScriptNum(got) != DecodeScriptNum(tc.element) {
+			t.Errorf("MinimallyEncode(%x) = %x changed the number", tc.element, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package script
+
+// MaxPubKeysPerMultisig is what an OP_CHECKMULTISIG counts for when the number of keys is not known
+const MaxPubKeysPerMultisig = 20
+
+// SigOpCount counts the signature operations in the script.
+// With accurate set, an OP_CHECKMULTISIG preceded by OP_1 through OP_16 counts for that many keys,
+// which is how redeem and witness scripts are counted. Otherwise it always counts for 20.
+func (s *Script) SigOpCount(accurate bool) int {
+	count := 0
+	lastOpCode := -1
+
+	for _, cmd := range *s {
+		if !isOpCode(cmd) {
+			lastOpCode = -1
+			continue
+		}
+
+		opCode := int(cmd[0])
+		switch opCode {
+		case 172, 173:
+			// OP_CHECKSIG, OP_CHECKSIGVERIFY
+			count++
+		case 174, 175:
+			// OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY
+			if accurate && lastOpCode >= 81 && lastOpCode <= 96 {
+				count += lastOpCode - 80
+			} else {
+				count += MaxPubKeysPerMultisig
+			}
+		}
+		lastOpCode = opCode
+	}
+
+	return count
+}
+
+// P2SHSigOpCount counts the signature operations of the redeem script in scriptSig when s is a p2sh ScriptPubKey
+func (s *Script) P2SHSigOpCount(scriptSig *Script) int {
+	if !s.IsP2SHScriptPubKey() {
+		return 0
+	}
+
+	redeemScript, err := lastPushAsScript(scriptSig)
+	if err != nil {
+		return 0
+	}
+
+	return redeemScript.SigOpCount(true)
+}
+
+// WitnessSigOpCount counts the signature operations of a witness program spent by scriptSig and witness.
+// P2SH wrapped witness programs are recognized as well. Unknown witness versions count as zero.
+func WitnessSigOpCount(scriptSig, scriptPubkey *Script, witness [][]byte) int {
+	if scriptPubkey.IsP2SHScriptPubKey() {
+		redeemScript, err := lastPushAsScript(scriptSig)
+		if err != nil {
+			return 0
+		}
+		scriptPubkey = redeemScript
+	}
+
+	switch {
+	case scriptPubkey.IsP2WPKHScriptPubKey():
+		return 1
+	case scriptPubkey.IsP2WSHScriptPubKey():
+		if len(witness) == 0 {
+			return 0
+		}
+		witnessScript, err := ParseRawScript(witness[len(witness)-1])
+		if err != nil {
+			return 0
+		}
+		return witnessScript.SigOpCount(true)
+	}
+
+	return 0
+}
+
+func lastPushAsScript(scriptSig *Script) (*Script, error) {
+	if scriptSig == nil || len(*scriptSig) == 0 {
+		return &Script{}, nil
+	}
+	return ParseRawScript((*scriptSig)[len(*scriptSig)-1])
+}
@@ -0,0 +1,93 @@
+package script
+
+import (
+	"bytes"
+	"testing"
+)
+
+func multisigScript(m, n int) *Script {
+	s := Script{[]byte{byte(80 + m)}}
+	for i := 0; i < n; i++ {
+		s = append(s, bytes.Repeat([]byte{byte(i + 2)}, 33))
+	}
+	s = append(s, []byte{byte(80 + n)}, []byte{0xae})
+	return &s
+}
+
+func rawScript(t *testing.T, s *Script) []byte {
+	t.Helper()
+	raw, err := s.RawSerialize()
+	if err != nil {
+		t.Fatalf("Failed to serialize script: %v", err)
+	}
+	return raw
+}
+
+func TestSigOpCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   *Script
+		accurate bool
+		want     int
+	}{
+		{"p2pkh", CreateP2pkhScript(make([]byte, 20)), false, 1},
+		{"checksigverify", &Script{[]byte{0xad}, []byte{0xac}}, false, 2},
+		{"multisig inaccurate", multisigScript(2, 3), false, 20},
+		{"multisig accurate", multisigScript(2, 3), true, 3},
+		{"multisig without key count", &Script{bytes.Repeat([]byte{1}, 33), []byte{0xaf}}, true, 20},
+		{"no sigops", &Script{[]byte{0x51}}, true, 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.script.SigOpCount(tt.accurate); got != tt.want {
+			t.Errorf("%s: SigOpCount(%v) = %d, want %d", tt.name, tt.accurate, got, tt.want)
+		}
+	}
+}
+
+func TestP2SHSigOpCount(t *testing.T) {
+	redeemScript := rawScript(t, multisigScript(2, 3))
+	scriptPubkey := CreateP2SHScript(make([]byte, 20))
+	scriptSig := &Script{[]byte{0x00}, bytes.Repeat([]byte{1}, 72), redeemScript}
+
+	if got := scriptPubkey.P2SHSigOpCount(scriptSig); got != 3 {
+		t.Errorf("P2SHSigOpCount = %d, want 3", got)
+	}
+
+	// Not p2sh, so the scriptSig is not looked at
+	if got := CreateP2pkhScript(make([]byte, 20)).P2SHSigOpCount(scriptSig); got != 0 {
+		t.Errorf("P2SHSigOpCount of p2pkh = %d, want 0", got)
+	}
+
+	// A redeem script that cannot be parsed counts as zero
+	truncated := &Script{[]byte{0x4c, 0xff}}
+	if got := scriptPubkey.P2SHSigOpCount(truncated); got != 0 {
+		t.Errorf("P2SHSigOpCount of malformed redeem script = %d, want 0", got)
+	}
+}
+
+func TestWitnessSigOpCount(t *testing.T) {
+	p2wpkh := &Script{[]byte{0x00}, make([]byte, 20)}
+	p2wsh := &Script{[]byte{0x00}, make([]byte, 32)}
+	witnessScript := rawScript(t, multisigScript(1, 2))
+
+	tests := []struct {
+		name         string
+		scriptSig    *Script
+		scriptPubkey *Script
+		witness      [][]byte
+		want         int
+	}{
+		{"p2wpkh", &Script{}, p2wpkh, [][]byte{{1}, {2}}, 1},
+		{"p2wsh", &Script{}, p2wsh, [][]byte{{}, {1}, witnessScript}, 2},
+		{"p2wsh without witness", &Script{}, p2wsh, nil, 0},
+		{"p2sh-p2wpkh", &Script{rawScript(t, p2wpkh)}, CreateP2SHScript(make([]byte, 20)), [][]byte{{1}, {2}}, 1},
+		{"p2pkh", &Script{}, CreateP2pkhScript(make([]byte, 20)), nil, 0},
+	}
+
+	for _, tt := range tests {
+		if got := WitnessSigOpCount(tt.scriptSig, tt.scriptPubkey, tt.witness); got != tt.want {
+			t.Errorf("%s: WitnessSigOpCount = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
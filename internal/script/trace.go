@@ -0,0 +1,78 @@
+package script
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+)
+
+// TraceStep is the state of the interpreter around a single command, stack elements are hex encoded from the bottom up
+type TraceStep struct {
+	Step int    `json:"step"`
+	Op   string `json:"op"`
+	// Executed is false for commands in a branch that is not executed
+	Executed    bool     `json:"executed"`
+	StackBefore []string `json:"stack_before"`
+	StackAfter  []string `json:"stack_after"`
+	AltStack    []string `json:"altstack"`
+	Result      bool     `json:"result"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// EvaluateTrace evaluates the script like Evaluate and writes a trace of each step to w as a line of JSON
+func (s *Script) EvaluateTrace(z *big.Int, w io.Writer) (bool, error) {
+	encoder := json.NewEncoder(w)
+	var writeErr error
+	ctx := &ExecutionContext{
+		Z:     z,
+		Flags: StandardVerifyFlags,
+		Tracer: func(step TraceStep) {
+			if writeErr == nil {
+				writeErr = encoder.Encode(step)
+			}
+		},
+	}
+
+	ok, err := s.Execute(ctx)
+	if writeErr != nil {
+		return false, writeErr
+	}
+	return ok, err
+}
+
+func (ctx *ExecutionContext) trace(cmd []byte, before []string, executed bool, err error) {
+	ctx.steps++
+	step := TraceStep{
+		Step:        ctx.steps,
+		Op:          commandName(cmd),
+		Executed:    executed,
+		StackBefore: before,
+		StackAfter:  ctx.Stack.hexElements(),
+		AltStack:    ctx.AltStack.hexElements(),
+		Result:      err == nil,
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	ctx.Tracer(step)
+}
+
+// commandName returns the name of an opcode, or the hex of the data a command pushes
+func commandName(cmd []byte) string {
+	if isOpCode(cmd) {
+		if name, ok := opCodeNames[int(cmd[0])]; ok {
+			return name
+		}
+		return "OP_UNKNOWN"
+	}
+	return hex.EncodeToString(cmd)
+}
+
+func (s Stack) hexElements() []string {
+	elements := make([]string, len(s))
+	for i, element := range s {
+		elements[i] = hex.EncodeToString(element)
+	}
+	return elements
+}
@@ -0,0 +1,70 @@
+package script
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func readTrace(t *testing.T, trace *bytes.Buffer) []TraceStep {
+	t.Helper()
+	var steps []TraceStep
+	scanner := bufio.NewScanner(trace)
+	for scanner.Scan() {
+		var step TraceStep
+		if err := json.Unmarshal(scanner.Bytes(), &step); err != nil {
+			t.Fatalf("Invalid trace line %q: %v", scanner.Text(), err)
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func TestEvaluateTrace(t *testing.T) {
+	// OP_2 OP_3 OP_ADD OP_5 OP_EQUAL
+	script := Script{[]byte{0x52}, []byte{0x53}, []byte{0x93}, []byte{0x55}, []byte{0x87}}
+
+	var trace bytes.Buffer
+	ok, err := script.EvaluateTrace(nil, &trace)
+	if !ok || err != nil {
+		t.Fatalf("Script should evaluate to true: %v", err)
+	}
+
+	steps := readTrace(t, &trace)
+	if len(steps) != 5 {
+		t.Fatalf("Expected 5 steps, got %d", len(steps))
+	}
+	add := steps[2]
+	if add.Step != 3 || add.Op != "OP_ADD" || !add.Executed || !add.Result {
+		t.Errorf("Unexpected OP_ADD step %+v", add)
+	}
+	if !reflect.DeepEqual(add.StackBefore, []string{"02", "03"}) || !reflect.DeepEqual(add.StackAfter, []string{"05"}) {
+		t.Errorf("OP_ADD stacks %v -> %v", add.StackBefore, add.StackAfter)
+	}
+	if last := steps[4]; !reflect.DeepEqual(last.StackAfter, []string{"01"}) {
+		t.Errorf("OP_EQUAL should leave 01, got %v", last.StackAfter)
+	}
+}
+
+func TestEvaluateTraceBranchesAndErrors(t *testing.T) {
+	// OP_0 OP_IF OP_2 OP_ENDIF OP_0 OP_VERIFY
+	script := Script{[]byte{0x00}, []byte{0x63}, []byte{0x52}, []byte{0x68}, []byte{0x00}, []byte{0x69}}
+
+	var trace bytes.Buffer
+	if ok, err := script.EvaluateTrace(nil, &trace); ok || err == nil {
+		t.Fatalf("OP_VERIFY of 0 should fail")
+	}
+
+	steps := readTrace(t, &trace)
+	if len(steps) != 6 {
+		t.Fatalf("Expected 6 steps, got %d", len(steps))
+	}
+	if steps[2].Executed || !steps[1].Executed || !steps[3].Executed {
+		t.Errorf("Only OP_2 is in a branch that is not executed: %+v", steps[1:4])
+	}
+	if failed := steps[5]; failed.Result || failed.Error == "" || failed.Op != "OP_VERIFY" {
+		t.Errorf("Expected the failing OP_VERIFY as last step, got %+v", failed)
+	}
+}
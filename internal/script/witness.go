@@ -0,0 +1,53 @@
+package script
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// WitnessProgram returns the version and program of a witness program ScriptPubKey (BIP141): OP_0 through OP_16
+// followed by a direct push of 2 to 40 bytes and nothing else. A program pushed with OP_PUSHDATA1 is no witness
+// program, so it is checked on the raw bytes of the script.
+func (s *Script) WitnessProgram() (int, []byte, bool) {
+	if len(*s) != 2 || !isOpCode((*s)[0]) {
+		return 0, nil, false
+	}
+	raw, err := s.RawSerialize()
+	if err != nil || len(raw) < 4 || len(raw) > 42 || int(raw[1]) != len(raw)-2 {
+		return 0, nil, false
+	}
+	var version int
+	switch opCode := raw[0]; {
+	case opCode == 0x00:
+		version = 0
+	case opCode >= 0x51 && opCode <= 0x60:
+		version = int(opCode) - 0x50
+	default:
+		return 0, nil, false
+	}
+	return version, raw[2:], true
+}
+
+// checkWitnessProgram applies the rules for witness versions no soft fork has defined, which includes version 1
+// programs that are not 32 bytes or are wrapped in p2sh. Consensus lets anyone spend them, so a later soft fork
+// can give them meaning, while ScriptVerifyDiscourageUpgradableWitnessProgram rejects them. The witness of
+// version 0 and taproot spends is not verified here, so they fail with ErrWitnessNotImplemented instead of
+// passing unchecked. Taproot outputs spent before ScriptVerifyTaproot are anyone can spend.
+func checkWitnessProgram(version int, program []byte, p2sh bool, flags VerificationFlags) error {
+	switch {
+	case version == 0:
+		return fmt.Errorf("witness version 0 program of %d bytes: %w", len(program), ErrWitnessNotImplemented)
+	case version == 1 && len(program) == 32 && !p2sh:
+		if flags.Has(ScriptVerifyTaproot) {
+			return fmt.Errorf("taproot program: %w", ErrWitnessNotImplemented)
+		}
+		return nil
+	case version == 1 && !p2sh && bytes.Equal(program, []byte{0x4e, 0x73}):
+		// pay to anchor is standard to spend
+		return nil
+	}
+	if flags.Has(ScriptVerifyDiscourageUpgradableWitnessProgram) {
+		return fmt.Errorf("witness version %d program of %d bytes: %w", version, len(program), ErrUpgradableWitnessProgram)
+	}
+	return nil
+}
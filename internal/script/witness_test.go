@@ -0,0 +1,115 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func TestWitnessProgram(t *testing.T) {
+	testCases := []struct {
+		scriptPubkey *Script
+		version      int
+		ok           bool
+	}{
+		{CreateP2WPKHScript(make([]byte, 20)), 0, true},
+		{CreateP2TRScript(make([]byte, 32)), 1, true},
+		{CreateP2AScript(), 1, true},
+		{&Script{{0x60}, make([]byte, 40)}, 16, true},
+		{&Script{{0x52}, make([]byte, 41)}, 0, false},
+		{&Script{{0x52}, {0x01}}, 0, false},
+		{&Script{{0x4f}, make([]byte, 20)}, 0, false},
+		{CreateP2pkhScript(make([]byte, 20)), 0, false},
+		{&Script{{0x00}, taggedPush(make([]byte, 20), 0x4c)}, 0, false},
+	}
+	for _, tc := range testCases {
+		version, program, ok := tc.scriptPubkey.WitnessProgram()
+		if ok != tc.ok || version != tc.version {
+			t.Errorf("WitnessProgram of %s = %d, %v, want %d, %v", tc.scriptPubkey, version, ok, tc.version, tc.ok)
+		}
+		if ok && !bytes.Equal(program, (*tc.scriptPubkey)[1]) {
+			t.Errorf("WitnessProgram of %s returned program %x", tc.scriptPubkey, program)
+		}
+	}
+}
+
+func TestPushDataWitnessProgram(t *testing.T) {
+	// OP_0 OP_PUSHDATA1 <20 bytes> is no witness program, a ScriptSig may spend it
+	raw := append([]byte{0x00, 0x4c, 0x14}, bytes.Repeat([]byte{0x01}, 20)...)
+	scriptPubkey, err := ParseRawScript(raw)
+	if err != nil {
+		t.Fatalf("ParseRawScript error: %v", err)
+	}
+	if serialized, _ := scriptPubkey.RawSerialize(); !bytes.Equal(serialized, raw) {
+		t.Errorf("OP_PUSHDATA1 program serialized to %x, want %x", serialized, raw)
+	}
+	if _, _, ok := scriptPubkey.WitnessProgram(); ok {
+		t.Errorf("%s should not be a witness program", scriptPubkey)
+	}
+	if err := VerifyScript(&Script{{0x51}}, scriptPubkey, &ExecutionContext{Flags: FlagsAtHeight(params.MainNet, 900000)}); err != nil {
+		t.Errorf("ScriptSig spending %s should verify: %v", scriptPubkey, err)
+	}
+}
+
+func TestUpgradableWitnessPrograms(t *testing.T) {
+	// the programs are not all zero, a zero program leaves false on the stack and fails before the witness rules apply
+	consensus := FlagsAtHeight(params.MainNet, 900000)
+	v2 := &Script{{0x52}, bytes.Repeat([]byte{0x01}, 32)}
+	v1Short := &Script{{0x51}, bytes.Repeat([]byte{0x01}, 20)}
+
+	for _, scriptPubkey := range []*Script{v2, v1Short} {
+		if err := VerifyScript(&Script{}, scriptPubkey, &ExecutionContext{Flags: consensus}); err != nil {
+			t.Errorf("Consensus should let anyone spend %s: %v", scriptPubkey, err)
+		}
+		err := VerifyScript(&Script{}, scriptPubkey, &ExecutionContext{Flags: PolicyVerifyFlags})
+		if !errors.Is(err, ErrUpgradableWitnessProgram) {
+			t.Errorf("Policy should reject spending %s, got %v", scriptPubkey, err)
+		}
+	}
+
+	// the defined versions and pay to anchor are not upgradable
+	for _, scriptPubkey := range []*Script{CreateP2WPKHScript(bytes.Repeat([]byte{0x01}, 20)), CreateP2TRScript(bytes.Repeat([]byte{0x01}, 32)), CreateP2AScript()} {
+		if err := VerifyScript(&Script{}, scriptPubkey, &ExecutionContext{Flags: PolicyVerifyFlags}); errors.Is(err, ErrUpgradableWitnessProgram) {
+			t.Errorf("%s is not an upgradable witness program", scriptPubkey)
+		}
+	}
+
+	// the witness of version 0 and taproot spends is not verified, they fail instead of passing unchecked
+	for _, scriptPubkey := range []*Script{CreateP2WPKHScript(bytes.Repeat([]byte{0x01}, 20)), CreateP2TRScript(bytes.Repeat([]byte{0x01}, 32))} {
+		if err := VerifyScript(&Script{}, scriptPubkey, &ExecutionContext{Flags: consensus}); !errors.Is(err, ErrWitnessNotImplemented) {
+			t.Errorf("Spending %s should fail with ErrWitnessNotImplemented, got %v", scriptPubkey, err)
+		}
+	}
+	preTaproot := StandardVerifyFlags | ScriptVerifyWitness
+	if err := VerifyScript(&Script{}, CreateP2TRScript(bytes.Repeat([]byte{0x01}, 32)), &ExecutionContext{Flags: preTaproot}); err != nil {
+		t.Errorf("Before taproot anyone can spend a version 1 program: %v", err)
+	}
+
+	// a native witness program is spent with an empty ScriptSig
+	if err := VerifyScript(&Script{{0x51}}, v2, &ExecutionContext{Flags: consensus}); !errors.Is(err, ErrWitnessMalleated) {
+		t.Errorf("ScriptSig spending a witness program should be empty, got %v", err)
+	}
+	// before segwit a witness program is only a script that leaves true
+	if err := VerifyScript(&Script{{0x51}}, v2, &ExecutionContext{Flags: StandardVerifyFlags}); err != nil {
+		t.Errorf("Without ScriptVerifyWitness the program is not checked: %v", err)
+	}
+}
+
+func TestUpgradableP2SHWitnessProgram(t *testing.T) {
+	// a 32 byte version 1 program is only taproot when it is not wrapped in p2sh
+	redeemScript, _ := CreateP2TRScript(bytes.Repeat([]byte{0x01}, 32)).RawSerialize()
+	p2sh := CreateP2SHScript(utils.Hash160(redeemScript))
+
+	if err := VerifyScript(&Script{redeemScript}, p2sh, &ExecutionContext{Flags: FlagsAtHeight(params.MainNet, 900000)}); err != nil {
+		t.Errorf("Consensus should let anyone spend a p2sh wrapped version 1 program: %v", err)
+	}
+	if err := VerifyScript(&Script{redeemScript}, p2sh, &ExecutionContext{Flags: PolicyVerifyFlags}); !errors.Is(err, ErrUpgradableWitnessProgram) {
+		t.Errorf("Policy should reject a p2sh wrapped version 1 program, got %v", err)
+	}
+	if err := VerifyScript(&Script{{0x51}, redeemScript}, p2sh, &ExecutionContext{Flags: PolicyVerifyFlags}); !errors.Is(err, ErrWitnessMalleated) {
+		t.Errorf("ScriptSig should only push the witness program, got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !libsecp256k1 || !cgo
+
+package signatureverification
+
+import "math/big"
+
+// CurveBackend names the implementation behind Sign and Verify. Build with the libsecp256k1 tag to use the C library.
+const CurveBackend = "go"
+
+func verify(point *S256Point, z *big.Int, sig *Signature) bool {
+	return point.verifyNative(z, sig)
+}
+
+func sign(key *PrivateKey, z *big.Int) (*Signature, error) {
+	return key.signNative(z)
+}
@@ -0,0 +1,73 @@
+//go:build libsecp256k1 && cgo
+
+package signatureverification
+
+/*
+#cgo LDFLAGS: -lsecp256k1
+#include <secp256k1.h>
+
+static secp256k1_context *new_context(void) {
+	return secp256k1_context_create(SECP256K1_CONTEXT_SIGN | SECP256K1_CONTEXT_VERIFY);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"math/big"
+	"unsafe"
+)
+
+// CurveBackend names the implementation behind Sign and Verify
+const CurveBackend = "libsecp256k1"
+
+// secp256k1Context is read only after creation, libsecp256k1 allows sharing it between goroutines
+var secp256k1Context = C.new_context()
+
+// verify checks the signature with libsecp256k1. Values the library cannot represent, such as an r or s
+// of at least N, are left to the Go implementation so both backends accept exactly the same signatures.
+func verify(point *S256Point, z *big.Int, sig *Signature) bool {
+	if z.BitLen() > 256 || sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.Cmp(N) >= 0 || sig.S.Cmp(N) >= 0 {
+		return point.verifyNative(z, sig)
+	}
+
+	sec := point.Serialize(false)
+	var pubkey C.secp256k1_pubkey
+	if C.secp256k1_ec_pubkey_parse(secp256k1Context, &pubkey, (*C.uchar)(unsafe.Pointer(&sec[0])), C.size_t(len(sec))) != 1 {
+		return point.verifyNative(z, sig)
+	}
+
+	compact := make([]byte, 64)
+	sig.R.FillBytes(compact[:32])
+	sig.S.FillBytes(compact[32:])
+	var signature C.secp256k1_ecdsa_signature
+	if C.secp256k1_ecdsa_signature_parse_compact(secp256k1Context, &signature, (*C.uchar)(unsafe.Pointer(&compact[0]))) != 1 {
+		return point.verifyNative(z, sig)
+	}
+	// libsecp256k1 only verifies low-S signatures, consensus accepts both halves
+	C.secp256k1_ecdsa_signature_normalize(secp256k1Context, &signature, &signature)
+
+	msg := z.FillBytes(make([]byte, 32))
+	return C.secp256k1_ecdsa_verify(secp256k1Context, &signature, (*C.uchar)(unsafe.Pointer(&msg[0])), &pubkey) == 1
+}
+
+// sign signs with libsecp256k1 and its RFC 6979 nonces. The nonce is the one GetDeterministicK derives and both
+// return the low-S form, so the signature equals the Go one.
+func sign(key *PrivateKey, z *big.Int) (*Signature, error) {
+	if z.BitLen() > 256 {
+		return key.signNative(z)
+	}
+
+	msg := z.FillBytes(make([]byte, 32))
+	secret := key.Secret.FillBytes(make([]byte, 32))
+	defer clear(secret)
+
+	var signature C.secp256k1_ecdsa_signature
+	if C.secp256k1_ecdsa_sign(secp256k1Context, &signature, (*C.uchar)(unsafe.Pointer(&msg[0])), (*C.uchar)(unsafe.Pointer(&secret[0])), nil, nil) != 1 {
+		return nil, fmt.Errorf("libsecp256k1 could not sign with this key")
+	}
+
+	compact := make([]byte, 64)
+	C.secp256k1_ecdsa_signature_serialize_compact(secp256k1Context, (*C.uchar)(unsafe.Pointer(&compact[0])), &signature)
+	return NewSignature(new(big.Int).SetBytes(compact[:32]), new(big.Int).SetBytes(compact[32:])), nil
+}
@@ -0,0 +1,46 @@
+//go:build libsecp256k1 && cgo
+
+package signatureverification
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// TestLibsecp256k1MatchesNative cross-validates the C backend against the Go implementation
+func TestLibsecp256k1MatchesNative(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		secret := new(big.Int).SetBytes(utils.Hash256([]byte{byte(i), 's'}))
+		key, err := NewPrivateKey(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		z := new(big.Int).SetBytes(utils.Hash256([]byte{byte(i), 'z'}))
+
+		libSig, err := key.Sign(z)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		nativeSig, err := key.signNative(z)
+		if err != nil {
+			t.Fatalf("signNative failed: %v", err)
+		}
+
+		// both derive the RFC 6979 nonce and pick the low s
+		if libSig.R.Cmp(nativeSig.R) != 0 || libSig.S.Cmp(nativeSig.S) != 0 {
+			t.Errorf("key %d: libsecp256k1 signed %s, Go signed %s", i, libSig, nativeSig)
+		}
+
+		for _, sig := range []*Signature{libSig, nativeSig} {
+			if !key.Point.Verify(z, sig) || !key.Point.verifyNative(z, sig) {
+				t.Errorf("key %d: signature %s does not verify with both backends", i, sig)
+			}
+			other := new(big.Int).Add(z, big.NewInt(1))
+			if key.Point.Verify(other, sig) != key.Point.verifyNative(other, sig) {
+				t.Errorf("key %d: backends disagree on a signature for another message", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package signatureverification
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// CompactSignatureLength is the length of a recoverable signature: a header byte, r and s
+const CompactSignatureLength = 65
+
+// compactHeader is the first header byte, Bitcoin Core adds the recovery id and 4 for a compressed key
+const compactHeader = 27
+
+// SignCompact signs z in the 65-byte recoverable format of Bitcoin Core's signmessage. The header tells
+// which of the up to four keys the signature (r, s) recovers to is this one, and whether it is compressed.
+func (e *PrivateKey) SignCompact(z *big.Int, compressed bool) ([]byte, error) {
+	sig, err := e.Sign(z)
+	if err != nil {
+		return nil, err
+	}
+
+	header := byte(compactHeader)
+	if compressed {
+		header += 4
+	}
+	compact := make([]byte, CompactSignatureLength)
+	sig.R.FillBytes(compact[1:33])
+	sig.S.FillBytes(compact[33:])
+
+	for recoveryID := byte(0); recoveryID < 4; recoveryID++ {
+		compact[0] = header + recoveryID
+		point, _, err := RecoverCompact(z, compact)
+		if err == nil && point.Equal(&e.Point.Point) {
+			return compact, nil
+		}
+	}
+	return nil, fmt.Errorf("signature does not recover to the signing key")
+}
+
+// RecoverCompact returns the public key that signed z with the recoverable signature compact, and whether
+// the signer used its compressed form. The signature is valid for the returned key by construction, so
+// the caller only has to check that the key is the one it expects.
+//
+// The recovery id of the header gives R = (r + (id>>1)N, y) with the parity of y in the low bit of id, and
+// because sR = zG + rP the key is P = r⁻¹(sR - zG).
+func RecoverCompact(z *big.Int, compact []byte) (*S256Point, bool, error) {
+	if len(compact) != CompactSignatureLength {
+		return nil, false, fmt.Errorf("compact signature is %d bytes, not %d", len(compact), CompactSignatureLength)
+	}
+	header := compact[0]
+	if header < compactHeader || header >= compactHeader+8 {
+		return nil, false, fmt.Errorf("bad compact signature header %d", header)
+	}
+	recoveryID := (header - compactHeader) & 3
+	compressed := header-compactHeader >= 4
+
+	r := new(big.Int).SetBytes(compact[1:33])
+	s := new(big.Int).SetBytes(compact[33:])
+	if r.Sign() == 0 || r.Cmp(N) >= 0 || s.Sign() == 0 || s.Cmp(N) >= 0 {
+		return nil, false, fmt.Errorf("compact signature is out of range")
+	}
+
+	x := new(big.Int).Set(r)
+	if recoveryID&2 != 0 {
+		x.Add(x, N)
+	}
+	if x.Cmp(S256Prime) >= 0 {
+		return nil, false, fmt.Errorf("recovery id %d gives no point", recoveryID)
+	}
+	sec := make([]byte, 33)
+	sec[0] = 0x02 | recoveryID&1
+	x.FillBytes(sec[1:])
+	R, err := ParseSEC(sec)
+	if err != nil {
+		return nil, false, fmt.Errorf("recovery id %d gives no point: %v", recoveryID, err)
+	}
+
+	rInv := new(big.Int).ModInverse(r, N)
+	u := new(big.Int).Mod(new(big.Int).Mul(s, rInv), N)
+	v := new(big.Int).Mod(new(big.Int).Neg(new(big.Int).Mul(z, rInv)), N)
+
+	uR, err := R.ScalarMultiplication(u)
+	if err != nil {
+		return nil, false, err
+	}
+	vG, err := G.ScalarMultiplication(v)
+	if err != nil {
+		return nil, false, err
+	}
+	sum, err := uR.Add(&vG.Point)
+	if err != nil {
+		return nil, false, err
+	}
+	if sum.IsIdentityElement() {
+		return nil, false, fmt.Errorf("signature recovers to the point at infinity")
+	}
+	return &S256Point{*sum}, compressed, nil
+}
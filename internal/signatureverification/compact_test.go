@@ -0,0 +1,67 @@
+package signatureverification
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func TestSignCompactRecovers(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		key, err := NewPrivateKey(utils.Hash256ToBigInt(fmt.Sprintf("compact secret %d", i)))
+		if err != nil {
+			t.Fatalf("failed to create private key: %v", err)
+		}
+		z := utils.Hash256ToBigInt(fmt.Sprintf("compact message %d", i))
+		compressed := i%2 == 0
+
+		compact, err := key.SignCompact(z, compressed)
+		if err != nil {
+			t.Fatalf("SignCompact: %v", err)
+		}
+		if len(compact) != CompactSignatureLength {
+			t.Fatalf("compact signature is %d bytes", len(compact))
+		}
+		point, gotCompressed, err := RecoverCompact(z, compact)
+		if err != nil {
+			t.Fatalf("RecoverCompact: %v", err)
+		}
+		if !point.Equal(&key.Point.Point) || gotCompressed != compressed {
+			t.Errorf("signature %d recovers to %x compressed %t", i, point.Serialize(true), gotCompressed)
+		}
+		sig := NewSignature(new(big.Int).SetBytes(compact[1:33]), new(big.Int).SetBytes(compact[33:]))
+		if !point.Verify(z, sig) {
+			t.Errorf("signature %d does not verify for the recovered key", i)
+		}
+
+		// the signature of another message recovers to another key
+		other, _, err := RecoverCompact(new(big.Int).Add(z, big.NewInt(1)), compact)
+		if err == nil && other.Equal(&key.Point.Point) {
+			t.Errorf("signature %d recovers to the signer for another message", i)
+		}
+	}
+}
+
+func TestRecoverCompactRejectsMalformed(t *testing.T) {
+	key, _ := NewPrivateKey(big.NewInt(8675309))
+	z := utils.Hash256ToBigInt("message")
+	compact, err := key.SignCompact(z, true)
+	if err != nil {
+		t.Fatalf("SignCompact: %v", err)
+	}
+
+	badHeader := append([]byte{26}, compact[1:]...)
+	zeroR := append(append([]byte{compact[0]}, make([]byte, 32)...), compact[33:]...)
+	for name, data := range map[string][]byte{
+		"short":       compact[:64],
+		"bad header":  badHeader,
+		"zero r":      zeroR,
+		"high header": append([]byte{35}, compact[1:]...),
+	} {
+		if _, _, err := RecoverCompact(z, data); err == nil {
+			t.Errorf("%s: RecoverCompact should fail", name)
+		}
+	}
+}
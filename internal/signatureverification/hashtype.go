@@ -0,0 +1,91 @@
+package signatureverification
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SigHashType is the byte that follows a DER signature in a script and selects what the signature commits to
+type SigHashType byte
+
+const (
+	// SigHashDefault only exists for taproot, where it signs like SigHashAll
+	SigHashDefault      SigHashType = 0x00
+	SigHashAll          SigHashType = 0x01
+	SigHashNone         SigHashType = 0x02
+	SigHashSingle       SigHashType = 0x03
+	SigHashAnyoneCanPay SigHashType = 0x80
+)
+
+// IsDefined returns whether t is SIGHASH_ALL, SIGHASH_NONE or SIGHASH_SINGLE, optionally with
+// SIGHASH_ANYONECANPAY, the only hash types standard legacy and segwit signatures may have
+func (t SigHashType) IsDefined() bool {
+	base := t &^ SigHashAnyoneCanPay
+	return base >= SigHashAll && base <= SigHashSingle
+}
+
+// String returns the name of the hash type, such as SIGHASH_SINGLE|SIGHASH_ANYONECANPAY
+func (t SigHashType) String() string {
+	var name string
+	switch t &^ SigHashAnyoneCanPay {
+	case SigHashDefault:
+		name = "SIGHASH_DEFAULT"
+	case SigHashAll:
+		name = "SIGHASH_ALL"
+	case SigHashNone:
+		name = "SIGHASH_NONE"
+	case SigHashSingle:
+		name = "SIGHASH_SINGLE"
+	default:
+		return fmt.Sprintf("SIGHASH_UNKNOWN(%#02x)", byte(t))
+	}
+	if t&SigHashAnyoneCanPay != 0 {
+		return strings.Join([]string{name, "SIGHASH_ANYONECANPAY"}, "|")
+	}
+	return name
+}
+
+// SignatureWithHashType is a signature as it is pushed in a script: DER followed by the hash type
+type SignatureWithHashType struct {
+	Signature *Signature
+	HashType  SigHashType
+}
+
+// SplitHashType splits the hash type byte off a signature as it is pushed in a script, without parsing the DER
+func SplitHashType(raw []byte) ([]byte, SigHashType, error) {
+	if len(raw) == 0 {
+		return nil, 0, fmt.Errorf("empty signature has no hash type")
+	}
+	return raw[:len(raw)-1], SigHashType(raw[len(raw)-1]), nil
+}
+
+// ParseSignatureWithHashType parses a DER signature followed by a hash type, which has to be defined
+func ParseSignatureWithHashType(raw []byte) (*SignatureWithHashType, error) {
+	sig, err := ParseSignatureWithAnyHashType(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !sig.HashType.IsDefined() {
+		return nil, fmt.Errorf("signature has undefined hash type %s", sig.HashType)
+	}
+	return sig, nil
+}
+
+// ParseSignatureWithAnyHashType parses a DER signature followed by a hash type of any value. Consensus accepts
+// undefined hash types in legacy and segwit scripts, only the policy of strict encoding rejects them.
+func ParseSignatureWithAnyHashType(raw []byte) (*SignatureWithHashType, error) {
+	der, hashType, err := SplitHashType(raw)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ParseDER(der)
+	if err != nil {
+		return nil, err
+	}
+	return &SignatureWithHashType{Signature: sig, HashType: hashType}, nil
+}
+
+// Serialize returns the DER signature followed by the hash type
+func (s *SignatureWithHashType) Serialize() []byte {
+	return append(s.Signature.Serialize(), byte(s.HashType))
+}
@@ -0,0 +1,77 @@
+package signatureverification
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestSigHashTypeString(t *testing.T) {
+	testCases := []struct {
+		hashType SigHashType
+		name     string
+		defined  bool
+	}{
+		{SigHashAll, "SIGHASH_ALL", true},
+		{SigHashNone, "SIGHASH_NONE", true},
+		{SigHashSingle | SigHashAnyoneCanPay, "SIGHASH_SINGLE|SIGHASH_ANYONECANPAY", true},
+		{SigHashDefault, "SIGHASH_DEFAULT", false},
+		{SigHashAnyoneCanPay, "SIGHASH_DEFAULT|SIGHASH_ANYONECANPAY", false},
+		{0x04, "SIGHASH_UNKNOWN(0x04)", false},
+	}
+	for _, tc := range testCases {
+		if got := tc.hashType.String(); got != tc.name {
+			t.Errorf("String of %#02x = %s, want %s", byte(tc.hashType), got, tc.name)
+		}
+		if got := tc.hashType.IsDefined(); got != tc.defined {
+			t.Errorf("IsDefined of %s = %v, want %v", tc.name, got, tc.defined)
+		}
+	}
+}
+
+func TestParseSignatureWithHashType(t *testing.T) {
+	key, err := NewPrivateKey(big.NewInt(12345))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := key.Sign(big.NewInt(67890))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := append(sig.Serialize(), byte(SigHashSingle|SigHashAnyoneCanPay))
+
+	parsed, err := ParseSignatureWithHashType(raw)
+	if err != nil {
+		t.Fatalf("ParseSignatureWithHashType error: %v", err)
+	}
+	if parsed.HashType != SigHashSingle|SigHashAnyoneCanPay {
+		t.Errorf("hash type = %s, want SIGHASH_SINGLE|SIGHASH_ANYONECANPAY", parsed.HashType)
+	}
+	if parsed.Signature.R.Cmp(sig.R) != 0 || parsed.Signature.S.Cmp(sig.S) != 0 {
+		t.Errorf("parsed signature differs from the signature")
+	}
+	if !bytes.Equal(parsed.Serialize(), raw) {
+		t.Errorf("Serialize = %x, want %x", parsed.Serialize(), raw)
+	}
+
+	der, hashType, err := SplitHashType(raw)
+	if err != nil || !bytes.Equal(der, sig.Serialize()) || hashType != parsed.HashType {
+		t.Errorf("SplitHashType = %x, %s, %v", der, hashType, err)
+	}
+
+	for _, invalid := range [][]byte{
+		nil,
+		append(sig.Serialize(), 0x00),
+		append(sig.Serialize(), 0x84),
+		{0x30, 0x01},
+	} {
+		if _, err := ParseSignatureWithHashType(invalid); err == nil {
+			t.Errorf("ParseSignatureWithHashType(%x) should fail", invalid)
+		}
+	}
+
+	// consensus leaves the hash type to the policy of strict encoding
+	if parsed, err := ParseSignatureWithAnyHashType(append(sig.Serialize(), 0x84)); err != nil || parsed.HashType != 0x84 {
+		t.Errorf("ParseSignatureWithAnyHashType with hash type 0x84 = %v, %v", parsed, err)
+	}
+}
@@ -0,0 +1,78 @@
+package signatureverification
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"sync"
+)
+
+// DefaultSigCacheSize is the number of valid signatures a signature cache holds by default
+const DefaultSigCacheSize = 50000
+
+// SigCache remembers signatures that verified, so a signature checked when a transaction entered the mempool
+// is not verified again when the block containing it is validated. Like the signature cache of Bitcoin Core
+// it only stores successes and evicts a random entry when full, which keeps lookups free of write locks.
+// It is safe for concurrent use.
+type SigCache struct {
+	mu       sync.RWMutex
+	capacity int
+	entries  map[[32]byte]struct{}
+}
+
+func NewSigCache(capacity int) *SigCache {
+	if capacity <= 0 {
+		capacity = DefaultSigCacheSize
+	}
+	return &SigCache{capacity: capacity, entries: make(map[[32]byte]struct{}, capacity)}
+}
+
+// Len returns the number of cached signatures
+func (c *SigCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// Verify is point.Verify(z, sig) that consults the cache first and adds the signature when it is valid.
+// A nil cache verifies every time.
+func (c *SigCache) Verify(point *S256Point, z *big.Int, sig *Signature) bool {
+	if c == nil {
+		return point.Verify(z, sig)
+	}
+
+	key := sigCacheKey(point, z, sig)
+	c.mu.RLock()
+	_, ok := c.entries[key]
+	c.mu.RUnlock()
+	if ok {
+		return true
+	}
+
+	if !point.Verify(z, sig) {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.capacity {
+		// map iteration starts at a random entry
+		for evicted := range c.entries {
+			delete(c.entries, evicted)
+			break
+		}
+	}
+	c.entries[key] = struct{}{}
+	return true
+}
+
+func sigCacheKey(point *S256Point, z *big.Int, sig *Signature) [32]byte {
+	h := sha256.New()
+	h.Write(point.Serialize(true))
+	zBytes := z.Bytes()
+	h.Write([]byte{byte(len(zBytes))})
+	h.Write(zBytes)
+	h.Write(sig.Serialize())
+	var key [32]byte
+	h.Sum(key[:0])
+	return key
+}
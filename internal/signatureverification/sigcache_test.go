@@ -0,0 +1,46 @@
+package signatureverification
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSigCache(t *testing.T) {
+	key, err := NewPrivateKey(big.NewInt(12345))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zs := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}
+	sigs := make([]*Signature, len(zs))
+	for i, z := range zs {
+		if sigs[i], err = key.Sign(z); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var noCache *SigCache
+	if !noCache.Verify(key.Point, zs[0], sigs[0]) || noCache.Verify(key.Point, zs[1], sigs[0]) {
+		t.Error("a nil cache should verify every signature")
+	}
+
+	cache := NewSigCache(2)
+	if !cache.Verify(key.Point, zs[0], sigs[0]) || !cache.Verify(key.Point, zs[0], sigs[0]) {
+		t.Error("valid signature should verify")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("cache holds %d signatures, want 1", cache.Len())
+	}
+	if cache.Verify(key.Point, zs[1], sigs[0]) || cache.Len() != 1 {
+		t.Errorf("invalid signature verified or was cached, %d entries", cache.Len())
+	}
+
+	// the cache stays within its size
+	for i := range zs {
+		if !cache.Verify(key.Point, zs[i], sigs[i]) {
+			t.Errorf("signature %d should verify", i)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("cache holds %d signatures, want 2", cache.Len())
+	}
+}
@@ -71,6 +71,40 @@ func ParseDER(data []byte) (*Signature, error) {
 	return NewSignature(r, s), nil
 }
 
+// IsStrictDER returns whether data is a DER signature in the strict encoding BIP66 requires:
+// exact lengths and minimally encoded, positive integers
+func IsStrictDER(data []byte) bool {
+	if len(data) < 8 || len(data) > 72 {
+		return false
+	}
+	if data[0] != 0x30 || int(data[1]) != len(data)-2 {
+		return false
+	}
+
+	lenR := int(data[3])
+	if 5+lenR >= len(data) {
+		return false
+	}
+	lenS := int(data[5+lenR])
+	if lenR+lenS+6 != len(data) {
+		return false
+	}
+
+	return isStrictDERInteger(data[2:4+lenR]) && isStrictDERInteger(data[4+lenR:])
+}
+
+// isStrictDERInteger checks an integer element: its marker, a non-zero length and no negative or padded value
+func isStrictDERInteger(element []byte) bool {
+	if element[0] != 0x02 || element[1] == 0 {
+		return false
+	}
+	value := element[2:]
+	if value[0]&0x80 != 0 {
+		return false
+	}
+	return len(value) == 1 || value[0] != 0 || value[1]&0x80 != 0
+}
+
 func parseBigInt(reader *bytes.Reader) (*big.Int, error) {
 	marker, err := reader.ReadByte()
 	if err != nil || marker != 0x02 {
@@ -98,6 +132,11 @@ func parseBigInt(reader *bytes.Reader) (*big.Int, error) {
 // 3. We calculate uG + vP = R;
 // 4. If R's x-coordinate equals r, the signature is valid;
 func (p256 *S256Point) Verify(z *big.Int, sig *Signature) bool {
+	return verify(p256, z, sig)
+}
+
+// verifyNative is Verify in pure Go, whichever curve backend is built in
+func (p256 *S256Point) verifyNative(z *big.Int, sig *Signature) bool {
 	// Calculate s_inv (modular inverse of s)
 	sInv := new(big.Int).ModInverse(sig.S, N)
 	if sInv == nil {
@@ -260,7 +299,17 @@ func NewPrivateKey(secret *big.Int) (*PrivateKey, error) {
 // 4. Calculate s = (z + re)/k;
 // 5. Signature is (r,s);
 func (e *PrivateKey) Sign(z *big.Int) (*Signature, error) {
+	if z == nil {
+		return nil, fmt.Errorf("one or more signature inputs were invalid")
+	}
+	return sign(e, z)
+}
+
+// halfN is N / 2, the largest s of a low-S signature
+var halfN = new(big.Int).Rsh(N, 1)
 
+// signNative is Sign in pure Go, whichever curve backend is built in. Like libsecp256k1 it returns low-S signatures.
+func (e *PrivateKey) signNative(z *big.Int) (*Signature, error) {
 	if z == nil {
 		return nil, fmt.Errorf("one or more signature inputs were invalid")
 	}
@@ -289,6 +338,10 @@ func (e *PrivateKey) Sign(z *big.Int) (*Signature, error) {
 
 	// Modulo with N to get the final result
 	s := new(big.Int).Mod(product, N)
+	// s and N - s both verify, the low one is standard (BIP62) and the one libsecp256k1 returns
+	if s.Cmp(halfN) > 0 {
+		s.Sub(N, s)
+	}
 
 	// P, err := G.ScalarMultiplication(e)
 
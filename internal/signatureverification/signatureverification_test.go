@@ -134,6 +134,31 @@ func TestSignatureDerParsing(t *testing.T) {
 	}
 }
 
+func TestIsStrictDER(t *testing.T) {
+	strict := "3045022037206a0610995c58074999cb9767b87af4c4978db68c06e8e6e81d282047a7c60221008ca63759c1157ebeaec0d03cecca119fc9a75bf8e6d0fa65c841c8e2738cdaec"
+	tests := []struct {
+		name string
+		der  string
+		want bool
+	}{
+		{"strict", strict, true},
+		{"minimal", "3006020101020101", true},
+		{"wrong total length", "3007020101020101", false},
+		{"padded r", "300702020001020101", false},
+		{"negative s", "3006020101020181", false},
+		{"empty r", "30050200020101", false},
+		{"truncated", "30040202", false},
+		{"trailing byte", strict + "00", false},
+	}
+
+	for _, tt := range tests {
+		der, _ := hex.DecodeString(tt.der)
+		if got := IsStrictDER(der); got != tt.want {
+			t.Errorf("%s: IsStrictDER = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
 // randInt generates a random big.Int with the given bit length.
 func randInt(bitLen int) *big.Int {
 	// Note: In a real-world scenario, you may want to use a more secure random number generator.
@@ -160,6 +185,27 @@ func TestSignAndVerify(t *testing.T) {
 	}
 }
 
+func TestSignLowS(t *testing.T) {
+	for i := 0; i < 32; i++ {
+		privKey, err := NewPrivateKey(utils.Hash256ToBigInt(fmt.Sprintf("secret %d", i)))
+		if err != nil {
+			t.Fatalf("failed to create private key: %v", err)
+		}
+		z := utils.Hash256ToBigInt(fmt.Sprintf("message %d", i))
+		sig, err := privKey.Sign(z)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		if sig.S.Cmp(halfN) > 0 {
+			t.Errorf("signature %d has high S %x", i, sig.S)
+		}
+		P, _ := G.ScalarMultiplication(privKey.Secret)
+		if !P.Verify(z, sig) {
+			t.Errorf("low-S signature %d does not verify", i)
+		}
+	}
+}
+
 func TestSerializeS256Point(t *testing.T) {
 	// Define the test case struct
 	type testCase struct {
@@ -0,0 +1,249 @@
+// Package testutil generates keys, coins and signed transactions for tests that must not touch the network.
+// Everything is derived from a seed, the same seed always produces the same fixtures.
+package testutil
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// ScriptType is a kind of output the generator can lock coins to
+type ScriptType int
+
+const (
+	P2PKH ScriptType = iota
+	// P2SH outputs pay to a 2-of-3 multisig redeem script
+	P2SH
+	P2WPKH
+	P2WSH
+	P2TR
+)
+
+// ScriptTypes lists every ScriptType
+var ScriptTypes = []ScriptType{P2PKH, P2SH, P2WPKH, P2WSH, P2TR}
+
+func (t ScriptType) String() string {
+	switch t {
+	case P2PKH:
+		return "p2pkh"
+	case P2SH:
+		return "p2sh"
+	case P2WPKH:
+		return "p2wpkh"
+	case P2WSH:
+		return "p2wsh"
+	case P2TR:
+		return "p2tr"
+	}
+	return fmt.Sprintf("ScriptType(%d)", int(t))
+}
+
+// Signable returns whether SignedTx can spend outputs of this type. Segwit inputs
+// need the BIP143 and BIP341 signature hashes, which this library cannot compute yet.
+func (t ScriptType) Signable() bool {
+	return t == P2PKH || t == P2SH
+}
+
+// UTXO is a generated coin together with what is needed to spend it
+type UTXO struct {
+	OutPoint transaction.OutPoint
+	TxOut    *transaction.TxOut
+	Type     ScriptType
+	// Keys can sign for the output, all three keys of the multisig for P2SH
+	Keys []*signatureverification.PrivateKey
+	// RedeemScript is the multisig script of P2SH and P2WSH outputs
+	RedeemScript *script.Script
+}
+
+// Generator derives fixtures from a seed. It is not safe for concurrent use.
+type Generator struct {
+	Testnet bool
+
+	seed    []byte
+	counter uint64
+	fetcher *transaction.TxFetcher
+	funding map[transaction.OutPoint]*transaction.TxOut
+}
+
+func NewGenerator(seed string, testnet bool) *Generator {
+	return &Generator{
+		Testnet: testnet,
+		seed:    []byte(seed),
+		fetcher: transaction.NewTxFetcher(),
+		funding: make(map[transaction.OutPoint]*transaction.TxOut),
+	}
+}
+
+// next returns 32 bytes that depend on the seed and on how many were returned before
+func (g *Generator) next() []byte {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, g.counter)
+	g.counter++
+	digest := sha256.Sum256(append(append([]byte{}, g.seed...), counter...))
+	return digest[:]
+}
+
+// Key returns the next private key
+func (g *Generator) Key() *signatureverification.PrivateKey {
+	for {
+		secret := new(big.Int).SetBytes(g.next())
+		if secret.Sign() == 0 || secret.Cmp(signatureverification.N) >= 0 {
+			continue
+		}
+		key, err := signatureverification.NewPrivateKey(secret)
+		if err != nil {
+			continue
+		}
+		return key
+	}
+}
+
+// UTXO creates a coin of amount satoshis locked to new keys. The transaction that funds it spends
+// a made-up outpoint and is added to Fetcher, so its output can be looked up without the network.
+func (g *Generator) UTXO(scriptType ScriptType, amount uint64) (*UTXO, error) {
+	utxo := &UTXO{Type: scriptType}
+	var scriptPubkey *script.Script
+	switch scriptType {
+	case P2PKH, P2WPKH:
+		key := g.Key()
+		utxo.Keys = []*signatureverification.PrivateKey{key}
+		h160 := key.Point.Hash160(true)
+		if scriptType == P2PKH {
+			scriptPubkey = script.CreateP2pkhScript(h160)
+		} else {
+			scriptPubkey = script.CreateP2WPKHScript(h160)
+		}
+	case P2SH, P2WSH:
+		utxo.Keys = []*signatureverification.PrivateKey{g.Key(), g.Key(), g.Key()}
+		utxo.RedeemScript = multisigScript(2, utxo.Keys)
+		rawRedeemScript, err := utxo.RedeemScript.RawSerialize()
+		if err != nil {
+			return nil, err
+		}
+		if scriptType == P2SH {
+			scriptPubkey = script.CreateP2SHScript(utils.Hash160(rawRedeemScript))
+		} else {
+			digest := sha256.Sum256(rawRedeemScript)
+			scriptPubkey = script.CreateP2WSHScript(digest[:])
+		}
+	case P2TR:
+		// the x coordinate of a key stands in for a tweaked output key
+		key := g.Key()
+		utxo.Keys = []*signatureverification.PrivateKey{key}
+		scriptPubkey = script.CreateP2TRScript(key.Point.Serialize(true)[1:])
+	default:
+		return nil, fmt.Errorf("unknown script type %s", scriptType)
+	}
+	utxo.TxOut = transaction.NewTxOut(amount, scriptPubkey)
+
+	funding := transaction.NewTx(1, []*transaction.TxIn{transaction.NewTxIn(g.next(), 0, &script.Script{}, 0xffffffff)}, []*transaction.TxOut{utxo.TxOut}, 0, g.Testnet)
+	if err := g.fetcher.Add(funding); err != nil {
+		return nil, err
+	}
+	hash, err := funding.Hash()
+	if err != nil {
+		return nil, err
+	}
+	utxo.OutPoint = transaction.NewOutPoint(hash, 0)
+	g.funding[utxo.OutPoint] = utxo.TxOut
+	return utxo, nil
+}
+
+// SignedTx spends utxos to outputs and signs every input. Only types that are Signable can be spent.
+func (g *Generator) SignedTx(utxos []*UTXO, outputs []*transaction.TxOut) (*transaction.Tx, error) {
+	txIns := make([]*transaction.TxIn, len(utxos))
+	for i, utxo := range utxos {
+		if !utxo.Type.Signable() {
+			return nil, fmt.Errorf("input %d: signing %s inputs is not supported", i, utxo.Type)
+		}
+		txIns[i] = transaction.NewTxInFromOutPoint(utxo.OutPoint, &script.Script{}, 0xffffffff)
+	}
+	tx := transaction.NewTx(1, txIns, outputs, 0, g.Testnet)
+
+	for i, utxo := range utxos {
+		signedScript := utxo.TxOut.ScriptPubkey
+		if utxo.Type == P2SH {
+			signedScript = utxo.RedeemScript
+		}
+		z, err := tx.SigHash(uint32(i), signedScript)
+		if err != nil {
+			return nil, err
+		}
+
+		var scriptSig script.Script
+		switch utxo.Type {
+		case P2PKH:
+			sig, err := sign(utxo.Keys[0], z)
+			if err != nil {
+				return nil, err
+			}
+			scriptSig = script.Script{sig, utxo.Keys[0].Point.Serialize(true)}
+		case P2SH:
+			// OP_CHECKMULTISIG pops one element too many, the first two keys sign
+			scriptSig = script.Script{{}}
+			for _, key := range utxo.Keys[:2] {
+				sig, err := sign(key, z)
+				if err != nil {
+					return nil, err
+				}
+				scriptSig = append(scriptSig, sig)
+			}
+			rawRedeemScript, err := utxo.RedeemScript.RawSerialize()
+			if err != nil {
+				return nil, err
+			}
+			scriptSig = append(scriptSig, rawRedeemScript)
+		}
+		tx.TxIns[i].ScriptSig = &scriptSig
+
+		if err := tx.VerifyInputWith(uint32(i), utxo.TxOut.ScriptPubkey, script.StandardVerifyFlags); err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+
+	if err := g.fetcher.Add(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Fetcher returns a fetcher that holds every generated transaction. Transactions that were not
+// generated are still fetched from the network, point its Client at a fake transport to prevent that.
+func (g *Generator) Fetcher() *transaction.TxFetcher {
+	return g.fetcher
+}
+
+// Prevouts looks up the outputs spent by the inputs of generated transactions
+func (g *Generator) Prevouts() transaction.PrevoutLookup {
+	return func(txIn *transaction.TxIn) (*transaction.TxOut, error) {
+		txOut, ok := g.funding[txIn.PrevOut]
+		if !ok {
+			return nil, fmt.Errorf("%s was not generated", txIn.PrevOut.DisplayString())
+		}
+		return txOut, nil
+	}
+}
+
+func multisigScript(m int, keys []*signatureverification.PrivateKey) *script.Script {
+	s := script.Script{[]byte{byte(0x50 + m)}}
+	for _, key := range keys {
+		s = append(s, key.Point.Serialize(true))
+	}
+	s = append(s, []byte{byte(0x50 + len(keys))}, []byte{0xae})
+	return &s
+}
+
+func sign(key *signatureverification.PrivateKey, z *big.Int) ([]byte, error) {
+	derSig, err := key.Sign(z)
+	if err != nil {
+		return nil, err
+	}
+	return append(derSig.Serialize(), byte(transaction.SigHashAll)), nil
+}
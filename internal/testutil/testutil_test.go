@@ -0,0 +1,109 @@
+package testutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	ids := func() []string {
+		g := NewGenerator("fixtures", true)
+		var ids []string
+		for _, scriptType := range ScriptTypes {
+			utxo, err := g.UTXO(scriptType, 10000)
+			if err != nil {
+				t.Fatalf("UTXO(%s) failed: %v", scriptType, err)
+			}
+			ids = append(ids, utxo.OutPoint.DisplayString())
+		}
+		return ids
+	}
+
+	first, second := ids(), ids()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("%s outpoint %s, then %s", ScriptTypes[i], first[i], second[i])
+		}
+	}
+
+	other := NewGenerator("other seed", true)
+	if utxo, _ := other.UTXO(P2PKH, 10000); utxo.OutPoint.DisplayString() == first[0] {
+		t.Error("different seeds generated the same coin")
+	}
+}
+
+func TestUTXOScriptTypes(t *testing.T) {
+	g := NewGenerator("fixtures", false)
+	checks := map[ScriptType]func(s *script.Script) bool{
+		P2PKH:  (*script.Script).IsP2PKHScriptPubKey,
+		P2SH:   (*script.Script).IsP2SHScriptPubKey,
+		P2WPKH: (*script.Script).IsP2WPKHScriptPubKey,
+		P2WSH:  (*script.Script).IsP2WSHScriptPubKey,
+		P2TR:   (*script.Script).IsP2TRScriptPubKey,
+	}
+	for _, scriptType := range ScriptTypes {
+		utxo, err := g.UTXO(scriptType, 5000)
+		if err != nil {
+			t.Fatalf("UTXO(%s) failed: %v", scriptType, err)
+		}
+		if !checks[scriptType](utxo.TxOut.ScriptPubkey) {
+			t.Errorf("%s coin is locked to %s", scriptType, utxo.TxOut.ScriptPubkey)
+		}
+
+		funding, err := g.Fetcher().Fetch(utxo.OutPoint.TxidString(), false, false)
+		if err != nil {
+			t.Fatalf("funding transaction of the %s coin not in the fetcher: %v", scriptType, err)
+		}
+		if funding.TxOuts[0].Amount != 5000 {
+			t.Errorf("%s funding output has %d satoshis", scriptType, funding.TxOuts[0].Amount)
+		}
+	}
+}
+
+func TestSignedTx(t *testing.T) {
+	g := NewGenerator("fixtures", true)
+	p2pkh, _ := g.UTXO(P2PKH, 30000)
+	p2sh, _ := g.UTXO(P2SH, 20000)
+	output := transaction.NewTxOut(45000, script.CreateP2WPKHScript(g.Key().Point.Hash160(true)))
+
+	tx, err := g.SignedTx([]*UTXO{p2pkh, p2sh}, []*transaction.TxOut{output})
+	if err != nil {
+		t.Fatalf("SignedTx failed: %v", err)
+	}
+	for i, utxo := range []*UTXO{p2pkh, p2sh} {
+		if err := tx.VerifyInputWith(uint32(i), utxo.TxOut.ScriptPubkey, script.StandardVerifyFlags); err != nil {
+			t.Errorf("input %d does not verify: %v", i, err)
+		}
+	}
+
+	// signatures use RFC6979 nonces, so the transaction is the same every time
+	again := NewGenerator("fixtures", true)
+	p2pkh, _ = again.UTXO(P2PKH, 30000)
+	p2sh, _ = again.UTXO(P2SH, 20000)
+	output = transaction.NewTxOut(45000, script.CreateP2WPKHScript(again.Key().Point.Hash160(true)))
+	tx2, err := again.SignedTx([]*UTXO{p2pkh, p2sh}, []*transaction.TxOut{output})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := tx.Serialize()
+	raw2, _ := tx2.Serialize()
+	if !bytes.Equal(raw, raw2) {
+		t.Error("the same seed produced different transactions")
+	}
+
+	txIn := tx.TxIns[1]
+	if prevout, err := g.Prevouts()(txIn); err != nil || prevout.Amount != 20000 {
+		t.Errorf("Prevouts returned %v, %v", prevout, err)
+	}
+}
+
+func TestSignedTxRejectsSegwit(t *testing.T) {
+	g := NewGenerator("fixtures", true)
+	utxo, _ := g.UTXO(P2WPKH, 10000)
+	if _, err := g.SignedTx([]*UTXO{utxo}, nil); err == nil {
+		t.Error("expected signing a p2wpkh input to fail")
+	}
+}
@@ -0,0 +1,180 @@
+// Package threshold is an EXPERIMENTAL 2-of-2 ECDSA signing protocol for prototyping multi-party custody.
+// It follows the message flow of Lindell's two-party ECDSA: the key and the nonce are shared multiplicatively,
+// so neither party ever holds the private key or the nonce of a signature, and the produced signatures
+// verify with the existing S256Point.Verify.
+//
+// The Paillier encryption of the first party's share is SIMULATED: EncryptedShare carries the share in the
+// clear, so the second party learns it. Do not protect real funds with this package.
+package threshold
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+// Party holds one share of a 2-of-2 key. Create the two halves with NewParty, exchange the public shares
+// with SetPeer, then sign with Party1.Commit, Party2.PartialSign and Party1.Finish.
+type Party struct {
+	PublicShare *signatureverification.S256Point
+
+	share     *big.Int
+	peerShare *signatureverification.S256Point
+	// nonce is the share of the nonce of the signature being made
+	nonce      *big.Int
+	noncePoint *signatureverification.S256Point
+}
+
+// EncryptedShare stands for the Paillier encryption of the first party's share. In this simulated mode it is not encrypted.
+type EncryptedShare struct {
+	share *big.Int
+}
+
+// PartialSignature is what the second party sends back to the first
+type PartialSignature struct {
+	// NoncePoint is the second party's share of the nonce point
+	NoncePoint *signatureverification.S256Point
+	// value stands for the Paillier ciphertext k2^-1 * (z + r * x1 * x2)
+	value *big.Int
+}
+
+// NewParty creates a party holding share, a random one when share is nil
+func NewParty(share *big.Int) (*Party, error) {
+	if share == nil {
+		var err error
+		if share, err = randomScalar(); err != nil {
+			return nil, err
+		}
+	}
+	if share.Sign() <= 0 || share.Cmp(signatureverification.N) >= 0 {
+		return nil, fmt.Errorf("share is out of range")
+	}
+	publicShare, err := signatureverification.G.ScalarMultiplication(share)
+	if err != nil {
+		return nil, err
+	}
+	return &Party{PublicShare: publicShare, share: new(big.Int).Set(share)}, nil
+}
+
+// SetPeer records the public share of the other party
+func (p *Party) SetPeer(peerShare *signatureverification.S256Point) {
+	p.peerShare = peerShare
+}
+
+// PublicKey returns the joint public key x1 * x2 * G, which both parties compute from their own share and the peer's public share
+func (p *Party) PublicKey() (*signatureverification.S256Point, error) {
+	if p.peerShare == nil {
+		return nil, fmt.Errorf("public share of the peer is not set")
+	}
+	return p.peerShare.ScalarMultiplication(p.share)
+}
+
+// EncryptedShare returns the first party's share for the second party, which needs it to compute partial signatures
+func (p *Party) EncryptedShare() *EncryptedShare {
+	return &EncryptedShare{share: new(big.Int).Set(p.share)}
+}
+
+// Commit starts a signature as the first party and returns its nonce point for the second party
+func (p *Party) Commit() (*signatureverification.S256Point, error) {
+	if err := p.newNonce(); err != nil {
+		return nil, err
+	}
+	return p.noncePoint, nil
+}
+
+// PartialSign signs z as the second party, given the nonce point of the first party.
+// The nonce is random for every call: deterministic nonces are unsafe when the other party can choose its own.
+func (p *Party) PartialSign(z *big.Int, peerNoncePoint *signatureverification.S256Point, encryptedShare *EncryptedShare) (*PartialSignature, error) {
+	if z == nil || peerNoncePoint == nil || encryptedShare == nil {
+		return nil, fmt.Errorf("one or more signature inputs were invalid")
+	}
+	if err := p.newNonce(); err != nil {
+		return nil, err
+	}
+	defer p.clearNonce()
+
+	r, err := sharedR(peerNoncePoint, p.nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// k2^-1 * (z + r * x1 * x2), computed on a Paillier ciphertext of x1 in the real protocol
+	x := new(big.Int).Mul(encryptedShare.share, p.share)
+	value := new(big.Int).Add(new(big.Int).Mod(z, signatureverification.N), new(big.Int).Mul(r, x))
+	value.Mul(value, new(big.Int).ModInverse(p.nonce, signatureverification.N))
+	value.Mod(value, signatureverification.N)
+
+	return &PartialSignature{NoncePoint: p.noncePoint, value: value}, nil
+}
+
+// Finish completes the signature of z as the first party and checks it against the joint public key
+func (p *Party) Finish(z *big.Int, partial *PartialSignature) (*signatureverification.Signature, error) {
+	if p.nonce == nil {
+		return nil, fmt.Errorf("no signature was started with Commit")
+	}
+	defer p.clearNonce()
+	if partial == nil || partial.NoncePoint == nil {
+		return nil, fmt.Errorf("partial signature is incomplete")
+	}
+
+	r, err := sharedR(partial.NoncePoint, p.nonce)
+	if err != nil {
+		return nil, err
+	}
+	s := new(big.Int).Mul(partial.value, new(big.Int).ModInverse(p.nonce, signatureverification.N))
+	s.Mod(s, signatureverification.N)
+	signature := signatureverification.NewSignature(r, s)
+
+	publicKey, err := p.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if !publicKey.Verify(z, signature) {
+		return nil, fmt.Errorf("joint signature does not verify")
+	}
+	return signature, nil
+}
+
+func (p *Party) newNonce() error {
+	nonce, err := randomScalar()
+	if err != nil {
+		return err
+	}
+	noncePoint, err := signatureverification.G.ScalarMultiplication(nonce)
+	if err != nil {
+		return err
+	}
+	p.nonce, p.noncePoint = nonce, noncePoint
+	return nil
+}
+
+// clearNonce forgets the nonce, a nonce used for two signatures reveals the share
+func (p *Party) clearNonce() {
+	p.nonce, p.noncePoint = nil, nil
+}
+
+// sharedR returns the x coordinate of k1 * k2 * G from the peer's nonce point and the own nonce
+func sharedR(peerNoncePoint *signatureverification.S256Point, nonce *big.Int) (*big.Int, error) {
+	R, err := peerNoncePoint.ScalarMultiplication(nonce)
+	if err != nil {
+		return nil, err
+	}
+	if R.X == nil {
+		return nil, fmt.Errorf("nonce point is the point at infinity")
+	}
+	return new(big.Int).Set(R.X.Value), nil
+}
+
+func randomScalar() (*big.Int, error) {
+	for {
+		k, err := rand.Int(rand.Reader, signatureverification.N)
+		if err != nil {
+			return nil, err
+		}
+		if k.Sign() > 0 {
+			return k, nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package threshold
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func newParties(t *testing.T) (*Party, *Party) {
+	t.Helper()
+	party1, err := NewParty(big.NewInt(12345))
+	if err != nil {
+		t.Fatal(err)
+	}
+	party2, err := NewParty(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	party1.SetPeer(party2.PublicShare)
+	party2.SetPeer(party1.PublicShare)
+	return party1, party2
+}
+
+func TestTwoPartySignature(t *testing.T) {
+	party1, party2 := newParties(t)
+	publicKey1, err := party1.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey2, _ := party2.PublicKey()
+	if publicKey1.Address(true, true) != publicKey2.Address(true, true) {
+		t.Fatal("the parties computed different public keys")
+	}
+
+	z := new(big.Int).SetBytes(utils.Hash256([]byte("two party message")))
+	noncePoint, err := party1.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial, err := party2.PartialSign(z, noncePoint, party1.EncryptedShare())
+	if err != nil {
+		t.Fatalf("PartialSign failed: %v", err)
+	}
+	signature, err := party1.Finish(z, partial)
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	// the signature is an ordinary ECDSA signature for the joint key, and DER encodes like one
+	parsed, err := signatureverification.ParseDER(signature.Serialize())
+	if err != nil || !publicKey2.Verify(z, parsed) {
+		t.Error("joint signature does not verify")
+	}
+	if publicKey2.Verify(new(big.Int).Add(z, big.NewInt(1)), parsed) {
+		t.Error("joint signature verifies for another message")
+	}
+
+	// the nonce is forgotten after a signature
+	if _, err := party1.Finish(z, partial); err == nil {
+		t.Error("expected Finish to refuse a second signature with the same nonce")
+	}
+}
+
+func TestTwoPartySignatureRejectsMismatch(t *testing.T) {
+	party1, party2 := newParties(t)
+	z := big.NewInt(1000)
+	noncePoint, _ := party1.Commit()
+	partial, err := party2.PartialSign(big.NewInt(999), noncePoint, party1.EncryptedShare())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := party1.Finish(z, partial); err == nil {
+		t.Error("expected a partial signature of another message to fail")
+	}
+
+	if _, err := NewParty(big.NewInt(0)); err == nil {
+		t.Error("expected a zero share to be rejected")
+	}
+	lonely, _ := NewParty(nil)
+	if _, err := lonely.PublicKey(); err == nil {
+		t.Error("expected PublicKey to fail without the peer's share")
+	}
+}
@@ -0,0 +1,251 @@
+package timestamp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/anchoring"
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/merkle"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// HeaderSource provides validated headers by height, such as a block.HeaderStore
+type HeaderSource interface {
+	HeaderAt(height uint32) (*block.Block, error)
+}
+
+// Batch aggregates document hashes into a single commitment output
+type Batch struct {
+	Root   []byte
+	Output *transaction.TxOut
+	Proofs []*Proof
+}
+
+// Proof proves a document hash was committed to in a block.
+// The digest hashes up to a root committed to in an OP_RETURN output of Tx,
+// and the txid hashes up to the merkle root of the block header at Height.
+// Until Complete is called the proof is pending and only the first step is known.
+type Proof struct {
+	Digest      string   `json:"digest"`
+	Index       int      `json:"index"`
+	Branch      []string `json:"branch"`
+	Tx          string   `json:"tx,omitempty"`
+	TxIndex     int      `json:"tx_index"`
+	TxBranch    []string `json:"tx_branch,omitempty"`
+	BlockHash   string   `json:"block_hash,omitempty"`
+	BlockHeight uint32   `json:"block_height"`
+	Testnet     bool     `json:"testnet"`
+}
+
+// Stamp aggregates the document hashes in a merkle tree and returns the output committing to its root,
+// with a pending proof for every document hash
+func Stamp(digests [][]byte, testnet bool) (*Batch, error) {
+	for _, digest := range digests {
+		if len(digest) != anchoring.CommitmentSize {
+			return nil, fmt.Errorf("document hash %x is not %d bytes", digest, anchoring.CommitmentSize)
+		}
+	}
+
+	root, err := merkle.MerkleRoot(digests)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := anchoring.CommitmentOutput([][]byte{root})
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &Batch{Root: root, Output: output}
+	for i, digest := range digests {
+		branch, err := merkle.MerkleBranch(digests, i)
+		if err != nil {
+			return nil, err
+		}
+		batch.Proofs = append(batch.Proofs, &Proof{
+			Digest:  hex.EncodeToString(digest),
+			Index:   i,
+			Branch:  encodeHashes(branch),
+			Testnet: testnet,
+		})
+	}
+
+	return batch, nil
+}
+
+// ParseProof reads a proof from its JSON serialization
+func ParseProof(data []byte) (*Proof, error) {
+	proof := &Proof{}
+	if err := json.Unmarshal(data, proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// Serialize returns the JSON serialization of the proof
+func (p *Proof) Serialize() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// IsPending returns whether the proof still misses the transaction and block
+func (p *Proof) IsPending() bool {
+	return p.Tx == ""
+}
+
+// Complete adds the transaction with the commitment output and its position in the block at height
+func (p *Proof) Complete(tx *transaction.Tx, fullBlock *block.FullBlock, height uint32) error {
+	root, err := p.root()
+	if err != nil {
+		return err
+	}
+	if !commitsTo(tx, root) {
+		return fmt.Errorf("transaction does not commit to %x", root)
+	}
+
+	id, err := tx.Id()
+	if err != nil {
+		return err
+	}
+
+	txIndex := -1
+	for i, blockTx := range fullBlock.Txs {
+		if blockId, err := blockTx.Id(); err == nil && blockId == id {
+			txIndex = i
+			break
+		}
+	}
+	if txIndex < 0 {
+		return fmt.Errorf("transaction %s is not in the block", id)
+	}
+
+	txBranch, err := fullBlock.TxMerkleBranch(txIndex)
+	if err != nil {
+		return err
+	}
+
+	blockHash, err := fullBlock.Header.Hash()
+	if err != nil {
+		return err
+	}
+
+	rawTx, err := tx.SerializeLegacy()
+	if err != nil {
+		return err
+	}
+
+	p.Tx = hex.EncodeToString(rawTx)
+	p.TxIndex = txIndex
+	p.TxBranch = encodeHashes(txBranch)
+	p.BlockHash = hex.EncodeToString(blockHash)
+	p.BlockHeight = height
+	return nil
+}
+
+// Verify checks a complete proof against the headers and returns the timestamp of the block
+// that commits to the document hash
+func (p *Proof) Verify(headers HeaderSource) (uint32, error) {
+	if p.IsPending() {
+		return 0, fmt.Errorf("proof is pending")
+	}
+
+	root, err := p.root()
+	if err != nil {
+		return 0, err
+	}
+
+	rawTx, err := hex.DecodeString(p.Tx)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := transaction.ParseTxStrict(rawTx, p.Testnet)
+	if err != nil {
+		return 0, err
+	}
+	if !commitsTo(tx, root) {
+		return 0, fmt.Errorf("transaction does not commit to %x", root)
+	}
+
+	txHash, err := tx.Hash()
+	if err != nil {
+		return 0, err
+	}
+	txBranch, err := decodeHashes(p.TxBranch)
+	if err != nil {
+		return 0, err
+	}
+
+	header, err := headers.HeaderAt(p.BlockHeight)
+	if err != nil {
+		return 0, err
+	}
+	blockHash, err := header.Hash()
+	if err != nil {
+		return 0, err
+	}
+	if hex.EncodeToString(blockHash) != p.BlockHash {
+		return 0, fmt.Errorf("block %s is not at height %d", p.BlockHash, p.BlockHeight)
+	}
+
+	merkleRoot := header.MerkleRoot
+	if !merkle.VerifyBranch(utils.ReverseBytes(txHash), txBranch, p.TxIndex, utils.ReverseBytes(merkleRoot[:])) {
+		return 0, fmt.Errorf("transaction is not in block %s", p.BlockHash)
+	}
+
+	return header.Timestamp, nil
+}
+
+// root hashes the digest up to the root of the batch
+func (p *Proof) root() ([]byte, error) {
+	digest, err := hex.DecodeString(p.Digest)
+	if err != nil {
+		return nil, err
+	}
+	branch, err := decodeHashes(p.Branch)
+	if err != nil {
+		return nil, err
+	}
+	if p.Index < 0 || p.Index>>len(branch) != 0 {
+		return nil, fmt.Errorf("index %d does not fit a branch of %d hashes", p.Index, len(branch))
+	}
+	return merkle.BranchRoot(digest, branch, p.Index), nil
+}
+
+// commitsTo returns whether one of the outputs of tx is a commitment to root
+func commitsTo(tx *transaction.Tx, root []byte) bool {
+	for _, txOut := range tx.TxOuts {
+		commitments, err := anchoring.Extract(txOut)
+		if err != nil {
+			continue
+		}
+		for _, commitment := range commitments {
+			if bytes.Equal(commitment, root) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func encodeHashes(hashes [][]byte) []string {
+	encoded := make([]string, len(hashes))
+	for i, hash := range hashes {
+		encoded[i] = hex.EncodeToString(hash)
+	}
+	return encoded
+}
+
+func decodeHashes(encoded []string) ([][]byte, error) {
+	hashes := make([][]byte, len(encoded))
+	for i, hexHash := range encoded {
+		hash, err := hex.DecodeString(hexHash)
+		if err != nil {
+			return nil, fmt.Errorf("bad hash %q: %v", hexHash, err)
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
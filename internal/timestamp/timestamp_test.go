@@ -0,0 +1,123 @@
+package timestamp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// Genesis coinbase with only the bits pushed in the ScriptSig, paying to the genesis pubkey
+const coinbaseTx = "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff0504ffff001dffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+type testHeaders map[uint32]*block.Block
+
+func (h testHeaders) HeaderAt(height uint32) (*block.Block, error) {
+	header, ok := h[height]
+	if !ok {
+		return nil, fmt.Errorf("no header at height %d", height)
+	}
+	return header, nil
+}
+
+// confirm puts tx in a block after a coinbase and returns it with its header at height 100
+func confirm(t *testing.T, tx *transaction.Tx) (*block.FullBlock, testHeaders) {
+	t.Helper()
+	rawCoinbase, _ := hex.DecodeString(coinbaseTx)
+	coinbase, err := transaction.ParseTx(bufio.NewReader(bytes.NewReader(rawCoinbase)), true)
+	if err != nil {
+		t.Fatalf("Error parsing coinbase: %v", err)
+	}
+
+	header := *block.Genesis(true)
+	header.Timestamp = 1700000000
+	fullBlock := &block.FullBlock{Header: &header, Txs: []*transaction.Tx{coinbase, tx}, Testnet: true}
+
+	root, err := fullBlock.MerkleRoot()
+	if err != nil {
+		t.Fatalf("Error computing the merkle root: %v", err)
+	}
+	copy(header.MerkleRoot[:], root)
+
+	return fullBlock, testHeaders{100: &header}
+}
+
+func TestStampAndVerify(t *testing.T) {
+	digests := [][]byte{
+		utils.Sha256Hash([]byte("first document")),
+		utils.Sha256Hash([]byte("second document")),
+		utils.Sha256Hash([]byte("third document")),
+	}
+
+	batch, err := Stamp(digests, true)
+	if err != nil {
+		t.Fatalf("Stamp error: %v", err)
+	}
+
+	txIn := transaction.NewTxIn(utils.Hash256([]byte("funding")), 0, &script.Script{}, 0xffffffff)
+	tx := transaction.NewTx(1, []*transaction.TxIn{txIn}, []*transaction.TxOut{batch.Output}, 0, true)
+	fullBlock, headers := confirm(t, tx)
+
+	for i, proof := range batch.Proofs {
+		if _, err := proof.Verify(headers); err == nil {
+			t.Errorf("Proof %d: expected a pending proof not to verify", i)
+		}
+
+		if err := proof.Complete(tx, fullBlock, 100); err != nil {
+			t.Fatalf("Proof %d: Complete error: %v", i, err)
+		}
+
+		data, err := proof.Serialize()
+		if err != nil {
+			t.Fatalf("Proof %d: Serialize error: %v", i, err)
+		}
+		parsed, err := ParseProof(data)
+		if err != nil {
+			t.Fatalf("Proof %d: ParseProof error: %v", i, err)
+		}
+
+		timestamp, err := parsed.Verify(headers)
+		if err != nil {
+			t.Errorf("Proof %d: Verify error: %v", i, err)
+		}
+		if timestamp != 1700000000 {
+			t.Errorf("Proof %d: expected timestamp 1700000000, got %d", i, timestamp)
+		}
+	}
+
+	// another document does not verify with the proof of the first
+	proof := *batch.Proofs[0]
+	proof.Digest = hex.EncodeToString(utils.Sha256Hash([]byte("forged document")))
+	if _, err := proof.Verify(headers); err == nil {
+		t.Errorf("Expected a forged document not to verify")
+	}
+
+	// nor does a proof pointing at another height
+	proof = *batch.Proofs[0]
+	proof.BlockHeight = 101
+	if _, err := proof.Verify(headers); err == nil {
+		t.Errorf("Expected a proof for a missing header not to verify")
+	}
+}
+
+func TestCompleteRejectsOtherTransactions(t *testing.T) {
+	batch, err := Stamp([][]byte{utils.Sha256Hash([]byte("document"))}, true)
+	if err != nil {
+		t.Fatalf("Stamp error: %v", err)
+	}
+
+	txIn := transaction.NewTxIn(utils.Hash256([]byte("funding")), 0, &script.Script{}, 0xffffffff)
+	payment := transaction.NewTxOut(1000, script.CreateP2pkhScript(make([]byte, 20)))
+	tx := transaction.NewTx(1, []*transaction.TxIn{txIn}, []*transaction.TxOut{payment}, 0, true)
+	fullBlock, _ := confirm(t, tx)
+
+	if err := batch.Proofs[0].Complete(tx, fullBlock, 100); err == nil {
+		t.Errorf("Expected an error for a transaction without the commitment")
+	}
+}
@@ -0,0 +1,159 @@
+package transaction
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+// MaxReplaceableSequence is the highest sequence with which an input signals that its transaction may be replaced
+// by one paying a higher fee (BIP125)
+const MaxReplaceableSequence = 0xfffffffd
+
+// SignalsReplacement returns whether an input of tx has a sequence of at most MaxReplaceableSequence
+func (tx *Tx) SignalsReplacement() bool {
+	for _, txIn := range tx.TxIns {
+		if txIn.Sequence <= MaxReplaceableSequence {
+			return true
+		}
+	}
+	return false
+}
+
+// BumpFee returns a replacement of tx that pays feeRate, and at least the fee of tx plus the minimum relay fee of
+// the replacement as BIP125 requires. The extra fee comes out of the change output at changeIndex. When the change
+// can not cover it without becoming dust, coins picked from wallet with SelectCoins are added as inputs, with a
+// sequence that signals replacement, and the change output takes what they add beyond the fee. All inputs are signed
+// again with resolver, as the signatures of tx commit to the outputs that changed; the outputs tx spends are found
+// with lookup, or fetched when it is nil. tx itself is left alone.
+func (tx *Tx) BumpFee(changeIndex int, feeRate float64, wallet []Coin, resolver KeyResolver, lookup PrevoutLookup) (*Tx, error) {
+	if !tx.SignalsReplacement() {
+		return nil, fmt.Errorf("transaction does not signal replacement")
+	}
+	if changeIndex < 0 || changeIndex >= len(tx.TxOuts) {
+		return nil, fmt.Errorf("change output %d does not exist", changeIndex)
+	}
+	if feeRate < MinRelayFeeRate {
+		return nil, fmt.Errorf("fee rate %.2f sat/vB is below the minimum relay fee rate of %.2f", feeRate, MinRelayFeeRate)
+	}
+	if lookup == nil {
+		lookup = NewTxFetcher().PrevoutLookup(tx.Testnet)
+	}
+
+	var inputSum, outputSum uint64
+	var coins []Coin
+	for i, txIn := range tx.TxIns {
+		prevout, err := lookup(txIn)
+		if err != nil {
+			return nil, &InputError{Index: i, Err: err}
+		}
+		inputSum += prevout.Amount
+		coins = append(coins, Coin{OutPoint: txIn.PrevOut, TxOut: prevout})
+	}
+	for _, txOut := range tx.TxOuts {
+		outputSum += txOut.Amount
+	}
+	if outputSum > inputSum {
+		return nil, fmt.Errorf("transaction spends %d satoshis more than its inputs", outputSum-inputSum)
+	}
+	oldFee := inputSum - outputSum
+
+	replacement := tx.Copy()
+	change := replacement.TxOuts[changeIndex]
+	otherOutputs := outputSum - change.Amount
+	dust := DustThreshold(change.ScriptPubkey)
+
+	vsize, err := estimatedVSize(replacement, coins)
+	if err != nil {
+		return nil, err
+	}
+	fee := bumpedFee(oldFee, feeRate, vsize)
+
+	if inputSum < otherOutputs+fee+dust {
+		// the inputs to add pay for themselves through their effective value, the rest of the transaction does not
+		var spent []OutPoint
+		for _, coin := range coins {
+			spent = append(spent, coin.OutPoint)
+		}
+		var available []Coin
+		for _, coin := range wallet {
+			if !slices.Contains(spent, coin.OutPoint) {
+				available = append(available, coin)
+			}
+		}
+		selection, err := SelectCoins(available, otherOutputs+fee+dust-inputSum, feeRate, feeRate, 0)
+		if err != nil {
+			return nil, fmt.Errorf("change of %d satoshis does not cover a fee of %d: %w", change.Amount, fee, err)
+		}
+		for _, coin := range selection.Coins {
+			replacement.TxIns = append(replacement.TxIns, NewTxInFromOutPoint(coin.OutPoint, &script.Script{}, MaxReplaceableSequence))
+			inputSum += coin.TxOut.Amount
+			coins = append(coins, coin)
+		}
+		if vsize, err = estimatedVSize(replacement, coins); err != nil {
+			return nil, err
+		}
+		fee = bumpedFee(oldFee, feeRate, vsize)
+	}
+	if inputSum < otherOutputs+fee+dust {
+		return nil, fmt.Errorf("change after a fee of %d satoshis would be dust", fee)
+	}
+	change.Amount = inputSum - otherOutputs - fee
+
+	for _, txIn := range replacement.TxIns {
+		txIn.ScriptSig, txIn.Witness = &script.Script{}, nil
+	}
+	unsigned, err := replacement.SignAll(resolver, func(txIn *TxIn) (*TxOut, error) {
+		for _, coin := range coins {
+			if coin.OutPoint == txIn.PrevOut {
+				return coin.TxOut, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown prevout %s", txIn.PrevOut.DisplayString())
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(unsigned) > 0 {
+		return nil, fmt.Errorf("no key to sign inputs %v of the replacement", unsigned)
+	}
+	return replacement, nil
+}
+
+// bumpedFee returns the fee of a replacement of vsize vbytes at feeRate, at least oldFee plus its minimum relay fee
+func bumpedFee(oldFee uint64, feeRate float64, vsize int) uint64 {
+	fee := uint64(math.Ceil(feeRate * float64(vsize)))
+	if minimum := oldFee + uint64(math.Ceil(MinRelayFeeRate*float64(vsize))); fee < minimum {
+		fee = minimum
+	}
+	return fee
+}
+
+// estimatedVSize returns the vsize of tx once the inputs spending coins, in the order of its inputs, are signed
+func estimatedVSize(tx *Tx, coins []Coin) (int, error) {
+	unsigned := tx.Copy()
+	satisfaction, witnessInputs := 0, 0
+	for i, txIn := range unsigned.TxIns {
+		weight, witness, ok := satisfactionWeight(coins[i].TxOut.ScriptPubkey)
+		if !ok {
+			return 0, &InputError{Index: i, Err: fmt.Errorf("size of the signed input spending %s is unknown", coins[i].TxOut.ScriptPubkey)}
+		}
+		txIn.ScriptSig, txIn.Witness = &script.Script{}, nil
+		satisfaction += weight
+		if witness {
+			witnessInputs++
+		}
+	}
+	weight, err := unsigned.Weight()
+	if err != nil {
+		return 0, err
+	}
+	weight += satisfaction
+	if witnessInputs > 0 {
+		// the marker and flag, and an empty witness for every input without one
+		weight += 2 + len(unsigned.TxIns) - witnessInputs
+	}
+	return (weight + WitnessScaleFactor - 1) / WitnessScaleFactor, nil
+}
@@ -0,0 +1,81 @@
+package transaction
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+func TestBumpFee(t *testing.T) {
+	alice, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	aliceScript := script.CreateP2pkhScript(alice.Point.Hash160(true))
+	spent := testCoin(0, 20000, aliceScript)
+	wallet := []Coin{spent, testCoin(1, 30000, aliceScript)}
+	lookup := coinLookup(wallet)
+
+	destination := script.CreateP2WPKHScript(make([]byte, 20))
+	tx := NewTx(2, []*TxIn{NewTxInFromOutPoint(spent.OutPoint, &script.Script{}, MaxReplaceableSequence)},
+		[]*TxOut{NewTxOut(10000, destination), NewTxOut(9000, aliceScript)}, 0, true)
+	if _, err := tx.SignAll(KeyMap{0: alice}, lookup); err != nil {
+		t.Fatalf("SignAll error: %v", err)
+	}
+	original, _ := tx.Serialize()
+
+	verifies := func(bumped *Tx) {
+		t.Helper()
+		for i, txIn := range bumped.TxIns {
+			prevout, _ := lookup(txIn)
+			if err := bumped.VerifyInputWith(uint32(i), prevout.ScriptPubkey, script.StandardVerifyFlags); err != nil {
+				t.Errorf("Input %d of the replacement does not verify: %v", i, err)
+			}
+			if txIn.Sequence > MaxReplaceableSequence {
+				t.Errorf("Input %d of the replacement does not signal replacement", i)
+			}
+		}
+		if bumped.TxOuts[0].Amount != 10000 {
+			t.Errorf("Replacement changed the payment to %d", bumped.TxOuts[0].Amount)
+		}
+	}
+
+	// the change covers 10 sat/vB
+	bumped, err := tx.BumpFee(1, 10, wallet, KeyMap{0: alice}, lookup)
+	if err != nil {
+		t.Fatalf("BumpFee error: %v", err)
+	}
+	verifies(bumped)
+	vsize, _ := bumped.VSize()
+	if fee := 20000 - 10000 - bumped.TxOuts[1].Amount; len(bumped.TxIns) != 1 || fee < uint64(10*vsize) || fee > uint64(10*(vsize+2)) {
+		t.Errorf("Replacement has %d inputs and pays %d for %d vbytes", len(bumped.TxIns), fee, vsize)
+	}
+	if after, _ := tx.Serialize(); string(after) != string(original) {
+		t.Errorf("BumpFee changed the original transaction")
+	}
+
+	// 50 sat/vB needs the second coin of the wallet
+	bumped, err = tx.BumpFee(1, 50, wallet, KeyMap{0: alice, 1: alice}, lookup)
+	if err != nil {
+		t.Fatalf("BumpFee with an added input error: %v", err)
+	}
+	if len(bumped.TxIns) != 2 || bumped.TxIns[1].PrevOut != wallet[1].OutPoint {
+		t.Fatalf("Replacement should spend the second coin as well, has %d inputs", len(bumped.TxIns))
+	}
+	verifies(bumped)
+	vsize, _ = bumped.VSize()
+	if fee := 50000 - 10000 - bumped.TxOuts[1].Amount; fee < uint64(50*vsize) || bumped.TxOuts[1].Amount < DustThreshold(aliceScript) {
+		t.Errorf("Replacement pays %d for %d vbytes with change %d", fee, vsize, bumped.TxOuts[1].Amount)
+	}
+
+	if _, err := tx.BumpFee(1, 50, wallet[:1], KeyMap{0: alice}, lookup); err == nil {
+		t.Errorf("Bump without coins to add should fail when the change does not cover it")
+	}
+	if _, err := tx.BumpFee(1, 50, wallet, KeyMap{0: alice}, lookup); err == nil {
+		t.Errorf("Bump should fail when the added input can not be signed")
+	}
+	final := tx.Copy()
+	final.TxIns[0].Sequence = SequenceFinal
+	if _, err := final.BumpFee(1, 10, wallet, KeyMap{0: alice}, lookup); err == nil {
+		t.Errorf("Transaction that does not signal replacement should not be bumped")
+	}
+}
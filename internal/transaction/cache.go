@@ -0,0 +1,206 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// The binary cache file starts with this magic followed by a one byte format version.
+// Every entry is: network tag (1 byte), txid (32 bytes), varint length and the serialized transaction including witness data.
+// Files without the magic are read as the old JSON map of txid to hex.
+const (
+	txCacheMagic   = "TXC"
+	txCacheVersion = byte(1)
+)
+
+// cacheNetwork tags a cached transaction with the network it was fetched from
+type cacheNetwork byte
+
+const (
+	// entries migrated from the old JSON cache don't know their network
+	networkUnknown cacheNetwork = iota
+	networkMainnet
+	networkTestnet
+)
+
+func networkOf(testnet bool) cacheNetwork {
+	if testnet {
+		return networkTestnet
+	}
+	return networkMainnet
+}
+
+// matches returns whether a cached entry can serve a request for the given network
+func (n cacheNetwork) matches(testnet bool) bool {
+	return n == networkUnknown || n == networkOf(testnet)
+}
+
+func (tf *TxFetcher) LoadCache(filename string) error {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if bytes.HasPrefix(raw, []byte(txCacheMagic)) {
+		return tf.loadBinaryCache(raw[len(txCacheMagic):])
+	}
+
+	return tf.loadJSONCache(raw)
+}
+
+func (tf *TxFetcher) loadBinaryCache(raw []byte) error {
+	reader := bufio.NewReader(bytes.NewReader(raw))
+
+	version, err := reader.ReadByte()
+	if err != nil {
+		return fmt.Errorf("tx cache has no version: %v", err)
+	}
+	if version != txCacheVersion {
+		return fmt.Errorf("unsupported tx cache version %d", version)
+	}
+
+	numEntries, err := utils.ReadVarint(reader)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < int(numEntries); i++ {
+		tag, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		network := cacheNetwork(tag)
+		if network > networkTestnet {
+			return fmt.Errorf("unknown network tag %d in tx cache", tag)
+		}
+
+		txID := make([]byte, 32)
+		if _, err := io.ReadFull(reader, txID); err != nil {
+			return err
+		}
+
+		length, err := utils.ReadVarint(reader)
+		if err != nil {
+			return err
+		}
+		serializedTx := make([]byte, length)
+		if _, err := io.ReadFull(reader, serializedTx); err != nil {
+			return err
+		}
+
+		if err := tf.addToCache(hex.EncodeToString(txID), serializedTx, network); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tf *TxFetcher) loadJSONCache(raw []byte) error {
+	diskCache := make(map[string]string)
+	if err := json.Unmarshal(raw, &diskCache); err != nil {
+		return err
+	}
+
+	for txID, rawHex := range diskCache {
+		serializedTx, err := hex.DecodeString(rawHex)
+		if err != nil {
+			return err
+		}
+
+		if err := tf.addToCache(txID, serializedTx, networkUnknown); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addToCache parses a serialized transaction and stores it after checking it matches its txid
+func (tf *TxFetcher) addToCache(txID string, serializedTx []byte, network cacheNetwork) error {
+	tx, err := ParseTxStrict(serializedTx, network == networkTestnet)
+	if err != nil {
+		return fmt.Errorf("cached tx %s: %v", txID, err)
+	}
+
+	id, err := tx.Id()
+	if err != nil {
+		return err
+	}
+	if id != txID {
+		return fmt.Errorf("cached tx %s hashes to %s", txID, id)
+	}
+
+	tf.mu.Lock()
+	tf.Cache[txID] = tx
+	tf.networks[txID] = network
+	tf.mu.Unlock()
+	return nil
+}
+
+// Add stores a copy of tx in the cache under its txid and for its network, so Fetch serves it without a request
+func (tf *TxFetcher) Add(tx *Tx) error {
+	id, err := tx.Id()
+	if err != nil {
+		return err
+	}
+	tf.mu.Lock()
+	tf.Cache[id] = tx.Copy()
+	tf.networks[id] = networkOf(tx.Testnet)
+	tf.mu.Unlock()
+	return nil
+}
+
+// DumpCache writes the cache in the binary format, keeping the network and witness data of every transaction
+func (tf *TxFetcher) DumpCache(filename string) error {
+	tf.mu.RLock()
+	defer tf.mu.RUnlock()
+
+	result := append([]byte(txCacheMagic), txCacheVersion)
+
+	numEntries, err := utils.EncodeVarint(uint64(len(tf.Cache)))
+	if err != nil {
+		return err
+	}
+	result = append(result, numEntries...)
+
+	// sort the entries so the same cache always produces the same file
+	txIDs := make([]string, 0, len(tf.Cache))
+	for txID := range tf.Cache {
+		txIDs = append(txIDs, txID)
+	}
+	sort.Strings(txIDs)
+
+	for _, txID := range txIDs {
+		tx := tf.Cache[txID]
+		txIDBytes, err := hex.DecodeString(txID)
+		if err != nil || len(txIDBytes) != 32 {
+			return fmt.Errorf("invalid txid in cache: %q", txID)
+		}
+
+		serializedTx, err := tx.Serialize()
+		if err != nil {
+			return err
+		}
+
+		length, err := utils.EncodeVarint(uint64(len(serializedTx)))
+		if err != nil {
+			return err
+		}
+
+		result = append(result, byte(tf.networks[txID]))
+		result = append(result, txIDBytes...)
+		result = append(result, length...)
+		result = append(result, serializedTx...)
+	}
+
+	return os.WriteFile(filename, result, 0644)
+}
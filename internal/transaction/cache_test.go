@@ -0,0 +1,167 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readJSONCache(t *testing.T) map[string]string {
+	t.Helper()
+	raw, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatalf("Failed to read cache file: %v", err)
+	}
+	diskCache := make(map[string]string)
+	if err := json.Unmarshal(raw, &diskCache); err != nil {
+		t.Fatalf("Failed to decode cache file: %v", err)
+	}
+	return diskCache
+}
+
+func TestLoadJSONCache(t *testing.T) {
+	fetcher := NewTxFetcher()
+	if err := fetcher.LoadCache(cacheFile); err != nil {
+		t.Fatalf("LoadCache error: %v", err)
+	}
+
+	diskCache := readJSONCache(t)
+	if len(fetcher.Cache) != len(diskCache) {
+		t.Fatalf("Expected %d cached transactions, got %d", len(diskCache), len(fetcher.Cache))
+	}
+
+	// Every transaction, including the segwit ones, serializes back to exactly what was cached
+	for txID, rawHex := range diskCache {
+		serializedTx, err := fetcher.Cache[txID].Serialize()
+		if err != nil {
+			t.Fatalf("Failed to serialize %s: %v", txID, err)
+		}
+		if hex.EncodeToString(serializedTx) != rawHex {
+			t.Errorf("Serialization of %s does not round trip", txID)
+		}
+		if fetcher.networks[txID] != networkUnknown {
+			t.Errorf("Migrated entry %s should have an unknown network", txID)
+		}
+	}
+
+	segwitTx := fetcher.Cache["78457666f82c28aa37b74b506745a7c7684dc7842a52a457b09f09446721e11c"]
+	if !segwitTx.HasWitness() {
+		t.Errorf("Expected witness data on segwit transaction")
+	}
+}
+
+func TestDumpAndLoadBinaryCache(t *testing.T) {
+	fetcher := NewTxFetcher()
+	if err := fetcher.LoadCache(cacheFile); err != nil {
+		t.Fatalf("LoadCache error: %v", err)
+	}
+
+	// Pretend one of the transactions was fetched from testnet
+	testnetID := "0d6fe5213c0b3291f208cba8bfb59b7476dffacc4e5cb66f6eb20a080843a299"
+	fetcher.networks[testnetID] = networkTestnet
+
+	binaryCacheFile := filepath.Join(t.TempDir(), "tx.cache")
+	if err := fetcher.DumpCache(binaryCacheFile); err != nil {
+		t.Fatalf("DumpCache error: %v", err)
+	}
+
+	raw, err := os.ReadFile(binaryCacheFile)
+	if err != nil {
+		t.Fatalf("Failed to read dumped cache: %v", err)
+	}
+	if !bytes.HasPrefix(raw, append([]byte(txCacheMagic), txCacheVersion)) {
+		t.Fatalf("Dumped cache does not start with the magic and version: %x", raw[:4])
+	}
+
+	reloaded := NewTxFetcher()
+	if err := reloaded.LoadCache(binaryCacheFile); err != nil {
+		t.Fatalf("LoadCache of binary cache error: %v", err)
+	}
+
+	if len(reloaded.Cache) != len(fetcher.Cache) {
+		t.Fatalf("Expected %d cached transactions, got %d", len(fetcher.Cache), len(reloaded.Cache))
+	}
+
+	for txID, tx := range fetcher.Cache {
+		want, _ := tx.Serialize()
+		have, _ := reloaded.Cache[txID].Serialize()
+		if !bytes.Equal(have, want) {
+			t.Errorf("Transaction %s changed after a binary round trip", txID)
+		}
+		if reloaded.networks[txID] != fetcher.networks[txID] {
+			t.Errorf("Network of %s changed after a binary round trip", txID)
+		}
+	}
+
+	if !reloaded.Cache[testnetID].Testnet {
+		t.Errorf("Transaction tagged as testnet should be loaded as testnet")
+	}
+
+	// Dumping is deterministic
+	secondCacheFile := filepath.Join(t.TempDir(), "tx.cache")
+	if err := reloaded.DumpCache(secondCacheFile); err != nil {
+		t.Fatalf("DumpCache error: %v", err)
+	}
+	second, _ := os.ReadFile(secondCacheFile)
+	if !bytes.Equal(raw, second) {
+		t.Errorf("Dumping the same cache twice produced different files")
+	}
+}
+
+func TestLoadCacheRejectsUnknownVersion(t *testing.T) {
+	badCacheFile := filepath.Join(t.TempDir(), "tx.cache")
+	if err := os.WriteFile(badCacheFile, append([]byte(txCacheMagic), 99, 0), 0644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+	if err := NewTxFetcher().LoadCache(badCacheFile); err == nil {
+		t.Errorf("Loading a cache with an unknown version should fail")
+	}
+}
+
+func TestCacheNetworkMatches(t *testing.T) {
+	tests := []struct {
+		network cacheNetwork
+		testnet bool
+		want    bool
+	}{
+		{networkUnknown, true, true},
+		{networkUnknown, false, true},
+		{networkMainnet, false, true},
+		{networkMainnet, true, false},
+		{networkTestnet, true, true},
+		{networkTestnet, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.network.matches(tt.testnet); got != tt.want {
+			t.Errorf("cacheNetwork(%d).matches(%v) = %v, want %v", tt.network, tt.testnet, got, tt.want)
+		}
+	}
+}
+
+func TestAddToCache(t *testing.T) {
+	rawTx, _ := hex.DecodeString("0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600")
+	tx, err := ParseTxStrict(rawTx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int32
+	fetcher := newFakeTxFetcher(&requests)
+	if err := fetcher.Add(tx); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	tx.Locktime = 0
+
+	const txID = "452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03"
+	fetched, err := fetcher.Fetch(txID, true, false)
+	if err != nil || fetched.Locktime != 410393 {
+		t.Fatalf("Fetch returned %v, %v; want the transaction as it was added", fetched, err)
+	}
+	if requests != 0 {
+		t.Errorf("Fetch made %d requests for an added transaction", requests)
+	}
+}
@@ -0,0 +1,114 @@
+package transaction
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// inputFee returns the fee at feeRate of the signed input spending coin, false when its size can not be estimated
+func inputFee(coin Coin, feeRate float64) (uint64, bool) {
+	weight, _, ok := satisfactionWeight(coin.TxOut.ScriptPubkey)
+	if !ok {
+		return 0, false
+	}
+	// the outpoint, script length and sequence of the input
+	weight += (32 + 4 + 1 + 4) * WitnessScaleFactor
+	vsize := (weight + WitnessScaleFactor - 1) / WitnessScaleFactor
+	return uint64(math.Ceil(feeRate * float64(vsize))), true
+}
+
+// EffectiveValue returns what coin contributes to a transaction at feeRate: its amount minus the fee of the input
+// that spends it. It is false for coins of which the size of the signed input can not be estimated.
+func EffectiveValue(coin Coin, feeRate float64) (int64, bool) {
+	fee, ok := inputFee(coin, feeRate)
+	if !ok {
+		return 0, false
+	}
+	return int64(coin.TxOut.Amount) - int64(fee), true
+}
+
+// CoinSelection is a set of coins picked by SelectCoins
+type CoinSelection struct {
+	Coins []Coin
+	// EffectiveValue is the sum of the effective values of the coins, Fee the fees of their inputs
+	EffectiveValue uint64
+	Fee            uint64
+	// Change is whether the excess over the target is worth a change output, otherwise it goes to the fee
+	Change bool
+	// Waste is the fee paid now for the inputs above what they would cost at the long term fee rate, plus the
+	// cost of the change output or, without change, the excess. Lower is better.
+	Waste int64
+}
+
+// SelectCoins picks coins with an effective value of at least target at feeRate. target is what the inputs have
+// to pay for: the outputs and the fee of the transaction without its inputs. Coins with a negative effective value,
+// or of which the size can not be estimated, are never picked. longTermFeeRate is the fee rate the coins are expected
+// to be spent at otherwise, and changeCost the fee of creating and later spending a change output; both only
+// affect the waste, of which the lowest of the candidate selections is returned.
+func SelectCoins(coins []Coin, target uint64, feeRate, longTermFeeRate float64, changeCost uint64) (*CoinSelection, error) {
+	type candidate struct {
+		coin         Coin
+		value        uint64
+		fee, longFee uint64
+	}
+	var usable []candidate
+	var available uint64
+	for _, coin := range coins {
+		value, ok := EffectiveValue(coin, feeRate)
+		if !ok || value <= 0 {
+			continue
+		}
+		fee, _ := inputFee(coin, feeRate)
+		longFee, _ := inputFee(coin, longTermFeeRate)
+		usable = append(usable, candidate{coin, uint64(value), fee, longFee})
+		available += uint64(value)
+	}
+	if available < target {
+		return nil, fmt.Errorf("coins with an effective value of %d satoshis at %.2f sat/vB do not reach %d", available, feeRate, target)
+	}
+	slices.SortStableFunc(usable, func(a, b candidate) int {
+		return cmp.Compare(b.value, a.value)
+	})
+
+	selection := func(picked []candidate) *CoinSelection {
+		s := &CoinSelection{}
+		for _, c := range picked {
+			s.Coins = append(s.Coins, c.coin)
+			s.EffectiveValue += c.value
+			s.Fee += c.fee
+			s.Waste += int64(c.fee) - int64(c.longFee)
+		}
+		excess := s.EffectiveValue - target
+		s.Change = excess > changeCost
+		if s.Change {
+			s.Waste += int64(changeCost)
+		} else {
+			s.Waste += int64(excess)
+		}
+		return s
+	}
+
+	// the largest coins first, until they reach the target
+	var largestFirst []candidate
+	var sum uint64
+	for _, c := range usable {
+		largestFirst = append(largestFirst, c)
+		if sum += c.value; sum >= target {
+			break
+		}
+	}
+	best := selection(largestFirst)
+
+	// a single coin that reaches the target without much excess can beat that
+	for _, c := range usable {
+		if c.value < target {
+			break
+		}
+		if single := selection([]candidate{c}); single.Waste < best.Waste {
+			best = single
+		}
+	}
+	return best, nil
+}
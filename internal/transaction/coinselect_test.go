@@ -0,0 +1,72 @@
+package transaction
+
+import (
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+func TestEffectiveValue(t *testing.T) {
+	p2pkh := script.CreateP2pkhScript(make([]byte, 20))
+	p2wpkh := script.CreateP2WPKHScript(make([]byte, 20))
+
+	// 149 and 69 vbytes at 10 sat/vB
+	if value, ok := EffectiveValue(testCoin(0, 20000, p2pkh), 10); !ok || value != 18510 {
+		t.Errorf("EffectiveValue of p2pkh = %d, %v, want 18510", value, ok)
+	}
+	if value, ok := EffectiveValue(testCoin(0, 20000, p2wpkh), 10); !ok || value != 19310 {
+		t.Errorf("EffectiveValue of p2wpkh = %d, %v, want 19310", value, ok)
+	}
+	if value, ok := EffectiveValue(testCoin(0, 1000, p2pkh), 10); !ok || value >= 0 {
+		t.Errorf("EffectiveValue of a dust coin = %d, %v, want a negative value", value, ok)
+	}
+	if _, ok := EffectiveValue(testCoin(0, 20000, script.CreateP2SHScript(make([]byte, 20))), 10); ok {
+		t.Errorf("EffectiveValue of a p2sh coin without redeem script should be unknown")
+	}
+}
+
+func TestSelectCoins(t *testing.T) {
+	p2pkh := script.CreateP2pkhScript(make([]byte, 20))
+	p2wpkh := script.CreateP2WPKHScript(make([]byte, 20))
+	coins := []Coin{
+		testCoin(0, 1000, p2pkh),
+		testCoin(1, 20000, p2wpkh),
+		testCoin(2, 50000, p2wpkh),
+		testCoin(3, 12000, p2wpkh),
+		testCoin(4, 100000, script.CreateP2SHScript(make([]byte, 20))),
+	}
+
+	// only the largest coin reaches the target, with change
+	selection, err := SelectCoins(coins, 30000, 10, 5, 1000)
+	if err != nil {
+		t.Fatalf("SelectCoins error: %v", err)
+	}
+	if len(selection.Coins) != 1 || selection.Coins[0].TxOut.Amount != 50000 || !selection.Change {
+		t.Errorf("Selection of 30000 = %+v", selection)
+	}
+	// 690 - 345 for the input and 1000 for the change
+	if selection.Waste != 1345 || selection.Fee != 690 || selection.EffectiveValue != 49310 {
+		t.Errorf("Selection of 30000 has waste %d, fee %d and effective value %d", selection.Waste, selection.Fee, selection.EffectiveValue)
+	}
+
+	// the 20000 coin leaves an excess smaller than a change output would cost
+	selection, err = SelectCoins(coins, 19000, 10, 5, 1000)
+	if err != nil {
+		t.Fatalf("SelectCoins error: %v", err)
+	}
+	if len(selection.Coins) != 1 || selection.Coins[0].TxOut.Amount != 20000 || selection.Change || selection.Waste != 655 {
+		t.Errorf("Selection of 19000 = %+v", selection)
+	}
+
+	// the dust coin and the p2sh coin are never picked
+	selection, err = SelectCoins(coins, 75000, 10, 5, 1000)
+	if err != nil {
+		t.Fatalf("SelectCoins error: %v", err)
+	}
+	if len(selection.Coins) != 3 {
+		t.Errorf("Selection of 75000 has %d coins, want 3", len(selection.Coins))
+	}
+	if _, err := SelectCoins(coins, 80000, 10, 5, 1000); err == nil {
+		t.Errorf("Effective value of 79930 should not reach 80000")
+	}
+}
@@ -0,0 +1,108 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+// ConsistencyError lists the fields that differ after a serialization round trip
+type ConsistencyError struct {
+	Mismatches []string
+}
+
+func (e *ConsistencyError) Error() string {
+	return fmt.Sprintf("transaction does not survive a serialization round trip: %s", strings.Join(e.Mismatches, "; "))
+}
+
+// ConsistencyCheck parses the serialization of tx again and compares the result field by field,
+// so transactions that would not serialize to the bytes that are hashed and relayed are caught.
+// It returns a *ConsistencyError describing every mismatch.
+func (tx *Tx) ConsistencyCheck() error {
+	raw, err := tx.Serialize()
+	if err != nil {
+		return err
+	}
+
+	parsed, err := ParseTx(bufio.NewReader(bytes.NewReader(raw)), tx.Testnet)
+	if err != nil {
+		return &ConsistencyError{Mismatches: []string{fmt.Sprintf("serialization does not parse: %v", err)}}
+	}
+
+	var mismatches []string
+	mismatch := func(format string, args ...any) {
+		mismatches = append(mismatches, fmt.Sprintf(format, args...))
+	}
+
+	if parsed.Version != tx.Version {
+		mismatch("version %d parsed as %d", tx.Version, parsed.Version)
+	}
+	if parsed.Locktime != tx.Locktime {
+		mismatch("locktime %d parsed as %d", tx.Locktime, parsed.Locktime)
+	}
+
+	if len(parsed.TxIns) != len(tx.TxIns) {
+		mismatch("%d inputs parsed as %d", len(tx.TxIns), len(parsed.TxIns))
+	}
+	for i := 0; i < min(len(tx.TxIns), len(parsed.TxIns)); i++ {
+		want, got := tx.TxIns[i], parsed.TxIns[i]
+		if want.PrevOut != got.PrevOut {
+			mismatch("input %d outpoint %s parsed as %s", i, want.PrevOut.DisplayString(), got.PrevOut.DisplayString())
+		}
+		if wantScript, gotScript := scriptBytes(want.ScriptSig), scriptBytes(got.ScriptSig); !bytes.Equal(wantScript, gotScript) {
+			mismatch("input %d script sig %x parsed as %x", i, wantScript, gotScript)
+		}
+		if want.Sequence != got.Sequence {
+			mismatch("input %d sequence %d parsed as %d", i, want.Sequence, got.Sequence)
+		}
+		if !equalWitness(want.Witness, got.Witness) {
+			mismatch("input %d witness of %d items parsed as %d items", i, len(want.Witness), len(got.Witness))
+		}
+	}
+
+	if len(parsed.TxOuts) != len(tx.TxOuts) {
+		mismatch("%d outputs parsed as %d", len(tx.TxOuts), len(parsed.TxOuts))
+	}
+	for i := 0; i < min(len(tx.TxOuts), len(parsed.TxOuts)); i++ {
+		want, got := tx.TxOuts[i], parsed.TxOuts[i]
+		if want.Amount != got.Amount {
+			mismatch("output %d amount %d parsed as %d", i, want.Amount, got.Amount)
+		}
+		if wantScript, gotScript := scriptBytes(want.ScriptPubkey), scriptBytes(got.ScriptPubkey); !bytes.Equal(wantScript, gotScript) {
+			mismatch("output %d script pubkey %x parsed as %x", i, wantScript, gotScript)
+		}
+	}
+
+	// the fields can match while the commands of a script are split up differently
+	if reserialized, err := parsed.Serialize(); err != nil || !bytes.Equal(reserialized, raw) {
+		mismatch("parsed transaction serializes differently")
+	}
+
+	if len(mismatches) > 0 {
+		return &ConsistencyError{Mismatches: mismatches}
+	}
+	return nil
+}
+
+func scriptBytes(s *script.Script) []byte {
+	raw, err := s.RawSerialize()
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+func equalWitness(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
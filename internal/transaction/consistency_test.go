@@ -0,0 +1,42 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+func TestConsistencyCheck(t *testing.T) {
+	rawTx, _ := hex.DecodeString("0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600")
+	tx, err := ParseTx(bufio.NewReader(bytes.NewReader(rawTx)), false)
+	if err != nil {
+		t.Fatalf("ParseTx error: %v", err)
+	}
+	if err := tx.ConsistencyCheck(); err != nil {
+		t.Errorf("Parsed transaction should be consistent: %v", err)
+	}
+
+	tx.TxIns[0].Witness = [][]byte{{0x01, 0x02}, {}}
+	if err := tx.ConsistencyCheck(); err != nil {
+		t.Errorf("Transaction with a witness should be consistent: %v", err)
+	}
+}
+
+func TestConsistencyCheckMismatch(t *testing.T) {
+	// without inputs the input count 0x00 and output count 0x01 look like the segwit marker and flag
+	tx := NewTx(1, nil, []*TxOut{NewTxOut(1000, script.CreateP2pkhScript(make([]byte, 20)))}, 0, false)
+
+	err := tx.ConsistencyCheck()
+	var consistencyErr *ConsistencyError
+	if !errors.As(err, &consistencyErr) {
+		t.Fatalf("Expected a ConsistencyError, got %v", err)
+	}
+	if len(consistencyErr.Mismatches) == 0 || !strings.Contains(err.Error(), "round trip") {
+		t.Errorf("Expected the mismatches to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors of parsing, signing and verifying transactions wrap one of these, so callers can tell failures apart
+// with errors.Is
+var (
+	// ErrMalformedTx is wrapped by the errors of transactions that do not parse
+	ErrMalformedTx = errors.New("malformed transaction")
+	// ErrInputNotFound is wrapped when an input index is beyond the inputs of the transaction
+	ErrInputNotFound = errors.New("input does not exist")
+	// ErrOutputNotFound is wrapped when an input spends an output its transaction does not have
+	ErrOutputNotFound = errors.New("output does not exist")
+)
+
+// InputError is the error of an input that can not be signed or does not verify. errors.As with an *InputError
+// gives the index of the input, errors.Is matches the error it wraps, such as script.ErrEvalFalse.
+type InputError struct {
+	Index int
+	Err   error
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("input %d: %v", e.Index, e.Err)
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// inputNotFound returns the error of a reference to input index, which tx does not have
+func inputNotFound(index uint32) error {
+	return &InputError{Index: int(index), Err: ErrInputNotFound}
+}
+
+// outputNotFound returns the error of an input that spends output index of a transaction without it
+func outputNotFound(index uint32) error {
+	return fmt.Errorf("%w: previous index %d out of range for transaction outputs", ErrOutputNotFound, index)
+}
+
+// malformedTx wraps err, the error of reading a transaction, with ErrMalformedTx
+func malformedTx(err error) error {
+	return fmt.Errorf("%w: %w", ErrMalformedTx, err)
+}
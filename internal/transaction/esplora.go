@@ -0,0 +1,219 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/caspereijkens/cryptocurrency/internal/merkle"
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+var (
+	// ErrTxNotFound is matched by the errors of requests for a transaction the API does not know
+	ErrTxNotFound = errors.New("transaction not found")
+	// ErrRateLimited is matched by the errors of requests the API refused because of its rate limit,
+	// errors.As with a *utils.HTTPError gives the time to wait
+	ErrRateLimited = utils.ErrRateLimited
+)
+
+// TxStatus is the confirmation status of a transaction as reported by the Esplora API
+type TxStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight uint32 `json:"block_height"`
+	BlockHash   string `json:"block_hash"`
+	BlockTime   uint32 `json:"block_time"`
+}
+
+// Outspend tells whether an output has been spent and by which transaction input
+type Outspend struct {
+	Spent  bool     `json:"spent"`
+	TxID   string   `json:"txid"`
+	Vin    uint32   `json:"vin"`
+	Status TxStatus `json:"status"`
+}
+
+// UTXO is an unspent output of an address
+type UTXO struct {
+	TxID   string   `json:"txid"`
+	Vout   uint32   `json:"vout"`
+	Value  uint64   `json:"value"`
+	Status TxStatus `json:"status"`
+}
+
+// AddressTx is a transaction in the history of an address, with the outputs its inputs spend
+type AddressTx struct {
+	TxID   string         `json:"txid"`
+	Vin    []AddressTxIn  `json:"vin"`
+	Vout   []AddressTxOut `json:"vout"`
+	Status TxStatus       `json:"status"`
+}
+
+// AddressTxIn is an input of an AddressTx
+type AddressTxIn struct {
+	TxID string `json:"txid"`
+	Vout uint32 `json:"vout"`
+	// Prevout is the output the input spends, nil for a coinbase input
+	Prevout *AddressTxOut `json:"prevout"`
+}
+
+// AddressTxOut is an output of an AddressTx, Address is empty for nonstandard outputs
+type AddressTxOut struct {
+	ScriptPubkey string `json:"scriptpubkey"`
+	Address      string `json:"scriptpubkey_address"`
+	Value        uint64 `json:"value"`
+}
+
+// Received returns what the transaction adds to the balance of address: its outputs to the
+// address minus the outputs of the address its inputs spend
+func (tx *AddressTx) Received(address string) int64 {
+	received := int64(0)
+	for _, txOut := range tx.Vout {
+		if txOut.Address == address {
+			received += int64(txOut.Value)
+		}
+	}
+	for _, txIn := range tx.Vin {
+		if txIn.Prevout != nil && txIn.Prevout.Address == address {
+			received -= int64(txIn.Prevout.Value)
+		}
+	}
+	return received
+}
+
+// FetchStatus returns whether and where the transaction is confirmed
+func (tf *TxFetcher) FetchStatus(txID string, testnet bool) (*TxStatus, error) {
+	status := &TxStatus{}
+	if err := tf.getJSON(fmt.Sprintf("%s/tx/%s/status", tf.GetURL(testnet), txID), status); err != nil {
+		return nil, txError(txID, testnet, err)
+	}
+	return status, nil
+}
+
+// Confirmations returns the number of blocks from the block confirming the transaction up to and including the
+// tip at tipHeight, 0 for a transaction that is not confirmed
+func (s *TxStatus) Confirmations(tipHeight uint32) uint32 {
+	if !s.Confirmed || tipHeight < s.BlockHeight {
+		return 0
+	}
+	return tipHeight - s.BlockHeight + 1
+}
+
+// FetchTipHeight returns the height of the tip of the best chain the API knows
+func (tf *TxFetcher) FetchTipHeight(testnet bool) (uint32, error) {
+	url := fmt.Sprintf("%s/blocks/tip/height", tf.GetURL(testnet))
+	body, err := tf.get(url)
+	if err != nil {
+		return 0, err
+	}
+	height, err := strconv.ParseUint(strings.TrimSpace(string(body)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected response from %s: %v", url, err)
+	}
+	return uint32(height), nil
+}
+
+// MerkleProof proves that a transaction is in the block at BlockHeight
+type MerkleProof struct {
+	BlockHeight uint32
+	Proof       merkle.Proof
+}
+
+type esploraMerkleProof struct {
+	BlockHeight uint32   `json:"block_height"`
+	Merkle      []string `json:"merkle"`
+	Pos         int      `json:"pos"`
+}
+
+// FetchMerkleProof returns the proof that the confirmed transaction is in its block. Check it with Verify against
+// a header synced locally, such as with block.HeaderStore.VerifyMerkleProof, rather than trusting the API.
+func (tf *TxFetcher) FetchMerkleProof(txID string, testnet bool) (*MerkleProof, error) {
+	response := &esploraMerkleProof{}
+	if err := tf.getJSON(fmt.Sprintf("%s/tx/%s/merkle-proof", tf.GetURL(testnet), txID), response); err != nil {
+		return nil, txError(txID, testnet, err)
+	}
+	proof := &MerkleProof{BlockHeight: response.BlockHeight, Proof: merkle.Proof{Index: response.Pos}}
+	for _, hashHex := range response.Merkle {
+		hash, err := hex.DecodeString(hashHex)
+		if err != nil || len(hash) != 32 {
+			return nil, fmt.Errorf("merkle proof of transaction %s has an invalid hash %q", txID, hashHex)
+		}
+		// the API shows the hashes in display byte order
+		slices.Reverse(hash)
+		proof.Proof.Branch = append(proof.Proof.Branch, hash)
+	}
+	return proof, nil
+}
+
+// Verify checks that the proof shows the transaction with txID, in display byte order, is committed to by
+// merkleRoot, the merkle root of a block header in display byte order
+func (p *MerkleProof) Verify(txID string, merkleRoot []byte) error {
+	txid, err := utils.ParseUint256HexLE(txID)
+	if err != nil {
+		return fmt.Errorf("invalid txid %q: %v", txID, err)
+	}
+	root := slices.Clone(merkleRoot)
+	slices.Reverse(root)
+	if !p.Proof.Verify(txid[:], root) {
+		return fmt.Errorf("transaction %s is not in the block at height %d", txID, p.BlockHeight)
+	}
+	return nil
+}
+
+// FetchOutspend returns the spending status of output vout of the transaction
+func (tf *TxFetcher) FetchOutspend(txID string, vout uint32, testnet bool) (*Outspend, error) {
+	outspend := &Outspend{}
+	if err := tf.getJSON(fmt.Sprintf("%s/tx/%s/outspend/%d", tf.GetURL(testnet), txID, vout), outspend); err != nil {
+		return nil, txError(txID, testnet, err)
+	}
+	return outspend, nil
+}
+
+// FetchAddressUTXOs returns the unspent outputs paying to address, including unconfirmed ones
+func (tf *TxFetcher) FetchAddressUTXOs(address string, testnet bool) ([]*UTXO, error) {
+	var utxos []*UTXO
+	if err := tf.getJSON(fmt.Sprintf("%s/address/%s/utxo", tf.GetURL(testnet), address), &utxos); err != nil {
+		return nil, err
+	}
+	return utxos, nil
+}
+
+// FetchAddressTxs returns the most recent transactions of address: the unconfirmed ones
+// followed by the last 25 confirmed ones, newest first
+func (tf *TxFetcher) FetchAddressTxs(address string, testnet bool) ([]*AddressTx, error) {
+	var txs []*AddressTx
+	if err := tf.getJSON(fmt.Sprintf("%s/address/%s/txs", tf.GetURL(testnet), address), &txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+func (tf *TxFetcher) getJSON(url string, v interface{}) error {
+	body, err := tf.get(url)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("unexpected response from %s: %v", url, err)
+	}
+	return nil
+}
+
+// txError marks a not found response to a request for transaction txID with ErrTxNotFound
+// and names the network of the request
+func txError(txID string, testnet bool, err error) error {
+	network := params.ForNetwork(testnet).Name
+	if errors.Is(err, utils.ErrNotFound) {
+		return fmt.Errorf("%w: %s on %s: %w", ErrTxNotFound, txID, network, err)
+	}
+	return fmt.Errorf("transaction %s on %s: %w", txID, network, err)
+}
+
+func (tf *TxFetcher) get(url string) ([]byte, error) {
+	return utils.HTTPGet(tf.Client, tf.UserAgent, url)
+}
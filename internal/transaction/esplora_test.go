@@ -0,0 +1,148 @@
+package transaction
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/merkle"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+type roundTripFunc func(request *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestTxFetcherWithClient(t *testing.T) {
+	rawTx := "0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600"
+	txID := "452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03"
+
+	var requests []*http.Request
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		requests = append(requests, request)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(rawTx + "\n"))}, nil
+	})}
+
+	fetcher := NewTxFetcherWithClient(client, "wallet/2.0")
+	tx, err := fetcher.Fetch(txID, false, false)
+	if err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	if id, _ := tx.Id(); id != txID {
+		t.Errorf("Fetched %s, want %s", id, txID)
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(requests))
+	}
+	if got := requests[0].Header.Get("User-Agent"); got != "wallet/2.0" {
+		t.Errorf("User-Agent = %q, want wallet/2.0", got)
+	}
+	if got := requests[0].URL.String(); got != "https://blockstream.info/api/tx/"+txID+"/hex" {
+		t.Errorf("Unexpected URL %s", got)
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		if strings.Contains(request.URL.Path, "/tx/00") {
+			return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: io.NopCloser(strings.NewReader("Transaction not found"))}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Status:     "429 Too Many Requests",
+			Header:     http.Header{"Retry-After": []string{"5"}},
+			Body:       io.NopCloser(strings.NewReader("Too Many Requests")),
+		}, nil
+	})}
+	fetcher := NewTxFetcherWithClient(client, "")
+
+	missing := strings.Repeat("00", 32)
+	if _, err := fetcher.Fetch(missing, false, true); !errors.Is(err, ErrTxNotFound) || !strings.Contains(err.Error(), missing) {
+		t.Errorf("Fetch of a missing transaction returned %v", err)
+	}
+	if _, err := fetcher.FetchStatus(missing, false); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("FetchStatus of a missing transaction returned %v", err)
+	}
+
+	_, err := fetcher.Fetch(strings.Repeat("11", 32), false, true)
+	var httpErr *utils.HTTPError
+	if !errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTxNotFound) || !errors.As(err, &httpErr) || httpErr.RetryAfter != 5*time.Second {
+		t.Errorf("rate limited Fetch returned %v", err)
+	}
+
+	// address requests are not about a transaction
+	if _, err := fetcher.FetchAddressUTXOs("mzx5YhAH9kNHtcN481u6WkjeHjYtVeKVh2", true); errors.Is(err, ErrTxNotFound) || !errors.Is(err, ErrRateLimited) {
+		t.Errorf("rate limited FetchAddressUTXOs returned %v", err)
+	}
+}
+
+func TestFetchMerkleProof(t *testing.T) {
+	txID := "452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03"
+	sibling := strings.Repeat("ab", 32)
+	var path string
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		path = request.URL.Path
+		body := `{"block_height":700000,"merkle":["` + sibling + `"],"pos":1}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	proof, err := NewTxFetcherWithClient(client, "").FetchMerkleProof(txID, false)
+	if err != nil {
+		t.Fatalf("FetchMerkleProof error: %v", err)
+	}
+	if path != "/api/tx/"+txID+"/merkle-proof" {
+		t.Errorf("Requested %s", path)
+	}
+	if proof.BlockHeight != 700000 || proof.Proof.Index != 1 || len(proof.Proof.Branch) != 1 {
+		t.Fatalf("Unexpected proof %+v", proof)
+	}
+
+	// the transaction is the right leaf of a block with two transactions
+	leaf, _ := utils.ParseUint256HexLE(txID)
+	siblingLeaf, _ := utils.ParseUint256HexLE(sibling)
+	root := merkle.MerkleParent(siblingLeaf[:], leaf[:])
+	slices.Reverse(root)
+	if err := proof.Verify(txID, root); err != nil {
+		t.Errorf("Verify error: %v", err)
+	}
+	if err := proof.Verify(sibling, root); err == nil {
+		t.Errorf("Proof should not verify another transaction")
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		if request.URL.Path == "/testnet/api/blocks/tip/height" {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("2500000"))}, nil
+		}
+		body := `{"confirmed":true,"block_height":2499998,"block_hash":"00000000000000185cd4f26d0a3ef4085b2a6954a21d3e1e6e93bbd21e8b6e8d","block_time":1700000000}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+	fetcher := NewTxFetcherWithClient(client, "")
+
+	tip, err := fetcher.FetchTipHeight(true)
+	if err != nil || tip != 2500000 {
+		t.Fatalf("FetchTipHeight = %d, %v", tip, err)
+	}
+	status, err := fetcher.FetchStatus(strings.Repeat("11", 32), true)
+	if err != nil {
+		t.Fatalf("FetchStatus error: %v", err)
+	}
+	if got := status.Confirmations(tip); got != 3 {
+		t.Errorf("Confirmations = %d, want 3", got)
+	}
+	// a tip behind the block, such as a header store that is not synced yet
+	if got := status.Confirmations(2499990); got != 0 {
+		t.Errorf("Confirmations below the block = %d, want 0", got)
+	}
+	if got := (&TxStatus{}).Confirmations(tip); got != 0 {
+		t.Errorf("Confirmations of an unconfirmed transaction = %d, want 0", got)
+	}
+}
@@ -0,0 +1,112 @@
+package transaction
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MinRelayFeeRate is the lowest fee rate in satoshis per vbyte nodes relay at by default
+const MinRelayFeeRate = 1.0
+
+// projectedBlockVSize is the size available to transactions in a block, MaxBlockWeight divided by WitnessScaleFactor
+const projectedBlockVSize = 1000000
+
+// FeeBucket holds the mempool transactions paying at least FeeRate and less than the bucket before it
+type FeeBucket struct {
+	// FeeRate is in satoshis per vbyte
+	FeeRate float64
+	VSize   uint64
+	// Blocks is the number of blocks it takes until the bucket starts to confirm, when the mempool is mined
+	// by fee rate and nothing arrives. 1 is the next block.
+	Blocks int
+}
+
+// FeeHistogram is the mempool of an Esplora backend grouped by fee rate, highest fee rate first
+type FeeHistogram struct {
+	Count    int
+	VSize    uint64
+	TotalFee uint64
+	Buckets  []FeeBucket
+}
+
+// esploraMempool is the response of the mempool endpoint, its histogram pairs are fee rate and vsize
+type esploraMempool struct {
+	Count        int          `json:"count"`
+	VSize        uint64       `json:"vsize"`
+	TotalFee     uint64       `json:"total_fee"`
+	FeeHistogram [][2]float64 `json:"fee_histogram"`
+}
+
+// FetchFeeHistogram returns the mempool of the backend grouped by fee rate, with the block each bucket is projected in
+func (tf *TxFetcher) FetchFeeHistogram(testnet bool) (*FeeHistogram, error) {
+	var mempool esploraMempool
+	if err := tf.getJSON(fmt.Sprintf("%s/mempool", tf.GetURL(testnet)), &mempool); err != nil {
+		return nil, err
+	}
+	return newFeeHistogram(mempool), nil
+}
+
+func newFeeHistogram(mempool esploraMempool) *FeeHistogram {
+	h := &FeeHistogram{Count: mempool.Count, VSize: mempool.VSize, TotalFee: mempool.TotalFee}
+	var ahead uint64
+	for _, pair := range mempool.FeeHistogram {
+		vsize := uint64(pair[1])
+		h.Buckets = append(h.Buckets, FeeBucket{FeeRate: pair[0], VSize: vsize, Blocks: int(ahead/projectedBlockVSize) + 1})
+		ahead += vsize
+	}
+	return h
+}
+
+// FetchFeeEstimates returns the fee rates the backend estimates for confirmation within a number of blocks
+func (tf *TxFetcher) FetchFeeEstimates(testnet bool) (map[int]float64, error) {
+	var raw map[string]float64
+	url := fmt.Sprintf("%s/fee-estimates", tf.GetURL(testnet))
+	if err := tf.getJSON(url, &raw); err != nil {
+		return nil, err
+	}
+	estimates := make(map[int]float64, len(raw))
+	for target, feeRate := range raw {
+		blocks, err := strconv.Atoi(target)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected response from %s: target %q", url, target)
+		}
+		estimates[blocks] = feeRate
+	}
+	return estimates, nil
+}
+
+// EstimateFeeRate returns the fee rate a transaction needs to be in one of the next blocks: the rate of the bucket that
+// fills the last of them. When the mempool does not fill them MinRelayFeeRate is enough.
+func (h *FeeHistogram) EstimateFeeRate(blocks int) float64 {
+	if blocks < 1 {
+		blocks = 1
+	}
+	var ahead uint64
+	for _, bucket := range h.Buckets {
+		ahead += bucket.VSize
+		if ahead >= uint64(blocks)*projectedBlockVSize {
+			return max(bucket.FeeRate, MinRelayFeeRate)
+		}
+	}
+	return MinRelayFeeRate
+}
+
+// String draws the histogram with a bar per bucket, scaled to the largest one
+func (h *FeeHistogram) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d transactions, %d vbytes, %d satoshis in fees\n", h.Count, h.VSize, h.TotalFee)
+	var largest uint64
+	for _, bucket := range h.Buckets {
+		largest = max(largest, bucket.VSize)
+	}
+	const width = 40
+	for _, bucket := range h.Buckets {
+		bar := 0
+		if largest > 0 {
+			bar = int(bucket.VSize * width / largest)
+		}
+		fmt.Fprintf(&sb, "%8.1f sat/vB %10d vB  block %-3d %s\n", bucket.FeeRate, bucket.VSize, bucket.Blocks, strings.Repeat("#", bar))
+	}
+	return sb.String()
+}
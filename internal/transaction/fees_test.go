@@ -0,0 +1,64 @@
+package transaction
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFetchFeeHistogram(t *testing.T) {
+	var urls []string
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		urls = append(urls, request.URL.String())
+		body := `{"count":3000,"vsize":2600000,"total_fee":9000000,"fee_histogram":[[50.5,400000],[20,700000],[10,900000],[2,600000]]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+	fetcher := NewTxFetcherWithClient(client, "")
+
+	histogram, err := fetcher.FetchFeeHistogram(true)
+	if err != nil {
+		t.Fatalf("FetchFeeHistogram error: %v", err)
+	}
+	if len(urls) != 1 || urls[0] != "https://blockstream.info/testnet/api/mempool" {
+		t.Errorf("Unexpected requests %v", urls)
+	}
+	if histogram.Count != 3000 || histogram.VSize != 2600000 || histogram.TotalFee != 9000000 {
+		t.Errorf("Unexpected totals %+v", histogram)
+	}
+	want := []FeeBucket{{50.5, 400000, 1}, {20, 700000, 1}, {10, 900000, 2}, {2, 600000, 3}}
+	if len(histogram.Buckets) != len(want) {
+		t.Fatalf("Got %d buckets, want %d", len(histogram.Buckets), len(want))
+	}
+	for i, bucket := range histogram.Buckets {
+		if bucket != want[i] {
+			t.Errorf("Bucket %d = %+v, want %+v", i, bucket, want[i])
+		}
+	}
+
+	for blocks, want := range map[int]float64{0: 20, 1: 20, 2: 10, 3: MinRelayFeeRate, 10: MinRelayFeeRate} {
+		if got := histogram.EstimateFeeRate(blocks); got != want {
+			t.Errorf("EstimateFeeRate(%d) = %v, want %v", blocks, got, want)
+		}
+	}
+
+	if !strings.Contains(histogram.String(), "block 3") {
+		t.Errorf("String should show the projected block:\n%s", histogram)
+	}
+}
+
+func TestFetchFeeEstimates(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		body := `{"1":87.882,"6":20.5,"144":1.027}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+	fetcher := NewTxFetcherWithClient(client, "")
+
+	estimates, err := fetcher.FetchFeeEstimates(false)
+	if err != nil {
+		t.Fatalf("FetchFeeEstimates error: %v", err)
+	}
+	if len(estimates) != 3 || estimates[1] != 87.882 || estimates[6] != 20.5 || estimates[144] != 1.027 {
+		t.Errorf("Unexpected estimates %v", estimates)
+	}
+}
@@ -0,0 +1,212 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+const goldenFile = "./resources/golden.json"
+
+// go test -run TestGoldenCorpus -golden.add <txid>,<txid> fetches transactions and adds them to the corpus
+var (
+	goldenAdd     = flag.String("golden.add", "", "comma-separated txids to fetch and add to the golden corpus")
+	goldenTestnet = flag.Bool("golden.testnet", false, "fetch the transactions of -golden.add from testnet")
+)
+
+// goldenTx is a real transaction with what this package made of it when it was added to the corpus
+type goldenTx struct {
+	Txid        string `json:"txid"`
+	Wtxid       string `json:"wtxid"`
+	Description string `json:"description"`
+	Hex         string `json:"hex"`
+	Size        int    `json:"size"`
+	Weight      int    `json:"weight"`
+	Inputs      int    `json:"inputs"`
+	Outputs     int    `json:"outputs"`
+}
+
+func loadGoldenCorpus(t *testing.T) []goldenTx {
+	t.Helper()
+	raw, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("could not read the golden corpus: %v", err)
+	}
+	var corpus []goldenTx
+	if err := json.Unmarshal(raw, &corpus); err != nil {
+		t.Fatalf("golden corpus is not valid JSON: %v", err)
+	}
+	return corpus
+}
+
+// newGoldenTx records tx as a corpus entry
+func newGoldenTx(t *testing.T, tx *Tx) goldenTx {
+	t.Helper()
+	id, err := tx.Id()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := tx.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	weight, err := tx.Weight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the wtxid is the txid of the serialization with the witnesses (BIP141)
+	wtxid := utils.Hash256(raw)
+	slices.Reverse(wtxid)
+	return goldenTx{
+		Txid:        id,
+		Wtxid:       hex.EncodeToString(wtxid),
+		Description: describeTx(tx),
+		Hex:         hex.EncodeToString(raw),
+		Size:        len(raw),
+		Weight:      weight,
+		Inputs:      len(tx.TxIns),
+		Outputs:     len(tx.TxOuts),
+	}
+}
+
+// describeTx names the kinds of inputs and outputs of tx, such as "spends p2pkh, pays p2pkh and p2sh"
+func describeTx(tx *Tx) string {
+	var inputs, outputs []string
+	for _, txIn := range tx.TxIns {
+		if kind := inputKind(tx, txIn); !slices.Contains(inputs, kind) {
+			inputs = append(inputs, kind)
+		}
+	}
+	for _, txOut := range tx.TxOuts {
+		if kind := outputKind(txOut); !slices.Contains(outputs, kind) {
+			outputs = append(outputs, kind)
+		}
+	}
+	return "spends " + strings.Join(inputs, " and ") + ", pays " + strings.Join(outputs, " and ")
+}
+
+func inputKind(tx *Tx, txIn *TxIn) string {
+	cmds := *txIn.ScriptSig
+	switch {
+	case tx.IsCoinbase():
+		return "coinbase"
+	case len(cmds) == 0 && len(txIn.Witness) == 2:
+		return "p2wpkh"
+	case len(cmds) == 0 && len(txIn.Witness) == 1:
+		return "p2tr"
+	case len(cmds) == 0 && isControlBlock(txIn.Witness[len(txIn.Witness)-1]):
+		return "p2tr"
+	case len(cmds) == 0 && len(txIn.Witness) > 2:
+		return "p2wsh"
+	case len(cmds) == 1 && len(cmds[0]) == 22:
+		return "p2sh-p2wpkh"
+	case len(cmds) == 1 && len(cmds[0]) == 34:
+		return "p2sh-p2wsh"
+	case len(cmds) == 1:
+		return "p2pk"
+	case len(cmds) == 2 && (len(cmds[1]) == 33 || len(cmds[1]) == 65):
+		return "p2pkh"
+	case len(cmds) >= 2 && script.IsOp(cmds[0], 0x00) && isSignature(cmds[len(cmds)-1]):
+		// the dummy element and signatures, the redeem script of a p2sh multisig spend would come last
+		return "bare multisig"
+	}
+	return "p2sh"
+}
+
+// isControlBlock returns whether the last witness element of an input is the control block of a taproot script
+// path spend (BIP341)
+func isControlBlock(element []byte) bool {
+	return len(element) >= 33 && (len(element)-33)%32 == 0 && element[0]&0xfe == 0xc0
+}
+
+// isSignature returns whether element looks like a DER signature with a sighash byte
+func isSignature(element []byte) bool {
+	return len(element) >= 9 && len(element) <= 73 && element[0] == 0x30
+}
+
+func outputKind(txOut *TxOut) string {
+	scriptPubkey := txOut.ScriptPubkey
+	switch {
+	case scriptPubkey.IsP2PKHScriptPubKey():
+		return "p2pkh"
+	case scriptPubkey.IsP2SHScriptPubKey():
+		return "p2sh"
+	case scriptPubkey.IsP2WPKHScriptPubKey():
+		return "p2wpkh"
+	case scriptPubkey.IsP2WSHScriptPubKey():
+		return "p2wsh"
+	case scriptPubkey.IsP2TRScriptPubKey():
+		return "p2tr"
+	case isNullData(txOut):
+		return "op_return"
+	}
+	if scriptPubkey.IsMultisigScriptPubKey() {
+		return "bare multisig"
+	}
+	cmds := *scriptPubkey
+	if len(cmds) == 2 && (len(cmds[0]) == 33 || len(cmds[0]) == 65) && bytes.Equal(cmds[1], []byte{0xac}) {
+		return "p2pk"
+	}
+	return "nonstandard"
+}
+
+func TestGoldenCorpus(t *testing.T) {
+	corpus := loadGoldenCorpus(t)
+	if *goldenAdd != "" {
+		corpus = addGoldenTxs(t, corpus, strings.Split(*goldenAdd, ","), *goldenTestnet)
+	}
+
+	for _, golden := range corpus {
+		raw, err := hex.DecodeString(golden.Hex)
+		if err != nil {
+			t.Errorf("%s: hex does not decode: %v", golden.Txid, err)
+			continue
+		}
+		tx, err := ParseTxStrict(raw, false)
+		if err != nil {
+			t.Errorf("%s: %v", golden.Txid, err)
+			continue
+		}
+		serialized, err := tx.Serialize()
+		if err != nil || !bytes.Equal(serialized, raw) {
+			t.Errorf("%s: does not serialize to the bytes it was parsed from: %v", golden.Txid, err)
+		}
+		if got := newGoldenTx(t, tx); got != golden {
+			t.Errorf("%s parses as\n%+v\nwant\n%+v", golden.Txid, got, golden)
+		}
+	}
+}
+
+// addGoldenTxs fetches the transactions of txIDs and writes the corpus with them added, sorted by txid
+func addGoldenTxs(t *testing.T, corpus []goldenTx, txIDs []string, testnet bool) []goldenTx {
+	t.Helper()
+	fetcher := NewTxFetcher()
+	for _, txID := range txIDs {
+		if slices.ContainsFunc(corpus, func(golden goldenTx) bool { return golden.Txid == txID }) {
+			continue
+		}
+		tx, err := fetcher.Fetch(txID, testnet, true)
+		if err != nil {
+			t.Fatalf("could not fetch %s: %v", txID, err)
+		}
+		corpus = append(corpus, newGoldenTx(t, tx))
+	}
+	slices.SortFunc(corpus, func(a, b goldenTx) int { return strings.Compare(a.Txid, b.Txid) })
+
+	raw, err := json.MarshalIndent(corpus, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(goldenFile, append(raw, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return corpus
+}
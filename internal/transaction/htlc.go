@@ -0,0 +1,65 @@
+package transaction
+
+import (
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// SignHTLCRedeem spends the p2sh HTLC of script.CreateHTLCScript at inputIndex with the secret, signed by the receiver
+func (tx *Tx) SignHTLCRedeem(inputIndex uint32, privateKey *signatureverification.PrivateKey, secret []byte, htlcScript *script.Script) error {
+	// OP_1 selects the redeem branch
+	return tx.signHTLC(inputIndex, privateKey, htlcScript, secret, []byte{0x51})
+}
+
+// SignHTLCRefund spends the p2sh HTLC of script.CreateHTLCScript at inputIndex back to the sender.
+// The transaction locktime must be at or after the HTLC locktime, and the input must not be final.
+func (tx *Tx) SignHTLCRefund(inputIndex uint32, privateKey *signatureverification.PrivateKey, htlcScript *script.Script) error {
+	if int(inputIndex) >= len(tx.TxIns) {
+		return inputNotFound(inputIndex)
+	}
+	if tx.TxIns[inputIndex].Sequence == 0xffffffff {
+		return fmt.Errorf("input %d has a final sequence, which disables the locktime", inputIndex)
+	}
+
+	// OP_0 selects the refund branch
+	return tx.signHTLC(inputIndex, privateKey, htlcScript, []byte{0x00})
+}
+
+func (tx *Tx) signHTLC(inputIndex uint32, privateKey *signatureverification.PrivateKey, htlcScript *script.Script, branch ...[]byte) error {
+	if int(inputIndex) >= len(tx.TxIns) {
+		return inputNotFound(inputIndex)
+	}
+
+	rawHTLCScript, err := htlcScript.RawSerialize()
+	if err != nil {
+		return err
+	}
+
+	z, err := tx.SigHash(inputIndex, htlcScript)
+	if err != nil {
+		return err
+	}
+
+	derSig, err := privateKey.Sign(z)
+	if err != nil {
+		return err
+	}
+	sig := append(derSig.Serialize(), byte(SigHashAll))
+
+	scriptSig := append(script.Script{sig}, branch...)
+	scriptSig = append(scriptSig, rawHTLCScript)
+
+	previousScriptSig := tx.TxIns[inputIndex].ScriptSig
+	tx.TxIns[inputIndex].ScriptSig = &scriptSig
+
+	// the HTLC output is not fetched, its p2sh ScriptPubKey follows from the HTLC script
+	scriptPubkey := script.CreateP2SHScript(utils.Hash160(rawHTLCScript))
+	if err := tx.executeInput(inputIndex, scriptPubkey, htlcScript, z, script.StandardVerifyFlags, nil); err != nil {
+		tx.TxIns[inputIndex].ScriptSig = previousScriptSig
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package transaction
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func htlcSpend(locktime, sequence uint32) *Tx {
+	txIn := NewTxIn(bytes.Repeat([]byte{0xaa}, 32), 0, &script.Script{}, sequence)
+	txOut := NewTxOut(40000, script.CreateP2pkhScript(make([]byte, 20)))
+	return NewTx(1, []*TxIn{txIn}, []*TxOut{txOut}, locktime, true)
+}
+
+func TestHTLCRedeemAndRefund(t *testing.T) {
+	receiver, _ := signatureverification.NewPrivateKey(big.NewInt(7777))
+	sender, _ := signatureverification.NewPrivateKey(big.NewInt(8888))
+	secret := []byte("atomic swap secret of 32 bytes!!")
+
+	htlcScript, err := script.CreateHTLCScript(utils.Sha256Hash(secret), receiver.Point.Serialize(true), sender.Point.Serialize(true), 2500000)
+	if err != nil {
+		t.Fatalf("CreateHTLCScript error: %v", err)
+	}
+
+	// The receiver redeems with the secret at any time
+	redeem := htlcSpend(0, 0xffffffff)
+	if err := redeem.SignHTLCRedeem(0, receiver, secret, htlcScript); err != nil {
+		t.Errorf("SignHTLCRedeem error: %v", err)
+	}
+	if len(*redeem.TxIns[0].ScriptSig) != 4 {
+		t.Errorf("Redeem ScriptSig should have signature, secret, branch and HTLC script")
+	}
+
+	if err := htlcSpend(0, 0xffffffff).SignHTLCRedeem(0, receiver, []byte("not the secret"), htlcScript); err == nil {
+		t.Errorf("Redeeming with the wrong secret should fail")
+	}
+	if err := htlcSpend(0, 0xffffffff).SignHTLCRedeem(0, sender, secret, htlcScript); err == nil {
+		t.Errorf("Redeeming with the sender key should fail")
+	}
+
+	// The sender can only refund once the locktime has passed
+	refund := htlcSpend(2500001, 0xfffffffe)
+	if err := refund.SignHTLCRefund(0, sender, htlcScript); err != nil {
+		t.Errorf("SignHTLCRefund error: %v", err)
+	}
+
+	early := htlcSpend(2499999, 0xfffffffe)
+	if err := early.SignHTLCRefund(0, sender, htlcScript); err == nil {
+		t.Errorf("Refunding before the locktime should fail")
+	}
+	if len(*early.TxIns[0].ScriptSig) != 0 {
+		t.Errorf("A failed refund should leave the ScriptSig untouched")
+	}
+
+	if err := htlcSpend(2500001, 0xffffffff).SignHTLCRefund(0, sender, htlcScript); err == nil {
+		t.Errorf("Refunding with a final sequence should fail")
+	}
+
+	// an input the transaction does not have is an error, for both branches
+	var inputErr *InputError
+	if err := htlcSpend(2500001, 0xfffffffe).SignHTLCRefund(1, sender, htlcScript); !errors.As(err, &inputErr) {
+		t.Errorf("Refunding a missing input = %v, want an InputError", err)
+	}
+	if err := htlcSpend(0, 0xffffffff).SignHTLCRedeem(1, receiver, secret, htlcScript); !errors.As(err, &inputErr) {
+		t.Errorf("Redeeming a missing input = %v, want an InputError", err)
+	}
+}
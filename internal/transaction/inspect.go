@@ -0,0 +1,79 @@
+package transaction
+
+import (
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+// MaxMoney is the most satoshis there will ever be, no output or sum of outputs may exceed it
+const MaxMoney = 21_000_000 * 100_000_000
+
+// TotalOutput returns the sum of the amounts of the outputs of tx
+func (tx *Tx) TotalOutput() uint64 {
+	total := uint64(0)
+	for _, txOut := range tx.TxOuts {
+		total += txOut.Amount
+	}
+	return total
+}
+
+// OutputsToAddress returns the indexes of the outputs of tx that pay to address, on the network of tx
+func (tx *Tx) OutputsToAddress(address string) []int {
+	var indexes []int
+	for i, txOut := range tx.TxOuts {
+		if outputAddress, err := txOut.Address(tx.Testnet); err == nil && outputAddress == address {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// FindOutput returns the index of the first output of tx that match returns true for
+func (tx *Tx) FindOutput(match func(txOut *TxOut) bool) (int, bool) {
+	for i, txOut := range tx.TxOuts {
+		if match(txOut) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// FindInput returns the index of the first input of tx that match returns true for
+func (tx *Tx) FindInput(match func(txIn *TxIn) bool) (int, bool) {
+	for i, txIn := range tx.TxIns {
+		if match(txIn) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// AddInput appends txIn to the inputs of tx and returns its index. An input without a ScriptSig gets an empty
+// one, an input spending an output another input already spends is rejected.
+func (tx *Tx) AddInput(txIn *TxIn) (int, error) {
+	if txIn == nil {
+		return 0, fmt.Errorf("cannot add a nil input")
+	}
+	if i, found := tx.FindInput(func(other *TxIn) bool { return other.PrevOut == txIn.PrevOut }); found {
+		return 0, fmt.Errorf("input %d already spends %s", i, txIn.PrevOut.DisplayString())
+	}
+	if txIn.ScriptSig == nil {
+		txIn.ScriptSig = &script.Script{}
+	}
+	tx.TxIns = append(tx.TxIns, txIn)
+	return len(tx.TxIns) - 1, nil
+}
+
+// AddOutput appends txOut to the outputs of tx and returns its index. Outputs without a ScriptPubKey and outputs
+// that take the total of tx beyond MaxMoney are rejected.
+func (tx *Tx) AddOutput(txOut *TxOut) (int, error) {
+	if txOut == nil || txOut.ScriptPubkey == nil {
+		return 0, fmt.Errorf("cannot add an output without a ScriptPubKey")
+	}
+	if txOut.Amount > MaxMoney || tx.TotalOutput() > MaxMoney-txOut.Amount {
+		return 0, fmt.Errorf("output of %d sat takes the outputs beyond the maximum of %d sat", txOut.Amount, uint64(MaxMoney))
+	}
+	tx.TxOuts = append(tx.TxOuts, txOut)
+	return len(tx.TxOuts) - 1, nil
+}
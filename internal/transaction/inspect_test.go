@@ -0,0 +1,67 @@
+package transaction
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func TestTxOutputHelpers(t *testing.T) {
+	h160 := utils.Hash160([]byte("payee"))
+	address := utils.H160ToP2PKHAddress(h160, true)
+	tx := NewTx(2, nil, []*TxOut{
+		NewTxOut(1000, script.CreateP2pkhScript(h160)),
+		NewTxOut(2000, script.CreateP2WPKHScript(h160)),
+		NewTxOut(3000, script.CreateP2pkhScript(h160)),
+	}, 0, true)
+
+	if total := tx.TotalOutput(); total != 6000 {
+		t.Errorf("TotalOutput = %d, want 6000", total)
+	}
+	if indexes := tx.OutputsToAddress(address); !reflect.DeepEqual(indexes, []int{0, 2}) {
+		t.Errorf("OutputsToAddress = %v, want [0 2]", indexes)
+	}
+	if indexes := tx.OutputsToAddress(utils.H160ToP2PKHAddress(h160, false)); indexes != nil {
+		t.Errorf("OutputsToAddress of the mainnet address = %v, want none", indexes)
+	}
+
+	if i, ok := tx.FindOutput(func(txOut *TxOut) bool { return txOut.ScriptPubkey.IsP2WPKHScriptPubKey() }); !ok || i != 1 {
+		t.Errorf("FindOutput = %d, %v, want 1", i, ok)
+	}
+	if i, ok := tx.FindOutput(func(txOut *TxOut) bool { return txOut.Amount > 5000 }); ok || i != -1 {
+		t.Errorf("FindOutput = %d, %v, want no output", i, ok)
+	}
+}
+
+func TestTxAddInputAndOutput(t *testing.T) {
+	tx := NewTx(2, nil, nil, 0, false)
+
+	prevOut := NewOutPoint(utils.Hash256([]byte("funding")), 1)
+	if i, err := tx.AddInput(NewTxInFromOutPoint(prevOut, nil, SequenceFinal)); err != nil || i != 0 {
+		t.Fatalf("AddInput = %d, %v", i, err)
+	}
+	if tx.TxIns[0].ScriptSig == nil {
+		t.Errorf("AddInput should give an input without a ScriptSig an empty one")
+	}
+	if _, err := tx.AddInput(NewTxInFromOutPoint(prevOut, &script.Script{}, 0)); err == nil {
+		t.Errorf("AddInput should reject a second input spending the same output")
+	}
+	if i, ok := tx.FindInput(func(txIn *TxIn) bool { return txIn.PrevOut == prevOut }); !ok || i != 0 {
+		t.Errorf("FindInput = %d, %v, want 0", i, ok)
+	}
+
+	if i, err := tx.AddOutput(NewTxOut(MaxMoney-1, script.CreateP2AScript())); err != nil || i != 0 {
+		t.Fatalf("AddOutput = %d, %v", i, err)
+	}
+	if _, err := tx.AddOutput(NewTxOut(2, script.CreateP2AScript())); err == nil {
+		t.Errorf("AddOutput should reject outputs beyond MaxMoney")
+	}
+	if _, err := tx.AddOutput(&TxOut{Amount: 1}); err == nil {
+		t.Errorf("AddOutput should reject an output without a ScriptPubKey")
+	}
+	if i, err := tx.AddOutput(NewTxOut(1, script.CreateP2AScript())); err != nil || i != 1 || len(tx.TxOuts) != 2 {
+		t.Errorf("AddOutput = %d, %v, want the second output", i, err)
+	}
+}
@@ -0,0 +1,77 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"encoding/json"
+)
+
+type jsonTx struct {
+	TxID     string      `json:"txid"`
+	Version  uint32      `json:"version"`
+	Locktime uint32      `json:"locktime"`
+	Vin      []jsonTxIn  `json:"vin"`
+	Vout     []jsonTxOut `json:"vout"`
+}
+
+type jsonTxIn struct {
+	TxID      string   `json:"txid"`
+	Vout      uint32   `json:"vout"`
+	ScriptSig string   `json:"scriptsig"`
+	Sequence  uint32   `json:"sequence"`
+	Witness   []string `json:"witness,omitempty"`
+}
+
+type jsonTxOut struct {
+	Value        uint64 `json:"value"`
+	ScriptPubkey string `json:"scriptpubkey"`
+	Address      string `json:"scriptpubkey_address,omitempty"`
+}
+
+// MarshalJSON encodes the transaction with the field names of the esplora API.
+// Outputs of a standard type include their address on the network of the transaction.
+func (tx *Tx) MarshalJSON() ([]byte, error) {
+	id, err := tx.Id()
+	if err != nil {
+		return nil, err
+	}
+
+	result := jsonTx{
+		TxID:     id,
+		Version:  tx.Version,
+		Locktime: tx.Locktime,
+		Vin:      make([]jsonTxIn, len(tx.TxIns)),
+		Vout:     make([]jsonTxOut, len(tx.TxOuts)),
+	}
+
+	for i, txIn := range tx.TxIns {
+		scriptSig, err := txIn.ScriptSig.RawSerialize()
+		if err != nil {
+			return nil, err
+		}
+		result.Vin[i] = jsonTxIn{
+			TxID:      txIn.PrevOut.TxidString(),
+			Vout:      txIn.PrevOut.Index,
+			ScriptSig: hex.EncodeToString(scriptSig),
+			Sequence:  txIn.Sequence,
+		}
+		for _, item := range txIn.Witness {
+			result.Vin[i].Witness = append(result.Vin[i].Witness, hex.EncodeToString(item))
+		}
+	}
+
+	for i, txOut := range tx.TxOuts {
+		scriptPubkey, err := txOut.ScriptPubkey.RawSerialize()
+		if err != nil {
+			return nil, err
+		}
+		// nonstandard outputs have no address
+		address, _ := txOut.Address(tx.Testnet)
+		result.Vout[i] = jsonTxOut{
+			Value:        txOut.Amount,
+			ScriptPubkey: hex.EncodeToString(scriptPubkey),
+			Address:      address,
+		}
+	}
+
+	return json.Marshal(result)
+}
@@ -0,0 +1,44 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func TestTxMarshalJSON(t *testing.T) {
+	rawTx, _ := hex.DecodeString("0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600")
+	tx, err := ParseTx(bufio.NewReader(bytes.NewReader(rawTx)), false)
+	if err != nil {
+		t.Fatalf("Error parsing transaction: %v", err)
+	}
+
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("Error encoding transaction: %v", err)
+	}
+
+	var decoded jsonTx
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Error decoding %s: %v", data, err)
+	}
+
+	id, _ := tx.Id()
+	if decoded.TxID != id || decoded.Version != 1 || decoded.Locktime != 410393 {
+		t.Errorf("Unexpected transaction fields in %s", data)
+	}
+	if len(decoded.Vin) != 1 || decoded.Vin[0].TxID != "d1c789a9c60383bf715f3f6ad9d14b91fe55f3deb369fe5d9280cb1a01793f81" || decoded.Vin[0].Sequence != 0xfffffffe {
+		t.Errorf("Unexpected inputs in %s", data)
+	}
+	if len(decoded.Vout) != 2 {
+		t.Fatalf("Expected 2 outputs in %s", data)
+	}
+	if decoded.Vout[0].Value != 32454049 || decoded.Vout[0].ScriptPubkey != "76a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac" {
+		t.Errorf("Unexpected first output in %s", data)
+	}
+	if decoded.Vout[0].Address != "1JAHBxA51vwp5C2zpSB15VbxSZK3hVJs2H" {
+		t.Errorf("Expected address 1JAHBxA51vwp5C2zpSB15VbxSZK3hVJs2H, got %s", decoded.Vout[0].Address)
+	}
+}
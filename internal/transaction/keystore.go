@@ -0,0 +1,90 @@
+package transaction
+
+import (
+	"sync"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+// KeyStore is a KeyResolver that finds the key of an input by the ScriptPubKey of the output it spends
+type KeyStore struct {
+	mu sync.RWMutex
+	// keys are indexed by the raw serialization of the ScriptPubKeys they can satisfy
+	keys map[string]*signatureverification.PrivateKey
+}
+
+func NewKeyStore() *KeyStore {
+	return &KeyStore{keys: make(map[string]*signatureverification.PrivateKey)}
+}
+
+// Add indexes key by the outputs paying to it: p2pkh of the compressed and the uncompressed key, p2wpkh and p2sh-p2wpkh.
+// Of these SignAll only signs the p2pkh ones.
+func (ks *KeyStore) Add(key *signatureverification.PrivateKey) error {
+	h160 := key.Point.Hash160(true)
+	p2wpkh := script.CreateP2WPKHScript(h160)
+	p2shP2WPKH, err := script.ScriptToP2SH(p2wpkh)
+	if err != nil {
+		return err
+	}
+	return ks.add(key,
+		script.CreateP2pkhScript(h160),
+		script.CreateP2pkhScript(key.Point.Hash160(false)),
+		p2wpkh,
+		p2shP2WPKH,
+	)
+}
+
+// AddRedeemScript indexes key by the p2sh output of redeemScript, which key signs. SignAll does not sign p2sh inputs,
+// the key is for Lookup.
+func (ks *KeyStore) AddRedeemScript(redeemScript *script.Script, key *signatureverification.PrivateKey) error {
+	scriptPubkey, err := script.ScriptToP2SH(redeemScript)
+	if err != nil {
+		return err
+	}
+	return ks.add(key, scriptPubkey)
+}
+
+func (ks *KeyStore) add(key *signatureverification.PrivateKey, scriptPubkeys ...*script.Script) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for _, scriptPubkey := range scriptPubkeys {
+		raw, err := scriptPubkey.RawSerialize()
+		if err != nil {
+			return err
+		}
+		ks.keys[string(raw)] = key
+	}
+	return nil
+}
+
+// Lookup returns the key that can satisfy scriptPubkey
+func (ks *KeyStore) Lookup(scriptPubkey *script.Script) (*signatureverification.PrivateKey, bool) {
+	raw, err := scriptPubkey.RawSerialize()
+	if err != nil {
+		return nil, false
+	}
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[string(raw)]
+	return key, ok
+}
+
+// LookupAddress returns the key that can spend from address, a p2pkh, p2sh or p2wpkh address
+func (ks *KeyStore) LookupAddress(address string, testnet bool) (*signatureverification.PrivateKey, bool) {
+	scriptPubkey, err := AddressScriptPubkey(address, testnet)
+	if err != nil {
+		return nil, false
+	}
+	return ks.Lookup(scriptPubkey)
+}
+
+// ResolveKey returns the key of prevout when SignAll can sign it. Outputs it cannot sign, such as p2wpkh ones, are
+// left unsigned, Lookup still finds their key.
+func (ks *KeyStore) ResolveKey(index int, txIn *TxIn, prevout *TxOut) (*signatureverification.PrivateKey, error) {
+	if !signable(prevout.ScriptPubkey) {
+		return nil, nil
+	}
+	key, _ := ks.Lookup(prevout.ScriptPubkey)
+	return key, nil
+}
@@ -0,0 +1,102 @@
+package transaction
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func TestKeyStoreLookup(t *testing.T) {
+	alice, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	bob, _ := signatureverification.NewPrivateKey(big.NewInt(12345))
+	store := NewKeyStore()
+	if err := store.Add(alice); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	// a 1-of-1 multisig redeem script of bob
+	redeemScript := &script.Script{{0x51}, bob.Point.Serialize(true), {0x51}, {0xae}}
+	if err := store.AddRedeemScript(redeemScript, bob); err != nil {
+		t.Fatalf("AddRedeemScript error: %v", err)
+	}
+
+	rawP2WPKH, _ := script.CreateP2WPKHScript(alice.Point.Hash160(true)).RawSerialize()
+	rawRedeem, _ := redeemScript.RawSerialize()
+	tests := []struct {
+		name         string
+		scriptPubkey *script.Script
+		want         *signatureverification.PrivateKey
+	}{
+		{"p2pkh", script.CreateP2pkhScript(alice.Point.Hash160(true)), alice},
+		{"uncompressed p2pkh", script.CreateP2pkhScript(alice.Point.Hash160(false)), alice},
+		{"p2wpkh", script.CreateP2WPKHScript(alice.Point.Hash160(true)), alice},
+		{"p2sh-p2wpkh", script.CreateP2SHScript(utils.Hash160(rawP2WPKH)), alice},
+		{"p2sh redeem script", script.CreateP2SHScript(utils.Hash160(rawRedeem)), bob},
+		{"unknown", script.CreateP2pkhScript(bob.Point.Hash160(true)), nil},
+	}
+	for _, tt := range tests {
+		got, ok := store.Lookup(tt.scriptPubkey)
+		if got != tt.want || ok != (tt.want != nil) {
+			t.Errorf("%s: Lookup = %v, %v", tt.name, got, ok)
+		}
+	}
+
+	if got, ok := store.LookupAddress(alice.Point.Address(true, true), true); !ok || got != alice {
+		t.Errorf("LookupAddress of the p2pkh address failed")
+	}
+	if _, ok := store.LookupAddress(alice.Point.Address(true, false), true); ok {
+		t.Errorf("LookupAddress of a mainnet address on testnet should fail")
+	}
+}
+
+func TestSignAllWithKeyStore(t *testing.T) {
+	alice, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	bob, _ := signatureverification.NewPrivateKey(big.NewInt(12345))
+	store := NewKeyStore()
+	if err := store.Add(alice); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	aliceScript := script.CreateP2pkhScript(alice.Point.Hash160(true))
+	tx, lookup := spendingTx(script.CreateP2pkhScript(bob.Point.Hash160(true)), aliceScript)
+
+	unsigned, err := tx.SignAll(store, lookup)
+	if err != nil {
+		t.Fatalf("SignAll error: %v", err)
+	}
+	if len(unsigned) != 1 || unsigned[0] != 0 {
+		t.Errorf("Unsigned inputs = %v, want [0]", unsigned)
+	}
+	if err := tx.VerifyInputWith(1, aliceScript, script.StandardVerifyFlags); err != nil {
+		t.Errorf("Input 1 does not verify: %v", err)
+	}
+}
+
+func TestSignAllWithKeyStoreSkipsUnsignableOutputs(t *testing.T) {
+	alice, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	store := NewKeyStore()
+	if err := store.Add(alice); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	p2pkh := script.CreateP2pkhScript(alice.Point.Hash160(true))
+	p2wpkh := script.CreateP2WPKHScript(alice.Point.Hash160(true))
+	tx, lookup := spendingTx(p2pkh, p2wpkh)
+
+	unsigned, err := tx.SignAll(store, lookup)
+	if err != nil {
+		t.Fatalf("SignAll error: %v", err)
+	}
+	if len(unsigned) != 1 || unsigned[0] != 1 {
+		t.Errorf("Unsigned inputs = %v, want [1]", unsigned)
+	}
+	if err := tx.VerifyInputWith(0, p2pkh, script.StandardVerifyFlags); err != nil {
+		t.Errorf("Input 0 does not verify: %v", err)
+	}
+	if len(*tx.TxIns[1].ScriptSig) != 0 {
+		t.Errorf("The p2wpkh input should be left alone")
+	}
+	if _, ok := store.Lookup(p2wpkh); !ok {
+		t.Errorf("Lookup should still find the key of the p2wpkh output")
+	}
+}
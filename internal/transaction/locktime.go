@@ -0,0 +1,197 @@
+package transaction
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+// LockTimeThreshold separates the two meanings of a locktime: below it is a block height, from it on a unix time
+const LockTimeThreshold = 500000000
+
+// Bits of the sequence of an input that make up its relative locktime (BIP68)
+const (
+	// SequenceFinal disables the locktime of the transaction when all its inputs have it
+	SequenceFinal = 0xffffffff
+	// SequenceLockTimeDisableFlag disables the relative locktime of the input
+	SequenceLockTimeDisableFlag = 1 << 31
+	// SequenceLockTimeTypeFlag makes the relative locktime count units of 512 seconds instead of blocks
+	SequenceLockTimeTypeFlag = 1 << 22
+	// SequenceLockTimeMask holds the value of the relative locktime
+	SequenceLockTimeMask = 0x0000ffff
+	// SequenceLockTimeGranularity is the number of seconds in a unit of a relative locktime
+	SequenceLockTimeGranularity = 512
+)
+
+// LockTime is a decoded nLockTime: the block height or unix time from which the transaction can be mined
+type LockTime struct {
+	// IsTime tells whether Value is a unix time rather than a block height
+	IsTime bool
+	Value  uint32
+}
+
+// DecodeLockTime returns what the locktime of a transaction means
+func DecodeLockTime(locktime uint32) LockTime {
+	return LockTime{IsTime: locktime >= LockTimeThreshold, Value: locktime}
+}
+
+// LockTimeAtHeight returns the locktime of a transaction that can be mined from the block after height on
+func LockTimeAtHeight(height uint32) (uint32, error) {
+	if height >= LockTimeThreshold {
+		return 0, fmt.Errorf("height %d would be read as a unix time", height)
+	}
+	return height, nil
+}
+
+// LockTimeAtTime returns the locktime of a transaction that can be mined once the median time past is after t
+func LockTimeAtTime(t time.Time) (uint32, error) {
+	unix := t.Unix()
+	if unix < LockTimeThreshold || unix > 0xffffffff {
+		return 0, fmt.Errorf("time %s cannot be encoded as a locktime", t.UTC().Format(time.RFC3339))
+	}
+	return uint32(unix), nil
+}
+
+// Time returns the unix time of a time based locktime
+func (l LockTime) Time() time.Time {
+	return time.Unix(int64(l.Value), 0).UTC()
+}
+
+func (l LockTime) String() string {
+	if l.IsTime {
+		return l.Time().Format(time.RFC3339)
+	}
+	if l.Value == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("block %d", l.Value)
+}
+
+// RelativeLockTime is a decoded sequence: the number of blocks or 512 second units that have to pass
+// after the spent output confirmed (BIP68)
+type RelativeLockTime struct {
+	// Disabled is set when the sequence does not lock the input
+	Disabled bool
+	// IsTime tells whether Value counts units of SequenceLockTimeGranularity seconds rather than blocks
+	IsTime bool
+	Value  uint16
+}
+
+// DecodeSequence returns the relative locktime the sequence of an input sets
+func DecodeSequence(sequence uint32) RelativeLockTime {
+	return RelativeLockTime{
+		Disabled: sequence&SequenceLockTimeDisableFlag != 0,
+		IsTime:   sequence&SequenceLockTimeTypeFlag != 0,
+		Value:    uint16(sequence & SequenceLockTimeMask),
+	}
+}
+
+// SequenceForBlocks returns the sequence of an input that can be mined blocks after the output it spends
+func SequenceForBlocks(blocks uint16) uint32 {
+	return uint32(blocks)
+}
+
+// SequenceForDuration returns the sequence of an input that can be mined d after the output it spends,
+// rounded up to whole units of 512 seconds
+func SequenceForDuration(d time.Duration) (uint32, error) {
+	if d < 0 {
+		return 0, fmt.Errorf("duration %s is negative", d)
+	}
+	units := (d + SequenceLockTimeGranularity*time.Second - 1) / (SequenceLockTimeGranularity * time.Second)
+	if units > SequenceLockTimeMask {
+		return 0, fmt.Errorf("duration %s exceeds the longest relative locktime", d)
+	}
+	return SequenceLockTimeTypeFlag | uint32(units), nil
+}
+
+// Duration returns how long a time based relative locktime lasts
+func (r RelativeLockTime) Duration() time.Duration {
+	return time.Duration(r.Value) * SequenceLockTimeGranularity * time.Second
+}
+
+// Sequence encodes the relative locktime, the other bits of the sequence are left zero
+func (r RelativeLockTime) Sequence() uint32 {
+	sequence := uint32(r.Value)
+	if r.IsTime {
+		sequence |= SequenceLockTimeTypeFlag
+	}
+	if r.Disabled {
+		sequence |= SequenceLockTimeDisableFlag
+	}
+	return sequence
+}
+
+func (r RelativeLockTime) String() string {
+	switch {
+	case r.Disabled:
+		return "disabled"
+	case r.IsTime:
+		return fmt.Sprintf("%s (%d x 512 seconds)", r.Duration(), r.Value)
+	}
+	return fmt.Sprintf("%d blocks", r.Value)
+}
+
+// CheckTimelocks returns an error when input inputIndex of tx does not satisfy the OP_CHECKLOCKTIMEVERIFY (BIP65)
+// and OP_CHECKSEQUENCEVERIFY (BIP112) in lockingScript, the script of the output it spends or its redeem script.
+// Only timelocks pushed right before the opcode are checked, as every standard template does.
+func (tx *Tx) CheckTimelocks(inputIndex uint32, lockingScript *script.Script) error {
+	if int(inputIndex) >= len(tx.TxIns) {
+		return inputNotFound(inputIndex)
+	}
+	sequence := tx.TxIns[inputIndex].Sequence
+	cmds := *lockingScript
+	for i := 1; i < len(cmds); i++ {
+		if !script.IsOp(cmds[i], 0xb1) && !script.IsOp(cmds[i], 0xb2) {
+			continue
+		}
+		required, ok := script.CmdNumber(cmds[i-1], 5)
+		if !ok || required < 0 {
+			return fmt.Errorf("timelock of command %d is not a positive number", i)
+		}
+		var err error
+		if cmds[i][0] == 0xb1 {
+			err = tx.checkLockTime(uint32(required), sequence)
+		} else {
+			err = tx.checkSequence(uint32(required), sequence)
+		}
+		if err != nil {
+			return fmt.Errorf("input %d: %w", inputIndex, err)
+		}
+	}
+	return nil
+}
+
+func (tx *Tx) checkLockTime(required, sequence uint32) error {
+	want, have := DecodeLockTime(required), DecodeLockTime(tx.Locktime)
+	if want.IsTime != have.IsTime {
+		return fmt.Errorf("locktime %s and required locktime %s are not of the same kind", have, want)
+	}
+	if have.Value < want.Value {
+		return fmt.Errorf("locktime %s is before the required %s", have, want)
+	}
+	if sequence == SequenceFinal {
+		return fmt.Errorf("final sequence disables the locktime")
+	}
+	return nil
+}
+
+func (tx *Tx) checkSequence(required, sequence uint32) error {
+	want, have := DecodeSequence(required), DecodeSequence(sequence)
+	if want.Disabled {
+		return nil
+	}
+	if tx.Version < 2 {
+		return fmt.Errorf("version %d does not enable relative locktimes", tx.Version)
+	}
+	if have.Disabled {
+		return fmt.Errorf("sequence %#x disables the relative locktime", sequence)
+	}
+	if want.IsTime != have.IsTime {
+		return fmt.Errorf("relative locktime %s and required %s are not of the same kind", have, want)
+	}
+	if have.Value < want.Value {
+		return fmt.Errorf("relative locktime %s is shorter than the required %s", have, want)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package transaction
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func TestDecodeLockTime(t *testing.T) {
+	tests := []struct {
+		locktime uint32
+		want     string
+	}{
+		{0, "none"},
+		{800000, "block 800000"},
+		{LockTimeThreshold - 1, "block 499999999"},
+		{1700000000, "2023-11-14T22:13:20Z"},
+	}
+	for _, tt := range tests {
+		if got := DecodeLockTime(tt.locktime).String(); got != tt.want {
+			t.Errorf("DecodeLockTime(%d) = %s, want %s", tt.locktime, got, tt.want)
+		}
+	}
+
+	if locktime, err := LockTimeAtTime(time.Unix(1700000000, 0)); err != nil || locktime != 1700000000 {
+		t.Errorf("LockTimeAtTime = %d, %v", locktime, err)
+	}
+	if _, err := LockTimeAtTime(time.Unix(1000, 0)); err == nil {
+		t.Errorf("A time before the threshold should be rejected")
+	}
+	if _, err := LockTimeAtHeight(LockTimeThreshold); err == nil {
+		t.Errorf("A height at the threshold should be rejected")
+	}
+}
+
+func TestDecodeSequence(t *testing.T) {
+	tests := []struct {
+		sequence uint32
+		want     RelativeLockTime
+		str      string
+	}{
+		{SequenceFinal, RelativeLockTime{Disabled: true, IsTime: true, Value: 0xffff}, "disabled"},
+		{144, RelativeLockTime{Value: 144}, "144 blocks"},
+		{SequenceLockTimeTypeFlag | 2, RelativeLockTime{IsTime: true, Value: 2}, "17m4s (2 x 512 seconds)"},
+		// bits outside the BIP68 fields are ignored
+		{1<<23 | 10, RelativeLockTime{Value: 10}, "10 blocks"},
+	}
+	for _, tt := range tests {
+		got := DecodeSequence(tt.sequence)
+		if got != tt.want || got.String() != tt.str {
+			t.Errorf("DecodeSequence(%#x) = %+v %q, want %+v %q", tt.sequence, got, got, tt.want, tt.str)
+		}
+	}
+
+	sequence, err := SequenceForDuration(time.Hour)
+	if err != nil {
+		t.Fatalf("SequenceForDuration error: %v", err)
+	}
+	// an hour is 7.03 units, rounded up to 8
+	if relative := DecodeSequence(sequence); !relative.IsTime || relative.Value != 8 || relative.Sequence() != sequence {
+		t.Errorf("SequenceForDuration(1h) = %+v", relative)
+	}
+	if _, err := SequenceForDuration(400 * 24 * time.Hour); err == nil {
+		t.Errorf("A duration over a year should not fit")
+	}
+	if SequenceForBlocks(6) != 6 {
+		t.Errorf("SequenceForBlocks(6) = %d", SequenceForBlocks(6))
+	}
+}
+
+func TestCheckTimelocks(t *testing.T) {
+	pubkey := make([]byte, 33)
+	// <800000> OP_CHECKLOCKTIMEVERIFY OP_DROP <144> OP_CHECKSEQUENCEVERIFY OP_DROP <pubkey> OP_CHECKSIG
+	locking := &script.Script{{0x00, 0x35, 0x0c}, {0xb1}, {0x75}, {0x90, 0x00}, {0xb2}, {0x75}, pubkey, {0xac}}
+
+	newSpend := func(version, locktime, sequence uint32) *Tx {
+		txIn := NewTxIn(make([]byte, 32), 0, &script.Script{}, sequence)
+		return NewTx(version, []*TxIn{txIn}, nil, locktime, false)
+	}
+
+	tests := []struct {
+		name    string
+		tx      *Tx
+		wantErr string
+	}{
+		{"satisfied", newSpend(2, 800000, 144), ""},
+		{"locktime too early", newSpend(2, 799999, 144), "before the required"},
+		{"locktime is a time", newSpend(2, 1700000000, 144), "not of the same kind"},
+		{"final sequence", newSpend(2, 800000, SequenceFinal), "disables"},
+		{"relative locktime too short", newSpend(2, 800000, 143), "shorter than"},
+		{"relative locktime in time units", newSpend(2, 800000, SequenceLockTimeTypeFlag|144), "not of the same kind"},
+		{"version 1", newSpend(1, 800000, 144), "version 1"},
+	}
+	for _, tt := range tests {
+		err := tt.tx.CheckTimelocks(0, locking)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// a disabled requirement in the script is a no-op, as OP_CHECKSEQUENCEVERIFY treats it
+	cmd, _ := script.NumberCmd(SequenceLockTimeDisableFlag)
+	disabled := &script.Script{cmd, {0xb2}}
+	if err := newSpend(1, 0, SequenceFinal).CheckTimelocks(0, disabled); err != nil {
+		t.Errorf("Disabled relative locktime should pass: %v", err)
+	}
+}
+
+func TestVerifyTimelockedSpends(t *testing.T) {
+	key, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	pubkey := key.Point.Serialize(true)
+	cltv := script.NewBuilder().PushInt(800000).Ops("OP_CHECKLOCKTIMEVERIFY", "OP_DROP").PushData(pubkey).Op("OP_CHECKSIG").MustScript()
+	csv := script.NewBuilder().PushInt(144).Ops("OP_CHECKSEQUENCEVERIFY", "OP_DROP").PushData(pubkey).Op("OP_CHECKSIG").MustScript()
+
+	// spend returns a transaction spending the p2sh output of redeemScript, signed by key
+	spend := func(redeemScript *script.Script, version, locktime, sequence uint32) (*Tx, *script.Script) {
+		raw, _ := redeemScript.RawSerialize()
+		txIn := NewTxIn(utils.Hash256([]byte("timelocked")), 0, &script.Script{}, sequence)
+		txOut := NewTxOut(5000, script.CreateP2pkhScript(make([]byte, 20)))
+		tx := NewTx(version, []*TxIn{txIn}, []*TxOut{txOut}, locktime, false)
+		z, err := tx.SigHash(0, redeemScript)
+		if err != nil {
+			t.Fatalf("SigHash error: %v", err)
+		}
+		signature, _ := key.Sign(z)
+		tx.TxIns[0].ScriptSig = &script.Script{append(signature.Serialize(), byte(SigHashAll)), raw}
+		return tx, script.CreateP2SHScript(utils.Hash160(raw))
+	}
+
+	tests := []struct {
+		name                        string
+		redeemScript                *script.Script
+		version, locktime, sequence uint32
+		valid                       bool
+	}{
+		{"cltv satisfied", cltv, 1, 800000, 0xfffffffe, true},
+		{"cltv later", cltv, 1, 800001, 0, true},
+		{"cltv too early", cltv, 1, 799999, 0xfffffffe, false},
+		{"cltv time locktime", cltv, 1, 1700000000, 0xfffffffe, false},
+		{"cltv final sequence", cltv, 1, 800000, SequenceFinal, false},
+		{"csv satisfied", csv, 2, 0, 144, true},
+		{"csv longer", csv, 2, 0, SequenceForBlocks(200), true},
+		{"csv too short", csv, 2, 0, 143, false},
+		{"csv time units", csv, 2, 0, SequenceLockTimeTypeFlag | 144, false},
+		{"csv disabled sequence", csv, 2, 0, SequenceLockTimeDisableFlag | 144, false},
+		{"csv version 1", csv, 1, 0, 144, false},
+	}
+	for _, tt := range tests {
+		tx, scriptPubkey := spend(tt.redeemScript, tt.version, tt.locktime, tt.sequence)
+		err := tx.VerifyInputWith(0, scriptPubkey, script.StandardVerifyFlags)
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: spend should not verify", tt.name)
+		}
+		// the interpreter and CheckTimelocks agree on every spend
+		if checkErr := tx.CheckTimelocks(0, tt.redeemScript); (checkErr == nil) != tt.valid {
+			t.Errorf("%s: CheckTimelocks = %v, want valid %v", tt.name, checkErr, tt.valid)
+		}
+	}
+
+	// before BIP65 and BIP112 the opcodes were OP_NOP2 and OP_NOP3, nothing is checked
+	for _, redeemScript := range []*script.Script{cltv, csv} {
+		tx, scriptPubkey := spend(redeemScript, 1, 0, SequenceFinal)
+		if err := tx.VerifyInputWith(0, scriptPubkey, script.ScriptVerifyP2SH); err != nil {
+			t.Errorf("Spend without the timelock rules should verify: %v", err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package transaction
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// OutPoint references an output of a previous transaction.
+// Txid is kept in the byte order of the serialization, block explorers and RPCs display it reversed.
+type OutPoint struct {
+	Txid  [32]byte
+	Index uint32
+}
+
+// NewOutPoint creates an outpoint from a txid in display byte order, as returned by Tx.Hash
+func NewOutPoint(txid []byte, index uint32) OutPoint {
+	op := OutPoint{Index: index}
+	copy(op.Txid[:], txid)
+	slices.Reverse(op.Txid[:])
+	return op
+}
+
+// OutPointFromString parses an outpoint written as txid:index, the format of DisplayString
+func OutPointFromString(s string) (OutPoint, error) {
+	txidHex, indexString, ok := strings.Cut(s, ":")
+	if !ok {
+		return OutPoint{}, fmt.Errorf("outpoint %q is not txid:index", s)
+	}
+	txid, err := utils.ParseUint256Hex(txidHex)
+	if err != nil {
+		return OutPoint{}, fmt.Errorf("outpoint %q does not start with a 32 byte txid", s)
+	}
+	index, err := strconv.ParseUint(indexString, 10, 32)
+	if err != nil {
+		return OutPoint{}, fmt.Errorf("outpoint %q has an invalid index: %v", s, err)
+	}
+	return NewOutPoint(txid[:], uint32(index)), nil
+}
+
+// ParseOutPoint reads the 36 byte serialization of an outpoint
+func ParseOutPoint(reader io.Reader) (OutPoint, error) {
+	var op OutPoint
+	if _, err := io.ReadFull(reader, op.Txid[:]); err != nil {
+		return OutPoint{}, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &op.Index); err != nil {
+		return OutPoint{}, err
+	}
+	return op, nil
+}
+
+// WireBytes returns the serialization of the outpoint: the txid followed by the index, little endian
+func (op OutPoint) WireBytes() []byte {
+	return binary.LittleEndian.AppendUint32(append([]byte{}, op.Txid[:]...), op.Index)
+}
+
+// DisplayTxid returns the txid in display byte order
+func (op OutPoint) DisplayTxid() []byte {
+	txid := slices.Clone(op.Txid[:])
+	slices.Reverse(txid)
+	return txid
+}
+
+// TxidValue returns the txid as a number, which compares and prints in display byte order
+func (op OutPoint) TxidValue() utils.Uint256 {
+	txid, _ := utils.Uint256FromDigest(op.Txid[:])
+	return txid
+}
+
+// TxidString returns the txid as hex in display byte order, as used to look up transactions
+func (op OutPoint) TxidString() string {
+	return op.TxidValue().String()
+}
+
+// DisplayString returns the outpoint as txid:index
+func (op OutPoint) DisplayString() string {
+	return fmt.Sprintf("%s:%d", op.TxidString(), op.Index)
+}
+
+// IsNull returns whether the outpoint is the null outpoint a coinbase input refers to
+func (op OutPoint) IsNull() bool {
+	return op.Txid == [32]byte{} && op.Index == 0xffffffff
+}
@@ -0,0 +1,62 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestOutPoint(t *testing.T) {
+	display := "d1c789a9c60383bf715f3f6ad9d14b91fe55f3deb369fe5d9280cb1a01793f81"
+	txid, _ := hex.DecodeString(display)
+	op := NewOutPoint(txid, 1)
+
+	// internally the txid is in serialization order
+	if op.Txid[0] != 0x81 || op.Txid[31] != 0xd1 {
+		t.Errorf("Txid should be stored reversed, got %x", op.Txid)
+	}
+	if op.TxidString() != display || op.DisplayString() != display+":1" {
+		t.Errorf("Unexpected display %s", op.DisplayString())
+	}
+	if op.TxidValue().String() != display {
+		t.Errorf("TxidValue = %s, want %s", op.TxidValue(), display)
+	}
+	if !bytes.Equal(op.DisplayTxid(), txid) {
+		t.Errorf("DisplayTxid = %x, want %x", op.DisplayTxid(), txid)
+	}
+
+	wire := op.WireBytes()
+	if len(wire) != 36 || hex.EncodeToString(wire) != "813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d101000000" {
+		t.Errorf("Unexpected wire bytes %x", wire)
+	}
+	parsed, err := ParseOutPoint(bufio.NewReader(bytes.NewReader(wire)))
+	if err != nil || parsed != op {
+		t.Errorf("ParseOutPoint = %v, %v, want %v", parsed, err, op)
+	}
+
+	fromString, err := OutPointFromString(op.DisplayString())
+	if err != nil || fromString != op {
+		t.Errorf("OutPointFromString = %v, %v, want %v", fromString, err, op)
+	}
+	for _, invalid := range []string{display, "abcd:1", display + ":x", display + ":4294967296"} {
+		if _, err := OutPointFromString(invalid); err == nil {
+			t.Errorf("OutPointFromString(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestNullOutPoint(t *testing.T) {
+	if !NewOutPoint(make([]byte, 32), 0xffffffff).IsNull() {
+		t.Errorf("Zero txid at index 0xffffffff should be null")
+	}
+	if NewOutPoint(make([]byte, 32), 0).IsNull() {
+		t.Errorf("Index 0 should not be null")
+	}
+
+	// the previous transaction of a TxIn built from a display txid reads back the same
+	txid := bytes.Repeat([]byte{0x01, 0x02}, 16)
+	if txIn := NewTxIn(txid, 0, nil, 0); !bytes.Equal(txIn.PrevTx(), txid) {
+		t.Errorf("PrevTx = %x, want %x", txIn.PrevTx(), txid)
+	}
+}
@@ -0,0 +1,104 @@
+package transaction
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+// MaxStandardWitnessScriptSize is the largest witness script of a p2wsh input nodes relay
+const MaxStandardWitnessScriptSize = 3600
+
+// Satisfaction is what is at hand to satisfy a witness script
+type Satisfaction struct {
+	// Signatures holds DER signatures followed by their sighash type, by the SEC serialization of the signing key
+	Signatures map[string][]byte
+	// Preimages holds secrets by their sha256 hash
+	Preimages map[string][]byte
+}
+
+// P2WSHWitness returns the witness stack that spends a p2wsh output of witnessScript with satisfaction, the witness
+// script itself last. The witness scripts it knows are <key> OP_CHECKSIG, m-of-n OP_CHECKMULTISIG, which gets the
+// empty dummy element and its signatures in key order, and the HTLC of script.CreateHTLCScript, which is redeemed
+// when the preimage is there and refunded otherwise. Branches are selected with 0x01 and an empty item, the only
+// arguments OP_IF accepts in a witness script.
+func P2WSHWitness(witnessScript *script.Script, satisfaction *Satisfaction) ([][]byte, error) {
+	raw, err := witnessScript.RawSerialize()
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > MaxStandardWitnessScriptSize {
+		return nil, fmt.Errorf("witness script of %d bytes exceeds the standard %d", len(raw), MaxStandardWitnessScriptSize)
+	}
+
+	var stack [][]byte
+	cmds := *witnessScript
+	if required, keys, ok := witnessScript.ParseMultisig(); ok {
+		// OP_CHECKMULTISIG pops one element too many, it has to be empty
+		stack = append(stack, []byte{})
+		for _, key := range keys {
+			if signature, ok := satisfaction.Signatures[string(key)]; ok && len(stack) <= required {
+				stack = append(stack, signature)
+			}
+		}
+		if len(stack) <= required {
+			return nil, fmt.Errorf("%d of the %d signatures the witness script needs", len(stack)-1, required)
+		}
+	} else if len(cmds) == 2 && isPublicKey(cmds[0]) && script.IsOp(cmds[1], 0xac) {
+		signature, err := satisfaction.signature(cmds[0])
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, signature)
+	} else if secretHash, receiver, sender, ok := htlcParts(witnessScript); ok {
+		if preimage, ok := satisfaction.Preimages[string(secretHash)]; ok {
+			signature, err := satisfaction.signature(receiver)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, signature, preimage, []byte{0x01})
+		} else {
+			signature, err := satisfaction.signature(sender)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, signature, []byte{})
+		}
+	} else {
+		return nil, fmt.Errorf("do not know how to satisfy witness script %s", witnessScript)
+	}
+	return append(stack, raw), nil
+}
+
+// signature returns the signature by key
+func (s *Satisfaction) signature(key []byte) ([]byte, error) {
+	signature, ok := s.Signatures[string(key)]
+	if !ok {
+		return nil, fmt.Errorf("no signature by key %x", key)
+	}
+	return signature, nil
+}
+
+// htlcParts returns the secret hash, receiver key and sender key of an HTLC made by script.CreateHTLCScript
+func htlcParts(s *script.Script) ([]byte, []byte, []byte, bool) {
+	cmds := *s
+	opCodes := map[int]byte{0: 0x63, 1: 0xa8, 3: 0x88, 5: 0x67, 7: 0xb1, 8: 0x75, 10: 0x68, 11: 0xac}
+	if len(cmds) != 12 {
+		return nil, nil, nil, false
+	}
+	for i, opCode := range opCodes {
+		if !bytes.Equal(cmds[i], []byte{opCode}) {
+			return nil, nil, nil, false
+		}
+	}
+	if len(cmds[2]) != sha256.Size || !isPublicKey(cmds[4]) || !isPublicKey(cmds[9]) {
+		return nil, nil, nil, false
+	}
+	return cmds[2], cmds[4], cmds[9], true
+}
+
+func isPublicKey(cmd []byte) bool {
+	return len(cmd) == 33 || len(cmd) == 65
+}
@@ -0,0 +1,78 @@
+package transaction
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+func TestP2WSHWitness(t *testing.T) {
+	z := big.NewInt(0xfeed)
+	var keys [][]byte
+	signatures := make(map[string][]byte)
+	for secret := int64(1); secret <= 3; secret++ {
+		key, _ := signatureverification.NewPrivateKey(big.NewInt(secret))
+		sig, err := key.Sign(z)
+		if err != nil {
+			t.Fatalf("Sign error: %v", err)
+		}
+		keys = append(keys, key.Point.Serialize(true))
+		signatures[string(key.Point.Serialize(true))] = append(sig.Serialize(), byte(SigHashAll))
+	}
+	// the witness satisfies its script when the script runs on the items before it
+	satisfies := func(witness [][]byte) bool {
+		witnessScript, err := script.ParseRawScript(witness[len(witness)-1])
+		if err != nil {
+			t.Fatalf("ParseRawScript error: %v", err)
+		}
+		stack := script.Stack(append([][]byte{}, witness[:len(witness)-1]...))
+		ok, err := witnessScript.Execute(&script.ExecutionContext{Stack: stack, Z: z, Flags: script.ScriptVerifyMinimalIf})
+		return ok && err == nil
+	}
+
+	multisig := &script.Script{{0x52}, keys[0], keys[1], keys[2], {0x53}, {0xae}}
+	witness, err := P2WSHWitness(multisig, &Satisfaction{Signatures: map[string][]byte{
+		string(keys[2]): signatures[string(keys[2])],
+		string(keys[0]): signatures[string(keys[0])],
+	}})
+	if err != nil {
+		t.Fatalf("P2WSHWitness of multisig error: %v", err)
+	}
+	if len(witness) != 4 || len(witness[0]) != 0 || !bytes.Equal(witness[1], signatures[string(keys[0])]) || !bytes.Equal(witness[2], signatures[string(keys[2])]) {
+		t.Errorf("Multisig witness should be the empty dummy and the signatures in key order, got %x", witness)
+	}
+	if !satisfies(witness) {
+		t.Errorf("Multisig witness does not satisfy its script")
+	}
+	if _, err := P2WSHWitness(multisig, &Satisfaction{Signatures: map[string][]byte{string(keys[1]): signatures[string(keys[1])]}}); err == nil {
+		t.Errorf("One signature should not satisfy a 2-of-3 multisig")
+	}
+
+	pk := &script.Script{keys[1], {0xac}}
+	if witness, err := P2WSHWitness(pk, &Satisfaction{Signatures: signatures}); err != nil || !satisfies(witness) {
+		t.Errorf("Witness of a single key script does not satisfy it: %v", err)
+	}
+
+	secret := []byte("secret")
+	secretHash := sha256.Sum256(secret)
+	htlc, err := script.CreateHTLCScript(secretHash[:], keys[0], keys[1], 500)
+	if err != nil {
+		t.Fatalf("CreateHTLCScript error: %v", err)
+	}
+	redeem, err := P2WSHWitness(htlc, &Satisfaction{Signatures: signatures, Preimages: map[string][]byte{string(secretHash[:]): secret}})
+	if err != nil || !bytes.Equal(redeem[1], secret) || !satisfies(redeem) {
+		t.Errorf("HTLC should be redeemed with the preimage: %x, %v", redeem, err)
+	}
+	refund, err := P2WSHWitness(htlc, &Satisfaction{Signatures: signatures})
+	if err != nil || len(refund) != 3 || len(refund[1]) != 0 || !satisfies(refund) {
+		t.Errorf("HTLC without the preimage should be refunded: %x, %v", refund, err)
+	}
+
+	if _, err := P2WSHWitness(&script.Script{{0x51}}, &Satisfaction{}); err == nil {
+		t.Errorf("Unknown witness script should fail")
+	}
+}
@@ -0,0 +1,190 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// ErrInvalidPackage is wrapped by the errors of a parent and child that nodes would not accept as a package
+var ErrInvalidPackage = errors.New("invalid package")
+
+// PackageResult is the answer of a node to a submitted package, in the format of the submitpackage RPC of Bitcoin
+// Core that mempool.space passes on
+type PackageResult struct {
+	// Message is "success" when every transaction of the package was accepted
+	Message string `json:"package_msg"`
+	// TxResults holds the result of every transaction by wtxid
+	TxResults map[string]PackageTxResult `json:"tx-results"`
+	// ReplacedTransactions are the txids of the mempool transactions the package replaced
+	ReplacedTransactions []string `json:"replaced-transactions"`
+}
+
+// PackageTxResult is the result of one transaction of a package, Error is empty when it was accepted
+type PackageTxResult struct {
+	TxID  string `json:"txid"`
+	VSize int    `json:"vsize"`
+	Error string `json:"error"`
+}
+
+// CheckPackage returns an error wrapping ErrInvalidPackage when parent and child would not be accepted together:
+// child has to spend an output of parent and their fees together have to reach MinRelayFeeRate, even when the fee
+// rate of parent alone does not. The TRUC and ephemeral dust rules are checked as well. lookup finds the outputs
+// spent by the inputs of parent and the other inputs of child.
+func CheckPackage(parent, child *Tx, lookup PrevoutLookup) error {
+	parentHash, err := parent.Hash()
+	if err != nil {
+		return err
+	}
+	parentTxid := NewOutPoint(parentHash, 0).Txid
+	spendsParent := false
+	for _, txIn := range child.TxIns {
+		if txIn.PrevOut.Txid == parentTxid {
+			spendsParent = true
+		}
+	}
+	if !spendsParent {
+		return packageError("child does not spend an output of the parent")
+	}
+
+	parentFee, err := packageFee(parent, lookup)
+	if err != nil {
+		return fmt.Errorf("parent: %w", err)
+	}
+	// the outputs of the parent are not anywhere yet, the child finds them in the package
+	childFee, err := packageFee(child, func(txIn *TxIn) (*TxOut, error) {
+		if txIn.PrevOut.Txid != parentTxid {
+			return lookup(txIn)
+		}
+		if int(txIn.PrevOut.Index) >= len(parent.TxOuts) {
+			return nil, outputNotFound(txIn.PrevOut.Index)
+		}
+		return parent.TxOuts[txIn.PrevOut.Index], nil
+	})
+	if err != nil {
+		return fmt.Errorf("child: %w", err)
+	}
+
+	parentVSize, err := parent.VSize()
+	if err != nil {
+		return err
+	}
+	childVSize, err := child.VSize()
+	if err != nil {
+		return err
+	}
+	feeRate := float64(parentFee+childFee) / float64(parentVSize+childVSize)
+	if feeRate < MinRelayFeeRate {
+		return packageError("package fee rate of %.2f sat/vB is below the minimum relay fee rate of %.2f", feeRate, MinRelayFeeRate)
+	}
+
+	if err := CheckTRUC(parent, nil, 0); err != nil {
+		return fmt.Errorf("%w: parent: %w", ErrInvalidPackage, err)
+	}
+	if err := CheckTRUC(child, []*Tx{parent}, 0); err != nil {
+		return fmt.Errorf("%w: child: %w", ErrInvalidPackage, err)
+	}
+	if err := CheckEphemeralDust(parent, parentFee); err != nil {
+		return fmt.Errorf("%w: parent: %w", ErrInvalidPackage, err)
+	}
+	if err := CheckEphemeralDust(child, childFee); err != nil {
+		return fmt.Errorf("%w: child: %w", ErrInvalidPackage, err)
+	}
+	if err := CheckEphemeralSpends(parent, []*Tx{child}); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+	return nil
+}
+
+// packageFee returns the fee of tx with the outputs its inputs spend found by lookup
+func packageFee(tx *Tx, lookup PrevoutLookup) (uint64, error) {
+	var inputSum, outputSum uint64
+	for i, txIn := range tx.TxIns {
+		prevout, err := lookup(txIn)
+		if err != nil {
+			return 0, &InputError{Index: i, Err: err}
+		}
+		inputSum += prevout.Amount
+	}
+	for _, txOut := range tx.TxOuts {
+		outputSum += txOut.Amount
+	}
+	if outputSum > inputSum {
+		return 0, fmt.Errorf("transaction spends %d satoshis more than its inputs", outputSum-inputSum)
+	}
+	return inputSum - outputSum, nil
+}
+
+func packageError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidPackage, fmt.Sprintf(format, args...))
+}
+
+// PackageURL returns the API that accepts packages: BaseURL when it is set, like every other request of the
+// fetcher. The public Esplora of Blockstream has no package endpoint, so without it the package goes to the
+// same API of mempool.space.
+func (tf *TxFetcher) PackageURL(testnet bool) string {
+	if tf.BaseURL != "" {
+		return tf.BaseURL
+	}
+	if testnet {
+		return "https://mempool.space/testnet/api"
+	}
+	return "https://mempool.space/api"
+}
+
+// SubmitPackage checks parent and child with CheckPackage and submits them together, so a parent that pays less
+// than the minimum relay fee is accepted with the child that pays for it. lookup finds the outputs spent by the
+// inputs of parent and the other inputs of child, they are fetched when it is nil. An error is returned unless
+// both transactions were accepted.
+func (tf *TxFetcher) SubmitPackage(parent, child *Tx, lookup PrevoutLookup, testnet bool) (*PackageResult, error) {
+	if lookup == nil {
+		lookup = tf.PrevoutLookup(testnet)
+	}
+	if err := CheckPackage(parent, child, lookup); err != nil {
+		return nil, err
+	}
+
+	var rawTxs []string
+	for _, tx := range []*Tx{parent, child} {
+		raw, err := tx.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		rawTxs = append(rawTxs, hex.EncodeToString(raw))
+	}
+	body, err := json.Marshal(rawTxs)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/txs/package", tf.PackageURL(testnet))
+	response, err := utils.HTTPPost(tf.Client, tf.UserAgent, url, "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	var result PackageResult
+	if err := json.Unmarshal(response, &result); err != nil {
+		return nil, fmt.Errorf("unexpected response from %s: %v", url, err)
+	}
+	if result.Message != "success" {
+		return &result, fmt.Errorf("package was not accepted: %s", strings.Join(append([]string{result.Message}, result.txErrors()...), "; "))
+	}
+	return &result, nil
+}
+
+// txErrors returns the errors of the rejected transactions, sorted so they always print the same
+func (r *PackageResult) txErrors() []string {
+	var errs []string
+	for wtxid, txResult := range r.TxResults {
+		if txResult.Error != "" {
+			errs = append(errs, fmt.Sprintf("%s: %s", wtxid, txResult.Error))
+		}
+	}
+	slices.Sort(errs)
+	return errs
+}
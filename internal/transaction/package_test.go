@@ -0,0 +1,114 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+// anchoredPackage returns a TRUC parent paying no fee with an anchor output, and a child spending both of its
+// outputs for a fee of 1000 satoshis
+func anchoredPackage(t *testing.T) (*Tx, *Tx, PrevoutLookup) {
+	t.Helper()
+	parent, lookup := spendingTx(script.CreateP2pkhScript(make([]byte, 20)))
+	parent.Version = TRUCVersion
+	parent.TxOuts = []*TxOut{
+		NewTxOut(10000, script.CreateP2WPKHScript(make([]byte, 20))),
+		NewTxOut(0, script.CreateP2AScript()),
+	}
+	child := trucChild(t, parent, 0, TRUCVersion)
+	anchor := child.TxIns[0].PrevOut
+	anchor.Index = 1
+	child.TxIns = append(child.TxIns, NewTxInFromOutPoint(anchor, &script.Script{}, SequenceFinal))
+	child.TxOuts[0].Amount = 9000
+	return parent, child, lookup
+}
+
+func TestCheckPackage(t *testing.T) {
+	parent, child, lookup := anchoredPackage(t)
+	if err := CheckPackage(parent, child, lookup); err != nil {
+		t.Fatalf("child paying for its parent should be a valid package: %v", err)
+	}
+
+	free := child.Copy()
+	free.TxOuts[0].Amount = 10000
+	if err := CheckPackage(parent, free, lookup); !errors.Is(err, ErrInvalidPackage) {
+		t.Errorf("package without fees should be invalid, got %v", err)
+	}
+
+	anchorLeft := child.Copy()
+	anchorLeft.TxIns = anchorLeft.TxIns[:1]
+	if err := CheckPackage(parent, anchorLeft, lookup); !errors.Is(err, ErrInvalidPackage) || !errors.Is(err, ErrTRUCViolation) {
+		t.Errorf("package leaving the anchor unspent should be invalid, got %v", err)
+	}
+
+	unrelated, _ := spendingTx(script.CreateP2pkhScript(make([]byte, 20)))
+	if err := CheckPackage(parent, unrelated, lookup); !errors.Is(err, ErrInvalidPackage) {
+		t.Errorf("child not spending the parent should be invalid, got %v", err)
+	}
+
+	v2Child := child.Copy()
+	v2Child.Version = 2
+	if err := CheckPackage(parent, v2Child, lookup); !errors.Is(err, ErrTRUCViolation) {
+		t.Errorf("version 2 child of a TRUC parent should be a TRUC violation, got %v", err)
+	}
+}
+
+func TestSubmitPackage(t *testing.T) {
+	parent, child, lookup := anchoredPackage(t)
+
+	var submitted []string
+	wantURL := "https://mempool.space/testnet/api/txs/package"
+	response := `{"package_msg":"success","tx-results":{}}`
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		if request.Method != http.MethodPost || request.URL.String() != wantURL {
+			t.Errorf("unexpected request %s %s", request.Method, request.URL)
+		}
+		body, _ := io.ReadAll(request.Body)
+		if err := json.Unmarshal(body, &submitted); err != nil {
+			t.Errorf("package is not a JSON array: %v", err)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(response))}, nil
+	})}
+	fetcher := NewTxFetcherWithClient(client, "")
+
+	result, err := fetcher.SubmitPackage(parent, child, lookup, true)
+	if err != nil || result.Message != "success" {
+		t.Fatalf("SubmitPackage = %+v, %v", result, err)
+	}
+	if len(submitted) != 2 {
+		t.Fatalf("submitted %d transactions, want the parent and the child", len(submitted))
+	}
+	for i, tx := range []*Tx{parent, child} {
+		raw, _ := tx.Serialize()
+		if submitted[i] != hex.EncodeToString(raw) {
+			t.Errorf("transaction %d of the package is %s, want %x", i, submitted[i], raw)
+		}
+	}
+
+	response = `{"package_msg":"transaction failed","tx-results":{"aa":{"txid":"bb","error":"min relay fee not met"}}}`
+	if _, err := fetcher.SubmitPackage(parent, child, lookup, true); err == nil || !strings.Contains(err.Error(), "min relay fee not met") {
+		t.Errorf("rejected package should return the error of the node, got %v", err)
+	}
+
+	submitted = nil
+	free := child.Copy()
+	free.TxOuts[0].Amount = 10000
+	if _, err := fetcher.SubmitPackage(parent, free, lookup, true); !errors.Is(err, ErrInvalidPackage) || submitted != nil {
+		t.Errorf("invalid package should be rejected before it is submitted, got %v", err)
+	}
+
+	// a fetcher of another API submits the package there
+	fetcher.BaseURL = "https://mempool.space/signet/api"
+	wantURL = "https://mempool.space/signet/api/txs/package"
+	response = `{"package_msg":"success","tx-results":{}}`
+	if _, err := fetcher.SubmitPackage(parent, child, lookup, true); err != nil {
+		t.Errorf("SubmitPackage with BaseURL error: %v", err)
+	}
+}
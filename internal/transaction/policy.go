@@ -0,0 +1,136 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+// ErrPolicyViolation is wrapped by the errors of a SigningPolicy that refuses a transaction
+var ErrPolicyViolation = errors.New("signing policy violation")
+
+// Policy decides whether a transaction may be signed
+type Policy interface {
+	Check(tx *Tx) error
+}
+
+// SigningPolicy holds the spending conditions a service that signs automatically enforces.
+// Limits left at zero are not checked.
+type SigningPolicy struct {
+	// MaxAmount is the most a transaction may send to outputs other than ChangeAddresses, in satoshis
+	MaxAmount uint64
+	// AllowedAddresses, when not empty, are the only addresses besides ChangeAddresses that may be paid
+	AllowedAddresses []string
+	ChangeAddresses  []string
+	// MaxFeeRate is the highest fee rate in satoshis per vbyte. It is measured on the transaction as it is,
+	// before signing that overestimates the rate, which errs on the safe side.
+	MaxFeeRate float64
+	// MinConfirmations is how deep the outputs spent by the inputs have to be buried
+	MinConfirmations uint32
+
+	// Prevouts finds the spent outputs, they are fetched when nil
+	Prevouts PrevoutLookup
+	// Confirmations returns the number of confirmations of the output spent by txIn, required with MinConfirmations
+	Confirmations func(txIn *TxIn) (uint32, error)
+}
+
+// Check returns an error wrapping ErrPolicyViolation when tx does not meet the policy
+func (p *SigningPolicy) Check(tx *Tx) error {
+	var sent uint64
+	for i, txOut := range tx.TxOuts {
+		address, err := txOut.Address(tx.Testnet)
+		if err == nil && slices.Contains(p.ChangeAddresses, address) {
+			continue
+		}
+		if len(p.AllowedAddresses) > 0 && (err != nil || !slices.Contains(p.AllowedAddresses, address)) {
+			return p.violation("output %d pays %s, which is not an allowed address", i, describeOutput(txOut, tx.Testnet))
+		}
+		sent += txOut.Amount
+	}
+	if p.MaxAmount > 0 && sent > p.MaxAmount {
+		return p.violation("transaction sends %d satoshis, more than the maximum of %d", sent, p.MaxAmount)
+	}
+
+	if p.MaxFeeRate > 0 {
+		if err := p.checkFeeRate(tx); err != nil {
+			return err
+		}
+	}
+
+	if p.MinConfirmations > 0 {
+		if p.Confirmations == nil {
+			return fmt.Errorf("policy requires %d confirmations but has no way to look them up", p.MinConfirmations)
+		}
+		for i, txIn := range tx.TxIns {
+			confirmations, err := p.Confirmations(txIn)
+			if err != nil {
+				return fmt.Errorf("input %d: %w", i, err)
+			}
+			if confirmations < p.MinConfirmations {
+				return p.violation("input %d spends %s with %d confirmations, %d are required", i, txIn.PrevOut.DisplayString(), confirmations, p.MinConfirmations)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *SigningPolicy) checkFeeRate(tx *Tx) error {
+	var inputSum, outputSum uint64
+	for i, txIn := range tx.TxIns {
+		value, err := p.inputValue(txIn, tx.Testnet)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+		inputSum += value
+	}
+	for _, txOut := range tx.TxOuts {
+		outputSum += txOut.Amount
+	}
+	if outputSum > inputSum {
+		return fmt.Errorf("output is larger than input, which is not allowed")
+	}
+
+	vsize, err := tx.VSize()
+	if err != nil {
+		return err
+	}
+	if feeRate := float64(inputSum-outputSum) / float64(vsize); feeRate > p.MaxFeeRate {
+		return p.violation("fee rate of %.2f sat/vB exceeds the maximum of %.2f", feeRate, p.MaxFeeRate)
+	}
+	return nil
+}
+
+func (p *SigningPolicy) inputValue(txIn *TxIn, testnet bool) (uint64, error) {
+	if p.Prevouts == nil {
+		return txIn.Value(testnet)
+	}
+	txOut, err := p.Prevouts(txIn)
+	if err != nil {
+		return 0, err
+	}
+	return txOut.Amount, nil
+}
+
+func (p *SigningPolicy) violation(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrPolicyViolation, fmt.Sprintf(format, args...))
+}
+
+func describeOutput(txOut *TxOut, testnet bool) string {
+	if address, err := txOut.Address(testnet); err == nil {
+		return address
+	}
+	return txOut.ScriptPubkey.String()
+}
+
+// SignInputWithPolicy signs the input like SignInput, after policy accepted the transaction
+func (tx *Tx) SignInputWithPolicy(inputIndex uint32, privateKey *signatureverification.PrivateKey, policy Policy) error {
+	if err := policy.Check(tx); err != nil {
+		return err
+	}
+	if !tx.SignInput(inputIndex, privateKey) {
+		return fmt.Errorf("input %d could not be signed", inputIndex)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package transaction
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+func newPolicyTx() *Tx {
+	txIn := NewTxIn(bytes.Repeat([]byte{0x11}, 32), 0, &script.Script{}, 0xffffffff)
+	payment := NewTxOut(40000, script.CreateP2pkhScript(bytes.Repeat([]byte{0x22}, 20)))
+	change := NewTxOut(55000, script.CreateP2WPKHScript(bytes.Repeat([]byte{0x33}, 20)))
+	return NewTx(1, []*TxIn{txIn}, []*TxOut{payment, change}, 0, true)
+}
+
+func TestSigningPolicy(t *testing.T) {
+	tx := newPolicyTx()
+	paymentAddress, _ := tx.TxOuts[0].Address(true)
+	changeAddress, _ := tx.TxOuts[1].Address(true)
+	prevouts := func(txIn *TxIn) (*TxOut, error) { return NewTxOut(100000, &script.Script{}), nil }
+	confirmations := func(txIn *TxIn) (uint32, error) { return 3, nil }
+
+	tests := []struct {
+		name   string
+		policy SigningPolicy
+		want   string
+	}{
+		{"no limits", SigningPolicy{}, ""},
+		{"change does not count", SigningPolicy{MaxAmount: 40000, ChangeAddresses: []string{changeAddress}}, ""},
+		{"amount", SigningPolicy{MaxAmount: 40000}, "sends 95000 satoshis"},
+		{"whitelist", SigningPolicy{AllowedAddresses: []string{paymentAddress}, ChangeAddresses: []string{changeAddress}}, ""},
+		{"not whitelisted", SigningPolicy{AllowedAddresses: []string{paymentAddress}}, "output 1 pays " + changeAddress},
+		// 5000 satoshis over the 116 vbytes of the unsigned transaction
+		{"fee rate", SigningPolicy{MaxFeeRate: 45, Prevouts: prevouts}, ""},
+		{"fee rate too high", SigningPolicy{MaxFeeRate: 40, Prevouts: prevouts}, "fee rate of 43.10 sat/vB"},
+		{"confirmations", SigningPolicy{MinConfirmations: 3, Confirmations: confirmations}, ""},
+		{"too few confirmations", SigningPolicy{MinConfirmations: 6, Confirmations: confirmations}, "3 confirmations, 6 are required"},
+	}
+
+	for _, tt := range tests {
+		err := tt.policy.Check(tx)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrPolicyViolation) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want a policy violation containing %q", tt.name, err, tt.want)
+		}
+	}
+
+	// a policy that cannot look up confirmations fails instead of letting the transaction through
+	policy := SigningPolicy{MinConfirmations: 1}
+	if err := policy.Check(tx); err == nil || errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("got error %v, want a configuration error", err)
+	}
+}
+
+func TestSignInputWithPolicy(t *testing.T) {
+	tx := newPolicyTx()
+	privateKey, err := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.SignInputWithPolicy(0, privateKey, &SigningPolicy{MaxAmount: 1000}); !errors.Is(err, ErrPolicyViolation) {
+		t.Fatalf("got error %v, want %v", err, ErrPolicyViolation)
+	}
+	if len(*tx.TxIns[0].ScriptSig) != 0 {
+		t.Error("the input was signed although the policy refused the transaction")
+	}
+}
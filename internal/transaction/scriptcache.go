@@ -0,0 +1,90 @@
+package transaction
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"sync"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+// DefaultScriptCacheSize is the number of validated inputs a script cache holds by default
+const DefaultScriptCacheSize = 100000
+
+// ScriptCache remembers inputs that passed script verification, like the script cache of Bitcoin Core,
+// so an input checked when a block template was assembled is not executed again when the block is validated.
+// Only successes are stored, the least recently used entry is evicted when the cache is full.
+// It is safe for concurrent use.
+type ScriptCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[[32]byte]*list.Element
+	order    *list.List
+}
+
+func NewScriptCache(capacity int) *ScriptCache {
+	if capacity <= 0 {
+		capacity = DefaultScriptCacheSize
+	}
+	return &ScriptCache{capacity: capacity, entries: make(map[[32]byte]*list.Element), order: list.New()}
+}
+
+// Len returns the number of cached validations
+func (c *ScriptCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *ScriptCache) contains(key [32]byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if ok {
+		c.order.MoveToFront(element)
+	}
+	return ok
+}
+
+func (c *ScriptCache) add(key [32]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(key)
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.([32]byte))
+	}
+}
+
+// scriptCacheKey identifies a script validation. The signature hash commits to the whole transaction except the
+// scriptSigs, so together with the scriptSig, the witness, the ScriptPubKey and the flags it fixes the outcome.
+func scriptCacheKey(txIn *TxIn, scriptPubkey *script.Script, z *big.Int, flags script.VerificationFlags) ([32]byte, error) {
+	scriptSig, err := txIn.ScriptSig.Serialize()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	serializedScriptPubkey, err := scriptPubkey.Serialize()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	h := sha256.New()
+	h.Write(scriptSig)
+	h.Write(serializedScriptPubkey)
+	h.Write(binary.AppendUvarint(nil, uint64(len(txIn.Witness))))
+	for _, item := range txIn.Witness {
+		h.Write(binary.AppendUvarint(nil, uint64(len(item))))
+		h.Write(item)
+	}
+	h.Write(z.FillBytes(make([]byte, 32)))
+	h.Write(binary.LittleEndian.AppendUint32(nil, uint32(flags)))
+	var key [32]byte
+	h.Sum(key[:0])
+	return key, nil
+}
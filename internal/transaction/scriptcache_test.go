@@ -0,0 +1,99 @@
+package transaction
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+func TestVerifyInputCached(t *testing.T) {
+	txIns := make([]*TxIn, 3)
+	for i := range txIns {
+		txIns[i] = NewTxIn(bytes.Repeat([]byte{byte(i + 1)}, 32), 0, &script.Script{}, 0xffffffff)
+	}
+	tx := NewTx(1, txIns, []*TxOut{NewTxOut(1000, script.CreateP2pkhScript(make([]byte, 20)))}, 0, false)
+	succeeds := &script.Script{{0x51}}
+	fails := &script.Script{{0x00}}
+	cache := NewScriptCache(2)
+
+	if err := tx.VerifyInputCached(0, succeeds, script.StandardVerifyFlags, VerifyCaches{Scripts: cache}); err != nil {
+		t.Fatalf("VerifyInputCached failed: %v", err)
+	}
+	if err := tx.VerifyInputCached(0, succeeds, script.StandardVerifyFlags, VerifyCaches{Scripts: cache}); err != nil || cache.Len() != 1 {
+		t.Errorf("second validation returned %v with %d cached, want 1", err, cache.Len())
+	}
+
+	if err := tx.VerifyInputCached(0, fails, script.StandardVerifyFlags, VerifyCaches{Scripts: cache}); err == nil {
+		t.Error("failing script should not be served from the cache")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("failure was cached, %d entries", cache.Len())
+	}
+
+	// the least recently used input is evicted
+	tx.VerifyInputCached(1, succeeds, script.StandardVerifyFlags, VerifyCaches{Scripts: cache})
+	tx.VerifyInputCached(0, succeeds, script.StandardVerifyFlags, VerifyCaches{Scripts: cache})
+	tx.VerifyInputCached(2, succeeds, script.StandardVerifyFlags, VerifyCaches{Scripts: cache})
+	if cache.Len() != 2 {
+		t.Errorf("cache holds %d entries, want 2", cache.Len())
+	}
+	for index, want := range []bool{true, false, true} {
+		z, _ := tx.SigHash(uint32(index), succeeds)
+		key, _ := scriptCacheKey(tx.TxIns[index], succeeds, z, script.StandardVerifyFlags)
+		if got := cache.contains(key); got != want {
+			t.Errorf("input %d cached: %t, want %t", index, got, want)
+		}
+	}
+}
+
+func TestScriptCacheKey(t *testing.T) {
+	tx := NewTx(1, []*TxIn{NewTxIn(make([]byte, 32), 0, &script.Script{}, 0xffffffff)}, nil, 0, false)
+	scriptPubkey := &script.Script{{0x51}}
+	z, _ := tx.SigHash(0, scriptPubkey)
+	key, _ := scriptCacheKey(tx.TxIns[0], scriptPubkey, z, script.StandardVerifyFlags)
+
+	if other, _ := scriptCacheKey(tx.TxIns[0], scriptPubkey, z, script.ScriptVerifyP2SH); other == key {
+		t.Error("key does not depend on the flags")
+	}
+	if other, _ := scriptCacheKey(tx.TxIns[0], &script.Script{{0x52}}, z, script.StandardVerifyFlags); other == key {
+		t.Error("key does not depend on the ScriptPubKey")
+	}
+	tx.TxIns[0].Witness = [][]byte{{0x01}}
+	if other, _ := scriptCacheKey(tx.TxIns[0], scriptPubkey, z, script.StandardVerifyFlags); other == key {
+		t.Error("key does not depend on the witness")
+	}
+	tx.Locktime = 1
+	zLocktime, _ := tx.SigHash(0, scriptPubkey)
+	tx.TxIns[0].Witness = nil
+	if other, _ := scriptCacheKey(tx.TxIns[0], scriptPubkey, zLocktime, script.StandardVerifyFlags); other == key {
+		t.Error("key does not depend on the signature hash")
+	}
+}
+
+func TestVerifyInputSigCache(t *testing.T) {
+	key, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	scriptPubkey := script.CreateP2pkhScript(key.Point.Hash160(true))
+	tx := NewTx(1, []*TxIn{NewTxIn(make([]byte, 32), 0, &script.Script{}, 0xffffffff)}, []*TxOut{NewTxOut(1000, scriptPubkey)}, 0, false)
+	z, _ := tx.SigHash(0, scriptPubkey)
+	sig, _ := key.Sign(z)
+	tx.TxIns[0].ScriptSig = &script.Script{append(sig.Serialize(), byte(SigHashAll)), key.Point.Serialize(true)}
+
+	caches := VerifyCaches{Signatures: signatureverification.NewSigCache(10)}
+	for i := 0; i < 2; i++ {
+		if err := tx.VerifyInputCached(0, scriptPubkey, script.StandardVerifyFlags, caches); err != nil {
+			t.Fatalf("VerifyInputCached failed: %v", err)
+		}
+	}
+	if caches.Signatures.Len() != 1 {
+		t.Errorf("signature cache holds %d signatures, want 1", caches.Signatures.Len())
+	}
+
+	// a signature for another transaction is not in the cache
+	tx.Locktime = 1
+	if err := tx.VerifyInputCached(0, scriptPubkey, script.StandardVerifyFlags, caches); err == nil {
+		t.Error("signature should not verify after the transaction changed")
+	}
+}
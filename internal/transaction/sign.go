@@ -0,0 +1,153 @@
+package transaction
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+// KeyResolver supplies the private keys SignAll signs with
+type KeyResolver interface {
+	// ResolveKey returns the key that signs input index, which spends prevout, or nil when it has none
+	ResolveKey(index int, txIn *TxIn, prevout *TxOut) (*signatureverification.PrivateKey, error)
+}
+
+// KeyMap resolves the keys of the inputs by their index
+type KeyMap map[int]*signatureverification.PrivateKey
+
+func (m KeyMap) ResolveKey(index int, txIn *TxIn, prevout *TxOut) (*signatureverification.PrivateKey, error) {
+	return m[index], nil
+}
+
+// SignAll signs every input resolver has a key for and verifies it right away, the first input that fails to sign or
+// verify stops it. It returns the indexes of the inputs left unsigned. The spent outputs are found with lookup, they
+// are fetched when it is nil. Inputs spending p2pkh outputs and 1-of-n bare multisig outputs are signed with
+// SIGHASH_ALL.
+func (tx *Tx) SignAll(resolver KeyResolver, lookup PrevoutLookup) ([]int, error) {
+	if lookup == nil {
+		lookup = NewTxFetcher().PrevoutLookup(tx.Testnet)
+	}
+	var unsigned []int
+	for i, txIn := range tx.TxIns {
+		prevout, err := lookup(txIn)
+		if err != nil {
+			return nil, &InputError{Index: i, Err: err}
+		}
+		key, err := resolver.ResolveKey(i, txIn, prevout)
+		if err != nil {
+			return nil, &InputError{Index: i, Err: err}
+		}
+		if key == nil {
+			unsigned = append(unsigned, i)
+			continue
+		}
+		if prevout.ScriptPubkey.IsMultisigScriptPubKey() {
+			err = tx.SignMultisig(uint32(i), prevout.ScriptPubkey, key)
+		} else {
+			err = tx.signP2PKH(uint32(i), key, prevout.ScriptPubkey)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return unsigned, nil
+}
+
+// signable returns whether SignAll can sign an input spending scriptPubkey
+func signable(scriptPubkey *script.Script) bool {
+	return scriptPubkey.IsP2PKHScriptPubKey() || scriptPubkey.IsMultisigScriptPubKey()
+}
+
+// signP2PKH signs input inputIndex, which spends the p2pkh scriptPubkey of the compressed or uncompressed key
+func (tx *Tx) signP2PKH(inputIndex uint32, privateKey *signatureverification.PrivateKey, scriptPubkey *script.Script) error {
+	if !scriptPubkey.IsP2PKHScriptPubKey() {
+		return &InputError{Index: int(inputIndex), Err: fmt.Errorf("spends %s, only p2pkh outputs can be signed", scriptPubkey)}
+	}
+	h160 := (*scriptPubkey)[2]
+	var compressed bool
+	switch {
+	case bytes.Equal(h160, privateKey.Point.Hash160(true)):
+		compressed = true
+	case bytes.Equal(h160, privateKey.Point.Hash160(false)):
+		compressed = false
+	default:
+		return &InputError{Index: int(inputIndex), Err: fmt.Errorf("spends an output the key does not own")}
+	}
+
+	z, err := tx.SigHash(inputIndex, scriptPubkey)
+	if err != nil {
+		return err
+	}
+	derSig, err := privateKey.Sign(z)
+	if err != nil {
+		return err
+	}
+	sig := append(derSig.Serialize(), byte(SigHashAll))
+
+	previousScriptSig := tx.TxIns[inputIndex].ScriptSig
+	tx.TxIns[inputIndex].ScriptSig = &script.Script{sig, privateKey.Point.Serialize(compressed)}
+	if err := tx.VerifyInputWith(inputIndex, scriptPubkey, script.StandardVerifyFlags); err != nil {
+		tx.TxIns[inputIndex].ScriptSig = previousScriptSig
+		return fmt.Errorf("signed input does not verify: %w", err)
+	}
+	return nil
+}
+
+// SignMultisig signs input inputIndex, which spends the bare multisig scriptPubkey, with SIGHASH_ALL signatures
+// of keys. Keys that are not in the script are rejected, and there have to be as many as the script requires.
+func (tx *Tx) SignMultisig(inputIndex uint32, scriptPubkey *script.Script, keys ...*signatureverification.PrivateKey) error {
+	required, pubkeys, ok := scriptPubkey.ParseMultisig()
+	if !ok {
+		return &InputError{Index: int(inputIndex), Err: fmt.Errorf("spends %s, which is not a bare multisig output", scriptPubkey)}
+	}
+
+	// a key passed twice signs once, only distinct keys count towards the required signatures
+	signers := make(map[string]*signatureverification.PrivateKey)
+	for _, key := range keys {
+		pubkey, found := multisigPubkey(pubkeys, key)
+		if !found {
+			return &InputError{Index: int(inputIndex), Err: fmt.Errorf("key %x is not in the multisig output", key.Point.Serialize(true))}
+		}
+		signers[string(pubkey)] = key
+	}
+	if len(signers) < required {
+		return &InputError{Index: int(inputIndex), Err: fmt.Errorf("%d distinct keys for an output that needs %d signatures", len(signers), required)}
+	}
+
+	z, err := tx.SigHash(inputIndex, scriptPubkey)
+	if err != nil {
+		return err
+	}
+	signatures := make(map[string][]byte)
+	for pubkey, key := range signers {
+		derSig, err := key.Sign(z)
+		if err != nil {
+			return err
+		}
+		signatures[pubkey] = append(derSig.Serialize(), byte(SigHashAll))
+	}
+	scriptSig := multisigScriptSig(scriptPubkey, signatures)
+	if scriptSig == nil {
+		return &InputError{Index: int(inputIndex), Err: fmt.Errorf("signatures do not satisfy the multisig output")}
+	}
+
+	previousScriptSig := tx.TxIns[inputIndex].ScriptSig
+	tx.TxIns[inputIndex].ScriptSig = scriptSig
+	if err := tx.VerifyInputWith(inputIndex, scriptPubkey, script.StandardVerifyFlags); err != nil {
+		tx.TxIns[inputIndex].ScriptSig = previousScriptSig
+		return fmt.Errorf("signed input does not verify: %w", err)
+	}
+	return nil
+}
+
+// multisigPubkey returns the serialization of the public key of key among pubkeys, compressed or not
+func multisigPubkey(pubkeys [][]byte, key *signatureverification.PrivateKey) ([]byte, bool) {
+	for _, pubkey := range pubkeys {
+		if bytes.Equal(pubkey, key.Point.Serialize(true)) || bytes.Equal(pubkey, key.Point.Serialize(false)) {
+			return pubkey, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,140 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// spendingTx returns a transaction spending one output per scriptPubkey, and the lookup that finds them
+func spendingTx(scriptPubkeys ...*script.Script) (*Tx, PrevoutLookup) {
+	prevouts := make(map[OutPoint]*TxOut)
+	var txIns []*TxIn
+	for i, scriptPubkey := range scriptPubkeys {
+		txIn := NewTxIn(utils.Hash256([]byte{byte(i)}), uint32(i), &script.Script{}, 0xffffffff)
+		txIns = append(txIns, txIn)
+		prevouts[txIn.PrevOut] = NewTxOut(uint64(10000*(i+1)), scriptPubkey)
+	}
+	txOuts := []*TxOut{NewTxOut(5000, script.CreateP2pkhScript(make([]byte, 20)))}
+	lookup := func(txIn *TxIn) (*TxOut, error) {
+		if prevout, ok := prevouts[txIn.PrevOut]; ok {
+			return prevout, nil
+		}
+		return nil, fmt.Errorf("unknown prevout %s:%d", txIn.PrevOut.TxidString(), txIn.PrevOut.Index)
+	}
+	return NewTx(1, txIns, txOuts, 0, true), lookup
+}
+
+func TestSignAll(t *testing.T) {
+	alice, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	bob, _ := signatureverification.NewPrivateKey(big.NewInt(12345))
+	aliceScript := script.CreateP2pkhScript(alice.Point.Hash160(true))
+	// bob's output pays to his uncompressed key
+	bobScript := script.CreateP2pkhScript(bob.Point.Hash160(false))
+	tx, lookup := spendingTx(aliceScript, aliceScript, bobScript)
+
+	unsigned, err := tx.SignAll(KeyMap{0: alice, 2: bob}, lookup)
+	if err != nil {
+		t.Fatalf("SignAll error: %v", err)
+	}
+	if len(unsigned) != 1 || unsigned[0] != 1 {
+		t.Errorf("Unsigned inputs = %v, want [1]", unsigned)
+	}
+	for i, scriptPubkey := range []*script.Script{aliceScript, bobScript} {
+		index := uint32(i * 2)
+		if err := tx.VerifyInputWith(index, scriptPubkey, script.StandardVerifyFlags); err != nil {
+			t.Errorf("Input %d does not verify: %v", index, err)
+		}
+	}
+	if len(*tx.TxIns[1].ScriptSig) != 0 {
+		t.Errorf("Input without a key should be left alone")
+	}
+}
+
+func TestSignAllErrors(t *testing.T) {
+	alice, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	bob, _ := signatureverification.NewPrivateKey(big.NewInt(12345))
+	aliceScript := script.CreateP2pkhScript(alice.Point.Hash160(true))
+
+	tx, lookup := spendingTx(aliceScript)
+	if _, err := tx.SignAll(KeyMap{0: bob}, lookup); err == nil {
+		t.Errorf("Signing with a key that does not own the output should fail")
+	}
+	if len(*tx.TxIns[0].ScriptSig) != 0 {
+		t.Errorf("Failed input should not get a ScriptSig")
+	}
+
+	tx, lookup = spendingTx(script.CreateP2WPKHScript(alice.Point.Hash160(true)))
+	if _, err := tx.SignAll(KeyMap{0: alice}, lookup); err == nil {
+		t.Errorf("Signing a p2wpkh output should fail")
+	}
+
+	tx, _ = spendingTx(aliceScript)
+	missing := func(txIn *TxIn) (*TxOut, error) { return nil, fmt.Errorf("not found") }
+	if _, err := tx.SignAll(KeyMap{0: alice}, missing); err == nil {
+		t.Errorf("A failing lookup should fail SignAll")
+	}
+}
+
+func TestSignMultisig(t *testing.T) {
+	alice, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	bob, _ := signatureverification.NewPrivateKey(big.NewInt(12345))
+	carol, _ := signatureverification.NewPrivateKey(big.NewInt(54321))
+	multisig, err := script.CreateMultisigScript(2, [][]byte{alice.Point.Serialize(true), bob.Point.Serialize(true), carol.Point.Serialize(false)})
+	if err != nil {
+		t.Fatalf("CreateMultisigScript error: %v", err)
+	}
+
+	tx, _ := spendingTx(multisig)
+	if err := tx.SignMultisig(0, multisig, alice); err == nil {
+		t.Errorf("One key for a 2-of-3 output should fail")
+	}
+	// the signatures go in the order of the keys, whatever the order of the signers
+	if err := tx.SignMultisig(0, multisig, carol, alice); err != nil {
+		t.Fatalf("SignMultisig error: %v", err)
+	}
+	if len(*tx.TxIns[0].ScriptSig) != 3 {
+		t.Errorf("ScriptSig %s should hold the dummy element and 2 signatures", tx.TxIns[0].ScriptSig)
+	}
+	if err := tx.VerifyInputWith(0, multisig, script.StandardVerifyFlags); err != nil {
+		t.Errorf("Signed input does not verify: %v", err)
+	}
+
+	// the same key twice is one signature, not the two the output needs
+	tx, _ = spendingTx(multisig)
+	err = tx.SignMultisig(0, multisig, alice, alice)
+	var inputErr *InputError
+	if !errors.As(err, &inputErr) || inputErr.Index != 0 {
+		t.Errorf("Signing twice with one key = %v, want an InputError", err)
+	}
+	if tx.TxIns[0].ScriptSig == nil || len(*tx.TxIns[0].ScriptSig) != 0 {
+		t.Errorf("Failed input should keep its empty ScriptSig")
+	}
+
+	other, _ := signatureverification.NewPrivateKey(big.NewInt(1))
+	tx, _ = spendingTx(multisig)
+	if err := tx.SignMultisig(0, multisig, alice, other); err == nil {
+		t.Errorf("A key outside of the output should fail")
+	}
+	if len(*tx.TxIns[0].ScriptSig) != 0 {
+		t.Errorf("Failed input should not get a ScriptSig")
+	}
+
+	oneOfTwo, _ := script.CreateMultisigScript(1, [][]byte{alice.Point.Serialize(true), bob.Point.Serialize(true)})
+	tx, lookup := spendingTx(oneOfTwo)
+	if _, err := tx.SignAll(KeyMap{0: bob}, lookup); err != nil {
+		t.Fatalf("SignAll of a 1-of-2 output error: %v", err)
+	}
+	if err := tx.VerifyInputWith(0, oneOfTwo, script.StandardVerifyFlags); err != nil {
+		t.Errorf("Input signed by SignAll does not verify: %v", err)
+	}
+	if got := describeTxOut(NewTxOut(1000, oneOfTwo), false); !strings.HasSuffix(got, " 1-of-2 multisig") {
+		t.Errorf("describeTxOut = %q, want the m-of-n of the output", got)
+	}
+}
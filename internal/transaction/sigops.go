@@ -0,0 +1,50 @@
+package transaction
+
+import "github.com/caspereijkens/cryptocurrency/internal/script"
+
+// WitnessScaleFactor is the weight of a legacy signature operation relative to a witness one
+const WitnessScaleFactor = 4
+
+// ScriptPubkeyLookup returns the ScriptPubKey of the output spent by txIn
+type ScriptPubkeyLookup func(txIn *TxIn) (*script.Script, error)
+
+// LegacySigOpCount counts the signature operations in the ScriptSigs and ScriptPubKeys of the transaction
+func (tx *Tx) LegacySigOpCount() int {
+	count := 0
+	for _, txIn := range tx.TxIns {
+		count += txIn.ScriptSig.SigOpCount(false)
+	}
+	for _, txOut := range tx.TxOuts {
+		count += txOut.ScriptPubkey.SigOpCount(false)
+	}
+	return count
+}
+
+// SigOpCost returns the signature operation cost of the transaction.
+// The outputs being spent are fetched to count p2sh and witness signature operations.
+func (tx *Tx) SigOpCost() (int, error) {
+	return tx.SigOpCostWith(func(txIn *TxIn) (*script.Script, error) {
+		return txIn.ScriptPubkey(tx.Testnet)
+	})
+}
+
+// SigOpCostWith is SigOpCost with the spent ScriptPubKeys provided by lookup
+func (tx *Tx) SigOpCostWith(lookup ScriptPubkeyLookup) (int, error) {
+	cost := tx.LegacySigOpCount() * WitnessScaleFactor
+
+	if tx.IsCoinbase() {
+		return cost, nil
+	}
+
+	for _, txIn := range tx.TxIns {
+		scriptPubkey, err := lookup(txIn)
+		if err != nil {
+			return 0, err
+		}
+
+		cost += scriptPubkey.P2SHSigOpCount(txIn.ScriptSig) * WitnessScaleFactor
+		cost += script.WitnessSigOpCount(txIn.ScriptSig, scriptPubkey, txIn.Witness)
+	}
+
+	return cost, nil
+}
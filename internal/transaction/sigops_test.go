@@ -0,0 +1,66 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+func rawScript(t *testing.T, s *script.Script) []byte {
+	t.Helper()
+	raw, err := s.RawSerialize()
+	if err != nil {
+		t.Fatalf("Failed to serialize script: %v", err)
+	}
+	return raw
+}
+
+func TestSigOpCost(t *testing.T) {
+	pubkey := bytes.Repeat([]byte{0x02}, 33)
+	// 2-of-3 multisig redeem script
+	redeemScript := &script.Script{[]byte{0x52}, pubkey, pubkey, pubkey, []byte{0x53}, []byte{0xae}}
+	p2wpkh := &script.Script{[]byte{0x00}, make([]byte, 20)}
+
+	prevTxP2SH := bytes.Repeat([]byte{0x01}, 32)
+	prevTxP2WPKH := bytes.Repeat([]byte{0x02}, 32)
+	scriptPubkeys := map[string]*script.Script{
+		hex.EncodeToString(prevTxP2SH):   script.CreateP2SHScript(make([]byte, 20)),
+		hex.EncodeToString(prevTxP2WPKH): p2wpkh,
+	}
+	lookup := func(txIn *TxIn) (*script.Script, error) {
+		scriptPubkey, ok := scriptPubkeys[txIn.PrevOut.TxidString()]
+		if !ok {
+			return nil, fmt.Errorf("unknown prevout %s", txIn)
+		}
+		return scriptPubkey, nil
+	}
+
+	p2shIn := NewTxIn(prevTxP2SH, 0, &script.Script{[]byte{0x00}, bytes.Repeat([]byte{0x30}, 72), rawScript(t, redeemScript)}, 0xffffffff)
+	p2wpkhIn := NewTxIn(prevTxP2WPKH, 0, &script.Script{}, 0xffffffff)
+	p2wpkhIn.Witness = [][]byte{bytes.Repeat([]byte{0x30}, 72), pubkey}
+	txOut := NewTxOut(1000, script.CreateP2pkhScript(make([]byte, 20)))
+
+	tx := NewTx(1, []*TxIn{p2shIn, p2wpkhIn}, []*TxOut{txOut}, 0, false)
+
+	if got := tx.LegacySigOpCount(); got != 1 {
+		t.Errorf("LegacySigOpCount = %d, want 1", got)
+	}
+
+	// p2pkh output: 1*4, p2sh 2-of-3: 3*4, p2wpkh: 1
+	cost, err := tx.SigOpCostWith(lookup)
+	if err != nil {
+		t.Fatalf("SigOpCostWith error: %v", err)
+	}
+	if cost != 17 {
+		t.Errorf("SigOpCostWith = %d, want 17", cost)
+	}
+
+	// Missing prevouts are reported
+	tx.TxIns = append(tx.TxIns, NewTxIn(make([]byte, 32), 1, &script.Script{}, 0xffffffff))
+	if _, err := tx.SigOpCostWith(lookup); err == nil {
+		t.Errorf("Expected an error for an unknown prevout")
+	}
+}
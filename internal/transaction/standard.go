@@ -0,0 +1,28 @@
+package transaction
+
+import "fmt"
+
+// MaxStandardScriptSigSize is the largest ScriptSig nodes relay, enough for a 15-of-15 multisig p2sh spend with
+// compressed keys. It is a policy rule of Bitcoin Core, not a consensus one.
+const MaxStandardScriptSigSize = 1650
+
+// CheckStandardScriptSigs returns an error when a ScriptSig of tx would keep nodes from relaying it:
+// one that is larger than MaxStandardScriptSigSize or does anything but push data. Coinbases are not relayed.
+func (tx *Tx) CheckStandardScriptSigs() error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+	for i, txIn := range tx.TxIns {
+		raw, err := txIn.ScriptSig.RawSerialize()
+		if err != nil {
+			return err
+		}
+		if len(raw) > MaxStandardScriptSigSize {
+			return fmt.Errorf("ScriptSig of input %d is %d bytes, more than the standard %d", i, len(raw), MaxStandardScriptSigSize)
+		}
+		if !txIn.ScriptSig.IsPushOnly() {
+			return fmt.Errorf("ScriptSig of input %d is not push only", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package transaction
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func TestCheckStandardScriptSigs(t *testing.T) {
+	tx, _ := spendingTx(script.CreateP2pkhScript(make([]byte, 20)))
+	tx.TxIns[0].ScriptSig = &script.Script{make([]byte, 72), make([]byte, 33)}
+	if err := tx.CheckStandardScriptSigs(); err != nil {
+		t.Errorf("p2pkh ScriptSig should be standard: %v", err)
+	}
+
+	// four pushes of 415 bytes with their OP_PUSHDATA2 prefixes are 1672 bytes
+	large := script.Script{}
+	for i := 0; i < 4; i++ {
+		large = append(large, make([]byte, 415))
+	}
+	tx.TxIns[0].ScriptSig = &large
+	if err := tx.CheckStandardScriptSigs(); err == nil {
+		t.Errorf("ScriptSig over %d bytes should not be standard", MaxStandardScriptSigSize)
+	}
+
+	tx.TxIns[0].ScriptSig = &script.Script{make([]byte, 72), {0x76}}
+	if err := tx.CheckStandardScriptSigs(); err == nil {
+		t.Errorf("ScriptSig with OP_DUP should not be standard")
+	}
+}
+
+func TestP2SHScriptSigMustBePushOnly(t *testing.T) {
+	alice, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	// OP_1 <alice> OP_1 OP_CHECKMULTISIG
+	redeemScript := &script.Script{{0x51}, alice.Point.Serialize(true), {0x51}, {0xae}}
+	rawRedeem, _ := redeemScript.RawSerialize()
+	scriptPubkey := script.CreateP2SHScript(utils.Hash160(rawRedeem))
+	tx, _ := spendingTx(scriptPubkey)
+
+	z, err := tx.SigHash(0, redeemScript)
+	if err != nil {
+		t.Fatalf("SigHash error: %v", err)
+	}
+	signature, _ := alice.Sign(z)
+	sig := append(signature.Serialize(), byte(SigHashAll))
+
+	tx.TxIns[0].ScriptSig = &script.Script{{0x00}, sig, rawRedeem}
+	if err := tx.VerifyInputWith(0, scriptPubkey, script.StandardVerifyFlags); err != nil {
+		t.Fatalf("p2sh spend should verify: %v", err)
+	}
+
+	// OP_NOP does not change the result, but BIP16 only allows pushes
+	tx.TxIns[0].ScriptSig = &script.Script{{0x00}, sig, {0x61}, rawRedeem}
+	if err := tx.VerifyInputWith(0, scriptPubkey, script.StandardVerifyFlags); err == nil {
+		t.Errorf("p2sh spend with OP_NOP in the ScriptSig should not verify")
+	}
+}
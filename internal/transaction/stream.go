@@ -0,0 +1,161 @@
+package transaction
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// MaxTxSize bounds the serialized size of a transaction, a transaction cannot be larger than a block
+const MaxTxSize = 4000000
+
+// A transaction of MaxTxSize holds at most this many inputs of 41 bytes, outputs of 9 bytes or witness items of 1 byte.
+// Counts above these come from corrupted or hostile data and are rejected before anything is allocated for them.
+const (
+	MaxTxInputs       = MaxTxSize / 41
+	MaxTxOutputs      = MaxTxSize / 9
+	MaxWitnessItems   = MaxTxSize
+	maxWitnessItemLen = MaxTxSize
+)
+
+// TxStream reads a transaction from a stream one input and one output at a time, so very large transactions
+// can be processed without holding all of them in memory. Read the inputs with NextInput until it returns io.EOF,
+// then the outputs with NextOutput, then call Finish for the witnesses and the locktime.
+type TxStream struct {
+	Version uint32
+	// Segwit is whether the transaction has the segwit marker and flag, its witnesses follow the outputs
+	Segwit     bool
+	NumInputs  uint64
+	NumOutputs uint64
+	Testnet    bool
+	// Lenient keeps scripts that do not parse as opaque scripts, see ParseTxLenient
+	Lenient bool
+
+	reader      *bufio.Reader
+	inputsRead  uint64
+	outputsRead uint64
+	outputsSeen bool
+}
+
+// NewTxStream reads the version and the input count of a transaction from reader
+func NewTxStream(reader *bufio.Reader, testnet bool) (*TxStream, error) {
+	stream := &TxStream{reader: reader, Testnet: testnet}
+
+	// version is an integer in 4 bytes, little-endian
+	if err := binary.Read(reader, binary.LittleEndian, &stream.Version); err != nil {
+		return nil, err
+	}
+
+	// A segwit transaction has the marker 0x00 (an input count of zero) and the flag 0x01 after the version
+	if marker, err := reader.Peek(2); err == nil && marker[0] == 0x00 && marker[1] == 0x01 {
+		stream.Segwit = true
+		if _, err := reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+
+	numInputs, err := readCount(reader, MaxTxInputs, "inputs")
+	if err != nil {
+		return nil, err
+	}
+	stream.NumInputs = numInputs
+	return stream, nil
+}
+
+// NextInput returns the next input, or io.EOF after the last one
+func (s *TxStream) NextInput() (*TxIn, error) {
+	if s.inputsRead == s.NumInputs {
+		return nil, io.EOF
+	}
+	txIn, err := parseTxIn(s.reader, s.parseScript())
+	if err != nil {
+		return nil, fmt.Errorf("input %d: %w", s.inputsRead, err)
+	}
+	s.inputsRead++
+	return txIn, nil
+}
+
+// NextOutput returns the next output, or io.EOF after the last one. All inputs have to be read first.
+func (s *TxStream) NextOutput() (*TxOut, error) {
+	if err := s.readOutputCount(); err != nil {
+		return nil, err
+	}
+	if s.outputsRead == s.NumOutputs {
+		return nil, io.EOF
+	}
+	txOut, err := parseTxOut(s.reader, s.parseScript())
+	if err != nil {
+		return nil, fmt.Errorf("output %d: %w", s.outputsRead, err)
+	}
+	s.outputsRead++
+	return txOut, nil
+}
+
+func (s *TxStream) parseScript() func(reader *bufio.Reader) (*script.Script, error) {
+	if s.Lenient {
+		return script.ParseScriptLenient
+	}
+	return script.ParseScript
+}
+
+func (s *TxStream) readOutputCount() error {
+	if s.outputsSeen {
+		return nil
+	}
+	if s.inputsRead != s.NumInputs {
+		return fmt.Errorf("%d of %d inputs are not read yet", s.NumInputs-s.inputsRead, s.NumInputs)
+	}
+	numOutputs, err := readCount(s.reader, MaxTxOutputs, "outputs")
+	if err != nil {
+		return err
+	}
+	s.NumOutputs, s.outputsSeen = numOutputs, true
+	return nil
+}
+
+// Finish reads the witnesses of a segwit transaction, one per input in order, and the locktime.
+// All outputs have to be read first. Witness stacks are passed to witness, which may be nil to skip them.
+func (s *TxStream) Finish(witness func(index uint64, items [][]byte)) (uint32, error) {
+	if err := s.readOutputCount(); err != nil {
+		return 0, err
+	}
+	if s.outputsRead != s.NumOutputs {
+		return 0, fmt.Errorf("%d of %d outputs are not read yet", s.NumOutputs-s.outputsRead, s.NumOutputs)
+	}
+
+	// the witness of every input comes after the outputs
+	if s.Segwit {
+		for i := uint64(0); i < s.NumInputs; i++ {
+			items, err := parseWitness(s.reader)
+			if err != nil {
+				return 0, fmt.Errorf("witness %d: %w", i, err)
+			}
+			if witness != nil {
+				witness(i, items)
+			}
+		}
+	}
+
+	// locktime is an integer in 4 bytes, little-endian
+	var locktime uint32
+	if err := binary.Read(s.reader, binary.LittleEndian, &locktime); err != nil {
+		return 0, err
+	}
+	return locktime, nil
+}
+
+// readCount reads a varint count and rejects counts above limit
+func readCount(reader *bufio.Reader, limit uint64, what string) (uint64, error) {
+	count, err := utils.ReadVarint(reader)
+	if err != nil {
+		return 0, err
+	}
+	if count > limit {
+		return 0, fmt.Errorf("%d %s exceed the limit of %d", count, what, limit)
+	}
+	return count, nil
+}
@@ -0,0 +1,119 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+func TestTxStream(t *testing.T) {
+	rawTx, _ := hex.DecodeString("0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600")
+	stream, err := NewTxStream(bufio.NewReader(bytes.NewReader(rawTx)), false)
+	if err != nil {
+		t.Fatalf("NewTxStream failed: %v", err)
+	}
+	if stream.Version != 1 || stream.Segwit || stream.NumInputs != 1 {
+		t.Fatalf("unexpected stream header %+v", stream)
+	}
+
+	if _, err := stream.NextOutput(); err == nil {
+		t.Error("expected outputs to be refused before the inputs are read")
+	}
+
+	txIn, err := stream.NextInput()
+	if err != nil {
+		t.Fatalf("NextInput failed: %v", err)
+	}
+	if txIn.PrevOut.TxidString() != "d1c789a9c60383bf715f3f6ad9d14b91fe55f3deb369fe5d9280cb1a01793f81" {
+		t.Errorf("unexpected input %s", txIn)
+	}
+	if _, err := stream.NextInput(); err != io.EOF {
+		t.Errorf("got %v after the last input, want io.EOF", err)
+	}
+
+	var amounts []uint64
+	for {
+		txOut, err := stream.NextOutput()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextOutput failed: %v", err)
+		}
+		amounts = append(amounts, txOut.Amount)
+	}
+	if len(amounts) != 2 || amounts[0] != 32454049 || amounts[1] != 10011545 {
+		t.Errorf("got output amounts %v", amounts)
+	}
+
+	locktime, err := stream.Finish(nil)
+	if err != nil || locktime != 410393 {
+		t.Errorf("Finish returned %d, %v, want locktime 410393", locktime, err)
+	}
+}
+
+func TestTxStreamWitness(t *testing.T) {
+	txIn := NewTxIn(bytes.Repeat([]byte{0x11}, 32), 0, &script.Script{}, 0xffffffff)
+	txIn.Witness = [][]byte{{0xab, 0xcd}, {0x01, 0x02, 0x03}}
+	tx := NewTx(2, []*TxIn{txIn}, []*TxOut{NewTxOut(1000, script.CreateP2WPKHScript(make([]byte, 20)))}, 0, false)
+	raw, err := tx.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	stream, err := NewTxStream(bufio.NewReader(bytes.NewReader(raw)), false)
+	if err != nil {
+		t.Fatalf("NewTxStream failed: %v", err)
+	}
+	if !stream.Segwit {
+		t.Fatal("expected a segwit transaction")
+	}
+	if _, err := stream.NextInput(); err != nil {
+		t.Fatalf("NextInput failed: %v", err)
+	}
+	if _, err := stream.Finish(nil); err == nil {
+		t.Error("expected Finish to refuse unread outputs")
+	}
+	if _, err := stream.NextOutput(); err != nil {
+		t.Fatalf("NextOutput failed: %v", err)
+	}
+
+	var witnesses [][][]byte
+	if _, err := stream.Finish(func(index uint64, items [][]byte) { witnesses = append(witnesses, items) }); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if len(witnesses) != 1 || len(witnesses[0]) != 2 || !bytes.Equal(witnesses[0][1], []byte{0x01, 0x02, 0x03}) {
+		t.Errorf("got witnesses %x", witnesses)
+	}
+}
+
+func TestParseTxRejectsHostileCounts(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want string
+	}{
+		// version 1, 0xffffffffffffffff inputs
+		{"inputs", "01000000ffffffffffffffffff", "inputs exceed"},
+		// version 1, no inputs, 2^32 outputs
+		{"outputs", "0100000000feffffffff", "outputs exceed"},
+		// version 1, one input with a script of 2^32 bytes
+		{"script", "0100000001" + strings.Repeat("00", 36) + "feffffffff", "script length"},
+		// segwit, one empty input, no outputs and a witness of 2^32 items
+		{"witness", "01000000000101" + strings.Repeat("00", 41) + "00" + "feffffffff", "witness items exceed"},
+	}
+
+	for _, tt := range tests {
+		raw, _ := hex.DecodeString(tt.hex)
+		_, err := ParseTx(bufio.NewReader(bytes.NewReader(raw)), false)
+		if !errors.Is(err, ErrMalformedTx) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want a malformed transaction error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
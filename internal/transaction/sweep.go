@@ -0,0 +1,113 @@
+package transaction
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+// DustRelayFeeRate is the fee rate in satoshis per vbyte nodes use to decide whether an output is dust
+const DustRelayFeeRate = 3.0
+
+// Coin is an unspent output together with the outpoint that spends it
+type Coin struct {
+	OutPoint OutPoint
+	TxOut    *TxOut
+}
+
+// DustThreshold returns the smallest amount an output paying scriptPubkey can have without being dust:
+// what it costs at DustRelayFeeRate to create the output and later spend it
+func DustThreshold(scriptPubkey *script.Script) uint64 {
+	raw, err := scriptPubkey.RawSerialize()
+	if err != nil {
+		return 0
+	}
+	// amount, script length and script
+	size := 8 + 1 + len(raw)
+	if scriptPubkey.IsP2WPKHScriptPubKey() || scriptPubkey.IsP2WSHScriptPubKey() || scriptPubkey.IsP2TRScriptPubKey() ||
+		scriptPubkey.IsP2AScriptPubKey() {
+		// an input with its signature and key in the witness
+		size += 32 + 4 + 1 + 107/WitnessScaleFactor + 4
+	} else {
+		size += 32 + 4 + 1 + 107 + 4
+	}
+	return uint64(size) * DustRelayFeeRate
+}
+
+// satisfactionWeight returns the weight a signature and key add to an input spending scriptPubkey, and whether they
+// go in the witness. Only outputs that are spent with one signature are known.
+func satisfactionWeight(scriptPubkey *script.Script) (weight int, witness bool, ok bool) {
+	switch {
+	case scriptPubkey.IsP2PKHScriptPubKey():
+		// a push of a signature of up to 72 bytes with its sighash type and of a 33 byte key
+		return (1 + 73 + 1 + 33) * WitnessScaleFactor, false, true
+	case scriptPubkey.IsP2WPKHScriptPubKey():
+		// the number of witness items, a signature with its sighash type and a 33 byte key
+		return 1 + 1 + 73 + 1 + 33, true, true
+	case scriptPubkey.IsP2TRScriptPubKey():
+		// a key path spend with a 64 byte signature
+		return 1 + 1 + 64, true, true
+	}
+	return 0, false, false
+}
+
+// SweepAll builds an unsigned transaction spending coins into a single output paying destination, with the fee
+// at feeRate satoshis per vbyte subtracted from it. There is no change. Coins that cost more to spend than they are
+// worth, and coins of which the size of the signed input can not be estimated, are left out and returned. The inputs
+// are in outpoint order, so the same coins always sweep into the same transaction.
+func SweepAll(coins []Coin, destination *script.Script, feeRate float64, testnet bool) (*Tx, []Coin, error) {
+	if feeRate < MinRelayFeeRate {
+		return nil, nil, fmt.Errorf("fee rate %.2f sat/vB is below the minimum relay fee rate of %.2f", feeRate, MinRelayFeeRate)
+	}
+	sorted := slices.Clone(coins)
+	slices.SortFunc(sorted, func(a, b Coin) int {
+		if c := bytes.Compare(a.OutPoint.Txid[:], b.OutPoint.Txid[:]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.OutPoint.Index, b.OutPoint.Index)
+	})
+
+	var txIns []*TxIn
+	var skipped []Coin
+	var total uint64
+	satisfaction, witnessInputs := 0, 0
+	for _, coin := range sorted {
+		weight, witness, _ := satisfactionWeight(coin.TxOut.ScriptPubkey)
+		if value, ok := EffectiveValue(coin, feeRate); !ok || value <= 0 {
+			skipped = append(skipped, coin)
+			continue
+		}
+		txIns = append(txIns, NewTxInFromOutPoint(coin.OutPoint, &script.Script{}, SequenceFinal))
+		total += coin.TxOut.Amount
+		satisfaction += weight
+		if witness {
+			witnessInputs++
+		}
+	}
+	if len(txIns) == 0 {
+		return nil, skipped, fmt.Errorf("none of the %d coins is worth spending at %.2f sat/vB", len(coins), feeRate)
+	}
+
+	tx := NewTx(1, txIns, []*TxOut{NewTxOut(0, destination)}, 0, testnet)
+	weight, err := tx.Weight()
+	if err != nil {
+		return nil, skipped, err
+	}
+	weight += satisfaction
+	if witnessInputs > 0 {
+		// the marker and flag, and an empty witness for every input without one
+		weight += 2 + len(txIns) - witnessInputs
+	}
+	vsize := (weight + WitnessScaleFactor - 1) / WitnessScaleFactor
+	fee := uint64(math.Ceil(feeRate * float64(vsize)))
+
+	if total < fee || total-fee < DustThreshold(destination) {
+		return nil, skipped, fmt.Errorf("sweeping %d satoshis leaves %d after a fee of %d, the output needs at least %d", total, int64(total)-int64(fee), fee, DustThreshold(destination))
+	}
+	tx.TxOuts[0].Amount = total - fee
+	return tx, skipped, nil
+}
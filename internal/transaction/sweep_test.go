@@ -0,0 +1,111 @@
+package transaction
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func testCoin(i int, amount uint64, scriptPubkey *script.Script) Coin {
+	return Coin{NewOutPoint(utils.Hash256([]byte{byte(i)}), uint32(i)), NewTxOut(amount, scriptPubkey)}
+}
+
+func coinLookup(coins []Coin) PrevoutLookup {
+	return func(txIn *TxIn) (*TxOut, error) {
+		for _, coin := range coins {
+			if coin.OutPoint == txIn.PrevOut {
+				return coin.TxOut, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown prevout %s", txIn.PrevOut.DisplayString())
+	}
+}
+
+func TestDustThreshold(t *testing.T) {
+	tests := []struct {
+		name         string
+		scriptPubkey *script.Script
+		want         uint64
+	}{
+		{"p2pkh", script.CreateP2pkhScript(make([]byte, 20)), 546},
+		{"p2wpkh", script.CreateP2WPKHScript(make([]byte, 20)), 294},
+		{"p2a", script.CreateP2AScript(), 240},
+	}
+	for _, tt := range tests {
+		if got := DustThreshold(tt.scriptPubkey); got != tt.want {
+			t.Errorf("%s: DustThreshold = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSweepAll(t *testing.T) {
+	alice, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	aliceScript := script.CreateP2pkhScript(alice.Point.Hash160(true))
+	coins := []Coin{
+		testCoin(0, 20000, aliceScript),
+		testCoin(1, 100, aliceScript),
+		testCoin(2, 10000, aliceScript),
+		testCoin(3, 50000, script.CreateP2SHScript(make([]byte, 20))),
+	}
+	destination := script.CreateP2WPKHScript(make([]byte, 20))
+	feeRate := 10.0
+
+	tx, skipped, err := SweepAll(coins, destination, feeRate, true)
+	if err != nil {
+		t.Fatalf("SweepAll error: %v", err)
+	}
+	if len(tx.TxIns) != 2 || len(tx.TxOuts) != 1 {
+		t.Fatalf("Sweep has %d inputs and %d outputs, want 2 and 1", len(tx.TxIns), len(tx.TxOuts))
+	}
+	// the dust coin and the p2sh coin of which the redeem script is unknown are left out
+	if len(skipped) != 2 || skipped[0].TxOut.Amount+skipped[1].TxOut.Amount != 50100 {
+		t.Errorf("Skipped coins = %v", skipped)
+	}
+
+	// the inputs do not depend on the order of the coins
+	reversed := slices.Clone(coins)
+	slices.Reverse(reversed)
+	again, _, _ := SweepAll(reversed, destination, feeRate, true)
+	first, _ := tx.Serialize()
+	second, _ := again.Serialize()
+	if string(first) != string(second) {
+		t.Errorf("Sweeping the same coins in another order gives another transaction")
+	}
+
+	if _, err := tx.SignAll(KeyMap{0: alice, 1: alice}, coinLookup(coins)); err != nil {
+		t.Fatalf("SignAll error: %v", err)
+	}
+	fee := 30000 - tx.TxOuts[0].Amount
+	vsize, _ := tx.VSize()
+	if float64(fee) < feeRate*float64(vsize) {
+		t.Errorf("Fee of %d for %d vbytes is below %.1f sat/vB", fee, vsize, feeRate)
+	}
+	// the estimate assumes the longest signatures, it can not be far off
+	if float64(fee) > feeRate*float64(vsize+4) {
+		t.Errorf("Fee of %d for %d vbytes overestimates the size", fee, vsize)
+	}
+}
+
+func TestSweepAllErrors(t *testing.T) {
+	p2pkh := script.CreateP2pkhScript(make([]byte, 20))
+	tests := []struct {
+		name    string
+		coins   []Coin
+		feeRate float64
+	}{
+		{"all dust", []Coin{testCoin(0, 1000, p2pkh), testCoin(1, 1000, p2pkh)}, 10},
+		{"output below dust", []Coin{testCoin(0, 2400, p2pkh)}, 10},
+		{"fee rate below minimum", []Coin{testCoin(0, 100000, p2pkh)}, 0.5},
+		{"no coins", nil, 10},
+	}
+	for _, tt := range tests {
+		if _, _, err := SweepAll(tt.coins, p2pkh, tt.feeRate, true); err == nil {
+			t.Errorf("%s: SweepAll should fail", tt.name)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package transaction
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// TapscriptLeafVersion is the leaf version of BIP342 scripts
+const TapscriptLeafVersion = byte(0xc0)
+
+// annexTag is the first byte of an annex, the last witness item of a taproot input that has one
+const annexTag = 0x50
+
+// TapLeafSpend describes the script path spend a taproot signature commits to (BIP342)
+type TapLeafSpend struct {
+	// LeafHash is the TapLeafHash of the executed script
+	LeafHash []byte
+	// KeyVersion is 0 for the keys of BIP342 scripts
+	KeyVersion byte
+	// CodeSeparatorPos is the opcode position of the last executed OP_CODESEPARATOR, 0xffffffff if there is none
+	CodeSeparatorPos uint32
+}
+
+// TapLeafHash returns the hash of a tapscript leaf with the given leaf version
+func TapLeafHash(leafVersion byte, leafScript *script.Script) ([]byte, error) {
+	serialized, err := leafScript.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	return utils.TaggedHash("TapLeaf", append([]byte{leafVersion}, serialized...)), nil
+}
+
+// Annex returns the annex of a taproot witness, or nil when it has none.
+// A witness with at least two items whose last item starts with 0x50 carries one.
+func Annex(witness [][]byte) []byte {
+	if len(witness) < 2 {
+		return nil
+	}
+	if last := witness[len(witness)-1]; len(last) > 0 && last[0] == annexTag {
+		return last
+	}
+	return nil
+}
+
+// TaprootSigHash returns the BIP341 signature hash of input inputIndex. prevouts are the outputs spent by
+// all inputs, in order, since the hash commits to every amount and ScriptPubKey. annex is the annex
+// of the input or nil. leaf is nil for key path spends and describes the script for script path spends.
+func (tx *Tx) TaprootSigHash(inputIndex uint32, prevouts []*TxOut, hashType uint32, annex []byte, leaf *TapLeafSpend) ([]byte, error) {
+	switch hashType {
+	case SigHashDefault, SigHashAll, SigHashNone, SigHashSingle,
+		SigHashAll | SigHashAnyoneCanPay, SigHashNone | SigHashAnyoneCanPay, SigHashSingle | SigHashAnyoneCanPay:
+	default:
+		return nil, fmt.Errorf("invalid taproot hash type 0x%02x", hashType)
+	}
+	if int(inputIndex) >= len(tx.TxIns) {
+		return nil, inputNotFound(inputIndex)
+	}
+	if len(prevouts) != len(tx.TxIns) {
+		return nil, fmt.Errorf("got %d spent outputs for %d inputs", len(prevouts), len(tx.TxIns))
+	}
+	if annex != nil && (len(annex) == 0 || annex[0] != annexTag) {
+		return nil, fmt.Errorf("annex does not start with 0x%02x", annexTag)
+	}
+	if leaf != nil && len(leaf.LeafHash) != 32 {
+		return nil, fmt.Errorf("leaf hash is %d bytes, want 32", len(leaf.LeafHash))
+	}
+
+	anyoneCanPay := hashType&SigHashAnyoneCanPay != 0
+	outputType := hashType & 0x03
+	if outputType == SigHashDefault {
+		outputType = SigHashAll
+	}
+
+	// the epoch byte comes before the message
+	msg := []byte{0x00, byte(hashType)}
+	msg = binary.LittleEndian.AppendUint32(msg, tx.Version)
+	msg = binary.LittleEndian.AppendUint32(msg, tx.Locktime)
+
+	if !anyoneCanPay {
+		var outPoints, amounts, scriptPubkeys, sequences []byte
+		for i, txIn := range tx.TxIns {
+			outPoints = append(outPoints, txIn.PrevOut.WireBytes()...)
+			amounts = binary.LittleEndian.AppendUint64(amounts, prevouts[i].Amount)
+			serialized, err := prevouts[i].ScriptPubkey.Serialize()
+			if err != nil {
+				return nil, err
+			}
+			scriptPubkeys = append(scriptPubkeys, serialized...)
+			sequences = binary.LittleEndian.AppendUint32(sequences, txIn.Sequence)
+		}
+		msg = append(msg, utils.Sha256Hash(outPoints)...)
+		msg = append(msg, utils.Sha256Hash(amounts)...)
+		msg = append(msg, utils.Sha256Hash(scriptPubkeys)...)
+		msg = append(msg, utils.Sha256Hash(sequences)...)
+	}
+
+	if outputType == SigHashAll {
+		var outputs []byte
+		for _, txOut := range tx.TxOuts {
+			serialized, err := txOut.Serialize()
+			if err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, serialized...)
+		}
+		msg = append(msg, utils.Sha256Hash(outputs)...)
+	}
+
+	// spend_type is twice the extension flag, plus one when there is an annex
+	var spendType byte
+	if leaf != nil {
+		spendType = 2
+	}
+	if annex != nil {
+		spendType |= 1
+	}
+	msg = append(msg, spendType)
+
+	if anyoneCanPay {
+		txIn := tx.TxIns[inputIndex]
+		msg = append(msg, txIn.PrevOut.WireBytes()...)
+		msg = binary.LittleEndian.AppendUint64(msg, prevouts[inputIndex].Amount)
+		serialized, err := prevouts[inputIndex].ScriptPubkey.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		msg = append(msg, serialized...)
+		msg = binary.LittleEndian.AppendUint32(msg, txIn.Sequence)
+	} else {
+		msg = binary.LittleEndian.AppendUint32(msg, inputIndex)
+	}
+
+	if annex != nil {
+		length, err := utils.EncodeVarint(uint64(len(annex)))
+		if err != nil {
+			return nil, err
+		}
+		msg = append(msg, utils.Sha256Hash(append(length, annex...))...)
+	}
+
+	if outputType == SigHashSingle {
+		if int(inputIndex) >= len(tx.TxOuts) {
+			return nil, fmt.Errorf("SIGHASH_SINGLE input %d has no output with the same index", inputIndex)
+		}
+		serialized, err := tx.TxOuts[inputIndex].Serialize()
+		if err != nil {
+			return nil, err
+		}
+		msg = append(msg, utils.Sha256Hash(serialized)...)
+	}
+
+	if leaf != nil {
+		msg = append(msg, leaf.LeafHash...)
+		msg = append(msg, leaf.KeyVersion)
+		msg = binary.LittleEndian.AppendUint32(msg, leaf.CodeSeparatorPos)
+	}
+
+	return utils.TaggedHash("TapSighash", msg), nil
+}
@@ -0,0 +1,205 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+func newTaprootTx() (*Tx, []*TxOut) {
+	txIns := []*TxIn{
+		NewTxIn(bytes.Repeat([]byte{0x11}, 32), 0, &script.Script{}, 0xfffffffd),
+		NewTxIn(bytes.Repeat([]byte{0x22}, 32), 1, &script.Script{}, 0xffffffff),
+	}
+	txOuts := []*TxOut{
+		NewTxOut(7000, script.CreateP2TRScript(bytes.Repeat([]byte{0x33}, 32))),
+		NewTxOut(2000, script.CreateP2WPKHScript(bytes.Repeat([]byte{0x44}, 20))),
+	}
+	prevouts := []*TxOut{
+		NewTxOut(5000, script.CreateP2TRScript(bytes.Repeat([]byte{0x55}, 32))),
+		NewTxOut(5000, script.CreateP2TRScript(bytes.Repeat([]byte{0x66}, 32))),
+	}
+	return NewTx(2, txIns, txOuts, 0, false), prevouts
+}
+
+func TestTaprootSigHash(t *testing.T) {
+	tx, prevouts := newTaprootTx()
+	sigHash := func(index uint32, hashType uint32, annex []byte, leaf *TapLeafSpend) []byte {
+		t.Helper()
+		hash, err := tx.TaprootSigHash(index, prevouts, hashType, annex, leaf)
+		if err != nil {
+			t.Fatalf("TaprootSigHash(%d, 0x%02x) failed: %v", index, hashType, err)
+		}
+		if len(hash) != 32 {
+			t.Fatalf("got a %d byte hash", len(hash))
+		}
+		return hash
+	}
+
+	base := sigHash(0, SigHashDefault, nil, nil)
+	// SIGHASH_DEFAULT signs the same data as SIGHASH_ALL but commits to a different hash type byte
+	if bytes.Equal(base, sigHash(0, SigHashAll, nil, nil)) {
+		t.Error("SIGHASH_DEFAULT and SIGHASH_ALL hash the same")
+	}
+	if bytes.Equal(base, sigHash(1, SigHashDefault, nil, nil)) {
+		t.Error("the hash does not commit to the input index")
+	}
+	if bytes.Equal(base, sigHash(0, SigHashDefault, []byte{0x50, 0x01}, nil)) {
+		t.Error("the hash does not commit to the annex")
+	}
+	leaf := &TapLeafSpend{LeafHash: bytes.Repeat([]byte{0x77}, 32), CodeSeparatorPos: 0xffffffff}
+	scriptPath := sigHash(0, SigHashDefault, nil, leaf)
+	if bytes.Equal(base, scriptPath) {
+		t.Error("key and script path spends hash the same")
+	}
+	leaf.CodeSeparatorPos = 3
+	if bytes.Equal(scriptPath, sigHash(0, SigHashDefault, nil, leaf)) {
+		t.Error("the hash does not commit to the OP_CODESEPARATOR position")
+	}
+
+	// ANYONECANPAY only commits to its own input, NONE to no output, SINGLE to the output with the same index
+	anyoneCanPay := sigHash(0, SigHashAll|SigHashAnyoneCanPay, nil, nil)
+	none := sigHash(0, SigHashNone, nil, nil)
+	single := sigHash(1, SigHashSingle, nil, nil)
+
+	tx.TxIns[1].Sequence = 0
+	prevouts[1].Amount = 6000
+	if !bytes.Equal(anyoneCanPay, sigHash(0, SigHashAll|SigHashAnyoneCanPay, nil, nil)) {
+		t.Error("ANYONECANPAY commits to the other inputs")
+	}
+	if bytes.Equal(none, sigHash(0, SigHashNone, nil, nil)) {
+		t.Error("SIGHASH_NONE does not commit to the other inputs")
+	}
+	tx.TxIns[1].Sequence, prevouts[1].Amount = 0xffffffff, 5000
+
+	tx.TxOuts[0].Amount = 6500
+	if !bytes.Equal(none, sigHash(0, SigHashNone, nil, nil)) {
+		t.Error("SIGHASH_NONE commits to the outputs")
+	}
+	if !bytes.Equal(single, sigHash(1, SigHashSingle, nil, nil)) {
+		t.Error("SIGHASH_SINGLE commits to the other outputs")
+	}
+	if bytes.Equal(anyoneCanPay, sigHash(0, SigHashAll|SigHashAnyoneCanPay, nil, nil)) {
+		t.Error("SIGHASH_ALL|ANYONECANPAY does not commit to the outputs")
+	}
+}
+
+// TestTaprootSigHashBIP341Vectors checks the key path spends of the wallet test vectors of BIP341
+func TestTaprootSigHashBIP341Vectors(t *testing.T) {
+	rawTx, _ := hex.DecodeString("02000000097de20cbff686da83a54981d2b9bab3586f4ca7e48f57f5b55963115f3b334e9c010000000000000000d7b7cab57b1393ace2d064f4d4a2cb8af6def61273e127517d44759b6dafdd990000000000fffffffff8e1f583384333689228c5d28eac13366be082dc57441760d957275419a418420000000000fffffffff0689180aa63b30cb162a73c6d2a38b7eeda2a83ece74310fda0843ad604853b0100000000feffffffaa5202bdf6d8ccd2ee0f0202afbbb7461d9264a25e5bfd3c5a52ee1239e0ba6c0000000000feffffff956149bdc66faa968eb2be2d2faa29718acbfe3941215893a2a3446d32acd050000000000000000000e664b9773b88c09c32cb70a2a3e4da0ced63b7ba3b22f848531bbb1d5d5f4c94010000000000000000e9aa6b8e6c9de67619e6a3924ae25696bb7b694bb677a632a74ef7eadfd4eabf0000000000ffffffffa778eb6a263dc090464cd125c466b5a99667720b1c110468831d058aa1b82af10100000000ffffffff0200ca9a3b000000001976a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac807840cb0000000020ac9a87f5594be208f8532db38cff670c450ed2fea8fcdefcc9a663f78bab962b0065cd1d")
+	// the second output is 32 bytes that do not parse as a script
+	tx, err := ParseTxLenient(bufio.NewReader(bytes.NewReader(rawTx)), false)
+	if err != nil {
+		t.Fatalf("ParseTxLenient failed: %v", err)
+	}
+
+	utxosSpent := []struct {
+		scriptPubkey string
+		amount       uint64
+	}{
+		{"512053a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343", 420000000},
+		{"5120147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3", 462000000},
+		{"76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", 294000000},
+		{"5120e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e", 504000000},
+		{"512091b64d5324723a985170e4dc5a0f84c041804f2cd12660fa5dec09fc21783605", 630000000},
+		{"00147dd65592d0ab2fe0d0257d571abf032cd9db93dc", 378000000},
+		{"512075169f4001aa68f15bbed28b218df1d0a62cbbcf1188c6665110c293c907b831", 672000000},
+		{"5120712447206d7a5238acc7ff53fbe94a3b64539ad291c7cdbc490b7577e4b17df5", 546000000},
+		{"512077e30a5522dd9f894c3f8b8bd4c4b2cf82ca7da8a3ea6a239655c39c050ab220", 588000000},
+	}
+	var prevouts []*TxOut
+	for _, utxo := range utxosSpent {
+		raw, _ := hex.DecodeString(utxo.scriptPubkey)
+		scriptPubkey, err := script.ParseRawScript(raw)
+		if err != nil {
+			t.Fatalf("ScriptPubKey %s does not parse: %v", utxo.scriptPubkey, err)
+		}
+		prevouts = append(prevouts, NewTxOut(utxo.amount, scriptPubkey))
+	}
+
+	tests := []struct {
+		inputIndex uint32
+		hashType   uint32
+		sigHash    string
+	}{
+		{0, SigHashSingle, "2514a6272f85cfa0f45eb907fcb0d121b808ed37c6ea160a5a9046ed5526d555"},
+		{1, SigHashSingle | SigHashAnyoneCanPay, "325a644af47e8a5a2591cda0ab0723978537318f10e6a63d4eed783b96a71a4d"},
+		{3, SigHashAll, "bf013ea93474aa67815b1b6cc441d23b64fa310911d991e713cd34c7f5d46669"},
+		{4, SigHashDefault, "4f900a0bae3f1446fd48490c2958b5a023228f01661cda3496a11da502a7f7ef"},
+		{6, SigHashNone, "15f25c298eb5cdc7eb1d638dd2d45c97c4c59dcaec6679cfc16ad84f30876b85"},
+		{7, SigHashNone | SigHashAnyoneCanPay, "cd292de50313804dabe4685e83f923d2969577191a3e1d2882220dca88cbeb10"},
+		{8, SigHashAll | SigHashAnyoneCanPay, "cccb739eca6c13a8a89e6e5cd317ffe55669bbda23f2fd37b0f18755e008edd2"},
+	}
+	for _, tt := range tests {
+		sigHash, err := tx.TaprootSigHash(tt.inputIndex, prevouts, tt.hashType, nil, nil)
+		if err != nil {
+			t.Errorf("TaprootSigHash(%d, 0x%02x) failed: %v", tt.inputIndex, tt.hashType, err)
+			continue
+		}
+		if got := hex.EncodeToString(sigHash); got != tt.sigHash {
+			t.Errorf("TaprootSigHash(%d, 0x%02x) = %s, want %s", tt.inputIndex, tt.hashType, got, tt.sigHash)
+		}
+	}
+}
+
+func TestTaprootSigHashErrors(t *testing.T) {
+	tx, prevouts := newTaprootTx()
+	tests := []struct {
+		name     string
+		index    uint32
+		prevouts []*TxOut
+		hashType uint32
+		annex    []byte
+		leaf     *TapLeafSpend
+	}{
+		{"hash type", 0, prevouts, 0x04, nil, nil},
+		{"anyonecanpay default", 0, prevouts, SigHashAnyoneCanPay, nil, nil},
+		{"input", 2, prevouts, SigHashDefault, nil, nil},
+		{"prevouts", 0, prevouts[:1], SigHashDefault, nil, nil},
+		{"annex", 0, prevouts, SigHashDefault, []byte{0x51}, nil},
+		{"leaf hash", 0, prevouts, SigHashDefault, nil, &TapLeafSpend{LeafHash: []byte{0x01}}},
+	}
+	for _, tt := range tests {
+		if _, err := tx.TaprootSigHash(tt.index, tt.prevouts, tt.hashType, tt.annex, tt.leaf); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	tx.TxOuts = tx.TxOuts[:1]
+	if _, err := tx.TaprootSigHash(1, prevouts, SigHashSingle, nil, nil); err == nil {
+		t.Error("expected SIGHASH_SINGLE without a matching output to fail")
+	}
+}
+
+func TestAnnex(t *testing.T) {
+	tests := []struct {
+		witness [][]byte
+		want    []byte
+	}{
+		{nil, nil},
+		// a single item is the signature even if it starts with 0x50
+		{[][]byte{{0x50, 0x01}}, nil},
+		{[][]byte{{0x01}, {0x50, 0x02}}, []byte{0x50, 0x02}},
+		{[][]byte{{0x01}, {0x51}}, nil},
+	}
+	for _, tt := range tests {
+		if got := Annex(tt.witness); !bytes.Equal(got, tt.want) {
+			t.Errorf("Annex(%x) = %x, want %x", tt.witness, got, tt.want)
+		}
+	}
+}
+
+func TestTapLeafHash(t *testing.T) {
+	leafScript := &script.Script{bytes.Repeat([]byte{0x01}, 32), {0xac}}
+	hash, err := TapLeafHash(TapscriptLeafVersion, leafScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := TapLeafHash(TapscriptLeafVersion+2, leafScript)
+	if len(hash) != 32 || bytes.Equal(hash, other) {
+		t.Errorf("leaf hashes %x and %x", hash, other)
+	}
+}
@@ -5,20 +5,30 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"math/big"
 	"net/http"
-	"os"
 	"slices"
+	"strings"
+	"sync"
 
+	"github.com/caspereijkens/cryptocurrency/internal/params"
 	"github.com/caspereijkens/cryptocurrency/internal/script"
 	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
 	"github.com/caspereijkens/cryptocurrency/internal/utils"
 )
 
-const SigHashAll = uint32(1)
+const SigHashAll = uint32(signatureverification.SigHashAll)
+
+// The other hash types. SigHashDefault only exists for taproot, where it signs like SigHashAll.
+const (
+	SigHashDefault      = uint32(signatureverification.SigHashDefault)
+	SigHashNone         = uint32(signatureverification.SigHashNone)
+	SigHashSingle       = uint32(signatureverification.SigHashSingle)
+	SigHashAnyoneCanPay = uint32(signatureverification.SigHashAnyoneCanPay)
+)
 
 type Tx struct {
 	Version  uint32
@@ -38,21 +48,97 @@ func NewTx(version uint32, txIns []*TxIn, txOuts []*TxOut, locktime uint32, test
 	}
 }
 
+// Copy returns a deep copy of the transaction that shares no inputs, outputs, scripts or witnesses with tx
+func (tx *Tx) Copy() *Tx {
+	txIns := make([]*TxIn, len(tx.TxIns))
+	for i, txIn := range tx.TxIns {
+		txIns[i] = txIn.Copy()
+	}
+	txOuts := make([]*TxOut, len(tx.TxOuts))
+	for i, txOut := range tx.TxOuts {
+		txOuts[i] = txOut.Copy()
+	}
+	return NewTx(tx.Version, txIns, txOuts, tx.Locktime, tx.Testnet)
+}
+
+// String describes the transaction, with the amounts and the fee when the outputs its inputs spend were resolved
+// before, by Fee or Verify for example. It never fetches them itself.
 func (tx *Tx) String() string {
-	txInsStr := ""
+	return tx.StringWith(func(txIn *TxIn) (*TxOut, error) {
+		if txIn.prevout == nil || txIn.prevoutTestnet != tx.Testnet {
+			return nil, fmt.Errorf("output %s was not resolved", txIn.PrevOut.DisplayString())
+		}
+		return txIn.prevout, nil
+	})
+}
+
+// Invalidate drops the spent outputs the inputs of tx kept, see TxIn.Prevout
+func (tx *Tx) Invalidate() {
 	for _, txIn := range tx.TxIns {
-		txInsStr += fmt.Sprintf("%s\n", txIn.String())
-	}
-	txOutsStr := ""
-	for _, txOut := range tx.TxOuts {
-		txOutsStr += fmt.Sprintf("%s\n", txOut.String())
+		txIn.Invalidate()
 	}
+}
+
+// PrevoutLookup returns the output spent by txIn
+type PrevoutLookup func(txIn *TxIn) (*TxOut, error)
+
+// StringWith is String with the amount and address of every input, and the fee and fee rate,
+// for which lookup provides the spent outputs. Inputs it cannot resolve are shown as before,
+// the others are still resolved and only the fee is left out. A nil lookup resolves nothing.
+func (tx *Tx) StringWith(lookup PrevoutLookup) string {
 	id, err := tx.Id()
 	if err != nil {
 		return ""
 	}
-	return fmt.Sprintf("tx: %s\nversion: %d\ntx_ins:\n%s\n"+
+
+	txInsStr := ""
+	var inputSum uint64
+	lookingUp := lookup != nil && !tx.IsCoinbase()
+	resolved := lookingUp
+	for _, txIn := range tx.TxIns {
+		if !lookingUp {
+			txInsStr += fmt.Sprintf("%s\n", txIn.String())
+			continue
+		}
+		prevout, err := lookup(txIn)
+		if err != nil {
+			resolved = false
+			txInsStr += fmt.Sprintf("%s\n", txIn.String())
+			continue
+		}
+		inputSum += prevout.Amount
+		txInsStr += fmt.Sprintf("%s %s\n", txIn.String(), describeTxOut(prevout, tx.Testnet))
+	}
+
+	txOutsStr := ""
+	var outputSum uint64
+	for _, txOut := range tx.TxOuts {
+		outputSum += txOut.Amount
+		txOutsStr += fmt.Sprintf("%s\n", describeTxOut(txOut, tx.Testnet))
+	}
+
+	result := fmt.Sprintf("tx: %s\nversion: %d\ntx_ins:\n%s\n"+
 		"tx_outs:\n%s\nlocktime: %d", id, tx.Version, txInsStr, txOutsStr, tx.Locktime)
+
+	if resolved && inputSum >= outputSum {
+		fee := inputSum - outputSum
+		if vsize, err := tx.VSize(); err == nil {
+			result += fmt.Sprintf("\nfee: %s (%.2f sat/vB)", utils.FormatWithUnderscore(int(fee)), float64(fee)/float64(vsize))
+		}
+	}
+
+	return result
+}
+
+// describeTxOut returns the String of txOut followed by its address, if it has one, or its m-of-n for a bare multisig
+func describeTxOut(txOut *TxOut, testnet bool) string {
+	if address, err := txOut.Address(testnet); err == nil {
+		return fmt.Sprintf("%s %s", txOut.String(), address)
+	}
+	if required, keys, ok := txOut.ScriptPubkey.ParseMultisig(); ok {
+		return fmt.Sprintf("%s %d-of-%d multisig", txOut.String(), required, len(keys))
+	}
+	return txOut.String()
 }
 
 func (tx *Tx) Id() (string, error) {
@@ -63,8 +149,17 @@ func (tx *Tx) Id() (string, error) {
 	return hex.EncodeToString(hash256), nil
 }
 
+// Txid returns the hash of the transaction as a number, which prints as its id
+func (tx *Tx) Txid() (utils.Uint256, error) {
+	s, err := tx.SerializeLegacy()
+	if err != nil {
+		return utils.Uint256{}, err
+	}
+	return utils.Uint256FromDigest(utils.Hash256(s))
+}
+
 func (tx *Tx) Hash() ([]byte, error) {
-	s, err := tx.Serialize()
+	s, err := tx.SerializeLegacy()
 	if err != nil {
 		return nil, err
 	}
@@ -75,54 +170,162 @@ func (tx *Tx) Hash() ([]byte, error) {
 }
 
 func ParseTx(reader *bufio.Reader, testnet bool) (*Tx, error) {
-	// version is an integer in 4 bytes, little-endian
-	var version uint32
-	if err := binary.Read(reader, binary.LittleEndian, &version); err != nil {
-		return nil, err
-	}
+	return parseTx(reader, testnet, false)
+}
+
+// ParseTxLenient is ParseTx for historical transactions with scripts that do not parse: they are kept as opaque
+// scripts with script.ParseScriptLenient, so the transaction still serializes to the same bytes, prints, and its
+// other inputs can be verified. An input with an opaque ScriptSig or spending an opaque ScriptPubKey fails to verify.
+func ParseTxLenient(reader *bufio.Reader, testnet bool) (*Tx, error) {
+	return parseTx(reader, testnet, true)
+}
 
-	numInputs, err := utils.ReadVarint(reader)
+func parseTx(reader *bufio.Reader, testnet, lenient bool) (*Tx, error) {
+	stream, err := NewTxStream(reader, testnet)
 	if err != nil {
-		return nil, err
+		return nil, malformedTx(err)
 	}
+	stream.Lenient = lenient
 
-	inputs := make([]*TxIn, 0, numInputs)
-	for i := 0; i < int(numInputs); i++ {
-		txIn, err := ParseTxIn(reader)
+	inputs := make([]*TxIn, 0, stream.NumInputs)
+	for {
+		txIn, err := stream.NextInput()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return nil, err
+			return nil, malformedTx(err)
 		}
 		inputs = append(inputs, txIn)
 	}
 
-	numOutputs, err := utils.ReadVarint(reader)
+	// parse num_outputs number of TransactionOutputs
+	var outputs []*TxOut
+	for {
+		txOut, err := stream.NextOutput()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, malformedTx(err)
+		}
+		outputs = append(outputs, txOut)
+	}
+
+	locktime, err := stream.Finish(func(index uint64, items [][]byte) {
+		inputs[index].Witness = items
+	})
+	if err != nil {
+		return nil, malformedTx(err)
+	}
+
+	return NewTx(stream.Version, inputs, outputs, locktime, testnet), nil
+}
+
+// TrailingBytesError is returned by ParseTxStrict when bytes are left after the locktime
+type TrailingBytesError struct {
+	Count int
+}
+
+func (e *TrailingBytesError) Error() string {
+	return fmt.Sprintf("%d trailing bytes after the transaction", e.Count)
+}
+
+// Unwrap makes errors.Is match ErrMalformedTx
+func (e *TrailingBytesError) Unwrap() error {
+	return ErrMalformedTx
+}
+
+// ParseTxStrict parses a single serialized transaction and fails with a *TrailingBytesError when raw
+// holds more than that, such as a corrupted or concatenated input. ParseTx reads one transaction
+// from a stream and leaves whatever follows for the caller.
+func ParseTxStrict(raw []byte, testnet bool) (*Tx, error) {
+	source := bytes.NewReader(raw)
+	reader := bufio.NewReader(source)
+	tx, err := ParseTx(reader, testnet)
 	if err != nil {
 		return nil, err
 	}
+	if trailing := reader.Buffered() + source.Len(); trailing > 0 {
+		return nil, &TrailingBytesError{Count: trailing}
+	}
+	return tx, nil
+}
 
-	// parse num_outputs number of TransactionOutputs
-	outputs := make([]*TxOut, 0, numOutputs)
-	for i := 0; i < int(numOutputs); i++ {
-		txOut, err := ParseTxOut(reader)
+// parseWitness reads the stack items of the witness of one input
+func parseWitness(reader *bufio.Reader) ([][]byte, error) {
+	numItems, err := readCount(reader, MaxWitnessItems, "witness items")
+	if err != nil {
+		return nil, err
+	}
+
+	witness := make([][]byte, 0, min(numItems, 1024))
+	for i := 0; i < int(numItems); i++ {
+		length, err := readCount(reader, maxWitnessItemLen, "witness item bytes")
 		if err != nil {
 			return nil, err
 		}
-		outputs = append(outputs, txOut)
+		item := make([]byte, length)
+		if _, err := io.ReadFull(reader, item); err != nil {
+			return nil, err
+		}
+		witness = append(witness, item)
 	}
 
-	// locktime is an integer in 4 bytes, little-endian
-	var locktime uint32
-	if err := binary.Read(reader, binary.LittleEndian, &locktime); err != nil {
-		return nil, err
-	}
+	return witness, nil
+}
 
-	return NewTx(version, inputs, outputs, locktime, testnet), nil
+// HasWitness returns whether any of the inputs carries witness data
+func (tx *Tx) HasWitness() bool {
+	for _, txIn := range tx.TxIns {
+		if len(txIn.Witness) > 0 {
+			return true
+		}
+	}
+	return false
 }
 
+// Serialize returns the network serialization, which includes the witness data when there is any
 func (tx *Tx) Serialize() ([]byte, error) {
+	return tx.serialize(tx.HasWitness())
+}
+
+// SerializeLegacy returns the serialization without witness data, which is what the txid commits to
+func (tx *Tx) SerializeLegacy() ([]byte, error) {
+	return tx.serialize(false)
+}
+
+// Weight returns the BIP141 weight: three times the legacy size plus the full size
+func (tx *Tx) Weight() (int, error) {
+	legacy, err := tx.SerializeLegacy()
+	if err != nil {
+		return 0, err
+	}
+	full, err := tx.Serialize()
+	if err != nil {
+		return 0, err
+	}
+	return len(legacy)*(WitnessScaleFactor-1) + len(full), nil
+}
+
+// VSize returns the virtual size in vbytes, the weight divided by four and rounded up
+func (tx *Tx) VSize() (int, error) {
+	weight, err := tx.Weight()
+	if err != nil {
+		return 0, err
+	}
+	return (weight + WitnessScaleFactor - 1) / WitnessScaleFactor, nil
+}
+
+func (tx *Tx) serialize(withWitness bool) ([]byte, error) {
 	result := make([]byte, 4)
 	binary.LittleEndian.PutUint32(result, tx.Version)
 
+	if withWitness {
+		// marker and flag
+		result = append(result, 0x00, 0x01)
+	}
+
 	numInputs, err := utils.EncodeVarint(uint64(len(tx.TxIns)))
 	if err != nil {
 		return nil, err
@@ -153,6 +356,16 @@ func (tx *Tx) Serialize() ([]byte, error) {
 		result = append(result, serializedTxOut...)
 	}
 
+	if withWitness {
+		for _, txIn := range tx.TxIns {
+			serializedWitness, err := serializeWitness(txIn.Witness)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, serializedWitness...)
+		}
+	}
+
 	locktimeBytes := make([]byte, 4)
 	binary.LittleEndian.PutUint32(locktimeBytes, tx.Locktime)
 	result = append(result, locktimeBytes...)
@@ -160,9 +373,26 @@ func (tx *Tx) Serialize() ([]byte, error) {
 	return result, nil
 }
 
+func serializeWitness(witness [][]byte) ([]byte, error) {
+	result, err := utils.EncodeVarint(uint64(len(witness)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range witness {
+		length, err := utils.EncodeVarint(uint64(len(item)))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, length...)
+		result = append(result, item...)
+	}
+
+	return result, nil
+}
+
 func (tx *Tx) Fee() (uint64, error) {
-	// initialize input sum and output sum
-	var inputSum, outputSum uint64
+	var inputSum uint64
 
 	// use TransactionInput.Value() to sum up the input amounts
 	for _, txIn := range tx.TxIns {
@@ -173,11 +403,7 @@ func (tx *Tx) Fee() (uint64, error) {
 		inputSum += value
 	}
 
-	// use TransactionOutput.Amount to sum up the output amounts
-	for _, txOut := range tx.TxOuts {
-		outputSum += txOut.Amount
-	}
-
+	outputSum := tx.TotalOutput()
 	if outputSum > inputSum {
 		return 0, fmt.Errorf("output is larger than input, which is not allowed")
 	}
@@ -188,25 +414,54 @@ func (tx *Tx) Fee() (uint64, error) {
 
 // Returns the integer representation of the hash that needs to get signed for index input_index
 func (tx *Tx) SigHash(inputIndex uint32, redeemScript *script.Script) (*big.Int, error) {
-	var scriptSig = &script.Script{}
+	preimage, err := tx.SigHashPreimage(inputIndex, redeemScript, SigHashAll)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(utils.Hash256(preimage)), nil
+}
+
+// SigHashPreimage returns the bytes of which the double sha256 is the legacy signature hash of input inputIndex
+// with hashType: the transaction with the ScriptSig of that input replaced by redeemScript, or by the ScriptPubKey
+// it spends when redeemScript is nil, the other ScriptSigs empty and the hash type appended. SIGHASH_NONE and
+// SIGHASH_SINGLE drop the outputs that are not signed and zero the sequences of the other inputs,
+// SIGHASH_ANYONECANPAY keeps only the signed input. SIGHASH_SINGLE without a matching output has no preimage.
+func (tx *Tx) SigHashPreimage(inputIndex uint32, redeemScript *script.Script, hashType uint32) ([]byte, error) {
+	if int(inputIndex) >= len(tx.TxIns) {
+		return nil, inputNotFound(inputIndex)
+	}
+	baseType := hashType & 0x1f
+	if baseType == SigHashSingle && int(inputIndex) >= len(tx.TxOuts) {
+		return nil, fmt.Errorf("SIGHASH_SINGLE input %d has no output to sign", inputIndex)
+	}
+	scriptSig, err := getScriptSig(tx.TxIns[inputIndex], tx.Testnet, redeemScript)
+	if err != nil {
+		return nil, err
+	}
+
+	txIns := tx.TxIns
+	signedIndex := int(inputIndex)
+	if hashType&SigHashAnyoneCanPay != 0 {
+		txIns, signedIndex = tx.TxIns[inputIndex:inputIndex+1], 0
+	}
 
 	result := make([]byte, 4)
 	binary.LittleEndian.PutUint32(result, tx.Version)
 
-	numInputs, err := utils.EncodeVarint(uint64(len(tx.TxIns)))
+	numInputs, err := utils.EncodeVarint(uint64(len(txIns)))
 	if err != nil {
 		return nil, err
 	}
 	result = append(result, numInputs...)
 
-	for i, txIn := range tx.TxIns {
-		if i == int(inputIndex) {
-			scriptSig, err = getScriptSig(txIn, tx.Testnet, redeemScript)
-			if err != nil {
-				return nil, err
-			}
+	for i, txIn := range txIns {
+		txInModified := NewTxInFromOutPoint(txIn.PrevOut, &script.Script{}, txIn.Sequence)
+		if i == signedIndex {
+			txInModified.ScriptSig = scriptSig
+		} else if baseType == SigHashNone || baseType == SigHashSingle {
+			// the other inputs may be updated without invalidating the signature
+			txInModified.Sequence = 0
 		}
-		txInModified := NewTxIn(txIn.PrevTx, txIn.PrevIndex, scriptSig, txIn.Sequence)
 		txInModifiedBytes, err := txInModified.Serialize()
 		if err != nil {
 			return nil, err
@@ -214,14 +469,27 @@ func (tx *Tx) SigHash(inputIndex uint32, redeemScript *script.Script) (*big.Int,
 		result = append(result, txInModifiedBytes...)
 	}
 
-	numOutputs, err := utils.EncodeVarint(uint64(len(tx.TxOuts)))
+	txOuts := tx.TxOuts
+	switch baseType {
+	case SigHashNone:
+		txOuts = nil
+	case SigHashSingle:
+		// the outputs before the one of the input are blanked to an amount of -1 and an empty script
+		txOuts = make([]*TxOut, inputIndex+1)
+		for i := range txOuts[:inputIndex] {
+			txOuts[i] = NewTxOut(math.MaxUint64, &script.Script{})
+		}
+		txOuts[inputIndex] = tx.TxOuts[inputIndex]
+	}
+
+	numOutputs, err := utils.EncodeVarint(uint64(len(txOuts)))
 	if err != nil {
 		return nil, err
 	}
 
 	result = append(result, numOutputs...)
 
-	for _, txOut := range tx.TxOuts {
+	for _, txOut := range txOuts {
 		serializedTxOut, err := txOut.Serialize()
 		if err != nil {
 			return nil, err
@@ -233,13 +501,11 @@ func (tx *Tx) SigHash(inputIndex uint32, redeemScript *script.Script) (*big.Int,
 	binary.LittleEndian.PutUint32(locktimeBytes, tx.Locktime)
 	result = append(result, locktimeBytes...)
 
-	hashType := make([]byte, 4)
-	binary.LittleEndian.PutUint32(hashType, SigHashAll)
-	result = append(result, hashType...)
+	hashTypeBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(hashTypeBytes, hashType)
+	result = append(result, hashTypeBytes...)
 
-	resultHash256 := utils.Hash256(result)
-
-	return new(big.Int).SetBytes(resultHash256), nil
+	return result, nil
 }
 
 func getScriptSig(txIn *TxIn, testnet bool, redeemScript *script.Script) (*script.Script, error) {
@@ -258,34 +524,103 @@ func getScriptSig(txIn *TxIn, testnet bool, redeemScript *script.Script) (*scrip
 
 // Returns whether the input has a valid signature
 func (tx *Tx) VerifyInput(index uint32) bool {
-	var redeemScript *script.Script
-
-	txIn := tx.TxIns[index]
-	scriptPubkey, err := txIn.ScriptPubkey(tx.Testnet)
+	scriptPubkey, err := tx.TxIns[index].ScriptPubkey(tx.Testnet)
 	if err != nil {
 		return false
 	}
+	return tx.VerifyInputWith(index, scriptPubkey, script.StandardVerifyFlags) == nil
+}
 
-	if scriptPubkey.IsP2SHScriptPubKey() {
-		cmd := (*txIn.ScriptSig)[len(*txIn.ScriptSig)-1]
-		varInt, err := utils.EncodeVarint(uint64(len(cmd)))
-		if err != nil {
-			return false
+// VerifyInputWith verifies the input against the ScriptPubKey of the output it spends, applying the rules in flags
+func (tx *Tx) VerifyInputWith(index uint32, scriptPubkey *script.Script, flags script.VerificationFlags) error {
+	return tx.VerifyInputCached(index, scriptPubkey, flags, VerifyCaches{})
+}
+
+// VerifyCaches are the caches input verification consults and fills, either may be nil
+type VerifyCaches struct {
+	// Scripts skips the whole script execution of inputs that passed before
+	Scripts *ScriptCache
+	// Signatures skips the signature checks of signatures that verified before, in any input
+	Signatures *signatureverification.SigCache
+}
+
+// VerifyInputCached is VerifyInputWith that consults caches: an input caches.Scripts holds a successful
+// validation of is not executed, and signatures found in caches.Signatures are not verified again.
+func (tx *Tx) VerifyInputCached(index uint32, scriptPubkey *script.Script, flags script.VerificationFlags, caches VerifyCaches) error {
+	if int(index) >= len(tx.TxIns) {
+		return inputNotFound(index)
+	}
+	txIn := tx.TxIns[index]
+
+	// the signatures commit to the redeem script of p2sh outputs, to the ScriptPubKey otherwise
+	signedScript := scriptPubkey
+	if flags.Has(script.ScriptVerifyP2SH) && scriptPubkey.IsP2SHScriptPubKey() {
+		if len(*txIn.ScriptSig) == 0 {
+			return &InputError{Index: int(index), Err: fmt.Errorf("spends a p2sh output without a redeem script")}
+		}
+		if !txIn.ScriptSig.IsPushOnly() {
+			return &InputError{Index: int(index), Err: fmt.Errorf("ScriptSig spending a p2sh output is %w", script.ErrNotPushOnly)}
 		}
-		redeemScriptBytes := append(varInt, cmd...)
-		redeemScript, err = script.ParseScript(bufio.NewReader(bytes.NewReader(redeemScriptBytes)))
+		redeemScript, err := script.ParseRawScript((*txIn.ScriptSig)[len(*txIn.ScriptSig)-1])
 		if err != nil {
-			return false
+			return &InputError{Index: int(index), Err: fmt.Errorf("redeem script: %w", err)}
 		}
+		signedScript = redeemScript
 	}
-	z, err := tx.SigHash(index, redeemScript)
+
+	z, err := tx.SigHash(index, signedScript)
 	if err != nil {
-		return false
+		return err
+	}
+	if caches.Scripts == nil {
+		return tx.executeInput(index, scriptPubkey, signedScript, z, flags, caches.Signatures)
+	}
+
+	key, err := scriptCacheKey(txIn, scriptPubkey, z, flags)
+	if err != nil {
+		return err
 	}
+	if caches.Scripts.contains(key) {
+		return nil
+	}
+	if err := tx.executeInput(index, scriptPubkey, signedScript, z, flags, caches.Signatures); err != nil {
+		return err
+	}
+	caches.Scripts.add(key)
+	return nil
+}
 
-	combinedScript := txIn.ScriptSig.Add(scriptPubkey)
+// executeInput runs the ScriptSig of inputIndex against scriptPubkey with the locktime, sequence and version of tx.
+// z is the SIGHASH_ALL hash of signedScript, signatures of other hash types are checked against the hash of theirs.
+func (tx *Tx) executeInput(inputIndex uint32, scriptPubkey, signedScript *script.Script, z *big.Int, flags script.VerificationFlags, sigCache *signatureverification.SigCache) error {
+	txIn := tx.TxIns[inputIndex]
+	ctx := &script.ExecutionContext{
+		Z: z,
+		SigHash: func(hashType signatureverification.SigHashType) (*big.Int, error) {
+			if uint32(hashType) == SigHashAll {
+				return z, nil
+			}
+			if uint32(hashType)&0x1f == SigHashSingle && int(inputIndex) >= len(tx.TxOuts) {
+				// consensus signs the number 1 instead, a bug of the original client
+				return big.NewInt(1), nil
+			}
+			preimage, err := tx.SigHashPreimage(inputIndex, signedScript, uint32(hashType))
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Int).SetBytes(utils.Hash256(preimage)), nil
+		},
+		Locktime: int(tx.Locktime),
+		Sequence: int(txIn.Sequence),
+		Version:  int(tx.Version),
+		Flags:    flags,
+		SigCache: sigCache,
+	}
 
-	return combinedScript.Evaluate(z)
+	if err := script.VerifyScript(txIn.ScriptSig, scriptPubkey, ctx); err != nil {
+		return &InputError{Index: int(inputIndex), Err: err}
+	}
+	return nil
 }
 
 // Verify this transaction
@@ -334,15 +669,7 @@ func (tx *Tx) IsCoinbase() bool {
 
 	firstInput := tx.TxIns[0]
 
-	if !bytes.Equal(firstInput.PrevTx, make([]byte, 32)) {
-		return false
-	}
-
-	if firstInput.PrevIndex != 0xffffffff {
-		return false
-	}
-
-	return true
+	return firstInput.PrevOut.IsNull()
 }
 
 func (tx *Tx) CoinbaseHeight() (uint32, error) {
@@ -355,55 +682,82 @@ func (tx *Tx) CoinbaseHeight() (uint32, error) {
 	}
 
 	element := (*tx.TxIns[0].ScriptSig)[0]
-
-	for len(element) < 4 {
-		element = append(element, 0)
+	if len(element) > 4 {
+		return 0, fmt.Errorf("coinbase height push of %d bytes is too long", len(element))
 	}
 
-	height := binary.LittleEndian.Uint32(element)
+	// the element shares its bytes with the rest of the parsed script, pad a copy
+	var padded [4]byte
+	copy(padded[:], element)
+	height := binary.LittleEndian.Uint32(padded[:])
 
 	return height, nil
 }
 
 // TxIn represents a transaction input
 type TxIn struct {
-	PrevTx    []byte
-	PrevIndex uint32
+	PrevOut   OutPoint
 	ScriptSig *script.Script
 	Sequence  uint32
+	// Witness holds the witness stack items of a segwit input
+	Witness [][]byte
+
+	// prevout is the output the input spends once Prevout resolved it on the network of prevoutTestnet
+	prevout        *TxOut
+	prevoutTestnet bool
 }
 
-// NewTxIn creates a new TxIn instance
+// NewTxIn creates a new TxIn instance spending output prevIndex of the transaction with hash prevTx,
+// in display byte order as returned by Tx.Hash
 func NewTxIn(prevTx []byte, prevIndex uint32, scriptSig *script.Script, sequence uint32) *TxIn {
+	return NewTxInFromOutPoint(NewOutPoint(prevTx, prevIndex), scriptSig, sequence)
+}
+
+// NewTxInFromOutPoint creates a new TxIn instance spending prevOut
+func NewTxInFromOutPoint(prevOut OutPoint, scriptSig *script.Script, sequence uint32) *TxIn {
 	return &TxIn{
-		PrevTx:    prevTx,
-		PrevIndex: prevIndex,
+		PrevOut:   prevOut,
 		ScriptSig: scriptSig,
 		Sequence:  sequence,
 	}
 }
 
+// Copy returns a deep copy of the input
+func (txIn *TxIn) Copy() *TxIn {
+	copied := NewTxInFromOutPoint(txIn.PrevOut, txIn.ScriptSig.Copy(), txIn.Sequence)
+	if txIn.prevout != nil {
+		copied.prevout, copied.prevoutTestnet = txIn.prevout.Copy(), txIn.prevoutTestnet
+	}
+	if txIn.Witness != nil {
+		copied.Witness = make([][]byte, len(txIn.Witness))
+		for i, item := range txIn.Witness {
+			copied.Witness[i] = append([]byte{}, item...)
+		}
+	}
+	return copied
+}
+
+// PrevTx returns the hash of the previous transaction in display byte order
+func (txIn *TxIn) PrevTx() []byte {
+	return txIn.PrevOut.DisplayTxid()
+}
+
 // String returns a string representation of TxIn
 func (txIn *TxIn) String() string {
-	return fmt.Sprintf("%s:%d", hex.EncodeToString(txIn.PrevTx), txIn.PrevIndex)
+	return txIn.PrevOut.DisplayString()
 }
 
 // ParseTxIn parses a byte stream and returns a TxIn object
-// Possible IP: seems like because of historical reasons, the prevTxId was reversed: https://learnmeabitcoin.com/technical/txid
 func ParseTxIn(reader *bufio.Reader) (*TxIn, error) {
-	// prev_tx is 32 bytes, little endian
-	prevTX := make([]byte, 32)
-	if _, err := io.ReadFull(reader, prevTX); err != nil {
-		return nil, err
-	}
-	slices.Reverse(prevTX)
-	// prevIndex is an integer in 4 bytes, little endian
-	var prevIndex uint32
-	if err := binary.Read(reader, binary.LittleEndian, &prevIndex); err != nil {
+	return parseTxIn(reader, script.ParseScript)
+}
+
+func parseTxIn(reader *bufio.Reader, parseScript func(reader *bufio.Reader) (*script.Script, error)) (*TxIn, error) {
+	prevOut, err := ParseOutPoint(reader)
+	if err != nil {
 		return nil, err
 	}
-	// use script.ParseScript to get the ScriptSig
-	scriptSig, err := script.ParseScript(reader)
+	scriptSig, err := parseScript(reader)
 	if err != nil {
 		return nil, err
 	}
@@ -413,23 +767,12 @@ func ParseTxIn(reader *bufio.Reader) (*TxIn, error) {
 		return nil, err
 	}
 	// return an instance of the class
-	return NewTxIn(prevTX, prevIndex, scriptSig, sequence), nil
+	return NewTxInFromOutPoint(prevOut, scriptSig, sequence), nil
 }
 
 // Serialize returns the byte serialization of the transaction input
 func (txIn *TxIn) Serialize() ([]byte, error) {
-	var result []byte
-
-	// serialize prev_tx, little endian
-	prevTxLittleEndian := make([]byte, 32)
-	copy(prevTxLittleEndian, txIn.PrevTx)
-	slices.Reverse(prevTxLittleEndian)
-	result = append(result, prevTxLittleEndian...)
-
-	// serialize prev_index, 4 bytes, little endian
-	prevIndexBytes := make([]byte, 4)
-	binary.LittleEndian.PutUint32(prevIndexBytes, txIn.PrevIndex)
-	result = append(result, prevIndexBytes...)
+	result := txIn.PrevOut.WireBytes()
 
 	// serialize the ScriptSig
 	scriptSig, err := txIn.ScriptSig.Serialize()
@@ -447,30 +790,51 @@ func (txIn *TxIn) Serialize() ([]byte, error) {
 }
 
 func (txIn *TxIn) FetchTx(testnet bool) (*Tx, error) {
-	return NewTxFetcher().Fetch(hex.EncodeToString(txIn.PrevTx), testnet, false)
+	return NewTxFetcher().Fetch(txIn.PrevOut.TxidString(), testnet, false)
 }
 
-func (txIn *TxIn) Value(testnet bool) (uint64, error) {
+// Prevout returns the output txIn spends. It is fetched once and kept on the input, so the fee, signature
+// hashes and verification of a transaction fetch every spent output only once. Invalidate drops it.
+func (txIn *TxIn) Prevout(testnet bool) (*TxOut, error) {
+	if txIn.prevout != nil && txIn.prevoutTestnet == testnet {
+		return txIn.prevout, nil
+	}
 	tx, err := txIn.FetchTx(testnet)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-
-	numOutputs := uint32(len(tx.TxOuts))
-	if txIn.PrevIndex >= numOutputs {
-		return 0, fmt.Errorf("previous index %d out of range for transaction outputs", txIn.PrevIndex)
+	if txIn.PrevOut.Index >= uint32(len(tx.TxOuts)) {
+		return nil, outputNotFound(txIn.PrevOut.Index)
 	}
+	txIn.SetPrevout(tx.TxOuts[txIn.PrevOut.Index], testnet)
+	return txIn.prevout, nil
+}
+
+// SetPrevout records txOut as the output txIn spends on the network of testnet, such as an output a wallet
+// or a PSBT provides, so Prevout does not fetch it
+func (txIn *TxIn) SetPrevout(txOut *TxOut, testnet bool) {
+	txIn.prevout, txIn.prevoutTestnet = txOut, testnet
+}
 
-	return tx.TxOuts[txIn.PrevIndex].Amount, nil
+// Invalidate drops the output Prevout kept, for when the input was changed to spend another one
+func (txIn *TxIn) Invalidate() {
+	txIn.prevout = nil
+}
+
+func (txIn *TxIn) Value(testnet bool) (uint64, error) {
+	prevout, err := txIn.Prevout(testnet)
+	if err != nil {
+		return 0, err
+	}
+	return prevout.Amount, nil
 }
 
 func (txIn *TxIn) ScriptPubkey(testnet bool) (*script.Script, error) {
-	tx, err := txIn.FetchTx(testnet)
+	prevout, err := txIn.Prevout(testnet)
 	if err != nil {
 		return nil, err
 	}
-	scriptPubkey := tx.TxOuts[txIn.PrevIndex].ScriptPubkey
-	return scriptPubkey, nil
+	return prevout.ScriptPubkey, nil
 }
 
 // TransactionInput represents a transaction input
@@ -487,19 +851,84 @@ func NewTxOut(amount uint64, scriptPubkey *script.Script) *TxOut {
 	}
 }
 
+// Copy returns a deep copy of the output
+func (txOut *TxOut) Copy() *TxOut {
+	return NewTxOut(txOut.Amount, txOut.ScriptPubkey.Copy())
+}
+
 // String returns a string representation of TxIn
 func (txOut *TxOut) String() string {
 	return fmt.Sprintf("%s:%s", utils.FormatWithUnderscore(int(txOut.Amount)), txOut.ScriptPubkey.String())
 }
 
+// Address returns the address of a p2pkh, p2sh, p2wpkh, p2wsh or p2tr output on the given network
+func (txOut *TxOut) Address(testnet bool) (string, error) {
+	s := txOut.ScriptPubkey
+	switch {
+	case s == nil:
+		return "", fmt.Errorf("output has no ScriptPubKey")
+	case s.IsP2PKHScriptPubKey():
+		return utils.H160ToP2PKHAddress((*s)[2], testnet), nil
+	case s.IsP2SHScriptPubKey():
+		return utils.H160ToP2SHAddress((*s)[1], testnet), nil
+	case s.IsP2WPKHScriptPubKey(), s.IsP2WSHScriptPubKey():
+		return utils.EncodeSegwitAddress(0, (*s)[1], testnet)
+	case s.IsP2TRScriptPubKey():
+		return utils.EncodeSegwitAddress(1, (*s)[1], testnet)
+	}
+	if s.IsMultisigScriptPubKey() {
+		return "", fmt.Errorf("bare multisig ScriptPubKey %s has no address", s.String())
+	}
+	return "", fmt.Errorf("nonstandard ScriptPubKey %s has no address", s.String())
+}
+
+// AddressScriptPubkey returns the ScriptPubKey paying to a p2pkh, p2sh, p2wpkh, p2wsh or p2tr address of the given
+// network, the reverse of TxOut.Address
+func AddressScriptPubkey(address string, testnet bool) (*script.Script, error) {
+	if version, program, err := utils.DecodeSegwitAddress(address, testnet); err == nil {
+		switch {
+		case version == 0 && len(program) == 20:
+			return script.CreateP2WPKHScript(program), nil
+		case version == 0 && len(program) == 32:
+			return script.CreateP2WSHScript(program), nil
+		case version == 1 && len(program) == 32:
+			return script.CreateP2TRScript(program), nil
+		}
+		return nil, fmt.Errorf("segwit address %s of version %d is not supported", address, version)
+	}
+
+	payload, err := utils.DecodeBase58Checksum(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 21 {
+		return nil, fmt.Errorf("address %s has a payload of %d bytes, want 21", address, len(payload))
+	}
+	p2pkh, p2sh := byte(0x00), byte(0x05)
+	if testnet {
+		p2pkh, p2sh = 0x6f, 0xc4
+	}
+	switch payload[0] {
+	case p2pkh:
+		return script.CreateP2pkhScript(payload[1:]), nil
+	case p2sh:
+		return script.CreateP2SHScript(payload[1:]), nil
+	}
+	return nil, fmt.Errorf("address %s is not a %s address", address, params.ForNetwork(testnet).Name)
+}
+
 // ParseTxOut parses a byte stream and returns a TxOut object
 func ParseTxOut(reader *bufio.Reader) (*TxOut, error) {
+	return parseTxOut(reader, script.ParseScript)
+}
+
+func parseTxOut(reader *bufio.Reader, parseScript func(reader *bufio.Reader) (*script.Script, error)) (*TxOut, error) {
 	var amount uint64
 	if err := binary.Read(reader, binary.LittleEndian, &amount); err != nil {
 		return nil, err
 	}
 
-	scriptPubkey, err := script.ParseScript(reader)
+	scriptPubkey, err := parseScript(reader)
 	if err != nil {
 		return nil, err
 	}
@@ -522,17 +951,49 @@ func (txOut *TxOut) Serialize() ([]byte, error) {
 	return result, nil
 }
 
+// BlockVerifier checks that the block with blockHash, in display byte order, at height is part of the chain of the network
+type BlockVerifier func(blockHash string, height uint32, testnet bool) error
+
+// TxFetcher fetches transactions and caches them. It is safe for concurrent use:
+// Fetch hands out copies, so callers can change what they get without touching the cache.
 type TxFetcher struct {
+	// Cache holds the fetched transactions by txid. Access it directly only when no Fetch runs at the same time.
 	Cache map[string]*Tx
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+	// UserAgent, when set, is sent with every request
+	UserAgent string
+	// BaseURL, when set, is the Esplora API the requests go to instead of the public one of the network, such as
+	// one serving signet
+	BaseURL string
+	// VerifyBlock, when set, cross-checks every transaction Fetch downloads: the block it is confirmed
+	// in has to be part of the chain of the requested network, see block.HeaderStore.VerifyBlock
+	VerifyBlock BlockVerifier
+	// networks records which network each cached transaction was fetched from
+	networks map[string]cacheNetwork
+	// mu guards Cache and networks
+	mu sync.RWMutex
 }
 
 func NewTxFetcher() *TxFetcher {
+	return NewTxFetcherWithClient(nil, "")
+}
+
+// NewTxFetcherWithClient creates a fetcher that sends its requests with client and userAgent,
+// for proxies, custom TLS settings or headers
+func NewTxFetcherWithClient(client *http.Client, userAgent string) *TxFetcher {
 	return &TxFetcher{
-		Cache: make(map[string]*Tx),
+		Cache:     make(map[string]*Tx),
+		Client:    client,
+		UserAgent: userAgent,
+		networks:  make(map[string]cacheNetwork),
 	}
 }
 
 func (tf *TxFetcher) GetURL(testnet bool) string {
+	if tf.BaseURL != "" {
+		return tf.BaseURL
+	}
 	if testnet {
 		return "https://blockstream.info/testnet/api"
 	}
@@ -541,42 +1002,25 @@ func (tf *TxFetcher) GetURL(testnet bool) string {
 
 func (tf *TxFetcher) Fetch(txID string, testnet, fresh bool) (*Tx, error) {
 	if !fresh {
-		if cachedTx, ok := tf.Cache[txID]; ok {
-			cachedTx.Testnet = testnet
+		if cachedTx, ok := tf.cached(txID, testnet); ok {
 			return cachedTx, nil
 		}
 	}
 
-	url := fmt.Sprintf("%s/tx/%s/hex", tf.GetURL(testnet), txID)
-	response, err := http.Get(url)
+	network := params.ForNetwork(testnet).Name
+	rawHex, err := tf.get(fmt.Sprintf("%s/tx/%s/hex", tf.GetURL(testnet), txID))
 	if err != nil {
-		return nil, err
+		return nil, txError(txID, testnet, err)
 	}
-	defer response.Body.Close()
 
-	rawHex, err := io.ReadAll(response.Body)
+	raw, err := hex.DecodeString(strings.TrimSpace(string(rawHex)))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("response for transaction %s on %s is not hex: %v", txID, network, err)
 	}
 
-	raw, err := hex.DecodeString(string(rawHex))
+	tx, err := ParseTxStrict(raw, testnet)
 	if err != nil {
-		return nil, err
-	}
-
-	var tx *Tx
-	if raw[4] == 0 {
-		raw = append(raw[:4], raw[6:]...)
-		tx, err = ParseTx(bufio.NewReader(bytes.NewBuffer(raw)), testnet)
-		if err != nil {
-			return nil, err
-		}
-		tx.Locktime = binary.LittleEndian.Uint32(raw[len(raw)-4:])
-	} else {
-		tx, err = ParseTx(bufio.NewReader(bytes.NewBuffer(raw)), testnet)
-		if err != nil {
-			return nil, err
-		}
+		return nil, fmt.Errorf("transaction %s on %s: %w", txID, network, err)
 	}
 
 	id, err := tx.Id()
@@ -585,74 +1029,73 @@ func (tf *TxFetcher) Fetch(txID string, testnet, fresh bool) (*Tx, error) {
 	}
 
 	if id != txID {
-		return nil, fmt.Errorf("not the same id: %s vs %s", id, txID)
+		return nil, fmt.Errorf("not the same id: %s vs %s on %s", id, txID, network)
+	}
+
+	if tf.VerifyBlock != nil {
+		if err := tf.verifyNetwork(txID, testnet); err != nil {
+			return nil, err
+		}
 	}
 
+	tf.mu.Lock()
 	tf.Cache[txID] = tx
-	return tx, nil
+	tf.networks[txID] = networkOf(testnet)
+	tf.mu.Unlock()
+	return tx.Copy(), nil
 }
 
-func (tf *TxFetcher) LoadCache(filename string) error {
-	diskCacheFile, err := os.Open(filename)
+// verifyNetwork checks with VerifyBlock that the block confirming the transaction is in the chain of the
+// requested network. Unconfirmed transactions have no block to check and pass.
+func (tf *TxFetcher) verifyNetwork(txID string, testnet bool) error {
+	status, err := tf.FetchStatus(txID, testnet)
 	if err != nil {
 		return err
 	}
-	defer diskCacheFile.Close()
-
-	diskCache := make(map[string]string)
-	err = json.NewDecoder(diskCacheFile).Decode(&diskCache)
-	if err != nil {
-		return err
+	if !status.Confirmed {
+		return nil
 	}
-
-	for k, rawHex := range diskCache {
-		raw, err := hex.DecodeString(rawHex)
-		if err != nil {
-			return err
-		}
-
-		var tx *Tx
-		if raw[4] == 0 {
-			raw = append(raw[:4], raw[6:]...)
-			tx, err = ParseTx(bufio.NewReader(bytes.NewReader(raw)), false)
-			if err != nil {
-				return err
-			}
-			// TODO Why is this reassigning the Locktime?
-			// tx.Locktime = binary.LittleEndian.Uint32(raw[len(raw)-4:])
-		} else {
-			tx, err = ParseTx(bufio.NewReader(bytes.NewReader(raw)), false)
-			if err != nil {
-				return err
-			}
-		}
-
-		tf.Cache[k] = tx
+	if err := tf.VerifyBlock(status.BlockHash, status.BlockHeight, testnet); err != nil {
+		return fmt.Errorf("transaction %s is not confirmed on %s: %w", txID, params.ForNetwork(testnet).Name, err)
 	}
-
 	return nil
 }
 
-func (tf *TxFetcher) DumpCache(filename string) error {
-	diskCacheFile, err := os.Create(filename)
-	if err != nil {
-		return err
+// cached returns a copy of the cached transaction when it can serve a request for the network.
+// Entries whose network is unknown are tagged with the network they are first requested for.
+func (tf *TxFetcher) cached(txID string, testnet bool) (*Tx, bool) {
+	tf.mu.RLock()
+	cachedTx, ok := tf.Cache[txID]
+	network := tf.networks[txID]
+	tf.mu.RUnlock()
+	if !ok || !network.matches(testnet) {
+		return nil, false
+	}
+
+	tx := cachedTx.Copy()
+	tx.Testnet = testnet
+	if network == networkUnknown {
+		tf.mu.Lock()
+		if tf.networks[txID] == networkUnknown {
+			// the cached transaction is replaced rather than changed, copies handed out before keep their network
+			tf.Cache[txID] = tx.Copy()
+			tf.networks[txID] = networkOf(testnet)
+		}
+		tf.mu.Unlock()
 	}
-	defer diskCacheFile.Close()
+	return tx, true
+}
 
-	toDump := make(map[string]string)
-	for k, tx := range tf.Cache {
-		serializedTx, err := tx.Serialize()
+// PrevoutLookup returns a lookup that finds the spent outputs with the fetcher
+func (tf *TxFetcher) PrevoutLookup(testnet bool) PrevoutLookup {
+	return func(txIn *TxIn) (*TxOut, error) {
+		tx, err := tf.Fetch(txIn.PrevOut.TxidString(), testnet, false)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		toDump[k] = hex.EncodeToString(serializedTx)
-	}
-
-	err = json.NewEncoder(diskCacheFile).Encode(toDump)
-	if err != nil {
-		return err
+		if txIn.PrevOut.Index >= uint32(len(tx.TxOuts)) {
+			return nil, outputNotFound(txIn.PrevOut.Index)
+		}
+		return tx.TxOuts[txIn.PrevOut.Index], nil
 	}
-
-	return nil
 }
@@ -5,7 +5,14 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/caspereijkens/cryptocurrency/internal/script"
@@ -44,11 +51,11 @@ func TestParseInputs(t *testing.T) {
 		t.Errorf("Expected 1 input, got %d", len(tx.TxIns))
 	}
 	want, _ := hex.DecodeString("d1c789a9c60383bf715f3f6ad9d14b91fe55f3deb369fe5d9280cb1a01793f81")
-	if !bytes.Equal(tx.TxIns[0].PrevTx, want) {
-		t.Errorf("Expected PrevTx %x, got %x", want, tx.TxIns[0].PrevTx)
+	if !bytes.Equal(tx.TxIns[0].PrevTx(), want) {
+		t.Errorf("Expected PrevTx %x, got %x", want, tx.TxIns[0].PrevTx())
 	}
-	if tx.TxIns[0].PrevIndex != 0 {
-		t.Errorf("Expected PrevIndex 0, got %d", tx.TxIns[0].PrevIndex)
+	if tx.TxIns[0].PrevOut.Index != 0 {
+		t.Errorf("Expected PrevIndex 0, got %d", tx.TxIns[0].PrevOut.Index)
 	}
 	want, _ = hex.DecodeString("6b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278a")
 	have, err := tx.TxIns[0].ScriptSig.Serialize()
@@ -120,6 +127,21 @@ func TestTxId(t *testing.T) {
 	}
 }
 
+func TestTxidValue(t *testing.T) {
+	tx, _ := spendingTx(script.CreateP2pkhScript(make([]byte, 20)))
+	id, _ := tx.Id()
+	txid, err := tx.Txid()
+	if err != nil {
+		t.Fatalf("Txid error: %v", err)
+	}
+	if txid.String() != id {
+		t.Errorf("Txid = %s, want %s", txid, id)
+	}
+	if op := NewOutPoint(txid[:], 0); op.TxidValue() != txid {
+		t.Errorf("TxidValue = %s, want %s", op.TxidValue(), txid)
+	}
+}
+
 func TestTxFee(t *testing.T) {
 	id := "184d3393cea44574a7b521575878a5485fc3c18e4920808235c8f58264c1dc48"
 	tx, err := txFetcher.Fetch(id, testnet, fresh)
@@ -428,6 +450,9 @@ func TestTxCoinBaseHeight(t *testing.T) {
 	if height != expectedHeight {
 		t.Errorf("Wrong coinbase height. Expected: %d, Got: %d", expectedHeight, height)
 	}
+	if serialized, _ := tx.Serialize(); !bytes.Equal(serialized, txBytes) {
+		t.Errorf("CoinbaseHeight changed the transaction")
+	}
 
 	// non-coinbase transaction
 	expectedHeight = 0
@@ -452,3 +477,481 @@ func TestTxCoinBaseHeight(t *testing.T) {
 // 	stream = BytesIO(raw_tx)
 // 	tx = Tx.parse(stream)
 // 	self.assertIsNone(tx.coinbase_height())
+
+func TestTxOutAddress(t *testing.T) {
+	tests := []struct {
+		scriptPubkey string
+		testnet      bool
+		want         string
+	}{
+		{"76a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac", false, "1JAHBxA51vwp5C2zpSB15VbxSZK3hVJs2H"},
+		{"a91474d691da1574e6b3c192ecfb52cc8984ee7b6c5687", false, "3CLoMMyuoDQTPRD3XYZtCvgvkadrAdvdXh"},
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", false, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", true, "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"},
+		{"512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", false, "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+	}
+
+	for _, tt := range tests {
+		raw, _ := hex.DecodeString(tt.scriptPubkey)
+		scriptPubkey, err := script.ParseRawScript(raw)
+		if err != nil {
+			t.Fatalf("Error parsing ScriptPubkey %s: %v", tt.scriptPubkey, err)
+		}
+		address, err := NewTxOut(1000, scriptPubkey).Address(tt.testnet)
+		if err != nil {
+			t.Errorf("Address of %s: %v", tt.scriptPubkey, err)
+		}
+		if address != tt.want {
+			t.Errorf("Address of %s = %s, want %s", tt.scriptPubkey, address, tt.want)
+		}
+		if back, err := AddressScriptPubkey(tt.want, tt.testnet); err != nil || !back.Equal(scriptPubkey) {
+			t.Errorf("AddressScriptPubkey(%s) = %v, %v, want %s", tt.want, back, err, tt.scriptPubkey)
+		}
+	}
+	if _, err := AddressScriptPubkey("1JAHBxA51vwp5C2zpSB15VbxSZK3hVJs2H", true); err == nil {
+		t.Errorf("A mainnet address should not decode on testnet")
+	}
+
+	// OP_RETURN <data> has no address
+	if _, err := NewTxOut(0, &script.Script{[]byte{0x6a}, []byte("hello")}).Address(false); err == nil {
+		t.Errorf("Expected an error for a nonstandard ScriptPubkey")
+	}
+}
+
+func TestTxStringWith(t *testing.T) {
+	rawTx, _ := hex.DecodeString("0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600")
+	tx, _ := ParseTx(bufio.NewReader(bytes.NewReader(rawTx)), false)
+
+	weight, err := tx.Weight()
+	if err != nil {
+		t.Fatalf("Error computing weight: %v", err)
+	}
+	if weight != 4*len(rawTx) {
+		t.Errorf("Expected weight %d, got %d", 4*len(rawTx), weight)
+	}
+
+	h160, _ := hex.DecodeString("a802fc56c704ce87c42d7c92eb75e7896bdc41ae")
+	prevout := NewTxOut(42505594, script.CreateP2pkhScript(h160))
+	lookup := func(txIn *TxIn) (*TxOut, error) {
+		return prevout, nil
+	}
+
+	s := tx.StringWith(lookup)
+	wantInput := "d1c789a9c60383bf715f3f6ad9d14b91fe55f3deb369fe5d9280cb1a01793f81:0 " + describeTxOut(prevout, false)
+	if !strings.Contains(s, wantInput) {
+		t.Errorf("Expected input %q in %s", wantInput, s)
+	}
+	wantFee := fmt.Sprintf("fee: 40_000 (%.2f sat/vB)", 40000/float64(len(rawTx)))
+	if !strings.HasSuffix(s, wantFee) {
+		t.Errorf("Expected %q at the end of %s", wantFee, s)
+	}
+
+	// without the spent outputs there is no fee
+	s = tx.StringWith(func(txIn *TxIn) (*TxOut, error) {
+		return nil, fmt.Errorf("not found")
+	})
+	if s != tx.String() || strings.Contains(s, "fee:") {
+		t.Errorf("Expected the plain String when no outputs resolve, got %s", s)
+	}
+
+	// an input that does not resolve leaves out the fee, not the inputs after it
+	p2pkh := script.CreateP2pkhScript(h160)
+	tx, lookup = spendingTx(p2pkh, p2pkh, p2pkh)
+	s = tx.StringWith(func(txIn *TxIn) (*TxOut, error) {
+		if txIn.PrevOut.Index == 0 {
+			return nil, fmt.Errorf("not found")
+		}
+		return lookup(txIn)
+	})
+	for i, txIn := range tx.TxIns[1:] {
+		prevout, _ := lookup(txIn)
+		if want := txIn.String() + " " + describeTxOut(prevout, true); !strings.Contains(s, want) {
+			t.Errorf("Expected input %d as %q in %s", i+1, want, s)
+		}
+	}
+	if strings.Contains(s, "fee:") {
+		t.Errorf("Expected no fee with an unresolved input, got %s", s)
+	}
+}
+
+func TestTxPrevoutCache(t *testing.T) {
+	rawTx, _ := hex.DecodeString("0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600")
+	tx, _ := ParseTx(bufio.NewReader(bytes.NewReader(rawTx)), false)
+	if strings.Contains(tx.String(), "fee:") {
+		t.Errorf("Expected no fee before the spent output is known, got %s", tx.String())
+	}
+
+	// with the spent output recorded nothing is fetched
+	h160, _ := hex.DecodeString("a802fc56c704ce87c42d7c92eb75e7896bdc41ae")
+	prevout := NewTxOut(42505594, script.CreateP2pkhScript(h160))
+	tx.TxIns[0].SetPrevout(prevout, false)
+
+	if fee, err := tx.Fee(); err != nil || fee != 40000 {
+		t.Errorf("Expected a fee of 40000, got %d, %v", fee, err)
+	}
+	preimage, err := tx.SigHashPreimage(0, nil, SigHashAll)
+	if err != nil {
+		t.Fatalf("SigHashPreimage failed: %v", err)
+	}
+	if want, _ := tx.SigHashPreimage(0, prevout.ScriptPubkey, SigHashAll); !bytes.Equal(preimage, want) {
+		t.Errorf("Expected the preimage to sign the recorded ScriptPubKey")
+	}
+	if !strings.Contains(tx.String(), "fee: 40_000") {
+		t.Errorf("Expected String to show the fee of the resolved input, got %s", tx.String())
+	}
+
+	copied := tx.Copy()
+	copied.TxIns[0].prevout.Amount = 0
+	if fee, err := tx.Fee(); err != nil || fee != 40000 {
+		t.Errorf("Changing the spent output of a copy changed the fee to %d, %v", fee, err)
+	}
+
+	tx.Invalidate()
+	if strings.Contains(tx.String(), "fee:") {
+		t.Errorf("Expected no fee after Invalidate, got %s", tx.String())
+	}
+}
+
+func TestTxCopy(t *testing.T) {
+	rawTx, _ := hex.DecodeString("0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600")
+	tx, err := ParseTx(bufio.NewReader(bytes.NewReader(rawTx)), false)
+	if err != nil {
+		t.Fatalf("ParseTx failed: %v", err)
+	}
+	tx.TxIns[0].Witness = [][]byte{{0x01, 0x02}}
+
+	copied := tx.Copy()
+	copiedBytes, err := copied.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	originalBytes, _ := tx.Serialize()
+	if !bytes.Equal(copiedBytes, originalBytes) {
+		t.Fatalf("copy serializes to %x, want %x", copiedBytes, originalBytes)
+	}
+
+	// mutate everything the copy holds
+	(*copied.TxIns[0].ScriptSig)[0][0] ^= 0xff
+	copied.TxIns[0].Witness[0][0] = 0xff
+	copied.TxIns[0].PrevOut.Index = 7
+	(*copied.TxOuts[0].ScriptPubkey)[2][0] ^= 0xff
+	copied.TxOuts[0].Amount = 1
+	copied.TxOuts = copied.TxOuts[:1]
+
+	afterBytes, _ := tx.Serialize()
+	if !bytes.Equal(afterBytes, originalBytes) {
+		t.Error("mutating the copy changed the original transaction")
+	}
+	if tx.TxIns[0].Witness[0][0] != 0x01 {
+		t.Error("mutating the copy changed the original witness")
+	}
+}
+
+func newFakeTxFetcher(requests *int32) *TxFetcher {
+	rawTx := "0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600"
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		atomic.AddInt32(requests, 1)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(rawTx))}, nil
+	})}
+	return NewTxFetcherWithClient(client, "")
+}
+
+func TestFetchReturnsCopies(t *testing.T) {
+	const txID = "452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03"
+	var requests int32
+	fetcher := newFakeTxFetcher(&requests)
+
+	first, err := fetcher.Fetch(txID, false, false)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	first.TxOuts[0].Amount = 1
+	(*first.TxIns[0].ScriptSig)[0][0] ^= 0xff
+	first.Testnet = true
+
+	second, err := fetcher.Fetch(txID, false, false)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("got %d requests, want the second fetch served from the cache", requests)
+	}
+	if id, _ := second.Id(); id != txID || second.Testnet {
+		t.Errorf("changing a fetched transaction changed the cache: got %s, testnet %v", id, second.Testnet)
+	}
+}
+
+func TestFetchConcurrently(t *testing.T) {
+	const txID = "452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03"
+	var requests int32
+	fetcher := newFakeTxFetcher(&requests)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				tx, err := fetcher.Fetch(txID, false, i%8 == 0 && j == 0)
+				if err != nil {
+					errs <- err
+					return
+				}
+				// every caller scribbles over its copy
+				tx.TxOuts[0].Amount = uint64(i)
+				(*tx.TxOuts[0].ScriptPubkey)[2][0] = byte(j)
+				if id, err := tx.Id(); err != nil || id == txID {
+					errs <- fmt.Errorf("mutated copy still hashes to %s: %v", id, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	tx, err := fetcher.Fetch(txID, false, false)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if id, _ := tx.Id(); id != txID {
+		t.Errorf("cache was corrupted: transaction hashes to %s", id)
+	}
+}
+
+func TestParseTxStrict(t *testing.T) {
+	rawTx, _ := hex.DecodeString("0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600")
+	if _, err := ParseTxStrict(rawTx, false); err != nil {
+		t.Fatalf("ParseTxStrict failed: %v", err)
+	}
+
+	// ParseTx stops at the locktime, ParseTxStrict reports what follows
+	concatenated := append(append([]byte{}, rawTx...), rawTx[:5]...)
+	if _, err := ParseTx(bufio.NewReader(bytes.NewReader(concatenated)), false); err != nil {
+		t.Fatalf("ParseTx failed: %v", err)
+	}
+	_, err := ParseTxStrict(concatenated, false)
+	var trailing *TrailingBytesError
+	if !errors.As(err, &trailing) || trailing.Count != 5 || !errors.Is(err, ErrMalformedTx) {
+		t.Errorf("got error %v, want 5 trailing bytes", err)
+	}
+
+	if _, err := ParseTxStrict(rawTx[:len(rawTx)-1], false); !errors.Is(err, ErrMalformedTx) || errors.As(err, &trailing) {
+		t.Errorf("got error %v for a truncated transaction", err)
+	}
+}
+
+func TestVerifyInputErrors(t *testing.T) {
+	tx, _ := spendingTx(&script.Script{{0x00}}, &script.Script{{0x51}})
+
+	err := tx.VerifyInputWith(0, &script.Script{{0x00}}, script.StandardVerifyFlags)
+	var inputErr *InputError
+	if !errors.As(err, &inputErr) || inputErr.Index != 0 || !errors.Is(err, script.ErrEvalFalse) {
+		t.Errorf("Input ending in OP_0 should evaluate to false, got %v", err)
+	}
+	if err := tx.VerifyInputWith(1, &script.Script{{0x51}}, script.StandardVerifyFlags); err != nil {
+		t.Errorf("Input ending in OP_1 should verify: %v", err)
+	}
+	if err := tx.VerifyInputWith(2, &script.Script{{0x51}}, script.StandardVerifyFlags); !errors.Is(err, ErrInputNotFound) || !errors.As(err, &inputErr) || inputErr.Index != 2 {
+		t.Errorf("Input 2 should not exist, got %v", err)
+	}
+
+	// a ScriptSig spending p2sh with more than pushes
+	p2sh := script.CreateP2SHScript(utils.Hash160([]byte{0x51}))
+	tx.TxIns[0].ScriptSig = &script.Script{{0x51}, {0x76}, {0x75}, {0x51}}
+	if err := tx.VerifyInputWith(0, p2sh, script.StandardVerifyFlags); !errors.Is(err, script.ErrNotPushOnly) {
+		t.Errorf("ScriptSig spending p2sh should have to be push only, got %v", err)
+	}
+}
+
+func TestParseTxLenient(t *testing.T) {
+	alice, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	aliceScript := script.CreateP2pkhScript(alice.Point.Hash160(true))
+	tx, lookup := spendingTx(aliceScript, aliceScript)
+	if _, err := tx.SignAll(KeyMap{1: alice}, lookup); err != nil {
+		t.Fatalf("SignAll error: %v", err)
+	}
+	tx.TxIns[0].ScriptSig = &script.Script{{0x01, 0x02, 0x03, 0x04}}
+	serialized, _ := tx.Serialize()
+	// the ScriptSig of input 0 becomes a push of 75 bytes with only 4 left
+	rawHex := strings.Replace(hex.EncodeToString(serialized), "050401020304", "054b01020304", 1)
+	raw, _ := hex.DecodeString(rawHex)
+
+	if _, err := ParseTx(bufio.NewReader(bytes.NewReader(raw)), true); err == nil {
+		t.Fatalf("ParseTx should fail on a ScriptSig that does not parse")
+	}
+	parsed, err := ParseTxLenient(bufio.NewReader(bytes.NewReader(raw)), true)
+	if err != nil {
+		t.Fatalf("ParseTxLenient error: %v", err)
+	}
+	if !parsed.TxIns[0].ScriptSig.IsOpaque() {
+		t.Errorf("ScriptSig of input 0 should be opaque")
+	}
+	if reserialized, _ := parsed.Serialize(); !bytes.Equal(reserialized, raw) {
+		t.Errorf("Serialize = %x, want %x", reserialized, raw)
+	}
+	if err := parsed.VerifyInputWith(0, aliceScript, script.StandardVerifyFlags); err == nil {
+		t.Errorf("Input with an opaque ScriptSig should not verify")
+	}
+	if err := parsed.VerifyInputWith(1, aliceScript, script.StandardVerifyFlags); err != nil {
+		t.Errorf("Other input should still verify: %v", err)
+	}
+}
+
+func TestFetchVerifiesBlock(t *testing.T) {
+	const txID = "452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03"
+	rawTx := "0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600"
+	status := `{"confirmed":true,"block_height":410394,"block_hash":"000000000000000001d3b1930b7da2d3de42e6eb1d8bbe5e0f30bedcd7158ab4"}`
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		body := rawTx
+		if strings.HasSuffix(request.URL.Path, "/status") {
+			body = status
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	var verified []string
+	fetcher := NewTxFetcherWithClient(client, "")
+	fetcher.VerifyBlock = func(blockHash string, height uint32, testnet bool) error {
+		verified = append(verified, fmt.Sprintf("%s@%d testnet=%v", blockHash, height, testnet))
+		if testnet {
+			return fmt.Errorf("unknown block")
+		}
+		return nil
+	}
+
+	if _, err := fetcher.Fetch(txID, false, true); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if want := "000000000000000001d3b1930b7da2d3de42e6eb1d8bbe5e0f30bedcd7158ab4@410394 testnet=false"; len(verified) != 1 || verified[0] != want {
+		t.Errorf("verified %v, want %s", verified, want)
+	}
+
+	_, err := fetcher.Fetch(txID, true, true)
+	if err == nil || !strings.Contains(err.Error(), "not confirmed on testnet3") {
+		t.Errorf("Fetch from the wrong chain returned %v", err)
+	}
+	if _, ok := fetcher.cached(txID, true); ok {
+		t.Error("a transaction that failed the check should not be cached for testnet")
+	}
+}
+
+func TestSigHashPreimage(t *testing.T) {
+	scriptPubkey := script.CreateP2pkhScript(make([]byte, 20))
+	newTx := func() *Tx {
+		return NewTx(1, []*TxIn{
+			NewTxIn(utils.Hash256([]byte{0}), 0, &script.Script{{0x51}}, 0xfffffffe),
+			NewTxIn(utils.Hash256([]byte{1}), 1, &script.Script{{0x52}}, 0xfffffffe),
+		}, []*TxOut{NewTxOut(1000, scriptPubkey), NewTxOut(2000, scriptPubkey)}, 0, false)
+	}
+	preimage := func(tx *Tx, index uint32, hashType uint32) string {
+		t.Helper()
+		p, err := tx.SigHashPreimage(index, scriptPubkey, hashType)
+		if err != nil {
+			t.Fatalf("SigHashPreimage(%d, %#x) error: %v", index, hashType, err)
+		}
+		return hex.EncodeToString(p)
+	}
+	tx := newTx()
+
+	all := preimage(tx, 0, SigHashAll)
+	z, _ := tx.SigHash(0, scriptPubkey)
+	if rawAll, _ := hex.DecodeString(all); new(big.Int).SetBytes(utils.Hash256(rawAll)).Cmp(z) != 0 {
+		t.Errorf("SigHash is not the hash of the SIGHASH_ALL preimage")
+	}
+	if !strings.HasSuffix(all, "01000000") || !strings.HasSuffix(preimage(tx, 0, SigHashSingle|SigHashAnyoneCanPay), "83000000") {
+		t.Errorf("Preimage should end with the hash type")
+	}
+	// the ScriptSigs are not signed, only the script of the signed input is in its place
+	tx.TxIns[1].ScriptSig = &script.Script{{0x53}}
+	if preimage(tx, 0, SigHashAll) != all {
+		t.Errorf("ScriptSig of another input changed the SIGHASH_ALL preimage")
+	}
+	if rawSigned, _ := scriptPubkey.Serialize(); strings.Count(all, hex.EncodeToString(rawSigned)) != 3 {
+		t.Errorf("Only the signed input and the two outputs should hold the ScriptPubKey: %s", all)
+	}
+
+	// SIGHASH_NONE signs no output and lets the other inputs change their sequence
+	none := preimage(tx, 0, SigHashNone)
+	changed := newTx()
+	changed.TxOuts[0].Amount = 1
+	changed.TxIns[1].Sequence = 0
+	if preimage(changed, 0, SigHashNone) != none || preimage(changed, 0, SigHashAll) == all {
+		t.Errorf("SIGHASH_NONE should not sign the outputs and other sequences")
+	}
+
+	// SIGHASH_SINGLE signs the output of the input only
+	single := preimage(tx, 1, SigHashSingle)
+	changed = newTx()
+	changed.TxOuts[0].Amount = 1
+	if preimage(changed, 1, SigHashSingle) != single {
+		t.Errorf("SIGHASH_SINGLE should not sign the outputs before the one of the input")
+	}
+	changed.TxOuts[1].Amount = 1
+	if preimage(changed, 1, SigHashSingle) == single {
+		t.Errorf("SIGHASH_SINGLE should sign the output of the input")
+	}
+	changed.TxOuts = changed.TxOuts[:1]
+	if _, err := changed.SigHashPreimage(1, scriptPubkey, SigHashSingle); err == nil {
+		t.Errorf("SIGHASH_SINGLE without a matching output should fail")
+	}
+	// the base type is the low 5 bits, the bits above it do not make 0x23 another type than SIGHASH_SINGLE
+	if _, err := changed.SigHashPreimage(1, scriptPubkey, 0x23); err == nil {
+		t.Errorf("Hash type 0x23 without a matching output should fail like SIGHASH_SINGLE")
+	}
+
+	// SIGHASH_ANYONECANPAY lets inputs be added
+	anyone := preimage(tx, 1, SigHashAll|SigHashAnyoneCanPay)
+	changed = newTx()
+	changed.TxIns = append([]*TxIn{NewTxIn(utils.Hash256([]byte{2}), 0, &script.Script{}, 0)}, changed.TxIns...)
+	if preimage(changed, 2, SigHashAll|SigHashAnyoneCanPay) != anyone {
+		t.Errorf("SIGHASH_ANYONECANPAY should only sign its own input")
+	}
+
+	if _, err := tx.SigHashPreimage(2, scriptPubkey, SigHashAll); !errors.Is(err, ErrInputNotFound) {
+		t.Errorf("Preimage of a missing input should fail, got %v", err)
+	}
+}
+
+func TestVerifyInputHashTypes(t *testing.T) {
+	alice, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	aliceScript := script.CreateP2pkhScript(alice.Point.Hash160(true))
+	tx, _ := spendingTx(aliceScript, aliceScript)
+	sign := func(index uint32, z *big.Int, hashType uint32) {
+		t.Helper()
+		sig, err := alice.Sign(z)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.TxIns[index].ScriptSig = &script.Script{append(sig.Serialize(), byte(hashType)), alice.Point.Serialize(true)}
+	}
+
+	// every input is checked against the hash of the hash type of its signature, not the SIGHASH_ALL one
+	for _, hashType := range []uint32{SigHashNone, SigHashSingle | SigHashAnyoneCanPay, SigHashAll | SigHashAnyoneCanPay} {
+		preimage, err := tx.SigHashPreimage(0, aliceScript, hashType)
+		if err != nil {
+			t.Fatalf("SigHashPreimage(%#x) error: %v", hashType, err)
+		}
+		sign(0, new(big.Int).SetBytes(utils.Hash256(preimage)), hashType)
+		if err := tx.VerifyInputCached(0, aliceScript, script.StandardVerifyFlags, VerifyCaches{}); err != nil {
+			t.Errorf("Signature of hash type %#x should verify: %v", hashType, err)
+		}
+	}
+
+	// a SIGHASH_ALL signature passed off as SIGHASH_NONE does not verify
+	z, _ := tx.SigHash(0, aliceScript)
+	sign(0, z, SigHashNone)
+	if err := tx.VerifyInputCached(0, aliceScript, script.StandardVerifyFlags, VerifyCaches{}); err == nil {
+		t.Errorf("Signature of another hash type should fail")
+	}
+
+	// SIGHASH_SINGLE without a matching output signs the number 1
+	sign(1, big.NewInt(1), SigHashSingle)
+	if err := tx.VerifyInputCached(1, aliceScript, script.StandardVerifyFlags, VerifyCaches{}); err != nil {
+		t.Errorf("SIGHASH_SINGLE signature of 1 without a matching output should verify: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+// TRUC (topologically restricted until confirmation, BIP431) transactions opt into stricter relay rules by their
+// version, so a child can always bump their fee. These are policy rules of Bitcoin Core, not consensus ones.
+const (
+	TRUCVersion = 3
+	// TRUCMaxVSize is the largest a TRUC transaction may be
+	TRUCMaxVSize = 10000
+	// TRUCChildMaxVSize is the largest a TRUC transaction may be that spends an unconfirmed TRUC transaction
+	TRUCChildMaxVSize = 1000
+)
+
+// ErrTRUCViolation is wrapped by the errors of transactions that nodes enforcing the TRUC rules do not relay
+var ErrTRUCViolation = errors.New("TRUC policy violation")
+
+// IsTRUC returns whether tx signals the TRUC rules with version 3
+func (tx *Tx) IsTRUC() bool {
+	return tx.Version == TRUCVersion
+}
+
+// CheckTRUC returns an error wrapping ErrTRUCViolation when the TRUC rules keep tx from being relayed next to the
+// unconfirmed transactions it spends. otherChildren is the number of unconfirmed transactions besides tx that spend
+// an output of the unconfirmed parents. A TRUC transaction has at most one unconfirmed parent, which is TRUC, and
+// that parent has no other unconfirmed child. A transaction that is not TRUC can not spend an unconfirmed TRUC
+// one.
+func CheckTRUC(tx *Tx, unconfirmedParents []*Tx, otherChildren int) error {
+	for _, parent := range unconfirmedParents {
+		if parent.IsTRUC() && !tx.IsTRUC() {
+			return trucViolation("version %d transaction spends an unconfirmed TRUC transaction", tx.Version)
+		}
+		if !parent.IsTRUC() && tx.IsTRUC() {
+			return trucViolation("TRUC transaction spends an unconfirmed version %d transaction", parent.Version)
+		}
+	}
+	if !tx.IsTRUC() {
+		return nil
+	}
+
+	vsize, err := tx.VSize()
+	if err != nil {
+		return err
+	}
+	if vsize > TRUCMaxVSize {
+		return trucViolation("TRUC transaction of %d vbytes is larger than %d", vsize, TRUCMaxVSize)
+	}
+	if len(unconfirmedParents) == 0 {
+		return nil
+	}
+	if len(unconfirmedParents) > 1 {
+		return trucViolation("TRUC transaction has %d unconfirmed parents, at most 1 is allowed", len(unconfirmedParents))
+	}
+	if vsize > TRUCChildMaxVSize {
+		return trucViolation("TRUC child of %d vbytes is larger than %d", vsize, TRUCChildMaxVSize)
+	}
+	if otherChildren > 0 {
+		return trucViolation("unconfirmed TRUC parent already has %d other children, at most 1 child is allowed", otherChildren)
+	}
+	return nil
+}
+
+// EphemeralDust returns the indexes of the outputs of tx below the dust threshold. Nodes relay a transaction with
+// such an output, an ephemeral anchor, only when it pays no fee itself and the output is spent by a child in the
+// same package.
+func (tx *Tx) EphemeralDust() []int {
+	var dust []int
+	for i, txOut := range tx.TxOuts {
+		if txOut.Amount < DustThreshold(txOut.ScriptPubkey) && !isNullData(txOut) {
+			dust = append(dust, i)
+		}
+	}
+	return dust
+}
+
+// isNullData returns whether txOut is an OP_RETURN output, which can never be spent and so is never dust
+func isNullData(txOut *TxOut) bool {
+	cmds := *txOut.ScriptPubkey
+	return len(cmds) > 0 && script.IsOp(cmds[0], 0x6a)
+}
+
+// CheckEphemeralDust returns an error wrapping ErrTRUCViolation when tx has dust outputs that can not be relayed
+// as an ephemeral anchor: more than one of them, or a fee that is not zero
+func CheckEphemeralDust(tx *Tx, fee uint64) error {
+	dust := tx.EphemeralDust()
+	if len(dust) == 0 {
+		return nil
+	}
+	if len(dust) > 1 {
+		return trucViolation("transaction has %d dust outputs, at most 1 is allowed", len(dust))
+	}
+	if fee != 0 {
+		return trucViolation("transaction with dust output %d pays a fee of %d satoshis, it has to pay none", dust[0], fee)
+	}
+	return nil
+}
+
+// CheckEphemeralSpends returns an error wrapping ErrTRUCViolation when a dust output of parent is not spent by one
+// of children, the transactions submitted with it in one package
+func CheckEphemeralSpends(parent *Tx, children []*Tx) error {
+	dust := parent.EphemeralDust()
+	if len(dust) == 0 {
+		return nil
+	}
+	parentHash, err := parent.Hash()
+	if err != nil {
+		return err
+	}
+	spent := make(map[OutPoint]bool)
+	for _, child := range children {
+		for _, txIn := range child.TxIns {
+			spent[txIn.PrevOut] = true
+		}
+	}
+	for _, index := range dust {
+		if !spent[NewOutPoint(parentHash, uint32(index))] {
+			return trucViolation("dust output %d of the parent is not spent in the package", index)
+		}
+	}
+	return nil
+}
+
+func trucViolation(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrTRUCViolation, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,91 @@
+package transaction
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+// trucChild returns a transaction of version spending output index of parent
+func trucChild(t *testing.T, parent *Tx, index uint32, version uint32) *Tx {
+	t.Helper()
+	parentHash, err := parent.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txIn := NewTxInFromOutPoint(NewOutPoint(parentHash, index), &script.Script{}, SequenceFinal)
+	return NewTx(version, []*TxIn{txIn}, []*TxOut{NewTxOut(1000, script.CreateP2WPKHScript(make([]byte, 20)))}, 0, true)
+}
+
+func TestCheckTRUC(t *testing.T) {
+	parent, _ := spendingTx(script.CreateP2pkhScript(make([]byte, 20)))
+	parent.Version = TRUCVersion
+	if err := CheckTRUC(parent, nil, 0); err != nil {
+		t.Errorf("TRUC transaction without unconfirmed parents should pass: %v", err)
+	}
+
+	child := trucChild(t, parent, 0, TRUCVersion)
+	if err := CheckTRUC(child, []*Tx{parent}, 0); err != nil {
+		t.Errorf("TRUC child of a TRUC parent should pass: %v", err)
+	}
+	if err := CheckTRUC(child, []*Tx{parent}, 1); !errors.Is(err, ErrTRUCViolation) {
+		t.Errorf("second TRUC child should be a TRUC violation, got %v", err)
+	}
+	if err := CheckTRUC(child, []*Tx{parent, parent.Copy()}, 0); !errors.Is(err, ErrTRUCViolation) {
+		t.Errorf("TRUC child of two unconfirmed parents should be a TRUC violation, got %v", err)
+	}
+	if err := CheckTRUC(trucChild(t, parent, 0, 2), []*Tx{parent}, 0); !errors.Is(err, ErrTRUCViolation) {
+		t.Errorf("version 2 child of a TRUC parent should be a TRUC violation, got %v", err)
+	}
+
+	legacyParent := parent.Copy()
+	legacyParent.Version = 2
+	if err := CheckTRUC(trucChild(t, legacyParent, 0, TRUCVersion), []*Tx{legacyParent}, 0); !errors.Is(err, ErrTRUCViolation) {
+		t.Errorf("TRUC child of a version 2 parent should be a TRUC violation, got %v", err)
+	}
+	if err := CheckTRUC(trucChild(t, legacyParent, 0, 2), []*Tx{legacyParent}, 5); err != nil {
+		t.Errorf("version 2 transactions are not restricted: %v", err)
+	}
+
+	// an output script of two 500 byte pushes makes the child larger than a TRUC child may be
+	large := trucChild(t, parent, 0, TRUCVersion)
+	large.TxOuts[0].ScriptPubkey = &script.Script{make([]byte, 500), make([]byte, 500)}
+	if err := CheckTRUC(large, []*Tx{parent}, 0); !errors.Is(err, ErrTRUCViolation) {
+		t.Errorf("TRUC child over %d vbytes should be a TRUC violation, got %v", TRUCChildMaxVSize, err)
+	}
+	if err := CheckTRUC(large, nil, 0); err != nil {
+		t.Errorf("TRUC transaction without unconfirmed parents may be up to %d vbytes: %v", TRUCMaxVSize, err)
+	}
+}
+
+func TestEphemeralDust(t *testing.T) {
+	parent, _ := spendingTx(script.CreateP2pkhScript(make([]byte, 20)))
+	parent.Version = TRUCVersion
+	parent.TxOuts = append(parent.TxOuts,
+		NewTxOut(0, script.CreateP2AScript()),
+		NewTxOut(0, &script.Script{{0x6a}, []byte("data")}))
+
+	if dust := parent.EphemeralDust(); len(dust) != 1 || dust[0] != 1 {
+		t.Fatalf("EphemeralDust = %v, want the anchor output 1", dust)
+	}
+	if err := CheckEphemeralDust(parent, 0); err != nil {
+		t.Errorf("anchor on a transaction without fee should pass: %v", err)
+	}
+	if err := CheckEphemeralDust(parent, 100); !errors.Is(err, ErrTRUCViolation) {
+		t.Errorf("anchor on a transaction with a fee should be a TRUC violation, got %v", err)
+	}
+
+	twoAnchors := parent.Copy()
+	twoAnchors.TxOuts = append(twoAnchors.TxOuts, NewTxOut(0, script.CreateP2AScript()))
+	if err := CheckEphemeralDust(twoAnchors, 0); !errors.Is(err, ErrTRUCViolation) {
+		t.Errorf("two dust outputs should be a TRUC violation, got %v", err)
+	}
+
+	if err := CheckEphemeralSpends(parent, []*Tx{trucChild(t, parent, 1, TRUCVersion)}); err != nil {
+		t.Errorf("child spending the anchor should pass: %v", err)
+	}
+	if err := CheckEphemeralSpends(parent, []*Tx{trucChild(t, parent, 0, TRUCVersion)}); !errors.Is(err, ErrTRUCViolation) {
+		t.Errorf("package leaving the anchor unspent should be a TRUC violation, got %v", err)
+	}
+}
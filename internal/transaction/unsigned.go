@@ -0,0 +1,308 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// unsignedTxMagic starts a serialized UnsignedTx
+var unsignedTxMagic = []byte("utx\xff")
+
+// UnsignedTx is a transaction handed to a signer that is offline, with what the signer needs to check and sign
+// it without looking anything up: the output every input spends and the redeem script of the p2sh inputs.
+type UnsignedTx struct {
+	Tx       *Tx
+	Prevouts []*TxOut
+	// RedeemScripts holds the redeem script of every input spending a p2sh output, nil for the other inputs
+	RedeemScripts []*script.Script
+}
+
+// InputSignature is a signature made elsewhere for an input of an UnsignedTx
+type InputSignature struct {
+	Input int
+	// PublicKey is the SEC serialization of the key that signed
+	PublicKey []byte
+	// Signature is the DER signature followed by the sighash type
+	Signature []byte
+}
+
+// NewUnsignedTx packages tx with the outputs its inputs spend, which are found with lookup or fetched when it is
+// nil. redeemScripts holds the redeem scripts of the inputs spending p2sh outputs by input index.
+func NewUnsignedTx(tx *Tx, lookup PrevoutLookup, redeemScripts map[int]*script.Script) (*UnsignedTx, error) {
+	if lookup == nil {
+		lookup = NewTxFetcher().PrevoutLookup(tx.Testnet)
+	}
+	u := &UnsignedTx{
+		Tx:            tx,
+		Prevouts:      make([]*TxOut, len(tx.TxIns)),
+		RedeemScripts: make([]*script.Script, len(tx.TxIns)),
+	}
+	for i, txIn := range tx.TxIns {
+		prevout, err := lookup(txIn)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+		u.Prevouts[i] = prevout
+		u.RedeemScripts[i] = redeemScripts[i]
+	}
+	if err := u.check(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// check returns an error when the prevouts or redeem scripts do not belong to the inputs
+func (u *UnsignedTx) check() error {
+	if len(u.Prevouts) != len(u.Tx.TxIns) || len(u.RedeemScripts) != len(u.Tx.TxIns) {
+		return fmt.Errorf("%d prevouts and %d redeem scripts for %d inputs", len(u.Prevouts), len(u.RedeemScripts), len(u.Tx.TxIns))
+	}
+	for i, prevout := range u.Prevouts {
+		redeemScript := u.RedeemScripts[i]
+		if !prevout.ScriptPubkey.IsP2SHScriptPubKey() {
+			if redeemScript != nil {
+				return fmt.Errorf("input %d has a redeem script but does not spend a p2sh output", i)
+			}
+			continue
+		}
+		if redeemScript == nil {
+			return fmt.Errorf("input %d spends a p2sh output without a redeem script", i)
+		}
+		raw, err := redeemScript.RawSerialize()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(utils.Hash160(raw), (*prevout.ScriptPubkey)[1]) {
+			return fmt.Errorf("redeem script of input %d does not hash to the p2sh output it spends", i)
+		}
+	}
+	return nil
+}
+
+// SigHash returns the hash the signature of input inputIndex commits to, with SIGHASH_ALL
+func (u *UnsignedTx) SigHash(inputIndex int) (*big.Int, error) {
+	if inputIndex < 0 || inputIndex >= len(u.Tx.TxIns) {
+		return nil, &InputError{Index: inputIndex, Err: ErrInputNotFound}
+	}
+	return u.Tx.SigHash(uint32(inputIndex), u.signedScript(inputIndex))
+}
+
+// signedScript returns the script the signatures of input i commit to
+func (u *UnsignedTx) signedScript(i int) *script.Script {
+	if u.RedeemScripts[i] != nil {
+		return u.RedeemScripts[i]
+	}
+	return u.Prevouts[i].ScriptPubkey
+}
+
+// Serialize returns the transaction followed by the spent output and the redeem script of every input, an empty
+// script for inputs without one
+func (u *UnsignedTx) Serialize() ([]byte, error) {
+	result := append([]byte{}, unsignedTxMagic...)
+	serializedTx, err := u.Tx.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	length, err := utils.EncodeVarint(uint64(len(serializedTx)))
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, length...)
+	result = append(result, serializedTx...)
+
+	for i, prevout := range u.Prevouts {
+		serializedTxOut, err := prevout.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, serializedTxOut...)
+
+		redeemScript := u.RedeemScripts[i]
+		if redeemScript == nil {
+			redeemScript = &script.Script{}
+		}
+		serializedScript, err := redeemScript.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, serializedScript...)
+	}
+	return result, nil
+}
+
+// ParseUnsignedTx reads an UnsignedTx written by Serialize
+func ParseUnsignedTx(raw []byte, testnet bool) (*UnsignedTx, error) {
+	if !bytes.HasPrefix(raw, unsignedTxMagic) {
+		return nil, fmt.Errorf("not an unsigned transaction package")
+	}
+	reader := bufio.NewReader(bytes.NewReader(raw[len(unsignedTxMagic):]))
+	length, err := utils.ReadVarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(raw)) {
+		return nil, fmt.Errorf("transaction of %d bytes does not fit the package", length)
+	}
+	serializedTx := make([]byte, length)
+	if _, err := io.ReadFull(reader, serializedTx); err != nil {
+		return nil, err
+	}
+	tx, err := ParseTxStrict(serializedTx, testnet)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &UnsignedTx{Tx: tx}
+	for i := range tx.TxIns {
+		prevout, err := ParseTxOut(reader)
+		if err != nil {
+			return nil, fmt.Errorf("output spent by input %d: %w", i, err)
+		}
+		redeemScript, err := script.ParseScript(reader)
+		if err != nil {
+			return nil, fmt.Errorf("redeem script of input %d: %w", i, err)
+		}
+		if len(*redeemScript) == 0 {
+			redeemScript = nil
+		}
+		u.Prevouts = append(u.Prevouts, prevout)
+		u.RedeemScripts = append(u.RedeemScripts, redeemScript)
+	}
+	if _, err := reader.ReadByte(); err != io.EOF {
+		return nil, fmt.Errorf("unsigned transaction package has trailing bytes")
+	}
+	if err := u.check(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ImportSignatures checks every signature against its public key and the signature hash of its input and then
+// fills in the ScriptSigs of the inputs the signatures complete: a p2pkh input needs the signature of the key of
+// its output, a bare or p2sh multisig input the signatures of enough keys of its script, which have to be imported
+// together. Only SIGHASH_ALL signatures are accepted. A signature that does not check out rejects the whole import
+// and leaves the transaction alone. It returns the indexes of the inputs that are still not signed.
+func (u *UnsignedTx) ImportSignatures(signatures []InputSignature) ([]int, error) {
+	byInput := make(map[int]map[string][]byte)
+	for _, signature := range signatures {
+		if err := u.checkSignature(signature); err != nil {
+			return nil, err
+		}
+		if byInput[signature.Input] == nil {
+			byInput[signature.Input] = make(map[string][]byte)
+		}
+		byInput[signature.Input][string(signature.PublicKey)] = signature.Signature
+	}
+
+	scriptSigs := make(map[int]*script.Script)
+	for i, keySignatures := range byInput {
+		scriptSig, err := u.scriptSig(i, keySignatures)
+		if err != nil {
+			return nil, err
+		}
+		if scriptSig != nil {
+			scriptSigs[i] = scriptSig
+		}
+	}
+
+	signed := u.Tx.Copy()
+	for i, scriptSig := range scriptSigs {
+		signed.TxIns[i].ScriptSig = scriptSig
+		if err := signed.VerifyInputWith(uint32(i), u.Prevouts[i].ScriptPubkey, script.StandardVerifyFlags); err != nil {
+			return nil, fmt.Errorf("signed input %d does not verify: %w", i, err)
+		}
+	}
+	for i, scriptSig := range scriptSigs {
+		u.Tx.TxIns[i].ScriptSig = scriptSig
+	}
+
+	var unsigned []int
+	for i, txIn := range u.Tx.TxIns {
+		if len(*txIn.ScriptSig) == 0 {
+			unsigned = append(unsigned, i)
+		}
+	}
+	return unsigned, nil
+}
+
+// checkSignature returns an error unless signature is a valid SIGHASH_ALL signature of its input by its key
+func (u *UnsignedTx) checkSignature(signature InputSignature) error {
+	i := signature.Input
+	if i < 0 || i >= len(u.Tx.TxIns) {
+		return fmt.Errorf("signature of input %d: %w", i, ErrInputNotFound)
+	}
+	sig, err := signatureverification.ParseSignatureWithHashType(signature.Signature)
+	if err != nil {
+		return fmt.Errorf("signature of input %d: %w", i, err)
+	}
+	if sig.HashType != signatureverification.SigHashAll {
+		return fmt.Errorf("signature of input %d is a %s signature, not SIGHASH_ALL", i, sig.HashType)
+	}
+	point, err := signatureverification.ParseSEC(signature.PublicKey)
+	if err != nil {
+		return fmt.Errorf("public key of input %d: %w", i, err)
+	}
+	z, err := u.SigHash(i)
+	if err != nil {
+		return err
+	}
+	if !point.Verify(z, sig.Signature) {
+		return fmt.Errorf("signature of input %d does not verify against key %x", i, signature.PublicKey)
+	}
+	return nil
+}
+
+// scriptSig returns the ScriptSig of input i made with the signatures by key, nil when they are not enough
+func (u *UnsignedTx) scriptSig(i int, signatures map[string][]byte) (*script.Script, error) {
+	scriptPubkey := u.Prevouts[i].ScriptPubkey
+	if scriptPubkey.IsP2PKHScriptPubKey() {
+		for key, signature := range signatures {
+			point, _ := signatureverification.ParseSEC([]byte(key))
+			if bytes.Equal(point.Hash160(len(key) == 33), (*scriptPubkey)[2]) {
+				return &script.Script{signature, []byte(key)}, nil
+			}
+		}
+		return nil, fmt.Errorf("no signature of input %d is by the key of the output it spends", i)
+	}
+
+	if scriptPubkey.IsMultisigScriptPubKey() {
+		return multisigScriptSig(scriptPubkey, signatures), nil
+	}
+
+	redeemScript := u.RedeemScripts[i]
+	if redeemScript == nil || !redeemScript.IsMultisigScriptPubKey() {
+		return nil, fmt.Errorf("input %d spends %s, only p2pkh, bare multisig and p2sh multisig inputs can be signed", i, scriptPubkey)
+	}
+	scriptSig := multisigScriptSig(redeemScript, signatures)
+	if scriptSig == nil {
+		return nil, nil
+	}
+	raw, err := redeemScript.RawSerialize()
+	if err != nil {
+		return nil, err
+	}
+	*scriptSig = append(*scriptSig, raw)
+	return scriptSig, nil
+}
+
+// multisigScriptSig returns the ScriptSig that satisfies the multisig script with signatures by key, nil when
+// they are not enough. The signatures go in the order of the keys, after the dummy element OP_CHECKMULTISIG pops.
+func multisigScriptSig(multisig *script.Script, signatures map[string][]byte) *script.Script {
+	required, keys, _ := multisig.ParseMultisig()
+	scriptSig := script.Script{{0x00}}
+	for _, key := range keys {
+		if signature, ok := signatures[string(key)]; ok && len(scriptSig) <= required {
+			scriptSig = append(scriptSig, signature)
+		}
+	}
+	if len(scriptSig) <= required {
+		return nil
+	}
+	return &scriptSig
+}
@@ -0,0 +1,125 @@
+package transaction
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/script"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+func externalSignature(t *testing.T, u *UnsignedTx, input int, key *signatureverification.PrivateKey) InputSignature {
+	z, err := u.SigHash(input)
+	if err != nil {
+		t.Fatalf("SigHash error: %v", err)
+	}
+	sig, err := key.Sign(z)
+	if err != nil {
+		t.Fatalf("Sign error: %v", err)
+	}
+	return InputSignature{input, key.Point.Serialize(true), append(sig.Serialize(), byte(SigHashAll))}
+}
+
+func TestUnsignedTx(t *testing.T) {
+	var keys []*signatureverification.PrivateKey
+	for _, secret := range []int64{8675309, 12345, 54321} {
+		key, _ := signatureverification.NewPrivateKey(big.NewInt(secret))
+		keys = append(keys, key)
+	}
+	// a 2-of-3 multisig of all three keys
+	redeemScript := &script.Script{{0x52}, keys[0].Point.Serialize(true), keys[1].Point.Serialize(true), keys[2].Point.Serialize(true), {0x53}, {0xae}}
+	rawRedeem, _ := redeemScript.RawSerialize()
+	p2pkh := script.CreateP2pkhScript(keys[0].Point.Hash160(true))
+	tx, lookup := spendingTx(p2pkh, script.CreateP2SHScript(utils.Hash160(rawRedeem)))
+
+	if _, err := NewUnsignedTx(tx, lookup, nil); err == nil {
+		t.Errorf("Packaging a p2sh input without its redeem script should fail")
+	}
+	u, err := NewUnsignedTx(tx, lookup, map[int]*script.Script{1: redeemScript})
+	if err != nil {
+		t.Fatalf("NewUnsignedTx error: %v", err)
+	}
+
+	// the signer only gets the serialized package
+	raw, err := u.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize error: %v", err)
+	}
+	offline, err := ParseUnsignedTx(raw, true)
+	if err != nil {
+		t.Fatalf("ParseUnsignedTx error: %v", err)
+	}
+	if again, _ := offline.Serialize(); !bytes.Equal(again, raw) {
+		t.Errorf("Package does not round trip")
+	}
+	signatures := []InputSignature{
+		externalSignature(t, offline, 0, keys[0]),
+		externalSignature(t, offline, 1, keys[2]),
+		externalSignature(t, offline, 1, keys[0]),
+	}
+
+	// a signature by a key that does not verify rejects the whole import
+	forged := externalSignature(t, u, 0, keys[1])
+	forged.PublicKey = keys[0].Point.Serialize(true)
+	if _, err := u.ImportSignatures([]InputSignature{signatures[0], forged}); err == nil {
+		t.Errorf("Importing a forged signature should fail")
+	}
+	if len(*tx.TxIns[0].ScriptSig) != 0 {
+		t.Errorf("Failed import should leave the transaction alone")
+	}
+
+	// one signature does not complete the multisig input
+	unsigned, err := u.ImportSignatures(signatures[:2])
+	if err != nil {
+		t.Fatalf("ImportSignatures error: %v", err)
+	}
+	if len(unsigned) != 1 || unsigned[0] != 1 {
+		t.Errorf("Unsigned inputs = %v, want [1]", unsigned)
+	}
+	unsigned, err = u.ImportSignatures(signatures[1:])
+	if err != nil || len(unsigned) != 0 {
+		t.Fatalf("ImportSignatures = %v, %v", unsigned, err)
+	}
+	for i, prevout := range u.Prevouts {
+		if err := tx.VerifyInputWith(uint32(i), prevout.ScriptPubkey, script.StandardVerifyFlags); err != nil {
+			t.Errorf("Input %d does not verify: %v", i, err)
+		}
+	}
+}
+
+func TestImportSignaturesUnsupportedInput(t *testing.T) {
+	key, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	p2pkh := script.CreateP2pkhScript(key.Point.Hash160(true))
+	p2wpkh := script.CreateP2WPKHScript(key.Point.Hash160(true))
+	tx, lookup := spendingTx(p2pkh, p2wpkh)
+	u, err := NewUnsignedTx(tx, lookup, nil)
+	if err != nil {
+		t.Fatalf("NewUnsignedTx error: %v", err)
+	}
+
+	// the p2wpkh input has no redeem script, a signature for it is rejected instead of panicking
+	if _, err := u.ImportSignatures([]InputSignature{externalSignature(t, u, 1, key)}); err == nil {
+		t.Errorf("Importing a signature for a p2wpkh input should fail")
+	}
+	unsigned, err := u.ImportSignatures([]InputSignature{externalSignature(t, u, 0, key)})
+	if err != nil || len(unsigned) != 1 || unsigned[0] != 1 {
+		t.Errorf("ImportSignatures = %v, %v, want input 1 unsigned", unsigned, err)
+	}
+}
+
+func TestParseUnsignedTxErrors(t *testing.T) {
+	tx, lookup := spendingTx(script.CreateP2pkhScript(make([]byte, 20)))
+	u, _ := NewUnsignedTx(tx, lookup, nil)
+	raw, _ := u.Serialize()
+	for name, invalid := range map[string][]byte{
+		"no magic":       raw[4:],
+		"truncated":      raw[:len(raw)-1],
+		"trailing bytes": append(bytes.Clone(raw), 0x00),
+	} {
+		if _, err := ParseUnsignedTx(invalid, true); err == nil {
+			t.Errorf("%s: ParseUnsignedTx should fail", name)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const bech32Alphabet = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// The checksum constants of BIP173 (bech32, witness version 0) and BIP350 (bech32m, version 1 and up)
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	result := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]>>5)
+	}
+	result = append(result, 0)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]&31)
+	}
+	return result
+}
+
+// convertBits regroups data from fromBits wide groups into toBits wide groups
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	acc := uint32(0)
+	bits := uint(0)
+	maxValue := uint32(1)<<toBits - 1
+	var result []byte
+
+	for _, value := range data {
+		if uint32(value)>>fromBits != 0 {
+			return nil, fmt.Errorf("invalid data value %d", value)
+		}
+		acc = acc<<fromBits | uint32(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+
+	return result, nil
+}
+
+// SegwitHRP returns the human readable part of segwit addresses
+func SegwitHRP(testnet bool) string {
+	if testnet {
+		return "tb"
+	}
+	return "bc"
+}
+
+// EncodeSegwitAddress encodes a witness program as a bech32 (version 0) or bech32m (version 1 and up) address
+func EncodeSegwitAddress(witnessVersion byte, program []byte, testnet bool) (string, error) {
+	if witnessVersion > 16 {
+		return "", fmt.Errorf("invalid witness version %d", witnessVersion)
+	}
+	if len(program) < 2 || len(program) > 40 || (witnessVersion == 0 && len(program) != 20 && len(program) != 32) {
+		return "", fmt.Errorf("invalid witness program length %d for version %d", len(program), witnessVersion)
+	}
+
+	converted, err := convertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	data := append([]byte{witnessVersion}, converted...)
+
+	hrp := SegwitHRP(testnet)
+	checksumConst := uint32(bech32Const)
+	if witnessVersion > 0 {
+		checksumConst = bech32mConst
+	}
+
+	values := append(bech32HrpExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ checksumConst
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, d := range data {
+		sb.WriteByte(bech32Alphabet[d])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(bech32Alphabet[(polymod>>(5*(5-i)))&31])
+	}
+
+	return sb.String(), nil
+}
+
+// DecodeSegwitAddress returns the witness version and program of a segwit address for the given network
+func DecodeSegwitAddress(address string, testnet bool) (byte, []byte, error) {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return 0, nil, fmt.Errorf("mixed case in address %q", address)
+	}
+	address = strings.ToLower(address)
+
+	separator := strings.LastIndexByte(address, '1')
+	if separator < 1 || separator+7 > len(address) || len(address) > 90 {
+		return 0, nil, fmt.Errorf("invalid bech32 address %q", address)
+	}
+
+	hrp := address[:separator]
+	if hrp != SegwitHRP(testnet) {
+		return 0, nil, fmt.Errorf("address %q is not for this network", address)
+	}
+
+	data := make([]byte, 0, len(address)-separator-1)
+	for i := separator + 1; i < len(address); i++ {
+		d := strings.IndexByte(bech32Alphabet, address[i])
+		if d < 0 {
+			return 0, nil, fmt.Errorf("invalid character %q in address", address[i])
+		}
+		data = append(data, byte(d))
+	}
+
+	polymod := bech32Polymod(append(bech32HrpExpand(hrp), data...))
+	data = data[:len(data)-6]
+	if len(data) == 0 {
+		return 0, nil, fmt.Errorf("address %q has no witness version", address)
+	}
+
+	witnessVersion := data[0]
+	expectedConst := uint32(bech32Const)
+	if witnessVersion > 0 {
+		expectedConst = bech32mConst
+	}
+	if polymod != expectedConst {
+		return 0, nil, fmt.Errorf("invalid checksum in address %q", address)
+	}
+
+	program, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if witnessVersion > 16 || len(program) < 2 || len(program) > 40 || (witnessVersion == 0 && len(program) != 20 && len(program) != 32) {
+		return 0, nil, fmt.Errorf("invalid witness program in address %q", address)
+	}
+
+	return witnessVersion, program, nil
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSegwitAddress(t *testing.T) {
+	// Test vectors from BIP173 and BIP350
+	tests := []struct {
+		address string
+		testnet bool
+		version byte
+		program string
+	}{
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", false, 0, "751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", true, 0, "1863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", false, 1, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+		{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", true, 1, "000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+	}
+
+	for _, tt := range tests {
+		version, program, err := DecodeSegwitAddress(tt.address, tt.testnet)
+		if err != nil {
+			t.Errorf("DecodeSegwitAddress(%s) error: %v", tt.address, err)
+			continue
+		}
+		want, _ := hex.DecodeString(tt.program)
+		if version != tt.version || !bytes.Equal(program, want) {
+			t.Errorf("DecodeSegwitAddress(%s) = %d %x, want %d %s", tt.address, version, program, tt.version, tt.program)
+		}
+
+		address, err := EncodeSegwitAddress(tt.version, want, tt.testnet)
+		if err != nil {
+			t.Errorf("EncodeSegwitAddress error: %v", err)
+		}
+		if address != strings.ToLower(tt.address) {
+			t.Errorf("EncodeSegwitAddress = %s, want %s", address, strings.ToLower(tt.address))
+		}
+	}
+}
+
+func TestDecodeSegwitAddressInvalid(t *testing.T) {
+	// Invalid addresses from BIP173 and BIP350
+	tests := []string{
+		// version 1 with a bech32 instead of bech32m checksum
+		"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7k7grplx",
+		// version 0 with a bech32m checksum
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh",
+		// invalid program length for version 0
+		"bc1qr508d6qejxtdg4y5r3zarvaryvq37vs6e",
+		// mixed case
+		"bc1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4",
+		// zero padding of more than 4 bits
+		"bc1zw508d6qejxtdg4y5r3zarvaryvqyzf3du",
+		// empty data section
+		"bc1gmk9yu",
+		// testnet address on mainnet
+		"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7",
+	}
+
+	for _, address := range tests {
+		if _, _, err := DecodeSegwitAddress(address, false); err == nil {
+			t.Errorf("DecodeSegwitAddress(%s) should fail", address)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCooldown is how long a failed endpoint is skipped
+const DefaultCooldown = time.Minute
+
+// EsploraEndpoints are public Esplora APIs serving the same data, the mainnet ones and the testnet ones.
+// The first URL of each group is the one the fetchers send their requests to.
+var EsploraEndpoints = [][]string{
+	{"https://blockstream.info/api", "https://mempool.space/api"},
+	{"https://blockstream.info/testnet/api", "https://mempool.space/testnet/api"},
+}
+
+// FailoverTransport is an http.RoundTripper that sends requests for one of a group of equivalent base URLs
+// to the first healthy one of the group. An endpoint that fails, with a network error, a 5xx status or
+// a rate limit, is skipped for Cooldown or as long as its Retry-After asks, and the request moves on to the
+// next one. Requests for other URLs are passed through. It is safe for concurrent use.
+type FailoverTransport struct {
+	// Base sends the requests, http.DefaultTransport when nil
+	Base     http.RoundTripper
+	Cooldown time.Duration
+
+	mu     sync.Mutex
+	groups [][]*endpoint
+	now    func() time.Time
+}
+
+type endpoint struct {
+	base      string
+	downUntil time.Time
+}
+
+// NewFailoverTransport creates a transport for groups of equivalent base URLs, tried in the order given.
+// Put a self-hosted instance first to use the public APIs only when it is down.
+func NewFailoverTransport(groups ...[]string) *FailoverTransport {
+	t := &FailoverTransport{Cooldown: DefaultCooldown, now: time.Now}
+	for _, group := range groups {
+		endpoints := make([]*endpoint, len(group))
+		for i, base := range group {
+			endpoints[i] = &endpoint{base: strings.TrimSuffix(base, "/")}
+		}
+		t.groups = append(t.groups, endpoints)
+	}
+	return t
+}
+
+// NewFailoverClient returns a client that fails over between the EsploraEndpoints
+func NewFailoverClient() *http.Client {
+	return &http.Client{Transport: NewFailoverTransport(EsploraEndpoints...)}
+}
+
+func (t *FailoverTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *FailoverTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	group, suffix := t.match(request.URL.String())
+	if group == nil {
+		return t.base().RoundTrip(request)
+	}
+
+	candidates := t.order(group)
+	for i, ep := range candidates {
+		target, err := url.Parse(ep.base + suffix)
+		if err != nil {
+			return nil, err
+		}
+		attempt := request.Clone(request.Context())
+		attempt.URL, attempt.Host = target, target.Host
+
+		response, err := t.base().RoundTrip(attempt)
+		if err == nil && !failed(response) {
+			t.markUp(ep)
+			return response, nil
+		}
+		t.markDown(ep, response)
+
+		// the last endpoint's answer goes to the caller, so a rate limit still reaches it as a 429
+		if i == len(candidates)-1 {
+			return response, err
+		}
+		if response != nil {
+			response.Body.Close()
+		}
+	}
+	return nil, fmt.Errorf("no endpoints for %s", request.URL)
+}
+
+// CheckHealth requests the tip height from every endpoint, marks the ones that fail as down
+// and returns their errors by base URL
+func (t *FailoverTransport) CheckHealth() map[string]error {
+	failures := make(map[string]error)
+	for _, group := range t.groups {
+		for _, ep := range group {
+			request, err := http.NewRequest(http.MethodGet, ep.base+"/blocks/tip/height", nil)
+			if err != nil {
+				failures[ep.base] = err
+				continue
+			}
+			response, err := t.base().RoundTrip(request)
+			if err == nil && !failed(response) {
+				t.markUp(ep)
+				response.Body.Close()
+				continue
+			}
+			t.markDown(ep, response)
+			if response != nil {
+				response.Body.Close()
+				err = fmt.Errorf("GET %s: %s", request.URL, response.Status)
+			}
+			failures[ep.base] = err
+		}
+	}
+	return failures
+}
+
+// Healthy returns the base URLs that are not skipped at the moment
+func (t *FailoverTransport) Healthy() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var healthy []string
+	for _, group := range t.groups {
+		for _, ep := range group {
+			if !t.now().Before(ep.downUntil) {
+				healthy = append(healthy, ep.base)
+			}
+		}
+	}
+	return healthy
+}
+
+// match returns the group with the longest base URL that rawURL starts with, and the rest of rawURL
+func (t *FailoverTransport) match(rawURL string) ([]*endpoint, string) {
+	var group []*endpoint
+	suffix, longest := "", 0
+	for _, endpoints := range t.groups {
+		for _, ep := range endpoints {
+			rest, ok := strings.CutPrefix(rawURL, ep.base)
+			if !ok || len(ep.base) <= longest || (rest != "" && rest[0] != '/' && rest[0] != '?') {
+				continue
+			}
+			group, suffix, longest = endpoints, rest, len(ep.base)
+		}
+	}
+	return group, suffix
+}
+
+// order returns the healthy endpoints of group in their configured order, followed by
+// the ones that are down, those that come back first leading
+func (t *FailoverTransport) order(group []*endpoint) []*endpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	var healthy, down []*endpoint
+	for _, ep := range group {
+		if now.Before(ep.downUntil) {
+			down = append(down, ep)
+		} else {
+			healthy = append(healthy, ep)
+		}
+	}
+	sort.SliceStable(down, func(i, j int) bool { return down[i].downUntil.Before(down[j].downUntil) })
+	return append(healthy, down...)
+}
+
+func (t *FailoverTransport) markUp(ep *endpoint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ep.downUntil = time.Time{}
+}
+
+func (t *FailoverTransport) markDown(ep *endpoint, response *http.Response) {
+	cooldown := t.Cooldown
+	if response != nil {
+		cooldown = max(cooldown, parseRetryAfter(response.Header.Get("Retry-After"), t.now()))
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ep.downUntil = t.now().Add(cooldown)
+}
+
+// failed reports whether a response means the endpoint cannot serve requests right now
+func failed(response *http.Response) bool {
+	return response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+}
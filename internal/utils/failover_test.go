@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFailoverTransport(t *testing.T) {
+	var hosts []string
+	down := map[string]int{"primary.example": http.StatusServiceUnavailable}
+	transport := NewFailoverTransport([]string{"https://primary.example/api", "https://backup.example/api"})
+	now := time.Unix(1700000000, 0)
+	transport.now = func() time.Time { return now }
+	transport.Base = roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		hosts = append(hosts, request.URL.Host)
+		if request.URL.Path != "/api/tx/abcd/hex" {
+			t.Errorf("unexpected path %s", request.URL.Path)
+		}
+		if status, ok := down[request.URL.Host]; ok {
+			return respond(status, ""), nil
+		}
+		return respond(http.StatusOK, "abcd"), nil
+	})
+	client := &http.Client{Transport: transport}
+
+	body, err := HTTPGet(client, "", "https://primary.example/api/tx/abcd/hex")
+	if err != nil || string(body) != "abcd" {
+		t.Fatalf("HTTPGet returned %q, %v", body, err)
+	}
+	// the primary is skipped while it cools down
+	if _, err := HTTPGet(client, "", "https://primary.example/api/tx/abcd/hex"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"primary.example", "backup.example", "backup.example"}; !slices.Equal(hosts, want) {
+		t.Errorf("requests went to %v, want %v", hosts, want)
+	}
+	if healthy := transport.Healthy(); !slices.Equal(healthy, []string{"https://backup.example/api"}) {
+		t.Errorf("got healthy endpoints %v", healthy)
+	}
+
+	// after the cooldown the primary is tried again
+	now = now.Add(DefaultCooldown)
+	delete(down, "primary.example")
+	hosts = nil
+	if _, err := HTTPGet(client, "", "https://primary.example/api/tx/abcd/hex"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(hosts, []string{"primary.example"}) {
+		t.Errorf("requests went to %v, want the primary", hosts)
+	}
+
+	// when every endpoint is rate limited the caller sees the rate limit
+	down["primary.example"], down["backup.example"] = http.StatusTooManyRequests, http.StatusTooManyRequests
+	if _, err := HTTPGet(client, "", "https://backup.example/api/tx/abcd/hex"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got error %v, want %v", err, ErrRateLimited)
+	}
+}
+
+func TestFailoverTransportPassesOtherURLs(t *testing.T) {
+	transport := NewFailoverTransport(EsploraEndpoints...)
+	transport.Base = roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		if request.URL.String() != "https://blockstream.info/apix/tip" {
+			t.Errorf("request rewritten to %s", request.URL)
+		}
+		return respond(http.StatusServiceUnavailable, ""), nil
+	})
+	client := &http.Client{Transport: transport}
+	if _, err := HTTPGet(client, "", "https://blockstream.info/apix/tip"); err == nil {
+		t.Error("expected the error of the only request")
+	}
+
+	group, suffix := transport.match("https://blockstream.info/testnet/api/blocks/tip/height")
+	if len(group) != 2 || group[1].base != "https://mempool.space/testnet/api" || suffix != "/blocks/tip/height" {
+		t.Errorf("testnet URL matched %v with suffix %q", group, suffix)
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	transport := NewFailoverTransport(EsploraEndpoints...)
+	transport.Base = roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		if request.URL.Host == "mempool.space" {
+			return nil, errors.New("connection refused")
+		}
+		return respond(http.StatusOK, "800000"), nil
+	})
+
+	failures := transport.CheckHealth()
+	if len(failures) != 2 || failures["https://mempool.space/api"] == nil {
+		t.Errorf("got failures %v", failures)
+	}
+	if healthy := transport.Healthy(); !slices.Equal(healthy, []string{"https://blockstream.info/api", "https://blockstream.info/testnet/api"}) {
+		t.Errorf("got healthy endpoints %v", healthy)
+	}
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// HashBackend computes the SHA-256 digests behind Sha256Hash, Hash256 and Hash160. Only StdlibBackend
+// ships with the package; an assembly or SIMD implementation can be plugged in with RegisterHashBackend.
+type HashBackend interface {
+	Name() string
+	Sum256(data []byte) [32]byte
+}
+
+// StdlibBackend hashes with crypto/sha256, which uses the SHA extensions or AVX2 of the CPU when it has them
+type StdlibBackend struct{}
+
+func (StdlibBackend) Name() string { return "stdlib" }
+
+func (StdlibBackend) Sum256(data []byte) [32]byte { return sha256.Sum256(data) }
+
+var hashBackends = map[string]HashBackend{
+	"stdlib": StdlibBackend{},
+}
+
+var hashBackend atomic.Pointer[HashBackend]
+
+func init() {
+	SetHashBackend(StdlibBackend{})
+}
+
+// RegisterHashBackend makes backend selectable by name with SelectHashBackend. Call it from an init function.
+func RegisterHashBackend(backend HashBackend) {
+	hashBackends[backend.Name()] = backend
+}
+
+// SetHashBackend switches every hash of the package to backend. It is safe to call while hashing.
+func SetHashBackend(backend HashBackend) {
+	hashBackend.Store(&backend)
+}
+
+// SelectHashBackend switches to the registered backend called name
+func SelectHashBackend(name string) error {
+	backend, ok := hashBackends[name]
+	if !ok {
+		return fmt.Errorf("unknown hash backend %q", name)
+	}
+	SetHashBackend(backend)
+	return nil
+}
+
+// CurrentHashBackend returns the backend in use
+func CurrentHashBackend() HashBackend {
+	return *hashBackend.Load()
+}
+
+// hashBatchChunk is the number of inputs a worker of Hash256Batch takes at a time. Smaller batches
+// are hashed on the calling goroutine, starting workers costs more than hashing them.
+const hashBatchChunk = 128
+
+// Hash256Batch returns the Hash256 of every input, in order. The inputs are split in chunks hashed by
+// a pool of workers goroutines, GOMAXPROCS of them when workers is below one.
+func Hash256Batch(inputs [][]byte, workers int) [][]byte {
+	digests := make([][]byte, len(inputs))
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	chunks := (len(inputs) + hashBatchChunk - 1) / hashBatchChunk
+	workers = min(workers, chunks)
+	if workers <= 1 {
+		for i, input := range inputs {
+			digests[i] = Hash256(input)
+		}
+		return digests
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				start := int(next.Add(hashBatchChunk)) - hashBatchChunk
+				if start >= len(inputs) {
+					return
+				}
+				for i := start; i < min(start+hashBatchChunk, len(inputs)); i++ {
+					digests[i] = Hash256(inputs[i])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return digests
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestStdlibBackend(t *testing.T) {
+	digest := StdlibBackend{}.Sum256([]byte("abc"))
+	if got := hex.EncodeToString(digest[:]); got != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("sha256(abc) = %s", got)
+	}
+}
+
+// countingBackend is crypto/sha256 that counts its calls, to tell whether the package hashes with it
+type countingBackend struct{ calls *int }
+
+func (countingBackend) Name() string { return "counting" }
+
+func (c countingBackend) Sum256(data []byte) [32]byte {
+	*c.calls++
+	return sha256.Sum256(data)
+}
+
+func TestSelectHashBackend(t *testing.T) {
+	defer SetHashBackend(StdlibBackend{})
+
+	calls := 0
+	RegisterHashBackend(countingBackend{&calls})
+	defer delete(hashBackends, "counting")
+
+	want := Hash256([]byte("block header"))
+	if err := SelectHashBackend("counting"); err != nil {
+		t.Fatalf("SelectHashBackend failed: %v", err)
+	}
+	if name := CurrentHashBackend().Name(); name != "counting" {
+		t.Errorf("backend is %s after selecting counting", name)
+	}
+	if got := Hash256([]byte("block header")); !bytes.Equal(got, want) {
+		t.Errorf("Hash256 differs between backends: %x and %x", got, want)
+	}
+	if calls != 2 {
+		t.Errorf("Hash256 called the selected backend %d times, want 2", calls)
+	}
+	if err := SelectHashBackend("unknown"); err == nil {
+		t.Error("SelectHashBackend should reject an unknown backend")
+	}
+}
+
+func TestHash256Batch(t *testing.T) {
+	inputs := make([][]byte, 1000)
+	for i := range inputs {
+		inputs[i] = []byte(fmt.Sprintf("input %d", i))
+	}
+	for _, workers := range []int{0, 1, 3} {
+		digests := Hash256Batch(inputs, workers)
+		if len(digests) != len(inputs) {
+			t.Fatalf("%d workers: got %d digests for %d inputs", workers, len(digests), len(inputs))
+		}
+		for i, input := range inputs {
+			if !bytes.Equal(digests[i], Hash256(input)) {
+				t.Fatalf("%d workers: digest %d is wrong", workers, i)
+			}
+		}
+	}
+	if digests := Hash256Batch(nil, 0); len(digests) != 0 {
+		t.Errorf("got %d digests of no inputs", len(digests))
+	}
+}
+
+// BenchmarkHash256Batch compares hashing transactions one by one with Hash256Batch on all cores
+func BenchmarkHash256Batch(b *testing.B) {
+	inputs := make([][]byte, 4096)
+	for i := range inputs {
+		inputs[i] = make([]byte, 250)
+	}
+	b.Run("serial", func(b *testing.B) {
+		b.SetBytes(int64(len(inputs) * 250))
+		for i := 0; i < b.N; i++ {
+			for _, input := range inputs {
+				Hash256(input)
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		b.SetBytes(int64(len(inputs) * 250))
+		for i := 0; i < b.N; i++ {
+			Hash256Batch(inputs, 0)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is matched by an *HTTPError for a 404 response
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited is matched by an *HTTPError for a 429 response
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// HTTPError is a response with a status other than 200
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	// Body is the error message of the response
+	Body string
+	// RetryAfter is how long the server asks to wait before the next request, 0 when it did not say
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	message := fmt.Sprintf("%s %s: %s: %s", e.Method, e.URL, e.Status, e.Body)
+	if e.RetryAfter > 0 {
+		message += fmt.Sprintf(" (retry after %s)", e.RetryAfter)
+	}
+	return message
+}
+
+// Unwrap makes errors.Is match ErrNotFound and ErrRateLimited by the status code
+func (e *HTTPError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// HTTPGet fetches url with client, http.DefaultClient when nil, and returns the body of a 200 response.
+// Other responses return an *HTTPError. A non-empty userAgent replaces the default User-Agent header.
+func HTTPGet(client *http.Client, userAgent, url string) ([]byte, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return do(client, userAgent, request)
+}
+
+// HTTPPost posts body of contentType to url like HTTPGet fetches it, and returns the body of a 200 response
+func HTTPPost(client *http.Client, userAgent, url, contentType string, body []byte) ([]byte, error) {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", contentType)
+	return do(client, userAgent, request)
+}
+
+func do(client *http.Client, userAgent string, request *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if userAgent != "" {
+		request.Header.Set("User-Agent", userAgent)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, &HTTPError{
+			Method:     request.Method,
+			URL:        request.URL.String(),
+			StatusCode: response.StatusCode,
+			Status:     response.Status,
+			Body:       strings.TrimSpace(string(body)),
+			RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	return body, nil
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now).Round(time.Second)
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc answers requests without touching the network
+type roundTripFunc func(request *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func respond(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestHTTPGet(t *testing.T) {
+	var userAgent string
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		userAgent = request.Header.Get("User-Agent")
+		if request.URL.Path == "/missing" {
+			return respond(http.StatusNotFound, "not found\n"), nil
+		}
+		return respond(http.StatusOK, "hello"), nil
+	})}
+
+	body, err := HTTPGet(client, "test-agent/1.0", "https://example.com/hello")
+	if err != nil || string(body) != "hello" {
+		t.Fatalf("HTTPGet = %q, %v", body, err)
+	}
+	if userAgent != "test-agent/1.0" {
+		t.Errorf("User-Agent = %q, want test-agent/1.0", userAgent)
+	}
+
+	if _, err := HTTPGet(client, "", "https://example.com/missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected the error response in the error, got %v", err)
+	}
+	// the transport adds its default user agent to requests without one
+	if userAgent != "" {
+		t.Errorf("Without a user agent no header should be set, got %q", userAgent)
+	}
+}
+
+func TestHTTPGetErrors(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		switch request.URL.Path {
+		case "/missing":
+			return respond(http.StatusNotFound, "Transaction not found"), nil
+		case "/busy":
+			response := respond(http.StatusTooManyRequests, "Too Many Requests")
+			response.Header = http.Header{"Retry-After": []string{"30"}}
+			return response, nil
+		}
+		return respond(http.StatusInternalServerError, "oops"), nil
+	})}
+
+	_, err := HTTPGet(client, "", "https://example.com/missing")
+	var httpErr *HTTPError
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &httpErr) || httpErr.Body != "Transaction not found" {
+		t.Errorf("got %v, want a not found HTTPError", err)
+	}
+
+	_, err = HTTPGet(client, "", "https://example.com/busy")
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &httpErr) || httpErr.RetryAfter != 30*time.Second {
+		t.Errorf("got %v, want a rate limited HTTPError with a retry after 30s", err)
+	}
+
+	_, err = HTTPGet(client, "", "https://example.com/broken")
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrRateLimited) || !errors.As(err, &httpErr) || httpErr.StatusCode != 500 {
+		t.Errorf("got %v, want a plain HTTPError", err)
+	}
+}
+
+func TestHTTPPost(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(request.Body)
+		if request.Method != http.MethodPost || request.Header.Get("Content-Type") != "text/plain" {
+			return respond(http.StatusMethodNotAllowed, "want a text/plain POST"), nil
+		}
+		if string(body) == "bad" {
+			return respond(http.StatusBadRequest, "rejected"), nil
+		}
+		return respond(http.StatusOK, "got "+string(body)), nil
+	})}
+
+	body, err := HTTPPost(client, "", "https://example.com/tx", "text/plain", []byte("0100"))
+	if err != nil || string(body) != "got 0100" {
+		t.Fatalf("HTTPPost = %q, %v", body, err)
+	}
+
+	_, err = HTTPPost(client, "", "https://example.com/tx", "text/plain", []byte("bad"))
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest || !strings.HasPrefix(err.Error(), "POST ") {
+		t.Errorf("got %v, want a POST HTTPError", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"Mon, 01 Jan 2024 12:01:30 GMT", 90 * time.Second},
+		{"Mon, 01 Jan 2024 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"slices"
+)
+
+// Uint256 is an unsigned 256 bit integer such as a hash, a target or an amount of chainwork.
+// It is stored big endian, the order hashes are displayed in. Hash256 digests are the reverse of it.
+type Uint256 [32]byte
+
+// Uint256FromBytes interprets b as a big endian number of at most 32 bytes
+func Uint256FromBytes(b []byte) (Uint256, error) {
+	var u Uint256
+	if len(b) > len(u) {
+		return u, fmt.Errorf("%d bytes do not fit a uint256", len(b))
+	}
+	copy(u[len(u)-len(b):], b)
+	return u, nil
+}
+
+// Uint256FromDigest interprets a 32 byte digest as a little endian number, the way hashes are compared to targets
+func Uint256FromDigest(digest []byte) (Uint256, error) {
+	var u Uint256
+	if len(digest) != len(u) {
+		return u, fmt.Errorf("digest of %d bytes is not 32 bytes", len(digest))
+	}
+	copy(u[:], digest)
+	slices.Reverse(u[:])
+	return u, nil
+}
+
+// Uint256FromBig converts n, which must be positive or zero and fit in 256 bits
+func Uint256FromBig(n *big.Int) (Uint256, error) {
+	if n.Sign() < 0 {
+		return Uint256{}, fmt.Errorf("negative number %s does not fit a uint256", n)
+	}
+	if n.BitLen() > 256 {
+		return Uint256{}, fmt.Errorf("number of %d bits does not fit a uint256", n.BitLen())
+	}
+	var u Uint256
+	n.FillBytes(u[:])
+	return u, nil
+}
+
+// ParseUint256Hex parses 64 hex characters in display order, the way txids and block hashes are written
+func ParseUint256Hex(s string) (Uint256, error) {
+	var u Uint256
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return u, err
+	}
+	if len(b) != len(u) {
+		return u, fmt.Errorf("hex %q is not 32 bytes", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+// ParseUint256HexLE parses 64 hex characters in the little endian byte order of the serialization
+func ParseUint256HexLE(s string) (Uint256, error) {
+	u, err := ParseUint256Hex(s)
+	if err != nil {
+		return u, err
+	}
+	slices.Reverse(u[:])
+	return u, nil
+}
+
+// Big returns the number as a big.Int
+func (u Uint256) Big() *big.Int {
+	return new(big.Int).SetBytes(u[:])
+}
+
+// LittleEndian returns the bytes of the number in the byte order of the serialization
+func (u Uint256) LittleEndian() []byte {
+	b := slices.Clone(u[:])
+	slices.Reverse(b)
+	return b
+}
+
+// String returns the number as 64 hex characters in display order
+func (u Uint256) String() string {
+	return hex.EncodeToString(u[:])
+}
+
+// HexLE returns the number as 64 hex characters in the byte order of the serialization
+func (u Uint256) HexLE() string {
+	return hex.EncodeToString(u.LittleEndian())
+}
+
+// Cmp returns -1, 0 or +1 when u is smaller than, equal to or larger than v
+func (u Uint256) Cmp(v Uint256) int {
+	return bytes.Compare(u[:], v[:])
+}
+
+func (u Uint256) IsZero() bool {
+	return u == Uint256{}
+}
+
+// Add returns u+v and whether it overflowed 256 bits
+func (u Uint256) Add(v Uint256) (Uint256, bool) {
+	a, b := u.words(), v.words()
+	var sum [4]uint64
+	var carry uint64
+	for i := range sum {
+		sum[i], carry = bits.Add64(a[i], b[i], carry)
+	}
+	return fromWords(sum), carry != 0
+}
+
+// Sub returns u-v and whether it went below zero
+func (u Uint256) Sub(v Uint256) (Uint256, bool) {
+	a, b := u.words(), v.words()
+	var diff [4]uint64
+	var borrow uint64
+	for i := range diff {
+		diff[i], borrow = bits.Sub64(a[i], b[i], borrow)
+	}
+	return fromWords(diff), borrow != 0
+}
+
+// Mul64 returns u*n and whether it overflowed 256 bits
+func (u Uint256) Mul64(n uint64) (Uint256, bool) {
+	a := u.words()
+	var product [4]uint64
+	var carry uint64
+	for i := range product {
+		hi, lo := bits.Mul64(a[i], n)
+		var c uint64
+		product[i], c = bits.Add64(lo, carry, 0)
+		carry = hi + c
+	}
+	return fromWords(product), carry != 0
+}
+
+// Div64 returns u/n rounded down. It panics when n is zero.
+func (u Uint256) Div64(n uint64) Uint256 {
+	a := u.words()
+	var quotient [4]uint64
+	var remainder uint64
+	for i := len(a) - 1; i >= 0; i-- {
+		quotient[i], remainder = bits.Div64(remainder, a[i], n)
+	}
+	return fromWords(quotient)
+}
+
+// words returns the number as 64 bit words, least significant first
+func (u Uint256) words() [4]uint64 {
+	var w [4]uint64
+	for i := range w {
+		w[i] = binary.BigEndian.Uint64(u[32-8*(i+1):])
+	}
+	return w
+}
+
+func fromWords(w [4]uint64) Uint256 {
+	var u Uint256
+	for i := range w {
+		binary.BigEndian.PutUint64(u[32-8*(i+1):], w[i])
+	}
+	return u
+}
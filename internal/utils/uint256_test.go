@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestUint256Parsing(t *testing.T) {
+	displayHex := "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	u, err := ParseUint256Hex(displayHex)
+	if err != nil {
+		t.Fatalf("ParseUint256Hex error: %v", err)
+	}
+	if u.String() != displayHex {
+		t.Errorf("String = %s, want %s", u, displayHex)
+	}
+	le, err := ParseUint256HexLE(u.HexLE())
+	if err != nil || le != u {
+		t.Errorf("ParseUint256HexLE of HexLE = %s, %v", le, err)
+	}
+	fromDigest, err := Uint256FromDigest(u.LittleEndian())
+	if err != nil || fromDigest != u {
+		t.Errorf("Uint256FromDigest of LittleEndian = %s, %v", fromDigest, err)
+	}
+	fromBig, err := Uint256FromBig(u.Big())
+	if err != nil || fromBig != u {
+		t.Errorf("Uint256FromBig of Big = %s, %v", fromBig, err)
+	}
+	fromBytes, err := Uint256FromBytes(u.Big().Bytes())
+	if err != nil || fromBytes != u {
+		t.Errorf("Uint256FromBytes of a short big endian number = %s, %v", fromBytes, err)
+	}
+
+	for _, s := range []string{"", "00", displayHex + "00", "zz" + displayHex[2:]} {
+		if _, err := ParseUint256Hex(s); err == nil {
+			t.Errorf("ParseUint256Hex(%q) should fail", s)
+		}
+	}
+	if _, err := Uint256FromBig(big.NewInt(-1)); err == nil {
+		t.Errorf("Uint256FromBig of a negative number should fail")
+	}
+	if _, err := Uint256FromBig(new(big.Int).Lsh(big.NewInt(1), 256)); err == nil {
+		t.Errorf("Uint256FromBig of 2^256 should fail")
+	}
+	if _, err := Uint256FromBytes(make([]byte, 33)); err == nil {
+		t.Errorf("Uint256FromBytes of 33 bytes should fail")
+	}
+	if _, err := Uint256FromDigest(make([]byte, 20)); err == nil {
+		t.Errorf("Uint256FromDigest of 20 bytes should fail")
+	}
+}
+
+func TestUint256Arithmetic(t *testing.T) {
+	max256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	rng := rand.New(rand.NewSource(1))
+	random := func() *big.Int {
+		return new(big.Int).Rand(rng, max256)
+	}
+
+	for i := 0; i < 100; i++ {
+		a, b := random(), random()
+		ua, _ := Uint256FromBig(a)
+		ub, _ := Uint256FromBig(b)
+		n := rng.Uint64() | 1
+
+		sum, overflow := ua.Add(ub)
+		wantSum := new(big.Int).Add(a, b)
+		if overflow != (wantSum.Cmp(max256) > 0) || sum.Big().Cmp(wantSum.And(wantSum, max256)) != 0 {
+			t.Fatalf("%x + %x = %s, overflow %v", a, b, sum, overflow)
+		}
+
+		diff, borrow := ua.Sub(ub)
+		wantDiff := new(big.Int).Sub(a, b)
+		if borrow != (wantDiff.Sign() < 0) || diff.Big().Cmp(wantDiff.And(wantDiff, max256)) != 0 {
+			t.Fatalf("%x - %x = %s, borrow %v", a, b, diff, borrow)
+		}
+
+		product, overflow := ua.Mul64(n)
+		wantProduct := new(big.Int).Mul(a, new(big.Int).SetUint64(n))
+		if overflow != (wantProduct.Cmp(max256) > 0) || product.Big().Cmp(wantProduct.And(wantProduct, max256)) != 0 {
+			t.Fatalf("%x * %d = %s, overflow %v", a, n, product, overflow)
+		}
+
+		quotient := ua.Div64(n)
+		if quotient.Big().Cmp(new(big.Int).Div(a, new(big.Int).SetUint64(n))) != 0 {
+			t.Fatalf("%x / %d = %s", a, n, quotient)
+		}
+
+		if ua.Cmp(ub) != a.Cmp(b) {
+			t.Fatalf("Cmp(%x, %x) = %d", a, b, ua.Cmp(ub))
+		}
+	}
+
+	if !(Uint256{}).IsZero() {
+		t.Errorf("Zero value should be zero")
+	}
+}
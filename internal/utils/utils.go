@@ -8,11 +8,12 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"hash"
 	"math/big"
 	"strconv"
 	"strings"
 
-	"golang.org/x/crypto/ripemd160"
+	"github.com/caspereijkens/cryptocurrency/internal/ripemd160"
 )
 
 const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
@@ -67,9 +68,48 @@ func DecodeBase58(s string) ([]byte, error) {
 	return combined[1:21], nil
 }
 
+// DecodeBase58Checksum decodes a Base58Check string of any length, such as a WIF key or an extended key,
+// and returns the payload including its version bytes, without the checksum
+func DecodeBase58Checksum(s string) ([]byte, error) {
+	num := new(big.Int)
+	for _, c := range s {
+		digit := strings.IndexRune(base58Alphabet, c)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		num.Mul(num, big.NewInt(58))
+		num.Add(num, big.NewInt(int64(digit)))
+	}
+
+	// every leading 1 encodes a zero byte
+	zeros := len(s) - len(strings.TrimLeft(s, "1"))
+	combined := append(make([]byte, zeros), num.Bytes()...)
+	if len(combined) < 5 {
+		return nil, fmt.Errorf("base58 string %q is too short for a checksum", s)
+	}
+
+	payload, checksum := combined[:len(combined)-4], combined[len(combined)-4:]
+	if !bytes.Equal(Hash256(payload)[:4], checksum) {
+		return nil, fmt.Errorf("bad checksum: %x %x", checksum, Hash256(payload)[:4])
+	}
+	return payload, nil
+}
+
 func Hash256(data []byte) []byte {
-	sha256Digest := Sha256Hash(data)
-	return Sha256Hash(sha256Digest)
+	backend := CurrentHashBackend()
+	first := backend.Sum256(data)
+	second := backend.Sum256(first[:])
+	return second[:]
+}
+
+// TaggedHash is the BIP340 hash sha256(sha256(tag) || sha256(tag) || data), which keeps hashes for different purposes apart
+func TaggedHash(tag string, data []byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	h.Write(data)
+	return h.Sum(nil)
 }
 
 // HmacSHA256 computes the HMAC SHA-256 digest of the data using the given key
@@ -111,8 +151,8 @@ func LstripNullBytes(data []byte) []byte {
 
 // sha256 followed by ripemd160
 func Hash160(s []byte) []byte {
-	sha256Digest := Sha256Hash(s)
-	ripemd160Digest := Ripemd160Hash(sha256Digest)
+	sha256Digest := CurrentHashBackend().Sum256(s)
+	ripemd160Digest := Ripemd160Hash(sha256Digest[:])
 	return ripemd160Digest
 }
 
@@ -123,13 +163,17 @@ func Sha1Hash(s []byte) []byte {
 }
 
 func Sha256Hash(s []byte) []byte {
-	sha256Hash := sha256.New()
-	sha256Hash.Write(s)
-	return sha256Hash.Sum(nil)
+	digest := CurrentHashBackend().Sum256(s)
+	return digest[:]
+}
+
+// NewRipemd160 returns a hash.Hash computing RIPEMD-160
+func NewRipemd160() hash.Hash {
+	return ripemd160.New()
 }
 
 func Ripemd160Hash(s []byte) []byte {
-	ripemd160Hash := ripemd160.New()
+	ripemd160Hash := NewRipemd160()
 	ripemd160Hash.Write(s)
 	return ripemd160Hash.Sum(nil)
 }
@@ -3,6 +3,7 @@ package utils
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"math/big"
@@ -147,6 +148,36 @@ func TestDecodeBase58(t *testing.T) {
 	}
 }
 
+func TestDecodeBase58Checksum(t *testing.T) {
+	payloads := []string{
+		"6f507b27411ccf7f16f10297de6cef3f291623eddf",
+		"0000d52ad7ca9b3d096a38e752c2018e6fbc40cdf26f",
+		"ef0000000000000000000000000000000000000000000000000000000000000001",
+	}
+	for _, payloadHex := range payloads {
+		payload, _ := hex.DecodeString(payloadHex)
+		encoded := EncodeBase58Checksum(append([]byte{}, payload...))
+		result, err := DecodeBase58Checksum(encoded)
+		if err != nil {
+			t.Errorf("decoding %s failed: %v", encoded, err)
+			continue
+		}
+		if !bytes.Equal(result, payload) {
+			t.Errorf("decoding %s returned %x, want %s", encoded, result, payloadHex)
+		}
+	}
+
+	if _, err := DecodeBase58Checksum("mnrVtF8DWjMu839VW3rBfgYaAfKk8983Xg"); err == nil {
+		t.Error("expected a bad checksum to be rejected")
+	}
+	if _, err := DecodeBase58Checksum("mnrVtF8DWjMu839VW3rBfgYaAfKk8983X0"); err == nil {
+		t.Error("expected an invalid character to be rejected")
+	}
+	if _, err := DecodeBase58Checksum("11"); err == nil {
+		t.Error("expected a string without checksum to be rejected")
+	}
+}
+
 func TestHash160(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -458,3 +489,14 @@ func TestReverseBytes(t *testing.T) {
 		}
 	}
 }
+
+func TestTaggedHash(t *testing.T) {
+	tag := sha256.Sum256([]byte("TapLeaf"))
+	want := sha256.Sum256(append(append(tag[:], tag[:]...), 0xc0))
+	if got := TaggedHash("TapLeaf", []byte{0xc0}); !bytes.Equal(got, want[:]) {
+		t.Errorf("TaggedHash = %x, want %x", got, want)
+	}
+	if bytes.Equal(TaggedHash("TapLeaf", nil), TaggedHash("TapBranch", nil)) {
+		t.Error("different tags produced the same hash")
+	}
+}
@@ -0,0 +1,171 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+)
+
+// DefaultName is the wallet of commands that are not given one
+const DefaultName = "default"
+
+var (
+	// ErrWalletNotFound is wrapped when a wallet that was never created is loaded
+	ErrWalletNotFound = errors.New("wallet not found")
+	// ErrWalletExists is wrapped when a wallet is created with the name of an existing one
+	ErrWalletExists = errors.New("wallet already exists")
+)
+
+// validName is what a wallet name may hold, it is the name of the directory of the wallet
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Manager keeps named wallets in a directory, one subdirectory per wallet. A wallet is loaded once, every Load of
+// the same name returns the same Wallet, so one process can use any number of wallets from several goroutines.
+type Manager struct {
+	dir    string
+	mu     sync.Mutex
+	loaded map[string]*Wallet
+}
+
+func NewManager(dir string) *Manager {
+	return &Manager{dir: dir, loaded: make(map[string]*Wallet)}
+}
+
+// Create makes a new empty wallet called name on network, saves it and loads it
+func (m *Manager) Create(name string, network *params.Params) (*Wallet, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.loaded[name]; ok || m.exists(name) {
+		return nil, fmt.Errorf("%s: %w", name, ErrWalletExists)
+	}
+
+	w := newWallet(filepath.Join(m.dir, name), name, network)
+	if err := w.Save(); err != nil {
+		return nil, err
+	}
+	m.loaded[name] = w
+	return w, nil
+}
+
+// Load returns the wallet called name, reading it from its directory the first time
+func (m *Manager) Load(name string) (*Wallet, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if w, ok := m.loaded[name]; ok {
+		return w, nil
+	}
+	if !m.exists(name) {
+		return nil, fmt.Errorf("%s: %w", name, ErrWalletNotFound)
+	}
+
+	w, err := loadWallet(filepath.Join(m.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	if w.Name != name {
+		return nil, fmt.Errorf("wallet in directory %s is called %s", name, w.Name)
+	}
+	m.loaded[name] = w
+	return w, nil
+}
+
+// Default loads the DefaultName wallet, creating it on network when there is none yet
+func (m *Manager) Default(network *params.Params) (*Wallet, error) {
+	w, err := m.Load(DefaultName)
+	if errors.Is(err, ErrWalletNotFound) {
+		w, err = m.Create(DefaultName, network)
+		if errors.Is(err, ErrWalletExists) {
+			// another goroutine created it in the meantime
+			return m.Load(DefaultName)
+		}
+	}
+	return w, err
+}
+
+// Unload saves the wallet called name and forgets it, the next Load reads it again
+func (m *Manager) Unload(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.loaded[name]
+	if !ok {
+		return fmt.Errorf("%s is not loaded", name)
+	}
+	if err := w.Save(); err != nil {
+		return err
+	}
+	delete(m.loaded, name)
+	return nil
+}
+
+// Loaded returns the names of the loaded wallets, sorted
+func (m *Manager) Loaded() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sortedLoaded()
+}
+
+// List returns the names of the wallets in the directory, sorted. A missing directory has none.
+func (m *Manager) List() ([]string, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && m.exists(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	// ReadDir sorts by file name
+	return names, nil
+}
+
+// SaveAll saves every loaded wallet and returns the first error
+func (m *Manager) SaveAll() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, name := range m.sortedLoaded() {
+		if err := m.loaded[name].Save(); err != nil {
+			return fmt.Errorf("wallet %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) sortedLoaded() []string {
+	names := make([]string, 0, len(m.loaded))
+	for name := range m.loaded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// exists returns whether the directory holds a wallet called name
+func (m *Manager) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(m.dir, name, walletFileName))
+	return err == nil
+}
+
+// checkName rejects names that are not a single directory name, such as ones with a path separator
+func checkName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid wallet name %q, use letters, digits, '_', '.' and '-'", name)
+	}
+	return nil
+}
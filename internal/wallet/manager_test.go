@@ -0,0 +1,113 @@
+package wallet
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+)
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewManager(dir)
+
+	signet, err := manager.Create("signet", params.SigNet)
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	if _, err := manager.Create("signet", params.MainNet); !errors.Is(err, ErrWalletExists) {
+		t.Errorf("Creating signet twice = %v, want ErrWalletExists", err)
+	}
+	mainnet, err := manager.Create("mainnet", params.MainNet)
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+
+	// the wallets share no keys and no addresses
+	key, _ := signatureverification.NewPrivateKey(big.NewInt(8675309))
+	if err := signet.Keys.Add(key); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	if _, ok := mainnet.Keys.LookupAddress(key.Point.Address(true, false), false); ok {
+		t.Errorf("A key of the signet wallet should not be in the mainnet wallet")
+	}
+	signet.Watch(testnetAddress)
+	mainnet.Watch(mainnetAddress)
+	if len(signet.Addresses()) != 1 || len(mainnet.Addresses()) != 1 {
+		t.Errorf("Addresses %v and %v, want one each", signet.Addresses(), mainnet.Addresses())
+	}
+
+	if loaded, err := manager.Load("signet"); err != nil || loaded != signet {
+		t.Errorf("Load of a loaded wallet should return it, got %v", err)
+	}
+	if err := manager.Unload("signet"); err != nil {
+		t.Fatalf("Unload error: %v", err)
+	}
+	if loaded := manager.Loaded(); len(loaded) != 1 || loaded[0] != "mainnet" {
+		t.Errorf("Loaded = %v, want [mainnet]", loaded)
+	}
+
+	// another manager of the directory reads what the first one saved
+	other := NewManager(dir)
+	reloaded, err := other.Load("signet")
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if reloaded.Network != params.SigNet || len(reloaded.Addresses()) != 1 || reloaded.Addresses()[0] != testnetAddress {
+		t.Errorf("Reloaded signet wallet on %s with %v", reloaded.Network.Name, reloaded.Addresses())
+	}
+	if reloaded.Fetcher.GetURL(true) != signetEsploraURL {
+		t.Errorf("Signet wallet fetches from %s, want %s", reloaded.Fetcher.GetURL(true), signetEsploraURL)
+	}
+	if _, ok := reloaded.Keys.LookupAddress(key.Point.Address(true, true), true); ok {
+		t.Errorf("Private keys should not be saved")
+	}
+	if names, err := other.List(); err != nil || len(names) != 2 || names[0] != "mainnet" || names[1] != "signet" {
+		t.Errorf("List = %v, %v, want [mainnet signet]", names, err)
+	}
+
+	if _, err := other.Load("missing"); !errors.Is(err, ErrWalletNotFound) {
+		t.Errorf("Load of a missing wallet = %v, want ErrWalletNotFound", err)
+	}
+	for _, name := range []string{"", "..", "a/b", ".hidden"} {
+		if _, err := other.Create(name, params.MainNet); err == nil {
+			t.Errorf("Create(%q) should fail", name)
+		}
+	}
+}
+
+func TestManagerDefault(t *testing.T) {
+	manager := NewManager(t.TempDir())
+
+	// concurrent first uses all end up with the one default wallet
+	wallets := make([]*Wallet, 8)
+	var wg sync.WaitGroup
+	for i := range wallets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w, err := manager.Default(params.TestNet3)
+			if err != nil {
+				t.Errorf("Default error: %v", err)
+			}
+			wallets[i] = w
+		}(i)
+	}
+	wg.Wait()
+	for _, w := range wallets {
+		if w != wallets[0] {
+			t.Fatalf("Default returned different wallets")
+		}
+	}
+	if wallets[0].Name != DefaultName || wallets[0].Network != params.TestNet3 {
+		t.Errorf("Default wallet %s on %s", wallets[0].Name, wallets[0].Network.Name)
+	}
+
+	// the network only applies to a new default wallet
+	if w, err := manager.Default(params.MainNet); err != nil || w.Network != params.TestNet3 {
+		t.Errorf("Existing default wallet should keep its network, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/internal/esplora"
+	"github.com/caspereijkens/cryptocurrency/internal/monitor"
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+// The files of a wallet in its directory: the watched addresses and the transactions fetched for it
+const (
+	walletFileName = "wallet.json"
+	cacheFileName  = "txcache"
+)
+
+// signetEsploraURL is the Esplora API of the default signet, the fetchers only know the mainnet and testnet3 ones
+const signetEsploraURL = "https://mempool.space/signet/api"
+
+// Wallet is one named wallet of a Manager. It has a key store, transaction cache and watched addresses of its own,
+// so wallets on different networks, such as one for signet experiments and one for mainnet, never mix. All its
+// methods are safe for concurrent use.
+type Wallet struct {
+	Name    string
+	Network *params.Params
+	// Keys resolve the inputs the wallet signs. They only live in memory, the wallet files hold no private keys.
+	Keys *transaction.KeyStore
+	// Fetcher fetches the transactions of the wallet, its cache is saved with the wallet
+	Fetcher *transaction.TxFetcher
+
+	dir       string
+	mu        sync.Mutex
+	addresses []string
+}
+
+// walletFile is the JSON serialization of a wallet in walletFileName
+type walletFile struct {
+	Name      string   `json:"name"`
+	Network   string   `json:"network"`
+	Addresses []string `json:"addresses"`
+}
+
+func newWallet(dir, name string, network *params.Params) *Wallet {
+	fetcher := transaction.NewTxFetcher()
+	fetcher.BaseURL = esploraURL(network)
+	return &Wallet{
+		Name:    name,
+		Network: network,
+		Keys:    transaction.NewKeyStore(),
+		Fetcher: fetcher,
+		dir:     dir,
+	}
+}
+
+// esploraURL returns the Esplora API the fetchers of a wallet on network use, empty for the public one they default to
+func esploraURL(network *params.Params) string {
+	if network == params.SigNet {
+		return signetEsploraURL
+	}
+	return ""
+}
+
+// loadWallet reads the wallet stored in dir, with its transaction cache when it has one
+func loadWallet(dir string) (*Wallet, error) {
+	data, err := os.ReadFile(filepath.Join(dir, walletFileName))
+	if err != nil {
+		return nil, err
+	}
+	var file walletFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("bad wallet file in %s: %v", dir, err)
+	}
+	network, ok := params.ByName(file.Network)
+	if !ok {
+		return nil, fmt.Errorf("wallet %s is on unknown network %q", file.Name, file.Network)
+	}
+
+	w := newWallet(dir, file.Name, network)
+	if err := w.Watch(file.Addresses...); err != nil {
+		return nil, fmt.Errorf("wallet %s: %w", file.Name, err)
+	}
+	if err := w.Fetcher.LoadCache(filepath.Join(dir, cacheFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("wallet %s: %w", file.Name, err)
+	}
+	return w, nil
+}
+
+// Testnet returns whether the wallet is on a test network, testnet3 or signet, whose addresses it watches
+func (w *Wallet) Testnet() bool {
+	return w.Network != params.MainNet
+}
+
+// Watch adds addresses to the ones of the wallet. Addresses of another network are rejected.
+func (w *Wallet) Watch(addresses ...string) error {
+	for _, address := range addresses {
+		if _, err := transaction.AddressScriptPubkey(address, w.Testnet()); err != nil {
+			return fmt.Errorf("cannot watch %s: %w", address, err)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, address := range addresses {
+		if !w.watching(address) {
+			w.addresses = append(w.addresses, address)
+		}
+	}
+	return nil
+}
+
+func (w *Wallet) watching(address string) bool {
+	for _, watched := range w.addresses {
+		if watched == address {
+			return true
+		}
+	}
+	return false
+}
+
+// Addresses returns the watched addresses in the order they were added
+func (w *Wallet) Addresses() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.addresses...)
+}
+
+// Monitor returns a monitor of the addresses of the wallet on its network. Its histories belong to this wallet,
+// a monitor of another wallet reports none of its events. A nil backend polls the Esplora API of the network
+// with the Fetcher of the wallet.
+func (w *Wallet) Monitor(backend monitor.Backend, handler func(event monitor.Event)) *monitor.Monitor {
+	if backend == nil {
+		headerFetcher := block.NewHeaderFetcher()
+		headerFetcher.BaseURL = esploraURL(w.Network)
+		backend = &esplora.Backend{TxFetcher: w.Fetcher, HeaderFetcher: headerFetcher}
+	}
+	m := monitor.NewMonitor(backend, w.Testnet(), handler)
+	m.Watch(w.Addresses()...)
+	return m
+}
+
+// Save writes the addresses and the transaction cache of the wallet to its directory
+func (w *Wallet) Save() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, err := json.MarshalIndent(walletFile{Name: w.Name, Network: w.Network.Name, Addresses: w.addresses}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(w.dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(w.dir, walletFileName), data, 0644); err != nil {
+		return err
+	}
+	return w.Fetcher.DumpCache(filepath.Join(w.dir, cacheFileName))
+}
@@ -0,0 +1,89 @@
+package wallet
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/internal/monitor"
+	"github.com/caspereijkens/cryptocurrency/internal/params"
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+)
+
+const (
+	mainnetAddress = "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"
+	testnetAddress = "mzx5YhAH9kNHtcN481u6WkjeHjYtVeKVh2"
+)
+
+// recordingBackend records the addresses it is asked for and the network of every request
+type recordingBackend struct {
+	mu        sync.Mutex
+	addresses []string
+	testnet   []bool
+}
+
+func (b *recordingBackend) FetchAddressTxs(address string, testnet bool) ([]*transaction.AddressTx, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.addresses = append(b.addresses, address)
+	b.testnet = append(b.testnet, testnet)
+	return nil, nil
+}
+
+func (b *recordingBackend) FetchStatus(txID string, testnet bool) (*transaction.TxStatus, error) {
+	return &transaction.TxStatus{}, nil
+}
+
+func (b *recordingBackend) FetchTipHeight(testnet bool) (uint32, error) {
+	return 100, nil
+}
+
+func TestWalletWatch(t *testing.T) {
+	w := newWallet(t.TempDir(), "signet", params.SigNet)
+	if err := w.Watch(testnetAddress, testnetAddress); err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+	if err := w.Watch(mainnetAddress); err == nil {
+		t.Errorf("A signet wallet should not watch a mainnet address")
+	}
+	if addresses := w.Addresses(); len(addresses) != 1 || addresses[0] != testnetAddress {
+		t.Errorf("Addresses = %v, want only %s", addresses, testnetAddress)
+	}
+
+	backend := &recordingBackend{}
+	m := w.Monitor(backend, func(event monitor.Event) {})
+	if err := m.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll error: %v", err)
+	}
+	if len(backend.addresses) != 1 || backend.addresses[0] != testnetAddress || !backend.testnet[0] {
+		t.Errorf("Monitor polled %v on testnet %v, want %s on testnet", backend.addresses, backend.testnet, testnetAddress)
+	}
+}
+
+func TestWalletSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	w := newWallet(dir, "experiments", params.TestNet3)
+	if err := w.Watch(testnetAddress); err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+	if err := w.Save(); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	loaded, err := loadWallet(dir)
+	if err != nil {
+		t.Fatalf("loadWallet error: %v", err)
+	}
+	if loaded.Name != "experiments" || loaded.Network != params.TestNet3 {
+		t.Errorf("Loaded wallet %s on %s, want experiments on testnet3", loaded.Name, loaded.Network.Name)
+	}
+	if loaded.Fetcher.GetURL(true) != transaction.NewTxFetcher().GetURL(true) {
+		t.Errorf("A testnet3 wallet should fetch from the public testnet API, not %s", loaded.Fetcher.GetURL(true))
+	}
+	if addresses := loaded.Addresses(); len(addresses) != 1 || addresses[0] != testnetAddress {
+		t.Errorf("Loaded addresses = %v", addresses)
+	}
+	if loaded.Keys == w.Keys || loaded.Fetcher == w.Fetcher {
+		t.Errorf("A loaded wallet should have a key store and fetcher of its own")
+	}
+}
@@ -0,0 +1,51 @@
+// Package block is the public API for block headers, full blocks, the subsidy schedule and block templates
+package block
+
+import (
+	"bufio"
+	"bytes"
+
+	iblock "github.com/caspereijkens/cryptocurrency/internal/block"
+	"github.com/caspereijkens/cryptocurrency/pkg/script"
+)
+
+type (
+	// Header is an 80 byte block header
+	Header = iblock.Block
+	// FullBlock is a header together with its transactions
+	FullBlock = iblock.FullBlock
+	// Template is a block ready for mining
+	Template = iblock.BlockTemplate
+	// MempoolEntry is a transaction a template may include, with its fee
+	MempoolEntry = iblock.MempoolEntry
+)
+
+// ParseHeader parses an 80 byte block header
+func ParseHeader(raw []byte) (*Header, error) {
+	return iblock.ParseHeader(raw)
+}
+
+// ParseFullBlock parses a serialized block including its transactions
+func ParseFullBlock(raw []byte, testnet bool) (*FullBlock, error) {
+	return iblock.ParseFullBlock(bufio.NewReader(bytes.NewReader(raw)), testnet)
+}
+
+// Genesis returns the genesis header of mainnet or testnet
+func Genesis(testnet bool) *Header {
+	return iblock.Genesis(testnet)
+}
+
+// Subsidy returns the satoshis a block at height creates
+func Subsidy(height uint32) uint64 {
+	return iblock.BlockSubsidy(height)
+}
+
+// TotalSupplyAt returns the satoshis created by the blocks up to and including height
+func TotalSupplyAt(height uint32) uint64 {
+	return iblock.TotalSupplyAt(height)
+}
+
+// AssembleTemplate builds a block on prev at height from the mempool, paying the subsidy and fees to payout
+func AssembleTemplate(prev *Header, mempool []*MempoolEntry, height uint32, payout *script.Script, testnet bool) (*Template, error) {
+	return iblock.AssembleBlockTemplate(prev, mempool, height, payout, testnet)
+}
@@ -0,0 +1,24 @@
+package block
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestGenesis(t *testing.T) {
+	raw, err := Genesis(false).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := ParseHeader(raw)
+	if err != nil {
+		t.Fatalf("ParseHeader failed: %v", err)
+	}
+	hash, _ := header.Hash()
+	if got := hex.EncodeToString(hash); got != "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f" {
+		t.Errorf("genesis hash is %s", got)
+	}
+	if Subsidy(0) != 5000000000 || TotalSupplyAt(0) != 5000000000 {
+		t.Errorf("genesis subsidy is %d", Subsidy(0))
+	}
+}
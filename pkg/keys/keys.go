@@ -0,0 +1,58 @@
+// Package keys is the public API for secp256k1 keys, ECDSA signatures and the addresses derived from them
+package keys
+
+import (
+	"math/big"
+
+	"github.com/caspereijkens/cryptocurrency/internal/signatureverification"
+	"github.com/caspereijkens/cryptocurrency/internal/utils"
+)
+
+// PrivateKey is a secret scalar together with its public key
+type PrivateKey = signatureverification.PrivateKey
+
+// PublicKey is a point on secp256k1
+type PublicKey = signatureverification.S256Point
+
+// Signature is an ECDSA signature
+type Signature = signatureverification.Signature
+
+// NewPrivateKey returns the key for secret, which has to be between 1 and the order of the curve
+func NewPrivateKey(secret *big.Int) (*PrivateKey, error) {
+	return signatureverification.NewPrivateKey(secret)
+}
+
+// ParsePublicKey parses a compressed or uncompressed SEC public key
+func ParsePublicKey(sec []byte) (*PublicKey, error) {
+	return signatureverification.ParseSEC(sec)
+}
+
+// ParseSignature parses a DER signature without the trailing hash type
+func ParseSignature(der []byte) (*Signature, error) {
+	return signatureverification.ParseDER(der)
+}
+
+// Hash160 returns ripemd160(sha256(data)), the hash P2PKH and P2SH outputs commit to
+func Hash160(data []byte) []byte {
+	return utils.Hash160(data)
+}
+
+// P2PKHAddress returns the Base58Check address of the public key hash h160
+func P2PKHAddress(h160 []byte, testnet bool) string {
+	return utils.H160ToP2PKHAddress(h160, testnet)
+}
+
+// P2SHAddress returns the Base58Check address of the script hash h160
+func P2SHAddress(h160 []byte, testnet bool) string {
+	return utils.H160ToP2SHAddress(h160, testnet)
+}
+
+// SegwitAddress returns the bech32 or bech32m address of a witness program
+func SegwitAddress(witnessVersion byte, program []byte, testnet bool) (string, error) {
+	return utils.EncodeSegwitAddress(witnessVersion, program, testnet)
+}
+
+// DecodeSegwitAddress returns the witness version and program of a segwit address
+func DecodeSegwitAddress(address string, testnet bool) (byte, []byte, error) {
+	return utils.DecodeSegwitAddress(address, testnet)
+}
@@ -0,0 +1,41 @@
+package keys
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	key, err := NewPrivateKey(big.NewInt(5002))
+	if err != nil {
+		t.Fatalf("NewPrivateKey failed: %v", err)
+	}
+	if address := P2PKHAddress(key.Point.Hash160(false), true); address != "mmTPbXQFxboEtNRkwfh6K51jvdtHLxGeMA" {
+		t.Errorf("got address %s", address)
+	}
+
+	z := big.NewInt(0xcafe)
+	sig, err := key.Sign(z)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	parsedSig, err := ParseSignature(sig.Serialize())
+	if err != nil {
+		t.Fatalf("ParseSignature failed: %v", err)
+	}
+	publicKey, err := ParsePublicKey(key.Point.Serialize(true))
+	if err != nil {
+		t.Fatalf("ParsePublicKey failed: %v", err)
+	}
+	if !publicKey.Verify(z, parsedSig) {
+		t.Error("signature does not verify")
+	}
+
+	address, err := SegwitAddress(0, Hash160(key.Point.Serialize(true)), false)
+	if err != nil {
+		t.Fatalf("SegwitAddress failed: %v", err)
+	}
+	if version, program, err := DecodeSegwitAddress(address, false); err != nil || version != 0 || len(program) != 20 {
+		t.Errorf("DecodeSegwitAddress(%s) = %d, %x, %v", address, version, program, err)
+	}
+}
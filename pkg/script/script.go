@@ -0,0 +1,87 @@
+// Package script is the public API for Bitcoin scripts: parsing them, creating the standard output scripts
+// and selecting the verification rules. The types are those of the internal implementation, the functions
+// here are the supported way to create them.
+package script
+
+import (
+	iscript "github.com/caspereijkens/cryptocurrency/internal/script"
+)
+
+// Script is a parsed script, a list of opcodes and data pushes
+type Script = iscript.Script
+
+// VerificationFlags selects the rules scripts are verified with
+type VerificationFlags = iscript.VerificationFlags
+
+const (
+	VerifyP2SH                = iscript.ScriptVerifyP2SH
+	VerifyDERSig              = iscript.ScriptVerifyDERSig
+	VerifyCheckLockTimeVerify = iscript.ScriptVerifyCheckLockTimeVerify
+	VerifyCheckSequenceVerify = iscript.ScriptVerifyCheckSequenceVerify
+	VerifyMinimalIf           = iscript.ScriptVerifyMinimalIf
+	VerifyMinimalData         = iscript.ScriptVerifyMinimalData
+	VerifyWitness             = iscript.ScriptVerifyWitness
+	VerifyTaproot             = iscript.ScriptVerifyTaproot
+	// VerifyDiscourageUpgradableWitnessProgram rejects spends of witness versions no soft fork has defined yet
+	VerifyDiscourageUpgradableWitnessProgram = iscript.ScriptVerifyDiscourageUpgradableWitnessProgram
+	// VerifyStandard are the rules enforced on new transactions
+	VerifyStandard = iscript.StandardVerifyFlags
+	// VerifyPolicy adds the relay policy of Bitcoin Core to all soft forks
+	VerifyPolicy = iscript.PolicyVerifyFlags
+)
+
+// Num is a number as scripts encode it, for the values of OP_CHECKLOCKTIMEVERIFY and the counts of multisig
+type Num = iscript.ScriptNum
+
+// ParseNum decodes a numeric operand of at most maxLen bytes, minimally encoded when requireMinimal is set
+func ParseNum(element []byte, maxLen int, requireMinimal bool) (Num, error) {
+	return iscript.ParseScriptNum(element, maxLen, requireMinimal)
+}
+
+// Builder assembles a script from op codes by name and minimally encoded pushes
+type Builder = iscript.Builder
+
+// NewBuilder starts an empty script, such as NewBuilder().Op("OP_RETURN").PushData(payload).Script()
+func NewBuilder() *Builder {
+	return iscript.NewBuilder()
+}
+
+// Parse parses raw script bytes without a length prefix
+func Parse(raw []byte) (*Script, error) {
+	return iscript.ParseRawScript(raw)
+}
+
+// P2PKH returns the output script paying to the hash160 of a public key
+func P2PKH(h160 []byte) *Script {
+	return iscript.CreateP2pkhScript(h160)
+}
+
+// P2SH returns the output script paying to the hash160 of a redeem script
+func P2SH(h160 []byte) *Script {
+	return iscript.CreateP2SHScript(h160)
+}
+
+// P2WPKH returns the segwit v0 output script paying to the hash160 of a compressed public key
+func P2WPKH(h160 []byte) *Script {
+	return iscript.CreateP2WPKHScript(h160)
+}
+
+// P2WSH returns the segwit v0 output script paying to the sha256 of a witness script
+func P2WSH(s256 []byte) *Script {
+	return iscript.CreateP2WSHScript(s256)
+}
+
+// P2TR returns the taproot output script paying to a 32 byte x-only output key
+func P2TR(outputKey []byte) *Script {
+	return iscript.CreateP2TRScript(outputKey)
+}
+
+// P2SHAddress returns the p2sh address of a redeem script, hashing its serialization
+func P2SHAddress(redeemScript *Script, testnet bool) (string, error) {
+	return iscript.ScriptToP2SHAddress(redeemScript, testnet)
+}
+
+// P2WSHAddress returns the p2wsh address of a witness script, hashing its serialization
+func P2WSHAddress(witnessScript *Script, testnet bool) (string, error) {
+	return iscript.ScriptToP2WSHAddress(witnessScript, testnet)
+}
@@ -0,0 +1,56 @@
+package script
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	raw, _ := hex.DecodeString("76a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac")
+	s, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	h160, _ := hex.DecodeString("bc3b654dca7e56b04dca18f2566cdaf02e8d9ada")
+	serialized, _ := s.RawSerialize()
+	expected, _ := P2PKH(h160).RawSerialize()
+	if hex.EncodeToString(serialized) != hex.EncodeToString(expected) {
+		t.Errorf("parsed %x, P2PKH created %x", serialized, expected)
+	}
+	if !P2SH(h160).IsP2SHScriptPubKey() {
+		t.Error("P2SH did not create a p2sh script")
+	}
+	if VerifyStandard&VerifyP2SH == 0 {
+		t.Error("standard rules should include P2SH")
+	}
+}
+
+func TestNum(t *testing.T) {
+	encoded := Num(500000).Bytes()
+	n, err := ParseNum(encoded, 5, true)
+	if err != nil || n != 500000 {
+		t.Errorf("ParseNum(%x) = %d, %v", encoded, n, err)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	h160, _ := hex.DecodeString("bc3b654dca7e56b04dca18f2566cdaf02e8d9ada")
+	s, err := NewBuilder().Ops("OP_HASH160").PushData(h160).Op("OP_EQUAL").Script()
+	if err != nil {
+		t.Fatalf("Script error: %v", err)
+	}
+	if !s.Equal(P2SH(h160)) {
+		t.Errorf("Expected the p2sh script, got %v", s)
+	}
+}
+
+func TestScriptAddresses(t *testing.T) {
+	pubkey, _ := hex.DecodeString("0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	witnessScript := NewBuilder().PushData(pubkey).Op("OP_CHECKSIG").MustScript()
+	if address, err := P2WSHAddress(witnessScript, false); err != nil || address != "bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3" {
+		t.Errorf("P2WSHAddress = %s, %v", address, err)
+	}
+	if address, err := P2SHAddress(witnessScript, false); err != nil || address[0] != '3' {
+		t.Errorf("P2SHAddress = %s, %v, want a mainnet p2sh address", address, err)
+	}
+}
@@ -0,0 +1,72 @@
+// Package tx is the public API for Bitcoin transactions: parsing and creating them, signing and
+// verifying inputs, and fetching the transactions they spend.
+package tx
+
+import (
+	"net/http"
+
+	"github.com/caspereijkens/cryptocurrency/internal/transaction"
+	"github.com/caspereijkens/cryptocurrency/pkg/script"
+)
+
+type (
+	Tx = transaction.Tx
+	// TxIn spends the output an OutPoint refers to
+	TxIn  = transaction.TxIn
+	TxOut = transaction.TxOut
+	// OutPoint refers to an output by the id of its transaction and its index
+	OutPoint = transaction.OutPoint
+	// PrevoutLookup returns the output spent by an input
+	PrevoutLookup = transaction.PrevoutLookup
+	// Fetcher fetches transactions from an Esplora API and caches them
+	Fetcher = transaction.TxFetcher
+)
+
+// The signature hash types
+const (
+	SigHashDefault      = transaction.SigHashDefault
+	SigHashAll          = transaction.SigHashAll
+	SigHashNone         = transaction.SigHashNone
+	SigHashSingle       = transaction.SigHashSingle
+	SigHashAnyoneCanPay = transaction.SigHashAnyoneCanPay
+)
+
+// New creates a transaction
+func New(version uint32, inputs []*TxIn, outputs []*TxOut, locktime uint32, testnet bool) *Tx {
+	return transaction.NewTx(version, inputs, outputs, locktime, testnet)
+}
+
+// NewInput creates an input spending prevOut
+func NewInput(prevOut OutPoint, scriptSig *script.Script, sequence uint32) *TxIn {
+	return transaction.NewTxInFromOutPoint(prevOut, scriptSig, sequence)
+}
+
+// NewOutput creates an output paying amount satoshis to scriptPubkey
+func NewOutput(amount uint64, scriptPubkey *script.Script) *TxOut {
+	return transaction.NewTxOut(amount, scriptPubkey)
+}
+
+// NewOutPoint refers to output index of the transaction with txid in wire byte order
+func NewOutPoint(txid []byte, index uint32) OutPoint {
+	return transaction.NewOutPoint(txid, index)
+}
+
+// ParseOutPoint parses an outpoint written as txid:index
+func ParseOutPoint(s string) (OutPoint, error) {
+	return transaction.OutPointFromString(s)
+}
+
+// Parse parses a serialized transaction, with or without witness data. Trailing bytes are an error.
+func Parse(raw []byte, testnet bool) (*Tx, error) {
+	return transaction.ParseTxStrict(raw, testnet)
+}
+
+// NewFetcher returns a fetcher using the default Esplora API
+func NewFetcher() *Fetcher {
+	return transaction.NewTxFetcher()
+}
+
+// NewFetcherWithClient returns a fetcher that makes its requests with client
+func NewFetcherWithClient(client *http.Client, userAgent string) *Fetcher {
+	return transaction.NewTxFetcherWithClient(client, userAgent)
+}
@@ -0,0 +1,44 @@
+package tx
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/caspereijkens/cryptocurrency/pkg/script"
+)
+
+const bookTx = "0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600"
+
+func TestParse(t *testing.T) {
+	raw, _ := hex.DecodeString(bookTx)
+	tx, err := Parse(raw, false)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if id, _ := tx.Id(); id != "452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03" {
+		t.Errorf("got txid %s", id)
+	}
+	if _, err := Parse(append(raw, 0x00), false); err == nil {
+		t.Error("Parse should reject trailing bytes")
+	}
+}
+
+func TestNew(t *testing.T) {
+	prevOut, err := ParseOutPoint("452c629d67e41baec3ac6f04fe744b4b9617f8f859c63b3002f8684e7a4fee03:1")
+	if err != nil {
+		t.Fatalf("ParseOutPoint failed: %v", err)
+	}
+	tx := New(2, []*TxIn{NewInput(prevOut, &script.Script{}, 0xfffffffd)}, []*TxOut{NewOutput(1000, script.P2WPKH(make([]byte, 20)))}, 0, true)
+
+	raw, err := tx.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	parsed, err := Parse(raw, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if parsed.TxIns[0].PrevOut != prevOut || parsed.TxOuts[0].Amount != 1000 {
+		t.Errorf("transaction did not round trip: %s", parsed)
+	}
+}